@@ -13,14 +13,16 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
+	"forks/go-mysqlstack/driver"
 	"monitor"
 	"xbase/stats"
 	"xbase/sync2"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -48,6 +50,15 @@ type Connection interface {
 	Execute(string) (*sqltypes.Result, error)
 	ExecuteStreamFetch(string) (driver.Rows, error)
 	ExecuteWithLimits(query string, timeout int, maxmem int) (*sqltypes.Result, error)
+	SetSessionVars(vars map[string]string) error
+}
+
+// charsetVars are the session variables reset by 'SET NAMES' to the charset of the connection.
+var charsetVars = map[string]bool{
+	"character_set_client":     true,
+	"character_set_connection": true,
+	"character_set_results":    true,
+	"collation_connection":     true,
 }
 
 type connection struct {
@@ -64,6 +75,8 @@ type connection struct {
 	driver       driver.Conn
 	timestamp    int64 // Recycle timestamp, in seconds.
 	counters     *stats.Counters
+	// vars are the session variables applied to the connection, the value is the SQL literal.
+	vars map[string]string
 }
 
 // NewConnection creates a new connection.
@@ -242,10 +255,64 @@ func (c *connection) Kill(reason string) error {
 	return nil
 }
 
-// Recycle used to put current to pool.
+// SetSessionVars used to make the session variables of the connection same as the vars,
+// the changed ones are set and the ones not in vars are reset to the defaults by one SET.
+func (c *connection) SetSessionVars(vars map[string]string) error {
+	var names []string
+	for name, val := range vars {
+		if old, ok := c.vars[name]; !ok || old != val {
+			names = append(names, name)
+		}
+	}
+	var resets []string
+	resetNames := false
+	for name := range c.vars {
+		if _, ok := vars[name]; !ok {
+			if charsetVars[name] && c.charset != "" {
+				resetNames = true
+				continue
+			}
+			resets = append(resets, fmt.Sprintf("%s = DEFAULT", name))
+		}
+	}
+	if len(names) == 0 && len(resets) == 0 && !resetNames {
+		return nil
+	}
+
+	// The resets go first, 'SET NAMES' overwrites the character_set_* which are still set.
+	sort.Strings(resets)
+	if resetNames {
+		resets = append([]string{fmt.Sprintf("names '%s'", c.charset)}, resets...)
+		for name, val := range vars {
+			if charsetVars[name] && c.vars[name] == val {
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	exprs := resets
+	for _, name := range names {
+		exprs = append(exprs, fmt.Sprintf("%s = %s", name, vars[name]))
+	}
+	if _, err := c.Execute("set " + strings.Join(exprs, ", ")); err != nil {
+		return err
+	}
+
+	c.vars = make(map[string]string, len(vars))
+	for name, val := range vars {
+		c.vars[name] = val
+	}
+	return nil
+}
+
+// Recycle used to put current to pool, the session variables are reset before.
 func (c *connection) Recycle() {
 	defer mysqlStats.Record("conn.recycle", time.Now())
 	if !c.driver.Closed() {
+		if err := c.SetSessionVars(nil); err != nil {
+			c.Close()
+			return
+		}
 		c.pool.Put(c)
 	}
 }
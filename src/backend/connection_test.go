@@ -18,6 +18,7 @@ import (
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -94,6 +95,88 @@ func TestConnectionRecyle(t *testing.T) {
 	}
 }
 
+func TestConnectionSessionVars(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	// MySQL Server starts...
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+
+	// Connection
+	conn, cleanup := MockClient(log, addr)
+	defer cleanup()
+
+	// set.
+	{
+		query := "set sql_mode = 'ANSI', time_zone = '+08:00'"
+		fakedb.AddQuery(query, &sqltypes.Result{})
+		vars := map[string]string{"sql_mode": "'ANSI'", "time_zone": "'+08:00'"}
+		err := conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+
+		// The same vars are not set again.
+		err = conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(query))
+	}
+
+	// change.
+	{
+		fakedb.AddQuery("set time_zone = DEFAULT, character_set_client = 'latin1', character_set_results = 'latin1'", &sqltypes.Result{})
+		vars := map[string]string{"sql_mode": "'ANSI'", "character_set_client": "'latin1'", "character_set_results": "'latin1'"}
+		err := conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+
+		// The character_set_* are reset by the names.
+		fakedb.AddQuery("set names 'utf8', character_set_client = 'latin1'", &sqltypes.Result{})
+		vars = map[string]string{"sql_mode": "'ANSI'", "character_set_client": "'latin1'"}
+		err = conn.SetSessionVars(vars)
+		assert.Nil(t, err)
+	}
+
+	// error.
+	{
+		fakedb.AddQueryError("set foreign_key_checks = xx", errors.New("mock.set.error"))
+		vars := map[string]string{"sql_mode": "'ANSI'", "character_set_client": "'latin1'", "foreign_key_checks": "xx"}
+		err := conn.SetSessionVars(vars)
+		assert.NotNil(t, err)
+	}
+
+	// recycle.
+	{
+		query := "set names 'utf8', sql_mode = DEFAULT"
+		fakedb.AddQuery(query, &sqltypes.Result{})
+		conn.Recycle()
+		assert.Equal(t, 1, fakedb.GetQueryCalledNum(query))
+		assert.False(t, conn.Closed())
+	}
+}
+
+func TestConnectionSessionVarsResetError(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	// MySQL Server starts...
+	fakedb := fakedb.New(log, 1)
+	defer fakedb.Close()
+	addr := fakedb.Addrs()[0]
+
+	// Connection
+	conn, cleanup := MockClient(log, addr)
+	defer cleanup()
+
+	{
+		fakedb.AddQuery("set sql_mode = 'ANSI'", &sqltypes.Result{})
+		err := conn.SetSessionVars(map[string]string{"sql_mode": "'ANSI'"})
+		assert.Nil(t, err)
+
+		// The connection is closed if the reset fails.
+		fakedb.AddQueryError("set sql_mode = DEFAULT", errors.New("mock.reset.error"))
+		conn.Recycle()
+		assert.True(t, conn.Closed())
+	}
+}
+
 func TestConnectionKill(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
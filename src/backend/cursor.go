@@ -0,0 +1,261 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package backend
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"forks/go-mysqlstack/driver"
+	"xbase/sync2"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// maxCursorOpens is the max number of the cursors opening at the same time.
+	maxCursorOpens = 16
+)
+
+// Cursor used to fetch the rows of one backend query one by one.
+type Cursor interface {
+	Fields() []*querypb.Field
+	// Next returns the next row, nil if there's no more rows.
+	Next() ([]sqltypes.Value, error)
+	Close() error
+}
+
+// streamCursor is the cursor on the backend stream.
+type streamCursor struct {
+	conn      Connection
+	rows      driver.Rows
+	timeout   int
+	maxResult int
+	end       bool
+	killed    sync2.AtomicBool
+	timer     *time.Timer
+	killDone  chan struct{}
+}
+
+// newStreamCursor creates the cursor, the query is killed if it's not finished in timeout ms.
+func newStreamCursor(conn Connection, timeout int, maxResult int) *streamCursor {
+	c := &streamCursor{
+		conn:      conn,
+		timeout:   timeout,
+		maxResult: maxResult,
+	}
+	if timeout > 0 {
+		c.killDone = make(chan struct{})
+		c.timer = time.AfterFunc(time.Duration(timeout)*time.Millisecond, func() {
+			defer close(c.killDone)
+			c.killed.Set(true)
+			conn.Kill("cursor.timeout")
+		})
+	}
+	return c
+}
+
+// open used to execute the query and open the stream.
+func (c *streamCursor) open(query string) error {
+	rows, err := c.conn.ExecuteStreamFetch(query)
+	if err != nil {
+		c.stopTimer()
+		if c.killed.Get() {
+			return fmt.Errorf("Query execution was interrupted, timeout[%dms] exceeded", c.timeout)
+		}
+		return err
+	}
+	c.rows = rows
+	return nil
+}
+
+// stopTimer used to stop the timer and wait for the kill if it's fired.
+func (c *streamCursor) stopTimer() {
+	if c.timer != nil && !c.timer.Stop() {
+		<-c.killDone
+	}
+}
+
+// Fields returns the fields.
+func (c *streamCursor) Fields() []*querypb.Field {
+	return c.rows.Fields()
+}
+
+// Next returns the next row.
+func (c *streamCursor) Next() ([]sqltypes.Value, error) {
+	if c.end {
+		return nil, nil
+	}
+	if c.rows.Next() {
+		row, err := c.rows.RowValues()
+		if err != nil {
+			return nil, err
+		}
+		if c.maxResult > 0 && c.rows.Bytes() > c.maxResult {
+			return nil, fmt.Errorf("Query execution was interrupted, max memory usage[%d bytes] exceeded", c.maxResult)
+		}
+		return row, nil
+	}
+	c.end = true
+	if err := c.rows.LastError(); err != nil {
+		if c.killed.Get() {
+			return nil, fmt.Errorf("Query execution was interrupted, timeout[%dms] exceeded", c.timeout)
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// Close used to close the stream. If the rows are not all read, the connection is closed instead
+// of draining the rest rows, the backend stops the query when it writes to the closed connection.
+func (c *streamCursor) Close() error {
+	c.stopTimer()
+	if !c.end {
+		c.end = true
+		c.conn.Close()
+	}
+	return nil
+}
+
+// resultCursor is the cursor on a fetched result.
+type resultCursor struct {
+	qr  *sqltypes.Result
+	idx int
+}
+
+// Fields returns the fields.
+func (c *resultCursor) Fields() []*querypb.Field {
+	return c.qr.Fields
+}
+
+// Next returns the next row.
+func (c *resultCursor) Next() ([]sqltypes.Value, error) {
+	if c.idx >= len(c.qr.Rows) {
+		return nil, nil
+	}
+	row := c.qr.Rows[c.idx]
+	c.idx++
+	return row, nil
+}
+
+// Close impl.
+func (c *resultCursor) Close() error {
+	return nil
+}
+
+// ExecuteCursors used to execute the querys of the req and return a cursor for each of them in order,
+// the caller must close the cursors.
+// In twopc mode, the querys on the same backend share one connection which can't hold more than one
+// stream, so the results are fetched into memory, and so do the querys whose FOUND_ROWS() are fetched
+// after them, the stream may be closed before its end.
+// The cursors are limited by the timeout and the max result size of the txn as the Execute, but if the
+// spilling is enabled, the rows are spilled to disk by the caller, the max result size is checked on
+// the output rows by the caller instead.
+func (txn *Txn) ExecuteCursors(req *xcontext.RequestContext) ([]Cursor, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	if txn.aborted() {
+		return nil, errors.Errorf("txn.was.aborted")
+	}
+	if txn.twopc {
+		txn.state.Set(int32(txnStateExecutingTwoPC))
+	} else {
+		txn.state.Set(int32(txnStateExecutingNormal))
+	}
+
+	cursors := make([]Cursor, len(req.Querys))
+	allErrors := make([]error, 0, 8)
+	closeAll := func() {
+		for _, cursor := range cursors {
+			if cursor != nil {
+				cursor.Close()
+			}
+		}
+	}
+
+	maxResult := txn.maxResult
+	if txn.maxQueryMemory > 0 {
+		maxResult = 0
+	}
+
+	if txn.twopc || req.FoundRows != nil {
+		backends := make(map[string][]int)
+		for i, qt := range req.Querys {
+			backends[qt.Backend] = append(backends[qt.Backend], i)
+		}
+		oneShard := func(idxs []int) {
+			defer wg.Done()
+			for _, i := range idxs {
+				sub := xcontext.NewRequestContext()
+				sub.Mode = xcontext.ReqNormal
+				sub.TxnMode = req.TxnMode
+				sub.Querys = []xcontext.QueryTuple{req.Querys[i]}
+				sub.FoundRows = req.FoundRows
+				qr, err := txn.executeWithLimits(sub, maxResult)
+				if err != nil {
+					mu.Lock()
+					allErrors = append(allErrors, err)
+					mu.Unlock()
+					return
+				}
+				cursors[i] = &resultCursor{qr: qr}
+			}
+		}
+		for _, idxs := range backends {
+			wg.Add(1)
+			go oneShard(idxs)
+		}
+	} else {
+		// The opens are bounded, the connections are not dialed to the backends all at once.
+		sem := make(chan struct{}, maxCursorOpens)
+		oneShard := func(i int, qt xcontext.QueryTuple) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			c, err := txn.normalConnection(qt.Backend)
+			if err == nil {
+				cursor := newStreamCursor(c, txn.timeout, maxResult)
+				if err = cursor.open(qt.Query); err == nil {
+					cursors[i] = cursor
+					return
+				}
+				txn.log.Error("txn.execute.cursor.on[%v].query[%v].error:%+v", c.Address(), qt.Query, err)
+			}
+			mu.Lock()
+			txn.incErrors()
+			allErrors = append(allErrors, err)
+			mu.Unlock()
+		}
+		for i, qt := range req.Querys {
+			sem <- struct{}{}
+			mu.Lock()
+			failed := len(allErrors) > 0
+			mu.Unlock()
+			if failed {
+				<-sem
+				break
+			}
+			wg.Add(1)
+			go oneShard(i, qt)
+		}
+	}
+	wg.Wait()
+
+	if len(allErrors) > 0 {
+		closeAll()
+		return nil, allErrors[0]
+	}
+	return cursors, nil
+}
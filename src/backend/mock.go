@@ -10,6 +10,7 @@ package backend
 
 import (
 	"fmt"
+	"os"
 	"time"
 
 	"config"
@@ -118,7 +119,8 @@ func MockScatterDefault2(dir string) *config.ScatterConfig {
 
 // MockScatter used to mock a scatter.
 func MockScatter(log *xlog.Log, n int) (*Scatter, *fakedb.DB, func()) {
-	scatter := NewScatter(log, "")
+	metadir := fakedb.GetTmpDir("", "radon_scatter_", log)
+	scatter := NewScatter(log, metadir)
 	fakedb := fakedb.New(log, n)
 	backends := make(map[string]*Pool)
 	addrs := fakedb.Addrs()
@@ -133,6 +135,7 @@ func MockScatter(log *xlog.Log, n int) (*Scatter, *fakedb.DB, func()) {
 	return scatter, fakedb, func() {
 		fakedb.Close()
 		scatter.Close()
+		os.RemoveAll(metadir)
 	}
 }
 
@@ -207,7 +210,8 @@ func MockTxnMgrWithAttach(log *xlog.Log, n int) (*fakedb.DB, *TxnManager, map[st
 // MockTxnMgrScatter used to mock a txnMgr and a scatter.
 // commit err and rollback err will WriteXaCommitErrLog, need the scatter
 func MockTxnMgrScatter(log *xlog.Log, n int) (*fakedb.DB, *TxnManager, map[string]*Pool, []string, *Scatter, func()) {
-	scatter := NewScatter(log, "")
+	metadir := fakedb.GetTmpDir("", "radon_scatter_", log)
+	scatter := NewScatter(log, metadir)
 	fakedb := fakedb.New(log, n)
 	backends := make(map[string]*Pool)
 	addrs := fakedb.Addrs()
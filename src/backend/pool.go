@@ -169,3 +169,8 @@ func (p *Pool) JSON() string {
 	fmt.Fprintf(b, `{"name": "%s","capacity": %d, "counters":"%s"}`, p.conf.Name, p.conf.MaxConnections, p.counters.String())
 	return b.String()
 }
+
+// Stats returns the number of the idle connections and the copy of the counters.
+func (p *Pool) Stats() (int, map[string]int64) {
+	return len(p.getConns()), p.counters.Counts()
+}
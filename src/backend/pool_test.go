@@ -14,9 +14,10 @@ import (
 	"testing"
 	"time"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -53,6 +54,10 @@ func TestPool(t *testing.T) {
 		want := "{\"name\": \"node1\",\"capacity\": 64, \"counters\":\"{\"#pool.get\": 1, \"#pool.miss\": 1, \"#pool.put\": 164}\"}"
 		got := pool.JSON()
 		assert.Equal(t, want, got)
+
+		idle, counts := pool.Stats()
+		assert.Equal(t, 64, idle)
+		assert.Equal(t, int64(1), counts["#pool.miss"])
 	}
 
 	// clean
@@ -33,6 +33,8 @@ type Scatter struct {
 	txnMgr   *TxnManager
 	metadir  string
 	backends map[string]*Pool
+	// sequenceBackend is the backend pinned to store the sequences.
+	sequenceBackend string
 }
 
 // NewScatter creates a new scatter.
@@ -120,7 +122,7 @@ func (scatter *Scatter) FlushConfig() error {
 	log := scatter.log
 	file := path.Join(scatter.metadir, backendjson)
 
-	var backends config.BackendsConfig
+	backends := config.BackendsConfig{SequenceBackend: scatter.sequenceBackend}
 	for _, v := range scatter.backends {
 		backends.Backends = append(backends.Backends, v.conf)
 	}
@@ -175,9 +177,38 @@ func (scatter *Scatter) LoadConfig() error {
 		}
 		log.Info("scatter.load.backend:%+v", backend.Name)
 	}
+	scatter.sequenceBackend = conf.SequenceBackend
 	return nil
 }
 
+// SequenceBackend returns the backend pinned to store the sequences, empty if it's not pinned.
+func (scatter *Scatter) SequenceBackend() string {
+	scatter.mu.RLock()
+	defer scatter.mu.RUnlock()
+	return scatter.sequenceBackend
+}
+
+// PinSequenceBackend used to pin the backend to store the sequences if it's not pinned, and
+// writes it to file, so the sequences are not moved as the backends are added or renamed.
+// Returns the backend pinned.
+func (scatter *Scatter) PinSequenceBackend(backend string) (string, error) {
+	scatter.mu.Lock()
+	if scatter.sequenceBackend != "" {
+		defer scatter.mu.Unlock()
+		return scatter.sequenceBackend, nil
+	}
+	if _, ok := scatter.backends[backend]; !ok {
+		scatter.mu.Unlock()
+		return "", errors.Errorf("scatter.backend[%v].can.not.be.found", backend)
+	}
+	scatter.sequenceBackend = backend
+	scatter.mu.Unlock()
+	if err := scatter.FlushConfig(); err != nil {
+		return "", err
+	}
+	return backend, nil
+}
+
 // AllBackends returns all backends.
 func (scatter *Scatter) AllBackends() []string {
 	var backends []string
@@ -10,15 +10,16 @@ package backend
 
 import (
 	"fmt"
+	"strconv"
 	"sync"
 	"time"
 	"xcontext"
 
 	"config"
+	"forks/go-mysqlstack/driver"
 	"xbase/sync2"
 
 	"github.com/pkg/errors"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -60,6 +61,7 @@ type Transaction interface {
 	Finish() error
 
 	BeginScatter() error
+	BeginLocal() error
 	CommitScatter() error
 	RollbackScatter() error
 	SetMultiStmtTxn()
@@ -67,11 +69,21 @@ type Transaction interface {
 
 	SetTimeout(timeout int)
 	SetMaxResult(max int)
+	MaxResult() int
 	SetMaxJoinRows(max int)
 	MaxJoinRows() int
+	SetMaxQueryMemory(max int)
+	MaxQueryMemory() int
+	SetSpillDir(dir string)
+	SpillDir() string
+	SetGroupConcatMaxLen(max int)
+	GroupConcatMaxLen() int
+	SetSessionVars(vars map[string]string)
 
 	Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	ExecuteRaw(database string, query string) (*sqltypes.Result, error)
+	ExecuteCursors(req *xcontext.RequestContext) ([]Cursor, error)
+	ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*sqltypes.Result) error, streamBufferSize int) error
 }
 
 // Txn tuple.
@@ -85,6 +97,7 @@ type Txn struct {
 	req               *xcontext.RequestContext
 	txnd              *TxnDetail
 	twopc             bool
+	local             bool
 	isMultiStmtTxn    bool
 	start             time.Time
 	state             sync2.AtomicInt32
@@ -93,6 +106,10 @@ type Txn struct {
 	timeout           int
 	maxResult         int
 	maxJoinRows       int
+	maxQueryMemory    int
+	spillDir          string
+	groupConcatMaxLen int
+	sessionVars       map[string]string
 	errors            int
 	twopcConnections  map[string]Connection
 	normalConnections []Connection
@@ -129,6 +146,11 @@ func (txn *Txn) SetMaxResult(max int) {
 	txn.maxResult = max
 }
 
+// MaxResult returns txn maxResult.
+func (txn *Txn) MaxResult() int {
+	return txn.maxResult
+}
+
 // SetMaxJoinRows used to set the txn max join rows.
 func (txn *Txn) SetMaxJoinRows(max int) {
 	txn.maxJoinRows = max
@@ -139,6 +161,41 @@ func (txn *Txn) MaxJoinRows() int {
 	return txn.maxJoinRows
 }
 
+// SetMaxQueryMemory used to set the txn max memory before spilling to disk.
+func (txn *Txn) SetMaxQueryMemory(max int) {
+	txn.maxQueryMemory = max
+}
+
+// MaxQueryMemory returns txn maxQueryMemory.
+func (txn *Txn) MaxQueryMemory() int {
+	return txn.maxQueryMemory
+}
+
+// SetSpillDir used to set the txn spill directory.
+func (txn *Txn) SetSpillDir(dir string) {
+	txn.spillDir = dir
+}
+
+// SpillDir returns txn spillDir.
+func (txn *Txn) SpillDir() string {
+	return txn.spillDir
+}
+
+// SetGroupConcatMaxLen used to set the txn max length of the group_concat result.
+func (txn *Txn) SetGroupConcatMaxLen(max int) {
+	txn.groupConcatMaxLen = max
+}
+
+// GroupConcatMaxLen returns txn groupConcatMaxLen.
+func (txn *Txn) GroupConcatMaxLen() int {
+	return txn.groupConcatMaxLen
+}
+
+// SetSessionVars used to set the session variables which are applied to the txn connections.
+func (txn *Txn) SetSessionVars(vars map[string]string) {
+	txn.sessionVars = vars
+}
+
 // TxID returns txn id.
 func (txn *Txn) TxID() uint64 {
 	return txn.id
@@ -159,6 +216,11 @@ func (txn *Txn) XaState() int32 {
 	return txn.xaState.Get()
 }
 
+// aborted returns true if the txn was aborted.
+func (txn *Txn) aborted() bool {
+	return txn.state.Get() == int32(txnStateAborting)
+}
+
 func (txn *Txn) incErrors() {
 	txn.errors++
 }
@@ -181,9 +243,24 @@ func (txn *Txn) twopcConnection(backend string) (Connection, error) {
 		if err != nil {
 			return nil, err
 		}
+		if err = conn.SetSessionVars(txn.sessionVars); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		if txn.local {
+			if _, err = conn.Execute("begin"); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
 		txn.twopcConnMu.Lock()
 		txn.twopcConnections[backend] = conn
 		txn.twopcConnMu.Unlock()
+		return conn, nil
+	}
+	// The session variables may be changed in the multiple-statement txn.
+	if err = conn.SetSessionVars(txn.sessionVars); err != nil {
+		return nil, err
 	}
 	return conn, nil
 }
@@ -211,6 +288,10 @@ func (txn *Txn) normalConnection(backend string) (Connection, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err = conn.SetSessionVars(txn.sessionVars); err != nil {
+		conn.Close()
+		return nil, err
+	}
 	txn.normalConnMu.Lock()
 	txn.normalConnections = append(txn.normalConnections, conn)
 	txn.normalConnMu.Unlock()
@@ -220,7 +301,13 @@ func (txn *Txn) normalConnection(backend string) (Connection, error) {
 func (txn *Txn) fetchOneConnection(back string) (Connection, error) {
 	var err error
 	var conn Connection
-	if txn.twopc {
+
+	// The connections fetched after the abort can't be killed.
+	if txn.aborted() {
+		return nil, errors.Errorf("txn.was.aborted")
+	}
+	// The connections of the local txn are kept until it ends, the same as the twopc.
+	if txn.twopc || txn.local {
 		if conn, err = txn.twopcConnection(back); err != nil {
 			return nil, err
 		}
@@ -305,9 +392,53 @@ func (txn *Txn) BeginScatter() error {
 	return txn.xaStart()
 }
 
+// BeginLocal used to start the multiple-statement transaction without the twopc, the BEGIN is
+// sent to the backend connection when it's fetched, and the COMMIT or ROLLBACK to all of them
+// by the CommitScatter or RollbackScatter, it isn't atomic across the backends.
+func (txn *Txn) BeginLocal() error {
+	txnCounters.Add(txnCounterTxnBegin, 1)
+	txn.local = true
+	return nil
+}
+
+// endLocal used to send the COMMIT or ROLLBACK to all the connections of the local transaction.
+func (txn *Txn) endLocal(query string) error {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	log := txn.log
+	allErrors := make([]error, 0, 8)
+
+	txn.twopcConnMu.RLock()
+	for back, conn := range txn.twopcConnections {
+		wg.Add(1)
+		go func(back string, c Connection) {
+			defer wg.Done()
+			if _, x := c.Execute(query); x != nil {
+				log.Error("txn.local.execute.on[%v].query[%v].error:%+v", back, query, x)
+				mu.Lock()
+				allErrors = append(allErrors, x)
+				mu.Unlock()
+			}
+		}(back, conn)
+	}
+	txn.twopcConnMu.RUnlock()
+	wg.Wait()
+
+	if len(allErrors) > 0 {
+		txn.incErrors()
+		return allErrors[0]
+	}
+	// The connections are out of the transaction, they can be recycled.
+	txn.local = false
+	return nil
+}
+
 // CommitScatter is used in the multiple-statement transaction
 func (txn *Txn) CommitScatter() error {
 	txn.state.Set(int32(txnStateCommitting))
+	if txn.local {
+		return txn.endLocal("commit")
+	}
 	txn.twopc = true
 	txn.req = xcontext.NewRequestContext()
 	txn.req.Mode = xcontext.ReqScatter
@@ -331,6 +462,10 @@ func (txn *Txn) CommitScatter() error {
 func (txn *Txn) RollbackScatter() error {
 	log := txn.log
 	txn.state.Set(int32(txnStateRollbacking))
+	if txn.local {
+		log.Warning("txn.rollback.local.txid[%v]", txn.id)
+		return txn.endLocal("rollback")
+	}
 	txn.twopc = true
 	txn.req = xcontext.NewRequestContext()
 	txn.req.Mode = xcontext.ReqScatter
@@ -369,6 +504,11 @@ func (txn *Txn) ExecuteRaw(database string, query string) (*sqltypes.Result, err
 // Execute used to execute the query.
 // If the txn is in twopc mode, we do the xaStart before the real query execute.
 func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+	return txn.executeWithLimits(req, txn.maxResult)
+}
+
+// executeWithLimits used to execute the query with the max result size.
+func (txn *Txn) executeWithLimits(req *xcontext.RequestContext, maxResult int) (*sqltypes.Result, error) {
 	if txn.twopc {
 		// DATA RACE in the same txn e.g, UNION etc.
 		txn.mu.Lock()
@@ -389,7 +529,7 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 			}
 		}
 	}
-	qr, err := txn.execute(req)
+	qr, err := txn.execute(req, maxResult)
 	if err != nil {
 		txn.incErrors()
 		return nil, err
@@ -398,7 +538,7 @@ func (txn *Txn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 }
 
 // Execute used to execute a query to backends.
-func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+func (txn *Txn) execute(req *xcontext.RequestContext, maxResult int) (*sqltypes.Result, error) {
 	var err error
 	var mu sync.Mutex
 	var wg sync.WaitGroup
@@ -407,6 +547,9 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	qr := &sqltypes.Result{}
 	allErrors := make([]error, 0, 8)
 
+	if txn.aborted() {
+		return nil, errors.Errorf("txn.was.aborted")
+	}
 	if txn.twopc {
 		defer queryStats.Record("txn.2pc.execute", time.Now())
 		txn.state.Set(int32(txnStateExecutingTwoPC))
@@ -429,10 +572,22 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 				var innerqr *sqltypes.Result
 
 				// Execute to backends.
-				if innerqr, x = c.ExecuteWithLimits(query, txn.timeout, txn.maxResult); x != nil {
+				start := time.Now()
+				if innerqr, x = c.ExecuteWithLimits(query, txn.timeout, maxResult); x != nil {
 					log.Error("txn.execute.on[%v].query[%v].error:%+v", c.Address(), query, x)
 					break
 				}
+				if req.Trace != nil {
+					req.Trace(back, query, time.Since(start), len(innerqr.Rows))
+				}
+				if req.FoundRows != nil {
+					var found uint64
+					if found, x = fetchFoundRows(c); x != nil {
+						log.Error("txn.fetch.found.rows.on[%v].query[%v].error:%+v", c.Address(), query, x)
+						break
+					}
+					req.FoundRows(found)
+				}
 				mu.Lock()
 				qr.AppendResult(innerqr)
 				mu.Unlock()
@@ -507,6 +662,18 @@ func (txn *Txn) execute(req *xcontext.RequestContext) (*sqltypes.Result, error)
 	return qr, err
 }
 
+// fetchFoundRows returns the FOUND_ROWS() of the last query on the connection.
+func fetchFoundRows(c Connection) (uint64, error) {
+	qr, err := c.Execute("SELECT FOUND_ROWS()")
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != 1 {
+		return 0, errors.Errorf("txn.found.rows.unexpected.result:%v", qr.Rows)
+	}
+	return strconv.ParseUint(qr.Rows[0][0].ToString(), 10, 64)
+}
+
 // ExecuteStreamFetch used to execute stream fetch query.
 func (txn *Txn) ExecuteStreamFetch(req *xcontext.RequestContext, callback func(*sqltypes.Result) error, streamBufferSize int) error {
 	var err error
@@ -698,6 +865,7 @@ func (txn *Txn) Finish() error {
 	defer tz.Remove(txn.txnd)
 	defer func() {
 		txn.twopc = false
+		txn.local = false
 		txn.isMultiStmtTxn = false
 	}()
 
@@ -709,9 +877,9 @@ func (txn *Txn) Finish() error {
 	txn.xaState.Set(int32(txnXAStateNone))
 	txn.state.Set(int32(txnStateFinshing))
 
-	// 2pc connections.
+	// 2pc connections, the connections of the local txn not ended are closed to roll it back.
 	for id, conn := range txn.twopcConnections {
-		if txn.errors > 0 {
+		if txn.errors > 0 || txn.local {
 			conn.Close()
 		} else {
 			conn.Recycle()
@@ -741,6 +909,7 @@ func (txn *Txn) Abort() error {
 	defer tz.Remove(txn.txnd)
 	defer func() {
 		txn.twopc = false
+		txn.local = false
 		txn.isMultiStmtTxn = false
 	}()
 
@@ -11,7 +11,9 @@ package backend
 import (
 	"errors"
 	"fmt"
+	"sync"
 	"testing"
+	"time"
 
 	"xcontext"
 
@@ -93,6 +95,58 @@ func TestTxnNormalExecute(t *testing.T) {
 		want.AppendResult(result1)
 		assert.Equal(t, want, got)
 	}
+
+	// trace execute.
+	{
+		var mu sync.Mutex
+		traces := make(map[string]int)
+		rctx := &xcontext.RequestContext{
+			Querys: querys,
+			Trace: func(backend string, query string, latency time.Duration, rows int) {
+				mu.Lock()
+				defer mu.Unlock()
+				traces[backend+":"+query] = rows
+			},
+		}
+
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		_, err = txn.Execute(rctx)
+		assert.Nil(t, err)
+
+		want := map[string]int{
+			addrs[0] + ":" + querys[0].Query: len(result1.Rows),
+			addrs[1] + ":" + querys[1].Query: len(result2.Rows),
+			addrs[1] + ":" + querys[2].Query: len(result2.Rows),
+		}
+		assert.Equal(t, want, traces)
+	}
+}
+
+func TestTxnExecuteAfterAbort(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0]},
+	}
+	fakedb.AddQuery(querys[0].Query, result1)
+
+	txn, err := txnMgr.CreateTxn(backends)
+	assert.Nil(t, err)
+	defer txn.Finish()
+
+	err = txn.Abort()
+	assert.Nil(t, err)
+	rctx := &xcontext.RequestContext{
+		Querys: querys,
+	}
+	_, err = txn.Execute(rctx)
+	assert.EqualError(t, err, "txn.was.aborted")
 }
 
 func TestTxnNormalExecuteWithAttach(t *testing.T) {
@@ -251,6 +305,177 @@ func TestTxnExecuteStreamFetch(t *testing.T) {
 	}
 }
 
+func TestTxnExecuteCursors(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	querys := []xcontext.QueryTuple{
+		xcontext.QueryTuple{Query: "select * from node1", Backend: addrs[0]},
+		xcontext.QueryTuple{Query: "select * from node2", Backend: addrs[1]},
+		xcontext.QueryTuple{Query: "select * from node3", Backend: addrs[1]},
+	}
+
+	newResult := func(id string, n int) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "id",
+					Type: querypb.Type_INT32,
+				},
+			},
+		}
+		for i := 0; i < n; i++ {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id))})
+		}
+		return rs
+	}
+	fakedb.AddQueryStream(querys[0].Query, newResult("1", 3))
+	fakedb.AddQueryStream(querys[1].Query, newResult("2", 2))
+	fakedb.AddQueryStream(querys[2].Query, newResult("3", 1))
+	fakedb.AddQueryPattern("XA .*", &sqltypes.Result{})
+
+	fetch := func(cursors []Cursor) string {
+		var all [][]sqltypes.Value
+		for _, cursor := range cursors {
+			for {
+				row, err := cursor.Next()
+				assert.Nil(t, err)
+				if row == nil {
+					break
+				}
+				all = append(all, row)
+			}
+			cursor.Close()
+		}
+		return fmt.Sprintf("%v", all)
+	}
+	want := "[[1] [1] [1] [2] [2] [3]]"
+
+	// Normal.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		rctx := &xcontext.RequestContext{
+			Querys: querys,
+		}
+		cursors, err := txn.ExecuteCursors(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(cursors))
+		assert.Equal(t, "id", cursors[0].Fields()[0].Name)
+		assert.Equal(t, want, fetch(cursors))
+	}
+
+	// Twopc, the querys on the same backend.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		err = txn.Begin()
+		assert.Nil(t, err)
+
+		rctx := &xcontext.RequestContext{
+			TxnMode: xcontext.TxnRead,
+			Querys:  querys,
+		}
+		cursors, err := txn.ExecuteCursors(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, want, fetch(cursors))
+	}
+
+	// Error.
+	{
+		fakedb.AddQueryError(querys[1].Query, errors.New("mock.cursor.query.error"))
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		rctx := &xcontext.RequestContext{
+			Querys: querys,
+		}
+		_, err = txn.ExecuteCursors(rctx)
+		assert.Equal(t, "mock.cursor.query.error (errno 1105) (sqlstate HY000)", err.Error())
+	}
+
+	// Close before all the rows are read, the connection is closed without draining.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		rctx := &xcontext.RequestContext{
+			Querys: querys[:1],
+		}
+		cursors, err := txn.ExecuteCursors(rctx)
+		assert.Nil(t, err)
+		row, err := cursors[0].Next()
+		assert.Nil(t, err)
+		assert.Equal(t, "[1]", fmt.Sprintf("%v", row))
+		cursors[0].Close()
+		assert.True(t, cursors[0].(*streamCursor).conn.Closed())
+	}
+
+	// Max result.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxResult(1)
+
+		rctx := &xcontext.RequestContext{
+			Querys: querys[:1],
+		}
+		cursors, err := txn.ExecuteCursors(rctx)
+		assert.Nil(t, err)
+		_, err = cursors[0].Next()
+		assert.Nil(t, err)
+		_, err = cursors[0].Next()
+		assert.Equal(t, "Query execution was interrupted, max memory usage[1 bytes] exceeded", err.Error())
+		cursors[0].Close()
+
+		// The max result isn't checked if the spilling is enabled.
+		txn.SetMaxQueryMemory(1)
+		cursors, err = txn.ExecuteCursors(rctx)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1] [1] [1]]", fetch(cursors))
+	}
+
+	// Timeout.
+	{
+		fakedb.AddQueryDelay("select * from node4", newResult("4", 1), 1000)
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetTimeout(100)
+
+		rctx := &xcontext.RequestContext{
+			Querys: []xcontext.QueryTuple{
+				xcontext.QueryTuple{Query: "select * from node4", Backend: addrs[0]},
+			},
+		}
+		_, err = txn.ExecuteCursors(rctx)
+		assert.Equal(t, "Query execution was interrupted, timeout[100ms] exceeded", err.Error())
+	}
+
+	// Aborted.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		err = txn.Abort()
+		assert.Nil(t, err)
+
+		rctx := &xcontext.RequestContext{
+			Querys: querys,
+		}
+		_, err = txn.ExecuteCursors(rctx)
+		assert.Equal(t, "txn.was.aborted", err.Error())
+	}
+}
+
 func TestTxnNormalError(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -418,6 +643,118 @@ func TestTxnSetting(t *testing.T) {
 	}
 }
 
+func TestTxnSessionVars(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, addrs, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	query := "select * from node1"
+	set := "set sql_mode = 'ANSI', time_zone = '+08:00'"
+	reset := "set sql_mode = DEFAULT, time_zone = DEFAULT"
+	fakedb.AddQuery(query, result1)
+	fakedb.AddQuery(set, &sqltypes.Result{})
+	fakedb.AddQuery(reset, &sqltypes.Result{})
+
+	// normal.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		txn.SetSessionVars(map[string]string{"sql_mode": "'ANSI'", "time_zone": "'+08:00'"})
+		_, err = txn.ExecuteScatter(query)
+		assert.Nil(t, err)
+		txn.Finish()
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(set))
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum(reset))
+	}
+
+	// twopc.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		txn.SetSessionVars(map[string]string{"sql_mode": "'ANSI'", "time_zone": "'+08:00'"})
+		conn, err := txn.twopcConnection(addrs[0])
+		assert.Nil(t, err)
+		conn2, err := txn.twopcConnection(addrs[0])
+		assert.Nil(t, err)
+		assert.Equal(t, conn, conn2)
+		txn.Finish()
+		assert.Equal(t, 3, fakedb.GetQueryCalledNum(set))
+		assert.Equal(t, 3, fakedb.GetQueryCalledNum(reset))
+	}
+
+	// error.
+	{
+		fakedb.AddQueryError("set sql_mode = xx", errors.New("mock.set.error"))
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetSessionVars(map[string]string{"sql_mode": "xx"})
+		_, err = txn.ExecuteScatter(query)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestTxnLocal(t *testing.T) {
+	defer leaktest.Check(t)()
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedb, txnMgr, backends, _, cleanup := MockTxnMgr(log, 2)
+	defer cleanup()
+
+	query := "select * from node1"
+	fakedb.AddQuery(query, result1)
+	fakedb.AddQuery("begin", &sqltypes.Result{})
+	fakedb.AddQuery("commit", &sqltypes.Result{})
+	fakedb.AddQuery("rollback", &sqltypes.Result{})
+
+	// commit.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+		for i := 0; i < 2; i++ {
+			_, err = txn.ExecuteScatter(query)
+			assert.Nil(t, err)
+		}
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("begin"))
+		err = txn.CommitScatter()
+		assert.Nil(t, err)
+		txn.Finish()
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("commit"))
+		assert.Equal(t, 0, fakedb.GetQueryCalledNum("rollback"))
+	}
+
+	// rollback.
+	{
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+		_, err = txn.ExecuteScatter(query)
+		assert.Nil(t, err)
+		err = txn.RollbackScatter()
+		assert.Nil(t, err)
+		txn.Finish()
+		assert.Equal(t, 4, fakedb.GetQueryCalledNum("begin"))
+		assert.Equal(t, 2, fakedb.GetQueryCalledNum("rollback"))
+	}
+
+	// commit error.
+	{
+		fakedb.AddQueryError("commit", errors.New("mock.commit.error"))
+		txn, err := txnMgr.CreateTxn(backends)
+		assert.Nil(t, err)
+		err = txn.BeginLocal()
+		assert.Nil(t, err)
+		_, err = txn.ExecuteScatter(query)
+		assert.Nil(t, err)
+		err = txn.CommitScatter()
+		assert.NotNil(t, err)
+		txn.Finish()
+	}
+}
+
 func TestTxnExecuteTwopc(t *testing.T) {
 	defer leaktest.Check(t)()
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -38,6 +38,19 @@ type ProxyConfig struct {
 	LongQueryTime    int    `json:"long-query-time"`
 	StreamBufferSize int    `json:"stream-buffer-size"`
 	IdleTxnTimeout   uint32 `json:"kill-idle-transaction"` //is consistent with the official 8.0 kill_idle_transaction
+	MaxQueryMemory   int    `json:"max-query-memory"`      // 0 means spilling is disabled
+	SpillDir         string `json:"spill-dir"`
+	PlanCacheSize    int    `json:"plan-cache-size"` // 0 means the plan cache is disabled
+
+	// SchemaCheckInterval is the interval(in seconds) of the background schema drift check, 0 means disabled.
+	SchemaCheckInterval int `json:"schema-check-interval"`
+
+	// OnlineDDLConcurrency is the number of the partitions altered at the same time by the online DDL.
+	OnlineDDLConcurrency int `json:"online-ddl-concurrency"`
+	// OnlineDDLChunkSize is the number of the rows copied to the shadow table at a time by the online DDL.
+	OnlineDDLChunkSize int `json:"online-ddl-chunk-size"`
+	// OnlineDDLMaxThreadsRunning is the Threads_running of the backend above which the copy pauses, 0 means no throttle.
+	OnlineDDLMaxThreadsRunning int `json:"online-ddl-max-threads-running"`
 }
 
 // DefaultProxyConfig returns default proxy config.
@@ -54,6 +67,14 @@ func DefaultProxyConfig() *ProxyConfig {
 		LongQueryTime:    5,                // 5 seconds
 		StreamBufferSize: 1024 * 1024 * 32, // 32MB
 		IdleTxnTimeout:   60,               // 60 seconds
+		SpillDir:         "/tmp/radon_spill",
+		PlanCacheSize:    1024,
+
+		SchemaCheckInterval: 3600, // 1 hour
+
+		OnlineDDLConcurrency:       1,
+		OnlineDDLChunkSize:         1000,
+		OnlineDDLMaxThreadsRunning: 64,
 	}
 }
 
@@ -158,6 +179,8 @@ type BackendConfig struct {
 // BackendsConfig tuple.
 type BackendsConfig struct {
 	Backends []*BackendConfig `json:"backends"`
+	// SequenceBackend is the backend which stores the sequences, it's pinned when the sequences are first used.
+	SequenceBackend string `json:"sequence-backend,omitempty"`
 }
 
 // PartitionConfig tuple.
@@ -170,6 +193,19 @@ type PartitionConfig struct {
 // AutoIncrement tuple.
 type AutoIncrement struct {
 	Column string `json:"column"`
+	// Start is the table option AUTO_INCREMENT=N, the values generated are not less than it.
+	Start uint64 `json:"start,omitempty"`
+	// Sequence is the sequence which the values are generated from, such as 'db.seq',
+	// the values are generated from the table's own sequence if it's empty.
+	Sequence string `json:"sequence,omitempty"`
+}
+
+// SequenceConfig tuple.
+type SequenceConfig struct {
+	Name      string `json:"name"`
+	Start     uint64 `json:"start"`
+	Increment uint64 `json:"increment"`
+	Cache     uint64 `json:"cache"`
 }
 
 // TableConfig tuple.
@@ -300,6 +336,15 @@ func ReadTableConfig(data string) (*TableConfig, error) {
 	return conf, nil
 }
 
+// ReadSequenceConfig used to read the sequence config from the data.
+func ReadSequenceConfig(data string) (*SequenceConfig, error) {
+	conf := &SequenceConfig{}
+	if err := json.Unmarshal([]byte(data), conf); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return conf, nil
+}
+
 // ReadBackendsConfig used to read the backend config from the data.
 func ReadBackendsConfig(data string) (*BackendsConfig, error) {
 	conf := &BackendsConfig{}
@@ -62,5 +62,6 @@ func (admin *Admin) NewRouter() (rest.App, error) {
 		rest.Get("/v1/debug/configz", v1.ConfigzHandler(log, proxy)),
 		rest.Get("/v1/debug/backendz", v1.BackendzHandler(log, proxy)),
 		rest.Get("/v1/debug/schemaz", v1.SchemazHandler(log, proxy)),
+		rest.Get("/v1/debug/schemadriftz", v1.SchemaDriftzHandler(log, proxy)),
 	)
 }
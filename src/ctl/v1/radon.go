@@ -21,6 +21,7 @@ type radonParams struct {
 	MaxConnections   *int     `json:"max-connections"`
 	MaxResultSize    *int     `json:"max-result-size"`
 	MaxJoinRows      *int     `json:"max-join-rows"`
+	MaxQueryMemory   *int     `json:"max-query-memory"`
 	DDLTimeout       *int     `json:"ddl-timeout"`
 	QueryTimeout     *int     `json:"query-timeout"`
 	TwoPCEnable      *bool    `json:"twopc-enable"`
@@ -56,6 +57,9 @@ func radonConfigHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter
 	if p.MaxJoinRows != nil {
 		proxy.SetMaxJoinRows(*p.MaxJoinRows)
 	}
+	if p.MaxQueryMemory != nil {
+		proxy.SetMaxQueryMemory(*p.MaxQueryMemory)
+	}
 	if p.DDLTimeout != nil {
 		proxy.SetDDLTimeout(*p.DDLTimeout)
 	}
@@ -26,3 +26,16 @@ func SchemazHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
 func schemazHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
 	w.WriteJson(proxy.Router().Schemas)
 }
+
+// SchemaDriftzHandler impl.
+func SchemaDriftzHandler(log *xlog.Log, proxy *proxy.Proxy) rest.HandlerFunc {
+	f := func(w rest.ResponseWriter, r *rest.Request) {
+		schemaDriftzHandler(log, proxy, w, r)
+	}
+	return f
+}
+
+// schemaDriftzHandler returns the last results of the tables with drifted partitions.
+func schemaDriftzHandler(log *xlog.Log, proxy *proxy.Proxy, w rest.ResponseWriter, r *rest.Request) {
+	w.WriteJson(proxy.Spanner().SchemaChecker().Drifts())
+}
@@ -9,15 +9,16 @@
 package v1
 
 import (
+	"errors"
 	"strings"
 	"testing"
 
+	"forks/go-mysqlstack/driver"
 	"proxy"
 
 	"github.com/ant0ine/go-json-rest/rest"
 	"github.com/ant0ine/go-json-rest/rest/test"
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -68,3 +69,44 @@ func TestCtlV1Schemaz(t *testing.T) {
 		assert.True(t, got)
 	}
 }
+
+func TestCtlV1SchemaDriftz(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := proxy.MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("show create table .*", errors.New("mock.show.create.table.error"))
+	}
+
+	// create test table.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("radon check schema test.t1", -1)
+		assert.Nil(t, err)
+	}
+
+	{
+		api := rest.NewApi()
+		router, _ := rest.MakeRouter(
+			rest.Get("/v1/debug/schemadriftz", SchemaDriftzHandler(log, proxy)),
+		)
+		api.SetApp(router)
+		handler := api.MakeHandler()
+
+		recorded := test.RunRequest(t, handler, test.MakeSimpleRequest("GET", "http://localhost/v1/debug/schemadriftz", nil))
+		recorded.CodeIs(200)
+
+		body := recorded.Recorder.Body.String()
+		assert.True(t, strings.Contains(body, `"table":"t1"`), body)
+		assert.True(t, strings.Contains(body, "mock.show.create.table.error"), body)
+	}
+}
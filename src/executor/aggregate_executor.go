@@ -11,10 +11,12 @@ package executor
 import (
 	"sort"
 
+	"backend"
 	"expression"
 	"planner"
 	"xcontext"
 
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -24,10 +26,14 @@ var (
 )
 
 // AggregateExecutor represents aggregate executor.
-// Including: COUNT/MAX/MIN/SUM/AVG/GROUPBY.
+// Including: COUNT/MAX/MIN/SUM/AVG/GROUP_CONCAT/GROUPBY.
 type AggregateExecutor struct {
 	log  *xlog.Log
 	plan planner.Plan
+	// spill is the memory budget, nil if spilling is disabled.
+	spill *spillConf
+	// groupConcatMaxLen is the max length of the group_concat result.
+	groupConcatMaxLen int
 }
 
 // NewAggregateExecutor creates new AggregateExecutor.
@@ -38,11 +44,20 @@ func NewAggregateExecutor(log *xlog.Log, plan planner.Plan) *AggregateExecutor {
 	}
 }
 
+// newAggregateExecutor creates the AggregateExecutor with the limits of the txn.
+func newAggregateExecutor(log *xlog.Log, plan planner.Plan, txn backend.Transaction) *AggregateExecutor {
+	executor := NewAggregateExecutor(log, plan)
+	executor.spill = newSpillConf(txn)
+	if txn != nil {
+		executor.groupConcatMaxLen = txn.GroupConcatMaxLen()
+	}
+	return executor
+}
+
 // Execute used to execute the executor.
 func (executor *AggregateExecutor) Execute(ctx *xcontext.ResultContext) error {
 	rs := ctx.Results
-	executor.aggregate(rs)
-	return nil
+	return executor.aggregate(rs)
 }
 
 // Aggregate used to do rows-aggregator(COUNT/SUM/MIN/MAX/AVG) and grouped them into group-by fields.
@@ -51,34 +66,52 @@ func (executor *AggregateExecutor) Execute(ctx *xcontext.ResultContext) error {
 // eg: select a,b from tb group by b.        ×
 //     select count(a),b from tb group by b. √
 //     select b from tb group by b.          √
-func (executor *AggregateExecutor) aggregate(result *sqltypes.Result) {
-	var deIdxs []int
+// If the spilling is enabled, the rows are fed to the sorter one by one by the aggregate iterator,
+// the sorted runs are spilled to disk once they exceed the memory budget.
+func (executor *AggregateExecutor) aggregate(result *sqltypes.Result) error {
 	plan := executor.plan.(*planner.AggregatePlan)
 	if plan.Empty() {
-		return
+		return nil
 	}
 
-	aggPlans := plan.NormalAggregators()
-	aggPlansLen := len(aggPlans)
-	groupAggrs := plan.GroupAggregators()
-	if len(groupAggrs) > 0 {
+	if executor.spill != nil {
+		it, err := newAggregateIterator(executor, newRowsIterator(result), executor.spill)
+		if err != nil {
+			return err
+		}
+		res, err := fetchIterator(it, 0)
+		if err != nil {
+			return err
+		}
+		result.Fields = res.Fields
+		result.Rows = res.Rows
+		return nil
+	}
+
+	if groupAggrs := plan.GroupAggregators(); len(groupAggrs) > 0 {
+		less := executor.groupLess(groupAggrs)
 		sort.Slice(result.Rows, func(i, j int) bool {
-			for _, key := range groupAggrs {
-				cmp := sqltypes.NullsafeCompare(result.Rows[i][key.Index], result.Rows[j][key.Index])
-				if cmp == 0 {
-					continue
-				}
-				return cmp < 0
-			}
-			return true
+			// The equal rows are treated as in order.
+			return !less(result.Rows[j], result.Rows[i])
 		})
 	}
+	executor.group(result)
+	return nil
+}
+
+// group used to group the sorted rows and rebuild the results.
+func (executor *AggregateExecutor) group(result *sqltypes.Result) {
+	var deIdxs []int
+	plan := executor.plan.(*planner.AggregatePlan)
+	aggPlans := plan.NormalAggregators()
+	aggPlansLen := len(aggPlans)
+	groupAggrs := plan.GroupAggregators()
 
 	type group struct {
 		row      []sqltypes.Value
 		evalCtxs []*expression.AggEvaluateContext
 	}
-	aggrs := expression.NewAggregations(aggPlans, plan.IsPushDown, result.Fields)
+	aggrs := expression.NewAggregations(aggPlans, plan.IsPushDown, result.Fields, executor.groupConcatMaxLen)
 	var groups []*group
 	for _, row := range result.Rows {
 		length := len(groups)
@@ -118,6 +151,20 @@ func (executor *AggregateExecutor) aggregate(result *sqltypes.Result) {
 	result.RemoveColumns(deIdxs...)
 }
 
+// groupLess returns the less function by the group-by fields.
+func (executor *AggregateExecutor) groupLess(groupAggrs []planner.Aggregator) rowLess {
+	return func(a, b []sqltypes.Value) bool {
+		for _, key := range groupAggrs {
+			cmp := sqltypes.NullsafeCompare(a[key.Index], b[key.Index])
+			if cmp == 0 {
+				continue
+			}
+			return cmp < 0
+		}
+		return false
+	}
+}
+
 func (executor *AggregateExecutor) keysEqual(row1, row2 []sqltypes.Value, groups []planner.Aggregator) bool {
 	for _, v := range groups {
 		cmp := sqltypes.NullsafeCompare(row1[v.Index], row2[v.Index])
@@ -127,3 +174,98 @@ func (executor *AggregateExecutor) keysEqual(row1, row2 []sqltypes.Value, groups
 	}
 	return true
 }
+
+// aggregateIterator aggregates the rows of the src which sorted by the group-by fields,
+// a group is produced once the next group begins.
+type aggregateIterator struct {
+	executor *AggregateExecutor
+	src      rowIterator
+	flds     []*querypb.Field
+	aggrs    []*expression.Aggregation
+	deIdxs   []int
+	row      []sqltypes.Value
+	evalCtxs []*expression.AggEvaluateContext
+	groups   int
+	done     bool
+}
+
+// newAggregateIterator used to aggregate the rows of the src, if there're group-by fields,
+// the rows are sorted first.
+func newAggregateIterator(executor *AggregateExecutor, src rowIterator, spill *spillConf) (rowIterator, error) {
+	plan := executor.plan.(*planner.AggregatePlan)
+	if plan.Empty() {
+		return src, nil
+	}
+
+	if groupAggrs := plan.GroupAggregators(); len(groupAggrs) > 0 {
+		var err error
+		if src, err = newSorterIterator(src, spill, executor.groupLess(groupAggrs)); err != nil {
+			return nil, err
+		}
+	}
+
+	it := &aggregateIterator{
+		executor: executor,
+		src:      src,
+		aggrs:    expression.NewAggregations(plan.NormalAggregators(), plan.IsPushDown, src.fields(), executor.groupConcatMaxLen),
+	}
+	// The avg decompose columns are fixed by the plans, get them by an empty group.
+	evalCtxs := expression.NewAggEvalCtxs(it.aggrs, nil)
+	_, it.deIdxs = expression.GetResults(it.aggrs, evalCtxs, make([]sqltypes.Value, len(src.fields())))
+	rs := &sqltypes.Result{Fields: src.fields()}
+	rs.RemoveColumns(it.deIdxs...)
+	it.flds = rs.Fields
+	return it, nil
+}
+
+func (it *aggregateIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+func (it *aggregateIterator) next() ([]sqltypes.Value, error) {
+	plan := it.executor.plan.(*planner.AggregatePlan)
+	for !it.done {
+		row, err := it.src.next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			it.done = true
+			if it.row != nil {
+				return it.result(it.row, it.evalCtxs), nil
+			}
+			if it.groups == 0 && len(it.aggrs) > 0 {
+				it.groups++
+				evalCtxs := expression.NewAggEvalCtxs(it.aggrs, nil)
+				return it.result(make([]sqltypes.Value, len(it.src.fields())), evalCtxs), nil
+			}
+			return nil, nil
+		}
+
+		if it.row != nil && it.executor.keysEqual(it.row, row, plan.GroupAggregators()) {
+			for i, aggr := range it.aggrs {
+				aggr.Update(row, it.evalCtxs[i])
+			}
+			continue
+		}
+		prow, pctxs := it.row, it.evalCtxs
+		it.row, it.evalCtxs = row, expression.NewAggEvalCtxs(it.aggrs, row)
+		it.groups++
+		if prow != nil {
+			return it.result(prow, pctxs), nil
+		}
+	}
+	return nil, nil
+}
+
+// result used to build the result row of the group.
+func (it *aggregateIterator) result(row []sqltypes.Value, evalCtxs []*expression.AggEvaluateContext) []sqltypes.Value {
+	row, _ = expression.GetResults(it.aggrs, evalCtxs, row)
+	rs := &sqltypes.Result{Rows: [][]sqltypes.Value{row}}
+	rs.RemoveColumns(it.deIdxs...)
+	return rs.Rows[0]
+}
+
+func (it *aggregateIterator) close() {
+	it.src.close()
+}
@@ -100,10 +100,10 @@ func TestAggregateExecutor(t *testing.T) {
 	fakedbs.AddQuery("select id, max(score) as score from sbtest.A8 as A where id > 2", r2)
 
 	// distinct.
-	fakedbs.AddQuery("select id, score as score from sbtest.A0 as A where id > 2", r1)
-	fakedbs.AddQuery("select id, score as score from sbtest.A2 as A where id > 2", r2)
-	fakedbs.AddQuery("select id, score as score from sbtest.A4 as A where id > 2", r1)
-	fakedbs.AddQuery("select id, score as score from sbtest.A8 as A where id > 2", r2)
+	fakedbs.AddQuery("select id, score as score from sbtest.A0 as A where id > 2 group by score", r1)
+	fakedbs.AddQuery("select id, score as score from sbtest.A2 as A where id > 2 group by score", r2)
+	fakedbs.AddQuery("select id, score as score from sbtest.A4 as A where id > 2 group by score", r1)
+	fakedbs.AddQuery("select id, score as score from sbtest.A8 as A where id > 2 group by score", r2)
 
 	querys := []string{
 		"select id, sum(score) as score from A where id>1",
@@ -148,6 +148,13 @@ func TestAggregateExecutor(t *testing.T) {
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
 		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			want := fmt.Sprintf("%v", results[i])
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, want, got)
+		}
 	}
 }
 
@@ -246,6 +253,13 @@ func TestAggregateAvgExecutor(t *testing.T) {
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
 		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			want := fmt.Sprintf("%v", results[i])
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, want, got)
+		}
 	}
 }
 
@@ -357,6 +371,13 @@ func TestAggregateNotPush(t *testing.T) {
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
 		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			want := fmt.Sprintf("%v", wantResults[i])
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, want, got)
+		}
 	}
 }
 
@@ -446,5 +467,100 @@ func TestAggregateGroup(t *testing.T) {
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
 		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			want := fmt.Sprintf("%v", results[i])
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, want, got)
+		}
+	}
+}
+
+func TestAggregateGroupConcat(t *testing.T) {
+	newResult := func(rows ...[]string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "b",
+					Type: querypb.Type_INT32,
+				},
+				{
+					Name: "g",
+					Type: querypb.Type_VARCHAR,
+				},
+				{
+					Name: "c",
+					Type: querypb.Type_VARCHAR,
+				},
+				{
+					Name: "name",
+					Type: querypb.Type_VARCHAR,
+				},
+			},
+		}
+		for _, row := range rows {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(row[0])),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[1])),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[1])),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(row[1])),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// The distinct values are deduplicated in each group on the shards.
+	fakedbs.AddQueryPattern("select b, name as g, name as c, name from sbtest.A0 as A group by b, name .*", newResult([]string{"1", "x"}, []string{"1", "y"}, []string{"2", "x"}))
+	fakedbs.AddQueryPattern("select b, name as g, name as c, name from sbtest.A2 as A group by b, name .*", newResult([]string{"1", "x"}, []string{"2", "z"}))
+	fakedbs.AddQueryPattern("select b, name as g, name as c, name from sbtest.A4 as A group by b, name .*", newResult())
+	fakedbs.AddQueryPattern("select b, name as g, name as c, name from sbtest.A8 as A group by b, name .*", newResult([]string{"2", "x"}))
+
+	query := "select b, group_concat(distinct name order by name desc) as g, count(distinct name) as c from A group by b"
+	maxLens := []int{0, 1}
+	results := []string{
+		"[[1 y,x 2] [2 z,x 2]]",
+		"[[1 y 2] [2 z 2]]",
+	}
+
+	for i, maxLen := range maxLens {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetGroupConcatMaxLen(maxLen)
+		executor := NewSelectExecutor(log, plan, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := executor.Execute(ctx)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got)
+			assert.Equal(t, 3, len(ctx.Results.Fields))
+		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, results[i], got)
+		}
 	}
 }
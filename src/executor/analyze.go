@@ -0,0 +1,336 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"backend"
+	"planner"
+	"xcontext"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	_ planner.Annotator = &Analyzer{}
+)
+
+// QueryStats is the runtime statistics of the query sent to the backend.
+type QueryStats struct {
+	Backend string
+	Range   string
+	Query   string
+	Latency time.Duration
+	Rows    int
+	Loops   int
+}
+
+// PlanStats is the runtime statistics of the plan node or the sub plan.
+type PlanStats struct {
+	Elapsed    time.Duration
+	Rows       int
+	Loops      int
+	PeakMemory int
+	// Querys are the querys sent by the merge node, in the order they first finished.
+	Querys []*QueryStats
+}
+
+// Analyzer used to collect the runtime statistics of the plans for the EXPLAIN ANALYZE,
+// the plans are executed by the txn returned by Txn.
+type Analyzer struct {
+	mu    sync.Mutex
+	stats map[interface{}]*PlanStats
+}
+
+// NewAnalyzer creates the new analyzer.
+func NewAnalyzer() *Analyzer {
+	return &Analyzer{
+		stats: make(map[interface{}]*PlanStats),
+	}
+}
+
+// Txn returns the txn which records the statistics to the analyzer.
+func (a *Analyzer) Txn(txn backend.Transaction) backend.Transaction {
+	return &analyzeTxn{Transaction: txn, analyzer: a}
+}
+
+// Stats returns the statistics of the plan node or the sub plan, nil if it's never executed.
+func (a *Analyzer) Stats(plan interface{}) *PlanStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.stats[plan]
+}
+
+// Annotate impl planner.Annotator.
+func (a *Analyzer) Annotate(plan interface{}) string {
+	stats := a.Stats(plan)
+	if stats == nil {
+		return "(never executed)"
+	}
+	return fmt.Sprintf("(actual time=%.3fms rows=%d loops=%d peak_memory=%d)", milliseconds(stats.Elapsed), stats.Rows, stats.Loops, stats.PeakMemory)
+}
+
+// Querys impl planner.Annotator, the querys are in the order of the node's Querys,
+// the ones not planned are at the end, such as the one sent to a random backend.
+func (a *Analyzer) Querys(node *planner.MergeNode) []string {
+	var lines []string
+	stats := a.Stats(node)
+	if stats == nil || len(stats.Querys) == 0 {
+		for _, tuple := range node.Querys {
+			lines = append(lines, planner.FormatQuery(tuple))
+		}
+		return lines
+	}
+
+	order := make(map[string]int)
+	for i, tuple := range node.Querys {
+		order[queryStatsKey(tuple.Backend, tuple.Range)] = i
+	}
+	querys := make([]*QueryStats, len(stats.Querys))
+	copy(querys, stats.Querys)
+	sort.SliceStable(querys, func(i, j int) bool {
+		oi, ok := order[queryStatsKey(querys[i].Backend, querys[i].Range)]
+		if !ok {
+			oi = len(order)
+		}
+		oj, ok := order[queryStatsKey(querys[j].Backend, querys[j].Range)]
+		if !ok {
+			oj = len(order)
+		}
+		return oi < oj
+	})
+	for _, q := range querys {
+		tuple := xcontext.QueryTuple{Query: q.Query, Backend: q.Backend, Range: q.Range}
+		lines = append(lines, fmt.Sprintf("%s  (actual latency=%.3fms rows=%d loops=%d)", planner.FormatQuery(tuple), milliseconds(q.Latency), q.Rows, q.Loops))
+	}
+	return lines
+}
+
+// planStats returns the statistics of the plan, it's created if not exists.
+func (a *Analyzer) planStats(plan interface{}) *PlanStats {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats, ok := a.stats[plan]
+	if !ok {
+		stats = &PlanStats{}
+		a.stats[plan] = stats
+	}
+	return stats
+}
+
+// record used to record one execution of the plan which started at start.
+func (a *Analyzer) record(stats *PlanStats, start time.Time, rs *sqltypes.Result) {
+	rows, size := 0, 0
+	if rs != nil {
+		rows, size = len(rs.Rows), rowsSize(rs.Rows)
+	}
+	a.recordOutput(stats, time.Since(start), rows, size)
+}
+
+func (a *Analyzer) recordOutput(stats *PlanStats, elapsed time.Duration, rows int, size int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	stats.Elapsed += elapsed
+	stats.Loops++
+	stats.Rows += rows
+	if size > stats.PeakMemory {
+		stats.PeakMemory = size
+	}
+}
+
+// recordQuery used to record the query executed on the backend, the querys on the same
+// backend and range are accumulated, such as the ones of the nested loop join.
+func (a *Analyzer) recordQuery(stats *PlanStats, tuple xcontext.QueryTuple, latency time.Duration, rows int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	key := queryStatsKey(tuple.Backend, tuple.Range)
+	for _, q := range stats.Querys {
+		if queryStatsKey(q.Backend, q.Range) == key {
+			q.Latency += latency
+			q.Rows += rows
+			q.Loops++
+			return
+		}
+	}
+	stats.Querys = append(stats.Querys, &QueryStats{
+		Backend: tuple.Backend,
+		Range:   tuple.Range,
+		Query:   tuple.Query,
+		Latency: latency,
+		Rows:    rows,
+		Loops:   1,
+	})
+}
+
+func queryStatsKey(backend, rng string) string {
+	return backend + rng
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// analyzePlan used to record the execution of the sub plan if the txn is analyzed.
+func analyzePlan(txn backend.Transaction, plan planner.Plan, start time.Time, rs *sqltypes.Result) {
+	if t, ok := txn.(*analyzeTxn); ok {
+		t.analyzer.record(t.analyzer.planStats(plan), start, rs)
+	}
+}
+
+// analyzeNode used to record the output of the node before its sub plans executed,
+// if the txn is analyzed. The size is the memory held by the rows.
+func analyzeNode(txn backend.Transaction, rows int, size int) {
+	if t, ok := txn.(*analyzeTxn); ok && t.stats != nil && t.output == nil {
+		t.output = &nodeOutput{elapsed: time.Since(t.start), rows: rows, size: size}
+	}
+}
+
+// nodeOutput is the output of one execution of the plan node.
+type nodeOutput struct {
+	elapsed time.Duration
+	rows    int
+	size    int
+}
+
+// analyzeTxn is the txn which records the querys of the plan node to the analyzer.
+type analyzeTxn struct {
+	backend.Transaction
+	analyzer *Analyzer
+	stats    *PlanStats
+	// The start time and the output of the node's current execution, the executions
+	// of the same node are in sequence.
+	start  time.Time
+	output *nodeOutput
+}
+
+// forNode returns the txn which records the querys to the stats of the node.
+func (t *analyzeTxn) forNode(node planner.PlanNode) *analyzeTxn {
+	return &analyzeTxn{
+		Transaction: t.Transaction,
+		analyzer:    t.analyzer,
+		stats:       t.analyzer.planStats(node),
+	}
+}
+
+// Execute used to execute the querys and record their latency and rows.
+func (t *analyzeTxn) Execute(req *xcontext.RequestContext) (*sqltypes.Result, error) {
+	if t.stats != nil {
+		req.Trace = func(back string, query string, latency time.Duration, rows int) {
+			tuple := xcontext.QueryTuple{Query: query, Backend: back}
+			for _, qt := range req.Querys {
+				if qt.Backend == back && qt.Query == query {
+					tuple.Range = qt.Range
+					break
+				}
+			}
+			t.analyzer.recordQuery(t.stats, tuple, latency, rows)
+		}
+	}
+	return t.Transaction.Execute(req)
+}
+
+// ExecuteCursors used to open the cursors which record the rows read and the latency.
+func (t *analyzeTxn) ExecuteCursors(req *xcontext.RequestContext) ([]backend.Cursor, error) {
+	start := time.Now()
+	cursors, err := t.Transaction.ExecuteCursors(req)
+	if err != nil || t.stats == nil {
+		return cursors, err
+	}
+	for i, cursor := range cursors {
+		cursors[i] = &analyzeCursor{
+			Cursor: cursor,
+			start:  start,
+			done: func(tuple xcontext.QueryTuple) func(time.Duration, int) {
+				return func(latency time.Duration, rows int) {
+					t.analyzer.recordQuery(t.stats, tuple, latency, rows)
+				}
+			}(req.Querys[i]),
+		}
+	}
+	return cursors, nil
+}
+
+// analyzeCursor is the cursor which counts the rows read, the query is recorded once
+// the cursor is exhausted or closed.
+type analyzeCursor struct {
+	backend.Cursor
+	start    time.Time
+	rows     int
+	finished bool
+	done     func(latency time.Duration, rows int)
+}
+
+// Next impl.
+func (c *analyzeCursor) Next() ([]sqltypes.Value, error) {
+	row, err := c.Cursor.Next()
+	if row != nil {
+		c.rows++
+	} else {
+		c.finish()
+	}
+	return row, err
+}
+
+// Close impl.
+func (c *analyzeCursor) Close() error {
+	c.finish()
+	return c.Cursor.Close()
+}
+
+func (c *analyzeCursor) finish() {
+	if !c.finished {
+		c.finished = true
+		c.done(time.Since(c.start), c.rows)
+	}
+}
+
+// analyzeEngine is the engine which records its executions to the analyzer.
+type analyzeEngine struct {
+	PlanEngine
+	txn *analyzeTxn
+}
+
+// execute used to execute the engine and record the elapsed time and the rows.
+func (e *analyzeEngine) execute(ctx *xcontext.ResultContext) error {
+	e.begin()
+	err := e.PlanEngine.execute(ctx)
+	e.end(ctx.Results)
+	return err
+}
+
+// execBindVars used to execute the engine with the bindvars and record the elapsed time and the rows.
+func (e *analyzeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error {
+	e.begin()
+	err := e.PlanEngine.execBindVars(ctx, bindVars, wantfields)
+	e.end(ctx.Results)
+	return err
+}
+
+func (e *analyzeEngine) begin() {
+	e.txn.start = time.Now()
+	e.txn.output = nil
+}
+
+// end used to record the execution, the results are the output of the node if it has
+// no sub plans executed.
+func (e *analyzeEngine) end(rs *sqltypes.Result) {
+	t := e.txn
+	if t.output == nil {
+		analyzeNode(t, 0, 0)
+		if rs != nil {
+			t.output.rows, t.output.size = len(rs.Rows), rowsSize(rs.Rows)
+		}
+	}
+	t.analyzer.recordOutput(t.stats, t.output.elapsed, t.output.rows, t.output.size)
+}
@@ -0,0 +1,119 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"backend"
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestAnalyzer(t *testing.T) {
+	newResult := func(ids ...string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "id", Type: querypb.Type_INT32},
+				{Name: "name", Type: querypb.Type_VARCHAR},
+			},
+		}
+		for _, id := range ids {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name"+id)),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select id, name from sbtest.A0 as A .*", newResult("9", "7", "3"))
+	fakedbs.AddQueryPattern("select id, name from sbtest.A2 as A .*", newResult("8"))
+	fakedbs.AddQueryPattern("select id, name from sbtest.A4 as A .*", newResult())
+	fakedbs.AddQueryPattern("select id, name from sbtest.A8 as A .*", newResult("6", "5"))
+
+	querys := []string{
+		"select id, name from A where id>1 order by id desc",
+		"select id, name from A where id>1 order by id desc limit 2",
+	}
+	results := []string{
+		"[[9 name9] [8 name8] [7 name7] [6 name6] [5 name5] [3 name3]]",
+		"[[9 name9] [8 name8]]",
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+
+		analyzer := NewAnalyzer()
+		executor := NewSelectExecutor(log, plan, analyzer.Txn(txn))
+		ctx := xcontext.NewResultContext()
+		err = executor.Execute(ctx)
+		assert.Nil(t, err)
+		assert.Equal(t, results[i], fmt.Sprintf("%v", ctx.Results.Rows))
+
+		// The stats of the sub plans.
+		for _, sub := range plan.Root.Children().Plans() {
+			stats := analyzer.Stats(sub)
+			assert.NotNil(t, stats)
+			assert.Equal(t, 1, stats.Loops)
+			assert.Equal(t, len(ctx.Results.Rows), stats.Rows)
+		}
+
+		// The stats of the merge node and its querys.
+		stats := analyzer.Stats(plan.Root)
+		assert.Equal(t, 1, stats.Loops)
+		assert.Equal(t, 4, len(stats.Querys))
+		rows := 0
+		for _, q := range stats.Querys {
+			assert.Equal(t, 1, q.Loops)
+			assert.NotEqual(t, "", q.Range)
+			rows += q.Rows
+		}
+		lines := analyzer.Querys(plan.Root.(*planner.MergeNode))
+		assert.Equal(t, 4, len(lines))
+		assert.Contains(t, planner.FormatTree(plan, analyzer), "actual time=")
+		if i == 0 {
+			assert.Equal(t, 6, rows)
+			assert.Equal(t, 6, stats.Rows)
+		} else {
+			// The merge stops reading once the limit rows are merged.
+			assert.True(t, rows < 6)
+		}
+	}
+
+	// Never executed.
+	analyzer := NewAnalyzer()
+	assert.Equal(t, "(never executed)", analyzer.Annotate(&planner.MergeNode{}))
+}
@@ -0,0 +1,118 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"expression"
+	"planner"
+	"xcontext"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Executor = &FilterExecutor{}
+)
+
+// FilterExecutor represents filter executor, the merged rows are filtered by the conditions.
+type FilterExecutor struct {
+	log  *xlog.Log
+	plan planner.Plan
+}
+
+// NewFilterExecutor creates the new filter executor.
+func NewFilterExecutor(log *xlog.Log, plan planner.Plan) *FilterExecutor {
+	return &FilterExecutor{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the executor.
+func (executor *FilterExecutor) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	evals, err := executor.compile(rs.Fields)
+	if err != nil {
+		return err
+	}
+
+	rows := rs.Rows[:0]
+	for _, row := range rs.Rows {
+		ok, err := match(evals, row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+	rs.Rows = rows
+	return nil
+}
+
+// compile used to compile the filters on the fields.
+func (executor *FilterExecutor) compile(fields []*querypb.Field) ([]expression.Evaluator, error) {
+	plan := executor.plan.(*planner.FilterPlan)
+	evals := make([]expression.Evaluator, len(plan.Filters))
+	for i, filter := range plan.Filters {
+		eval, err := expression.NewEvaluator(filter, fields)
+		if err != nil {
+			return nil, err
+		}
+		evals[i] = eval
+	}
+	return evals, nil
+}
+
+// match used to check whether the row satisfies all the conditions.
+func match(evals []expression.Evaluator, row []sqltypes.Value) (bool, error) {
+	for _, eval := range evals {
+		ok, err := eval.EvalBool(row)
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// filterIterator skips the rows of the src which don't satisfy the conditions.
+type filterIterator struct {
+	rowIterator
+	evals []expression.Evaluator
+}
+
+func newFilterIterator(executor *FilterExecutor, src rowIterator) (*filterIterator, error) {
+	evals, err := executor.compile(src.fields())
+	if err != nil {
+		src.close()
+		return nil, err
+	}
+	return &filterIterator{
+		rowIterator: src,
+		evals:       evals,
+	}, nil
+}
+
+func (it *filterIterator) next() ([]sqltypes.Value, error) {
+	for {
+		row, err := it.rowIterator.next()
+		if err != nil || row == nil {
+			return nil, err
+		}
+		ok, err := match(it.evals, row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return row, nil
+		}
+	}
+}
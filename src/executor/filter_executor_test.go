@@ -0,0 +1,121 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"backend"
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestFilterExecutor(t *testing.T) {
+	fields := []*querypb.Field{
+		{
+			Name: "a",
+			Type: querypb.Type_INT32,
+		},
+		{
+			Name: "count(*)",
+			Type: querypb.Type_INT64,
+		},
+		{
+			Name: "max(d)",
+			Type: querypb.Type_INT32,
+		},
+	}
+	r1 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.NULL,
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("2")),
+				sqltypes.NULL,
+			},
+		},
+	}
+	r2 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("7")),
+			},
+		},
+	}
+	r3 := &sqltypes.Result{Fields: fields}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// Add querys.
+	fakedbs.AddQuery("select a, count(*), max(d) as `max(d)` from sbtest.A0 as A where id > 8 group by a order by a asc", r1)
+	fakedbs.AddQuery("select a, count(*), max(d) as `max(d)` from sbtest.A2 as A where id > 8 group by a order by a asc", r2)
+	fakedbs.AddQuery("select a, count(*), max(d) as `max(d)` from sbtest.A4 as A where id > 8 group by a order by a asc", r3)
+	fakedbs.AddQuery("select a, count(*), max(d) as `max(d)` from sbtest.A8 as A where id > 8 group by a order by a asc", r3)
+
+	query := "select a, count(*) from A where id>8 group by a having count(*) > 1 and max(d) is not null"
+	want := "[[1 2] [2 3]]"
+
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	log.Debug("plan:%+v", plan.JSON())
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+	{
+		ctx := xcontext.NewResultContext()
+		err := executor.Execute(ctx)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", ctx.Results.Rows)
+		assert.Equal(t, want, got)
+	}
+	// Stream fetch.
+	{
+		rs := streamFetchRows(t, executor)
+		got := fmt.Sprintf("%v", rs.Rows)
+		assert.Equal(t, want, got)
+	}
+}
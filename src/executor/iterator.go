@@ -0,0 +1,248 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+
+	"backend"
+	"planner"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+// rowIterator is the pull-based row source of the plan engines, the rows are produced
+// one by one, so the results can be streamed to the client with bounded memory.
+type rowIterator interface {
+	// fields returns the fields of the rows.
+	fields() []*querypb.Field
+	// next returns the next row, nil if there's no more rows.
+	next() ([]sqltypes.Value, error)
+	// close used to release the resources, such as the cursors and the spilled files.
+	close()
+}
+
+// iterateSubPlan used to wrap the src by the children plans in order.
+func iterateSubPlan(log *xlog.Log, node planner.PlanNode, src rowIterator, txn backend.Transaction) (rowIterator, error) {
+	var err error
+	spill := newSpillConf(txn)
+	it := src
+	subPlanTree := node.Children()
+	if subPlanTree == nil {
+		return it, nil
+	}
+	for _, subPlan := range subPlanTree.Plans() {
+		switch subPlan.Type() {
+		case planner.PlanTypeAggregate:
+			it, err = newAggregateIterator(newAggregateExecutor(log, subPlan, txn), it, spill)
+		case planner.PlanTypeOrderby:
+			it, err = newOrderByIterator(NewOrderByExecutor(log, subPlan), it, spill)
+		case planner.PlanTypeProject:
+			it, err = newProjectIterator(NewProjectExecutor(log, subPlan), it)
+		case planner.PlanTypeFilter:
+			it, err = newFilterIterator(NewFilterExecutor(log, subPlan), it)
+		case planner.PlanTypeWindow:
+			it, err = newWindowIterator(NewWindowExecutor(log, subPlan), it, spill)
+		case planner.PlanTypeLimit:
+			it = newLimitIterator(subPlan.(*planner.LimitPlan), it)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// fetchIterator used to fetch all the rows of the iterator to the result, the iterator is closed.
+// If maxResult is positive, the fetching is interrupted once the rows exceed maxResult bytes.
+func fetchIterator(it rowIterator, maxResult int) (*sqltypes.Result, error) {
+	defer it.close()
+	size := 0
+	rs := &sqltypes.Result{Fields: it.fields()}
+	for {
+		row, err := it.next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			return rs, nil
+		}
+		if maxResult > 0 {
+			if size += sqltypes.Values(row).Len(); size > maxResult {
+				return nil, fmt.Errorf("Query execution was interrupted, max memory usage[%d bytes] exceeded", maxResult)
+			}
+		}
+		rs.Rows = append(rs.Rows, row)
+		rs.RowsAffected++
+	}
+}
+
+// rowsIterator iterates the in-memory rows.
+type rowsIterator struct {
+	flds []*querypb.Field
+	rows [][]sqltypes.Value
+	idx  int
+}
+
+func newRowsIterator(rs *sqltypes.Result) *rowsIterator {
+	return &rowsIterator{
+		flds: rs.Fields,
+		rows: rs.Rows,
+	}
+}
+
+func (it *rowsIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+// next returns the next row, the returned row is released from the rows.
+func (it *rowsIterator) next() ([]sqltypes.Value, error) {
+	if it.idx >= len(it.rows) {
+		return nil, nil
+	}
+	row := it.rows[it.idx]
+	it.rows[it.idx] = nil
+	it.idx++
+	return row, nil
+}
+
+func (it *rowsIterator) close() {
+	it.rows = nil
+}
+
+// cursorsIterator concatenates the rows of the cursors.
+type cursorsIterator struct {
+	cursors []backend.Cursor
+	idx     int
+}
+
+func newCursorsIterator(cursors []backend.Cursor) *cursorsIterator {
+	return &cursorsIterator{cursors: cursors}
+}
+
+func (it *cursorsIterator) fields() []*querypb.Field {
+	return it.cursors[0].Fields()
+}
+
+func (it *cursorsIterator) next() ([]sqltypes.Value, error) {
+	for it.idx < len(it.cursors) {
+		row, err := it.cursors[it.idx].Next()
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			return row, nil
+		}
+		it.idx++
+	}
+	return nil, nil
+}
+
+func (it *cursorsIterator) close() {
+	for _, cursor := range it.cursors {
+		cursor.Close()
+	}
+}
+
+// mergeIterator merges the sorted rows of the cursors by the k-way merge.
+type mergeIterator struct {
+	*cursorsIterator
+	heap *mergeHeap
+}
+
+func newMergeIterator(cursors []backend.Cursor, less rowLess) (*mergeIterator, error) {
+	var items []*mergeItem
+	for i, cursor := range cursors {
+		items = append(items, &mergeItem{next: cursor.Next, order: i})
+	}
+	h, err := newMergeHeap(less, items)
+	if err != nil {
+		return nil, err
+	}
+	return &mergeIterator{
+		cursorsIterator: newCursorsIterator(cursors),
+		heap:            h,
+	}, nil
+}
+
+func (it *mergeIterator) next() ([]sqltypes.Value, error) {
+	return it.heap.next()
+}
+
+// sorterIterator iterates the rows of the spillSorter, the spilled files are removed on close.
+type sorterIterator struct {
+	flds   []*querypb.Field
+	sorter *spillSorter
+	read   func() ([]sqltypes.Value, error)
+}
+
+// newSorterIterator used to drain the src to a new spillSorter and iterate the sorted rows,
+// the src is closed.
+func newSorterIterator(src rowIterator, spill *spillConf, less rowLess) (*sorterIterator, error) {
+	defer src.close()
+	sorter := newSpillSorter(spill, less)
+	for {
+		row, err := src.next()
+		if err != nil {
+			sorter.close()
+			return nil, err
+		}
+		if row == nil {
+			break
+		}
+		if err := sorter.add(row); err != nil {
+			sorter.close()
+			return nil, err
+		}
+	}
+	read, err := sorter.reader()
+	if err != nil {
+		sorter.close()
+		return nil, err
+	}
+	return &sorterIterator{
+		flds:   src.fields(),
+		sorter: sorter,
+		read:   read,
+	}, nil
+}
+
+func (it *sorterIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+func (it *sorterIterator) next() ([]sqltypes.Value, error) {
+	return it.read()
+}
+
+func (it *sorterIterator) close() {
+	it.sorter.close()
+}
+
+// hiddenColsIterator removes the hidden columns at the end of the rows.
+type hiddenColsIterator struct {
+	rowIterator
+	hiddenCols int
+}
+
+func (it *hiddenColsIterator) fields() []*querypb.Field {
+	fields := it.rowIterator.fields()
+	return fields[:len(fields)-it.hiddenCols]
+}
+
+func (it *hiddenColsIterator) next() ([]sqltypes.Value, error) {
+	row, err := it.rowIterator.next()
+	if err != nil || row == nil {
+		return nil, err
+	}
+	return row[:len(row)-it.hiddenCols], nil
+}
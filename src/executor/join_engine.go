@@ -9,6 +9,7 @@
 package executor
 
 import (
+	"math"
 	"sync"
 
 	"backend"
@@ -56,7 +57,12 @@ func (j *JoinEngine) execute(ctx *xcontext.ResultContext) error {
 		}
 	}
 
-	maxrow := j.txn.MaxJoinRows()
+	// If the spilling is enabled, the rows of both sides are read by the cursors and the
+	// sorts spill the sorted runs while the rows arrive.
+	if spillable(j.node, j.txn) {
+		return executeIterate(j.iterate, j.node, ctx, j.txn)
+	}
+
 	if j.node.Strategy == planner.NestedLoop {
 		joinVars := make(map[string]*querypb.BindVariable)
 		if err := j.execBindVars(ctx, joinVars, true); err != nil {
@@ -76,28 +82,37 @@ func (j *JoinEngine) execute(ctx *xcontext.ResultContext) error {
 
 		ctx.Results = &sqltypes.Result{}
 		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
-		if len(lctx.Results.Rows) == 0 {
+		if len(lctx.Results.Rows) == 0 && !j.node.IsFullJoin {
 			return nil
 		}
 
 		var err error
-		if len(rctx.Results.Rows) == 0 {
-			err = concatLeftAndNil(lctx.Results.Rows, j.node, ctx.Results, maxrow)
+		res := j.newJoinResult(ctx.Results)
+		defer res.close()
+		if len(rctx.Results.Rows) == 0 || len(lctx.Results.Rows) == 0 {
+			if err = concatLeftAndNil(lctx.Results.Rows, j.node, res); err == nil {
+				err = concatNilAndRight(rctx.Results.Rows, j.node, res)
+			}
 		} else {
 			switch j.node.Strategy {
 			case planner.SortMerge:
-				err = sortMergeJoin(lctx.Results, rctx.Results, ctx.Results, j.node, maxrow)
+				err = sortMergeJoin(lctx.Results, rctx.Results, res, j.node)
 			case planner.Cartesian:
-				err = cartesianProduct(lctx.Results, rctx.Results, ctx.Results, j.node, maxrow)
+				err = cartesianProduct(lctx.Results, rctx.Results, res, j.node)
+			case planner.Hash:
+				err = semiHashJoin(lctx.Results, rctx.Results, res, j.node)
 			}
 		}
 
 		if err != nil {
 			return err
 		}
+		if res.spilled() {
+			return execSpilledSubPlan(j.log, j.node, ctx, j.txn, res.finish())
+		}
 	}
 
-	return execSubPlan(j.log, j.node, ctx)
+	return execSubPlan(j.log, j.node, ctx, j.txn)
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -105,8 +120,9 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 	var err error
 	lctx := xcontext.NewResultContext()
 	rctx := xcontext.NewResultContext()
-	maxrow := j.txn.MaxJoinRows()
 	ctx.Results = &sqltypes.Result{}
+	res := j.newJoinResult(ctx.Results)
+	defer res.close()
 
 	joinVars := make(map[string]*querypb.BindVariable)
 	if err = j.left.execBindVars(lctx, bindVars, wantfields); err != nil {
@@ -134,26 +150,35 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 				wantfields = false
 				ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
 			}
-			for _, rrow := range rctx.Results.Rows {
-				matchCnt++
-				ok := true
-				for _, idx := range j.node.RightTmpCols {
-					if !rrow[idx].IsNull() {
-						ok = false
-						break
+			if j.node.IsSemiJoin || j.node.IsAntiJoin {
+				if semiMatch(lrow, rctx.Results.Rows, j.node) {
+					matchCnt++
+					if j.node.IsSemiJoin {
+						if err = res.append(joinRows(lrow, nil, j.node.Cols)); err != nil {
+							return err
+						}
 					}
 				}
-				if ok {
-					ctx.Results.Rows = append(ctx.Results.Rows, joinRows(lrow, rrow, j.node.Cols))
-					ctx.Results.RowsAffected++
-					if len(ctx.Results.Rows) > maxrow {
-						return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			} else {
+				for _, rrow := range rctx.Results.Rows {
+					matchCnt++
+					ok := true
+					for _, idx := range j.node.RightTmpCols {
+						if !rrow[idx].IsNull() {
+							ok = false
+							break
+						}
+					}
+					if ok {
+						if err = res.append(joinRows(lrow, rrow, j.node.Cols)); err != nil {
+							return err
+						}
 					}
 				}
 			}
 		}
 		if matchCnt == 0 {
-			if err = concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, ctx.Results, maxrow); err != nil {
+			if err = concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, res); err != nil {
 				return err
 			}
 		}
@@ -169,6 +194,11 @@ func (j *JoinEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[stri
 		}
 		ctx.Results.Fields = joinFields(lctx.Results.Fields, rctx.Results.Fields, j.node.Cols)
 	}
+	if res.spilled() {
+		// The nested loop join's results are consumed by the parent join as the left side,
+		// so the spilled rows must be fetched back to memory.
+		return fetchRows(ctx.Results, res.finish(), -1)
+	}
 	return nil
 }
 
@@ -212,11 +242,13 @@ func joinFields(lfields, rfields []*querypb.Field, cols []int) []*querypb.Field
 func joinRows(lrow, rrow []sqltypes.Value, cols []int) []sqltypes.Value {
 	row := make([]sqltypes.Value, len(cols))
 	for i, index := range cols {
+		// lrow can be nil on full joins, rrow can be nil on left and full joins.
 		if index < 0 {
-			row[i] = lrow[-index-1]
+			if lrow != nil {
+				row[i] = lrow[-index-1]
+			}
 			continue
 		}
-		// rrow can be nil on left joins
 		if rrow != nil {
 			row[i] = rrow[index-1]
 		}
@@ -236,16 +268,491 @@ func combineVars(bv1, bv2 map[string]*querypb.BindVariable) map[string]*querypb.
 }
 
 // cartesianProduct used to produce cartesian product.
-func cartesianProduct(lres, rres, res *sqltypes.Result, node *planner.JoinNode, maxrow int) error {
-	res.Rows = make([][]sqltypes.Value, 0, len(lres.Rows)*len(rres.Rows))
+func cartesianProduct(lres, rres *sqltypes.Result, res *joinResult, node *planner.JoinNode) error {
+	if res.spill == nil {
+		res.Rows = make([][]sqltypes.Value, 0, len(lres.Rows)*len(rres.Rows))
+	}
 	for _, lrow := range lres.Rows {
 		for _, rrow := range rres.Rows {
-			res.Rows = append(res.Rows, joinRows(lrow, rrow, node.Cols))
-			res.RowsAffected++
-			if len(res.Rows) > maxrow {
-				return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			if err := res.append(joinRows(lrow, rrow, node.Cols)); err != nil {
+				return err
 			}
 		}
 	}
 	return nil
 }
+
+// joinResult collects the joined rows. If spilling is enabled, the rows beyond the
+// memory budget will be spilled to disk, otherwise the row count is limited by the maxrow.
+type joinResult struct {
+	*sqltypes.Result
+	maxrow int
+	spill  *spillConf
+	store  *spillSorter
+	size   int
+}
+
+func (j *JoinEngine) newJoinResult(res *sqltypes.Result) *joinResult {
+	return &joinResult{
+		Result: res,
+		maxrow: j.txn.MaxJoinRows(),
+		spill:  newSpillConf(j.txn),
+	}
+}
+
+// append used to append a joined row.
+func (r *joinResult) append(row []sqltypes.Value) error {
+	r.Rows = append(r.Rows, row)
+	r.RowsAffected++
+	if r.spill == nil {
+		if len(r.Rows) > r.maxrow {
+			return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", r.maxrow)
+		}
+		return nil
+	}
+
+	r.size += rowSize(row)
+	if r.size > r.spill.limit {
+		if r.store == nil {
+			r.store = newSpillSorter(r.spill, nil)
+		}
+		for i, row := range r.Rows {
+			if err := r.store.add(row); err != nil {
+				return err
+			}
+			r.Rows[i] = nil
+		}
+		if err := r.store.spill(); err != nil {
+			return err
+		}
+		r.Rows = r.Rows[:0]
+		r.size = 0
+	}
+	return nil
+}
+
+// spilled returns true if some rows have been spilled to disk.
+func (r *joinResult) spilled() bool {
+	return r.store != nil
+}
+
+// finish moves the in-memory rows to the store and returns it, the caller owns the store.
+func (r *joinResult) finish() *spillSorter {
+	store := r.store
+	store.rows = append(store.rows, r.Rows...)
+	r.Rows = nil
+	r.store = nil
+	return store
+}
+
+// close used to remove the spilled rows if the store isn't taken by the caller.
+func (r *joinResult) close() {
+	if r.store != nil {
+		r.store.close()
+		r.store = nil
+	}
+}
+
+// iterate used to join the rows of the left and right incrementally.
+func (j *JoinEngine) iterate() (rowIterator, error) {
+	var it rowIterator
+	var err error
+	switch j.node.Strategy {
+	case planner.NestedLoop:
+		it, err = j.iterateNestedLoop()
+	case planner.Hash:
+		it, err = j.iterateHash()
+	default:
+		it, err = j.iterateSorted()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return iterateSubPlan(j.log, j.node, it, j.txn)
+}
+
+// iterateNestedLoop used to fetch the left rows one by one, the right side is executed
+// with the bindvars of each left row.
+func (j *JoinEngine) iterateNestedLoop() (rowIterator, error) {
+	var left rowIterator
+	if m, ok := j.left.(*MergeEngine); ok {
+		var err error
+		if left, err = m.iterBindVars(nil); err != nil {
+			return nil, err
+		}
+	} else {
+		lctx := xcontext.NewResultContext()
+		if err := j.left.execBindVars(lctx, nil, true); err != nil {
+			return nil, err
+		}
+		left = newRowsIterator(lctx.Results)
+	}
+
+	var lfields, rfields []*querypb.Field
+	wantfields := true
+	joinVars := make(map[string]*querypb.BindVariable)
+	fill := func(res *joinResult) (bool, error) {
+		lrow, err := left.next()
+		if err != nil || lrow == nil {
+			return false, err
+		}
+
+		blend := true
+		matchCnt := 0
+		for _, idx := range j.node.LeftTmpCols {
+			vn := lrow[idx].ToNative()
+			if vn.(int64) == 0 {
+				blend = false
+				break
+			}
+		}
+		if blend {
+			rctx := xcontext.NewResultContext()
+			for k, col := range j.node.Vars {
+				joinVars[k] = sqltypes.ValueBindVariable(lrow[col])
+			}
+			if err = j.right.execBindVars(rctx, joinVars, wantfields); err != nil {
+				return false, err
+			}
+			if wantfields {
+				wantfields = false
+				rfields = rctx.Results.Fields
+			}
+			if j.node.IsSemiJoin || j.node.IsAntiJoin {
+				if semiMatch(lrow, rctx.Results.Rows, j.node) {
+					matchCnt++
+					if j.node.IsSemiJoin {
+						if err = res.append(joinRows(lrow, nil, j.node.Cols)); err != nil {
+							return false, err
+						}
+					}
+				}
+			} else {
+				for _, rrow := range rctx.Results.Rows {
+					matchCnt++
+					ok := true
+					for _, idx := range j.node.RightTmpCols {
+						if !rrow[idx].IsNull() {
+							ok = false
+							break
+						}
+					}
+					if ok {
+						if err = res.append(joinRows(lrow, rrow, j.node.Cols)); err != nil {
+							return false, err
+						}
+					}
+				}
+			}
+		}
+		if matchCnt == 0 {
+			if err = concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, res); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	it := newJoinIterator(fill, left)
+	// The right fields come with the first right results, fill until they're known.
+	lfields = left.fields()
+	for wantfields && !it.done {
+		if err := it.refill(); err != nil {
+			it.close()
+			return nil, err
+		}
+	}
+	if wantfields {
+		rctx := xcontext.NewResultContext()
+		for k := range j.node.Vars {
+			joinVars[k] = sqltypes.NullBindVariable
+		}
+		if err := j.right.getFields(rctx, joinVars); err != nil {
+			it.close()
+			return nil, err
+		}
+		rfields = rctx.Results.Fields
+	}
+	it.flds = joinFields(lfields, rfields, j.node.Cols)
+	return it, nil
+}
+
+// iterateSorted used to join the left and right by the sort merge join or the cartesian
+// product, the rows of both sides are sorted first and spilled to disk over the memory budget.
+func (j *JoinEngine) iterateSorted() (rowIterator, error) {
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var left, right rowIterator
+	allErrors := make([]error, 0, 2)
+	spill := newSpillConf(j.txn)
+	oneSide := func(exec PlanEngine, keys []planner.JoinKey, out *rowIterator) {
+		defer wg.Done()
+		it, err := exec.iterate()
+		if err == nil {
+			var less rowLess
+			if len(keys) > 0 {
+				less = joinKeysLess(keys)
+			}
+			*out, err = newSorterIterator(it, spill, less)
+		}
+		if err != nil {
+			mu.Lock()
+			allErrors = append(allErrors, err)
+			mu.Unlock()
+		}
+	}
+	wg.Add(2)
+	go oneSide(j.left, j.node.LeftKeys, &left)
+	go oneSide(j.right, j.node.RightKeys, &right)
+	wg.Wait()
+	if len(allErrors) > 0 {
+		for _, it := range []rowIterator{left, right} {
+			if it != nil {
+				it.close()
+			}
+		}
+		return nil, allErrors[0]
+	}
+
+	var fill func(res *joinResult) (bool, error)
+	if j.node.Strategy == planner.Cartesian {
+		fill = j.cartesianFill(left, right.(*sorterIterator))
+	} else {
+		fill = j.mergeJoinFill(left, right)
+	}
+	it := newJoinIterator(fill, left, right)
+	it.flds = joinFields(left.fields(), right.fields(), j.node.Cols)
+	return it, nil
+}
+
+// cartesianFill returns the fill which joins one left row with all the right rows.
+func (j *JoinEngine) cartesianFill(left rowIterator, right *sorterIterator) func(res *joinResult) (bool, error) {
+	return func(res *joinResult) (bool, error) {
+		lrow, err := left.next()
+		if err != nil || lrow == nil {
+			return false, err
+		}
+		read, err := right.sorter.reader()
+		if err != nil {
+			return false, err
+		}
+		matchCnt := 0
+		for {
+			rrow, err := read()
+			if err != nil {
+				return false, err
+			}
+			if rrow == nil {
+				break
+			}
+			matchCnt++
+			if err := res.append(joinRows(lrow, rrow, j.node.Cols)); err != nil {
+				return false, err
+			}
+		}
+		if matchCnt == 0 {
+			if err := concatLeftAndNil([][]sqltypes.Value{lrow}, j.node, res); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+}
+
+// mergeJoinFill returns the fill which merges the sorted left and right by the same join key chunks.
+func (j *JoinEngine) mergeJoinFill(left, right rowIterator) func(res *joinResult) (bool, error) {
+	node := j.node
+	lchunks := &chunkReader{it: left, keys: node.LeftKeys}
+	rchunks := &chunkReader{it: right, keys: node.RightKeys}
+	var lrows, rrows [][]sqltypes.Value
+	started := false
+	return func(res *joinResult) (bool, error) {
+		var err error
+		if !started {
+			started = true
+			if lrows, err = lchunks.next(); err != nil {
+				return false, err
+			}
+			if rrows, err = rchunks.next(); err != nil {
+				return false, err
+			}
+		}
+		// The unmatched right rows are returned on full joins.
+		more := func() bool {
+			return lrows != nil || (node.IsFullJoin && rrows != nil)
+		}
+		if lrows == nil {
+			if !more() {
+				return false, nil
+			}
+			if err = concatNilAndRight(rrows, node, res); err != nil {
+				return false, err
+			}
+			rrows, err = rchunks.next()
+			return more(), err
+		}
+		if rrows == nil {
+			if err = concatLeftAndNil(lrows, node, res); err != nil {
+				return false, err
+			}
+			lrows, err = lchunks.next()
+			return more(), err
+		}
+
+		cmp := 0
+		isNull := false
+		for k, key := range node.LeftKeys {
+			cmp = sqltypes.NullsafeCompare(lrows[0][key.Index], rrows[0][node.RightKeys[k].Index])
+			if cmp != 0 {
+				break
+			}
+			if lrows[0][key.Index].IsNull() {
+				isNull = true
+				break
+			}
+		}
+
+		if cmp == 0 {
+			if isNull {
+				if err = concatLeftAndNil(lrows, node, res); err == nil {
+					err = concatNilAndRight(rrows, node, res)
+				}
+			} else {
+				err = concatLeftAndRight(lrows, rrows, node, res)
+			}
+			if err != nil {
+				return false, err
+			}
+			if lrows, err = lchunks.next(); err != nil {
+				return false, err
+			}
+			rrows, err = rchunks.next()
+		} else if cmp > 0 {
+			if err = concatNilAndRight(rrows, node, res); err != nil {
+				return false, err
+			}
+			rrows, err = rchunks.next()
+		} else {
+			if err = concatLeftAndNil(lrows, node, res); err != nil {
+				return false, err
+			}
+			lrows, err = lchunks.next()
+		}
+		return more(), err
+	}
+}
+
+// joinKeysLess returns the less function by the join keys.
+func joinKeysLess(keys []planner.JoinKey) rowLess {
+	return func(a, b []sqltypes.Value) bool {
+		for _, key := range keys {
+			cmp := sqltypes.NullsafeCompare(a[key.Index], b[key.Index])
+			if cmp == 0 {
+				continue
+			}
+			return cmp < 0
+		}
+		return false
+	}
+}
+
+// chunkReader reads the rows with the same join keys from the sorted iterator.
+type chunkReader struct {
+	it   rowIterator
+	keys []planner.JoinKey
+	peek []sqltypes.Value
+	eof  bool
+}
+
+// next returns the next chunk, nil if there's no more rows.
+func (c *chunkReader) next() ([][]sqltypes.Value, error) {
+	if c.peek == nil {
+		if c.eof {
+			return nil, nil
+		}
+		row, err := c.it.next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			c.eof = true
+			return nil, nil
+		}
+		c.peek = row
+	}
+
+	chunk := [][]sqltypes.Value{c.peek}
+	c.peek = nil
+	for {
+		row, err := c.it.next()
+		if err != nil {
+			return nil, err
+		}
+		if row == nil {
+			c.eof = true
+			return chunk, nil
+		}
+		if !keysEqual(chunk[0], row, c.keys) {
+			c.peek = row
+			return chunk, nil
+		}
+		chunk = append(chunk, row)
+	}
+}
+
+// joinIterator returns the joined rows in batches, the fill appends the next batch to the
+// res and returns false if there's no more rows.
+type joinIterator struct {
+	flds    []*querypb.Field
+	res     *joinResult
+	idx     int
+	fill    func(res *joinResult) (bool, error)
+	sources []rowIterator
+	done    bool
+}
+
+func newJoinIterator(fill func(res *joinResult) (bool, error), sources ...rowIterator) *joinIterator {
+	return &joinIterator{
+		// The rows are sent out batch by batch, so the batch needn't be limited.
+		res:     &joinResult{Result: &sqltypes.Result{}, maxrow: math.MaxInt32},
+		fill:    fill,
+		sources: sources,
+	}
+}
+
+// refill used to fill the next batch, the unread rows are kept.
+func (it *joinIterator) refill() error {
+	if it.idx >= len(it.res.Rows) {
+		it.res.Rows = it.res.Rows[:0]
+		it.idx = 0
+	}
+	more, err := it.fill(it.res)
+	if err != nil {
+		return err
+	}
+	it.done = !more
+	return nil
+}
+
+func (it *joinIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+func (it *joinIterator) next() ([]sqltypes.Value, error) {
+	for it.idx >= len(it.res.Rows) {
+		if it.done {
+			return nil, nil
+		}
+		if err := it.refill(); err != nil {
+			return nil, err
+		}
+	}
+	it.idx++
+	return it.res.Rows[it.idx-1], nil
+}
+
+func (it *joinIterator) close() {
+	for _, src := range it.sources {
+		src.close()
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"planner"
 	"xcontext"
 
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -40,3 +41,31 @@ func (executor *LimitExecutor) Execute(ctx *xcontext.ResultContext) error {
 	rs.Limit(plan.Offset, plan.Limit)
 	return nil
 }
+
+// limitIterator skips the offset rows of the src and returns at most limit rows.
+type limitIterator struct {
+	rowIterator
+	offset, count int
+}
+
+func newLimitIterator(plan *planner.LimitPlan, src rowIterator) *limitIterator {
+	return &limitIterator{
+		rowIterator: src,
+		offset:      plan.Offset,
+		count:       plan.Limit,
+	}
+}
+
+func (it *limitIterator) next() ([]sqltypes.Value, error) {
+	for ; it.offset > 0; it.offset-- {
+		row, err := it.rowIterator.next()
+		if err != nil || row == nil {
+			return nil, err
+		}
+	}
+	if it.count <= 0 {
+		return nil, nil
+	}
+	it.count--
+	return it.rowIterator.next()
+}
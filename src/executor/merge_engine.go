@@ -9,6 +9,8 @@
 package executor
 
 import (
+	"time"
+
 	"backend"
 	"planner"
 	"xcontext"
@@ -42,11 +44,24 @@ func NewMergeEngine(log *xlog.Log, node *planner.MergeNode, txn backend.Transact
 func (m *MergeEngine) execute(ctx *xcontext.ResultContext) error {
 	var err error
 
+	// The ORDER BY ... LIMIT across shards, only the top rows are read by the k-way merge
+	// if the keys are byte-wise comparable.
+	if orderBy, limit := m.sortedPlans(); orderBy != nil && limit != nil {
+		return m.executeMerge(ctx)
+	}
+
+	// If the spilling is enabled, the rows are read by the cursors and the sorts spill the
+	// sorted runs while the rows arrive, the whole result isn't buffered before.
+	if m.node.ReqMode == xcontext.ReqNormal && spillable(m.node, m.txn) {
+		return executeIterate(m.iterate, m.node, ctx, m.txn)
+	}
+
 	reqCtx := xcontext.NewRequestContext()
 	reqCtx.Mode = m.node.ReqMode
 	reqCtx.TxnMode = xcontext.TxnRead
 	if reqCtx.Mode == xcontext.ReqNormal {
 		reqCtx.Querys = m.node.Querys
+		reqCtx.FoundRows = m.node.FoundRows
 	} else {
 		buf := sqlparser.NewTrackedBuffer(nil)
 		m.node.Sel.Format(buf)
@@ -56,7 +71,7 @@ func (m *MergeEngine) execute(ctx *xcontext.ResultContext) error {
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return execSubPlan(m.log, m.node, ctx)
+	return execSubPlan(m.log, m.node, ctx, m.txn)
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -64,6 +79,13 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	var query string
 	var err error
 
+	// The rows are read by the cursors if the spilling is enabled, see execute.
+	if spillable(m.node, m.txn) {
+		return executeIterate(func() (rowIterator, error) {
+			return m.iterBindVars(bindVars)
+		}, m.node, ctx, m.txn)
+	}
+
 	querys := m.node.Querys
 	for i, p := range m.node.ParsedQuerys {
 		query, err = p.GenerateQuery(bindVars, nil)
@@ -81,7 +103,7 @@ func (m *MergeEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 	if ctx.Results, err = m.txn.Execute(reqCtx); err != nil {
 		return err
 	}
-	return execSubPlan(m.log, m.node, ctx)
+	return execSubPlan(m.log, m.node, ctx, m.txn)
 }
 
 // getFields fetches the field info.
@@ -103,3 +125,110 @@ func (m *MergeEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string
 	}
 	return nil
 }
+
+// sortedPlans returns the orderby and limit(nil if no limit) plans if the shards' results can be
+// merged by the k-way merge, that is, the rows from each shard are sorted by the pushed-down ORDER BY
+// and no other plans need all the rows.
+func (m *MergeEngine) sortedPlans() (*planner.OrderByPlan, *planner.LimitPlan) {
+	if m.node.ReqMode != xcontext.ReqNormal {
+		return nil, nil
+	}
+
+	plans := m.node.Children().Plans()
+	switch len(plans) {
+	case 1:
+		if plans[0].Type() == planner.PlanTypeOrderby {
+			return plans[0].(*planner.OrderByPlan), nil
+		}
+	case 2:
+		if plans[0].Type() == planner.PlanTypeOrderby && plans[1].Type() == planner.PlanTypeLimit {
+			return plans[0].(*planner.OrderByPlan), plans[1].(*planner.LimitPlan)
+		}
+	}
+	return nil, nil
+}
+
+// iterate used to open the cursors on shards and iterate the rows. If the rows are sorted
+// across shards, they are merged by the k-way merge.
+func (m *MergeEngine) iterate() (rowIterator, error) {
+	if m.node.ReqMode != xcontext.ReqNormal {
+		ctx := xcontext.NewResultContext()
+		if err := m.execute(ctx); err != nil {
+			return nil, err
+		}
+		return newRowsIterator(ctx.Results), nil
+	}
+
+	cursors, err := m.openCursors(m.node.Querys)
+	if err != nil {
+		return nil, err
+	}
+	// The shards' order is only mergeable if the proxy compares the keys as the shards sort them,
+	// otherwise the rows are sorted by the proxy.
+	orderBy, limit := m.sortedPlans()
+	if orderBy == nil || !NewOrderByExecutor(m.log, orderBy).bytewise(cursors[0].Fields()) {
+		return iterateSubPlan(m.log, m.node, newCursorsIterator(cursors), m.txn)
+	}
+
+	var it rowIterator
+	less, err := NewOrderByExecutor(m.log, orderBy).less(cursors[0].Fields())
+	if err == nil {
+		it, err = newMergeIterator(cursors, less)
+	}
+	if err != nil {
+		newCursorsIterator(cursors).close()
+		return nil, err
+	}
+	if limit != nil {
+		it = newLimitIterator(limit, it)
+	}
+	return it, nil
+}
+
+// iterBindVars used to iterate the rows of the querys with bindvars.
+func (m *MergeEngine) iterBindVars(bindVars map[string]*querypb.BindVariable) (rowIterator, error) {
+	querys := make([]xcontext.QueryTuple, len(m.node.Querys))
+	copy(querys, m.node.Querys)
+	for i, p := range m.node.ParsedQuerys {
+		query, err := p.GenerateQuery(bindVars, nil)
+		if err != nil {
+			return nil, err
+		}
+		querys[i].Query = query
+	}
+
+	cursors, err := m.openCursors(querys)
+	if err != nil {
+		return nil, err
+	}
+	return iterateSubPlan(m.log, m.node, newCursorsIterator(cursors), m.txn)
+}
+
+// openCursors used to open the cursors of the querys.
+func (m *MergeEngine) openCursors(querys []xcontext.QueryTuple) ([]backend.Cursor, error) {
+	reqCtx := xcontext.NewRequestContext()
+	reqCtx.Mode = xcontext.ReqNormal
+	reqCtx.TxnMode = xcontext.TxnRead
+	reqCtx.Querys = querys
+	reqCtx.FoundRows = m.node.FoundRows
+	return m.txn.ExecuteCursors(reqCtx)
+}
+
+// executeMerge used to execute the ORDER BY ... LIMIT by the k-way merge, it stops reading
+// once offset+limit rows have been merged. If the keys aren't byte-wise comparable, the rows
+// are sorted by the proxy.
+func (m *MergeEngine) executeMerge(ctx *xcontext.ResultContext) error {
+	start := time.Now()
+	it, err := m.iterate()
+	if err != nil {
+		return err
+	}
+	if ctx.Results, err = fetchIterator(it, m.txn.MaxResult()); err != nil {
+		return err
+	}
+	// The order by and the limit are done by the merge.
+	orderBy, limit := m.sortedPlans()
+	analyzePlan(m.txn, orderBy, start, ctx.Results)
+	analyzePlan(m.txn, limit, start, ctx.Results)
+	return nil
+}
@@ -14,13 +14,12 @@ import (
 
 	"planner"
 
-	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 // sortMergeJoin used to join `lres` and `rres` to `res`.
-func sortMergeJoin(lres, rres, res *sqltypes.Result, node *planner.JoinNode, maxrow int) error {
+func sortMergeJoin(lres, rres *sqltypes.Result, res *joinResult, node *planner.JoinNode) error {
 	var wg sync.WaitGroup
 	sort := func(keys []planner.JoinKey, res *sqltypes.Result) {
 		defer wg.Done()
@@ -41,17 +40,21 @@ func sortMergeJoin(lres, rres, res *sqltypes.Result, node *planner.JoinNode, max
 	go sort(node.RightKeys, rres)
 	wg.Wait()
 
-	return mergeJoin(lres, rres, res, node, maxrow)
+	return mergeJoin(lres, rres, res, node)
 }
 
 // mergeJoin used to join the sorted results.
-func mergeJoin(lres, rres, res *sqltypes.Result, node *planner.JoinNode, maxrow int) error {
+func mergeJoin(lres, rres *sqltypes.Result, res *joinResult, node *planner.JoinNode) error {
 	var err error
 	lrows, lidx := fetchSameKeyRows(lres.Rows, node.LeftKeys, 0)
 	rrows, ridx := fetchSameKeyRows(rres.Rows, node.RightKeys, 0)
-	for lrows != nil {
+	for lrows != nil || (node.IsFullJoin && rrows != nil) {
+		if lrows == nil {
+			err = concatNilAndRight(rres.Rows[ridx-len(rrows):], node, res)
+			break
+		}
 		if rrows == nil {
-			err = concatLeftAndNil(lres.Rows[lidx-len(lrows):], node, res, maxrow)
+			err = concatLeftAndNil(lres.Rows[lidx-len(lrows):], node, res)
 			break
 		}
 
@@ -70,17 +73,20 @@ func mergeJoin(lres, rres, res *sqltypes.Result, node *planner.JoinNode, maxrow
 
 		if cmp == 0 {
 			if isNull {
-				err = concatLeftAndNil(lrows, node, res, maxrow)
+				if err = concatLeftAndNil(lrows, node, res); err == nil {
+					err = concatNilAndRight(rrows, node, res)
+				}
 			} else {
-				err = concatLeftAndRight(lrows, rrows, node, res, maxrow)
+				err = concatLeftAndRight(lrows, rrows, node, res)
 			}
 
 			lrows, lidx = fetchSameKeyRows(lres.Rows, node.LeftKeys, lidx)
 			rrows, ridx = fetchSameKeyRows(rres.Rows, node.RightKeys, ridx)
 		} else if cmp > 0 {
+			err = concatNilAndRight(rrows, node, res)
 			rrows, ridx = fetchSameKeyRows(rres.Rows, node.RightKeys, ridx)
 		} else {
-			err = concatLeftAndNil(lrows, node, res, maxrow)
+			err = concatLeftAndNil(lrows, node, res)
 			lrows, lidx = fetchSameKeyRows(lres.Rows, node.LeftKeys, lidx)
 		}
 
@@ -128,7 +134,7 @@ func keysEqual(row1, row2 []sqltypes.Value, joins []planner.JoinKey) bool {
 }
 
 // concatLeftAndRight used to concat thle left and right results, handle otherJoinOn|rightNull|OtherFilter.
-func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode, res *sqltypes.Result, maxrow int) error {
+func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode, res *joinResult) error {
 	var err error
 	var mu sync.Mutex
 	p := newCalcPool(joinWorkers)
@@ -150,53 +156,7 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode,
 
 		if blend {
 			for _, rrow := range rrows {
-				match := true
-				for _, filter := range node.CmpFilter {
-					v1, v2 := lrow[filter.Left], rrow[filter.Right]
-					if filter.Exchange {
-						v1, v2 = v2, v1
-					}
-					cmp := sqltypes.NullsafeCompare(v1, v2)
-					switch filter.Operator {
-					case sqlparser.EqualStr:
-						if cmp != 0 {
-							match = false
-						}
-					case sqlparser.LessThanStr:
-						if cmp != -1 {
-							match = false
-						}
-					case sqlparser.GreaterThanStr:
-						if cmp != 1 {
-							match = false
-						}
-					case sqlparser.LessEqualStr:
-						if cmp == 1 {
-							match = false
-						}
-					case sqlparser.GreaterEqualStr:
-						if cmp == -1 {
-							match = false
-						}
-					case sqlparser.NotEqualStr:
-						if cmp == 0 {
-							match = false
-						}
-					case sqlparser.NullSafeEqualStr:
-						if cmp != 0 {
-							match = false
-						}
-					}
-					if !match {
-						break
-					}
-					// null value cannot match.
-					if filter.Operator != sqlparser.NullSafeEqualStr && (lrow[filter.Left].IsNull() || rrow[filter.Right].IsNull()) {
-						match = false
-						break
-					}
-				}
-				if match {
+				if cmpFiltersMatch(lrow, rrow, node.CmpFilter) {
 					matchCnt++
 					ok := true
 					for _, idx := range node.RightTmpCols {
@@ -208,10 +168,7 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode,
 					if ok {
 						mu.Lock()
 						if err == nil {
-							res.Rows = append(res.Rows, joinRows(lrow, rrow, node.Cols))
-							res.RowsAffected++
-							if len(res.Rows) > maxrow {
-								err = errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+							if err = res.append(joinRows(lrow, rrow, node.Cols)); err != nil {
 								mu.Unlock()
 								break
 							}
@@ -224,11 +181,7 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode,
 		if matchCnt == 0 && node.IsLeftJoin && !node.HasRightFilter {
 			mu.Lock()
 			if err == nil {
-				res.Rows = append(res.Rows, joinRows(lrow, nil, node.Cols))
-				res.RowsAffected++
-				if len(res.Rows) > maxrow {
-					err = errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
-				}
+				err = res.append(joinRows(lrow, nil, node.Cols))
 			}
 			mu.Unlock()
 		}
@@ -243,13 +196,69 @@ func concatLeftAndRight(lrows, rrows [][]sqltypes.Value, node *planner.JoinNode,
 	return err
 }
 
-func concatLeftAndNil(lrows [][]sqltypes.Value, node *planner.JoinNode, res *sqltypes.Result, maxrow int) error {
-	if node.IsLeftJoin && !node.HasRightFilter {
+// cmpFiltersMatch returns true if the left and right rows match all the comparison filters.
+func cmpFiltersMatch(lrow, rrow []sqltypes.Value, filters []planner.Comparison) bool {
+	for _, filter := range filters {
+		v1, v2 := lrow[filter.Left], rrow[filter.Right]
+		if filter.Exchange {
+			v1, v2 = v2, v1
+		}
+		cmp := sqltypes.NullsafeCompare(v1, v2)
+		switch filter.Operator {
+		case sqlparser.EqualStr:
+			if cmp != 0 {
+				return false
+			}
+		case sqlparser.LessThanStr:
+			if cmp != -1 {
+				return false
+			}
+		case sqlparser.GreaterThanStr:
+			if cmp != 1 {
+				return false
+			}
+		case sqlparser.LessEqualStr:
+			if cmp == 1 {
+				return false
+			}
+		case sqlparser.GreaterEqualStr:
+			if cmp == -1 {
+				return false
+			}
+		case sqlparser.NotEqualStr:
+			if cmp == 0 {
+				return false
+			}
+		case sqlparser.NullSafeEqualStr:
+			if cmp != 0 {
+				return false
+			}
+		}
+		// null value cannot match.
+		if filter.Operator != sqlparser.NullSafeEqualStr && (lrow[filter.Left].IsNull() || rrow[filter.Right].IsNull()) {
+			return false
+		}
+	}
+	return true
+}
+
+func concatLeftAndNil(lrows [][]sqltypes.Value, node *planner.JoinNode, res *joinResult) error {
+	if (node.IsLeftJoin && !node.HasRightFilter) || node.IsFullJoin || node.IsAntiJoin {
 		for _, row := range lrows {
-			res.Rows = append(res.Rows, joinRows(row, nil, node.Cols))
-			res.RowsAffected++
-			if len(res.Rows) > maxrow {
-				return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", maxrow)
+			if err := res.append(joinRows(row, nil, node.Cols)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// concatNilAndRight used to concat the unmatched right rows with the null left of the full join.
+func concatNilAndRight(rrows [][]sqltypes.Value, node *planner.JoinNode, res *joinResult) error {
+	if node.IsFullJoin {
+		for _, row := range rrows {
+			if err := res.append(joinRows(nil, row, node.Cols)); err != nil {
+				return err
 			}
 		}
 	}
@@ -14,6 +14,7 @@ import (
 	"xcontext"
 
 	"github.com/pkg/errors"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -36,36 +37,37 @@ func NewOrderByExecutor(log *xlog.Log, plan planner.Plan) *OrderByExecutor {
 	}
 }
 
-// Execute used to execute the executor.
+// Execute used to execute the executor, the rows are sorted in memory.
+// The engines feed the spilling sorter from the cursors instead if the spilling is enabled.
 func (executor *OrderByExecutor) Execute(ctx *xcontext.ResultContext) error {
-	var err error
 	rs := ctx.Results
-	plan := executor.plan.(*planner.OrderByPlan)
+	if len(rs.Rows) < 2 {
+		return nil
+	}
+
+	less, err := executor.less(rs.Fields)
+	if err != nil {
+		return err
+	}
 
 	sort.Slice(rs.Rows, func(i, j int) bool {
-		// If there are any errors below, the function sets
-		// the external err and returns true. Once err is set,
-		// all subsequent calls return true. This will make
-		// Slice think that all elements are in the correct
-		// order and return more quickly.
-		for _, orderby := range plan.OrderBys {
-			if err != nil {
-				return true
-			}
+		// The equal rows are treated as in order.
+		return !less(rs.Rows[j], rs.Rows[i])
+	})
+	return nil
+}
 
-			idx := -1
-			for k, f := range rs.Fields {
-				if f.Name == orderby.Field && (orderby.Table == "" || orderby.Table == f.Table) {
-					idx = k
-					break
-				}
-			}
-			if idx == -1 {
-				err = errors.Errorf("can.not.find.the.orderby.field[%s].direction.asc", orderby.Field)
-				return true
-			}
+// less returns the less function by the order by fields.
+func (executor *OrderByExecutor) less(fields []*querypb.Field) (rowLess, error) {
+	plan := executor.plan.(*planner.OrderByPlan)
+	idxs, err := executor.fieldIdxs(fields)
+	if err != nil {
+		return nil, err
+	}
 
-			cmp := sqltypes.NullsafeCompare(rs.Rows[i][idx], rs.Rows[j][idx])
+	return func(a, b []sqltypes.Value) bool {
+		for i, orderby := range plan.OrderBys {
+			cmp := sqltypes.NullsafeCompare(a[idxs[i]], b[idxs[i]])
 			if cmp == 0 {
 				continue
 			}
@@ -74,8 +76,58 @@ func (executor *OrderByExecutor) Execute(ctx *xcontext.ResultContext) error {
 			}
 			return cmp < 0
 		}
-		return true
-	})
+		return false
+	}, nil
+}
 
-	return err
+// fieldIdxs returns the indexes of the order by fields in the fields.
+func (executor *OrderByExecutor) fieldIdxs(fields []*querypb.Field) ([]int, error) {
+	plan := executor.plan.(*planner.OrderByPlan)
+	idxs := make([]int, len(plan.OrderBys))
+	for i, orderby := range plan.OrderBys {
+		idx := -1
+		for k, f := range fields {
+			if f.Name == orderby.Field && (orderby.Table == "" || orderby.Table == f.Table) {
+				idx = k
+				break
+			}
+		}
+		if idx == -1 {
+			return nil, errors.Errorf("can.not.find.the.orderby.field[%s].direction.asc", orderby.Field)
+		}
+		idxs[i] = idx
+	}
+	return idxs, nil
+}
+
+// bytewise returns true if all the order by fields are compared by the shards as the less does,
+// that is, they are numeric, temporal(except TIME, whose negative values aren't byte-wise ordered)
+// or binary. The strings are sorted by their collations on the shards, such as utf8mb4_general_ci,
+// which the byte-wise comparing doesn't follow.
+func (executor *OrderByExecutor) bytewise(fields []*querypb.Field) bool {
+	idxs, err := executor.fieldIdxs(fields)
+	if err != nil {
+		return false
+	}
+	for _, idx := range idxs {
+		typ := fields[idx].Type
+		switch {
+		case sqltypes.IsIntegral(typ), sqltypes.IsFloat(typ), typ == sqltypes.Decimal:
+		case typ == sqltypes.Date, typ == sqltypes.Datetime, typ == sqltypes.Timestamp:
+		case sqltypes.IsBinary(typ):
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newOrderByIterator used to sort the rows of the src.
+func newOrderByIterator(executor *OrderByExecutor, src rowIterator, spill *spillConf) (rowIterator, error) {
+	less, err := executor.less(src.fields())
+	if err != nil {
+		src.close()
+		return nil, err
+	}
+	return newSorterIterator(src, spill, less)
 }
@@ -9,11 +9,14 @@
 package executor
 
 import (
+	"time"
+
 	"backend"
 	"planner"
 	"xcontext"
 
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -22,11 +25,19 @@ type PlanEngine interface {
 	execute(ctx *xcontext.ResultContext) error
 	execBindVars(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable, wantfields bool) error
 	getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error
+	iterate() (rowIterator, error)
 }
 
 // buildEngine used to build the executor tree.
 func buildEngine(log *xlog.Log, plan planner.PlanNode, txn backend.Transaction) PlanEngine {
 	var engine PlanEngine
+	// The querys of the node are recorded to its own stats if analyzed.
+	analyze, ok := txn.(*analyzeTxn)
+	if ok {
+		analyze = analyze.forNode(plan)
+		txn = analyze
+	}
+
 	switch node := plan.(type) {
 	case *planner.MergeNode:
 		engine = NewMergeEngine(log, node, txn)
@@ -41,32 +52,148 @@ func buildEngine(log *xlog.Log, plan planner.PlanNode, txn backend.Transaction)
 		unionEngine.right = buildEngine(log, node.Right, txn)
 		engine = unionEngine
 	}
+	if analyze != nil {
+		engine = &analyzeEngine{PlanEngine: engine, txn: analyze}
+	}
 	return engine
 }
 
+// removeHiddenCols used to remove the last hiddenCols columns which added by the planner.
+func removeHiddenCols(rs *sqltypes.Result, hiddenCols int) {
+	if hiddenCols == 0 {
+		return
+	}
+	rs.Fields = rs.Fields[:len(rs.Fields)-hiddenCols]
+	for i, row := range rs.Rows {
+		rs.Rows[i] = row[:len(row)-hiddenCols]
+	}
+}
+
+// spillable returns true if the spilling is enabled and the children plans of the node need all
+// the rows, such as the ORDER BY, GROUP BY and window functions.
+func spillable(node planner.PlanNode, txn backend.Transaction) bool {
+	if newSpillConf(txn) == nil {
+		return false
+	}
+	subPlanTree := node.Children()
+	if subPlanTree == nil {
+		return false
+	}
+	for _, subPlan := range subPlanTree.Plans() {
+		switch subPlan.Type() {
+		case planner.PlanTypeAggregate, planner.PlanTypeOrderby, planner.PlanTypeWindow:
+			return true
+		}
+	}
+	return false
+}
+
+// executeIterate used to execute the node and its children plans by the iterator got from the iterate,
+// the sorts spill the sorted runs while the rows arrive, only the output rows are held in memory.
+func executeIterate(iterate func() (rowIterator, error), node planner.PlanNode, ctx *xcontext.ResultContext, txn backend.Transaction) error {
+	start := time.Now()
+	it, err := iterate()
+	if err != nil {
+		return err
+	}
+	if ctx.Results, err = fetchIterator(it, txn.MaxResult()); err != nil {
+		return err
+	}
+	for _, subPlan := range node.Children().Plans() {
+		analyzePlan(txn, subPlan, start, ctx.Results)
+	}
+	return nil
+}
+
 // execSubPlan used to execute all the children plan.
-func execSubPlan(log *xlog.Log, node planner.PlanNode, ctx *xcontext.ResultContext) error {
+func execSubPlan(log *xlog.Log, node planner.PlanNode, ctx *xcontext.ResultContext, txn backend.Transaction) error {
+	if ctx.Results != nil {
+		analyzeNode(txn, len(ctx.Results.Rows), rowsSize(ctx.Results.Rows))
+	}
 	subPlanTree := node.Children()
 	if subPlanTree != nil {
-		for _, subPlan := range subPlanTree.Plans() {
-			switch subPlan.Type() {
-			case planner.PlanTypeAggregate:
-				aggrExecutor := NewAggregateExecutor(log, subPlan)
-				if err := aggrExecutor.Execute(ctx); err != nil {
-					return err
-				}
-			case planner.PlanTypeOrderby:
-				orderByExecutor := NewOrderByExecutor(log, subPlan)
-				if err := orderByExecutor.Execute(ctx); err != nil {
-					return err
-				}
-			case planner.PlanTypeLimit:
-				limitExecutor := NewLimitExecutor(log, subPlan)
-				if err := limitExecutor.Execute(ctx); err != nil {
-					return err
-				}
+		return execPlans(log, subPlanTree.Plans(), ctx, txn)
+	}
+	return nil
+}
+
+// execPlans used to execute the plans in order.
+func execPlans(log *xlog.Log, plans []planner.Plan, ctx *xcontext.ResultContext, txn backend.Transaction) error {
+	spill := newSpillConf(txn)
+	for _, subPlan := range plans {
+		start := time.Now()
+		switch subPlan.Type() {
+		case planner.PlanTypeAggregate:
+			aggrExecutor := newAggregateExecutor(log, subPlan, txn)
+			if err := aggrExecutor.Execute(ctx); err != nil {
+				return err
+			}
+		case planner.PlanTypeOrderby:
+			orderByExecutor := NewOrderByExecutor(log, subPlan)
+			if err := orderByExecutor.Execute(ctx); err != nil {
+				return err
+			}
+		case planner.PlanTypeProject:
+			projectExecutor := NewProjectExecutor(log, subPlan)
+			if err := projectExecutor.Execute(ctx); err != nil {
+				return err
+			}
+		case planner.PlanTypeFilter:
+			filterExecutor := NewFilterExecutor(log, subPlan)
+			if err := filterExecutor.Execute(ctx); err != nil {
+				return err
+			}
+		case planner.PlanTypeWindow:
+			windowExecutor := NewWindowExecutor(log, subPlan)
+			windowExecutor.spill = spill
+			if err := windowExecutor.Execute(ctx); err != nil {
+				return err
+			}
+		case planner.PlanTypeLimit:
+			limitExecutor := NewLimitExecutor(log, subPlan)
+			if err := limitExecutor.Execute(ctx); err != nil {
+				return err
 			}
 		}
+		analyzePlan(txn, subPlan, start, ctx.Results)
 	}
 	return nil
 }
+
+// execSpilledSubPlan used to execute all the children plan, the rows are in the src which
+// spilled to disk. The first limit consumes the src, the others execute in memory. The plans
+// which need all the rows are executed by the iterators, see spillable.
+func execSpilledSubPlan(log *xlog.Log, node planner.PlanNode, ctx *xcontext.ResultContext, txn backend.Transaction, src *spillSorter) error {
+	defer src.close()
+	analyzeNode(txn, src.count(), src.size)
+
+	var plans []planner.Plan
+	if subPlanTree := node.Children(); subPlanTree != nil {
+		plans = subPlanTree.Plans()
+	}
+
+	rs := ctx.Results
+	if len(plans) == 0 {
+		return fetchRows(rs, src, -1)
+	}
+
+	start := time.Now()
+	subPlan := plans[0]
+	switch subPlan.Type() {
+	case planner.PlanTypeLimit:
+		limit := subPlan.(*planner.LimitPlan)
+		if err := fetchRows(rs, src, limit.Offset+limit.Limit); err != nil {
+			return err
+		}
+		if err := NewLimitExecutor(log, subPlan).Execute(ctx); err != nil {
+			return err
+		}
+	default:
+		if err := fetchRows(rs, src, -1); err != nil {
+			return err
+		}
+		return execPlans(log, plans, ctx, txn)
+	}
+	analyzePlan(txn, subPlan, start, rs)
+	return execPlans(log, plans[1:], ctx, txn)
+}
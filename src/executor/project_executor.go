@@ -0,0 +1,122 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"expression"
+	"planner"
+	"xcontext"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Executor = &ProjectExecutor{}
+)
+
+// ProjectExecutor represents project executor, the exprs are evaluated on the merged rows.
+type ProjectExecutor struct {
+	log  *xlog.Log
+	plan planner.Plan
+}
+
+// NewProjectExecutor creates the new project executor.
+func NewProjectExecutor(log *xlog.Log, plan planner.Plan) *ProjectExecutor {
+	return &ProjectExecutor{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the executor.
+func (executor *ProjectExecutor) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	evals, fields, err := executor.compile(rs.Fields)
+	if err != nil {
+		return err
+	}
+	for _, row := range rs.Rows {
+		if err := executor.project(evals, row); err != nil {
+			return err
+		}
+	}
+	rs.Fields = fields
+	return nil
+}
+
+// compile used to compile the projections on the fields, returns the evaluators
+// and the fields fixed by the result types.
+func (executor *ProjectExecutor) compile(fields []*querypb.Field) ([]expression.Evaluator, []*querypb.Field, error) {
+	plan := executor.plan.(*planner.ProjectPlan)
+	evals := make([]expression.Evaluator, len(plan.Projections))
+	fixed := append([]*querypb.Field{}, fields...)
+	for i, proj := range plan.Projections {
+		eval, err := expression.NewEvaluator(proj.Expr, fields)
+		if err != nil {
+			return nil, nil, err
+		}
+		field := *fields[proj.Index]
+		eval.FixField(&field)
+		fixed[proj.Index] = &field
+		evals[i] = eval
+	}
+	return evals, fixed, nil
+}
+
+// project used to evaluate the projections and set the values to the row.
+func (executor *ProjectExecutor) project(evals []expression.Evaluator, row []sqltypes.Value) error {
+	plan := executor.plan.(*planner.ProjectPlan)
+	for i, eval := range evals {
+		val, err := eval.Eval(row)
+		if err != nil {
+			return err
+		}
+		row[plan.Projections[i].Index] = val
+	}
+	return nil
+}
+
+// projectIterator evaluates the projections on the rows of the src.
+type projectIterator struct {
+	rowIterator
+	executor *ProjectExecutor
+	evals    []expression.Evaluator
+	flds     []*querypb.Field
+}
+
+func newProjectIterator(executor *ProjectExecutor, src rowIterator) (*projectIterator, error) {
+	evals, fields, err := executor.compile(src.fields())
+	if err != nil {
+		src.close()
+		return nil, err
+	}
+	return &projectIterator{
+		rowIterator: src,
+		executor:    executor,
+		evals:       evals,
+		flds:        fields,
+	}, nil
+}
+
+func (it *projectIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+func (it *projectIterator) next() ([]sqltypes.Value, error) {
+	row, err := it.rowIterator.next()
+	if err != nil || row == nil {
+		return nil, err
+	}
+	if err := it.executor.project(it.evals, row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
@@ -0,0 +1,195 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"backend"
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestProjectExecutor(t *testing.T) {
+	fields := []*querypb.Field{
+		{
+			Name: "a",
+			Type: querypb.Type_INT32,
+		},
+		{
+			Name: "x",
+			Type: querypb.Type_NULL_TYPE,
+		},
+		{
+			Name: "ifnull(max(d), 0)",
+			Type: querypb.Type_NULL_TYPE,
+		},
+		{
+			Name: "sum(b)",
+			Type: querypb.Type_DECIMAL,
+		},
+		{
+			Name: "count(c)",
+			Type: querypb.Type_INT64,
+		},
+		{
+			Name: "max(d)",
+			Type: querypb.Type_INT32,
+		},
+	}
+	r1 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.NULL,
+				sqltypes.NULL,
+				sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("10")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("4")),
+				sqltypes.NULL,
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.NULL,
+				sqltypes.NULL,
+				sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("3")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("5")),
+			},
+		},
+	}
+	r2 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.NULL,
+				sqltypes.NULL,
+				sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("5")),
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("7")),
+			},
+		},
+	}
+	r3 := &sqltypes.Result{Fields: fields}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// Add querys.
+	fakedbs.AddQuery("select a, null as x, null as `ifnull(max(d), 0)`, sum(b) as `sum(b)`, count(c) as `count(c)`, max(d) as `max(d)` from sbtest.A0 as A where id > 8 group by a order by a asc", r1)
+	fakedbs.AddQuery("select a, null as x, null as `ifnull(max(d), 0)`, sum(b) as `sum(b)`, count(c) as `count(c)`, max(d) as `max(d)` from sbtest.A2 as A where id > 8 group by a order by a asc", r2)
+	fakedbs.AddQuery("select a, null as x, null as `ifnull(max(d), 0)`, sum(b) as `sum(b)`, count(c) as `count(c)`, max(d) as `max(d)` from sbtest.A4 as A where id > 8 group by a order by a asc", r3)
+	fakedbs.AddQuery("select a, null as x, null as `ifnull(max(d), 0)`, sum(b) as `sum(b)`, count(c) as `count(c)`, max(d) as `max(d)` from sbtest.A8 as A where id > 8 group by a order by a asc", r3)
+
+	query := "select a, sum(b)/count(c) as x, ifnull(max(d), 0) from A where id>8 group by a"
+	want := "[[1 3.0000 7] [2 3.0000 5]]"
+
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	log.Debug("plan:%+v", plan.JSON())
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+	{
+		ctx := xcontext.NewResultContext()
+		err := executor.Execute(ctx)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", ctx.Results.Rows)
+		assert.Equal(t, want, got)
+		assert.Equal(t, 3, len(ctx.Results.Fields))
+		assert.Equal(t, querypb.Type_DECIMAL, ctx.Results.Fields[1].Type)
+		assert.Equal(t, uint32(4), ctx.Results.Fields[1].Decimals)
+		assert.Equal(t, querypb.Type_INT64, ctx.Results.Fields[2].Type)
+		// The fields of the backends are not changed.
+		assert.Equal(t, querypb.Type_NULL_TYPE, fields[1].Type)
+	}
+	// Stream fetch.
+	{
+		rs := streamFetchRows(t, executor)
+		got := fmt.Sprintf("%v", rs.Rows)
+		assert.Equal(t, want, got)
+		assert.Equal(t, querypb.Type_DECIMAL, rs.Fields[1].Type)
+	}
+}
+
+func TestProjectExecutorErr(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "max(a) + 1",
+				Type: querypb.Type_NULL_TYPE,
+			},
+			{
+				Name: "max(a)",
+				Type: querypb.Type_INT64,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.NULL,
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("9223372036854775807")),
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select null as `max\\(a\\) \\+ 1`, max\\(a\\) as `max\\(a\\)` from .*", r1)
+
+	query := "select max(a) + 1 from A"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+	ctx := xcontext.NewResultContext()
+	err = executor.Execute(ctx)
+	assert.NotNil(t, err)
+}
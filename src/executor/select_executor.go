@@ -13,6 +13,7 @@ import (
 	"planner"
 	"xcontext"
 
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -44,5 +45,22 @@ func (executor *SelectExecutor) Execute(ctx *xcontext.ResultContext) error {
 	if err := planEngine.execute(ctx); err != nil {
 		return err
 	}
+	removeHiddenCols(ctx.Results, plan.HiddenCols)
 	return nil
 }
+
+// ExecuteStreamFetch used to execute the executor and send the rows to the callback in batches.
+// The rows are produced incrementally by the iterators, the sorts spill over the memory budget
+// and the shards' results are limited by the txn as the Execute.
+func (executor *SelectExecutor) ExecuteStreamFetch(callback func(*sqltypes.Result) error, streamBufferSize int) error {
+	plan := executor.plan.(*planner.SelectPlan)
+	// The plain rows across shards are sent as they arrive.
+	if m, ok := plan.Root.(*planner.MergeNode); ok && len(m.Children().Plans()) == 0 && plan.HiddenCols == 0 {
+		reqCtx := xcontext.NewRequestContext()
+		reqCtx.Mode = m.ReqMode
+		reqCtx.Querys = m.GetQuery()
+		return executor.txn.ExecuteStreamFetch(reqCtx, callback, streamBufferSize)
+	}
+
+	return streamFetch(buildEngine(executor.log, plan.Root, executor.txn), plan.HiddenCols, callback, streamBufferSize)
+}
@@ -10,6 +10,8 @@ package executor
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 	"testing"
 
 	"backend"
@@ -127,6 +129,265 @@ func TestMergeEngine(t *testing.T) {
 	}
 }
 
+func TestMergeEngineSorted(t *testing.T) {
+	newResult := func(ids ...string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "id",
+					Type: querypb.Type_INT32,
+				},
+				{
+					Name: "name",
+					Type: querypb.Type_VARCHAR,
+				},
+			},
+		}
+		for _, id := range ids {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name"+id)),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// The rows of each shard are sorted by the pushed-down order by.
+	fakedbs.AddQueryPattern("select id, name from sbtest.A0 .*", newResult("9", "5", "1"))
+	fakedbs.AddQueryPattern("select id, name from sbtest.A2 .*", newResult("6", "2"))
+	fakedbs.AddQueryPattern("select id, name from sbtest.A4 .*", newResult("7", "3"))
+	fakedbs.AddQueryPattern("select id, name from sbtest.A8 .*", newResult("8", "4"))
+
+	querys := []string{
+		"select id, name from A order by id desc limit 1, 3",
+		"select id, name from A order by id desc limit 0",
+		"select id, name from A order by id desc",
+	}
+	results := []string{
+		"[[8 name8] [7 name7] [6 name6]]",
+		"[]",
+		"[[9 name9] [8 name8] [7 name7] [6 name6] [5 name5] [4 name4] [3 name3] [2 name2] [1 name1]]",
+	}
+	streams := []string{
+		"[[8 name8] [7 name7] [6 name6]]",
+		"[]",
+		"[[9 name9] [8 name8] [7 name7] [6 name6] [5 name5] [4 name4] [3 name3] [2 name2] [1 name1]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		executor := NewSelectExecutor(log, plan, txn)
+
+		// Execute.
+		{
+			ctx := xcontext.NewResultContext()
+			err := executor.Execute(ctx)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got)
+		}
+
+		// Stream fetch.
+		{
+			var states []sqltypes.ResultState
+			rs := &sqltypes.Result{}
+			err := executor.ExecuteStreamFetch(func(qr *sqltypes.Result) error {
+				states = append(states, qr.State)
+				rs.Rows = append(rs.Rows, qr.Rows...)
+				return nil
+			}, 16)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, streams[i], got)
+			assert.Equal(t, sqltypes.RStateFields, states[0])
+			assert.Equal(t, sqltypes.RStateFinished, states[len(states)-1])
+		}
+	}
+}
+
+func TestMergeEngineSortedCollation(t *testing.T) {
+	newResult := func(names ...string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name: "name",
+					Type: querypb.Type_VARCHAR,
+				},
+			},
+		}
+		for _, name := range names {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(name)),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// The shards sort the names case-insensitively, which isn't the byte-wise order.
+	fakedbs.AddQueryPattern("select name from sbtest.A0 .*", newResult("a", "B"))
+	fakedbs.AddQueryPattern("select name from sbtest.A2 .*", newResult("C"))
+	fakedbs.AddQueryPattern("select name from sbtest.A4 .*", newResult())
+	fakedbs.AddQueryPattern("select name from sbtest.A8 .*", newResult())
+
+	query := "select name from A order by name limit 2"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+
+	// The rows are sorted by the proxy instead of the k-way merge.
+	want := "[[B] [C]]"
+	{
+		ctx := xcontext.NewResultContext()
+		err := executor.Execute(ctx)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", ctx.Results.Rows)
+		assert.Equal(t, want, got)
+	}
+	{
+		rs := &sqltypes.Result{}
+		err := executor.ExecuteStreamFetch(func(qr *sqltypes.Result) error {
+			rs.Rows = append(rs.Rows, qr.Rows...)
+			return nil
+		}, 16)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", rs.Rows)
+		assert.Equal(t, want, got)
+	}
+}
+
+func TestMergeEngineHiddenCols(t *testing.T) {
+	newResult := func(ids ...string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name:  "name",
+					Type:  querypb.Type_VARCHAR,
+					Table: "A",
+				},
+				{
+					Name:  "id",
+					Type:  querypb.Type_INT32,
+					Table: "A",
+				},
+			},
+		}
+		for _, id := range ids {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name"+id)),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id)),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select name, .*id from sbtest.A0 .*", newResult("9", "5", "1"))
+	fakedbs.AddQueryPattern("select name, .*id from sbtest.A2 .*", newResult("6", "2"))
+	fakedbs.AddQueryPattern("select name, .*id from sbtest.A4 .*", newResult("7", "3"))
+	fakedbs.AddQueryPattern("select name, .*id from sbtest.A8 .*", newResult("8", "4"))
+
+	querys := []string{
+		"select name from A order by id desc limit 3",
+		"select name from A where id>1 order by A.id desc",
+	}
+	results := []string{
+		"[[name9] [name8] [name7]]",
+		"[[name9] [name8] [name7] [name6] [name5] [name4] [name3] [name2] [name1]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, 1, plan.HiddenCols)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		executor := NewSelectExecutor(log, plan, txn)
+
+		// Execute.
+		{
+			ctx := xcontext.NewResultContext()
+			err := executor.Execute(ctx)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", ctx.Results.Rows)
+			assert.Equal(t, results[i], got)
+			assert.Equal(t, 1, len(ctx.Results.Fields))
+		}
+
+		// Stream fetch.
+		{
+			var fields []*querypb.Field
+			rs := &sqltypes.Result{}
+			err := executor.ExecuteStreamFetch(func(qr *sqltypes.Result) error {
+				if qr.State == sqltypes.RStateFields {
+					fields = qr.Fields
+				}
+				rs.Rows = append(rs.Rows, qr.Rows...)
+				return nil
+			}, 16)
+			assert.Nil(t, err)
+			got := fmt.Sprintf("%v", rs.Rows)
+			assert.Equal(t, results[i], got)
+			assert.Equal(t, 1, len(fields))
+		}
+	}
+}
+
 func TestJoinEngine(t *testing.T) {
 	r1 := &sqltypes.Result{
 		Fields: []*querypb.Field{
@@ -444,6 +705,7 @@ func TestJoinEngine(t *testing.T) {
 		defer txn.Finish()
 		txn.SetMaxJoinRows(32768)
 		executor := NewSelectExecutor(log, plan, txn)
+		var fields []*querypb.Field
 		{
 			ctx := xcontext.NewResultContext()
 			err := executor.Execute(ctx)
@@ -451,11 +713,227 @@ func TestJoinEngine(t *testing.T) {
 			want := results[i]
 			got := fmt.Sprintf("%v", ctx.Results.Rows)
 			assert.Equal(t, want, got)
+			fields = ctx.Results.Fields
 			log.Debug("%+v", ctx.Results)
 		}
+
+		// Stream fetch, the rows joined by chunks may be in different order without order by.
+		{
+			rs := streamFetchRows(t, executor)
+			assert.Equal(t, fields, rs.Fields)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", rs.Rows)))
+		}
 	}
 }
 
+func TestJoinEngineFullJoin(t *testing.T) {
+	fields := func(table string) []*querypb.Field {
+		return []*querypb.Field{
+			{
+				Name:  "id",
+				Type:  querypb.Type_INT32,
+				Table: table,
+			},
+		}
+	}
+	rows := func(table string, ids ...string) *sqltypes.Result {
+		r := &sqltypes.Result{Fields: fields(table)}
+		for _, id := range ids {
+			v := sqltypes.NULL
+			if id != "" {
+				v = sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id))
+			}
+			r.Rows = append(r.Rows, []sqltypes.Value{v})
+		}
+		return r
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	shards := map[string][]string{
+		"A0": {"", "1", "3", "5"},
+		"A2": {},
+		"A4": {},
+		"A8": {"7"},
+	}
+	for shard, ids := range shards {
+		fakedbs.AddQuery(fmt.Sprintf("select A.id from sbtest.%s as A order by A.id asc", shard), rows("A", ids...))
+
+		// The coalesced column is a placeholder on the shards.
+		r := rows("A", ids...)
+		r.Fields = append([]*querypb.Field{{Name: "id", Type: querypb.Type_NULL_TYPE}}, r.Fields...)
+		for j := range r.Rows {
+			r.Rows[j] = append([]sqltypes.Value{sqltypes.NULL}, r.Rows[j]...)
+		}
+		fakedbs.AddQuery(fmt.Sprintf("select null as id, A.id from sbtest.%s as A order by A.id asc", shard), r)
+	}
+	fakedbs.AddQuery("select B.id from sbtest.B0 as B order by B.id asc", rows("B", "3", "4"))
+	fakedbs.AddQuery("select B.id from sbtest.B1 as B order by B.id asc", rows("B", "", "6", "7"))
+
+	querys := []string{
+		"select A.id, B.id from A full join B on A.id = B.id",
+		"select A.id, B.id from A full outer join B using (id)",
+		"select A.id, B.id from A full join B on A.id = B.id where A.id is null or B.id is null",
+		"select id from A full join B using (id) where id > 4",
+	}
+	results := []string{
+		"[[ ] [ ] [1 ] [3 3] [ 4] [5 ] [ 6] [7 7]]",
+		"[[ ] [ ] [1 ] [3 3] [ 4] [5 ] [ 6] [7 7]]",
+		"[[ ] [ ] [1 ] [ 4] [5 ] [ 6]]",
+		"[[5] [6] [7]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		executor := NewSelectExecutor(log, plan, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := executor.Execute(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", ctx.Results.Rows)))
+		}
+		{
+			rs := streamFetchRows(t, executor)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", rs.Rows)))
+		}
+	}
+}
+
+func TestJoinEngineSemiJoin(t *testing.T) {
+	fields := func(table string) []*querypb.Field {
+		return []*querypb.Field{
+			{
+				Name:  "id",
+				Type:  querypb.Type_INT32,
+				Table: table,
+			},
+		}
+	}
+	rows := func(table string, ids ...string) *sqltypes.Result {
+		r := &sqltypes.Result{Fields: fields(table)}
+		for _, id := range ids {
+			v := sqltypes.NULL
+			if id != "" {
+				v = sqltypes.MakeTrusted(querypb.Type_INT32, []byte(id))
+			}
+			r.Rows = append(r.Rows, []sqltypes.Value{v})
+		}
+		return r
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	shards := map[string][]string{
+		"A0": {"", "1", "3", "5"},
+		"A2": {},
+		"A4": {},
+		"A8": {"7"},
+	}
+	for shard, ids := range shards {
+		fakedbs.AddQuery(fmt.Sprintf("select A.id from sbtest.%s as A", shard), rows("A", ids...))
+	}
+	fakedbs.AddQuery("select B.id from sbtest.B0 as B", rows("B", "3", "4"))
+	fakedbs.AddQuery("select B.id from sbtest.B1 as B", rows("B", "", "6", "7"))
+	fakedbs.AddQuery("select B.id from sbtest.B0 as B where B.id is not null", rows("B", "3", "4"))
+	fakedbs.AddQuery("select B.id from sbtest.B1 as B where B.id is not null", rows("B", "6", "7"))
+
+	querys := []string{
+		"select A.id from A where exists (select 1 from B where B.id = A.id)",
+		"select A.id from A where not exists (select 1 from B where B.id = A.id)",
+		"select A.id from A where A.id in (select B.id from B)",
+		"select A.id from A where A.id not in (select B.id from B)",
+		"select A.id from A where A.id not in (select B.id from B where B.id is not null)",
+	}
+	results := []string{
+		"[[3] [7]]",
+		"[[] [1] [5]]",
+		"[[3] [7]]",
+		// The NULL in the subquery makes 'NOT IN' unknown.
+		"[]",
+		"[[1] [5]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxJoinRows(32768)
+		executor := NewSelectExecutor(log, plan, txn)
+		{
+			ctx := xcontext.NewResultContext()
+			err := executor.Execute(ctx)
+			assert.Nil(t, err)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", ctx.Results.Rows)))
+		}
+		{
+			rs := streamFetchRows(t, executor)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", rs.Rows)))
+		}
+	}
+}
+
+// streamFetchRows used to collect the rows of the stream fetch.
+func streamFetchRows(t *testing.T, executor interface {
+	ExecuteStreamFetch(func(*sqltypes.Result) error, int) error
+}) *sqltypes.Result {
+	rs := &sqltypes.Result{}
+	err := executor.ExecuteStreamFetch(func(qr *sqltypes.Result) error {
+		if qr.State == sqltypes.RStateFields {
+			rs.Fields = qr.Fields
+		}
+		rs.Rows = append(rs.Rows, qr.Rows...)
+		return nil
+	}, 8)
+	assert.Nil(t, err)
+	return rs
+}
+
+// sortedRows used to sort the rows of the formatted result.
+func sortedRows(rows string) []string {
+	rows = strings.TrimSuffix(strings.TrimPrefix(rows, "["), "]")
+	if rows == "" {
+		return nil
+	}
+	out := strings.Split(strings.TrimSuffix(strings.TrimPrefix(rows, "["), "]"), "] [")
+	sort.Strings(out)
+	return out
+}
+
 func TestJoinEngineErr(t *testing.T) {
 	r1 := &sqltypes.Result{
 		Fields: []*querypb.Field{
@@ -683,6 +1161,11 @@ func TestUnionEngine(t *testing.T) {
 			assert.Equal(t, want, got)
 			log.Debug("%+v", ctx.Results)
 		}
+		// Stream fetch.
+		{
+			rs := streamFetchRows(t, executor)
+			assert.Equal(t, sortedRows(results[i]), sortedRows(fmt.Sprintf("%v", rs.Rows)))
+		}
 	}
 }
 
@@ -0,0 +1,205 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"strconv"
+	"strings"
+
+	"planner"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// semiHashTable is the right rows of the semi join or anti join, which are hashed by the join keys.
+type semiHashTable struct {
+	node    *planner.JoinNode
+	buckets map[string][][]sqltypes.Value
+	maxrow  int
+	spill   *spillConf
+	rows    int
+	size    int
+}
+
+func newSemiHashTable(node *planner.JoinNode, maxrow int, spill *spillConf) *semiHashTable {
+	return &semiHashTable{
+		node:    node,
+		buckets: make(map[string][][]sqltypes.Value),
+		maxrow:  maxrow,
+		spill:   spill,
+	}
+}
+
+// add used to add the right row, the row with NULL join keys never matches, so it's skipped.
+func (h *semiHashTable) add(rrow []sqltypes.Value) error {
+	key, ok := hashKey(rrow, h.node.RightKeys)
+	if !ok {
+		return nil
+	}
+	h.buckets[key] = append(h.buckets[key], rrow)
+	h.rows++
+	if h.spill == nil {
+		if h.rows > h.maxrow {
+			return errors.Errorf("unsupported: join.row.count.exceeded.allowed.limit.of.'%d'", h.maxrow)
+		}
+		return nil
+	}
+	h.size += rowSize(rrow)
+	if h.size > h.spill.limit {
+		return errors.Errorf("unsupported: semi.join.hash.table.exceeded.the.memory.limit.of.'%d'", h.spill.limit)
+	}
+	return nil
+}
+
+// probe returns true if the left row should be returned.
+func (h *semiHashTable) probe(lrow []sqltypes.Value) bool {
+	node := h.node
+	matched := false
+	if leftBlend(lrow, node) {
+		if key, ok := hashKey(lrow, node.LeftKeys); ok {
+			var rrows [][]sqltypes.Value
+			for _, rrow := range h.buckets[key] {
+				if joinKeysEqual(lrow, rrow, node) {
+					rrows = append(rrows, rrow)
+				}
+			}
+			matched = semiMatch(lrow, rrows, node)
+		}
+	}
+	return matched != node.IsAntiJoin
+}
+
+// semiHashJoin used to return the left rows by probing the hash table of the right rows.
+func semiHashJoin(lres, rres *sqltypes.Result, res *joinResult, node *planner.JoinNode) error {
+	table := newSemiHashTable(node, res.maxrow, res.spill)
+	for _, rrow := range rres.Rows {
+		if err := table.add(rrow); err != nil {
+			return err
+		}
+	}
+	for _, lrow := range lres.Rows {
+		if table.probe(lrow) {
+			if err := res.append(joinRows(lrow, nil, node.Cols)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// iterateHash used to build the hash table by the right rows, then probe it by the left
+// rows one by one.
+func (j *JoinEngine) iterateHash() (rowIterator, error) {
+	right, err := j.right.iterate()
+	if err != nil {
+		return nil, err
+	}
+	defer right.close()
+	table := newSemiHashTable(j.node, j.txn.MaxJoinRows(), newSpillConf(j.txn))
+	for {
+		rrow, err := right.next()
+		if err != nil {
+			return nil, err
+		}
+		if rrow == nil {
+			break
+		}
+		if err = table.add(rrow); err != nil {
+			return nil, err
+		}
+	}
+
+	left, err := j.left.iterate()
+	if err != nil {
+		return nil, err
+	}
+	fill := func(res *joinResult) (bool, error) {
+		lrow, err := left.next()
+		if err != nil || lrow == nil {
+			return false, err
+		}
+		if table.probe(lrow) {
+			if err = res.append(joinRows(lrow, nil, j.node.Cols)); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}
+	it := newJoinIterator(fill, left)
+	it.flds = joinFields(left.fields(), right.fields(), j.node.Cols)
+	return it, nil
+}
+
+// semiMatch returns true if the left row has a match in the right rows of the semi join
+// or anti join. The 'NOT IN' is unknown if there's NULL on either side, which is treated
+// as matched, so the left row will not be returned by the anti join.
+func semiMatch(lrow []sqltypes.Value, rrows [][]sqltypes.Value, node *planner.JoinNode) bool {
+	for _, rrow := range rrows {
+		if !cmpFiltersMatch(lrow, rrow, node.CmpFilter) {
+			continue
+		}
+		match := true
+		for _, key := range node.NullAwareKeys {
+			lv, rv := lrow[key.Left], rrow[key.Right]
+			if lv.IsNull() || rv.IsNull() {
+				continue
+			}
+			if sqltypes.NullsafeCompare(lv, rv) != 0 {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// leftBlend returns false if the left row doesn't match the conditions only referring to the left.
+func leftBlend(lrow []sqltypes.Value, node *planner.JoinNode) bool {
+	for _, idx := range node.LeftTmpCols {
+		vn := lrow[idx].ToNative()
+		if vn == nil || vn.(int64) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// joinKeysEqual returns true if the join keys of the left and right rows are equal.
+func joinKeysEqual(lrow, rrow []sqltypes.Value, node *planner.JoinNode) bool {
+	for i, key := range node.LeftKeys {
+		if sqltypes.NullsafeCompare(lrow[key.Index], rrow[node.RightKeys[i].Index]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hashKey returns the hash key of the row by the join keys, false if there's NULL key.
+// The numbers are formatted to the float, so the equal values with the different types
+// are in the same bucket, the rows in the bucket must be compared again.
+func hashKey(row []sqltypes.Value, keys []planner.JoinKey) (string, bool) {
+	var buf strings.Builder
+	for _, key := range keys {
+		v := row[key.Index]
+		if v.IsNull() {
+			return "", false
+		}
+		if f, err := v.ParseFloat64(); err == nil {
+			buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+		} else {
+			buf.Write(v.Raw())
+		}
+		buf.WriteByte(0)
+	}
+	return buf.String(), true
+}
@@ -0,0 +1,444 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"bufio"
+	"container/heap"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"backend"
+
+	"github.com/pkg/errors"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	// errIterStop used to stop the iterating early, it will not be returned to the caller.
+	errIterStop = errors.New("iterate.stop")
+)
+
+// rowOverhead is the estimated memory used by a row except the values.
+const rowOverhead = 24
+
+// spillConf is the per-query memory budget, the rows beyond the budget
+// will be spilled to the dir.
+type spillConf struct {
+	dir   string
+	limit int
+}
+
+// newSpillConf creates the spillConf from the txn, returns nil if the spilling is disabled.
+func newSpillConf(txn backend.Transaction) *spillConf {
+	if txn == nil || txn.MaxQueryMemory() <= 0 {
+		return nil
+	}
+	return &spillConf{
+		dir:   txn.SpillDir(),
+		limit: txn.MaxQueryMemory(),
+	}
+}
+
+// rowSize returns the estimated memory size of the row.
+func rowSize(row []sqltypes.Value) int {
+	return sqltypes.Values(row).Len() + rowOverhead
+}
+
+// rowsSize returns the estimated memory size of the rows.
+func rowsSize(rows [][]sqltypes.Value) int {
+	size := 0
+	for _, row := range rows {
+		size += rowSize(row)
+	}
+	return size
+}
+
+// spillFile is a temporary file which holds the rows.
+// Row format: ncols(uvarint), then for each value: type(uvarint), len(varint, -1 is NULL), bytes.
+type spillFile struct {
+	file   *os.File
+	writer *bufio.Writer
+	rows   int
+}
+
+func newSpillFile(dir string) (*spillFile, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0744); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	file, err := ioutil.TempFile(dir, "radon_spill_")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &spillFile{
+		file:   file,
+		writer: bufio.NewWriterSize(file, 64*1024),
+	}, nil
+}
+
+// write used to write a row to the file.
+func (f *spillFile) write(row []sqltypes.Value) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(len(row)))
+	if _, err := f.writer.Write(buf[:n]); err != nil {
+		return errors.WithStack(err)
+	}
+	for _, v := range row {
+		n = binary.PutUvarint(buf[:], uint64(v.Type()))
+		if _, err := f.writer.Write(buf[:n]); err != nil {
+			return errors.WithStack(err)
+		}
+		if v.IsNull() {
+			n = binary.PutVarint(buf[:], -1)
+			if _, err := f.writer.Write(buf[:n]); err != nil {
+				return errors.WithStack(err)
+			}
+			continue
+		}
+		raw := v.Raw()
+		n = binary.PutVarint(buf[:], int64(len(raw)))
+		if _, err := f.writer.Write(buf[:n]); err != nil {
+			return errors.WithStack(err)
+		}
+		if _, err := f.writer.Write(raw); err != nil {
+			return errors.WithStack(err)
+		}
+	}
+	f.rows++
+	return nil
+}
+
+// reader flushes the buffered rows and returns a reader from the beginning of the file.
+func (f *spillFile) reader() (*spillReader, error) {
+	if err := f.writer.Flush(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &spillReader{reader: bufio.NewReaderSize(f.file, 64*1024), left: f.rows}, nil
+}
+
+// close used to close and remove the file.
+func (f *spillFile) close() {
+	name := f.file.Name()
+	f.file.Close()
+	os.Remove(name)
+}
+
+// spillReader used to read the rows from the spillFile.
+type spillReader struct {
+	reader *bufio.Reader
+	left   int
+}
+
+// next returns the next row, nil if there's no more rows.
+func (r *spillReader) next() ([]sqltypes.Value, error) {
+	if r.left == 0 {
+		return nil, nil
+	}
+	ncols, err := binary.ReadUvarint(r.reader)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	row := make([]sqltypes.Value, ncols)
+	for i := range row {
+		typ, err := binary.ReadUvarint(r.reader)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		size, err := binary.ReadVarint(r.reader)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if size < 0 {
+			row[i] = sqltypes.NULL
+			continue
+		}
+		raw := make([]byte, size)
+		if _, err := io.ReadFull(r.reader, raw); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		row[i] = sqltypes.MakeTrusted(querypb.Type(typ), raw)
+	}
+	r.left--
+	return row, nil
+}
+
+// rowLess reports whether the row a should sort before the row b.
+type rowLess func(a, b []sqltypes.Value) bool
+
+// spillSorter holds the rows in memory until the budget is exceeded, then the rows
+// will be sorted(if less is not nil) and written to disk as a run.
+// When iterating, the sorted runs are merged by the k-way merge, the unsorted runs are
+// concatenated.
+type spillSorter struct {
+	conf *spillConf
+	less rowLess
+	size int
+	rows [][]sqltypes.Value
+	runs []*spillFile
+}
+
+// count returns the count of the rows in memory and spilled.
+func (s *spillSorter) count() int {
+	n := len(s.rows)
+	for _, run := range s.runs {
+		n += run.rows
+	}
+	return n
+}
+
+// newSpillSorter creates the spillSorter, if less is nil the rows are kept in the insertion order.
+// If the conf is nil, all the rows are held in memory.
+func newSpillSorter(conf *spillConf, less rowLess) *spillSorter {
+	return &spillSorter{
+		conf: conf,
+		less: less,
+	}
+}
+
+// add used to add a row to the sorter.
+func (s *spillSorter) add(row []sqltypes.Value) error {
+	s.rows = append(s.rows, row)
+	s.size += rowSize(row)
+	if s.conf != nil && s.size > s.conf.limit {
+		return s.spill()
+	}
+	return nil
+}
+
+// spill used to write the in-memory rows to disk as a run.
+func (s *spillSorter) spill() error {
+	if len(s.rows) == 0 {
+		return nil
+	}
+	if s.less != nil {
+		sort.SliceStable(s.rows, func(i, j int) bool {
+			return s.less(s.rows[i], s.rows[j])
+		})
+	}
+
+	run, err := newSpillFile(s.conf.dir)
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, run)
+	for _, row := range s.rows {
+		if err := run.write(row); err != nil {
+			return err
+		}
+	}
+	s.rows = nil
+	s.size = 0
+	return nil
+}
+
+// spilled returns true if some rows have been written to disk.
+func (s *spillSorter) spilled() bool {
+	return len(s.runs) > 0
+}
+
+// iterate calls the fn for each row, in sorted order if less is not nil.
+// If the fn returns errIterStop, the iterating stops without error.
+func (s *spillSorter) iterate(fn func(row []sqltypes.Value) error) error {
+	next, err := s.reader()
+	if err != nil {
+		return err
+	}
+	for {
+		row, err := next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return nil
+		}
+		if err := fn(row); err != nil {
+			if err == errIterStop {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// reader returns the function which pulls the rows one by one, in sorted order if less is not nil.
+// It returns nil row if there's no more rows. The sorter can be read more than once.
+func (s *spillSorter) reader() (func() ([]sqltypes.Value, error), error) {
+	idx := 0
+	rows := s.rows
+	nextMem := func() ([]sqltypes.Value, error) {
+		if idx < len(rows) {
+			idx++
+			return rows[idx-1], nil
+		}
+		return nil, nil
+	}
+
+	if s.less == nil {
+		// Concatenate the runs and the in-memory rows.
+		runs := s.runs
+		var current *spillReader
+		var next func() ([]sqltypes.Value, error)
+		next = func() ([]sqltypes.Value, error) {
+			if current == nil {
+				if len(runs) == 0 {
+					return nextMem()
+				}
+				reader, err := runs[0].reader()
+				if err != nil {
+					return nil, err
+				}
+				current = reader
+				runs = runs[1:]
+			}
+			row, err := current.next()
+			if err != nil {
+				return nil, err
+			}
+			if row == nil {
+				current = nil
+				return next()
+			}
+			return row, nil
+		}
+		return next, nil
+	}
+
+	// K-way merge the sorted runs and the in-memory rows.
+	sort.SliceStable(s.rows, func(i, j int) bool {
+		return s.less(s.rows[i], s.rows[j])
+	})
+	var items []*mergeItem
+	for i, run := range s.runs {
+		reader, err := run.reader()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, &mergeItem{next: reader.next, order: i})
+	}
+	items = append(items, &mergeItem{next: nextMem, order: len(s.runs)})
+	h, err := newMergeHeap(s.less, items)
+	if err != nil {
+		return nil, err
+	}
+	return h.next, nil
+}
+
+// close used to remove all the runs.
+func (s *spillSorter) close() {
+	for _, run := range s.runs {
+		run.close()
+	}
+	s.runs = nil
+	s.rows = nil
+}
+
+// mergeItem is the current row of one sorted input in the k-way merge.
+type mergeItem struct {
+	row   []sqltypes.Value
+	next  func() ([]sqltypes.Value, error)
+	order int
+}
+
+// mergeHeap is a min-heap of the inputs' current rows.
+type mergeHeap struct {
+	items []*mergeItem
+	less  rowLess
+}
+
+// newMergeHeap creates the mergeHeap, the first row of each input is read here.
+func newMergeHeap(less rowLess, inputs []*mergeItem) (*mergeHeap, error) {
+	h := &mergeHeap{less: less}
+	for _, input := range inputs {
+		row, err := input.next()
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			input.row = row
+			h.items = append(h.items, input)
+		}
+	}
+	heap.Init(h)
+	return h, nil
+}
+
+// Len impl.
+func (h *mergeHeap) Len() int { return len(h.items) }
+
+// Less impl, keep the inputs' order if the rows are equal to make the merge stable.
+func (h *mergeHeap) Less(i, j int) bool {
+	if h.less(h.items[i].row, h.items[j].row) {
+		return true
+	}
+	if h.less(h.items[j].row, h.items[i].row) {
+		return false
+	}
+	return h.items[i].order < h.items[j].order
+}
+
+// Swap impl.
+func (h *mergeHeap) Swap(i, j int) { h.items[i], h.items[j] = h.items[j], h.items[i] }
+
+// Push impl.
+func (h *mergeHeap) Push(x interface{}) { h.items = append(h.items, x.(*mergeItem)) }
+
+// Pop impl.
+func (h *mergeHeap) Pop() interface{} {
+	n := len(h.items)
+	item := h.items[n-1]
+	h.items = h.items[:n-1]
+	return item
+}
+
+// next returns the smallest row of the inputs, nil if all the inputs are exhausted.
+func (h *mergeHeap) next() ([]sqltypes.Value, error) {
+	if len(h.items) == 0 {
+		return nil, nil
+	}
+	item := h.items[0]
+	row := item.row
+	next, err := item.next()
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		heap.Pop(h)
+	} else {
+		item.row = next
+		heap.Fix(h, 0)
+	}
+	return row, nil
+}
+
+// fetchRows used to fetch at most bound rows(negative means all) from the sorter to the result.
+func fetchRows(result *sqltypes.Result, src *spillSorter, bound int) error {
+	result.Rows = result.Rows[:0]
+	if bound == 0 {
+		result.RowsAffected = 0
+		return nil
+	}
+	err := src.iterate(func(row []sqltypes.Value) error {
+		result.Rows = append(result.Rows, row)
+		if bound > 0 && len(result.Rows) >= bound {
+			return errIterStop
+		}
+		return nil
+	})
+	result.RowsAffected = uint64(len(result.Rows))
+	return err
+}
@@ -0,0 +1,346 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"testing"
+
+	"backend"
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestSpillSorter(t *testing.T) {
+	dir := "/tmp/radon_spill_test"
+	defer os.RemoveAll(dir)
+
+	conf := &spillConf{dir: dir, limit: 256}
+	less := func(a, b []sqltypes.Value) bool {
+		return sqltypes.NullsafeCompare(a[0], b[0]) < 0
+	}
+
+	// Sorted.
+	{
+		sorter := newSpillSorter(conf, less)
+		for i := 100; i > 0; i-- {
+			row := []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(strconv.Itoa(i%50))),
+				sqltypes.NULL,
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("name%d", i))),
+			}
+			assert.Nil(t, sorter.add(row))
+		}
+		assert.True(t, sorter.spilled())
+
+		var got [][]sqltypes.Value
+		err := sorter.iterate(func(row []sqltypes.Value) error {
+			got = append(got, row)
+			return nil
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, 100, len(got))
+		for i := 1; i < len(got); i++ {
+			assert.False(t, less(got[i], got[i-1]))
+		}
+		assert.True(t, got[0][1].IsNull())
+		assert.Equal(t, querypb.Type_VARCHAR, got[0][2].Type())
+		sorter.close()
+	}
+
+	// Unsorted, stop early.
+	{
+		sorter := newSpillSorter(conf, nil)
+		for i := 0; i < 100; i++ {
+			row := []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_INT32, []byte(strconv.Itoa(i)))}
+			assert.Nil(t, sorter.add(row))
+		}
+		assert.True(t, sorter.spilled())
+
+		rs := &sqltypes.Result{}
+		err := fetchRows(rs, sorter, 10)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[0] [1] [2] [3] [4] [5] [6] [7] [8] [9]]", fmt.Sprintf("%v", rs.Rows))
+		assert.Equal(t, uint64(10), rs.RowsAffected)
+		sorter.close()
+	}
+}
+
+func TestJoinEngineSpill(t *testing.T) {
+	newResult := func(table string) *sqltypes.Result {
+		rs := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{
+					Name:  "id",
+					Type:  querypb.Type_INT32,
+					Table: table,
+				},
+				{
+					Name:  "name",
+					Type:  querypb.Type_VARCHAR,
+					Table: table,
+				},
+			},
+		}
+		for i := 0; i < 10; i++ {
+			rs.Rows = append(rs.Rows, []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte(strconv.Itoa(i))),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("name%d", i%3))),
+			})
+		}
+		return rs
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	dir := "/tmp/radon_spill_test"
+	defer os.RemoveAll(dir)
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableBConfig(), router.MockTableSConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select s.id, s.name from .*", newResult("S"))
+	fakedbs.AddQueryPattern("select s.id as `count\\(s.id\\)`, s.name from .*", newResult("S"))
+	fakedbs.AddQueryPattern("select b.id, b.name from .*", newResult("B"))
+	names := newResult("B")
+	names.Fields = names.Fields[1:]
+	for i := range names.Rows {
+		names.Rows[i] = names.Rows[i][1:]
+	}
+	fakedbs.AddQueryPattern("select b.name from .*", names)
+
+	querys := []string{
+		"select S.id, S.name, B.id, B.name from S join B on S.name = B.name order by S.id desc, B.id desc limit 3",
+		"select S.id, S.name, B.id, B.name from S, B limit 2",
+		"select count(S.id), S.name from S join B on S.name = B.name group by S.name",
+	}
+	results := []string{
+		"[[9 name0 9 name0] [9 name0 9 name0] [9 name0 6 name0]]",
+		"[[0 name0 0 name0] [0 name0 1 name1]]",
+		"[[32 name0] [18 name1] [18 name2]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		// The results must be the same as the in-memory executing.
+		for _, spill := range []bool{false, true} {
+			txn, err := scatter.CreateTransaction()
+			assert.Nil(t, err)
+			defer txn.Finish()
+			txn.SetMaxJoinRows(32768)
+			if spill {
+				txn.SetMaxJoinRows(1)
+				txn.SetMaxQueryMemory(64)
+				txn.SetSpillDir(dir)
+			}
+			executor := NewSelectExecutor(log, plan, txn)
+			{
+				ctx := xcontext.NewResultContext()
+				err := executor.Execute(ctx)
+				assert.Nil(t, err)
+				got := fmt.Sprintf("%v", ctx.Results.Rows)
+				assert.Equal(t, results[i], got)
+			}
+			{
+				rs := streamFetchRows(t, executor)
+				got := fmt.Sprintf("%v", rs.Rows)
+				assert.Equal(t, results[i], got)
+			}
+		}
+	}
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestMergeEngineSpill(t *testing.T) {
+	rs := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		rs.Rows = append(rs.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(strconv.Itoa(i))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("name%d", i%3))),
+		})
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	dir := "/tmp/radon_merge_spill_test"
+	defer os.RemoveAll(dir)
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select .* from .*", rs)
+
+	querys := []string{
+		"select count(id), name from B group by name",
+		"select max(id), name from B group by name order by name desc",
+	}
+	results := []string{
+		"[[36 name0] [24 name1] [30 name2]]",
+		"[[8 name2] [7 name1] [9 name0]]",
+	}
+
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		// The whole result exceeds the max result size, it only works with the spilling.
+		for _, twopc := range []bool{false, true} {
+			for _, spill := range []bool{false, true} {
+				txn, err := scatter.CreateTransaction()
+				assert.Nil(t, err)
+				defer txn.Finish()
+				if twopc {
+					err = txn.Begin()
+					assert.Nil(t, err)
+				}
+				txn.SetMaxResult(32)
+				if spill {
+					txn.SetMaxQueryMemory(64)
+					txn.SetSpillDir(dir)
+				}
+				executor := NewSelectExecutor(log, plan, txn)
+				ctx := xcontext.NewResultContext()
+				err = executor.Execute(ctx)
+				if !spill {
+					assert.NotNil(t, err)
+					continue
+				}
+				assert.Nil(t, err)
+				got := fmt.Sprintf("%v", ctx.Results.Rows)
+				assert.Equal(t, results[i], got)
+			}
+		}
+	}
+	// The spilling doesn't lift the max result size of the output rows.
+	{
+		query := "select id, name from B order by id"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+
+		txn, err := scatter.CreateTransaction()
+		assert.Nil(t, err)
+		defer txn.Finish()
+		txn.SetMaxResult(32)
+		txn.SetMaxQueryMemory(64)
+		txn.SetSpillDir(dir)
+		executor := NewSelectExecutor(log, plan, txn)
+		ctx := xcontext.NewResultContext()
+		err = executor.Execute(ctx)
+		want := "Query execution was interrupted, max memory usage[32 bytes] exceeded"
+		assert.Equal(t, want, err.Error())
+	}
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
+
+func TestAggregateExecutorSpill(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	dir := "/tmp/radon_aggregate_spill_test"
+	defer os.RemoveAll(dir)
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	query := "select count(id), name from B group by name"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	aggrPlan := plan.Root.Children().Plans()[0]
+	assert.Equal(t, planner.PlanTypeAggregate, aggrPlan.Type())
+
+	rs := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "count(id)",
+				Type: querypb.Type_INT64,
+			},
+			{
+				Name: "name",
+				Type: querypb.Type_VARCHAR,
+			},
+		},
+	}
+	for i := 0; i < 100; i++ {
+		rs.Rows = append(rs.Rows, []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(fmt.Sprintf("name%d", i%3))),
+		})
+	}
+	rows := rs.Rows
+
+	// The rows are fed to the sorter one by one and released.
+	executor := NewAggregateExecutor(log, aggrPlan)
+	executor.spill = &spillConf{dir: dir, limit: 256}
+	ctx := xcontext.NewResultContext()
+	ctx.Results = rs
+	err = executor.Execute(ctx)
+	assert.Nil(t, err)
+	assert.Equal(t, "[[34 name0] [33 name1] [33 name2]]", fmt.Sprintf("%v", ctx.Results.Rows))
+	for _, row := range rows {
+		assert.Nil(t, row)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(files))
+}
@@ -0,0 +1,100 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// streamSender used to send the rows to the client callback in batches of the streamBufferSize.
+type streamSender struct {
+	callback   func(*sqltypes.Result) error
+	bufferSize int
+	fields     []*querypb.Field
+	qr         *sqltypes.Result
+	bytes      int
+	rows       uint64
+}
+
+func newStreamSender(callback func(*sqltypes.Result) error, bufferSize int) *streamSender {
+	return &streamSender{
+		callback:   callback,
+		bufferSize: bufferSize,
+	}
+}
+
+// sendFields used to send the fields, it must be called before sending rows.
+func (s *streamSender) sendFields(fields []*querypb.Field) error {
+	s.fields = fields
+	s.qr = &sqltypes.Result{Fields: fields, Rows: make([][]sqltypes.Value, 0, 256), State: sqltypes.RStateRows}
+	return s.callback(&sqltypes.Result{Fields: fields, State: sqltypes.RStateFields})
+}
+
+// send used to buffer the row, the buffer will be sent if it's full.
+func (s *streamSender) send(row []sqltypes.Value) error {
+	s.qr.Rows = append(s.qr.Rows, row)
+	s.bytes += sqltypes.Values(row).Len()
+	s.rows++
+	if s.bytes >= s.bufferSize {
+		return s.flush()
+	}
+	return nil
+}
+
+// flush used to send the buffered rows.
+func (s *streamSender) flush() error {
+	if len(s.qr.Rows) == 0 {
+		return nil
+	}
+	if err := s.callback(s.qr); err != nil {
+		return err
+	}
+	s.qr.Rows = s.qr.Rows[:0]
+	s.bytes = 0
+	return nil
+}
+
+// finish used to send the buffered rows and the finished state.
+func (s *streamSender) finish() error {
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return s.callback(&sqltypes.Result{Fields: s.fields, RowsAffected: s.rows, State: sqltypes.RStateFinished})
+}
+
+// streamFetch used to iterate the rows of the engine and send them to the callback in batches,
+// the last hiddenCols columns are removed.
+func streamFetch(engine PlanEngine, hiddenCols int, callback func(*sqltypes.Result) error, streamBufferSize int) error {
+	it, err := engine.iterate()
+	if err != nil {
+		return err
+	}
+	defer it.close()
+	if hiddenCols > 0 {
+		it = &hiddenColsIterator{rowIterator: it, hiddenCols: hiddenCols}
+	}
+
+	sender := newStreamSender(callback, streamBufferSize)
+	if err := sender.sendFields(it.fields()); err != nil {
+		return err
+	}
+	for {
+		row, err := it.next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			return sender.finish()
+		}
+		if err := sender.send(row); err != nil {
+			return err
+		}
+	}
+}
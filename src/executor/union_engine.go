@@ -9,6 +9,7 @@
 package executor
 
 import (
+	"bytes"
 	"errors"
 	"sync"
 
@@ -56,6 +57,12 @@ func (u *UnionEngine) execute(ctx *xcontext.ResultContext) error {
 			mu.Unlock()
 		}
 	}
+
+	// If the spilling is enabled, the rows of both sides are read by the cursors and the
+	// sorts spill the sorted runs while the rows arrive.
+	if spillable(u.node, u.txn) {
+		return executeIterate(u.iterate, u.node, ctx, u.txn)
+	}
 	lctx := xcontext.NewResultContext()
 	rctx := xcontext.NewResultContext()
 	wg.Add(1)
@@ -95,7 +102,7 @@ func (u *UnionEngine) execute(ctx *xcontext.ResultContext) error {
 		ctx.Results.Rows = lctx.Results.Rows
 		ctx.Results.RowsAffected = lctx.Results.RowsAffected
 	}
-	return execSubPlan(u.log, u.node, ctx)
+	return execSubPlan(u.log, u.node, ctx, u.txn)
 }
 
 // execBindVars used to execute querys with bindvas.
@@ -107,3 +114,91 @@ func (u *UnionEngine) execBindVars(ctx *xcontext.ResultContext, bindVars map[str
 func (u *UnionEngine) getFields(ctx *xcontext.ResultContext, bindVars map[string]*querypb.BindVariable) error {
 	return errors.New("UnionEngine.getFields: unreachable")
 }
+
+// iterate used to iterate the rows of the left and right, for UNION DISTINCT the rows are
+// sorted to remove the duplicates.
+func (u *UnionEngine) iterate() (rowIterator, error) {
+	left, err := u.left.iterate()
+	if err != nil {
+		return nil, err
+	}
+	right, err := u.right.iterate()
+	if err != nil {
+		left.close()
+		return nil, err
+	}
+	if len(left.fields()) != len(right.fields()) {
+		left.close()
+		right.close()
+		return nil, errors.New("unsupported: the.used.'select'.statements.have.a.different.number.of.columns")
+	}
+
+	spill := newSpillConf(u.txn)
+	var it rowIterator = &unionIterator{sources: []rowIterator{left, right}}
+	if u.node.Typ == "union distinct" || u.node.Typ == "union" {
+		if it, err = newSorterIterator(it, spill, rawLess); err != nil {
+			return nil, err
+		}
+		it = &distinctIterator{rowIterator: it}
+	}
+	return iterateSubPlan(u.log, u.node, it, u.txn)
+}
+
+// rawLess compares the rows by the raw bytes of the columns.
+func rawLess(a, b []sqltypes.Value) bool {
+	for i := range a {
+		if cmp := bytes.Compare(a[i].Raw(), b[i].Raw()); cmp != 0 {
+			return cmp < 0
+		}
+	}
+	return false
+}
+
+// unionIterator concatenates the rows of the sources.
+type unionIterator struct {
+	sources []rowIterator
+	idx     int
+}
+
+func (it *unionIterator) fields() []*querypb.Field {
+	return it.sources[0].fields()
+}
+
+func (it *unionIterator) next() ([]sqltypes.Value, error) {
+	for it.idx < len(it.sources) {
+		row, err := it.sources[it.idx].next()
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			return row, nil
+		}
+		it.idx++
+	}
+	return nil, nil
+}
+
+func (it *unionIterator) close() {
+	for _, src := range it.sources {
+		src.close()
+	}
+}
+
+// distinctIterator skips the duplicate rows of the sorted src.
+type distinctIterator struct {
+	rowIterator
+	last []sqltypes.Value
+}
+
+func (it *distinctIterator) next() ([]sqltypes.Value, error) {
+	for {
+		row, err := it.rowIterator.next()
+		if err != nil || row == nil {
+			return nil, err
+		}
+		if it.last == nil || rawLess(it.last, row) {
+			it.last = row
+			return row, nil
+		}
+	}
+}
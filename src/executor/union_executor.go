@@ -13,6 +13,7 @@ import (
 	"planner"
 	"xcontext"
 
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -46,3 +47,9 @@ func (executor *UnionExecutor) Execute(ctx *xcontext.ResultContext) error {
 	}
 	return nil
 }
+
+// ExecuteStreamFetch used to execute the executor and send the rows to the callback in batches.
+func (executor *UnionExecutor) ExecuteStreamFetch(callback func(*sqltypes.Result) error, streamBufferSize int) error {
+	plan := executor.plan.(*planner.UnionPlan)
+	return streamFetch(buildEngine(executor.log, plan.Root, executor.txn), 0, callback, streamBufferSize)
+}
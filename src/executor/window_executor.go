@@ -0,0 +1,282 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"expression"
+	"planner"
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Executor = &WindowExecutor{}
+)
+
+// WindowExecutor represents window executor, the window functions are computed on the merged rows.
+type WindowExecutor struct {
+	log  *xlog.Log
+	plan planner.Plan
+	// spill is the memory budget, nil if spilling is disabled.
+	spill *spillConf
+}
+
+// NewWindowExecutor creates the new window executor.
+func NewWindowExecutor(log *xlog.Log, plan planner.Plan) *WindowExecutor {
+	return &WindowExecutor{
+		log:  log,
+		plan: plan,
+	}
+}
+
+// Execute used to execute the executor.
+func (executor *WindowExecutor) Execute(ctx *xcontext.ResultContext) error {
+	rs := ctx.Results
+	it, err := newWindowIterator(executor, newRowsIterator(rs), executor.spill)
+	if err != nil {
+		return err
+	}
+	res, err := fetchIterator(it, 0)
+	if err != nil {
+		return err
+	}
+	rs.Fields = res.Fields
+	rs.Rows = res.Rows
+	return nil
+}
+
+// newWindowIterator used to compute the windows on the src, the windows with the same
+// partition by and order by are computed together.
+func newWindowIterator(executor *WindowExecutor, src rowIterator, spill *spillConf) (rowIterator, error) {
+	plan := executor.plan.(*planner.WindowPlan)
+	var specs []string
+	groups := make(map[string][]planner.Window)
+	for _, w := range plan.Windows {
+		spec := sqlparser.String(w.Func.Over)
+		if _, ok := groups[spec]; !ok {
+			specs = append(specs, spec)
+		}
+		groups[spec] = append(groups[spec], w)
+	}
+
+	var err error
+	it := src
+	for _, spec := range specs {
+		if it, err = newPartitionIterator(groups[spec], it, spill); err != nil {
+			return nil, err
+		}
+	}
+	return it, nil
+}
+
+// partitionIterator computes the windows of the same OVER clause. The partition by and order by
+// keys are appended to the rows of the src, the rows are sorted by the keys, then the windows are
+// computed partition by partition, so only the rows of one partition are held in memory.
+type partitionIterator struct {
+	src     rowIterator
+	flds    []*querypb.Field
+	windows []planner.Window
+	funcs   []*expression.WindowFunc
+	// width is the number of the columns without the keys.
+	width int
+	// parts is the number of the partition by keys.
+	parts int
+	// rows are the computed rows of the current partition.
+	rows [][]sqltypes.Value
+	pos  int
+	// pending is the first row of the next partition.
+	pending []sqltypes.Value
+}
+
+func newPartitionIterator(windows []planner.Window, src rowIterator, spill *spillConf) (*partitionIterator, error) {
+	fields := src.fields()
+	over := windows[0].Func.Over
+	var keys []expression.Evaluator
+	var orders []planner.Direction
+	for _, expr := range over.PartitionBy {
+		eval, err := expression.NewEvaluator(expr, fields)
+		if err != nil {
+			src.close()
+			return nil, err
+		}
+		keys = append(keys, eval)
+		orders = append(orders, planner.ASC)
+	}
+	for _, order := range over.OrderBy {
+		eval, err := expression.NewEvaluator(order.Expr, fields)
+		if err != nil {
+			src.close()
+			return nil, err
+		}
+		keys = append(keys, eval)
+		if order.Direction == sqlparser.DescScr {
+			orders = append(orders, planner.DESC)
+		} else {
+			orders = append(orders, planner.ASC)
+		}
+	}
+
+	fixed := append([]*querypb.Field{}, fields...)
+	funcs := make([]*expression.WindowFunc, len(windows))
+	for i, w := range windows {
+		fn, err := expression.NewWindowFunc(w.Func, fields)
+		if err != nil {
+			src.close()
+			return nil, err
+		}
+		field := *fields[w.Index]
+		fn.FixField(&field)
+		fixed[w.Index] = &field
+		funcs[i] = fn
+	}
+
+	it := &partitionIterator{
+		flds:    fixed,
+		windows: windows,
+		funcs:   funcs,
+		width:   len(fields),
+		parts:   len(over.PartitionBy),
+	}
+	if len(keys) == 0 {
+		it.src = src
+		return it, nil
+	}
+
+	width := it.width
+	less := func(a, b []sqltypes.Value) bool {
+		for i, order := range orders {
+			cmp := sqltypes.NullsafeCompare(a[width+i], b[width+i])
+			if cmp == 0 {
+				continue
+			}
+			if order == planner.DESC {
+				cmp = -cmp
+			}
+			return cmp < 0
+		}
+		return false
+	}
+	sorted, err := newSorterIterator(&keysIterator{rowIterator: src, keys: keys}, spill, less)
+	if err != nil {
+		return nil, err
+	}
+	it.src = sorted
+	return it, nil
+}
+
+func (it *partitionIterator) fields() []*querypb.Field {
+	return it.flds
+}
+
+func (it *partitionIterator) next() ([]sqltypes.Value, error) {
+	if it.pos >= len(it.rows) {
+		if err := it.nextPartition(); err != nil {
+			return nil, err
+		}
+		if len(it.rows) == 0 {
+			return nil, nil
+		}
+	}
+	it.pos++
+	return it.rows[it.pos-1], nil
+}
+
+func (it *partitionIterator) close() {
+	it.src.close()
+}
+
+// nextPartition used to read the rows of the next partition and compute the windows.
+func (it *partitionIterator) nextPartition() error {
+	it.rows, it.pos = it.rows[:0], 0
+	if it.pending != nil {
+		it.rows = append(it.rows, it.pending)
+		it.pending = nil
+	}
+	for {
+		row, err := it.src.next()
+		if err != nil {
+			return err
+		}
+		if row == nil {
+			break
+		}
+		if len(it.rows) > 0 && !it.equal(it.rows[0], row, it.width, it.width+it.parts) {
+			it.pending = row
+			break
+		}
+		it.rows = append(it.rows, row)
+	}
+
+	n := len(it.rows)
+	if n == 0 {
+		return nil
+	}
+	// The rows are peers if they are equal by the order by keys.
+	peers := make([]int, n)
+	peers[n-1] = n
+	for i := n - 2; i >= 0; i-- {
+		if it.equal(it.rows[i], it.rows[i+1], it.width+it.parts, len(it.rows[i])) {
+			peers[i] = peers[i+1]
+		} else {
+			peers[i] = i + 1
+		}
+	}
+
+	for k, fn := range it.funcs {
+		res, err := fn.Compute(it.rows, peers)
+		if err != nil {
+			return err
+		}
+		index := it.windows[k].Index
+		for i, row := range it.rows {
+			row[index] = res[i]
+		}
+	}
+	for i, row := range it.rows {
+		it.rows[i] = row[:it.width]
+	}
+	return nil
+}
+
+// equal used to check whether the columns of the rows in [start, end) are equal.
+func (it *partitionIterator) equal(a, b []sqltypes.Value, start, end int) bool {
+	for i := start; i < end; i++ {
+		if sqltypes.NullsafeCompare(a[i], b[i]) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// keysIterator appends the values of the keys to the rows of the src.
+type keysIterator struct {
+	rowIterator
+	keys []expression.Evaluator
+}
+
+func (it *keysIterator) next() ([]sqltypes.Value, error) {
+	row, err := it.rowIterator.next()
+	if err != nil || row == nil {
+		return nil, err
+	}
+	res := make([]sqltypes.Value, len(row), len(row)+len(it.keys))
+	copy(res, row)
+	for _, key := range it.keys {
+		v, err := key.Eval(row)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, v)
+	}
+	return res, nil
+}
@@ -0,0 +1,187 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package executor
+
+import (
+	"fmt"
+	"testing"
+
+	"backend"
+	"planner"
+	"router"
+	"xcontext"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestWindowExecutor(t *testing.T) {
+	fields := []*querypb.Field{
+		{
+			Name: "a",
+			Type: querypb.Type_INT32,
+		},
+		{
+			Name: "b",
+			Type: querypb.Type_INT32,
+		},
+		{
+			Name: "r",
+			Type: querypb.Type_NULL_TYPE,
+		},
+		{
+			Name: "s",
+			Type: querypb.Type_NULL_TYPE,
+		},
+		{
+			Name: "rn",
+			Type: querypb.Type_NULL_TYPE,
+		},
+	}
+	makeRow := func(a, b string) []sqltypes.Value {
+		return []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(a)),
+			sqltypes.MakeTrusted(querypb.Type_INT32, []byte(b)),
+			sqltypes.NULL,
+			sqltypes.NULL,
+			sqltypes.NULL,
+		}
+	}
+	r1 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			makeRow("1", "10"),
+			makeRow("2", "5"),
+			makeRow("1", "30"),
+		},
+	}
+	r2 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			makeRow("1", "20"),
+			makeRow("2", "7"),
+		},
+	}
+	r3 := &sqltypes.Result{Fields: fields}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	// Add querys, the limit is not pushed down.
+	fakedbs.AddQuery("select a, b, null as r, null as s, null as rn from sbtest.A0 as A where id > 8 order by a asc, r asc", r1)
+	fakedbs.AddQuery("select a, b, null as r, null as s, null as rn from sbtest.A2 as A where id > 8 order by a asc, r asc", r2)
+	fakedbs.AddQuery("select a, b, null as r, null as s, null as rn from sbtest.A4 as A where id > 8 order by a asc, r asc", r3)
+	fakedbs.AddQuery("select a, b, null as r, null as s, null as rn from sbtest.A8 as A where id > 8 order by a asc, r asc", r3)
+
+	query := "select a, b, rank() over (partition by a order by b desc) as r, sum(b) over (partition by a) as s, row_number() over (order by b) as rn from A where id>8 order by a, r limit 4"
+	want := "[[1 30 1 60 5] [1 20 2 60 4] [1 10 3 60 3] [2 7 1 12 2]]"
+
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+	log.Debug("plan:%+v", plan.JSON())
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+	{
+		ctx := xcontext.NewResultContext()
+		err := executor.Execute(ctx)
+		assert.Nil(t, err)
+		got := fmt.Sprintf("%v", ctx.Results.Rows)
+		assert.Equal(t, want, got)
+		assert.Equal(t, querypb.Type_INT64, ctx.Results.Fields[2].Type)
+		assert.Equal(t, querypb.Type_DECIMAL, ctx.Results.Fields[3].Type)
+		assert.Equal(t, querypb.Type_INT64, ctx.Results.Fields[4].Type)
+	}
+	// Stream fetch.
+	{
+		rs := streamFetchRows(t, executor)
+		got := fmt.Sprintf("%v", rs.Rows)
+		assert.Equal(t, want, got)
+		assert.Equal(t, querypb.Type_INT64, rs.Fields[2].Type)
+	}
+}
+
+func TestWindowExecutorErr(t *testing.T) {
+	r1 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "a",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "ntile(0) over (order by a asc)",
+				Type: querypb.Type_NULL_TYPE,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.NULL,
+			},
+		},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableAConfig())
+	assert.Nil(t, err)
+
+	// Create scatter and query handler.
+	scatter, fakedbs, cleanup := backend.MockScatter(log, 10)
+	defer cleanup()
+	fakedbs.AddQueryPattern("select a, null as `ntile\\(0\\) over \\(order by a asc\\)` from .*", r1)
+
+	query := "select a, ntile(0) over (order by a) from A"
+	node, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+
+	plan := planner.NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+	err = plan.Build()
+	assert.Nil(t, err)
+
+	txn, err := scatter.CreateTransaction()
+	assert.Nil(t, err)
+	defer txn.Finish()
+	executor := NewSelectExecutor(log, plan, txn)
+	{
+		ctx := xcontext.NewResultContext()
+		err = executor.Execute(ctx)
+		assert.NotNil(t, err)
+	}
+	// Stream fetch.
+	{
+		err = executor.ExecuteStreamFetch(func(qr *sqltypes.Result) error {
+			return nil
+		}, 8)
+		assert.NotNil(t, err)
+	}
+}
@@ -9,6 +9,9 @@
 package expression
 
 import (
+	"bytes"
+	"sort"
+
 	"planner"
 
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
@@ -25,6 +28,11 @@ type Aggregation struct {
 	isPushDown bool
 	// prec controls the number of digits.
 	prec int
+	// separator and orderBys are used by the group_concat.
+	separator string
+	orderBys  []planner.ConcatOrderBy
+	// maxLen is the max length of the group_concat result.
+	maxLen int
 }
 
 // AggEvaluateContext is used to store intermediate result when calculating aggregate functions.
@@ -34,21 +42,47 @@ type AggEvaluateContext struct {
 	hasErr bool
 	// buffer used to store the values when Aggregation.distinct is true.
 	buffer *common.HashTable
+	// concats used to store the values of the group_concat.
+	concats []concatValue
+	size    int
+}
+
+// concatValue is the value of the group_concat, with the order by keys.
+type concatValue struct {
+	val  sqltypes.Value
+	keys []sqltypes.Value
 }
 
 // NewAggregation new an Aggregetion.
-func newAggregation(p planner.Aggregator, isPushDown bool) *Aggregation {
+func newAggregation(p planner.Aggregator, isPushDown bool, groupConcatMaxLen int) *Aggregation {
+	if groupConcatMaxLen <= 0 {
+		groupConcatMaxLen = DefaultGroupConcatMaxLen
+	}
 	return &Aggregation{
 		distinct:   p.Distinct,
 		index:      p.Index,
 		aggrTyp:    p.Type,
 		isPushDown: isPushDown,
 		prec:       -1,
+		separator:  p.Separator,
+		orderBys:   p.OrderBys,
+		maxLen:     groupConcatMaxLen,
 	}
 }
 
 // InitEvalCtx used to init the AggEvaluateContext.
 func (aggr *Aggregation) InitEvalCtx(x []sqltypes.Value) *AggEvaluateContext {
+	if aggr.aggrTyp == planner.AggrTypeGroupConcat {
+		evalCtx := &AggEvaluateContext{
+			val:    sqltypes.NULL,
+			buffer: common.NewHashTable(),
+		}
+		if x != nil {
+			aggr.Update(x, evalCtx)
+		}
+		return evalCtx
+	}
+
 	var count int64
 	v := sqltypes.MakeTrusted(sqltypes.Null, nil)
 	if x != nil {
@@ -75,6 +109,14 @@ func (aggr *Aggregation) FixField(field *querypb.Field) {
 	if !aggr.isPushDown || aggr.aggrTyp == planner.AggrTypeAvg {
 		switch aggr.aggrTyp {
 		case planner.AggrTypeMax, planner.AggrTypeMin:
+		case planner.AggrTypeGroupConcat:
+			field.Decimals = 0
+			field.ColumnLength = uint32(aggr.maxLen)
+			if sqltypes.IsBinary(field.Type) {
+				field.Type = querypb.Type_VARBINARY
+			} else {
+				field.Type = querypb.Type_VARCHAR
+			}
 		case planner.AggrTypeCount:
 			field.Decimals = 0
 			field.ColumnLength = 21
@@ -176,12 +218,63 @@ func (aggr *Aggregation) Update(x []sqltypes.Value, evalCtx *AggEvaluateContext)
 			evalCtx.count++
 			evalCtx.val, err = sqltypes.NullsafeSum(evalCtx.val, v, aggr.fieldType, aggr.prec)
 		}
+	case planner.AggrTypeGroupConcat:
+		// The values beyond the max length are useless if no need to sort.
+		if len(aggr.orderBys) == 0 && evalCtx.size > aggr.maxLen {
+			return
+		}
+		value := concatValue{val: v}
+		for _, order := range aggr.orderBys {
+			value.keys = append(value.keys, x[order.Index])
+		}
+		evalCtx.concats = append(evalCtx.concats, value)
+		evalCtx.size += len(v.Raw()) + len(aggr.separator)
 	}
 	if err != nil {
 		evalCtx.hasErr = true
 	}
 }
 
+// groupConcat used to concat the values of the group_concat, the result is truncated to the max length.
+func (aggr *Aggregation) groupConcat(evalCtx *AggEvaluateContext) sqltypes.Value {
+	if len(evalCtx.concats) == 0 {
+		return sqltypes.NULL
+	}
+
+	concats := evalCtx.concats
+	if len(aggr.orderBys) > 0 {
+		sort.SliceStable(concats, func(i, j int) bool {
+			for k, order := range aggr.orderBys {
+				cmp := sqltypes.NullsafeCompare(concats[i].keys[k], concats[j].keys[k])
+				if cmp == 0 {
+					continue
+				}
+				if order.Direction == planner.DESC {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+	}
+
+	var buf bytes.Buffer
+	for i, concat := range concats {
+		if i > 0 {
+			buf.WriteString(aggr.separator)
+		}
+		buf.Write(concat.val.Raw())
+		if buf.Len() >= aggr.maxLen {
+			break
+		}
+	}
+	res := buf.Bytes()
+	if len(res) > aggr.maxLen {
+		res = res[:aggr.maxLen]
+	}
+	return sqltypes.MakeTrusted(aggr.fieldType, res)
+}
+
 // GetResult used to get Value finally.
 func (aggr *Aggregation) GetResult(evalCtx *AggEvaluateContext) sqltypes.Value {
 	var val sqltypes.Value
@@ -196,6 +289,8 @@ func (aggr *Aggregation) GetResult(evalCtx *AggEvaluateContext) sqltypes.Value {
 		}
 	case planner.AggrTypeMax, planner.AggrTypeMin:
 		val = evalCtx.val
+	case planner.AggrTypeGroupConcat:
+		val = aggr.groupConcat(evalCtx)
 	case planner.AggrTypeSum:
 		val, err = sqltypes.Cast(evalCtx.val, aggr.fieldType)
 	case planner.AggrTypeCount:
@@ -211,11 +306,12 @@ func (aggr *Aggregation) GetResult(evalCtx *AggEvaluateContext) sqltypes.Value {
 	return val
 }
 
-// NewAggregations new aggrs based on plans.
-func NewAggregations(plans []planner.Aggregator, isPushDown bool, fields []*querypb.Field) []*Aggregation {
+// NewAggregations new aggrs based on plans, groupConcatMaxLen is the max length of
+// the group_concat result, the default value is used if it's not positive.
+func NewAggregations(plans []planner.Aggregator, isPushDown bool, fields []*querypb.Field, groupConcatMaxLen int) []*Aggregation {
 	var aggrs []*Aggregation
 	for _, plan := range plans {
-		aggr := newAggregation(plan, isPushDown)
+		aggr := newAggregation(plan, isPushDown, groupConcatMaxLen)
 		aggr.FixField(fields[aggr.index])
 		aggrs = append(aggrs, aggr)
 	}
@@ -116,7 +116,7 @@ func TestNewAggregations(t *testing.T) {
 		{sqltypes.Decimal, sqltypes.Decimal},
 	}
 	for i, field := range fields {
-		aggrs := NewAggregations(plan1, false, field)
+		aggrs := NewAggregations(plan1, false, field, 0)
 		for j, aggr := range aggrs {
 			assert.Equal(t, typs[i][j], aggr.fieldType)
 		}
@@ -133,7 +133,7 @@ func TestNewAggregations(t *testing.T) {
 		Type: querypb.Type_VARCHAR,
 	}}
 
-	aggr2 := NewAggregations(plan2, false, field2)
+	aggr2 := NewAggregations(plan2, false, field2, 0)
 	assert.Equal(t, querypb.Type_INT64, aggr2[0].fieldType)
 	plan3 := []planner.Aggregator{{
 		Field:    "d",
@@ -145,7 +145,7 @@ func TestNewAggregations(t *testing.T) {
 		Name: "d",
 		Type: querypb.Type_VARCHAR,
 	}}
-	aggr3 := NewAggregations(plan3, true, field3)
+	aggr3 := NewAggregations(plan3, true, field3, 0)
 	assert.Equal(t, querypb.Type_FLOAT64, aggr3[0].fieldType)
 }
 
@@ -309,3 +309,62 @@ func TestGetResults(t *testing.T) {
 	assert.Equal(t, res, got)
 	assert.Equal(t, []int{1}, deIdxs)
 }
+
+func TestGroupConcat(t *testing.T) {
+	fields := []*querypb.Field{{
+		Name: "a",
+		Type: querypb.Type_VARCHAR,
+	}, {
+		Name: "b",
+		Type: querypb.Type_INT32,
+	}}
+	rows := [][]sqltypes.Value{
+		{sqltypes.NewVarChar("x"), sqltypes.NewInt32(3)},
+		{sqltypes.NewVarChar("y"), sqltypes.NewInt32(1)},
+		{sqltypes.NULL, sqltypes.NewInt32(5)},
+		{sqltypes.NewVarChar("x"), sqltypes.NewInt32(2)},
+		{sqltypes.NewVarChar("z"), sqltypes.NewInt32(4)},
+	}
+
+	plans := []planner.Aggregator{
+		{Field: "g1", Index: 0, Type: planner.AggrTypeGroupConcat, Separator: ","},
+		{Field: "g2", Index: 0, Type: planner.AggrTypeGroupConcat, Distinct: true, Separator: ";"},
+		{Field: "g3", Index: 0, Type: planner.AggrTypeGroupConcat, Separator: ",", OrderBys: []planner.ConcatOrderBy{{Index: 1, Direction: planner.DESC}}},
+		{Field: "g4", Index: 1, Type: planner.AggrTypeGroupConcat, Separator: "", OrderBys: []planner.ConcatOrderBy{{Index: 1, Direction: planner.ASC}}},
+	}
+	wants := []string{"x,y,x,z", "x;y;z", "z,x,x,y", "12345"}
+	for i, plan := range plans {
+		aggrs := NewAggregations([]planner.Aggregator{plan}, false, fields, 0)
+		evalCtxs := NewAggEvalCtxs(aggrs, rows[0])
+		for _, row := range rows[1:] {
+			aggrs[0].Update(row, evalCtxs[0])
+		}
+		row := []sqltypes.Value{sqltypes.NULL, sqltypes.NULL}
+		res, _ := GetResults(aggrs, evalCtxs, row)
+		assert.Equal(t, wants[i], res[plan.Index].String())
+	}
+	assert.Equal(t, querypb.Type_VARCHAR, fields[1].Type)
+	assert.Equal(t, uint32(DefaultGroupConcatMaxLen), fields[1].ColumnLength)
+
+	// Truncated by the max length.
+	{
+		fields := []*querypb.Field{{Name: "a", Type: querypb.Type_VARCHAR}}
+		aggrs := NewAggregations(plans[:1], false, fields, 5)
+		evalCtxs := NewAggEvalCtxs(aggrs, rows[0])
+		for _, row := range rows[1:] {
+			aggrs[0].Update(row, evalCtxs[0])
+		}
+		res, _ := GetResults(aggrs, evalCtxs, []sqltypes.Value{sqltypes.NULL})
+		assert.Equal(t, "x,y,x", res[0].String())
+		assert.Equal(t, querypb.Type_VARCHAR, fields[0].Type)
+	}
+
+	// Empty group.
+	{
+		fields := []*querypb.Field{{Name: "a", Type: querypb.Type_VARCHAR}}
+		aggrs := NewAggregations(plans[:1], false, fields, 0)
+		evalCtxs := NewAggEvalCtxs(aggrs, nil)
+		res, _ := GetResults(aggrs, evalCtxs, []sqltypes.Value{sqltypes.NULL})
+		assert.True(t, res[0].IsNull())
+	}
+}
@@ -0,0 +1,723 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// maxStringLen is the max length of the string built by the functions, such as REPEAT/LPAD,
+	// the result is NULL if exceeded.
+	maxStringLen = 16 * 1024 * 1024
+)
+
+// builtin is the built-in function.
+type builtin struct {
+	// minArgs and maxArgs is the count range of the args, maxArgs is -1 if unlimited.
+	minArgs, maxArgs int
+	// typ returns the result type by the args.
+	typ func(args []evalExpr) evalType
+	// eval evaluates the function on the row.
+	eval func(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error)
+}
+
+var builtins map[string]*builtin
+
+func init() {
+	builtins = map[string]*builtin{
+		// Control flow functions.
+		"if":       {3, 3, func(args []evalExpr) evalType { return mergeTypes(args[1].result(), args[2].result()) }, evalIf},
+		"ifnull":   {2, 2, mergeArgs, evalIfNull},
+		"nullif":   {2, 2, argType, evalNullIf},
+		"coalesce": {1, -1, mergeArgs, evalCoalesce},
+		"isnull":   {1, 1, fixedType(typeInt64), evalIsNull},
+
+		// String functions.
+		"concat":           {1, -1, stringArgs, evalConcat},
+		"concat_ws":        {2, -1, stringArgs, evalConcatWs},
+		"upper":            {1, 1, stringArg, evalUpper},
+		"ucase":            {1, 1, stringArg, evalUpper},
+		"lower":            {1, 1, stringArg, evalLower},
+		"lcase":            {1, 1, stringArg, evalLower},
+		"length":           {1, 1, fixedType(typeInt64), evalLength},
+		"octet_length":     {1, 1, fixedType(typeInt64), evalLength},
+		"char_length":      {1, 1, fixedType(typeInt64), evalCharLength},
+		"character_length": {1, 1, fixedType(typeInt64), evalCharLength},
+		"substring":        {2, 3, stringArg, evalSubstring},
+		"substr":           {2, 3, stringArg, evalSubstring},
+		"mid":              {3, 3, stringArg, evalSubstring},
+		"left":             {2, 2, stringArg, evalLeft},
+		"right":            {2, 2, stringArg, evalRight},
+		"trim":             {1, 1, stringArg, evalTrim},
+		"ltrim":            {1, 1, stringArg, evalTrim},
+		"rtrim":            {1, 1, stringArg, evalTrim},
+		"replace":          {3, 3, stringArgs, evalReplace},
+		"lpad":             {3, 3, stringArgs, evalPad},
+		"rpad":             {3, 3, stringArgs, evalPad},
+		"reverse":          {1, 1, stringArg, evalReverse},
+		"repeat":           {2, 2, stringArg, evalRepeat},
+		"instr":            {2, 2, fixedType(typeInt64), evalLocate},
+		"locate":           {2, 3, fixedType(typeInt64), evalLocate},
+
+		// Numeric functions.
+		"abs":      {1, 1, numericArg, evalAbs},
+		"ceil":     {1, 1, ceilType, evalCeil},
+		"ceiling":  {1, 1, ceilType, evalCeil},
+		"floor":    {1, 1, ceilType, evalCeil},
+		"round":    {1, 2, roundType, evalRound},
+		"truncate": {2, 2, roundType, evalRound},
+		"sign":     {1, 1, fixedType(typeInt64), evalSign},
+		"greatest": {2, -1, mergeArgs, evalGreatest},
+		"least":    {2, -1, mergeArgs, evalGreatest},
+		"pow":      {2, 2, fixedType(typeFloat64), evalPow},
+		"power":    {2, 2, fixedType(typeFloat64), evalPow},
+		"sqrt":     {1, 1, fixedType(typeFloat64), evalSqrt},
+
+		// Date and time functions.
+		"now":               {0, 1, fixedType(typeDtime), evalNow},
+		"current_timestamp": {0, 1, fixedType(typeDtime), evalNow},
+		"localtime":         {0, 1, fixedType(typeDtime), evalNow},
+		"localtimestamp":    {0, 1, fixedType(typeDtime), evalNow},
+		"sysdate":           {0, 1, fixedType(typeDtime), evalNow},
+		"curdate":           {0, 0, fixedType(typeDate), evalNow},
+		"current_date":      {0, 0, fixedType(typeDate), evalNow},
+		"curtime":           {0, 1, fixedType(typeTime), evalNow},
+		"current_time":      {0, 1, fixedType(typeTime), evalNow},
+		"date":              {1, 1, fixedType(typeDate), evalDate},
+		"year":              {1, 1, fixedType(typeInt64), evalDatePart},
+		"quarter":           {1, 1, fixedType(typeInt64), evalDatePart},
+		"month":             {1, 1, fixedType(typeInt64), evalDatePart},
+		"day":               {1, 1, fixedType(typeInt64), evalDatePart},
+		"dayofmonth":        {1, 1, fixedType(typeInt64), evalDatePart},
+		"dayofweek":         {1, 1, fixedType(typeInt64), evalDatePart},
+		"dayofyear":         {1, 1, fixedType(typeInt64), evalDatePart},
+		"weekday":           {1, 1, fixedType(typeInt64), evalDatePart},
+		"hour":              {1, 1, fixedType(typeInt64), evalTimePart},
+		"minute":            {1, 1, fixedType(typeInt64), evalTimePart},
+		"second":            {1, 1, fixedType(typeInt64), evalTimePart},
+		"datediff":          {2, 2, fixedType(typeInt64), evalDateDiff},
+		"date_format":       {2, 2, fixedType(typeVarChar), evalDateFormat},
+
+		// Miscellaneous functions.
+		"uuid": {0, 0, fixedType(typeVarChar), evalUUID},
+	}
+}
+
+// funcExpr is the call of the built-in function.
+type funcExpr struct {
+	name string
+	args []evalExpr
+	typ  evalType
+	fn   *builtin
+	text string
+	// now is the time of the statement.
+	now time.Time
+}
+
+func (c *compiler) compileFunc(node *sqlparser.FuncExpr) (evalExpr, error) {
+	name := strings.ToLower(node.Name.String())
+	if node.IsAggregate() {
+		return c.compileAggregate(node)
+	}
+
+	var exprs []sqlparser.Expr
+	for _, arg := range node.Exprs {
+		aliased, ok := arg.(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+		}
+		exprs = append(exprs, aliased.Expr)
+	}
+
+	switch name {
+	case "mod":
+		if len(exprs) != 2 {
+			return nil, errors.Errorf("Incorrect parameter count in the call to native function '%s'", name)
+		}
+		args, err := c.compileExprs(exprs...)
+		if err != nil {
+			return nil, err
+		}
+		return newArithExpr(sqlparser.ModStr, args[0], args[1], sqlparser.String(node)), nil
+	case "date_add", "date_sub", "adddate", "subdate":
+		return c.compileDateAdd(name, node, exprs)
+	}
+
+	fn, ok := builtins[name]
+	if !ok || !node.Qualifier.IsEmpty() {
+		return nil, errors.Errorf("unsupported: function.'%s'", name)
+	}
+	if len(exprs) < fn.minArgs || (fn.maxArgs >= 0 && len(exprs) > fn.maxArgs) {
+		return nil, errors.Errorf("Incorrect parameter count in the call to native function '%s'", name)
+	}
+	args, err := c.compileExprs(exprs...)
+	if err != nil {
+		return nil, err
+	}
+	f := &funcExpr{
+		name: name,
+		args: args,
+		fn:   fn,
+		text: sqlparser.String(node),
+		now:  c.now,
+	}
+	f.typ = fn.typ(args)
+	return f, nil
+}
+
+func (f *funcExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return f.fn.eval(f, row)
+}
+
+func (f *funcExpr) result() evalType {
+	return f.typ
+}
+
+// evalArgs evaluates all the args, the second result is true if any arg is NULL.
+func (f *funcExpr) evalArgs(row []sqltypes.Value) ([]sqltypes.Value, bool, error) {
+	hasNull := false
+	vals := make([]sqltypes.Value, len(f.args))
+	for i, arg := range f.args {
+		v, err := arg.eval(row)
+		if err != nil {
+			return nil, false, err
+		}
+		hasNull = hasNull || v.IsNull()
+		vals[i] = v
+	}
+	return vals, hasNull, nil
+}
+
+// binary returns true if the result is binary string.
+func (f *funcExpr) binary() bool {
+	return isBinaryType(f.typ.typ)
+}
+
+// str builds the string result.
+func (f *funcExpr) str(s string) sqltypes.Value {
+	return sqltypes.MakeTrusted(f.typ.typ, []byte(s))
+}
+
+func fixedType(typ evalType) func(args []evalExpr) evalType {
+	return func(args []evalExpr) evalType {
+		return typ
+	}
+}
+
+func argType(args []evalExpr) evalType {
+	return args[0].result()
+}
+
+func mergeArgs(args []evalExpr) evalType {
+	var types []evalType
+	for _, arg := range args {
+		types = append(types, arg.result())
+	}
+	return mergeTypes(types...)
+}
+
+func stringArg(args []evalExpr) evalType {
+	return stringType(args[0].result())
+}
+
+func stringArgs(args []evalExpr) evalType {
+	var types []evalType
+	for _, arg := range args {
+		types = append(types, arg.result())
+	}
+	return stringType(types...)
+}
+
+func numericArg(args []evalExpr) evalType {
+	res := args[0].result()
+	return numericType(numericKind(res.typ), res.decimals)
+}
+
+func ceilType(args []evalExpr) evalType {
+	switch kind := numericKind(args[0].result().typ); kind {
+	case kindDecimal:
+		return typeInt64
+	default:
+		return numericType(kind, 0)
+	}
+}
+
+func roundType(args []evalExpr) evalType {
+	res := numericArg(args)
+	if res.typ != sqltypes.Decimal {
+		return res
+	}
+	if len(args) == 1 {
+		res.decimals = 0
+	} else if cons, ok := args[1].(*constExpr); ok && !cons.val.IsNull() {
+		res.decimals = int(math.Max(0, math.Min(float64(toInt64(cons.val)), MaxDecimalScale)))
+	}
+	return res
+}
+
+func evalIf(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := f.args[0].eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	if b, null := toBool(v); b && !null {
+		return evalCast(f.args[1], row, f.typ)
+	}
+	return evalCast(f.args[2], row, f.typ)
+}
+
+func evalIfNull(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := f.args[0].eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	if !v.IsNull() {
+		return castTo(v, f.typ), nil
+	}
+	return evalCast(f.args[1], row, f.typ)
+}
+
+func evalNullIf(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, _, err := f.evalArgs(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	if vals[0].IsNull() || vals[1].IsNull() {
+		return vals[0], nil
+	}
+	kind, binary := compareKind(f.args[0].result(), f.args[1].result())
+	if compareValues(kind, binary, vals[0], vals[1]) == 0 {
+		return sqltypes.NULL, nil
+	}
+	return vals[0], nil
+}
+
+func evalCoalesce(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	for _, arg := range f.args {
+		v, err := arg.eval(row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if !v.IsNull() {
+			return castTo(v, f.typ), nil
+		}
+	}
+	return sqltypes.NULL, nil
+}
+
+func evalIsNull(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := f.args[0].eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	return boolValue(v.IsNull()), nil
+}
+
+func evalConcat(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	var buf strings.Builder
+	for _, v := range vals {
+		buf.Write(v.Raw())
+	}
+	return f.str(buf.String()), nil
+}
+
+func evalConcatWs(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, _, err := f.evalArgs(row)
+	if err != nil || vals[0].IsNull() {
+		return sqltypes.NULL, err
+	}
+	var strs []string
+	for _, v := range vals[1:] {
+		if !v.IsNull() {
+			strs = append(strs, v.ToString())
+		}
+	}
+	return f.str(strings.Join(strs, vals[0].ToString())), nil
+}
+
+func evalUpper(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	if f.binary() {
+		return f.str(vals[0].ToString()), nil
+	}
+	return f.str(strings.ToUpper(vals[0].ToString())), nil
+}
+
+func evalLower(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	if f.binary() {
+		return f.str(vals[0].ToString()), nil
+	}
+	return f.str(strings.ToLower(vals[0].ToString())), nil
+}
+
+func evalLength(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	return sqltypes.NewInt64(int64(vals[0].Len())), nil
+}
+
+func evalCharLength(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	if isBinaryType(f.args[0].result().typ) {
+		return sqltypes.NewInt64(int64(vals[0].Len())), nil
+	}
+	return sqltypes.NewInt64(int64(utf8.RuneCountInString(vals[0].ToString()))), nil
+}
+
+// chars splits the string to the chars, the binary string is split to bytes.
+func chars(s string, binary bool) []string {
+	var res []string
+	if binary {
+		for i := 0; i < len(s); i++ {
+			res = append(res, s[i:i+1])
+		}
+		return res
+	}
+	for _, r := range s {
+		res = append(res, string(r))
+	}
+	return res
+}
+
+// substringRange returns the range of the SUBSTRING(str, pos, len) in the n chars.
+func substringRange(n int, pos, length int64) (int, int) {
+	var start int64
+	switch {
+	case pos > 0:
+		start = pos - 1
+	case pos < 0:
+		start = int64(n) + pos
+	default:
+		return 0, 0
+	}
+	if start < 0 || start >= int64(n) || length <= 0 {
+		return 0, 0
+	}
+	end := int64(n)
+	if length < end-start {
+		end = start + length
+	}
+	return int(start), int(end)
+}
+
+func evalSubstring(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	length := int64(math.MaxInt64)
+	if len(vals) == 3 {
+		length = toInt64(vals[2])
+	}
+	cs := chars(vals[0].ToString(), f.binary())
+	start, end := substringRange(len(cs), toInt64(vals[1]), length)
+	return f.str(strings.Join(cs[start:end], "")), nil
+}
+
+func evalLeft(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	cs := chars(vals[0].ToString(), f.binary())
+	start, end := substringRange(len(cs), 1, toInt64(vals[1]))
+	return f.str(strings.Join(cs[start:end], "")), nil
+}
+
+func evalRight(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	cs := chars(vals[0].ToString(), f.binary())
+	n := toInt64(vals[1])
+	if n <= 0 {
+		return f.str(""), nil
+	}
+	if n < int64(len(cs)) {
+		cs = cs[int64(len(cs))-n:]
+	}
+	return f.str(strings.Join(cs, "")), nil
+}
+
+func evalTrim(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	s := vals[0].ToString()
+	switch f.name {
+	case "ltrim":
+		s = strings.TrimLeft(s, " ")
+	case "rtrim":
+		s = strings.TrimRight(s, " ")
+	default:
+		s = strings.Trim(s, " ")
+	}
+	return f.str(s), nil
+}
+
+func evalReplace(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	s, from := vals[0].ToString(), vals[1].ToString()
+	if from == "" {
+		return f.str(s), nil
+	}
+	return f.str(strings.Replace(s, from, vals[2].ToString(), -1)), nil
+}
+
+func evalPad(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	n := toInt64(vals[1])
+	if n < 0 || n > maxStringLen {
+		return sqltypes.NULL, nil
+	}
+	cs := chars(vals[0].ToString(), f.binary())
+	if int64(len(cs)) >= n {
+		return f.str(strings.Join(cs[:n], "")), nil
+	}
+	pad := chars(vals[2].ToString(), f.binary())
+	if len(pad) == 0 {
+		return sqltypes.NULL, nil
+	}
+	var fill []string
+	for i := int64(0); i < n-int64(len(cs)); i++ {
+		fill = append(fill, pad[i%int64(len(pad))])
+	}
+	if f.name == "lpad" {
+		return f.str(strings.Join(fill, "") + strings.Join(cs, "")), nil
+	}
+	return f.str(strings.Join(cs, "") + strings.Join(fill, "")), nil
+}
+
+func evalReverse(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	cs := chars(vals[0].ToString(), f.binary())
+	for i, j := 0, len(cs)-1; i < j; i, j = i+1, j-1 {
+		cs[i], cs[j] = cs[j], cs[i]
+	}
+	return f.str(strings.Join(cs, "")), nil
+}
+
+func evalRepeat(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	s, n := vals[0].ToString(), toInt64(vals[1])
+	if n <= 0 || s == "" {
+		return f.str(""), nil
+	}
+	if n > maxStringLen/int64(len(s)) {
+		return sqltypes.NULL, nil
+	}
+	return f.str(strings.Repeat(s, int(n))), nil
+}
+
+// evalLocate evaluates the INSTR(str, substr) and LOCATE(substr, str[, pos]).
+func evalLocate(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	str, sub := vals[0], vals[1]
+	if f.name == "locate" {
+		str, sub = sub, str
+	}
+	pos := int64(1)
+	if len(vals) == 3 {
+		pos = toInt64(vals[2])
+	}
+
+	binary := isBinaryType(str.Type()) || isBinaryType(sub.Type())
+	s, t := str.ToString(), sub.ToString()
+	if !binary {
+		s, t = strings.ToLower(s), strings.ToLower(t)
+	}
+	cs, ts := chars(s, binary), chars(t, binary)
+	if pos < 1 || pos > int64(len(cs))+1 {
+		return sqltypes.NewInt64(0), nil
+	}
+	for i := int(pos - 1); i+len(ts) <= len(cs); i++ {
+		if strings.Join(cs[i:i+len(ts)], "") == t {
+			return sqltypes.NewInt64(int64(i + 1)), nil
+		}
+	}
+	return sqltypes.NewInt64(0), nil
+}
+
+func evalAbs(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	if f.typ.typ == sqltypes.Float64 {
+		return sqltypes.NewFloat64(math.Abs(toFloat64(vals[0]))), nil
+	}
+	return fromDecimal(toDecimal(vals[0]).Abs(), f.typ, f.text)
+}
+
+// evalCeil evaluates the CEIL and FLOOR.
+func evalCeil(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	floor := f.name == "floor"
+	if f.typ.typ == sqltypes.Float64 {
+		if floor {
+			return sqltypes.NewFloat64(math.Floor(toFloat64(vals[0]))), nil
+		}
+		return sqltypes.NewFloat64(math.Ceil(toFloat64(vals[0]))), nil
+	}
+	d := toDecimal(vals[0])
+	if floor {
+		return fromDecimal(d.Floor(), f.typ, f.text)
+	}
+	return fromDecimal(d.Ceil(), f.typ, f.text)
+}
+
+// evalRound evaluates the ROUND and TRUNCATE.
+func evalRound(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	var places int64
+	if len(vals) == 2 {
+		places = toInt64(vals[1])
+		if places > MaxDecimalScale {
+			places = MaxDecimalScale
+		} else if places < -MaxDecimalScale {
+			places = -MaxDecimalScale
+		}
+	}
+	truncate := f.name == "truncate"
+
+	if f.typ.typ == sqltypes.Float64 {
+		x, p := toFloat64(vals[0]), math.Pow(10, float64(places))
+		if truncate {
+			return sqltypes.NewFloat64(math.Trunc(x*p) / p), nil
+		}
+		return sqltypes.NewFloat64(math.RoundToEven(x*p) / p), nil
+	}
+
+	d := toDecimal(vals[0])
+	if truncate {
+		d = d.Shift(int32(places)).Truncate(0).Shift(int32(-places))
+	} else {
+		d = d.Round(int32(places))
+	}
+	if f.typ.typ == sqltypes.Decimal {
+		return decimalValue(d, f.typ.decimals), nil
+	}
+	return fromDecimal(d, f.typ, f.text)
+}
+
+func evalSign(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	var d decimal.Decimal
+	if numericKind(vals[0].Type()) == kindFloat {
+		d = floatToDecimal(toFloat64(vals[0]))
+	} else {
+		d = toDecimal(vals[0])
+	}
+	return sqltypes.NewInt64(int64(d.Sign())), nil
+}
+
+// evalGreatest evaluates the GREATEST and LEAST.
+func evalGreatest(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	kind, binary := compareKind(f.typ, f.typ)
+	res := vals[0]
+	for _, v := range vals[1:] {
+		cmp := compareValues(kind, binary, v, res)
+		if (f.name == "greatest" && cmp > 0) || (f.name == "least" && cmp < 0) {
+			res = v
+		}
+	}
+	return castTo(res, f.typ), nil
+}
+
+func evalPow(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	res := math.Pow(toFloat64(vals[0]), toFloat64(vals[1]))
+	if math.IsInf(res, 0) || math.IsNaN(res) {
+		return sqltypes.NULL, errors.Errorf("DOUBLE value is out of range in '%s'", f.text)
+	}
+	return sqltypes.NewFloat64(res), nil
+}
+
+func evalSqrt(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	x := toFloat64(vals[0])
+	if x < 0 {
+		return sqltypes.NULL, nil
+	}
+	return sqltypes.NewFloat64(math.Sqrt(x)), nil
+}
+
+// evalUUID generates the random(version 4) UUID, which is different on each call.
+func evalUUID(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return sqltypes.NULL, err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return sqltypes.NewVarChar(fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])), nil
+}
@@ -0,0 +1,166 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+)
+
+func TestBuiltinString(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"concat(s, '-', a)", "Hello-10", querypb.Type_VARCHAR},
+		{"concat(s, n)", "NULL", querypb.Type_VARCHAR},
+		{"concat(bs, 'x')", "Hellox", querypb.Type_VARBINARY},
+		{"concat_ws(',', s, n, a)", "Hello,10", querypb.Type_VARCHAR},
+		{"concat_ws(n, s)", "NULL", querypb.Type_VARCHAR},
+		{"upper(s)", "HELLO", querypb.Type_VARCHAR},
+		{"ucase(s)", "HELLO", querypb.Type_VARCHAR},
+		{"lower(s)", "hello", querypb.Type_VARCHAR},
+		{"lower(bs)", "Hello", querypb.Type_VARBINARY},
+		{"length('中文')", "6", querypb.Type_INT64},
+		{"char_length('中文')", "2", querypb.Type_INT64},
+		{"char_length(n)", "NULL", querypb.Type_INT64},
+		{"substring(s, 2)", "ello", querypb.Type_VARCHAR},
+		{"substring(s, 2, 3)", "ell", querypb.Type_VARCHAR},
+		{"substr(s, -3, 2)", "ll", querypb.Type_VARCHAR},
+		{"substring(s, 0)", "", querypb.Type_VARCHAR},
+		{"substring(s, 10)", "", querypb.Type_VARCHAR},
+		{"mid('中文字符', 2, 2)", "文字", querypb.Type_VARCHAR},
+		{"left(s, 2)", "He", querypb.Type_VARCHAR},
+		{"left(s, -1)", "", querypb.Type_VARCHAR},
+		{"right(s, 3)", "llo", querypb.Type_VARCHAR},
+		{"right(s, 10)", "Hello", querypb.Type_VARCHAR},
+		{"trim('  x  ')", "x", querypb.Type_VARCHAR},
+		{"ltrim('  x  ')", "x  ", querypb.Type_VARCHAR},
+		{"rtrim('  x  ')", "  x", querypb.Type_VARCHAR},
+		{"replace(s, 'l', 'L')", "HeLLo", querypb.Type_VARCHAR},
+		{"replace(s, '', 'L')", "Hello", querypb.Type_VARCHAR},
+		{"lpad(a, 5, '0')", "00010", querypb.Type_VARCHAR},
+		{"rpad(s, 8, 'ab')", "Helloaba", querypb.Type_VARCHAR},
+		{"lpad(s, 2, '0')", "He", querypb.Type_VARCHAR},
+		{"lpad(s, 8, '')", "NULL", querypb.Type_VARCHAR},
+		{"reverse(s)", "olleH", querypb.Type_VARCHAR},
+		{"repeat('ab', 3)", "ababab", querypb.Type_VARCHAR},
+		{"repeat('ab', 0)", "", querypb.Type_VARCHAR},
+		{"instr(s, 'LL')", "3", querypb.Type_INT64},
+		{"instr(bs, 'LL')", "0", querypb.Type_INT64},
+		{"locate('l', s, 4)", "4", querypb.Type_INT64},
+		{"locate('x', s)", "0", querypb.Type_INT64},
+	})
+}
+
+func TestBuiltinNumeric(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"abs(-a)", "10", querypb.Type_INT64},
+		{"abs(-d)", "1.50", querypb.Type_DECIMAL},
+		{"abs(-f)", "2.5", querypb.Type_FLOAT64},
+		{"ceil(d)", "2", querypb.Type_INT64},
+		{"ceiling(f)", "3", querypb.Type_FLOAT64},
+		{"floor(-d)", "-2", querypb.Type_INT64},
+		{"floor(a)", "10", querypb.Type_INT64},
+		{"round(d)", "2", querypb.Type_DECIMAL},
+		{"round(d, 1)", "1.5", querypb.Type_DECIMAL},
+		{"round(a / b, 2)", "3.33", querypb.Type_DECIMAL},
+		{"round(f)", "2", querypb.Type_FLOAT64},
+		{"round(1234, -2)", "1200", querypb.Type_INT64},
+		{"round(-2.5)", "-3", querypb.Type_DECIMAL},
+		{"truncate(1.999, 2)", "1.99", querypb.Type_DECIMAL},
+		{"truncate(1999, -2)", "1900", querypb.Type_INT64},
+		{"truncate(f, 0)", "2", querypb.Type_FLOAT64},
+		{"sign(-d)", "-1", querypb.Type_INT64},
+		{"sign(0)", "0", querypb.Type_INT64},
+		{"greatest(a, b, 5)", "10", querypb.Type_INT64},
+		{"least(a, d)", "1.50", querypb.Type_DECIMAL},
+		{"greatest(a, n)", "NULL", querypb.Type_INT64},
+		{"least('b', 'A', 'c')", "A", querypb.Type_VARCHAR},
+		{"pow(2, 10)", "1024", querypb.Type_FLOAT64},
+		{"power(b, 2)", "9", querypb.Type_FLOAT64},
+		{"sqrt(16)", "4", querypb.Type_FLOAT64},
+		{"sqrt(-1)", "NULL", querypb.Type_FLOAT64},
+	})
+}
+
+func TestBuiltinTime(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"date(dt)", "2019-01-31", querypb.Type_DATE},
+		{"date('2019-02-30')", "NULL", querypb.Type_DATE},
+		{"year(dt)", "2019", querypb.Type_INT64},
+		{"quarter(dt)", "1", querypb.Type_INT64},
+		{"month('20190315')", "3", querypb.Type_INT64},
+		{"day(dd)", "31", querypb.Type_INT64},
+		{"dayofmonth(dd)", "31", querypb.Type_INT64},
+		{"dayofweek(dd)", "5", querypb.Type_INT64},
+		{"weekday(dd)", "3", querypb.Type_INT64},
+		{"dayofyear('2019-02-01')", "32", querypb.Type_INT64},
+		{"hour(dt)", "10", querypb.Type_INT64},
+		{"minute('10:11:12')", "11", querypb.Type_INT64},
+		{"second(dt)", "12", querypb.Type_INT64},
+		{"hour(dd)", "0", querypb.Type_INT64},
+		{"year(n)", "NULL", querypb.Type_INT64},
+		{"datediff(dt, '2019-01-01 23:59:59')", "30", querypb.Type_INT64},
+		{"datediff('2019-01-01', dd)", "-30", querypb.Type_INT64},
+		{"date_format(dt, '%Y-%m-%d %H:%i:%s')", "2019-01-31 10:11:12", querypb.Type_VARCHAR},
+		{"date_format(dt, '%a %b %D %e %c %y %j %k %l %p %r %T %W %w %M %f %%')",
+			"Thu Jan 31st 31 1 19 031 10 10 AM 10:11:12 AM 10:11:12 Thursday 4 January 000000 %", querypb.Type_VARCHAR},
+		{"date_add(dd, interval 1 month)", "2019-02-28", querypb.Type_DATE},
+		{"date_add(dd, interval 1 hour)", "2019-01-31 01:00:00", querypb.Type_DATETIME},
+		{"date_sub(dt, interval 1 year)", "2018-01-31 10:11:12", querypb.Type_DATETIME},
+		{"adddate(dd, 2)", "2019-02-02", querypb.Type_DATE},
+		{"subdate('2019-03-01', interval 1 day)", "2019-02-28", querypb.Type_VARCHAR},
+		{"dt + interval 1 quarter", "2019-04-30 10:11:12", querypb.Type_DATETIME},
+		{"interval 2 week + dd", "2019-02-14", querypb.Type_DATE},
+		{"'2019-01-01 10:00:00' - interval 30 minute", "2019-01-01 09:30:00", querypb.Type_VARCHAR},
+		{"date_add(dt, interval 1 microsecond)", "2019-01-31 10:11:12.000001", querypb.Type_DATETIME},
+		{"date_add(dd, interval n day)", "NULL", querypb.Type_DATE},
+		{"date_add('x', interval 1 day)", "NULL", querypb.Type_VARCHAR},
+	})
+}
+
+func TestBuiltinUUID(t *testing.T) {
+	e, err := NewEvaluator(parseExpr(t, "uuid()"), evalFields)
+	assert.Nil(t, err)
+	v1, err := e.Eval(evalRow)
+	assert.Nil(t, err)
+	assert.Regexp(t, "^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$", v1.String())
+	v2, err := e.Eval(evalRow)
+	assert.Nil(t, err)
+	assert.NotEqual(t, v1, v2)
+
+	_, err = NewEvaluator(parseExpr(t, "uuid(1)"), evalFields)
+	assert.NotNil(t, err)
+}
+
+func TestBuiltinNow(t *testing.T) {
+	for _, expr := range []string{"now()", "current_timestamp", "curdate()", "curtime()"} {
+		e, err := NewEvaluator(parseExpr(t, expr), evalFields)
+		assert.Nil(t, err)
+		v1, err := e.Eval(evalRow)
+		assert.Nil(t, err)
+		time.Sleep(10 * time.Millisecond)
+		v2, err := e.Eval(evalRow)
+		assert.Nil(t, err)
+		// The time is the same in the statement.
+		assert.Equal(t, v1, v2)
+	}
+}
+
+func TestBuiltinNowInLocation(t *testing.T) {
+	loc := time.FixedZone("", 5*3600)
+	e, err := NewEvaluatorInLocation(parseExpr(t, "now()"), evalFields, loc)
+	assert.Nil(t, err)
+	v, err := e.Eval(evalRow)
+	assert.Nil(t, err)
+	got, err := time.ParseInLocation("2006-01-02 15:04:05", v.ToString(), loc)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), got, 5*time.Second)
+}
@@ -0,0 +1,471 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	typeTime = evalType{sqltypes.Time, 0}
+
+	// intervalUnits is the supported units of the INTERVAL, true if the unit is not less than DAY.
+	intervalUnits = map[string]bool{
+		"microsecond": false,
+		"second":      false,
+		"minute":      false,
+		"hour":        false,
+		"day":         true,
+		"week":        true,
+		"month":       true,
+		"quarter":     true,
+		"year":        true,
+	}
+)
+
+// parseDatetime parses the DATE or DATETIME string, such as: '2019-01-02', '2019-01-02 10:11:12.123',
+// '20190102' and '20190102101112'. The second result is true if there is no time part.
+func parseDatetime(s string) (time.Time, bool, bool) {
+	s = strings.TrimSpace(s)
+	if isDigits(s) {
+		switch len(s) {
+		case 8:
+			s = s[0:4] + "-" + s[4:6] + "-" + s[6:8]
+		case 14:
+			s = s[0:4] + "-" + s[4:6] + "-" + s[6:8] + " " + s[8:10] + ":" + s[10:12] + ":" + s[12:14]
+		default:
+			return time.Time{}, false, false
+		}
+	}
+
+	datePart, timePart := s, ""
+	if idx := strings.IndexAny(s, " T"); idx >= 0 {
+		datePart, timePart = s[:idx], strings.TrimSpace(s[idx+1:])
+	}
+	ymd, ok := splitInts(datePart, "-", 3)
+	if !ok {
+		return time.Time{}, false, false
+	}
+	year, month, day := ymd[0], ymd[1], ymd[2]
+	if year > 9999 || month < 1 || month > 12 || day < 1 || day > daysIn(year, month) {
+		return time.Time{}, false, false
+	}
+
+	var hour, minute, second, nsec int
+	if timePart != "" {
+		if hour, minute, second, nsec, ok = parseClockParts(timePart); !ok || hour > 23 {
+			return time.Time{}, false, false
+		}
+	}
+	return time.Date(year, time.Month(month), day, hour, minute, second, nsec, time.UTC), timePart == "", true
+}
+
+// parseClockParts parses the 'hh:mm[:ss[.frac]]'.
+func parseClockParts(s string) (int, int, int, int, bool) {
+	nsec := 0
+	if idx := strings.IndexByte(s, '.'); idx >= 0 {
+		frac := s[idx+1:]
+		if len(frac) > 9 {
+			frac = frac[:9]
+		}
+		if frac != "" {
+			if !isDigits(frac) {
+				return 0, 0, 0, 0, false
+			}
+			n, _ := strconv.Atoi(frac + strings.Repeat("0", 9-len(frac)))
+			nsec = n
+		}
+		s = s[:idx]
+	}
+
+	parts, ok := splitInts(s, ":", 3)
+	if !ok {
+		if parts, ok = splitInts(s, ":", 2); !ok {
+			return 0, 0, 0, 0, false
+		}
+		parts = append(parts, 0)
+	}
+	if parts[1] > 59 || parts[2] > 59 {
+		return 0, 0, 0, 0, false
+	}
+	return parts[0], parts[1], parts[2], nsec, true
+}
+
+// parseClock parses the TIME or DATETIME, returns the time part.
+func parseClock(s string) (string, bool) {
+	if t, _, ok := parseDatetime(s); ok {
+		return t.Format("15:04:05"), true
+	}
+	s = strings.TrimSpace(s)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	hour, minute, second, _, ok := parseClockParts(s)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s%02d:%02d:%02d", sign, hour, minute, second), true
+}
+
+func splitInts(s, sep string, n int) ([]int, bool) {
+	strs := strings.Split(s, sep)
+	if len(strs) != n {
+		return nil, false
+	}
+	res := make([]int, n)
+	for i, str := range strs {
+		if !isDigits(str) || len(str) > 9 {
+			return nil, false
+		}
+		res[i], _ = strconv.Atoi(str)
+	}
+	return res, true
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// daysIn returns the days of the month.
+func daysIn(year, month int) int {
+	return time.Date(year, time.Month(month)+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func formatDate(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func formatDatetime(t time.Time) string {
+	s := t.Format("2006-01-02 15:04:05")
+	if t.Nanosecond() != 0 {
+		s = fmt.Sprintf("%s.%06d", s, t.Nanosecond()/1000)
+	}
+	return s
+}
+
+// evalNow evaluates the NOW/CURDATE/CURTIME, the time is the same in the statement.
+func evalNow(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	var s string
+	switch f.typ.typ {
+	case sqltypes.Date:
+		s = formatDate(f.now)
+	case sqltypes.Time:
+		s = f.now.Format("15:04:05")
+	default:
+		s = f.now.Format("2006-01-02 15:04:05")
+	}
+	return sqltypes.MakeTrusted(f.typ.typ, []byte(s)), nil
+}
+
+// evalTime evaluates the first arg as DATETIME, returns false if it is NULL or not a valid time.
+func (f *funcExpr) evalTime(row []sqltypes.Value) (time.Time, bool, error) {
+	v, err := f.args[0].eval(row)
+	if err != nil || v.IsNull() {
+		return time.Time{}, false, err
+	}
+	t, _, ok := parseDatetime(v.ToString())
+	return t, ok, nil
+}
+
+func evalDate(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	t, ok, err := f.evalTime(row)
+	if err != nil || !ok {
+		return sqltypes.NULL, err
+	}
+	return sqltypes.MakeTrusted(sqltypes.Date, []byte(formatDate(t))), nil
+}
+
+func evalDatePart(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	t, ok, err := f.evalTime(row)
+	if err != nil || !ok {
+		return sqltypes.NULL, err
+	}
+	var res int
+	switch f.name {
+	case "year":
+		res = t.Year()
+	case "quarter":
+		res = (int(t.Month())-1)/3 + 1
+	case "month":
+		res = int(t.Month())
+	case "dayofweek":
+		res = int(t.Weekday()) + 1
+	case "dayofyear":
+		res = t.YearDay()
+	case "weekday":
+		res = (int(t.Weekday()) + 6) % 7
+	default:
+		res = t.Day()
+	}
+	return sqltypes.NewInt64(int64(res)), nil
+}
+
+func evalTimePart(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := f.args[0].eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+	clock, ok := parseClock(v.ToString())
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+	hour, minute, second, _, _ := parseClockParts(strings.TrimPrefix(clock, "-"))
+	switch f.name {
+	case "hour":
+		return sqltypes.NewInt64(int64(hour)), nil
+	case "minute":
+		return sqltypes.NewInt64(int64(minute)), nil
+	}
+	return sqltypes.NewInt64(int64(second)), nil
+}
+
+func evalDateDiff(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	t1, _, ok1 := parseDatetime(vals[0].ToString())
+	t2, _, ok2 := parseDatetime(vals[1].ToString())
+	if !ok1 || !ok2 {
+		return sqltypes.NULL, nil
+	}
+	t1 = t1.Truncate(24 * time.Hour)
+	t2 = t2.Truncate(24 * time.Hour)
+	return sqltypes.NewInt64(int64(t1.Sub(t2).Hours() / 24)), nil
+}
+
+func evalDateFormat(f *funcExpr, row []sqltypes.Value) (sqltypes.Value, error) {
+	vals, hasNull, err := f.evalArgs(row)
+	if err != nil || hasNull {
+		return sqltypes.NULL, err
+	}
+	t, _, ok := parseDatetime(vals[0].ToString())
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+	return f.str(dateFormat(t, vals[1].ToString())), nil
+}
+
+// dateFormat formats the time by the format of the MySQL DATE_FORMAT.
+func dateFormat(t time.Time, format string) string {
+	var buf strings.Builder
+	hour12 := t.Hour() % 12
+	if hour12 == 0 {
+		hour12 = 12
+	}
+	ampm := "AM"
+	if t.Hour() >= 12 {
+		ampm = "PM"
+	}
+
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			buf.WriteByte(c)
+			continue
+		}
+		i++
+		switch format[i] {
+		case 'a':
+			buf.WriteString(t.Weekday().String()[:3])
+		case 'b':
+			buf.WriteString(t.Month().String()[:3])
+		case 'c':
+			fmt.Fprintf(&buf, "%d", t.Month())
+		case 'D':
+			fmt.Fprintf(&buf, "%d%s", t.Day(), ordinalSuffix(t.Day()))
+		case 'd':
+			fmt.Fprintf(&buf, "%02d", t.Day())
+		case 'e':
+			fmt.Fprintf(&buf, "%d", t.Day())
+		case 'f':
+			fmt.Fprintf(&buf, "%06d", t.Nanosecond()/1000)
+		case 'H':
+			fmt.Fprintf(&buf, "%02d", t.Hour())
+		case 'h', 'I':
+			fmt.Fprintf(&buf, "%02d", hour12)
+		case 'i':
+			fmt.Fprintf(&buf, "%02d", t.Minute())
+		case 'j':
+			fmt.Fprintf(&buf, "%03d", t.YearDay())
+		case 'k':
+			fmt.Fprintf(&buf, "%d", t.Hour())
+		case 'l':
+			fmt.Fprintf(&buf, "%d", hour12)
+		case 'M':
+			buf.WriteString(t.Month().String())
+		case 'm':
+			fmt.Fprintf(&buf, "%02d", t.Month())
+		case 'p':
+			buf.WriteString(ampm)
+		case 'r':
+			fmt.Fprintf(&buf, "%02d:%02d:%02d %s", hour12, t.Minute(), t.Second(), ampm)
+		case 'S', 's':
+			fmt.Fprintf(&buf, "%02d", t.Second())
+		case 'T':
+			fmt.Fprintf(&buf, "%02d:%02d:%02d", t.Hour(), t.Minute(), t.Second())
+		case 'W':
+			buf.WriteString(t.Weekday().String())
+		case 'w':
+			fmt.Fprintf(&buf, "%d", t.Weekday())
+		case 'Y':
+			fmt.Fprintf(&buf, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&buf, "%02d", t.Year()%100)
+		default:
+			buf.WriteByte(format[i])
+		}
+	}
+	return buf.String()
+}
+
+func ordinalSuffix(day int) string {
+	if day >= 11 && day <= 13 {
+		return "th"
+	}
+	switch day % 10 {
+	case 1:
+		return "st"
+	case 2:
+		return "nd"
+	case 3:
+		return "rd"
+	}
+	return "th"
+}
+
+// dateAddExpr is the DATE_ADD/DATE_SUB and the date +/- INTERVAL.
+type dateAddExpr struct {
+	date     evalExpr
+	interval *intervalExpr
+	sub      bool
+	typ      evalType
+}
+
+func (c *compiler) compileDateAdd(name string, node *sqlparser.FuncExpr, exprs []sqlparser.Expr) (evalExpr, error) {
+	if len(exprs) != 2 {
+		return nil, errors.Errorf("Incorrect parameter count in the call to native function '%s'", name)
+	}
+	date, err := c.compileExprs(exprs[0])
+	if err != nil {
+		return nil, err
+	}
+	arg, err := c.compile(exprs[1])
+	if err != nil {
+		return nil, err
+	}
+	interval, ok := arg.(*intervalExpr)
+	if !ok {
+		// ADDDATE(expr, days).
+		if name == "date_add" || name == "date_sub" {
+			return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+		}
+		interval = &intervalExpr{expr: arg, unit: "day"}
+	}
+	return newDateAddExpr(date[0], interval, name == "date_sub" || name == "subdate")
+}
+
+func newDateAddExpr(date evalExpr, interval *intervalExpr, sub bool) (evalExpr, error) {
+	e := &dateAddExpr{date: date, interval: interval, sub: sub}
+	switch date.result().typ {
+	case sqltypes.Date:
+		e.typ = typeDtime
+		if intervalUnits[interval.unit] {
+			e.typ = typeDate
+		}
+	case sqltypes.Datetime, sqltypes.Timestamp:
+		e.typ = typeDtime
+	default:
+		e.typ = typeVarChar
+	}
+	return e, nil
+}
+
+func (e *dateAddExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.date.eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+	t, dateOnly, ok := parseDatetime(v.ToString())
+	if !ok {
+		return sqltypes.NULL, nil
+	}
+	iv, err := e.interval.eval(row)
+	if err != nil || iv.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	n := toInt64(iv)
+	if e.sub {
+		n = -n
+	}
+	switch e.interval.unit {
+	case "microsecond":
+		t = t.Add(time.Duration(n) * time.Microsecond)
+	case "second":
+		t = t.Add(time.Duration(n) * time.Second)
+	case "minute":
+		t = t.Add(time.Duration(n) * time.Minute)
+	case "hour":
+		t = t.Add(time.Duration(n) * time.Hour)
+	case "day":
+		t = t.AddDate(0, 0, int(n))
+	case "week":
+		t = t.AddDate(0, 0, int(n)*7)
+	case "month":
+		t = addMonths(t, n)
+	case "quarter":
+		t = addMonths(t, n*3)
+	case "year":
+		t = addMonths(t, n*12)
+	}
+	if t.Year() < 0 || t.Year() > 9999 {
+		return sqltypes.NULL, nil
+	}
+
+	if e.typ.typ == sqltypes.Date || (e.typ.typ != sqltypes.Datetime && dateOnly && intervalUnits[e.interval.unit]) {
+		return sqltypes.MakeTrusted(e.typ.typ, []byte(formatDate(t))), nil
+	}
+	return sqltypes.MakeTrusted(e.typ.typ, []byte(formatDatetime(t))), nil
+}
+
+func (e *dateAddExpr) result() evalType {
+	return e.typ
+}
+
+// addMonths adds the months, the day is adjusted to the last day of the month if overflow,
+// such as: '2019-01-31' + 1 month -> '2019-02-28'.
+func addMonths(t time.Time, n int64) time.Time {
+	months := int64(t.Year())*12 + int64(t.Month()) - 1 + n
+	if months < 0 || months >= 10000*12 {
+		return time.Date(-1, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	year, month := int(months/12), int(months%12)+1
+	day := t.Day()
+	if last := daysIn(year, month); day > last {
+		day = last
+	}
+	return time.Date(year, time.Month(month), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+}
@@ -15,4 +15,12 @@ const (
 	FloatDigits = 6
 	// DoubleDigits double decimal precision.
 	DoubleDigits = 15
+	// NotFixedDec the decimals of the float and the string.
+	NotFixedDec = 31
+	// MaxDecimalScale the max scale of the decimal.
+	MaxDecimalScale = 30
+	// DivPrecisionIncrement the scale increment of the decimal division result.
+	DivPrecisionIncrement = 4
+	// DefaultGroupConcatMaxLen the default value of the group_concat_max_len.
+	DefaultGroupConcatMaxLen = 1024
 )
@@ -0,0 +1,434 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// evalKind is the kind of the values which decides how they are computed and compared.
+type evalKind int
+
+const (
+	kindNull evalKind = iota
+	kindInt
+	kindUint
+	kindDecimal
+	kindFloat
+	kindString
+	kindTemporal
+)
+
+// evalType is the static result type of the expression.
+type evalType struct {
+	typ querypb.Type
+	// decimals is the scale of the decimal, NotFixedDec for the float and the string.
+	decimals int
+}
+
+var (
+	typeNull    = evalType{sqltypes.Null, 0}
+	typeInt64   = evalType{sqltypes.Int64, 0}
+	typeUint64  = evalType{sqltypes.Uint64, 0}
+	typeFloat64 = evalType{sqltypes.Float64, NotFixedDec}
+	typeVarChar = evalType{sqltypes.VarChar, NotFixedDec}
+	typeDate    = evalType{sqltypes.Date, 0}
+	typeDtime   = evalType{sqltypes.Datetime, 0}
+
+	minInt64Dec  = decimal.New(math.MinInt64, 0)
+	maxInt64Dec  = decimal.New(math.MaxInt64, 0)
+	maxUint64Dec = decimal.RequireFromString(strconv.FormatUint(math.MaxUint64, 10))
+)
+
+// kindOf returns the kind of the type.
+func kindOf(typ querypb.Type) evalKind {
+	switch {
+	case typ == sqltypes.Null:
+		return kindNull
+	case sqltypes.IsSigned(typ):
+		return kindInt
+	case sqltypes.IsUnsigned(typ):
+		return kindUint
+	case typ == sqltypes.Decimal:
+		return kindDecimal
+	case sqltypes.IsFloat(typ):
+		return kindFloat
+	case sqltypes.IsTemporal(typ):
+		return kindTemporal
+	}
+	return kindString
+}
+
+// numericKind returns the kind of the type in the numeric context, the strings are
+// converted to double and the temporals are converted to integer.
+func numericKind(typ querypb.Type) evalKind {
+	switch kind := kindOf(typ); kind {
+	case kindString:
+		return kindFloat
+	case kindTemporal:
+		return kindInt
+	default:
+		return kind
+	}
+}
+
+// numericType returns the type of the numeric kind.
+func numericType(kind evalKind, decimals int) evalType {
+	switch kind {
+	case kindInt:
+		return typeInt64
+	case kindUint:
+		return typeUint64
+	case kindDecimal:
+		return evalType{sqltypes.Decimal, decimals}
+	case kindNull:
+		return typeNull
+	}
+	return typeFloat64
+}
+
+// mergeNumericKind returns the kind of the arithmetic on the kinds.
+func mergeNumericKind(k1, k2 evalKind) evalKind {
+	switch {
+	case k1 == kindNull:
+		return k2
+	case k2 == kindNull:
+		return k1
+	case k1 == kindFloat || k2 == kindFloat:
+		return kindFloat
+	case k1 == kindDecimal || k2 == kindDecimal:
+		return kindDecimal
+	case k1 == kindUint || k2 == kindUint:
+		return kindUint
+	}
+	return kindInt
+}
+
+// isBinaryType returns true if the type is a binary string.
+func isBinaryType(typ querypb.Type) bool {
+	return sqltypes.IsBinary(typ)
+}
+
+// stringType returns the string type of the result which is concatenated by the args,
+// the result is binary if any arg is binary.
+func stringType(args ...evalType) evalType {
+	for _, arg := range args {
+		if isBinaryType(arg.typ) {
+			return evalType{sqltypes.VarBinary, NotFixedDec}
+		}
+	}
+	return typeVarChar
+}
+
+// mergeTypes returns the result type of the control flow functions, such as IF/IFNULL/CASE.
+func mergeTypes(args ...evalType) evalType {
+	var types []evalType
+	for _, arg := range args {
+		if arg.typ != sqltypes.Null {
+			types = append(types, arg)
+		}
+	}
+	if len(types) == 0 {
+		return typeNull
+	}
+
+	res := types[0]
+	same, numeric, temporal := true, true, true
+	kind := kindNull
+	for _, t := range types {
+		if t.typ != res.typ {
+			same = false
+		}
+		if t.decimals > res.decimals {
+			res.decimals = t.decimals
+		}
+		switch k := kindOf(t.typ); k {
+		case kindInt, kindUint, kindDecimal, kindFloat:
+			kind = mergeNumericKind(kind, k)
+			temporal = false
+		case kindTemporal:
+			numeric = false
+		default:
+			numeric, temporal = false, false
+		}
+	}
+
+	switch {
+	case same:
+		return res
+	case numeric:
+		return numericType(kind, res.decimals)
+	case temporal:
+		return typeDtime
+	}
+	return stringType(types...)
+}
+
+// compareKind returns the kind used to compare the values of the types.
+func compareKind(t1, t2 evalType) (evalKind, bool) {
+	k1, k2 := kindOf(t1.typ), kindOf(t2.typ)
+	if k1 == kindNull {
+		k1 = k2
+	}
+	if k2 == kindNull {
+		k2 = k1
+	}
+
+	binary := isBinaryType(t1.typ) || isBinaryType(t2.typ)
+	switch {
+	case k1 == kindNull:
+		return kindNull, false
+	case k1 == kindString && k2 == kindString:
+		return kindString, binary
+	case k1 == kindTemporal && (k2 == kindTemporal || k2 == kindString),
+		k2 == kindTemporal && k1 == kindString:
+		return kindTemporal, false
+	case k1 == kindFloat || k2 == kindFloat || k1 == kindString || k2 == kindString:
+		return kindFloat, false
+	}
+	return kindDecimal, false
+}
+
+// compareValues compares the non-null values by the kind.
+func compareValues(kind evalKind, binary bool, v1, v2 sqltypes.Value) int {
+	switch kind {
+	case kindString:
+		return compareString(v1.ToString(), v2.ToString(), binary)
+	case kindTemporal:
+		t1, _, ok1 := parseDatetime(v1.ToString())
+		t2, _, ok2 := parseDatetime(v2.ToString())
+		if ok1 && ok2 {
+			switch {
+			case t1.Before(t2):
+				return -1
+			case t1.After(t2):
+				return 1
+			}
+			return 0
+		}
+		return compareString(v1.ToString(), v2.ToString(), true)
+	case kindFloat:
+		return sqltypes.CompareFloat64(toFloat64(v1), toFloat64(v2))
+	}
+	return toDecimal(v1).Cmp(toDecimal(v2))
+}
+
+// compareString compares the strings, the non-binary strings are compared case-insensitively
+// and the trailing spaces are ignored.
+func compareString(s1, s2 string, binary bool) int {
+	if !binary {
+		s1 = strings.ToLower(strings.TrimRight(s1, " "))
+		s2 = strings.ToLower(strings.TrimRight(s2, " "))
+	}
+	return strings.Compare(s1, s2)
+}
+
+// numberPrefix returns the longest prefix of the string which is a number,
+// as MySQL converts the string to number, such as: '12abc' -> 12.
+func numberPrefix(s string) string {
+	s = strings.TrimLeft(s, " \t\r\n")
+	i, digits := 0, false
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	for ; i < len(s) && isDigit(s[i]); i++ {
+		digits = true
+	}
+	if i < len(s) && s[i] == '.' {
+		for i++; i < len(s) && isDigit(s[i]); i++ {
+			digits = true
+		}
+	}
+	if !digits {
+		return "0"
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		k := j
+		for ; k < len(s) && isDigit(s[k]); k++ {
+		}
+		if k > j {
+			i = k
+		}
+	}
+	return s[:i]
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// temporalDigits converts the temporal to the numeric string, such as:
+// '2019-01-02 10:11:12.5' -> '20190102101112.5'.
+func temporalDigits(s string) string {
+	buf := make([]byte, 0, len(s))
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if isDigit(c) || c == '.' || (c == '-' && i == 0) {
+			buf = append(buf, c)
+		}
+	}
+	if len(buf) == 0 {
+		return "0"
+	}
+	return string(buf)
+}
+
+// toFloat64 converts the value to float64.
+func toFloat64(v sqltypes.Value) float64 {
+	var s string
+	switch kindOf(v.Type()) {
+	case kindNull:
+		return 0
+	case kindInt, kindUint, kindDecimal, kindFloat:
+		s = v.ToString()
+	case kindTemporal:
+		s = temporalDigits(v.ToString())
+	default:
+		s = numberPrefix(v.ToString())
+	}
+	f, _ := strconv.ParseFloat(s, 64)
+	return f
+}
+
+// toDecimal converts the value to decimal.
+func toDecimal(v sqltypes.Value) decimal.Decimal {
+	var s string
+	switch kindOf(v.Type()) {
+	case kindNull:
+		return decimal.Zero
+	case kindInt, kindUint, kindDecimal:
+		s = v.ToString()
+	case kindFloat:
+		return floatToDecimal(toFloat64(v))
+	case kindTemporal:
+		s = temporalDigits(v.ToString())
+	default:
+		s = numberPrefix(v.ToString())
+	}
+	d, err := decimal.NewFromString(s)
+	if err != nil {
+		return decimal.Zero
+	}
+	return d
+}
+
+func floatToDecimal(f float64) decimal.Decimal {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return decimal.Zero
+	}
+	return decimal.NewFromFloat(f)
+}
+
+// toInt64 converts the value to int64, the non-integer is rounded.
+func toInt64(v sqltypes.Value) int64 {
+	switch kindOf(v.Type()) {
+	case kindNull:
+		return 0
+	case kindInt:
+		i, _ := v.ParseInt64()
+		return i
+	case kindUint:
+		u, _ := v.ParseUint64()
+		return int64(u)
+	}
+	d := toDecimal(v).Round(0)
+	switch {
+	case d.LessThan(minInt64Dec):
+		return math.MinInt64
+	case d.GreaterThan(maxInt64Dec):
+		return math.MaxInt64
+	}
+	return d.IntPart()
+}
+
+// toUint64 converts the value to uint64, the negative is converted by two's complement.
+func toUint64(v sqltypes.Value) uint64 {
+	switch kindOf(v.Type()) {
+	case kindUint:
+		u, _ := v.ParseUint64()
+		return u
+	case kindInt:
+		i, _ := v.ParseInt64()
+		return uint64(i)
+	}
+	d := toDecimal(v).Round(0)
+	switch {
+	case d.IsNegative():
+		return uint64(toInt64(v))
+	case d.GreaterThan(maxUint64Dec):
+		return math.MaxUint64
+	}
+	u, _ := strconv.ParseUint(d.String(), 10, 64)
+	return u
+}
+
+// toBool converts the value to bool, the second result is true if the value is NULL.
+func toBool(v sqltypes.Value) (bool, bool) {
+	switch kindOf(v.Type()) {
+	case kindNull:
+		return false, true
+	case kindInt, kindUint, kindDecimal:
+		return !toDecimal(v).IsZero(), false
+	}
+	return toFloat64(v) != 0, false
+}
+
+// boolValue returns 1 if b is true, otherwise 0.
+func boolValue(b bool) sqltypes.Value {
+	if b {
+		return sqltypes.NewInt64(1)
+	}
+	return sqltypes.NewInt64(0)
+}
+
+// decimalValue builds the value by the decimal with the scale.
+func decimalValue(d decimal.Decimal, scale int) sqltypes.Value {
+	return sqltypes.MakeTrusted(sqltypes.Decimal, []byte(d.StringFixed(int32(scale))))
+}
+
+// castTo converts the value to the type.
+func castTo(v sqltypes.Value, t evalType) sqltypes.Value {
+	if v.IsNull() || t.typ == sqltypes.Null || (v.Type() == t.typ && t.typ != sqltypes.Decimal) {
+		return v
+	}
+
+	switch kindOf(t.typ) {
+	case kindInt:
+		return sqltypes.NewInt64(toInt64(v))
+	case kindUint:
+		return sqltypes.NewUint64(toUint64(v))
+	case kindDecimal:
+		return decimalValue(toDecimal(v), t.decimals)
+	case kindFloat:
+		return sqltypes.NewFloat64(toFloat64(v))
+	case kindTemporal:
+		if t.typ == sqltypes.Time {
+			return sqltypes.MakeTrusted(t.typ, v.Raw())
+		}
+		tm, _, ok := parseDatetime(v.ToString())
+		if !ok {
+			return sqltypes.NULL
+		}
+		if t.typ == sqltypes.Date {
+			return sqltypes.MakeTrusted(t.typ, []byte(formatDate(tm)))
+		}
+		return sqltypes.MakeTrusted(t.typ, []byte(formatDatetime(tm)))
+	}
+	return sqltypes.MakeTrusted(t.typ, v.Raw())
+}
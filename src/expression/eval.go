@@ -0,0 +1,1049 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/shopspring/decimal"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Evaluator is the compiled expression, which is evaluated on the rows.
+type Evaluator interface {
+	// Eval used to evaluate the expression on the row.
+	Eval(row []sqltypes.Value) (sqltypes.Value, error)
+	// EvalBool used to evaluate the expression as a condition, NULL is false.
+	EvalBool(row []sqltypes.Value) (bool, error)
+	// FixField used to fix the field by the result type.
+	FixField(field *querypb.Field)
+}
+
+// evalExpr is the node of the compiled expression.
+type evalExpr interface {
+	eval(row []sqltypes.Value) (sqltypes.Value, error)
+	result() evalType
+}
+
+type evaluator struct {
+	expr evalExpr
+}
+
+// NewEvaluator used to compile the expr to the Evaluator, the columns in the expr are
+// resolved to the fields by the name and the table, the aggregate functions are resolved
+// to the fields named by the function text, such as `sum(a)`.
+func NewEvaluator(expr sqlparser.Expr, fields []*querypb.Field) (Evaluator, error) {
+	return NewEvaluatorInLocation(expr, fields, time.Local)
+}
+
+// NewEvaluatorInLocation is the same as NewEvaluator, the time functions such as NOW() are
+// evaluated in the loc, which is the time zone of the session.
+func NewEvaluatorInLocation(expr sqlparser.Expr, fields []*querypb.Field, loc *time.Location) (Evaluator, error) {
+	c := &compiler{fields: fields, now: time.Now().In(loc)}
+	e, err := c.compile(expr)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := e.(*intervalExpr); ok {
+		return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(expr))
+	}
+	return &evaluator{expr: e}, nil
+}
+
+// Eval used to evaluate the expression on the row.
+func (e *evaluator) Eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return e.expr.eval(row)
+}
+
+// EvalBool used to evaluate the expression as a condition, NULL is false.
+func (e *evaluator) EvalBool(row []sqltypes.Value) (bool, error) {
+	v, err := e.expr.eval(row)
+	if err != nil {
+		return false, err
+	}
+	ok, isNull := toBool(v)
+	return ok && !isNull, nil
+}
+
+// FixField used to fix the field by the result type.
+func (e *evaluator) FixField(field *querypb.Field) {
+	fixField(field, e.expr.result())
+}
+
+// fixField used to fix the type, decimals, charset and flags of the field by the type.
+func fixField(field *querypb.Field, res evalType) {
+	field.Type = res.typ
+	field.Decimals = uint32(res.decimals)
+	field.Charset = 63
+	field.Flags = uint32(querypb.MySqlFlag_BINARY_FLAG)
+	switch kindOf(res.typ) {
+	case kindInt, kindUint, kindDecimal, kindFloat:
+		field.Flags |= uint32(querypb.MySqlFlag_NUM_FLAG)
+	case kindString:
+		if !isBinaryType(res.typ) {
+			field.Charset = 33
+			field.Flags = 0
+		}
+	}
+}
+
+// compiler used to compile the sqlparser.Expr.
+type compiler struct {
+	fields []*querypb.Field
+	// now is the time of the statement, used by NOW() etc.
+	now time.Time
+}
+
+func (c *compiler) compile(expr sqlparser.Expr) (evalExpr, error) {
+	switch node := expr.(type) {
+	case *sqlparser.ParenExpr:
+		return c.compile(node.Expr)
+	case *sqlparser.CollateExpr:
+		return c.compile(node.Expr)
+	case *sqlparser.ColName:
+		return c.compileColumn(node)
+	case *sqlparser.SQLVal:
+		return compileSQLVal(node)
+	case *sqlparser.NullVal:
+		return &constExpr{val: sqltypes.NULL, typ: typeNull}, nil
+	case sqlparser.BoolVal:
+		return &constExpr{val: boolValue(bool(node)), typ: typeInt64}, nil
+	case *sqlparser.AndExpr:
+		return c.compileLogic(true, node.Left, node.Right)
+	case *sqlparser.OrExpr:
+		return c.compileLogic(false, node.Left, node.Right)
+	case *sqlparser.NotExpr:
+		e, err := c.compile(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &notExpr{expr: e}, nil
+	case *sqlparser.ComparisonExpr:
+		return c.compileComparison(node)
+	case *sqlparser.RangeCond:
+		return c.compileRange(node)
+	case *sqlparser.IsExpr:
+		e, err := c.compile(node.Expr)
+		if err != nil {
+			return nil, err
+		}
+		return &isExpr{op: node.Operator, expr: e}, nil
+	case *sqlparser.BinaryExpr:
+		return c.compileBinary(node)
+	case *sqlparser.UnaryExpr:
+		return c.compileUnary(node)
+	case *sqlparser.IntervalExpr:
+		return c.compileInterval(node)
+	case *sqlparser.CaseExpr:
+		return c.compileCase(node)
+	case *sqlparser.ConvertExpr:
+		return c.compileConvert(node)
+	case *sqlparser.FuncExpr:
+		return c.compileFunc(node)
+	case *sqlparser.GroupConcatExpr:
+		return c.compileAggregate(node)
+	}
+	return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(expr))
+}
+
+func (c *compiler) compileExprs(exprs ...sqlparser.Expr) ([]evalExpr, error) {
+	var res []evalExpr
+	for _, expr := range exprs {
+		e, err := c.compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := e.(*intervalExpr); ok {
+			return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(expr))
+		}
+		res = append(res, e)
+	}
+	return res, nil
+}
+
+// compileColumn resolves the column to the index of the fields.
+func (c *compiler) compileColumn(col *sqlparser.ColName) (evalExpr, error) {
+	name := col.Name.String()
+	table := col.Qualifier.Name.String()
+	for i, field := range c.fields {
+		if strings.EqualFold(field.Name, name) && (table == "" || field.Table == table) {
+			return &columnExpr{index: i, typ: evalType{field.Type, int(field.Decimals)}}, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported: unknown.column.'%s'.in.expression", sqlparser.String(col))
+}
+
+// compileAggregate resolves the aggregate function to the field named by its text,
+// which has been computed by the aggregation.
+func (c *compiler) compileAggregate(node sqlparser.Expr) (evalExpr, error) {
+	text := sqlparser.String(node)
+	for i, field := range c.fields {
+		if field.Name == text {
+			return &columnExpr{index: i, typ: evalType{field.Type, int(field.Decimals)}}, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported: aggregate.'%s'.in.expression", text)
+}
+
+// compileSQLVal used to compile the literal.
+func compileSQLVal(val *sqlparser.SQLVal) (evalExpr, error) {
+	s := string(val.Val)
+	switch val.Type {
+	case sqlparser.StrVal:
+		return &constExpr{val: sqltypes.NewVarChar(s), typ: typeVarChar}, nil
+	case sqlparser.IntVal:
+		if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+			return &constExpr{val: sqltypes.NewInt64(i), typ: typeInt64}, nil
+		}
+		if u, err := strconv.ParseUint(s, 10, 64); err == nil {
+			return &constExpr{val: sqltypes.NewUint64(u), typ: typeUint64}, nil
+		}
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		return &constExpr{val: decimalValue(d, 0), typ: evalType{sqltypes.Decimal, 0}}, nil
+	case sqlparser.FloatVal:
+		if strings.ContainsAny(s, "eE") {
+			f, err := strconv.ParseFloat(s, 64)
+			if err != nil {
+				return nil, err
+			}
+			return &constExpr{val: sqltypes.NewFloat64(f), typ: typeFloat64}, nil
+		}
+		d, err := decimal.NewFromString(s)
+		if err != nil {
+			return nil, err
+		}
+		scale := 0
+		if idx := strings.IndexByte(s, '.'); idx >= 0 {
+			scale = len(s) - idx - 1
+		}
+		return &constExpr{val: decimalValue(d, scale), typ: evalType{sqltypes.Decimal, scale}}, nil
+	case sqlparser.HexNum, sqlparser.HexVal:
+		v, err := val.HexDecode()
+		if err != nil {
+			return nil, err
+		}
+		return &constExpr{val: sqltypes.MakeTrusted(sqltypes.VarBinary, v), typ: evalType{sqltypes.VarBinary, NotFixedDec}}, nil
+	}
+	return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(val))
+}
+
+// constExpr is the literal.
+type constExpr struct {
+	val sqltypes.Value
+	typ evalType
+}
+
+func (e *constExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return e.val, nil
+}
+
+func (e *constExpr) result() evalType {
+	return e.typ
+}
+
+// columnExpr is the column of the row.
+type columnExpr struct {
+	index int
+	typ   evalType
+}
+
+func (e *columnExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return row[e.index], nil
+}
+
+func (e *columnExpr) result() evalType {
+	return e.typ
+}
+
+// logicExpr is the AND/OR with the three-valued logic.
+type logicExpr struct {
+	and         bool
+	left, right evalExpr
+}
+
+func (c *compiler) compileLogic(and bool, left, right sqlparser.Expr) (evalExpr, error) {
+	args, err := c.compileExprs(left, right)
+	if err != nil {
+		return nil, err
+	}
+	return &logicExpr{and: and, left: args[0], right: args[1]}, nil
+}
+
+func (e *logicExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	lv, err := e.left.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	lb, lnull := toBool(lv)
+	// Short-circuit: FALSE AND x, TRUE OR x.
+	if !lnull && lb != e.and {
+		return boolValue(lb), nil
+	}
+
+	rv, err := e.right.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	rb, rnull := toBool(rv)
+	switch {
+	case !rnull && rb != e.and:
+		return boolValue(rb), nil
+	case lnull || rnull:
+		return sqltypes.NULL, nil
+	}
+	return boolValue(e.and), nil
+}
+
+func (e *logicExpr) result() evalType {
+	return typeInt64
+}
+
+// notExpr is the NOT.
+type notExpr struct {
+	expr evalExpr
+}
+
+func (e *notExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	b, null := toBool(v)
+	if null {
+		return sqltypes.NULL, nil
+	}
+	return boolValue(!b), nil
+}
+
+func (e *notExpr) result() evalType {
+	return typeInt64
+}
+
+// isExpr is the IS [NOT] NULL/TRUE/FALSE.
+type isExpr struct {
+	op   string
+	expr evalExpr
+}
+
+func (e *isExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	b, null := toBool(v)
+	switch e.op {
+	case sqlparser.IsNullStr:
+		return boolValue(null), nil
+	case sqlparser.IsNotNullStr:
+		return boolValue(!null), nil
+	case sqlparser.IsTrueStr:
+		return boolValue(!null && b), nil
+	case sqlparser.IsNotTrueStr:
+		return boolValue(null || !b), nil
+	case sqlparser.IsFalseStr:
+		return boolValue(!null && !b), nil
+	}
+	return boolValue(null || b), nil
+}
+
+func (e *isExpr) result() evalType {
+	return typeInt64
+}
+
+// compareExpr is the comparison of two values.
+type compareExpr struct {
+	op          string
+	left, right evalExpr
+	kind        evalKind
+	binary      bool
+}
+
+func (c *compiler) compileComparison(node *sqlparser.ComparisonExpr) (evalExpr, error) {
+	switch node.Operator {
+	case sqlparser.InStr, sqlparser.NotInStr:
+		return c.compileIn(node)
+	case sqlparser.LikeStr, sqlparser.NotLikeStr:
+		return c.compileLike(node)
+	case sqlparser.RegexpStr, sqlparser.NotRegexpStr:
+		return c.compileRegexp(node)
+	}
+
+	args, err := c.compileExprs(node.Left, node.Right)
+	if err != nil {
+		return nil, err
+	}
+	return newCompareExpr(node.Operator, args[0], args[1]), nil
+}
+
+func newCompareExpr(op string, left, right evalExpr) *compareExpr {
+	kind, binary := compareKind(left.result(), right.result())
+	return &compareExpr{op: op, left: left, right: right, kind: kind, binary: binary}
+}
+
+func (e *compareExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	lv, err := e.left.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	rv, err := e.right.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+
+	if lv.IsNull() || rv.IsNull() {
+		if e.op == sqlparser.NullSafeEqualStr {
+			return boolValue(lv.IsNull() && rv.IsNull()), nil
+		}
+		return sqltypes.NULL, nil
+	}
+
+	cmp := compareValues(e.kind, e.binary, lv, rv)
+	switch e.op {
+	case sqlparser.EqualStr, sqlparser.NullSafeEqualStr:
+		return boolValue(cmp == 0), nil
+	case sqlparser.LessThanStr:
+		return boolValue(cmp < 0), nil
+	case sqlparser.GreaterThanStr:
+		return boolValue(cmp > 0), nil
+	case sqlparser.LessEqualStr:
+		return boolValue(cmp <= 0), nil
+	case sqlparser.GreaterEqualStr:
+		return boolValue(cmp >= 0), nil
+	}
+	return boolValue(cmp != 0), nil
+}
+
+func (e *compareExpr) result() evalType {
+	return typeInt64
+}
+
+// compileRange compiles the BETWEEN to the comparisons.
+// eg: a between b and c -> a >= b and a <= c,
+// a not between b and c -> a < b or a > c.
+func (c *compiler) compileRange(node *sqlparser.RangeCond) (evalExpr, error) {
+	args, err := c.compileExprs(node.Left, node.From, node.To)
+	if err != nil {
+		return nil, err
+	}
+	if node.Operator == sqlparser.BetweenStr {
+		return &logicExpr{
+			and:   true,
+			left:  newCompareExpr(sqlparser.GreaterEqualStr, args[0], args[1]),
+			right: newCompareExpr(sqlparser.LessEqualStr, args[0], args[2]),
+		}, nil
+	}
+	return &logicExpr{
+		left:  newCompareExpr(sqlparser.LessThanStr, args[0], args[1]),
+		right: newCompareExpr(sqlparser.GreaterThanStr, args[0], args[2]),
+	}, nil
+}
+
+// inExpr is the [NOT] IN.
+type inExpr struct {
+	not  bool
+	left evalExpr
+	list []*compareExpr
+}
+
+func (c *compiler) compileIn(node *sqlparser.ComparisonExpr) (evalExpr, error) {
+	tuple, ok := node.Right.(sqlparser.ValTuple)
+	if !ok {
+		return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+	}
+	if _, ok := node.Left.(sqlparser.ValTuple); ok {
+		return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+	}
+
+	left, err := c.compile(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	list, err := c.compileExprs(tuple...)
+	if err != nil {
+		return nil, err
+	}
+	in := &inExpr{not: node.Operator == sqlparser.NotInStr, left: left}
+	for _, item := range list {
+		in.list = append(in.list, newCompareExpr(sqlparser.EqualStr, left, item))
+	}
+	return in, nil
+}
+
+func (e *inExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	lv, err := e.left.eval(row)
+	if err != nil || lv.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	hasNull := false
+	for _, cmp := range e.list {
+		v, err := cmp.right.eval(row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if v.IsNull() {
+			hasNull = true
+			continue
+		}
+		if compareValues(cmp.kind, cmp.binary, lv, v) == 0 {
+			return boolValue(!e.not), nil
+		}
+	}
+	if hasNull {
+		return sqltypes.NULL, nil
+	}
+	return boolValue(e.not), nil
+}
+
+func (e *inExpr) result() evalType {
+	return typeInt64
+}
+
+// matchExpr is the [NOT] LIKE and [NOT] REGEXP.
+type matchExpr struct {
+	not         bool
+	left, right evalExpr
+	binary      bool
+	// escape is the escape char of the LIKE, -1 if the REGEXP.
+	escape rune
+	// re is the compiled pattern if the pattern is constant.
+	re *regexp.Regexp
+}
+
+func (c *compiler) compileLike(node *sqlparser.ComparisonExpr) (evalExpr, error) {
+	args, err := c.compileExprs(node.Left, node.Right)
+	if err != nil {
+		return nil, err
+	}
+	e := &matchExpr{
+		not:    node.Operator == sqlparser.NotLikeStr,
+		left:   args[0],
+		right:  args[1],
+		binary: isBinaryType(args[0].result().typ) || isBinaryType(args[1].result().typ),
+		escape: '\\',
+	}
+	if node.Escape != nil {
+		esc, ok := node.Escape.(*sqlparser.SQLVal)
+		if !ok || esc.Type != sqlparser.StrVal {
+			return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+		}
+		e.escape = 0
+		if runes := []rune(string(esc.Val)); len(runes) > 0 {
+			e.escape = runes[0]
+		}
+	}
+	return e, e.prepare()
+}
+
+func (c *compiler) compileRegexp(node *sqlparser.ComparisonExpr) (evalExpr, error) {
+	args, err := c.compileExprs(node.Left, node.Right)
+	if err != nil {
+		return nil, err
+	}
+	e := &matchExpr{
+		not:    node.Operator == sqlparser.NotRegexpStr,
+		left:   args[0],
+		right:  args[1],
+		binary: isBinaryType(args[0].result().typ) || isBinaryType(args[1].result().typ),
+		escape: -1,
+	}
+	return e, e.prepare()
+}
+
+// prepare compiles the constant pattern.
+func (e *matchExpr) prepare() error {
+	if cons, ok := e.right.(*constExpr); ok && !cons.val.IsNull() {
+		re, err := e.compilePattern(cons.val.ToString())
+		if err != nil {
+			return err
+		}
+		e.re = re
+	}
+	return nil
+}
+
+func (e *matchExpr) compilePattern(pattern string) (*regexp.Regexp, error) {
+	prefix := "(?s)"
+	if !e.binary {
+		prefix = "(?is)"
+	}
+	if e.escape < 0 {
+		re, err := regexp.Compile(prefix + pattern)
+		if err != nil {
+			return nil, errors.Errorf("Got error '%v' from regexp", err)
+		}
+		return re, nil
+	}
+
+	var buf strings.Builder
+	buf.WriteString(prefix)
+	buf.WriteString("^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; {
+		case r == e.escape && i+1 < len(runes):
+			i++
+			buf.WriteString(regexp.QuoteMeta(string(runes[i])))
+		case r == '%':
+			buf.WriteString(".*")
+		case r == '_':
+			buf.WriteString(".")
+		default:
+			buf.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	buf.WriteString("$")
+	return regexp.Compile(buf.String())
+}
+
+func (e *matchExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	lv, err := e.left.eval(row)
+	if err != nil || lv.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	re := e.re
+	if re == nil {
+		rv, err := e.right.eval(row)
+		if err != nil || rv.IsNull() {
+			return sqltypes.NULL, err
+		}
+		if re, err = e.compilePattern(rv.ToString()); err != nil {
+			return sqltypes.NULL, err
+		}
+	}
+	return boolValue(re.MatchString(lv.ToString()) != e.not), nil
+}
+
+func (e *matchExpr) result() evalType {
+	return typeInt64
+}
+
+// arithExpr is the arithmetic and the bit operation.
+type arithExpr struct {
+	op          string
+	left, right evalExpr
+	// kind is the kind of the computation.
+	kind evalKind
+	typ  evalType
+	text string
+}
+
+func (c *compiler) compileBinary(node *sqlparser.BinaryExpr) (evalExpr, error) {
+	left, err := c.compile(node.Left)
+	if err != nil {
+		return nil, err
+	}
+	right, err := c.compile(node.Right)
+	if err != nil {
+		return nil, err
+	}
+
+	// date + interval 1 day.
+	if interval, ok := right.(*intervalExpr); ok {
+		if node.Operator != sqlparser.PlusStr && node.Operator != sqlparser.MinusStr {
+			return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+		}
+		return newDateAddExpr(left, interval, node.Operator == sqlparser.MinusStr)
+	}
+	if interval, ok := left.(*intervalExpr); ok && node.Operator == sqlparser.PlusStr {
+		return newDateAddExpr(right, interval, false)
+	}
+	if _, ok := left.(*intervalExpr); ok {
+		return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+	}
+	return newArithExpr(node.Operator, left, right, sqlparser.String(node)), nil
+}
+
+func newArithExpr(op string, left, right evalExpr, text string) *arithExpr {
+	lt, rt := left.result(), right.result()
+	lk, rk := numericKind(lt.typ), numericKind(rt.typ)
+	ls, rs := lt.decimals, rt.decimals
+	if lk != kindDecimal {
+		ls = 0
+	}
+	if rk != kindDecimal {
+		rs = 0
+	}
+
+	e := &arithExpr{op: op, left: left, right: right, text: text}
+	switch op {
+	case sqlparser.DivStr:
+		e.kind = kindDecimal
+		if lk == kindFloat || rk == kindFloat {
+			e.kind = kindFloat
+		}
+		e.typ = numericType(e.kind, minInt(ls+DivPrecisionIncrement, MaxDecimalScale))
+	case sqlparser.IntDivStr:
+		e.kind = kindInt
+		if lk == kindUint || rk == kindUint {
+			e.kind = kindUint
+		}
+		e.typ = numericType(e.kind, 0)
+	case sqlparser.BitAndStr, sqlparser.BitOrStr, sqlparser.BitXorStr, sqlparser.ShiftLeftStr, sqlparser.ShiftRightStr:
+		e.kind = kindUint
+		e.typ = typeUint64
+	default:
+		e.kind = mergeNumericKind(lk, rk)
+		if e.kind == kindNull {
+			e.kind = kindFloat
+		}
+		scale := maxInt(ls, rs)
+		if op == sqlparser.MultStr {
+			scale = minInt(ls+rs, MaxDecimalScale)
+		}
+		e.typ = numericType(e.kind, scale)
+	}
+	return e
+}
+
+func (e *arithExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	lv, err := e.left.eval(row)
+	if err != nil || lv.IsNull() {
+		return sqltypes.NULL, err
+	}
+	rv, err := e.right.eval(row)
+	if err != nil || rv.IsNull() {
+		return sqltypes.NULL, err
+	}
+
+	switch e.op {
+	case sqlparser.BitAndStr, sqlparser.BitOrStr, sqlparser.BitXorStr, sqlparser.ShiftLeftStr, sqlparser.ShiftRightStr:
+		return e.evalBit(toUint64(lv), toUint64(rv)), nil
+	}
+	if e.kind == kindFloat {
+		return e.evalFloat(toFloat64(lv), toFloat64(rv))
+	}
+
+	var d decimal.Decimal
+	l, r := toDecimal(lv), toDecimal(rv)
+	switch e.op {
+	case sqlparser.PlusStr:
+		d = l.Add(r)
+	case sqlparser.MinusStr:
+		d = l.Sub(r)
+	case sqlparser.MultStr:
+		d = l.Mul(r)
+	case sqlparser.DivStr:
+		if r.IsZero() {
+			return sqltypes.NULL, nil
+		}
+		d = l.DivRound(r, int32(e.typ.decimals))
+	case sqlparser.IntDivStr:
+		if r.IsZero() {
+			return sqltypes.NULL, nil
+		}
+		d, _ = l.QuoRem(r, 0)
+	case sqlparser.ModStr:
+		if r.IsZero() {
+			return sqltypes.NULL, nil
+		}
+		d = l.Mod(r)
+	}
+	return fromDecimal(d, e.typ, e.text)
+}
+
+func (e *arithExpr) evalFloat(l, r float64) (sqltypes.Value, error) {
+	var f float64
+	switch e.op {
+	case sqlparser.PlusStr:
+		f = l + r
+	case sqlparser.MinusStr:
+		f = l - r
+	case sqlparser.MultStr:
+		f = l * r
+	case sqlparser.DivStr:
+		if r == 0 {
+			return sqltypes.NULL, nil
+		}
+		f = l / r
+	case sqlparser.ModStr:
+		if r == 0 {
+			return sqltypes.NULL, nil
+		}
+		f = math.Mod(l, r)
+	}
+	if math.IsInf(f, 0) || math.IsNaN(f) {
+		return sqltypes.NULL, errors.Errorf("DOUBLE value is out of range in '%s'", e.text)
+	}
+	return sqltypes.NewFloat64(f), nil
+}
+
+func (e *arithExpr) evalBit(l, r uint64) sqltypes.Value {
+	var u uint64
+	switch e.op {
+	case sqlparser.BitAndStr:
+		u = l & r
+	case sqlparser.BitOrStr:
+		u = l | r
+	case sqlparser.BitXorStr:
+		u = l ^ r
+	case sqlparser.ShiftLeftStr:
+		if r < 64 {
+			u = l << r
+		}
+	case sqlparser.ShiftRightStr:
+		if r < 64 {
+			u = l >> r
+		}
+	}
+	return sqltypes.NewUint64(u)
+}
+
+func (e *arithExpr) result() evalType {
+	return e.typ
+}
+
+// fromDecimal builds the value of the type by the decimal, returns error if out of range.
+func fromDecimal(d decimal.Decimal, typ evalType, text string) (sqltypes.Value, error) {
+	switch typ.typ {
+	case sqltypes.Int64:
+		if d.LessThan(minInt64Dec) || d.GreaterThan(maxInt64Dec) {
+			return sqltypes.NULL, errors.Errorf("BIGINT value is out of range in '%s'", text)
+		}
+		return sqltypes.MakeTrusted(sqltypes.Int64, []byte(d.String())), nil
+	case sqltypes.Uint64:
+		if d.IsNegative() || d.GreaterThan(maxUint64Dec) {
+			return sqltypes.NULL, errors.Errorf("BIGINT UNSIGNED value is out of range in '%s'", text)
+		}
+		return sqltypes.MakeTrusted(sqltypes.Uint64, []byte(d.String())), nil
+	}
+	return decimalValue(d, typ.decimals), nil
+}
+
+// unaryExpr is the unary minus and the bit inversion.
+type unaryExpr struct {
+	op   string
+	expr evalExpr
+	typ  evalType
+	text string
+}
+
+func (c *compiler) compileUnary(node *sqlparser.UnaryExpr) (evalExpr, error) {
+	args, err := c.compileExprs(node.Expr)
+	if err != nil {
+		return nil, err
+	}
+	expr := args[0]
+	switch node.Operator {
+	case sqlparser.UPlusStr:
+		return expr, nil
+	case sqlparser.BangStr:
+		return &notExpr{expr: expr}, nil
+	case sqlparser.TildaStr:
+		return &unaryExpr{op: node.Operator, expr: expr, typ: typeUint64}, nil
+	}
+
+	res := expr.result()
+	kind := numericKind(res.typ)
+	if kind == kindUint {
+		kind = kindInt
+	}
+	return &unaryExpr{op: node.Operator, expr: expr, typ: numericType(kind, res.decimals), text: sqlparser.String(node)}, nil
+}
+
+func (e *unaryExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+	switch {
+	case e.op == sqlparser.TildaStr:
+		return sqltypes.NewUint64(^toUint64(v)), nil
+	case e.typ.typ == sqltypes.Float64:
+		return sqltypes.NewFloat64(-toFloat64(v)), nil
+	}
+	return fromDecimal(toDecimal(v).Neg(), e.typ, e.text)
+}
+
+func (e *unaryExpr) result() evalType {
+	return e.typ
+}
+
+// caseExpr is the CASE.
+type caseExpr struct {
+	base  evalExpr
+	conds []evalExpr
+	vals  []evalExpr
+	els   evalExpr
+	typ   evalType
+}
+
+func (c *compiler) compileCase(node *sqlparser.CaseExpr) (evalExpr, error) {
+	var err error
+	e := &caseExpr{}
+	if node.Expr != nil {
+		if e.base, err = c.compile(node.Expr); err != nil {
+			return nil, err
+		}
+	}
+
+	var types []evalType
+	for _, when := range node.Whens {
+		args, err := c.compileExprs(when.Cond, when.Val)
+		if err != nil {
+			return nil, err
+		}
+		cond := args[0]
+		if e.base != nil {
+			cond = newCompareExpr(sqlparser.EqualStr, e.base, cond)
+		}
+		e.conds = append(e.conds, cond)
+		e.vals = append(e.vals, args[1])
+		types = append(types, args[1].result())
+	}
+	if node.Else != nil {
+		if e.els, err = c.compile(node.Else); err != nil {
+			return nil, err
+		}
+		types = append(types, e.els.result())
+	}
+	e.typ = mergeTypes(types...)
+	return e, nil
+}
+
+func (e *caseExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	for i, cond := range e.conds {
+		v, err := cond.eval(row)
+		if err != nil {
+			return sqltypes.NULL, err
+		}
+		if b, null := toBool(v); b && !null {
+			return evalCast(e.vals[i], row, e.typ)
+		}
+	}
+	if e.els == nil {
+		return sqltypes.NULL, nil
+	}
+	return evalCast(e.els, row, e.typ)
+}
+
+func (e *caseExpr) result() evalType {
+	return e.typ
+}
+
+// evalCast evaluates the expr and converts the result to the type.
+func evalCast(expr evalExpr, row []sqltypes.Value, typ evalType) (sqltypes.Value, error) {
+	v, err := expr.eval(row)
+	if err != nil {
+		return sqltypes.NULL, err
+	}
+	return castTo(v, typ), nil
+}
+
+// convertExpr is the CAST/CONVERT.
+type convertExpr struct {
+	expr evalExpr
+	typ  evalType
+}
+
+func (c *compiler) compileConvert(node *sqlparser.ConvertExpr) (evalExpr, error) {
+	args, err := c.compileExprs(node.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &convertExpr{expr: args[0]}
+	switch typ := strings.ToLower(node.Type.Type); {
+	case typ == "binary":
+		e.typ = evalType{sqltypes.VarBinary, NotFixedDec}
+	case typ == "char" || typ == "nchar":
+		e.typ = typeVarChar
+	case typ == "date":
+		e.typ = typeDate
+	case typ == "datetime":
+		e.typ = typeDtime
+	case typ == "time":
+		e.typ = evalType{sqltypes.Time, 0}
+	case strings.HasPrefix(typ, "signed"):
+		e.typ = typeInt64
+	case strings.HasPrefix(typ, "unsigned"):
+		e.typ = typeUint64
+	case typ == "decimal":
+		scale := 0
+		if node.Type.Scale != nil {
+			if scale, err = strconv.Atoi(string(node.Type.Scale.Val)); err != nil {
+				return nil, err
+			}
+		}
+		e.typ = evalType{sqltypes.Decimal, minInt(scale, MaxDecimalScale)}
+	default:
+		return nil, errors.Errorf("unsupported: expression.'%s'", sqlparser.String(node))
+	}
+	return e, nil
+}
+
+func (e *convertExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	v, err := e.expr.eval(row)
+	if err != nil || v.IsNull() {
+		return sqltypes.NULL, err
+	}
+	if e.typ.typ == sqltypes.Time {
+		tm, ok := parseClock(v.ToString())
+		if !ok {
+			return sqltypes.NULL, nil
+		}
+		return sqltypes.MakeTrusted(sqltypes.Time, []byte(tm)), nil
+	}
+	return castTo(v, e.typ), nil
+}
+
+func (e *convertExpr) result() evalType {
+	return e.typ
+}
+
+// intervalExpr is the INTERVAL, only used as the argument of the DATE_ADD/DATE_SUB.
+type intervalExpr struct {
+	expr evalExpr
+	unit string
+}
+
+func (c *compiler) compileInterval(node *sqlparser.IntervalExpr) (evalExpr, error) {
+	unit := strings.ToLower(node.Unit.String())
+	if _, ok := intervalUnits[unit]; !ok {
+		return nil, errors.Errorf("unsupported: interval.unit.'%s'", unit)
+	}
+	args, err := c.compileExprs(node.Expr)
+	if err != nil {
+		return nil, err
+	}
+	return &intervalExpr{expr: args[0], unit: unit}, nil
+}
+
+func (e *intervalExpr) eval(row []sqltypes.Value) (sqltypes.Value, error) {
+	return e.expr.eval(row)
+}
+
+func (e *intervalExpr) result() evalType {
+	return e.expr.result()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
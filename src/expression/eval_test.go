@@ -0,0 +1,330 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	evalFields = []*querypb.Field{
+		{Name: "a", Table: "t", Type: querypb.Type_INT64},
+		{Name: "b", Table: "t", Type: querypb.Type_INT32},
+		{Name: "d", Table: "t", Type: querypb.Type_DECIMAL, Decimals: 2},
+		{Name: "f", Table: "t", Type: querypb.Type_FLOAT64, Decimals: 31},
+		{Name: "s", Table: "t", Type: querypb.Type_VARCHAR},
+		{Name: "bs", Table: "t", Type: querypb.Type_VARBINARY},
+		{Name: "n", Table: "t", Type: querypb.Type_INT64},
+		{Name: "dt", Table: "t", Type: querypb.Type_DATETIME},
+		{Name: "dd", Table: "t", Type: querypb.Type_DATE},
+		{Name: "u", Table: "t", Type: querypb.Type_UINT64},
+		{Name: "a", Table: "t2", Type: querypb.Type_INT64},
+	}
+	evalRow = []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_INT64, []byte("10")),
+		sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3")),
+		sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("1.50")),
+		sqltypes.MakeTrusted(querypb.Type_FLOAT64, []byte("2.5")),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Hello")),
+		sqltypes.MakeTrusted(querypb.Type_VARBINARY, []byte("Hello")),
+		sqltypes.NULL,
+		sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte("2019-01-31 10:11:12")),
+		sqltypes.MakeTrusted(querypb.Type_DATE, []byte("2019-01-31")),
+		sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("18446744073709551615")),
+		sqltypes.MakeTrusted(querypb.Type_INT64, []byte("7")),
+	}
+)
+
+type evalCase struct {
+	expr string
+	res  string
+	typ  querypb.Type
+}
+
+func parseExpr(t *testing.T, expr string) sqlparser.Expr {
+	node, err := sqlparser.Parse("select " + expr + " from t")
+	assert.Nil(t, err)
+	return node.(*sqlparser.Select).SelectExprs[0].(*sqlparser.AliasedExpr).Expr
+}
+
+func testEvalCases(t *testing.T, cases []evalCase) {
+	for _, c := range cases {
+		e, err := NewEvaluator(parseExpr(t, c.expr), evalFields)
+		assert.Nil(t, err, c.expr)
+		v, err := e.Eval(evalRow)
+		assert.Nil(t, err, c.expr)
+		res := v.ToString()
+		if v.IsNull() {
+			res = "NULL"
+		}
+		assert.Equal(t, c.res, res, c.expr)
+
+		field := &querypb.Field{}
+		e.FixField(field)
+		assert.Equal(t, c.typ, field.Type, c.expr)
+	}
+}
+
+func TestEvalArithmetic(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"a + b", "13", querypb.Type_INT64},
+		{"a - b * 2", "4", querypb.Type_INT64},
+		{"a / b", "3.3333", querypb.Type_DECIMAL},
+		{"a / 0", "NULL", querypb.Type_DECIMAL},
+		{"a div b", "3", querypb.Type_INT64},
+		{"a % b", "1", querypb.Type_INT64},
+		{"mod(-a, b)", "-1", querypb.Type_INT64},
+		{"a + d", "11.50", querypb.Type_DECIMAL},
+		{"d * d", "2.2500", querypb.Type_DECIMAL},
+		{"d / b", "0.500000", querypb.Type_DECIMAL},
+		{"a + f", "12.5", querypb.Type_FLOAT64},
+		{"a / f", "4", querypb.Type_FLOAT64},
+		{"'3abc' + 1", "4", querypb.Type_FLOAT64},
+		{"a + n", "NULL", querypb.Type_INT64},
+		{"1.5e1 * 2", "30", querypb.Type_FLOAT64},
+		{"-a", "-10", querypb.Type_INT64},
+		{"-d", "-1.50", querypb.Type_DECIMAL},
+		{"+a", "10", querypb.Type_INT64},
+		{"u - 1", "18446744073709551614", querypb.Type_UINT64},
+		{"a & b", "2", querypb.Type_UINT64},
+		{"a | b", "11", querypb.Type_UINT64},
+		{"a ^ b", "9", querypb.Type_UINT64},
+		{"a << 2", "40", querypb.Type_UINT64},
+		{"a >> 1", "5", querypb.Type_UINT64},
+		{"~0", "18446744073709551615", querypb.Type_UINT64},
+		{"t2.a * t.a", "70", querypb.Type_INT64},
+		{"dd + 0", "20190131", querypb.Type_INT64},
+		{"12345678901234567890 + 1", "12345678901234567891", querypb.Type_UINT64},
+	})
+}
+
+func TestEvalArithmeticError(t *testing.T) {
+	cases := []struct {
+		expr string
+		err  string
+	}{
+		{"9223372036854775807 + a", "BIGINT value is out of range in '9223372036854775807 + a'"},
+		{"u + 1", "BIGINT UNSIGNED value is out of range in 'u + 1'"},
+		{"0 - u", "BIGINT UNSIGNED value is out of range in '0 - u'"},
+		{"1e308 * 10", "DOUBLE value is out of range in '1e308 * 10'"},
+	}
+	for _, c := range cases {
+		e, err := NewEvaluator(parseExpr(t, c.expr), evalFields)
+		assert.Nil(t, err)
+		_, err = e.Eval(evalRow)
+		assert.EqualError(t, err, c.err)
+	}
+}
+
+func TestEvalComparison(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"a > b", "1", querypb.Type_INT64},
+		{"a = 10.0", "1", querypb.Type_INT64},
+		{"a != b", "1", querypb.Type_INT64},
+		{"a <= b", "0", querypb.Type_INT64},
+		{"a >= '10'", "1", querypb.Type_INT64},
+		{"a = n", "NULL", querypb.Type_INT64},
+		{"n <=> null", "1", querypb.Type_INT64},
+		{"a <=> n", "0", querypb.Type_INT64},
+		{"s = 'hello '", "1", querypb.Type_INT64},
+		{"bs = 'hello'", "0", querypb.Type_INT64},
+		{"s < 'world'", "1", querypb.Type_INT64},
+		{"dt > dd", "1", querypb.Type_INT64},
+		{"dd = '2019-01-31 00:00:00'", "1", querypb.Type_INT64},
+		{"dt < '2019-02-01'", "1", querypb.Type_INT64},
+		{"a in (1, 10)", "1", querypb.Type_INT64},
+		{"a in (1, null)", "NULL", querypb.Type_INT64},
+		{"a not in (1, 2)", "1", querypb.Type_INT64},
+		{"n in (1, 2)", "NULL", querypb.Type_INT64},
+		{"a between b and 10", "1", querypb.Type_INT64},
+		{"a not between b and 10", "0", querypb.Type_INT64},
+		{"s like 'he%'", "1", querypb.Type_INT64},
+		{"s like 'h_llo'", "1", querypb.Type_INT64},
+		{"s not like '%x%'", "1", querypb.Type_INT64},
+		{"bs like 'he%'", "0", querypb.Type_INT64},
+		{"'a%' like 'a!%' escape '!'", "1", querypb.Type_INT64},
+		{"'ab' like 'a\\\\%'", "0", querypb.Type_INT64},
+		{"s like concat(left(s, 2), '%')", "1", querypb.Type_INT64},
+		{"s regexp '^h.*o$'", "1", querypb.Type_INT64},
+		{"s not regexp 'x'", "1", querypb.Type_INT64},
+		{"n is null", "1", querypb.Type_INT64},
+		{"a is not null", "1", querypb.Type_INT64},
+		{"a is true", "1", querypb.Type_INT64},
+		{"n is not true", "1", querypb.Type_INT64},
+		{"0 is false", "1", querypb.Type_INT64},
+		{"n is not false", "1", querypb.Type_INT64},
+	})
+}
+
+func TestEvalLogic(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"a > 1 and b > 1", "1", querypb.Type_INT64},
+		{"a > 1 and n > 1", "NULL", querypb.Type_INT64},
+		{"a < 1 and n > 1", "0", querypb.Type_INT64},
+		{"n > 1 and a < 1", "0", querypb.Type_INT64},
+		{"a < 1 or b > 1", "1", querypb.Type_INT64},
+		{"a < 1 or n > 1", "NULL", querypb.Type_INT64},
+		{"n > 1 or a > 1", "1", querypb.Type_INT64},
+		{"not a", "0", querypb.Type_INT64},
+		{"not n", "NULL", querypb.Type_INT64},
+		{"!0", "1", querypb.Type_INT64},
+		{"(a > 1) + (b > 1)", "2", querypb.Type_INT64},
+		{"true", "1", querypb.Type_INT64},
+	})
+}
+
+func TestEvalControlFlow(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"if(a > b, s, 'x')", "Hello", querypb.Type_VARCHAR},
+		{"if(n, 1, 2.50)", "2.50", querypb.Type_DECIMAL},
+		{"if(a, 1, 2.50)", "1.00", querypb.Type_DECIMAL},
+		{"ifnull(n, 0)", "0", querypb.Type_INT64},
+		{"ifnull(n, f)", "2.5", querypb.Type_FLOAT64},
+		{"ifnull(a, 's')", "10", querypb.Type_VARCHAR},
+		{"ifnull(n, null)", "NULL", querypb.Type_INT64},
+		{"ifnull(null, null)", "NULL", querypb.Type_NULL_TYPE},
+		{"nullif(a, 10)", "NULL", querypb.Type_INT64},
+		{"nullif(a, b)", "10", querypb.Type_INT64},
+		{"coalesce(n, null, b, a)", "3", querypb.Type_INT64},
+		{"coalesce(null, dd, dt)", "2019-01-31 00:00:00", querypb.Type_DATETIME},
+		{"coalesce(n, dd)", "2019-01-31", querypb.Type_VARCHAR},
+		{"isnull(n)", "1", querypb.Type_INT64},
+		{"case when a < 1 then 'x' when a > 1 then 'y' end", "y", querypb.Type_VARCHAR},
+		{"case a when 1 then 'x' else 'z' end", "z", querypb.Type_VARCHAR},
+		{"case n when 1 then 'x' end", "NULL", querypb.Type_VARCHAR},
+		{"case when b = 3 then d else a end", "1.50", querypb.Type_DECIMAL},
+	})
+}
+
+func TestEvalConvert(t *testing.T) {
+	testEvalCases(t, []evalCase{
+		{"cast(d as signed)", "2", querypb.Type_INT64},
+		{"cast(-1 as unsigned)", "18446744073709551615", querypb.Type_UINT64},
+		{"cast(f as decimal(10, 3))", "2.500", querypb.Type_DECIMAL},
+		{"cast(a as char)", "10", querypb.Type_VARCHAR},
+		{"cast(s as binary)", "Hello", querypb.Type_VARBINARY},
+		{"cast(dt as date)", "2019-01-31", querypb.Type_DATE},
+		{"cast(dd as datetime)", "2019-01-31 00:00:00", querypb.Type_DATETIME},
+		{"cast(dt as time)", "10:11:12", querypb.Type_TIME},
+		{"cast('abc' as date)", "NULL", querypb.Type_DATE},
+		{"s collate utf8_bin", "Hello", querypb.Type_VARCHAR},
+		{"x'4142'", "AB", querypb.Type_VARBINARY},
+	})
+}
+
+func TestEvalUnsupported(t *testing.T) {
+	cases := []struct {
+		expr string
+		err  string
+	}{
+		{"x + 1", "unsupported: unknown.column.'x'.in.expression"},
+		{"t3.a", "unsupported: unknown.column.'t3.a'.in.expression"},
+		{"sum(a) + 1", "unsupported: aggregate.'sum(a)'.in.expression"},
+		{"rand()", "unsupported: function.'rand'"},
+		{"abs(a, b)", "Incorrect parameter count in the call to native function 'abs'"},
+		{"mod(a)", "Incorrect parameter count in the call to native function 'mod'"},
+		{"(a, b) in ((1, 2))", "unsupported: expression.'(a, b) in ((1, 2))'"},
+		{"a in (select 1)", "unsupported: expression.'a in (select 1 from dual)'"},
+		{"cast(a as json)", "unsupported: expression.'convert(a, json)'"},
+		{"interval 1 day", "unsupported: expression.'interval 1 day'"},
+		{"date_add(dt, 1)", "unsupported: expression.'date_add(dt, 1)'"},
+		{"date_add(dt, interval 1 day_hour)", "unsupported: interval.unit.'day_hour'"},
+		{"interval 1 day - dt", "unsupported: expression.'interval 1 day - dt'"},
+		{"s regexp '('", "Got error 'error parsing regexp: missing closing ): `(?is)(`' from regexp"},
+		{"a like 'x' escape b", "unsupported: expression.'a like 'x' escape b'"},
+	}
+	for _, c := range cases {
+		_, err := NewEvaluator(parseExpr(t, c.expr), evalFields)
+		assert.EqualError(t, err, c.err, c.expr)
+	}
+}
+
+func TestEvalFixField(t *testing.T) {
+	cases := []struct {
+		expr  string
+		field *querypb.Field
+	}{
+		{"a / b", &querypb.Field{Type: querypb.Type_DECIMAL, Decimals: 4, Charset: 63, Flags: 32896}},
+		{"concat(s, a)", &querypb.Field{Type: querypb.Type_VARCHAR, Decimals: 31, Charset: 33}},
+		{"concat(bs, a)", &querypb.Field{Type: querypb.Type_VARBINARY, Decimals: 31, Charset: 63, Flags: 128}},
+		{"f + 1", &querypb.Field{Type: querypb.Type_FLOAT64, Decimals: 31, Charset: 63, Flags: 32896}},
+		{"dt + interval 1 day", &querypb.Field{Type: querypb.Type_DATETIME, Charset: 63, Flags: 128}},
+	}
+	for _, c := range cases {
+		e, err := NewEvaluator(parseExpr(t, c.expr), evalFields)
+		assert.Nil(t, err)
+		field := &querypb.Field{}
+		e.FixField(field)
+		assert.Equal(t, c.field, field, c.expr)
+	}
+}
+
+func TestEvalAggregate(t *testing.T) {
+	fields := []*querypb.Field{
+		{Name: "sum(a)", Type: querypb.Type_DECIMAL},
+		{Name: "count(b)", Type: querypb.Type_INT64},
+		{Name: "group_concat(s separator '-')", Type: querypb.Type_VARCHAR},
+	}
+	row := []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("10")),
+		sqltypes.MakeTrusted(querypb.Type_INT64, []byte("4")),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("x-y")),
+	}
+	cases := []struct {
+		expr string
+		res  string
+	}{
+		{"sum(a) / count(b)", "2.5000"},
+		{"ifnull(sum(a), 0) + 1", "11"},
+		{"concat(group_concat(s separator '-'), '!')", "x-y!"},
+	}
+	for _, c := range cases {
+		e, err := NewEvaluator(parseExpr(t, c.expr), fields)
+		assert.Nil(t, err)
+		v, err := e.Eval(row)
+		assert.Nil(t, err)
+		assert.Equal(t, c.res, v.ToString(), c.expr)
+	}
+
+	_, err := NewEvaluator(parseExpr(t, "max(a) + 1"), fields)
+	assert.EqualError(t, err, "unsupported: aggregate.'max(a)'.in.expression")
+}
+
+func TestEvalBool(t *testing.T) {
+	cases := []struct {
+		expr string
+		res  bool
+	}{
+		{"a > b", true},
+		{"a < b", false},
+		{"n", false},
+		{"n is null", true},
+		{"s", false},
+		{"'1abc'", true},
+		{"d", true},
+		{"0.0", false},
+	}
+	for _, c := range cases {
+		e, err := NewEvaluator(parseExpr(t, c.expr), evalFields)
+		assert.Nil(t, err)
+		ok, err := e.EvalBool(evalRow)
+		assert.Nil(t, err)
+		assert.Equal(t, c.res, ok, c.expr)
+	}
+
+	e, err := NewEvaluator(parseExpr(t, "u + 1"), evalFields)
+	assert.Nil(t, err)
+	_, err = e.EvalBool(evalRow)
+	assert.NotNil(t, err)
+}
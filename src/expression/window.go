@@ -0,0 +1,264 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"time"
+
+	"planner"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// windowArgs is the min and max number of the args of the window functions.
+var windowArgs = map[string][2]int{
+	"row_number":   {0, 0},
+	"rank":         {0, 0},
+	"dense_rank":   {0, 0},
+	"percent_rank": {0, 0},
+	"cume_dist":    {0, 0},
+	"ntile":        {1, 1},
+	"lag":          {1, 3},
+	"lead":         {1, 3},
+	"first_value":  {1, 1},
+	"last_value":   {1, 1},
+	"nth_value":    {2, 2},
+	"sum":          {1, 1},
+	"count":        {1, 1},
+	"avg":          {1, 1},
+	"min":          {1, 1},
+	"max":          {1, 1},
+}
+
+// windowAggrs is the aggregate functions used as the window functions.
+var windowAggrs = map[string]planner.AggrType{
+	"sum":   planner.AggrTypeSum,
+	"count": planner.AggrTypeCount,
+	"avg":   planner.AggrTypeAvg,
+	"min":   planner.AggrTypeMin,
+	"max":   planner.AggrTypeMax,
+}
+
+// WindowFunc operator, computes the window function over the rows of a partition.
+// The frame is the default of MySQL: the whole partition if there's no order by,
+// otherwise from the first row of the partition to the last peer of the current row.
+type WindowFunc struct {
+	name  string
+	args  []evalExpr
+	typ   evalType
+	field querypb.Field
+	// aggr is the aggregation of the aggregate window function.
+	aggr *Aggregation
+}
+
+// NewWindowFunc used to compile the window function on the fields.
+func NewWindowFunc(node *sqlparser.FuncExpr, fields []*querypb.Field) (*WindowFunc, error) {
+	name := node.Name.Lowered()
+	limits, ok := windowArgs[name]
+	if !ok || node.Distinct {
+		return nil, errors.Errorf("unsupported: window.function.'%s'", sqlparser.String(node))
+	}
+	if len(node.Exprs) < limits[0] || len(node.Exprs) > limits[1] {
+		return nil, errors.Errorf("Incorrect parameter count in the call to native function '%s'", name)
+	}
+
+	w := &WindowFunc{name: name}
+	c := &compiler{fields: fields, now: time.Now()}
+	for _, arg := range node.Exprs {
+		switch arg := arg.(type) {
+		case *sqlparser.AliasedExpr:
+			e, err := c.compile(arg.Expr)
+			if err != nil {
+				return nil, err
+			}
+			w.args = append(w.args, e)
+		case *sqlparser.StarExpr:
+			if name != "count" {
+				return nil, errors.Errorf("unsupported: syntax.error.at.'%s'", sqlparser.String(node))
+			}
+			// count(*) counts all the rows.
+			w.args = append(w.args, &constExpr{val: sqltypes.NewInt64(1), typ: typeInt64})
+		default:
+			return nil, errors.Errorf("unsupported: window.function.'%s'", sqlparser.String(node))
+		}
+	}
+
+	switch name {
+	case "row_number", "rank", "dense_rank", "ntile":
+		w.typ = typeInt64
+	case "percent_rank", "cume_dist":
+		w.typ = typeFloat64
+	case "lag", "lead":
+		w.typ = w.args[0].result()
+		if len(w.args) == 3 {
+			w.typ = mergeTypes(w.typ, w.args[2].result())
+		}
+	case "first_value", "last_value", "nth_value":
+		w.typ = w.args[0].result()
+	default:
+		fixField(&w.field, w.args[0].result())
+		aggrs := NewAggregations([]planner.Aggregator{{Index: 0, Type: windowAggrs[name]}}, false, []*querypb.Field{&w.field}, 0)
+		w.aggr = aggrs[0]
+		w.typ = evalType{typ: w.field.Type, decimals: int(w.field.Decimals)}
+	}
+	fixField(&w.field, w.typ)
+	return w, nil
+}
+
+// FixField used to fix the field by the result type.
+func (w *WindowFunc) FixField(field *querypb.Field) {
+	field.Type = w.field.Type
+	field.Decimals = w.field.Decimals
+	field.Charset = w.field.Charset
+	field.Flags = w.field.Flags
+}
+
+// Compute used to compute the window function on the rows of a partition, the rows are
+// sorted by the order by of the window. The peers[i] is the end index(exclusive) of the
+// peer group which the rows[i] belongs to, the rows are peers if they are equal by the
+// order by, all the rows are peers if there's no order by.
+func (w *WindowFunc) Compute(rows [][]sqltypes.Value, peers []int) ([]sqltypes.Value, error) {
+	n := len(rows)
+	res := make([]sqltypes.Value, n)
+	if n == 0 {
+		return res, nil
+	}
+
+	switch w.name {
+	case "row_number":
+		for i := range rows {
+			res[i] = sqltypes.NewInt64(int64(i + 1))
+		}
+	case "rank", "dense_rank", "percent_rank", "cume_dist":
+		rank, dense := 0, 0
+		for i := range rows {
+			if i == 0 || peers[i] != peers[i-1] {
+				rank = i + 1
+				dense++
+			}
+			switch w.name {
+			case "rank":
+				res[i] = sqltypes.NewInt64(int64(rank))
+			case "dense_rank":
+				res[i] = sqltypes.NewInt64(int64(dense))
+			case "percent_rank":
+				pr := float64(0)
+				if n > 1 {
+					pr = float64(rank-1) / float64(n-1)
+				}
+				res[i] = sqltypes.NewFloat64(pr)
+			case "cume_dist":
+				res[i] = sqltypes.NewFloat64(float64(peers[i]) / float64(n))
+			}
+		}
+	case "ntile":
+		buckets, err := w.positiveArg(rows[0], 0)
+		if err != nil {
+			return nil, err
+		}
+		size, extra := n/buckets, n%buckets
+		// The first extra buckets have one more row.
+		bound := extra * (size + 1)
+		for i := range rows {
+			var bucket int
+			if i < bound {
+				bucket = i / (size + 1)
+			} else {
+				bucket = extra + (i-bound)/size
+			}
+			res[i] = sqltypes.NewInt64(int64(bucket + 1))
+		}
+	case "lag", "lead":
+		offset := 1
+		if len(w.args) > 1 {
+			v, err := w.args[1].eval(rows[0])
+			if err != nil {
+				return nil, err
+			}
+			if v.IsNull() || toInt64(v) < 0 {
+				return nil, errors.Errorf("Incorrect arguments to %s", w.name)
+			}
+			offset = int(toInt64(v))
+		}
+		if w.name == "lag" {
+			offset = -offset
+		}
+		for i := range rows {
+			var v sqltypes.Value
+			var err error
+			if j := i + offset; j >= 0 && j < n {
+				v, err = w.args[0].eval(rows[j])
+			} else if len(w.args) == 3 {
+				v, err = w.args[2].eval(rows[i])
+			} else {
+				v = sqltypes.NULL
+			}
+			if err != nil {
+				return nil, err
+			}
+			res[i] = castTo(v, w.typ)
+		}
+	case "first_value", "last_value", "nth_value":
+		nth := 1
+		if w.name == "nth_value" {
+			var err error
+			if nth, err = w.positiveArg(rows[0], 1); err != nil {
+				return nil, err
+			}
+		}
+		for i := range rows {
+			// The frame ends at the last peer of the current row.
+			j := nth - 1
+			if w.name == "last_value" {
+				j = peers[i] - 1
+			}
+			if j >= peers[i] {
+				res[i] = sqltypes.NULL
+				continue
+			}
+			v, err := w.args[0].eval(rows[j])
+			if err != nil {
+				return nil, err
+			}
+			res[i] = v
+		}
+	default:
+		evalCtx := w.aggr.InitEvalCtx(nil)
+		for i := 0; i < n; {
+			start, end := i, peers[i]
+			for ; i < end; i++ {
+				v, err := w.args[0].eval(rows[i])
+				if err != nil {
+					return nil, err
+				}
+				w.aggr.Update([]sqltypes.Value{v}, evalCtx)
+			}
+			val := w.aggr.GetResult(evalCtx)
+			for k := start; k < end; k++ {
+				res[k] = val
+			}
+		}
+	}
+	return res, nil
+}
+
+// positiveArg returns the arg at the index, which should be a positive integer.
+func (w *WindowFunc) positiveArg(row []sqltypes.Value, index int) (int, error) {
+	v, err := w.args[index].eval(row)
+	if err != nil {
+		return 0, err
+	}
+	if v.IsNull() || toInt64(v) <= 0 {
+		return 0, errors.Errorf("Incorrect arguments to %s", w.name)
+	}
+	return int(toInt64(v)), nil
+}
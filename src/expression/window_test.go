@@ -0,0 +1,139 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package expression
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	windowFields = []*querypb.Field{
+		{Name: "k", Table: "t", Type: querypb.Type_INT64},
+		{Name: "v", Table: "t", Type: querypb.Type_DECIMAL, Decimals: 2},
+	}
+	// The rows are sorted by k.
+	windowRows = [][]sqltypes.Value{
+		{sqltypes.NewInt64(1), sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("1.00"))},
+		{sqltypes.NewInt64(1), sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("2.00"))},
+		{sqltypes.NewInt64(2), sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("3.00"))},
+		{sqltypes.NewInt64(3), sqltypes.NULL},
+		{sqltypes.NewInt64(3), sqltypes.MakeTrusted(querypb.Type_DECIMAL, []byte("5.00"))},
+	}
+	// The peers ordered by k.
+	windowPeers = []int{2, 2, 3, 5, 5}
+)
+
+func TestWindowFunc(t *testing.T) {
+	cases := []struct {
+		expr  string
+		peers []int
+		res   string
+		typ   querypb.Type
+	}{
+		{"row_number() over (order by k)", windowPeers, "[1 2 3 4 5]", querypb.Type_INT64},
+		{"rank() over (order by k)", windowPeers, "[1 1 3 4 4]", querypb.Type_INT64},
+		{"rank() over ()", []int{5, 5, 5, 5, 5}, "[1 1 1 1 1]", querypb.Type_INT64},
+		{"dense_rank() over (order by k)", windowPeers, "[1 1 2 3 3]", querypb.Type_INT64},
+		{"percent_rank() over (order by k)", windowPeers, "[0 0 0.5 0.75 0.75]", querypb.Type_FLOAT64},
+		{"cume_dist() over (order by k)", windowPeers, "[0.4 0.4 0.6 1 1]", querypb.Type_FLOAT64},
+		{"ntile(2) over (order by k)", windowPeers, "[1 1 1 2 2]", querypb.Type_INT64},
+		{"ntile(3) over (order by k)", windowPeers, "[1 1 2 2 3]", querypb.Type_INT64},
+		{"ntile(10) over (order by k)", windowPeers, "[1 2 3 4 5]", querypb.Type_INT64},
+		{"lag(v) over (order by k)", windowPeers, "[NULL 1.00 2.00 3.00 NULL]", querypb.Type_DECIMAL},
+		{"lead(v, 2, 0) over (order by k)", windowPeers, "[3.00 NULL 5.00 0.00 0.00]", querypb.Type_DECIMAL},
+		{"lag(k, 0) over (order by k)", windowPeers, "[1 1 2 3 3]", querypb.Type_INT64},
+		{"first_value(v) over (order by k)", windowPeers, "[1.00 1.00 1.00 1.00 1.00]", querypb.Type_DECIMAL},
+		{"last_value(v) over (order by k)", windowPeers, "[2.00 2.00 3.00 5.00 5.00]", querypb.Type_DECIMAL},
+		{"nth_value(v, 3) over (order by k)", windowPeers, "[NULL NULL 3.00 3.00 3.00]", querypb.Type_DECIMAL},
+		{"sum(v) over (order by k)", windowPeers, "[3.00 3.00 6.00 11.00 11.00]", querypb.Type_DECIMAL},
+		{"sum(v) over ()", []int{5, 5, 5, 5, 5}, "[11.00 11.00 11.00 11.00 11.00]", querypb.Type_DECIMAL},
+		{"count(v) over (order by k)", windowPeers, "[2 2 3 4 4]", querypb.Type_INT64},
+		{"count(*) over (order by k)", windowPeers, "[2 2 3 5 5]", querypb.Type_INT64},
+		{"avg(v) over (order by k)", windowPeers, "[1.500000 1.500000 2.000000 2.750000 2.750000]", querypb.Type_DECIMAL},
+		{"min(v) over (order by k desc)", windowPeers, "[1.00 1.00 1.00 1.00 1.00]", querypb.Type_DECIMAL},
+		{"max(k + 1) over (order by k)", windowPeers, "[2 2 3 4 4]", querypb.Type_INT64},
+	}
+
+	for _, c := range cases {
+		fn := parseExpr(t, c.expr).(*sqlparser.FuncExpr)
+		w, err := NewWindowFunc(fn, windowFields)
+		assert.Nil(t, err, c.expr)
+		res, err := w.Compute(windowRows, c.peers)
+		assert.Nil(t, err, c.expr)
+		var vals []string
+		for _, v := range res {
+			if v.IsNull() {
+				vals = append(vals, "NULL")
+			} else {
+				vals = append(vals, v.ToString())
+			}
+		}
+		assert.Equal(t, c.res, fmt.Sprintf("%v", vals), c.expr)
+
+		field := &querypb.Field{}
+		w.FixField(field)
+		assert.Equal(t, c.typ, field.Type, c.expr)
+	}
+}
+
+func TestWindowFuncError(t *testing.T) {
+	compileErrs := []struct {
+		expr string
+		err  string
+	}{
+		{"foo() over ()", "unsupported: window.function.'foo() over ()'"},
+		{"count(distinct v) over ()", "unsupported: window.function.'count(distinct v) over ()'"},
+		{"rank(v) over ()", "Incorrect parameter count in the call to native function 'rank'"},
+		{"lag() over ()", "Incorrect parameter count in the call to native function 'lag'"},
+		{"sum(*) over ()", "unsupported: syntax.error.at.'sum(*) over ()'"},
+		{"sum(x) over ()", "unsupported: unknown.column.'x'.in.expression"},
+	}
+	for _, c := range compileErrs {
+		fn := parseExpr(t, c.expr).(*sqlparser.FuncExpr)
+		_, err := NewWindowFunc(fn, windowFields)
+		assert.NotNil(t, err, c.expr)
+		if err != nil {
+			assert.Equal(t, c.err, err.Error(), c.expr)
+		}
+	}
+
+	computeErrs := []struct {
+		expr string
+		err  string
+	}{
+		{"ntile(0) over ()", "Incorrect arguments to ntile"},
+		{"ntile(null) over ()", "Incorrect arguments to ntile"},
+		{"nth_value(v, -1) over ()", "Incorrect arguments to nth_value"},
+		{"lag(v, -1) over ()", "Incorrect arguments to lag"},
+	}
+	for _, c := range computeErrs {
+		fn := parseExpr(t, c.expr).(*sqlparser.FuncExpr)
+		w, err := NewWindowFunc(fn, windowFields)
+		assert.Nil(t, err, c.expr)
+		_, err = w.Compute(windowRows, windowPeers)
+		assert.NotNil(t, err, c.expr)
+		if err != nil {
+			assert.Equal(t, c.err, err.Error(), c.expr)
+		}
+	}
+
+	// Empty partition.
+	fn := parseExpr(t, "rank() over ()").(*sqlparser.FuncExpr)
+	w, err := NewWindowFunc(fn, windowFields)
+	assert.Nil(t, err)
+	res, err := w.Compute(nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(res))
+}
@@ -15,8 +15,8 @@ import (
 	"sync"
 
 	"config"
+	"forks/go-mysqlstack/driver"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
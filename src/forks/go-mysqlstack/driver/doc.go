@@ -0,0 +1,18 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+// Package driver is forked from the driver of the vendored github.com/xelabs/go-mysqlstack,
+// with the binary protocol prepared statements which the upstream doesn't have:
+// 1. The Handler prepares the statement by ComStmtPrepare and executes it by ComStmtExecute.
+// 2. The stmt-prepare-response carries the metadata of the params and the result columns.
+// 3. The COM_STMT_SEND_LONG_DATA and the types bound by the last execution.
+// 4. The unknown statement ids fail with ER_UNKNOWN_STMT_HANDLER as MySQL.
+// The packet, proto and sqldb are still the vendored ones, the changes of them are kept
+// in stmt_proto.go. Drop the fork once the upstream has the prepared statements.
+package driver
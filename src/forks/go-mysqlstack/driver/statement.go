@@ -0,0 +1,172 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"github.com/xelabs/go-mysqlstack/sqldb"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// Statement --
+type Statement struct {
+	conn        *conn
+	ID          uint32
+	ParamCount  uint16
+	PrepareStmt string
+	ColumnNames []string
+	// Params is the metadata of the params, the params are sent as VARBINARY if it's nil.
+	Params []*querypb.Field
+	// Fields is the metadata of the result columns, nil if the statement returns no result set.
+	Fields   []*querypb.Field
+	BindVars map[string]*querypb.BindVariable
+	// Prepared is kept by the handler at the prepare, such as the parsed statement.
+	Prepared interface{}
+
+	// paramsType is the types of the params bound by the last execution.
+	paramsType []querypb.Type
+	// longData is the params sent by COM_STMT_SEND_LONG_DATA, the client side only records the param id.
+	longData     map[uint16][]byte
+	longDataSent map[uint16]bool
+}
+
+// appendLongData used to append the long data of the param, which is cleared after the execution.
+func (s *Statement) appendLongData(paramID uint16, data []byte) error {
+	if paramID >= s.ParamCount {
+		return errWrongArguments("mysqld_stmt_send_long_data")
+	}
+	if s.longData == nil {
+		s.longData = make(map[uint16][]byte)
+	}
+	s.longData[paramID] = append(s.longData[paramID], data...)
+	return nil
+}
+
+// ComStatementExecute -- statement execute write.
+func (s *Statement) ComStatementExecute(parameters []sqltypes.Value) error {
+	var err error
+	var datas []byte
+	var iRows Rows
+
+	if datas, err = packStatementExecute(s.ID, parameters, s.longDataSent); err != nil {
+		return err
+	}
+	s.longDataSent = nil
+
+	if iRows, err = s.conn.stmtQuery(sqldb.COM_STMT_EXECUTE, datas); err != nil {
+		return err
+	}
+	for iRows.Next() {
+		if _, err := iRows.RowValues(); err != nil {
+			s.conn.Cleanup()
+			return err
+		}
+	}
+	// Drain the results and check last error.
+	if err := iRows.Close(); err != nil {
+		s.conn.Cleanup()
+		return err
+	}
+	return nil
+}
+
+// ComStatementExecute -- statement execute write.
+func (s *Statement) ComStatementQuery(parameters []sqltypes.Value) (*sqltypes.Result, error) {
+	var err error
+	var datas []byte
+	var iRows Rows
+	var qrRow []sqltypes.Value
+	var qrRows [][]sqltypes.Value
+
+	if datas, err = packStatementExecute(s.ID, parameters, s.longDataSent); err != nil {
+		return nil, err
+	}
+	s.longDataSent = nil
+
+	if iRows, err = s.conn.stmtQuery(sqldb.COM_STMT_EXECUTE, datas); err != nil {
+		return nil, err
+	}
+	for iRows.Next() {
+		if qrRow, err = iRows.RowValues(); err != nil {
+			s.conn.Cleanup()
+			return nil, err
+		}
+		if qrRow != nil {
+			qrRows = append(qrRows, qrRow)
+		}
+	}
+	// Drain the results and check last error.
+	if err := iRows.Close(); err != nil {
+		s.conn.Cleanup()
+		return nil, err
+	}
+
+	rowsAffected := iRows.RowsAffected()
+	if rowsAffected == 0 {
+		rowsAffected = uint64(len(qrRows))
+	}
+	qr := &sqltypes.Result{
+		Fields:       iRows.Fields(),
+		RowsAffected: rowsAffected,
+		InsertID:     iRows.LastInsertID(),
+		Rows:         qrRows,
+	}
+	return qr, err
+}
+
+// ComStatementSendLongData -- send the data of the param in pieces, the value of
+// the param in the next execution is ignored.
+func (s *Statement) ComStatementSendLongData(paramID uint16, data []byte) error {
+	buf := common.NewBuffer(6 + len(data))
+	buf.WriteU32(s.ID)
+	buf.WriteU16(paramID)
+	buf.WriteBytes(data)
+	if err := s.conn.packets.WriteCommand(sqldb.COM_STMT_SEND_LONG_DATA, buf.Datas()); err != nil {
+		return err
+	}
+	if s.longDataSent == nil {
+		s.longDataSent = make(map[uint16]bool)
+	}
+	s.longDataSent[paramID] = true
+	return nil
+}
+
+// ComStatementReset -- reset the stmt.
+func (s *Statement) ComStatementReset() error {
+	var data [4]byte
+
+	// Add arg [32 bit]
+	data[0] = byte(s.ID)
+	data[1] = byte(s.ID >> 8)
+	data[2] = byte(s.ID >> 16)
+	data[3] = byte(s.ID >> 24)
+	if err := s.conn.packets.WriteCommand(sqldb.COM_STMT_RESET, data[:]); err != nil {
+		return err
+	}
+	s.longDataSent = nil
+	return s.conn.packets.ReadOK()
+}
+
+// ComStatementClose -- close the stmt.
+func (s *Statement) ComStatementClose() error {
+	var data [4]byte
+
+	// Add arg [32 bit]
+	data[0] = byte(s.ID)
+	data[1] = byte(s.ID >> 8)
+	data[2] = byte(s.ID >> 16)
+	data[3] = byte(s.ID >> 24)
+	if err := s.conn.packets.WriteCommand(sqldb.COM_STMT_CLOSE, data[:]); err != nil {
+		return err
+	}
+	return nil
+}
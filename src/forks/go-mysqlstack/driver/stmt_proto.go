@@ -0,0 +1,330 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"fmt"
+
+	"github.com/xelabs/go-mysqlstack/packet"
+	"github.com/xelabs/go-mysqlstack/proto"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// The binary protocol of the prepared statements, which is forked from the packet and proto
+// of go-mysqlstack, with the metadata of the params and columns, the long data and the types
+// bound by the last execution.
+
+const (
+	// ER_WRONG_ARGUMENTS enum.
+	ER_WRONG_ARGUMENTS = 1210
+
+	// ER_UNKNOWN_STMT_HANDLER enum.
+	ER_UNKNOWN_STMT_HANDLER = 1243
+)
+
+// errWrongArguments returns the ER_WRONG_ARGUMENTS error of the command.
+func errWrongArguments(cmd string) *sqldb.SQLError {
+	return &sqldb.SQLError{Num: ER_WRONG_ARGUMENTS, State: "HY000", Message: fmt.Sprintf("Incorrect arguments to %s", cmd)}
+}
+
+// errUnknownStmtHandler returns the ER_UNKNOWN_STMT_HANDLER error of the statement command.
+func errUnknownStmtHandler(stmtID uint32, cmd string) *sqldb.SQLError {
+	return &sqldb.SQLError{Num: ER_UNKNOWN_STMT_HANDLER, State: "HY000", Message: fmt.Sprintf("Unknown prepared statement handler (%v) given to %s", stmtID, cmd)}
+}
+
+// stmtPrepareResponse -- the stmt-prepare-response with the metadata of the params and columns.
+type stmtPrepareResponse struct {
+	*proto.Statement
+	Params  []*querypb.Field
+	Columns []*querypb.Field
+}
+
+// stmtExecute -- the stmt-execute packet.
+type stmtExecute struct {
+	ID uint32
+	// ParamsType is the types of the params bound by the stmt-execute packet.
+	ParamsType []querypb.Type
+	BindVars   map[string]*querypb.BindVariable
+}
+
+// writeStatementPrepareResponse -- write the stmt prepare response to client by server.
+func writeStatementPrepareResponse(p *packet.Packets, clientFlags uint32, stmt *Statement) error {
+	// First write statement prepare package.
+	datas := proto.PackStatementPrepare(&proto.Statement{
+		ID:          stmt.ID,
+		ColumnCount: uint16(len(stmt.Fields)),
+		ParamCount:  stmt.ParamCount,
+	})
+	if err := p.Append(datas); err != nil {
+		return err
+	}
+
+	// Send param fields.
+	if stmt.ParamCount > 0 {
+		for i := uint16(0); i < stmt.ParamCount; i++ {
+			field := &querypb.Field{Name: "?", Type: sqltypes.VarBinary, Charset: 63}
+			if int(i) < len(stmt.Params) {
+				field = stmt.Params[i]
+			}
+			if err := p.Append(proto.PackColumn(field)); err != nil {
+				return err
+			}
+		}
+		if (clientFlags & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+			p.AppendEOF(0, 0)
+		}
+	}
+
+	// Send column fields.
+	if len(stmt.Fields) > 0 {
+		for _, field := range stmt.Fields {
+			if err := p.Append(proto.PackColumn(field)); err != nil {
+				return err
+			}
+		}
+		if (clientFlags & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+			p.AppendEOF(0, 0)
+		}
+	}
+	return p.Flush()
+}
+
+// readStatementPrepareResponse -- read the stmt prepare response by client from the server.
+func readStatementPrepareResponse(p *packet.Packets, clientFlags uint32) (*stmtPrepareResponse, error) {
+	var err error
+	var data []byte
+
+	if data, err = p.Next(); err != nil {
+		return nil, err
+	}
+
+	switch data[0] {
+	case proto.ERR_PACKET:
+		return nil, p.ParseERR(data)
+	}
+
+	protoStmt, err := proto.UnPackStatementPrepare(data)
+	if err != nil {
+		return nil, err
+	}
+	stmt := &stmtPrepareResponse{Statement: protoStmt}
+
+	if stmt.ParamCount > 0 {
+		for i := uint16(0); i < stmt.ParamCount; i++ {
+			if data, err = p.Next(); err != nil {
+				return nil, err
+			}
+			param, err := proto.UnpackColumn(data)
+			if err != nil {
+				return nil, err
+			}
+			stmt.Params = append(stmt.Params, param)
+		}
+
+		if (clientFlags & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+			if err = p.ReadEOF(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if stmt.ColumnCount > 0 {
+		for i := uint16(0); i < stmt.ColumnCount; i++ {
+			if data, err = p.Next(); err != nil {
+				return nil, err
+			}
+			column, err := proto.UnpackColumn(data)
+			if err != nil {
+				return nil, err
+			}
+			stmt.ColumnNames = append(stmt.ColumnNames, column.Name)
+			stmt.Columns = append(stmt.Columns, column)
+		}
+
+		if (clientFlags & sqldb.CLIENT_DEPRECATE_EOF) == 0 {
+			if err = p.ReadEOF(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return stmt, nil
+}
+
+// packStatementExecute -- used to pack the stmt execute packet from the client.
+// The values of the params in the longData are sent by COM_STMT_SEND_LONG_DATA, so they're skipped.
+// https://dev.mysql.com/doc/internals/en/com-stmt-execute.html
+func packStatementExecute(stmtID uint32, parameters []sqltypes.Value, longData map[uint16]bool) ([]byte, error) {
+	paramsLen := len(parameters)
+	nullBitMapLen := (paramsLen + 7) / 8
+	nullMask := make([]byte, nullBitMapLen)
+
+	var paramsType []byte
+	var paramsValue []byte
+	for i, param := range parameters {
+		// Handle null mask.
+		if longData[uint16(i)] {
+			// The value is sent by long data.
+		} else if param.IsNull() {
+			nullMask[i/8] |= 1 << (uint(i) & 7)
+		} else {
+			v, err := param.ToMySQL()
+			if err != nil {
+				return nil, err
+			}
+			paramsValue = append(paramsValue, v...)
+		}
+		typ, flags := sqltypes.TypeToMySQL(param.Type())
+		paramsType = append(paramsType, byte(typ))
+		paramsType = append(paramsType, byte(flags))
+	}
+
+	buf := common.NewBuffer(64)
+
+	// Statement ID[4 bytes]
+	buf.WriteU32(stmtID)
+
+	// flags (0: CURSOR_TYPE_NO_CURSOR) [1 byte]
+	buf.WriteU8(0x00)
+
+	// iteration_count (uint32(1)) [4 bytes]
+	buf.WriteU32(0x01)
+
+	if paramsLen > 0 {
+		// NULL-bitmap, length: (num-params+7)/8
+		buf.WriteBytes(nullMask)
+
+		// newParameterBoundFlag 1 [1 byte]
+		buf.WriteU8(1)
+
+		// params type
+		buf.WriteBytes(paramsType)
+		// params value
+		buf.WriteBytes(paramsValue)
+	}
+	return buf.Datas(), nil
+}
+
+// unpackStatementExecute -- unpack the stmt-execute packet from client.
+// The paramsType is the types bound by the last execution, used if the new-params-bound-flag is 0.
+// The values of the params in the longData are sent by COM_STMT_SEND_LONG_DATA, not in the packet.
+func unpackStatementExecute(data []byte, paramsCount uint16, paramsType []querypb.Type, longData map[uint16][]byte, parseValueFn func(*common.Buffer, querypb.Type) (interface{}, error)) (*stmtExecute, error) {
+	var err error
+
+	stmt := &stmtExecute{}
+	bitMap := make([]byte, 0)
+	buf := common.ReadBuffer(data)
+
+	// statement ID
+	if stmt.ID, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading statement ID failed")
+	}
+
+	// cursor type flags
+	if _, err = buf.ReadU8(); err != nil {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading cursor type flags failed")
+	}
+
+	// iteration count
+	var itercount uint32
+	if itercount, err = buf.ReadU32(); err != nil {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading iteration count failed")
+	}
+	if itercount != 1 {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "iteration count is not equal to 1")
+	}
+
+	if paramsCount > 0 {
+		// Init.
+		stmt.BindVars = make(map[string]*querypb.BindVariable)
+
+		if bitMap, err = buf.ReadBytes(int((paramsCount + 7) / 8)); err != nil {
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading NULL-bitmap failed")
+		}
+
+		var newParamsBoundFlag byte
+		if newParamsBoundFlag, err = buf.ReadU8(); err != nil {
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading NULL-bitmap failed")
+		}
+		if newParamsBoundFlag == 0x01 {
+			var mysqlType, flags byte
+			paramsType = make([]querypb.Type, paramsCount)
+			for i := uint16(0); i < paramsCount; i++ {
+				if mysqlType, err = buf.ReadU8(); err != nil {
+					return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading parameter type failed")
+				}
+
+				if flags, err = buf.ReadU8(); err != nil {
+					return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading parameter flags failed")
+				}
+				// Convert MySQL type to Vitess type.
+				valType, err := sqltypes.MySQLToType(int64(mysqlType), int64(flags))
+				if err != nil {
+					return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "MySQLToType(%v,%v) failed: %v", mysqlType, flags, err)
+				}
+				paramsType[i] = valType
+			}
+		} else if len(paramsType) != int(paramsCount) {
+			return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "reading parameter type failed: the types were never bound")
+		}
+		stmt.ParamsType = paramsType
+
+		for i := uint16(0); i < paramsCount; i++ {
+			var val interface{}
+			name := fmt.Sprintf("v%d", i+1)
+			typ := paramsType[i]
+
+			if data, ok := longData[i]; ok {
+				stmt.BindVars[name] = sqltypes.BytesBindVariable(data)
+				continue
+			}
+
+			if (bitMap[i/8] & (1 << uint(i%8))) > 0 {
+				val, err = parseValueFn(buf, sqltypes.Null)
+			} else {
+				val, err = parseValueFn(buf, typ)
+			}
+			if err != nil {
+				return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "decoding parameter value failed(%v) failed: %v", typ, err)
+			}
+
+			// If value is nil, must set bind variables to nil.
+			bv, err := sqltypes.BuildBindVariable(val)
+			if err != nil {
+				return nil, sqldb.NewSQLErrorf(sqldb.ER_MALFORMED_PACKET, "build converted parameters value failed: %v", err)
+			}
+			// The decimal and temporal values are decoded as the strings, keep their types.
+			if val != nil {
+				switch typ {
+				case sqltypes.Decimal, sqltypes.Date, sqltypes.Datetime, sqltypes.Timestamp, sqltypes.Time:
+					bv.Type = typ
+				}
+			}
+			stmt.BindVars[name] = bv
+		}
+	}
+	return stmt, nil
+}
+
+// parseMySQLValue -- parse the binary value of the param, the TINYINT is signed unless
+// it's flagged as unsigned.
+func parseMySQLValue(buf *common.Buffer, typ querypb.Type) (interface{}, error) {
+	if typ == sqltypes.Int8 {
+		val, err := buf.ReadU8()
+		if err != nil {
+			return nil, err
+		}
+		return int8(val), nil
+	}
+	return sqltypes.ParseMySQLValues(buf, typ)
+}
@@ -0,0 +1,67 @@
+/*
+ * go-mysqlstack
+ * xelabs.org
+ *
+ * Copyright (c) XeLabs
+ * GPL License
+ *
+ */
+
+package driver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+func TestStmtProtoExecuteLongData(t *testing.T) {
+	id := uint32(11)
+	values := []sqltypes.Value{
+		sqltypes.NewInt32(11),
+		sqltypes.MakeTrusted(sqltypes.Blob, []byte("ignored")),
+		sqltypes.MakeTrusted(sqltypes.Decimal, []byte("1.50")),
+		sqltypes.MakeTrusted(sqltypes.Null, nil),
+	}
+
+	datas, err := packStatementExecute(id, values, map[uint16]bool{1: true})
+	assert.Nil(t, err)
+
+	longData := map[uint16][]byte{1: []byte("long data")}
+	got, err := unpackStatementExecute(datas, 4, nil, longData, parseMySQLValue)
+	assert.Nil(t, err)
+	assert.Equal(t, id, got.ID)
+	want := map[string]*querypb.BindVariable{
+		"v1": sqltypes.Int64BindVariable(11),
+		"v2": sqltypes.BytesBindVariable([]byte("long data")),
+		"v3": {Type: sqltypes.Decimal, Value: []byte("1.50")},
+		"v4": sqltypes.NullBindVariable,
+	}
+	assert.Equal(t, want, got.BindVars)
+	assert.Equal(t, []querypb.Type{sqltypes.Int32, sqltypes.Blob, sqltypes.Decimal, sqltypes.Null}, got.ParamsType)
+}
+
+func TestStmtProtoExecuteBoundTypes(t *testing.T) {
+	buff := common.NewBuffer(32)
+	buff.WriteU32(1)
+	buff.WriteU8(0)
+	buff.WriteU32(1)
+	// null bits.
+	buff.WriteU8(0)
+	// newParameterBoundFlag.
+	buff.WriteU8(0)
+	// value.
+	buff.WriteU8(0xff)
+
+	// Types are not bound.
+	_, err := unpackStatementExecute(buff.Datas(), 1, nil, nil, parseMySQLValue)
+	assert.NotNil(t, err)
+
+	got, err := unpackStatementExecute(buff.Datas(), 1, []querypb.Type{sqltypes.Int8}, nil, parseMySQLValue)
+	assert.Nil(t, err)
+	assert.Equal(t, sqltypes.Int64BindVariable(-1), got.BindVars["v1"])
+}
@@ -75,6 +75,14 @@ var (
 			Name: "peer_number",
 			Help: "radon peer Number",
 		})
+
+	schemaDriftNum = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "schema_drift_partitions",
+			Help: "number of the partitions whose definitions drift from the majority",
+		},
+		[]string{"database", "table"},
+	)
 )
 
 func init() {
@@ -85,6 +93,7 @@ func init() {
 	prometheus.MustRegister(diskUsage)
 	prometheus.MustRegister(slowQueryTotalCounter)
 	prometheus.MustRegister(peerNum)
+	prometheus.MustRegister(schemaDriftNum)
 }
 
 // Start monitor
@@ -162,3 +171,8 @@ func PeerNumDec() {
 func PeerNumSet(v float64) {
 	peerNum.Set(v)
 }
+
+// SchemaDriftSet set the number of the drifted partitions of the table
+func SchemaDriftSet(database string, table string, v float64) {
+	schemaDriftNum.WithLabelValues(database, table).Set(v)
+}
@@ -184,6 +184,18 @@ func TestPeerNum(t *testing.T) {
 	assert.EqualValues(t, 1, v)
 }
 
+func TestSchemaDriftSet(t *testing.T) {
+	SchemaDriftSet("db1", "t1", 3)
+
+	var m dto.Metric
+	g, _ := schemaDriftNum.GetMetricWithLabelValues("db1", "t1")
+	err := g.Write(&m)
+	assert.Nil(t, err)
+	v := m.GetGauge().GetValue()
+
+	assert.EqualValues(t, 3, v)
+}
+
 func TestMonitorStart(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.ERROR))
 	var conf config.Config
@@ -44,6 +44,9 @@ const (
 	// AggrTypeAvg enum.
 	AggrTypeAvg AggrType = "AVG"
 
+	// AggrTypeGroupConcat enum.
+	AggrTypeGroupConcat AggrType = "GROUP_CONCAT"
+
 	// AggrTypeGroupBy enum.
 	AggrTypeGroupBy AggrType = "GROUP BY"
 )
@@ -54,6 +57,16 @@ type Aggregator struct {
 	Index    int
 	Type     AggrType
 	Distinct bool
+	// Separator is the separator of the group_concat.
+	Separator string `json:",omitempty"`
+	// OrderBys is the order by of the group_concat.
+	OrderBys []ConcatOrderBy `json:",omitempty"`
+}
+
+// ConcatOrderBy tuple, the values of the group_concat are sorted by the column at the index.
+type ConcatOrderBy struct {
+	Index     int
+	Direction Direction
 }
 
 // AggregatePlan represents order-by plan.
@@ -86,7 +99,7 @@ func NewAggregatePlan(log *xlog.Log, exprs []sqlparser.SelectExpr, tuples, group
 
 // analyze used to check the aggregator is at the support level.
 // Supports:
-// SUM/COUNT/MIN/MAX/AVG/GROUPBY
+// SUM/COUNT/MIN/MAX/AVG/GROUP_CONCAT/GROUPBY
 // Notes:
 // group by fields must be in the select list, for example:
 // select count(a), a from t group by a --[OK]
@@ -120,11 +133,19 @@ func (p *AggregatePlan) analyze() error {
 			aggType = AggrTypeMax
 		case "avg":
 			aggType = AggrTypeAvg
+		case "group_concat":
+			aggType = AggrTypeGroupConcat
 		default:
 			return errors.Errorf("unsupported: function:%+v", tuple.aggrFuc)
 		}
 
-		p.normalAggrs = append(p.normalAggrs, Aggregator{Field: tuple.field, Index: k, Type: aggType, Distinct: tuple.distinct})
+		aggr := Aggregator{Field: tuple.field, Index: k, Type: aggType, Distinct: tuple.distinct}
+		if aggType == AggrTypeGroupConcat {
+			if err := p.analyzeGroupConcat(&tuple, &aggr); err != nil {
+				return err
+			}
+		}
+		p.normalAggrs = append(p.normalAggrs, aggr)
 		if p.IsPushDown {
 			if aggType == AggrTypeAvg {
 				p.normalAggrs = append(p.normalAggrs, Aggregator{Field: fmt.Sprintf("sum(%s)", tuple.aggrField), Index: k, Type: AggrTypeSum})
@@ -161,6 +182,43 @@ func (p *AggregatePlan) analyze() error {
 	return nil
 }
 
+// analyzeGroupConcat used to get the separator and the order by of the group_concat,
+// the order by fields have been added to the select list.
+func (p *AggregatePlan) analyzeGroupConcat(tuple *selectTuple, aggr *Aggregator) error {
+	concat := tuple.expr.(*sqlparser.AliasedExpr).Expr.(*sqlparser.GroupConcatExpr)
+	aggr.Separator = ","
+	if concat.Separator != "" {
+		aggr.Separator = strings.TrimSuffix(strings.TrimPrefix(concat.Separator, " separator '"), "'")
+	}
+
+	for _, order := range concat.OrderBy {
+		col, ok := order.Expr.(*sqlparser.ColName)
+		if !ok {
+			return errors.Errorf("unsupported: orderby:[%s].in.group_concat", sqlparser.String(order.Expr))
+		}
+		idx := -1
+		for i, t := range p.tuples {
+			if col.Qualifier.IsEmpty() && t.alias == col.Name.String() {
+				idx = i
+				break
+			}
+			if t.isCol && t.field == col.Name.String() && (col.Qualifier.IsEmpty() || t.referTables[0] == col.Qualifier.Name.String()) {
+				idx = i
+				break
+			}
+		}
+		if idx == -1 {
+			return errors.Errorf("unsupported: orderby:[%s].in.group_concat", sqlparser.String(col))
+		}
+		direction := ASC
+		if order.Direction == sqlparser.DescScr {
+			direction = DESC
+		}
+		aggr.OrderBys = append(aggr.OrderBys, ConcatOrderBy{Index: idx, Direction: direction})
+	}
+	return nil
+}
+
 // Build used to build distributed querys.
 func (p *AggregatePlan) Build() error {
 	return p.analyze()
@@ -66,6 +66,25 @@ func NewDDLPlan(log *xlog.Log, database string, query string, node *sqlparser.DD
 	}
 }
 
+// lookup returns the shard key and the segments of the table. The table of the CREATE TABLE
+// is staged in router and invisible to Lookup, so it's resolved by LookupStaged first.
+func (p *DDLPlan) lookup(database string, table string) (string, []router.Segment, error) {
+	if p.node.Action == sqlparser.CreateTableStr {
+		if shardKey, segments, err := p.router.LookupStaged(database, table); err == nil {
+			return shardKey, segments, nil
+		}
+	}
+	shardKey, err := p.router.ShardKey(database, table)
+	if err != nil {
+		return "", nil, err
+	}
+	segments, err := p.router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return "", nil, err
+	}
+	return shardKey, segments, nil
+}
+
 // Build used to build DDL distributed querys.
 // sqlparser.DDL is a simple grammar ast, it just parses database and table name in the prefix.
 func (p *DDLPlan) Build() error {
@@ -82,8 +101,8 @@ func (p *DDLPlan) Build() error {
 			database = node.Table.Qualifier.String()
 		}
 
-		// Get the shard key.
-		shardKey, err := p.router.ShardKey(database, table)
+		// Get the shard key and the segments.
+		shardKey, segments, err := p.lookup(database, table)
 		if err != nil {
 			return err
 		}
@@ -124,10 +143,6 @@ func (p *DDLPlan) Build() error {
 			}
 		}
 
-		segments, err := p.router.Lookup(database, table, nil, nil)
-		if err != nil {
-			return err
-		}
 		for _, segment := range segments {
 			var query string
 
@@ -452,3 +452,43 @@ func TestDDLPlanWithRenameNoshard(t *testing.T) {
 		}
 	}
 }
+
+func TestDDLPlanCreateStagedTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.CreateDatabase(database)
+	assert.Nil(t, err)
+	err = route.StageTable(database, "t1", "id", router.TableTypePartition, []string{"backend1", "backend2"}, nil)
+	assert.Nil(t, err)
+
+	// The staged table is resolved by the plan of the CREATE TABLE.
+	{
+		query := "create table t1(id int, a int) partition by hash(id)"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDDLPlan(log, database, query, node.(*sqlparser.DDL), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, 32, len(plan.Querys))
+	}
+
+	// The staged table is invisible to the other plans.
+	{
+		query := "alter table t1 engine=tokudb"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewDDLPlan(log, database, query, node.(*sqlparser.DDL), route)
+		err = plan.Build()
+		assert.NotNil(t, err)
+
+		query = "select * from t1"
+		node, err = sqlparser.Parse(query)
+		assert.Nil(t, err)
+		selPlan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = selPlan.Build()
+		assert.NotNil(t, err)
+	}
+}
@@ -48,6 +48,9 @@ type DeletePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Hints are the optimizer hints in the comments.
+	Hints *Hints
 }
 
 // NewDeletePlan used to create DeletePlan
@@ -83,6 +86,12 @@ func (p *DeletePlan) Build() error {
 	}
 
 	node := p.node
+	hints, err := ParseHints(node.Comments)
+	if err != nil {
+		return err
+	}
+	p.Hints = hints
+
 	// Database.
 	database := p.database
 	if !node.Table.Qualifier.IsEmpty() {
@@ -113,7 +122,8 @@ func (p *DeletePlan) Build() error {
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
-	return nil
+	p.Querys, err = hints.routeDML(p.Querys, shardkey)
+	return err
 }
 
 // Type returns the type of the plan.
@@ -126,6 +136,7 @@ func (p *DeletePlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
+		Hints      []string              `json:",omitempty"`
 	}
 
 	// Partitions.
@@ -134,6 +145,7 @@ func (p *DeletePlan) JSON() string {
 	exp := &explain{
 		RawQuery:   p.RawQuery,
 		Partitions: parts,
+		Hints:      p.Hints.Strings(),
 	}
 	bout, err := json.MarshalIndent(exp, "", "\t")
 	if err != nil {
@@ -10,6 +10,7 @@ package planner
 
 import (
 	"fmt"
+	"strings"
 
 	"router"
 
@@ -282,8 +283,8 @@ func parserSelectExpr(expr *sqlparser.AliasedExpr, tbInfos map[string]*TableInfo
 			}
 			referTables = append(referTables, tableName)
 		case *sqlparser.FuncExpr:
-			distinct = node.Distinct
 			if node.IsAggregate() {
+				distinct = node.Distinct
 				hasAggregates = true
 				if node != expr.Expr {
 					return false, errors.Errorf("unsupported: '%s'.contain.aggregate.in.select.exprs", field)
@@ -300,7 +301,23 @@ func parserSelectExpr(expr *sqlparser.AliasedExpr, tbInfos map[string]*TableInfo
 				}
 			}
 		case *sqlparser.GroupConcatExpr:
-			return false, errors.Errorf("unsupported: group_concat.in.select.exprs")
+			hasAggregates = true
+			if node != expr.Expr {
+				return false, errors.Errorf("unsupported: '%s'.contain.aggregate.in.select.exprs", field)
+			}
+			funcName = "group_concat"
+			distinct = node.Distinct != ""
+			// group_concat(a, b) is the same as group_concat(concat(a, b)), both skip the NULL.
+			var arg sqlparser.SQLNode = node.Exprs
+			if len(node.Exprs) > 1 {
+				arg = &sqlparser.FuncExpr{Name: sqlparser.NewColIdent("concat"), Exprs: node.Exprs}
+			}
+			buf := sqlparser.NewTrackedBuffer(nil)
+			arg.Format(buf)
+			aggrField = buf.String()
+			if aggrField == "*" {
+				return false, errors.Errorf("unsupported: syntax.error.at.'%s'", field)
+			}
 		case *sqlparser.Subquery:
 			return false, errors.Errorf("unsupported: subqueries.in.select.exprs")
 		}
@@ -329,7 +346,8 @@ func parserSelectExprs(exprs sqlparser.SelectExprs, root SelectNode) ([]selectTu
 			}
 			if hasAgg {
 				hasAggs = true
-				hasDist = hasDist || tuple.distinct
+				// The group_concat cannot be pushed down, the same as the distinct.
+				hasDist = hasDist || tuple.distinct || tuple.aggrFuc == "group_concat"
 			}
 			tuples = append(tuples, *tuple)
 		case *sqlparser.StarExpr:
@@ -468,6 +486,49 @@ func decomposeAgg(tuple *selectTuple) *sqlparser.AliasedExpr {
 	}
 }
 
+// getDistinctGroupBy used to get the group by pushed down to the shards if the aggregate functions
+// cannot be pushed down. If all the aggregate functions are distinct or min/max, the rows can be
+// deduplicated by the group by and the aggregate args on the shards, otherwise returns nil, all
+// the rows should be fetched.
+// eg: select count(distinct a), max(c), b from t group by b
+// ->  select a as `count(distinct a)`, c as `max(c)`, b from t group by b, a, c
+func getDistinctGroupBy(fields []selectTuple, groupBy sqlparser.GroupBy) sqlparser.GroupBy {
+	res := append(sqlparser.GroupBy{}, groupBy...)
+	add := func(expr sqlparser.Expr) {
+		for _, by := range res {
+			if sqlparser.String(by) == sqlparser.String(expr) {
+				return
+			}
+		}
+		res = append(res, expr)
+	}
+
+	for _, tuple := range fields {
+		if tuple.aggrFuc == "" {
+			continue
+		}
+		aggrFuc := strings.ToLower(tuple.aggrFuc)
+		if !tuple.distinct && aggrFuc != "min" && aggrFuc != "max" {
+			return nil
+		}
+
+		var args sqlparser.SelectExprs
+		switch expr := tuple.expr.(*sqlparser.AliasedExpr).Expr.(type) {
+		case *sqlparser.FuncExpr:
+			args = expr.Exprs
+		case *sqlparser.GroupConcatExpr:
+			args = expr.Exprs
+			for _, order := range expr.OrderBy {
+				add(order.Expr)
+			}
+		}
+		for _, arg := range args {
+			add(arg.(*sqlparser.AliasedExpr).Expr)
+		}
+	}
+	return res
+}
+
 // convertToLeftJoin converts a right join into a left join.
 func convertToLeftJoin(joinExpr *sqlparser.JoinTableExpr) {
 	newExpr := joinExpr.LeftExpr
@@ -679,6 +740,477 @@ func checkGroupBy(exprs sqlparser.GroupBy, fields []selectTuple, router *router.
 	return groupTuples, nil
 }
 
+// addHiddenCols used to add the group by and order by(including the order by of the group_concat)
+// exprs which are not in the select list to the end of the select exprs, the non-column exprs are
+// aliased and replaced by the alias.
+// eg: select a from t group by b order by a+1
+// ->  select a, b, a + 1 as tmph_0 from t group by b order by tmph_0 asc
+// Returns the count of the hidden columns.
+func addHiddenCols(node *sqlparser.Select, fields []selectTuple, tbInfos map[string]*TableInfo) (int, error) {
+	hidden := 0
+	addHidden := func(expr sqlparser.Expr, isGroup bool) (sqlparser.Expr, error) {
+		aliasExpr := &sqlparser.AliasedExpr{Expr: expr}
+		switch exp := expr.(type) {
+		case *sqlparser.ColName:
+			ok, tuple := checkInTuple(exp.Name.String(), exp.Qualifier.Name.String(), fields)
+			if ok && (!isGroup || tuple.field != "*") {
+				return expr, nil
+			}
+			// The unknown table will be checked later.
+			if table := exp.Qualifier.Name.String(); table != "" {
+				if _, ok := tbInfos[table]; !ok {
+					return expr, nil
+				}
+			}
+		case *sqlparser.SQLVal:
+			// The position is not supported.
+			return expr, nil
+		default:
+			aliasExpr.As = sqlparser.NewColIdent(fmt.Sprintf("tmph_%d", hidden))
+		}
+
+		buf := sqlparser.NewTrackedBuffer(nil)
+		expr.Format(buf)
+		if node.Distinct != "" {
+			return nil, errors.Errorf("unsupported: distinct.with.[%s].not.in.select.list", buf.String())
+		}
+		tuple, _, err := parserSelectExpr(aliasExpr, tbInfos)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, *tuple)
+		node.SelectExprs = append(node.SelectExprs, aliasExpr)
+		hidden++
+		if aliasExpr.As.IsEmpty() {
+			return expr, nil
+		}
+		return &sqlparser.ColName{Name: aliasExpr.As}, nil
+	}
+
+	var err error
+	// The order by of the group_concat.
+	for _, expr := range node.SelectExprs {
+		aliasExpr, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		concat, ok := aliasExpr.Expr.(*sqlparser.GroupConcatExpr)
+		if !ok || len(concat.OrderBy) == 0 {
+			continue
+		}
+		// Keep the field name.
+		if aliasExpr.As.IsEmpty() {
+			aliasExpr.As = sqlparser.NewColIdent(sqlparser.String(concat))
+		}
+		for _, order := range concat.OrderBy {
+			if order.Expr, err = addHidden(order.Expr, false); err != nil {
+				return 0, err
+			}
+		}
+	}
+	for i, by := range node.GroupBy {
+		if node.GroupBy[i], err = addHidden(by, true); err != nil {
+			return 0, err
+		}
+	}
+	for _, order := range node.OrderBy {
+		if order.Expr, err = addHidden(order.Expr, false); err != nil {
+			return 0, err
+		}
+	}
+	return hidden, nil
+}
+
+// addEvalCols used to pick out the select exprs, order by exprs and having filters which cannot be
+// computed by the backends, they will be evaluated by the proxy after the results merged. The select
+// exprs are replaced by the null placeholders, the aggregate functions and the columns in them are
+// added to the end of the select exprs, the aggregate functions are aliased by their text.
+// eg: select a, sum(b)/count(c) as x from t group by a having max(b) > 1
+// ->  select a, null as x, sum(b) as `sum(b)`, count(c) as `count(c)`, max(b) as `max(b)` from t group by a
+// Returns the projections, the post filters and the count of the hidden columns.
+func addEvalCols(node *sqlparser.Select, root SelectNode) ([]Projection, []sqlparser.Expr, int, error) {
+	// The aggregate functions are computed by the backends.
+	if m, ok := root.(*MergeNode); ok && isGroupByShard(node, m) {
+		return nil, nil, 0, nil
+	}
+
+	var projections []Projection
+	var filters []sqlparser.Expr
+	hidden := 0
+
+	// The select aliases and their exprs, which can be referred by the having.
+	aliases := make(map[string]sqlparser.Expr)
+	for _, expr := range node.SelectExprs {
+		if aliasExpr, ok := expr.(*sqlparser.AliasedExpr); ok && !aliasExpr.As.IsEmpty() {
+			aliases[aliasExpr.As.String()] = aliasExpr.Expr
+		}
+	}
+
+	addProjection := func(aliasExpr *sqlparser.AliasedExpr, alias string, index int) {
+		projections = append(projections, Projection{Field: alias, Index: index, Expr: aliasExpr.Expr})
+		hidden += addLeafCols(node, aliasExpr.Expr, nil)
+		aliasExpr.Expr = &sqlparser.NullVal{}
+		aliasExpr.As = sqlparser.NewColIdent(alias)
+	}
+
+	evals := make(map[string]bool)
+	for i, cnt := 0, len(node.SelectExprs); i < cnt; i++ {
+		aliasExpr, ok := node.SelectExprs[i].(*sqlparser.AliasedExpr)
+		if !ok || !needEval(aliasExpr.Expr, root) {
+			continue
+		}
+		if node.Distinct != "" {
+			return nil, nil, 0, errors.New("unsupported: distinct")
+		}
+		alias := aliasExpr.As.String()
+		if alias == "" {
+			alias = sqlparser.String(aliasExpr.Expr)
+		}
+		evals[alias] = true
+		addProjection(aliasExpr, alias, i)
+	}
+
+	for _, by := range node.GroupBy {
+		if col, ok := by.(*sqlparser.ColName); ok && col.Qualifier.IsEmpty() && evals[col.Name.String()] {
+			return nil, nil, 0, errors.Errorf("unsupported: group.by.field[%s].should.be.in.noaggregate.select.list", col.Name.String())
+		}
+	}
+
+	for i, order := range node.OrderBy {
+		if !needEval(order.Expr, root) {
+			continue
+		}
+		alias := fmt.Sprintf("tmpe_%d", i)
+		aliasExpr := &sqlparser.AliasedExpr{Expr: order.Expr}
+		node.SelectExprs = append(node.SelectExprs, aliasExpr)
+		hidden++
+		addProjection(aliasExpr, alias, len(node.SelectExprs)-1)
+		order.Expr = &sqlparser.ColName{Name: aliasExpr.As}
+	}
+
+	if node.Having != nil {
+		var having sqlparser.Expr
+		for _, filter := range splitAndExpression(nil, node.Having.Expr) {
+			if !needPostFilter(filter, root, aliases) {
+				if having == nil {
+					having = filter
+				} else {
+					having = &sqlparser.AndExpr{Left: having, Right: filter}
+				}
+				continue
+			}
+			hidden += addLeafCols(node, filter, aliases)
+			filters = append(filters, filter)
+		}
+		node.Having = nil
+		if having != nil {
+			node.Having = sqlparser.NewWhere(sqlparser.HavingStr, having)
+		}
+	}
+	return projections, filters, hidden, nil
+}
+
+// addLeafCols used to add the leaves of the expr to the select exprs as the hidden columns,
+// the aggregate functions are aliased by their text, the unqualified columns referring to
+// the aliases are skipped. Returns the number of the added columns.
+func addLeafCols(node *sqlparser.Select, expr sqlparser.Expr, aliases map[string]sqlparser.Expr) int {
+	added := 0
+	addLeaf := func(expr sqlparser.Expr, isAggr bool) {
+		text := sqlparser.String(expr)
+		for _, e := range node.SelectExprs {
+			if aliasExpr, ok := e.(*sqlparser.AliasedExpr); ok && sqlparser.String(aliasExpr.Expr) == text {
+				if aliasExpr.As.IsEmpty() || aliasExpr.As.String() == text {
+					return
+				}
+			}
+		}
+		aliasExpr := &sqlparser.AliasedExpr{Expr: expr}
+		if isAggr {
+			aliasExpr.As = sqlparser.NewColIdent(text)
+		}
+		node.SelectExprs = append(node.SelectExprs, aliasExpr)
+		added++
+	}
+	_ = sqlparser.Walk(func(n sqlparser.SQLNode) (kontinue bool, err error) {
+		switch n := n.(type) {
+		case *sqlparser.FuncExpr:
+			if n.IsAggregate() {
+				addLeaf(n, true)
+				return false, nil
+			}
+		case *sqlparser.GroupConcatExpr:
+			addLeaf(n, true)
+			return false, nil
+		case *sqlparser.ColName:
+			if n.Qualifier.IsEmpty() {
+				if _, ok := aliases[n.Name.String()]; ok {
+					return false, nil
+				}
+			}
+			addLeaf(n, false)
+		}
+		return true, nil
+	}, expr)
+	return added
+}
+
+// addWindowCols used to pick out the window functions computed by the proxy, the windows are
+// replaced by the null placeholders and their leaves are added as the hidden columns.
+// The windows partitioned by the shard key are computed by the backends.
+func addWindowCols(node *sqlparser.Select, root SelectNode) ([]Window, int, error) {
+	if err := checkWindowClauses(node); err != nil {
+		return nil, 0, err
+	}
+
+	var windows []Window
+	for i, expr := range node.SelectExprs {
+		aliasExpr, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if fn, ok := aliasExpr.Expr.(*sqlparser.FuncExpr); ok && fn.IsWindow() {
+			if hasWindow(fn.Exprs) || hasWindow(fn.Over) {
+				return nil, 0, errors.Errorf("unsupported: window.function.in.window.function.'%s'", sqlparser.String(fn))
+			}
+			alias := aliasExpr.As.String()
+			if alias == "" {
+				alias = sqlparser.String(fn)
+			}
+			windows = append(windows, Window{Field: alias, Index: i, Func: fn})
+			continue
+		}
+		if hasWindow(aliasExpr.Expr) {
+			return nil, 0, errors.Errorf("unsupported: '%s'.contain.window.function.in.select.exprs", sqlparser.String(aliasExpr.Expr))
+		}
+	}
+	if len(windows) == 0 {
+		return nil, 0, nil
+	}
+
+	if m, ok := root.(*MergeNode); ok && isWindowPushable(node, windows, m) {
+		return nil, 0, nil
+	}
+	// The distinct is done before the windows computed.
+	if node.Distinct != "" {
+		return nil, 0, errors.New("unsupported: distinct")
+	}
+
+	hidden := 0
+	for _, w := range windows {
+		hidden += addLeafCols(node, w.Func, nil)
+		aliasExpr := node.SelectExprs[w.Index].(*sqlparser.AliasedExpr)
+		aliasExpr.Expr = &sqlparser.NullVal{}
+		aliasExpr.As = sqlparser.NewColIdent(w.Field)
+	}
+	return windows, hidden, nil
+}
+
+// checkWindowClauses used to check the window functions out of the select exprs.
+func checkWindowClauses(node *sqlparser.Select) error {
+	if node.Where != nil && hasWindow(node.Where.Expr) {
+		return errors.New("unsupported: window.function.in.where.clause")
+	}
+	for _, by := range node.GroupBy {
+		if hasWindow(by) {
+			return errors.New("unsupported: window.function.in.group.by.clause")
+		}
+	}
+	if node.Having != nil && hasWindow(node.Having.Expr) {
+		return errors.New("unsupported: window.function.in.having.clause")
+	}
+	for _, order := range node.OrderBy {
+		if hasWindow(order.Expr) {
+			return errors.New("unsupported: window.function.in.order.by.clause")
+		}
+	}
+	return nil
+}
+
+// isWindowPushable used to check whether the windows can be computed by the backends, that is
+// all the windows are partitioned by the shard key, and the rows in a group are in one backend.
+func isWindowPushable(node *sqlparser.Select, windows []Window, m *MergeNode) bool {
+	aggregated := len(node.GroupBy) > 0
+	for _, expr := range node.SelectExprs {
+		if aliasExpr, ok := expr.(*sqlparser.AliasedExpr); ok && hasAggregate(aliasExpr.Expr) {
+			aggregated = true
+		}
+	}
+	if aggregated && !isGroupByShard(node, m) {
+		return false
+	}
+	for _, w := range windows {
+		// The aggregates in the window are computed before the window.
+		if hasAggregate(w.Func) || !hasShardKey(w.Func.Over.PartitionBy, m) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasWindow used to check whether the node contains the window functions.
+func hasWindow(expr sqlparser.SQLNode) bool {
+	has := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if fn, ok := node.(*sqlparser.FuncExpr); ok && fn.IsWindow() {
+			has = true
+			return false, nil
+		}
+		return true, nil
+	}, expr)
+	return has
+}
+
+// isGroupByShard used to check whether the group by contains the shardkey, then
+// the rows in a group are all in one backend.
+func isGroupByShard(node *sqlparser.Select, m *MergeNode) bool {
+	var exprs []sqlparser.Expr
+	for _, by := range node.GroupBy {
+		if col, ok := by.(*sqlparser.ColName); ok && col.Qualifier.IsEmpty() {
+			for _, expr := range node.SelectExprs {
+				if aliasExpr, ok := expr.(*sqlparser.AliasedExpr); ok && aliasExpr.As.String() == col.Name.String() {
+					if c, ok := aliasExpr.Expr.(*sqlparser.ColName); ok {
+						by = c
+					}
+					break
+				}
+			}
+		}
+		exprs = append(exprs, by)
+	}
+	return hasShardKey(exprs, m)
+}
+
+// hasShardKey used to check whether the exprs contain the shardkey column.
+func hasShardKey(exprs []sqlparser.Expr, m *MergeNode) bool {
+	tbInfos := m.getReferredTables()
+	for _, expr := range exprs {
+		col, ok := expr.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+		table := col.Qualifier.Name.String()
+		if table == "" {
+			if len(tbInfos) != 1 {
+				continue
+			}
+			table, _ = getOneTableInfo(tbInfos)
+		}
+		if _, ok := tbInfos[table]; !ok {
+			continue
+		}
+		if ok, _ := checkShard(table, col.Name.String(), tbInfos, m.router); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// needEval used to check whether the select expr should be evaluated by the proxy, such as
+// the expr contains the aggregate functions, or crosses the nodes of the join.
+func needEval(expr sqlparser.Expr, root SelectNode) bool {
+	if _, ok := expr.(*sqlparser.ColName); ok {
+		return false
+	}
+	if hasAggregate(expr) {
+		return !isAggregate(expr)
+	}
+	j, ok := root.(*JoinNode)
+	if !ok {
+		return false
+	}
+	tbs := getTbInExpr(expr)
+	if !checkTbInNode(tbs, j.getReferredTables()) {
+		// The unknown column will be checked later.
+		return false
+	}
+	_, isFunc := expr.(*sqlparser.FuncExpr)
+	return !isPushable(j, tbs, isFunc)
+}
+
+// isPushable used to check whether the expr can be pushed down to one node, the same as pushSelectExpr.
+func isPushable(node SelectNode, tbs []string, isFunc bool) bool {
+	j, ok := node.(*JoinNode)
+	if !ok {
+		return true
+	}
+	// The function on the both sides of the full join may return non-null value for the null row.
+	if j.IsFullJoin && isFunc {
+		return false
+	}
+	if checkTbInNode(tbs, j.Left.getReferredTables()) {
+		return isPushable(j.Left, tbs, isFunc)
+	}
+	// The function on the right of the left join may return non-null value for the null row.
+	if j.IsLeftJoin && isFunc {
+		return false
+	}
+	if checkTbInNode(tbs, j.Right.getReferredTables()) || j.isHint {
+		return isPushable(j.Right, tbs, isFunc)
+	}
+	return false
+}
+
+// needPostFilter used to check whether the having filter should be evaluated by the proxy, such as
+// the filter contains the aggregate functions, or crosses the nodes of the join.
+func needPostFilter(filter sqlparser.Expr, root SelectNode, aliases map[string]sqlparser.Expr) bool {
+	if hasAggregate(filter) {
+		return true
+	}
+
+	j, isJoin := root.(*JoinNode)
+	refer := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if col, ok := node.(*sqlparser.ColName); ok && col.Qualifier.IsEmpty() {
+			if expr, ok := aliases[col.Name.String()]; ok && (isJoin || hasAggregate(expr)) {
+				refer = true
+				return false, nil
+			}
+		}
+		return true, nil
+	}, filter)
+	if refer || !isJoin || j.isHint {
+		return refer
+	}
+
+	tbs := getTbInExpr(filter)
+	if !checkTbInNode(tbs, j.getReferredTables()) {
+		return false
+	}
+	var parent *MergeNode
+	for _, tb := range tbs {
+		p := j.referredTables[tb].parent
+		if parent != nil && parent != p {
+			return true
+		}
+		parent = p
+	}
+	return false
+}
+
+// hasAggregate used to check whether the expr contains the aggregate functions.
+func hasAggregate(expr sqlparser.Expr) bool {
+	has := false
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if isAggregate(node) {
+			has = true
+			return false, nil
+		}
+		return true, nil
+	}, expr)
+	return has
+}
+
+// isAggregate used to check whether the node is an aggregate function.
+func isAggregate(node sqlparser.SQLNode) bool {
+	switch node := node.(type) {
+	case *sqlparser.FuncExpr:
+		return node.IsAggregate()
+	case *sqlparser.GroupConcatExpr:
+		return true
+	}
+	return false
+}
+
 // checkDistinct used to check the distinct, and convert distinct to groupby.
 func checkDistinct(node *sqlparser.Select, groups, fields []selectTuple, router *router.Router, tbInfos map[string]*TableInfo, canOpt bool) ([]selectTuple, error) {
 	// field in grouby must be contained in the select exprs, that mains groups is a subset of fields.
@@ -828,8 +1360,12 @@ func checkShard(table, col string, tbInfos map[string]*TableInfo, router *router
 	return false, nil
 }
 
-// getIndex used to get index from router.
+// getIndex used to get index from router. If the val is a bind variable, the index is got when it's bound.
 func getIndex(router *router.Router, tbInfo *TableInfo, val *sqlparser.SQLVal) error {
+	if val.Type == sqlparser.ValArg {
+		tbInfo.parent.routeArgs = append(tbInfo.parent.routeArgs, routeArg{tbInfo: tbInfo, name: string(val.Val[1:])})
+		return nil
+	}
 	idx, err := router.GetIndex(tbInfo.database, tbInfo.tableName, val)
 	if err != nil {
 		return err
@@ -849,3 +1385,137 @@ func getSelectExprs(node sqlparser.SelectStatement) sqlparser.SelectExprs {
 	}
 	return exprs
 }
+
+// replaceColNames used to replace the columns in the expr, the column is replaced if the fn
+// returns non-nil. Returns the replaced expr.
+func replaceColNames(expr sqlparser.Expr, fn func(col *sqlparser.ColName) sqlparser.Expr) sqlparser.Expr {
+	replace := func(expr sqlparser.Expr) sqlparser.Expr {
+		return replaceColNames(expr, fn)
+	}
+	replaceExprs := func(exprs sqlparser.SelectExprs) {
+		for _, expr := range exprs {
+			if aliasExpr, ok := expr.(*sqlparser.AliasedExpr); ok {
+				aliasExpr.Expr = replace(aliasExpr.Expr)
+			}
+		}
+	}
+
+	switch node := expr.(type) {
+	case *sqlparser.ColName:
+		if res := fn(node); res != nil {
+			return res
+		}
+	case *sqlparser.AndExpr:
+		node.Left, node.Right = replace(node.Left), replace(node.Right)
+	case *sqlparser.OrExpr:
+		node.Left, node.Right = replace(node.Left), replace(node.Right)
+	case *sqlparser.NotExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.ParenExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.ComparisonExpr:
+		node.Left, node.Right = replace(node.Left), replace(node.Right)
+		if node.Escape != nil {
+			node.Escape = replace(node.Escape)
+		}
+	case *sqlparser.RangeCond:
+		node.Left, node.From, node.To = replace(node.Left), replace(node.From), replace(node.To)
+	case *sqlparser.IsExpr:
+		node.Expr = replace(node.Expr)
+	case sqlparser.ValTuple:
+		for i, e := range node {
+			node[i] = replace(e)
+		}
+	case *sqlparser.BinaryExpr:
+		node.Left, node.Right = replace(node.Left), replace(node.Right)
+	case *sqlparser.UnaryExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.IntervalExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.CollateExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.FuncExpr:
+		replaceExprs(node.Exprs)
+		if node.Over != nil {
+			for i, e := range node.Over.PartitionBy {
+				node.Over.PartitionBy[i] = replace(e)
+			}
+			for _, order := range node.Over.OrderBy {
+				order.Expr = replace(order.Expr)
+			}
+		}
+	case *sqlparser.GroupConcatExpr:
+		replaceExprs(node.Exprs)
+		for _, order := range node.OrderBy {
+			order.Expr = replace(order.Expr)
+		}
+	case *sqlparser.CaseExpr:
+		if node.Expr != nil {
+			node.Expr = replace(node.Expr)
+		}
+		for _, when := range node.Whens {
+			when.Cond, when.Val = replace(when.Cond), replace(when.Val)
+		}
+		if node.Else != nil {
+			node.Else = replace(node.Else)
+		}
+	case *sqlparser.ConvertExpr:
+		node.Expr = replace(node.Expr)
+	case *sqlparser.ConvertUsingExpr:
+		node.Expr = replace(node.Expr)
+	}
+	return expr
+}
+
+// splitPostFilters used to split out the where filters referring to the tables of the full joins,
+// they cannot be pushed down and are evaluated by the proxy after the joins.
+func splitPostFilters(root SelectNode, joins []joinTuple, filters []filterTuple) ([]joinTuple, []filterTuple, []sqlparser.Expr) {
+	tbs := make(map[string]bool)
+	getFullJoinTables(root, tbs)
+	if len(tbs) == 0 {
+		return joins, filters, nil
+	}
+
+	refer := func(referTables []string) bool {
+		for _, tb := range referTables {
+			if tbs[tb] {
+				return true
+			}
+		}
+		return false
+	}
+	var posts []sqlparser.Expr
+	var pushJoins []joinTuple
+	var pushFilters []filterTuple
+	for _, join := range joins {
+		if refer(join.referTables) {
+			posts = append(posts, join.expr)
+			continue
+		}
+		pushJoins = append(pushJoins, join)
+	}
+	for _, filter := range filters {
+		if refer(filter.referTables) {
+			posts = append(posts, filter.expr)
+			continue
+		}
+		pushFilters = append(pushFilters, filter)
+	}
+	return pushJoins, pushFilters, posts
+}
+
+// getFullJoinTables used to get the tables under the full joins.
+func getFullJoinTables(node SelectNode, tbs map[string]bool) {
+	j, ok := node.(*JoinNode)
+	if !ok {
+		return
+	}
+	if j.IsFullJoin {
+		for tb := range j.referredTables {
+			tbs[tb] = true
+		}
+		return
+	}
+	getFullJoinTables(j.Left, tbs)
+	getFullJoinTables(j.Right, tbs)
+}
@@ -0,0 +1,78 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"encoding/json"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Plan = &FilterPlan{}
+)
+
+// FilterPlan represents the filter plan, the rows are filtered by the proxy
+// after the results merged, such as the having clause with the aggregates.
+type FilterPlan struct {
+	log *xlog.Log
+
+	// Filters are the conditions that the rows must satisfy.
+	Filters []sqlparser.Expr
+
+	// type
+	typ PlanType
+}
+
+// NewFilterPlan used to create FilterPlan.
+func NewFilterPlan(log *xlog.Log, filters []sqlparser.Expr) *FilterPlan {
+	return &FilterPlan{
+		log:     log,
+		Filters: filters,
+		typ:     PlanTypeFilter,
+	}
+}
+
+// Build used to build distributed querys.
+func (p *FilterPlan) Build() error {
+	return nil
+}
+
+// Type returns the type of the plan.
+func (p *FilterPlan) Type() PlanType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *FilterPlan) JSON() string {
+	type explain struct {
+		Filters []string
+	}
+
+	exp := &explain{}
+	for _, filter := range p.Filters {
+		exp.Filters = append(exp.Filters, sqlparser.String(filter))
+	}
+	bout, err := json.MarshalIndent(exp, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return string(bout)
+}
+
+// Children returns the children of the plan.
+func (p *FilterPlan) Children() *PlanTree {
+	return nil
+}
+
+// Size returns the memory size.
+func (p *FilterPlan) Size() int {
+	return 0
+}
@@ -0,0 +1,39 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestFilterPlan(t *testing.T) {
+	query := "select a from t having count(*) > 1 and sum(b)/count(b) < 10"
+	want := `{
+	"Filters": [
+		"count(*) \u003e 1",
+		"sum(b) / count(b) \u003c 10"
+	]
+}`
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tree, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	node := tree.(*sqlparser.Select)
+	plan := NewFilterPlan(log, splitAndExpression(nil, node.Having.Expr))
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, want, plan.JSON())
+	assert.Nil(t, plan.Children())
+	assert.Equal(t, PlanTypeFilter, plan.Type())
+	assert.Equal(t, 0, plan.Size())
+}
@@ -139,7 +139,8 @@ func scanAliasedTableExpr(log *xlog.Log, r *router.Router, database string, tabl
 // scanJoinTableExpr produces a SelectNode subtree by the JoinTableExpr.
 func scanJoinTableExpr(log *xlog.Log, router *router.Router, database string, joinExpr *sqlparser.JoinTableExpr) (SelectNode, error) {
 	switch joinExpr.Join {
-	case sqlparser.JoinStr, sqlparser.StraightJoinStr, sqlparser.LeftJoinStr:
+	case sqlparser.JoinStr, sqlparser.StraightJoinStr, sqlparser.LeftJoinStr, sqlparser.FullOuterJoinStr:
+	case sqlparser.NaturalJoinStr, sqlparser.NaturalLeftJoinStr, sqlparser.NaturalRightJoinStr:
 	case sqlparser.RightJoinStr:
 		convertToLeftJoin(joinExpr)
 	default:
@@ -175,6 +176,9 @@ func join(log *xlog.Log, lpn, rpn SelectNode, joinExpr *sqlparser.JoinTableExpr,
 		}
 		referredTables[k] = v
 	}
+	if joinExpr != nil && isNaturalJoin(joinExpr) {
+		return naturalJoin(lpn, rpn, joinExpr)
+	}
 	if joinExpr != nil {
 		if joinExpr.On == nil {
 			joinExpr = nil
@@ -189,6 +193,11 @@ func join(log *xlog.Log, lpn, rpn SelectNode, joinExpr *sqlparser.JoinTableExpr,
 				joinOn[i] = jt
 			}
 
+			// full join only supports the equal conditions between the left and right.
+			if joinExpr.Join == sqlparser.FullOuterJoinStr && len(otherJoinOn) > 0 {
+				return nil, errors.Errorf("unsupported: clause.'%s'.in.full.join", sqlparser.String(otherJoinOn[0].expr))
+			}
+
 			// inner join's other join on would add to where.
 			if joinExpr.Join != sqlparser.LeftJoinStr && len(otherJoinOn) > 0 {
 				if len(joinOn) == 0 {
@@ -208,8 +217,9 @@ func join(log *xlog.Log, lpn, rpn SelectNode, joinExpr *sqlparser.JoinTableExpr,
 		}
 	}
 
-	// analyse if can be merged.
-	if lmn, ok := lpn.(*MergeNode); ok {
+	// analyse if can be merged, the full join cannot be pushed down.
+	isFullJoin := joinExpr != nil && joinExpr.Join == sqlparser.FullOuterJoinStr
+	if lmn, ok := lpn.(*MergeNode); ok && !isFullJoin {
 		if rmn, ok := rpn.(*MergeNode); ok {
 			// if all of left's or right's tables are global tables.
 			if lmn.nonGlobalCnt == 0 || rmn.nonGlobalCnt == 0 {
@@ -289,3 +299,287 @@ func isSameShard(ltb, rtb map[string]*TableInfo, lcn, rcn *sqlparser.ColName) bo
 	}
 	return true
 }
+
+// isNaturalJoin returns true if the join is a natural join.
+func isNaturalJoin(joinExpr *sqlparser.JoinTableExpr) bool {
+	switch joinExpr.Join {
+	case sqlparser.NaturalJoinStr, sqlparser.NaturalLeftJoinStr, sqlparser.NaturalRightJoinStr:
+		return true
+	}
+	return false
+}
+
+// naturalJoin used to merge the natural join, the common columns are unknown by the proxy,
+// so the join must be pushed down. It can be pushed down if one side are all global tables,
+// or the tables of the both sides have the same shardkey and shards.
+func naturalJoin(lpn, rpn SelectNode, joinExpr *sqlparser.JoinTableExpr) (SelectNode, error) {
+	if lmn, ok := lpn.(*MergeNode); ok {
+		if rmn, ok := rpn.(*MergeNode); ok {
+			if lmn.nonGlobalCnt == 0 || rmn.nonGlobalCnt == 0 {
+				return mergeRoutes(lmn, rmn, joinExpr, nil)
+			}
+			for ltb, lt := range lmn.referredTables {
+				for rtb, rt := range rmn.referredTables {
+					if lt.shardKey == "" || lt.shardKey != rt.shardKey {
+						continue
+					}
+					lcn := &sqlparser.ColName{Name: sqlparser.NewColIdent(lt.shardKey), Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(ltb)}}
+					rcn := &sqlparser.ColName{Name: sqlparser.NewColIdent(rt.shardKey), Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(rtb)}}
+					if isSameShard(lmn.referredTables, rmn.referredTables, lcn, rcn) {
+						return mergeRoutes(lmn, rmn, joinExpr, nil)
+					}
+				}
+			}
+		}
+	}
+	return nil, errors.New("unsupported: natural.join.in.cross-shard.query")
+}
+
+// joinScope is the tables and the coalesced columns of the table expr in the FROM clause.
+type joinScope struct {
+	// tables are the tables' names or aliases.
+	tables []string
+	// shardKeys are the tables' shardkeys.
+	shardKeys map[string]string
+	// coalesced are the columns of the USING and NATURAL joins, the key is the lowered column name.
+	// The value builds the column expr, such as 't1.a' or 'coalesce(t1.a, t2.a)' for full join.
+	coalesced map[string]func() sqlparser.Expr
+}
+
+func newJoinScope() *joinScope {
+	return &joinScope{
+		shardKeys: make(map[string]string),
+		coalesced: make(map[string]func() sqlparser.Expr),
+	}
+}
+
+// merge used to merge the scope s into the js.
+func (js *joinScope) merge(s *joinScope) {
+	js.tables = append(js.tables, s.tables...)
+	for k, v := range s.shardKeys {
+		js.shardKeys[k] = v
+	}
+	for k, v := range s.coalesced {
+		if _, ok := js.coalesced[k]; !ok {
+			js.coalesced[k] = v
+		}
+	}
+}
+
+// column returns the builder of the column in the scope.
+func (js *joinScope) column(col sqlparser.ColIdent) (func() sqlparser.Expr, error) {
+	if expr, ok := js.coalesced[col.Lowered()]; ok {
+		return expr, nil
+	}
+	if len(js.tables) != 1 {
+		return nil, errors.Errorf("unsupported: column.'%s'.in.using.clause.is.ambiguous", col.String())
+	}
+	return js.tableColumn(js.tables[0], col), nil
+}
+
+// tableColumn returns the builder of the table's column, the coalesced column takes precedence.
+func (js *joinScope) tableColumn(table string, col sqlparser.ColIdent) func() sqlparser.Expr {
+	if expr, ok := js.coalesced[col.Lowered()]; ok {
+		return expr
+	}
+	return func() sqlparser.Expr {
+		return &sqlparser.ColName{Name: col, Qualifier: sqlparser.TableName{Name: sqlparser.NewTableIdent(table)}}
+	}
+}
+
+// replace returns the coalesced column expr, nil if the col is not a coalesced column.
+func (js *joinScope) replace(col *sqlparser.ColName) sqlparser.Expr {
+	if col.Qualifier.IsEmpty() {
+		if expr, ok := js.coalesced[col.Name.Lowered()]; ok {
+			return expr()
+		}
+	}
+	return nil
+}
+
+// qualify returns the qualified column, the coalesced column takes precedence,
+// nil if the col is qualified or the scope has more than one table.
+func (js *joinScope) qualify(col *sqlparser.ColName) sqlparser.Expr {
+	if expr := js.replace(col); expr != nil {
+		return expr
+	}
+	if col.Qualifier.IsEmpty() && len(js.tables) == 1 {
+		return js.tableColumn(js.tables[0], col.Name)()
+	}
+	return nil
+}
+
+/* resolveJoinColumns used to resolve the USING and NATURAL joins in the FROM clause.
+ * The USING columns are converted to the ON conditions, and the unqualified columns
+ * referring to the coalesced columns are rewritten to the qualified columns.
+ * eg: select id from t1 left join t2 using(id) where id>1;
+ * ->  select t1.id as id from t1 left join t2 using(id) on t1.id=t2.id where t1.id>1;
+ * The coalesced column of the full join is 'coalesce(t1.id, t2.id)'.
+ */
+func resolveJoinColumns(r *router.Router, database string, node *sqlparser.Select) error {
+	scope, err := scanJoinScopes(r, database, node.From)
+	if err != nil || len(scope.coalesced) == 0 {
+		return err
+	}
+
+	replaceColumns(node, scope.replace)
+	return nil
+}
+
+// replaceColumns used to replace the columns of the select by the fn, the select aliases
+// take precedence over the columns in the group by, having and order by.
+func replaceColumns(node *sqlparser.Select, fn func(col *sqlparser.ColName) sqlparser.Expr) {
+	aliases := make(map[string]bool)
+	for _, expr := range node.SelectExprs {
+		aliasExpr, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		if col, ok := aliasExpr.Expr.(*sqlparser.ColName); ok && aliasExpr.As.IsEmpty() {
+			// Keep the field name.
+			if _, ok := fn(col).(*sqlparser.FuncExpr); ok {
+				aliasExpr.As = col.Name
+			}
+		}
+		aliasExpr.Expr = replaceColNames(aliasExpr.Expr, fn)
+		if !aliasExpr.As.IsEmpty() {
+			aliases[aliasExpr.As.Lowered()] = true
+		}
+	}
+	if node.Where != nil {
+		node.Where.Expr = replaceColNames(node.Where.Expr, fn)
+	}
+
+	replace := func(col *sqlparser.ColName) sqlparser.Expr {
+		if col.Qualifier.IsEmpty() && aliases[col.Name.Lowered()] {
+			return nil
+		}
+		return fn(col)
+	}
+	for i, by := range node.GroupBy {
+		node.GroupBy[i] = replaceColNames(by, replace)
+	}
+	if node.Having != nil {
+		node.Having.Expr = replaceColNames(node.Having.Expr, replace)
+	}
+	for _, order := range node.OrderBy {
+		order.Expr = replaceColNames(order.Expr, replace)
+	}
+}
+
+// scanJoinScopes used to build the scope of the TableExprs.
+func scanJoinScopes(r *router.Router, database string, tableExprs sqlparser.TableExprs) (*joinScope, error) {
+	scope := newJoinScope()
+	for _, tableExpr := range tableExprs {
+		s, err := scanJoinScope(r, database, tableExpr)
+		if err != nil {
+			return nil, err
+		}
+		scope.merge(s)
+	}
+	return scope, nil
+}
+
+// scanJoinScope used to build the scope of the TableExpr, and convert the USING to ON conditions.
+func scanJoinScope(r *router.Router, database string, tableExpr sqlparser.TableExpr) (*joinScope, error) {
+	switch tableExpr := tableExpr.(type) {
+	case *sqlparser.AliasedTableExpr:
+		scope := newJoinScope()
+		name := tableExpr.As.String()
+		if expr, ok := tableExpr.Expr.(sqlparser.TableName); ok {
+			if name == "" {
+				name = expr.Name.String()
+			}
+			db := database
+			if !expr.Qualifier.IsEmpty() {
+				db = expr.Qualifier.String()
+			}
+			if conf, err := r.TableConfig(db, expr.Name.String()); err == nil {
+				scope.shardKeys[name] = conf.ShardKey
+			}
+		}
+		scope.tables = append(scope.tables, name)
+		return scope, nil
+	case *sqlparser.ParenTableExpr:
+		return scanJoinScopes(r, database, tableExpr.Exprs)
+	case *sqlparser.JoinTableExpr:
+		return scanJoinTableScope(r, database, tableExpr)
+	}
+	return newJoinScope(), nil
+}
+
+// scanJoinTableScope used to build the scope of the JoinTableExpr.
+func scanJoinTableScope(r *router.Router, database string, joinExpr *sqlparser.JoinTableExpr) (*joinScope, error) {
+	left, err := scanJoinScope(r, database, joinExpr.LeftExpr)
+	if err != nil {
+		return nil, err
+	}
+	right, err := scanJoinScope(r, database, joinExpr.RightExpr)
+	if err != nil {
+		return nil, err
+	}
+	scope := newJoinScope()
+	scope.merge(left)
+	scope.merge(right)
+
+	// The coalesced column of the join, the value of the inner or left join is the left column,
+	// the right join is the right column, the full join is the first non-null value of them.
+	coalesce := func(lexpr, rexpr func() sqlparser.Expr) func() sqlparser.Expr {
+		switch joinExpr.Join {
+		case sqlparser.RightJoinStr, sqlparser.NaturalRightJoinStr:
+			return rexpr
+		case sqlparser.FullOuterJoinStr:
+			return func() sqlparser.Expr {
+				return &sqlparser.FuncExpr{
+					Name: sqlparser.NewColIdent("coalesce"),
+					Exprs: sqlparser.SelectExprs{
+						&sqlparser.AliasedExpr{Expr: lexpr()},
+						&sqlparser.AliasedExpr{Expr: rexpr()},
+					},
+				}
+			}
+		}
+		return lexpr
+	}
+
+	switch {
+	case len(joinExpr.Using) > 0:
+		var on sqlparser.Expr
+		for _, col := range joinExpr.Using {
+			lexpr, err := left.column(col)
+			if err != nil {
+				return nil, err
+			}
+			rexpr, err := right.column(col)
+			if err != nil {
+				return nil, err
+			}
+			cond := &sqlparser.ComparisonExpr{Operator: sqlparser.EqualStr, Left: lexpr(), Right: rexpr()}
+			if on == nil {
+				on = cond
+			} else {
+				on = &sqlparser.AndExpr{Left: on, Right: cond}
+			}
+			scope.coalesced[col.Lowered()] = coalesce(lexpr, rexpr)
+		}
+		joinExpr.On = on
+	case isNaturalJoin(joinExpr):
+		// Only the shardkey is known as the common column.
+		for _, ltb := range left.tables {
+			for _, rtb := range right.tables {
+				key := left.shardKeys[ltb]
+				if key == "" || key != right.shardKeys[rtb] {
+					continue
+				}
+				col := sqlparser.NewColIdent(key)
+				if _, ok := scope.coalesced[col.Lowered()]; ok {
+					continue
+				}
+				scope.coalesced[col.Lowered()] = coalesce(left.tableColumn(ltb, col), right.tableColumn(rtb, col))
+			}
+		}
+	case joinExpr.On != nil:
+		joinExpr.On = replaceColNames(joinExpr.On, scope.replace)
+	}
+	return scope, nil
+}
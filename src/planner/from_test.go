@@ -372,7 +372,7 @@ func TestScanTableExprsError(t *testing.T) {
 	wants := []string{
 		"Table 'C' doesn't exist (errno 1146) (sqlstate 42S02)",
 		"unsupported: subquery.in.select",
-		"unsupported: join.type:natural join",
+		"unsupported: natural.join.in.cross-shard.query",
 		"unsupported: unknown.column.'id'.in.clause",
 		"unsupported: unknown.table.'C'.in.clause",
 		"unsupported: unknown.table.'C'.in.clause",
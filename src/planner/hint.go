@@ -0,0 +1,220 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
+)
+
+var (
+	hintCommentRE = regexp.MustCompile(`^/\*\+(.*)\*/$`)
+	hintRE        = regexp.MustCompile(`(\w+)\s*(\(([^)]*)\))?`)
+)
+
+// Hints are the optimizer hints in the comments of the statement, such as
+// '/*+ RADON_SHARD(backend1) NESTED_LOOP(a, b) NO_TWOPC */'.
+// The unknown hints are ignored, like MySQL.
+type Hints struct {
+	// Shard is the backend from RADON_SHARD(backend), the query is only sent to
+	// the partitions on the backend.
+	Shard string
+	// NestedLoop is true if the NESTED_LOOP hint is set, the joins between the
+	// NestedLoopTables are done by the nested loop, all the joins if it's empty.
+	NestedLoop       bool
+	NestedLoopTables []string
+	// NoTwoPC is true if the NO_TWOPC hint is set, the DML is executed without
+	// the distributed transaction, it's best-effort.
+	NoTwoPC bool
+}
+
+// ParseHints used to parse the hints in the comments, the '/*+nested+*/' is
+// the same as '/*+ NESTED_LOOP */'.
+func ParseHints(comments sqlparser.Comments) (*Hints, error) {
+	hints := &Hints{}
+	for _, comment := range comments {
+		matches := hintCommentRE.FindStringSubmatch(common.BytesToString(comment))
+		if matches == nil {
+			continue
+		}
+		for _, hint := range hintRE.FindAllStringSubmatch(matches[1], -1) {
+			var args []string
+			for _, arg := range strings.Split(hint[3], ",") {
+				if arg = strings.Trim(strings.TrimSpace(arg), "`"); arg != "" {
+					args = append(args, arg)
+				}
+			}
+
+			switch strings.ToUpper(hint[1]) {
+			case "RADON_SHARD":
+				if len(args) != 1 {
+					return nil, errors.Errorf("unsupported: hint.RADON_SHARD.requires.one.backend.but.got[%s]", hint[3])
+				}
+				hints.Shard = args[0]
+			case "NESTED_LOOP", "NESTED":
+				hints.NestedLoop = true
+				hints.NestedLoopTables = append(hints.NestedLoopTables, args...)
+			case "NO_TWOPC":
+				hints.NoTwoPC = true
+			}
+		}
+	}
+	return hints, nil
+}
+
+// GetHints returns the hints of the statement, nil if the statement has no comments.
+func GetHints(node sqlparser.Statement) (*Hints, error) {
+	var comments sqlparser.Comments
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		comments = node.Comments
+	case *sqlparser.Insert:
+		comments = node.Comments
+	case *sqlparser.Update:
+		comments = node.Comments
+	case *sqlparser.Delete:
+		comments = node.Comments
+	}
+	if len(comments) == 0 {
+		return nil, nil
+	}
+	return ParseHints(comments)
+}
+
+// Strings returns the hints in the normalized form, used by the EXPLAIN.
+func (h *Hints) Strings() []string {
+	if h == nil {
+		return nil
+	}
+	var hints []string
+	if h.Shard != "" {
+		hints = append(hints, fmt.Sprintf("RADON_SHARD(%s)", h.Shard))
+	}
+	switch {
+	case h.NestedLoop && len(h.NestedLoopTables) == 0:
+		hints = append(hints, "NESTED_LOOP")
+	case h.NestedLoop:
+		hints = append(hints, fmt.Sprintf("NESTED_LOOP(%s)", strings.Join(h.NestedLoopTables, ", ")))
+	}
+	if h.NoTwoPC {
+		hints = append(hints, "NO_TWOPC")
+	}
+	return hints
+}
+
+// nestedLoop returns true if the join of the left and right tables is done by the
+// nested loop. With the tables, the join must have one of them on each side, or the
+// only one on the right side.
+func (h *Hints) nestedLoop(left, right map[string]*TableInfo) bool {
+	if h == nil || !h.NestedLoop {
+		return false
+	}
+	if len(h.NestedLoopTables) == 0 {
+		return true
+	}
+
+	var inLeft, inRight bool
+	for _, table := range h.NestedLoopTables {
+		if _, ok := left[table]; ok {
+			inLeft = true
+		}
+		if _, ok := right[table]; ok {
+			inRight = true
+		}
+	}
+	return inRight && (inLeft || len(h.NestedLoopTables) == 1)
+}
+
+// route returns the indexes of the querys on the hint shard. If all the tables are
+// global, the query is sent to the shard.
+func (h *Hints) route(querys []xcontext.QueryTuple, global bool) ([]int, error) {
+	if global && len(querys) == 1 {
+		querys[0].Backend = h.Shard
+		return []int{0}, nil
+	}
+
+	var idxs []int
+	for i, query := range querys {
+		if query.Backend == h.Shard {
+			idxs = append(idxs, i)
+		}
+	}
+	if len(idxs) == 0 {
+		return nil, errors.Errorf("unsupported: hint.RADON_SHARD.backend[%s].has.no.partitions.of.the.query", h.Shard)
+	}
+	return idxs, nil
+}
+
+// routeDML returns the querys of the DML on the hint shard. The global table is
+// unsupported, its copies on the backends must be the same.
+func (h *Hints) routeDML(querys []xcontext.QueryTuple, shardKey string) ([]xcontext.QueryTuple, error) {
+	if h == nil || h.Shard == "" {
+		return querys, nil
+	}
+	if shardKey == "" && len(querys) > 1 {
+		return nil, errors.New("unsupported: hint.RADON_SHARD.on.the.global.table")
+	}
+
+	idxs, err := h.route(querys, false)
+	if err != nil {
+		return nil, err
+	}
+	routed := make([]xcontext.QueryTuple, 0, len(idxs))
+	for _, i := range idxs {
+		routed = append(routed, querys[i])
+	}
+	return routed, nil
+}
+
+// setNestedLoop used to set the joins in the tree to be done by the nested loop by the hints.
+func setNestedLoop(node PlanNode, hints *Hints) {
+	if j, ok := node.(*JoinNode); ok {
+		if hints.nestedLoop(j.Left.getReferredTables(), j.Right.getReferredTables()) {
+			j.isHint = true
+		}
+		setNestedLoop(j.Left, hints)
+		setNestedLoop(j.Right, hints)
+	}
+}
+
+// routeShard used to keep the querys of the merge nodes in the tree on the hint shard.
+func routeShard(node PlanNode, hints *Hints) error {
+	if hints == nil || hints.Shard == "" {
+		return nil
+	}
+	switch node := node.(type) {
+	case *MergeNode:
+		idxs, err := hints.route(node.Querys, node.nonGlobalCnt == 0)
+		if err != nil {
+			return err
+		}
+		querys := make([]xcontext.QueryTuple, 0, len(idxs))
+		parsedQuerys := make([]*sqlparser.ParsedQuery, 0, len(idxs))
+		for _, i := range idxs {
+			querys = append(querys, node.Querys[i])
+			if i < len(node.ParsedQuerys) {
+				parsedQuerys = append(parsedQuerys, node.ParsedQuerys[i])
+			}
+		}
+		node.Querys, node.ParsedQuerys = querys, parsedQuerys
+	case *JoinNode:
+		if err := routeShard(node.Left, hints); err != nil {
+			return err
+		}
+		return routeShard(node.Right, hints)
+	}
+	return nil
+}
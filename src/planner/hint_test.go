@@ -0,0 +1,222 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestParseHints(t *testing.T) {
+	querys := []string{
+		"select a from t",
+		"select /* RADON_SHARD(backend1) */ a from t",
+		"select /*+ RADON_SHARD(backend1) */ a from t",
+		"select /*+ radon_shard(`backend1`) nested_loop(a,b) no_twopc unknown(x) */ a from t",
+		"select /*+nested+*/ a from t",
+		"delete /*+ NO_TWOPC */ from t",
+		"update /*+ RADON_SHARD(backend2) */ t set a=1",
+		"insert /*+ NESTED_LOOP */ into t values(1)",
+	}
+	results := [][]string{
+		nil,
+		nil,
+		{"RADON_SHARD(backend1)"},
+		{"RADON_SHARD(backend1)", "NESTED_LOOP(a, b)", "NO_TWOPC"},
+		{"NESTED_LOOP"},
+		{"NO_TWOPC"},
+		{"RADON_SHARD(backend2)"},
+		{"NESTED_LOOP"},
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		hints, err := GetHints(node)
+		assert.Nil(t, err)
+		assert.Equal(t, results[i], hints.Strings(), query)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"select /*+ RADON_SHARD() */ a from t",
+			"select /*+ RADON_SHARD(backend1, backend2) */ a from t",
+		}
+		for _, query := range querys {
+			node, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			_, err = GetHints(node)
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestHintsNestedLoop(t *testing.T) {
+	left := map[string]*TableInfo{"a": nil}
+	right := map[string]*TableInfo{"b": nil, "c": nil}
+	tcases := []struct {
+		hints *Hints
+		want  bool
+	}{
+		{nil, false},
+		{&Hints{}, false},
+		{&Hints{NestedLoop: true}, true},
+		{&Hints{NestedLoop: true, NestedLoopTables: []string{"b"}}, true},
+		{&Hints{NestedLoop: true, NestedLoopTables: []string{"a"}}, false},
+		{&Hints{NestedLoop: true, NestedLoopTables: []string{"a", "c"}}, true},
+		{&Hints{NestedLoop: true, NestedLoopTables: []string{"b", "c"}}, false},
+		{&Hints{NestedLoop: true, NestedLoopTables: []string{"d"}}, false},
+	}
+	for _, tcase := range tcases {
+		assert.Equal(t, tcase.want, tcase.hints.nestedLoop(left, right))
+	}
+}
+
+func TestSelectPlanWithHints(t *testing.T) {
+	querys := []string{
+		"select /*+ RADON_SHARD(backend2) */ a from B where id>1",
+		"select /*+ RADON_SHARD(backend1) NESTED_LOOP(B) */ A.a from A join B on A.a=B.a",
+		"select /*+ NESTED_LOOP(A, B) */ A.a from A join B on A.a=B.a where A.id=1",
+	}
+	results := []string{
+		`Hints: RADON_SHARD(backend2)
+-> Merge: 1 partitions
+    -> Query on backend2 [512-4096): select /*+ RADON_SHARD(backend2) */ a from sbtest.B1 as B where id > 1
+`,
+		`Hints: RADON_SHARD(backend1), NESTED_LOOP(B)
+-> Inner join (Nested Loop Join): A.a = B.a
+    -> Merge: 1 partitions
+        -> Query on backend1 [0-32): select /*+ RADON_SHARD(backend1) NESTED_LOOP(B) */ A.a from sbtest.A1 as A
+    -> Merge: 1 partitions
+        -> Query on backend1 [0-512): select /*+ RADON_SHARD(backend1) NESTED_LOOP(B) */ 1 from sbtest.B0 as B where :A_a = B.a
+`,
+		`Hints: NESTED_LOOP(A, B)
+-> Inner join (Nested Loop Join): A.a = B.a
+    -> Merge: 1 partitions
+        -> Query on backend6 [512-4096): select /*+ NESTED_LOOP(A, B) */ A.a from sbtest.A6 as A where A.id = 1
+    -> Merge: 2 partitions
+        -> Query on backend1 [0-512): select /*+ NESTED_LOOP(A, B) */ 1 from sbtest.B0 as B where :A_a = B.a
+        -> Query on backend2 [512-4096): select /*+ NESTED_LOOP(A, B) */ 1 from sbtest.B1 as B where :A_a = B.a
+`,
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		assert.Nil(t, plan.Build())
+		assert.Equal(t, results[i], FormatTree(plan, nil))
+	}
+
+	// The global table is sent to the hint shard.
+	{
+		query := "select /*+ RADON_SHARD(backend2) */ a from G"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		assert.Nil(t, plan.Build())
+		assert.Equal(t, 1, len(plan.Root.(*MergeNode).Querys))
+		assert.Equal(t, "backend2", plan.Root.(*MergeNode).Querys[0].Backend)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"select /*+ RADON_SHARD(backend1) */ a from A where id=1",
+			"select /*+ RADON_SHARD(backend1, backend2) */ a from A",
+		}
+		for _, query := range querys {
+			node, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+			assert.NotNil(t, plan.Build())
+		}
+	}
+}
+
+func TestDMLPlanWithHints(t *testing.T) {
+	querys := []string{
+		"delete /*+ RADON_SHARD(backend2) */ from B where id>1",
+		"update /*+ RADON_SHARD(backend1) */ B set a=1 where a>1",
+		"insert /*+ RADON_SHARD(backend2) */ into B(id, a) values(1, 1)",
+		"update /*+ NO_TWOPC */ B set a=1 where id=1",
+	}
+	results := []string{
+		`Hints: RADON_SHARD(backend2)
+-> Delete: 1 partitions
+    -> Query on backend2 [512-4096): delete /*+ RADON_SHARD(backend2) */ from sbtest.B1 where id > 1
+`,
+		`Hints: RADON_SHARD(backend1)
+-> Update: 1 partitions
+    -> Query on backend1 [0-512): update /*+ RADON_SHARD(backend1) */ sbtest.B0 set a = 1 where a > 1
+`,
+		`Hints: RADON_SHARD(backend2)
+-> Insert: 1 partitions
+    -> Query on backend2 [512-4096): insert /*+ RADON_SHARD(backend2) */ into sbtest.B1(id, a) values (1, 1)
+`,
+		`Hints: NO_TWOPC
+-> Update: 1 partitions
+    -> Query on backend2 [512-4096): update /*+ NO_TWOPC */ sbtest.B1 set a = 1 where id = 1
+`,
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.AddForTest(database, router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		var plan Plan
+		switch node := node.(type) {
+		case *sqlparser.Delete:
+			plan = NewDeletePlan(log, database, query, node, route)
+		case *sqlparser.Update:
+			plan = NewUpdatePlan(log, database, query, node, route)
+		case *sqlparser.Insert:
+			plan = NewInsertPlan(log, database, query, node, route)
+		}
+		assert.Nil(t, plan.Build())
+		assert.Equal(t, results[i], FormatTree(plan, nil))
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"delete /*+ RADON_SHARD(backend1) */ from B where id=1",
+			"update /*+ RADON_SHARD(backend1) */ G set a=1 where a>1",
+			"insert /*+ RADON_SHARD(backend1) */ into B(id, a) values(1, 1), (3, 3)",
+		}
+		for _, query := range querys {
+			node, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			var plan Plan
+			switch node := node.(type) {
+			case *sqlparser.Delete:
+				plan = NewDeletePlan(log, database, query, node, route)
+			case *sqlparser.Update:
+				plan = NewUpdatePlan(log, database, query, node, route)
+			case *sqlparser.Insert:
+				plan = NewInsertPlan(log, database, query, node, route)
+			}
+			assert.NotNil(t, plan.Build(), query)
+		}
+	}
+}
@@ -25,6 +25,9 @@ var (
 	_ Plan = &InsertPlan{}
 )
 
+// nullShardVal is the value which the NULL shard key is routed by.
+var nullShardVal = sqlparser.NewIntVal([]byte("0"))
+
 // InsertPlan represents insertion plan
 type InsertPlan struct {
 	log *xlog.Log
@@ -49,6 +52,9 @@ type InsertPlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Hints are the optimizer hints in the comments.
+	Hints *Hints
 }
 
 // NewInsertPlan used to create InsertPlan
@@ -66,7 +72,11 @@ func NewInsertPlan(log *xlog.Log, database string, query string, node *sqlparser
 
 // Build used to build distributed querys.
 func (p *InsertPlan) Build() error {
+	var err error
 	node := p.node
+	if p.Hints, err = ParseHints(node.Comments); err != nil {
+		return err
+	}
 
 	database := p.database
 	// Qualifier is database in the insert query, such as "db.t1".
@@ -102,7 +112,7 @@ func (p *InsertPlan) Build() error {
 			}
 			p.Querys = append(p.Querys, tuple)
 		}
-		return nil
+		return p.routeHint(shardKey)
 	}
 
 	// Check the OnDup.
@@ -138,8 +148,14 @@ func (p *InsertPlan) Build() error {
 		if idx >= len(row) {
 			return errors.Errorf("unsupported: shardkey[%v].out.of.index:[%v]", shardKey, idx)
 		}
-		shardVal, ok := row[idx].(*sqlparser.SQLVal)
-		if !ok {
+		var shardVal *sqlparser.SQLVal
+		switch val := row[idx].(type) {
+		case *sqlparser.SQLVal:
+			shardVal = val
+		case *sqlparser.NullVal:
+			// The NULL is routed as 0, same as the HASH partitioning of MySQL.
+			shardVal = nullShardVal
+		default:
 			return errors.Errorf("unsupported: shardkey[%v].type.canot.be[%T]", shardKey, row[idx])
 		}
 
@@ -174,6 +190,19 @@ func (p *InsertPlan) Build() error {
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
+	return p.routeHint(shardKey)
+}
+
+// routeHint used to check the querys are all on the shard of the RADON_SHARD hint,
+// the rows on the other backends can't be dropped.
+func (p *InsertPlan) routeHint(shardKey string) error {
+	querys, err := p.Hints.routeDML(p.Querys, shardKey)
+	if err != nil {
+		return err
+	}
+	if len(querys) != len(p.Querys) {
+		return errors.Errorf("unsupported: hint.RADON_SHARD.backend[%s].does.not.hold.all.the.rows", p.Hints.Shard)
+	}
 	return nil
 }
 
@@ -187,6 +216,7 @@ func (p *InsertPlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
+		Hints      []string              `json:",omitempty"`
 	}
 
 	var parts []xcontext.QueryTuple
@@ -196,6 +226,7 @@ func (p *InsertPlan) JSON() string {
 	exp := &explain{
 		RawQuery:   p.RawQuery,
 		Partitions: parts,
+		Hints:      p.Hints.Strings(),
 	}
 	bout, err := json.MarshalIndent(exp, "", "\t")
 	if err != nil {
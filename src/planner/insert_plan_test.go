@@ -61,12 +61,23 @@ func TestInsertPlan(t *testing.T) {
 			"Range": "[512-4096)"
 		}
 	]
+}`,
+		`{
+	"RawQuery": "insert into A(id, b, c) values(null,2,3),(0,4,5)",
+	"Partitions": [
+		{
+			"Query": "insert into sbtest.A1(id, b, c) values (null, 2, 3), (0, 4, 5)",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		}
+	]
 }`,
 	}
 	querys := []string{
 		"insert into A(id, b, c) values(1,2,3) on duplicate key update c=11",
 		"insert into A(id, b, c) values(1,2,3),(23,4,5), (65536,3,4)",
 		"insert into sbtest.A(id, b, c) values(1,2,3),(23,4,5), (65536,3,4)",
+		"insert into A(id, b, c) values(null,2,3),(0,4,5)",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
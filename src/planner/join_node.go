@@ -16,7 +16,6 @@ import (
 
 	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
-	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -30,6 +29,8 @@ const (
 	SortMerge
 	// NestedLoop Join.
 	NestedLoop
+	// Hash Join, used by the semi join and anti join.
+	Hash
 )
 
 // JoinKey is the column info in the on conditions.
@@ -94,6 +95,17 @@ type JoinNode struct {
 	rightNull                []nullExpr
 	// whether is left join.
 	IsLeftJoin bool
+	// whether is full join, the unmatched rows of both sides are returned.
+	IsFullJoin bool
+	// whether is semi join, the left rows which have matches are returned once.
+	IsSemiJoin bool
+	// whether is anti join, the left rows which have no matches are returned.
+	IsAntiJoin bool
+	// NullAwareKeys is the 'NOT IN' comparison of the anti join, the row with NULL
+	// on either side is unknown, treated as matched.
+	NullAwareKeys []Comparison
+	// the subquery of the semi join or anti join.
+	semi *semiJoin
 	// whether the right node has filters in left join.
 	HasRightFilter bool
 	// record the `otherJoin.left`'s index in left.fields.
@@ -105,7 +117,7 @@ type JoinNode struct {
 	// `t1.a` in LeftKeys, `t1.a=1` in tableFilter. in the map,
 	// key is 0(index is 0), value is tableFilter(`t1.a=1`).
 	keyFilters map[int][]filterTuple
-	// isHint defines whether has /*+nested+*/.
+	// isHint defines whether has the NESTED_LOOP hint.
 	isHint bool
 	order  int
 	// Vars defines the list of joinVars that need to be built
@@ -116,9 +128,10 @@ type JoinNode struct {
 // newJoinNode used to create JoinNode.
 func newJoinNode(log *xlog.Log, Left, Right SelectNode, router *router.Router, joinExpr *sqlparser.JoinTableExpr,
 	joinOn []joinTuple, referredTables map[string]*TableInfo) *JoinNode {
-	isLeftJoin := false
-	if joinExpr != nil && joinExpr.Join == sqlparser.LeftJoinStr {
-		isLeftJoin = true
+	isLeftJoin, isFullJoin := false, false
+	if joinExpr != nil {
+		isLeftJoin = joinExpr.Join == sqlparser.LeftJoinStr
+		isFullJoin = joinExpr.Join == sqlparser.FullOuterJoinStr
 	}
 	return &JoinNode{
 		log:            log,
@@ -131,6 +144,7 @@ func newJoinNode(log *xlog.Log, Left, Right SelectNode, router *router.Router, j
 		Vars:           make(map[string]int),
 		referredTables: referredTables,
 		IsLeftJoin:     isLeftJoin,
+		IsFullJoin:     isFullJoin,
 		children:       NewPlanTree(),
 	}
 }
@@ -380,6 +394,8 @@ func (j *JoinNode) pushEqualCmpr(joins []joinTuple) SelectNode {
 			} else {
 				node.joinOn = append(node.joinOn, join)
 				if node.joinExpr != nil {
+					// The USING clause cannot carry the other conditions.
+					node.joinExpr.Using = nil
 					node.joinExpr.On = &sqlparser.AndExpr{
 						Left:  node.joinExpr.On,
 						Right: join.expr,
@@ -407,14 +423,30 @@ func (j *JoinNode) calcRoute() (SelectNode, error) {
 		return j, err
 	}
 
+	// the subquery is pushed down if the left and right node have same routes.
+	if j.semi != nil {
+		if lmn, ok := j.Left.(*MergeNode); ok {
+			if rmn, ok := j.Right.(*MergeNode); ok {
+				if (lmn.backend != "" && lmn.backend == rmn.backend) || rmn.nonGlobalCnt == 0 {
+					mn := mergeSemiJoin(lmn, rmn, j.semi)
+					mn.setParent(j.parent)
+					return mn, nil
+				}
+			}
+		}
+		return j, nil
+	}
+
 	// left and right node have same routes.
-	if lmn, ok := j.Left.(*MergeNode); ok {
+	if lmn, ok := j.Left.(*MergeNode); ok && !j.IsFullJoin {
 		if rmn, ok := j.Right.(*MergeNode); ok {
 			if (lmn.backend != "" && lmn.backend == rmn.backend) || rmn.nonGlobalCnt == 0 || lmn.nonGlobalCnt == 0 {
 				if lmn.nonGlobalCnt == 0 {
 					lmn.backend = rmn.backend
 					lmn.routeLen = rmn.routeLen
 					lmn.index = rmn.index
+					lmn.routeArgs = rmn.routeArgs
+					lmn.fixedIndex = rmn.fixedIndex
 				}
 				mn, _ := mergeRoutes(lmn, rmn, j.joinExpr, nil)
 				mn.setParent(j.parent)
@@ -529,6 +561,9 @@ func (j *JoinNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.
 func (j *JoinNode) handleOthers() error {
 	var err error
 	var idx int
+	if j.IsFullJoin && j.isHint {
+		return errors.New("unsupported: nested.loop.join.in.full.join")
+	}
 	if lp, ok := j.Left.(*JoinNode); ok {
 		if err = lp.handleOthers(); err != nil {
 			return err
@@ -549,6 +584,10 @@ func (j *JoinNode) handleOthers() error {
 		return err
 	}
 
+	if err = j.pushNotIn(&idx); err != nil {
+		return err
+	}
+
 	return j.pushOtherFilters(j.otherFilter, &idx, false)
 }
 
@@ -564,6 +603,24 @@ func (j *JoinNode) pushNullExprs(idx *int) error {
 	return nil
 }
 
+// pushNotIn used to push the both sides of the 'NOT IN' comparison.
+func (j *JoinNode) pushNotIn(idx *int) error {
+	if !j.IsAntiJoin || j.semi.in == nil {
+		return nil
+	}
+	in := j.semi.in
+	lidx, err := j.pushOtherFilter(in.Left, j.Left, getTbInExpr(in.Left), idx)
+	if err != nil {
+		return err
+	}
+	ridx, err := j.pushOtherFilter(in.Right, j.Right, getTbInExpr(in.Right), idx)
+	if err != nil {
+		return err
+	}
+	j.NullAwareKeys = append(j.NullAwareKeys, Comparison{lidx, ridx, in.Operator, false})
+	return nil
+}
+
 // pushOtherFilters used to push otherFilter.
 func (j *JoinNode) pushOtherFilters(filters []filterTuple, idx *int, isOtherJoin bool) error {
 	for _, filter := range filters {
@@ -681,6 +738,11 @@ func (j *JoinNode) pushOtherFilter(expr sqlparser.Expr, node SelectNode, tbs []s
 
 // pushSelectExpr used to push the select field.
 func (j *JoinNode) pushSelectExpr(field selectTuple) (int, error) {
+	if exp, ok := field.expr.(*sqlparser.AliasedExpr); ok && j.IsFullJoin {
+		if _, ok := exp.Expr.(*sqlparser.FuncExpr); ok {
+			return -1, errors.Errorf("unsupported: expr.'%s'.in.cross-shard.full.join", field.field)
+		}
+	}
 	if checkTbInNode(field.referTables, j.Left.getReferredTables()) {
 		index, err := j.Left.pushSelectExpr(field)
 		if err != nil {
@@ -688,6 +750,13 @@ func (j *JoinNode) pushSelectExpr(field selectTuple) (int, error) {
 		}
 		j.Cols = append(j.Cols, -index-1)
 	} else {
+		if j.semi != nil {
+			name := field.field
+			if exp, ok := field.expr.(*sqlparser.AliasedExpr); ok {
+				name = sqlparser.String(exp.Expr)
+			}
+			return -1, errors.Errorf("unsupported: unknown.column.'%s'.in.field.list", name)
+		}
 		if exp, ok := field.expr.(*sqlparser.AliasedExpr); ok && j.IsLeftJoin {
 			if _, ok := exp.Expr.(*sqlparser.FuncExpr); ok {
 				return -1, errors.Errorf("unsupported: expr.'%s'.in.cross-shard.left.join", field.field)
@@ -792,7 +861,8 @@ func (j *JoinNode) buildOrderBy(expr sqlparser.Expr, node SelectNode, idx *int)
 		index, _ = node.pushSelectExpr(tuple)
 	}
 
-	if m, ok := node.(*MergeNode); ok {
+	// The semi join and anti join needn't the sorted rows.
+	if m, ok := node.(*MergeNode); ok && j.semi == nil {
 		m.Sel.(*sqlparser.Select).OrderBy = append(m.Sel.(*sqlparser.Select).OrderBy, &sqlparser.Order{
 			Expr:      col,
 			Direction: sqlparser.AscScr,
@@ -867,12 +937,6 @@ func (j *JoinNode) pushLimit(sel sqlparser.SelectStatement) error {
 
 // pushMisc used tp push miscelleaneous constructs.
 func (j *JoinNode) pushMisc(sel *sqlparser.Select) {
-	if len(sel.Comments) > 0 {
-		hint := "/*+nested+*/"
-		if common.BytesToString(sel.Comments[0]) == hint {
-			j.isHint = true
-		}
-	}
 	j.Left.pushMisc(sel)
 	j.Right.pushMisc(sel)
 }
@@ -898,6 +962,8 @@ func (j *JoinNode) Order() int {
 func (j *JoinNode) buildQuery(tbInfos map[string]*TableInfo) {
 	if j.isHint {
 		j.Strategy = NestedLoop
+	} else if j.semi != nil {
+		j.Strategy = Hash
 	} else {
 		if len(j.LeftKeys) == 0 && len(j.CmpFilter) == 0 {
 			j.Strategy = Cartesian
@@ -15,7 +15,9 @@ import (
 	"router"
 	"xcontext"
 
+	"github.com/pkg/errors"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -32,6 +34,10 @@ type MergeNode struct {
 	backend string
 	// the shard index slice.
 	index []int
+	// the bind variables on the shardkey, the route is calculated when they're bound.
+	routeArgs []routeArg
+	// the shard index calculated by the values in the query, used with the routeArgs.
+	fixedIndex []int
 	// length of the route.
 	routeLen int
 	// referred tables' tableInfo map.
@@ -55,6 +61,15 @@ type MergeNode struct {
 	order   int
 	// Mode.
 	ReqMode xcontext.RequestMode
+	// FoundRows is called with the FOUND_ROWS() of each query if it's set, the querys are with the
+	// SQL_CALC_FOUND_ROWS.
+	FoundRows func(rows uint64)
+}
+
+// routeArg is the bind variable on the shardkey of the table.
+type routeArg struct {
+	tbInfo *TableInfo
+	name   string
 }
 
 // newMergeNode used to create MergeNode.
@@ -151,15 +166,17 @@ func (m *MergeNode) pushEqualCmpr(joins []joinTuple) SelectNode {
 // calcRoute used to calc the route.
 func (m *MergeNode) calcRoute() (SelectNode, error) {
 	var err error
+	// The querys are built for all the shards, and chosen when the routeArgs are bound.
+	if len(m.routeArgs) > 0 {
+		m.fixedIndex, m.index = m.index, nil
+	}
 	for _, tbInfo := range m.referredTables {
 		if m.nonGlobalCnt == 0 {
-			segments, err := m.router.Lookup(tbInfo.database, tbInfo.tableName, nil, nil)
+			idx, backend, err := m.randomBackend(tbInfo)
 			if err != nil {
 				return nil, err
 			}
-			rand := rand.New(rand.NewSource(time.Now().UnixNano()))
-			idx := rand.Intn(len(segments))
-			m.backend = segments[idx].Backend
+			m.backend = backend
 			m.index = append(m.index, idx)
 			m.routeLen = 1
 			break
@@ -181,6 +198,82 @@ func (m *MergeNode) calcRoute() (SelectNode, error) {
 	return m, nil
 }
 
+// randomBackend used to choose a backend of the global table randomly.
+func (m *MergeNode) randomBackend(tbInfo *TableInfo) (int, string, error) {
+	segments, err := m.router.Lookup(tbInfo.database, tbInfo.tableName, nil, nil)
+	if err != nil {
+		return -1, "", err
+	}
+	rand := rand.New(rand.NewSource(time.Now().UnixNano()))
+	idx := rand.Intn(len(segments))
+	return idx, segments[idx].Backend, nil
+}
+
+// bind returns a copy of the MergeNode whose querys are bound by the bind variables.
+// If there're routeArgs, only the querys of the routed shards are kept.
+func (m *MergeNode) bind(bindVars map[string]*querypb.BindVariable) (*MergeNode, error) {
+	var idxs []int
+	if len(m.routeArgs) > 0 {
+		index := append([]int(nil), m.fixedIndex...)
+		for _, arg := range m.routeArgs {
+			val := bindVarToLiteral(bindVars[arg.name])
+			if val == nil {
+				return nil, errors.Errorf("unsupported: bind.variable.'%s'.on.shardkey", arg.name)
+			}
+			idx, err := m.router.GetIndex(arg.tbInfo.database, arg.tbInfo.tableName, val)
+			if err != nil {
+				return nil, err
+			}
+			index = append(index, idx)
+		}
+		tbInfo := m.routeArgs[0].tbInfo
+		segments, err := m.router.GetSegments(tbInfo.database, tbInfo.tableName, index)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segments {
+			for i, query := range m.Querys {
+				if query.Range == segment.Range.String() {
+					idxs = append(idxs, i)
+				}
+			}
+		}
+	} else {
+		for i := range m.Querys {
+			idxs = append(idxs, i)
+		}
+	}
+
+	n := *m
+	n.Querys = make([]xcontext.QueryTuple, 0, len(idxs))
+	n.ParsedQuerys = make([]*sqlparser.ParsedQuery, 0, len(idxs))
+	for _, i := range idxs {
+		query, err := m.ParsedQuerys[i].GenerateQuery(bindVars, nil)
+		if err != nil {
+			return nil, err
+		}
+		tuple := m.Querys[i]
+		tuple.Query = query
+		n.Querys = append(n.Querys, tuple)
+		n.ParsedQuerys = append(n.ParsedQuerys, m.ParsedQuerys[i])
+	}
+	n.routeLen = len(n.Querys)
+
+	// The global tables' backend is chosen randomly for each query.
+	if m.nonGlobalCnt == 0 {
+		for _, tbInfo := range m.referredTables {
+			_, backend, err := m.randomBackend(tbInfo)
+			if err != nil {
+				return nil, err
+			}
+			n.backend = backend
+			n.Querys[0].Backend = backend
+			break
+		}
+	}
+	return &n, nil
+}
+
 // pushSelectExprs used to push the select fields.
 func (m *MergeNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser.Select, aggTyp aggrType) error {
 	node := m.Sel.(*sqlparser.Select)
@@ -212,6 +305,9 @@ func (m *MergeNode) pushSelectExprs(fields, groups []selectTuple, sel *sqlparser
 	}
 
 	if aggTyp != nullAgg || len(groups) > 0 {
+		if aggTyp == notPush {
+			node.GroupBy = getDistinctGroupBy(fields, sel.GroupBy)
+		}
 		aggrPlan := NewAggregatePlan(m.log, node.SelectExprs, fields, groups, aggTyp == canPush)
 		if err := aggrPlan.Build(); err != nil {
 			return err
@@ -258,8 +354,15 @@ func (m *MergeNode) pushLimit(sel sqlparser.SelectStatement) error {
 	if err := limitPlan.Build(); err != nil {
 		return err
 	}
+	// The windows need all the rows.
+	windowed := false
+	for _, plan := range m.children.Plans() {
+		if plan.Type() == PlanTypeWindow {
+			windowed = true
+		}
+	}
 	m.children.Add(limitPlan)
-	if len(m.Sel.(*sqlparser.Select).GroupBy) == 0 {
+	if len(m.Sel.(*sqlparser.Select).GroupBy) == 0 && !windowed {
 		// Rewrite the limit clause.
 		m.Sel.SetLimit(limitPlan.ReWritten())
 	}
@@ -0,0 +1,145 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"fmt"
+
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+/* Parameterize used to replace the literals in the where clause of the select by the bind
+ * variables in place, fills the values into the bindVars and returns the text of the select.
+ * The querys which only differ in these values have the same text, so they can share the plan
+ * built by the parameterized node. The literals are restored by BindLiterals with the bindVars.
+ * eg: select a from t1 where id=1 and b='x';
+ * ->  select a from t1 where id = :v1 and b = :v2; bindVars: v1=1, v2='x'.
+ */
+func Parameterize(node *sqlparser.Select, bindVars map[string]*querypb.BindVariable) string {
+	if node.Where != nil {
+		reserved := sqlparser.GetBindvars(node)
+		counter := 1
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			switch node := node.(type) {
+			case *sqlparser.Subquery, *sqlparser.ConvertType:
+				// The literals in the subquery and the type are kept.
+				return false, nil
+			case *sqlparser.SQLVal:
+				bv := literalToBindVar(node)
+				if bv == nil {
+					return true, nil
+				}
+				var name string
+				for {
+					name = fmt.Sprintf("v%d", counter)
+					counter++
+					if _, ok := reserved[name]; !ok {
+						break
+					}
+				}
+				bindVars[name] = bv
+				*node = *sqlparser.NewValArg([]byte(":" + name))
+			}
+			return true, nil
+		}, node.Where)
+	}
+	return sqlparser.String(node)
+}
+
+// BindLiterals used to replace the bind variables in the node with their values, returns
+// false if some bind variable cannot be replaced by a literal, such as NULL and the list.
+func BindLiterals(node sqlparser.SQLNode, bindVars map[string]*querypb.BindVariable) bool {
+	var args []*sqlparser.SQLVal
+	var vals []*sqlparser.SQLVal
+	ok := true
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case sqlparser.ListArg:
+			ok = false
+		case *sqlparser.SQLVal:
+			if node.Type != sqlparser.ValArg {
+				return true, nil
+			}
+			val := bindVarToLiteral(bindVars[string(node.Val[1:])])
+			if val == nil {
+				ok = false
+				return false, nil
+			}
+			args = append(args, node)
+			vals = append(vals, val)
+		}
+		return ok, nil
+	}, node)
+	if !ok {
+		return false
+	}
+	for i, arg := range args {
+		*arg = *vals[i]
+	}
+	return true
+}
+
+// literalToBindVar returns the bind variable of the literal, nil if the literal cannot be parameterized.
+func literalToBindVar(val *sqlparser.SQLVal) *querypb.BindVariable {
+	switch val.Type {
+	case sqlparser.StrVal:
+		return &querypb.BindVariable{Type: sqltypes.VarBinary, Value: val.Val}
+	case sqlparser.IntVal:
+		return &querypb.BindVariable{Type: sqltypes.Int64, Value: val.Val}
+	case sqlparser.FloatVal:
+		return &querypb.BindVariable{Type: sqltypes.Float64, Value: val.Val}
+	}
+	return nil
+}
+
+// bindVarToLiteral returns the literal of the bind variable, nil if it cannot be a literal.
+func bindVarToLiteral(bv *querypb.BindVariable) *sqlparser.SQLVal {
+	if bv == nil {
+		return nil
+	}
+	switch {
+	case sqltypes.IsIntegral(bv.Type):
+		return sqlparser.NewIntVal(bv.Value)
+	case sqltypes.IsFloat(bv.Type) || bv.Type == sqltypes.Decimal:
+		return sqlparser.NewFloatVal(bv.Value)
+	case sqltypes.IsQuoted(bv.Type):
+		return sqlparser.NewStrVal(bv.Value)
+	}
+	return nil
+}
+
+// Cacheable returns true if the plan can be cached and bound by the different values,
+// that's the whole query is pushed down to the shards.
+func (p *SelectPlan) Cacheable() bool {
+	// The route of the RADON_SHARD hint is lost after bound.
+	if p.Hints != nil && p.Hints.Shard != "" {
+		return false
+	}
+	m, ok := p.Root.(*MergeNode)
+	return ok && m.ReqMode == xcontext.ReqNormal
+}
+
+// Bind returns a copy of the cacheable plan, whose querys are bound by the bind variables.
+func (p *SelectPlan) Bind(bindVars map[string]*querypb.BindVariable) (*SelectPlan, error) {
+	if !p.Cacheable() {
+		return nil, errors.New("unsupported: bind.the.uncacheable.plan")
+	}
+	root, err := p.Root.(*MergeNode).bind(bindVars)
+	if err != nil {
+		return nil, err
+	}
+	plan := *p
+	plan.Root = root
+	return &plan, nil
+}
@@ -0,0 +1,184 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestParameterize(t *testing.T) {
+	querys := []string{
+		"select a from A where id=1 and b='x'",
+		"select a from A where id in (1, 2.5) order by a limit 10",
+		"select a from A where id = :v1 and b = -1",
+		"select a from A where cast(b as char(10)) = 'x' and a in (select a from B where id = 3)",
+		"select 1 from A",
+	}
+	results := []string{
+		"select a from A where id = :v1 and b = :v2",
+		"select a from A where id in (:v1, :v2) order by a asc limit 10",
+		"select a from A where id = :v1 and b = :v2",
+		"select a from A where convert(b, char(10)) = :v1 and a in (select a from B where id = 3)",
+		"select 1 from A",
+	}
+	vars := []map[string]*querypb.BindVariable{
+		{"v1": sqltypes.Int64BindVariable(1), "v2": {Type: sqltypes.VarBinary, Value: []byte("x")}},
+		{"v1": sqltypes.Int64BindVariable(1), "v2": {Type: sqltypes.Float64, Value: []byte("2.5")}},
+		{"v1": nil, "v2": sqltypes.Int64BindVariable(-1)},
+		{"v1": {Type: sqltypes.VarBinary, Value: []byte("x")}},
+		{},
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		bindVars := make(map[string]*querypb.BindVariable)
+		if i == 2 {
+			bindVars["v1"] = nil
+		}
+		got := Parameterize(node.(*sqlparser.Select), bindVars)
+		assert.Equal(t, results[i], got)
+		assert.Equal(t, results[i], sqlparser.String(node))
+		assert.Equal(t, vars[i], bindVars)
+
+		// Restore the literals.
+		if i != 2 {
+			assert.True(t, BindLiterals(node, bindVars))
+			want, err := sqlparser.Parse(query)
+			assert.Nil(t, err)
+			assert.Equal(t, sqlparser.String(want), sqlparser.String(node))
+		}
+	}
+}
+
+func TestBindLiterals(t *testing.T) {
+	querys := []string{
+		"select a from A where id = :id and b = :b and c = :c",
+		"select a from A where id = :id",
+		"select a from A where id in ::ids",
+	}
+	results := []string{
+		"select a from A where id = 1 and b = 'x' and c = 1.5",
+		"select a from A where id = :id",
+		"select a from A where id in ::ids",
+	}
+	oks := []bool{true, false, false}
+	bindVars := []map[string]*querypb.BindVariable{
+		{
+			"id": sqltypes.Int64BindVariable(1),
+			"b":  {Type: sqltypes.VarChar, Value: []byte("x")},
+			"c":  {Type: sqltypes.Decimal, Value: []byte("1.5")},
+		},
+		{
+			"id": {Type: sqltypes.Null},
+		},
+		{
+			"ids": {Type: querypb.Type_TUPLE},
+		},
+	}
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		ok := BindLiterals(node, bindVars[i])
+		assert.Equal(t, oks[i], ok)
+		assert.Equal(t, results[i], sqlparser.String(node))
+	}
+}
+
+func TestSelectPlanBind(t *testing.T) {
+	querys := []string{
+		"select a from A where id=1 and b='x'",
+		"select a from A where id=1000",
+		"select a from A where id in (1, 1000) and b='x'",
+		"select a from A where id=1 or id=33",
+		"select a from A where b > 10 order by a limit 2",
+		"select count(*) from A where id=-1",
+		"select a from G where a = 1",
+		"select A.a from A join A as A1 on A.id = A1.id where A.id = 5",
+		"select a from A where cast(id as char(10)) = '3'",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for _, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		want := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = want.Build()
+		assert.Nil(t, err)
+
+		node, err = sqlparser.Parse(query)
+		assert.Nil(t, err)
+		bindVars := make(map[string]*querypb.BindVariable)
+		text := Parameterize(node.(*sqlparser.Select), bindVars)
+		plan := NewSelectPlan(log, database, text, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.True(t, plan.Cacheable())
+
+		got, err := plan.Bind(bindVars)
+		assert.Nil(t, err)
+		if want.Root.(*MergeNode).nonGlobalCnt == 0 {
+			assert.Equal(t, len(want.Root.GetQuery()), len(got.Root.GetQuery()))
+			assert.Equal(t, want.Root.GetQuery()[0].Query, got.Root.GetQuery()[0].Query)
+			continue
+		}
+		assert.Equal(t, want.Root.GetQuery(), got.Root.GetQuery())
+	}
+}
+
+func TestSelectPlanBindError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+
+	// Uncacheable.
+	{
+		query := "select A.a from A join B on A.a = B.a where A.id = :v1"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.False(t, plan.Cacheable())
+		_, err = plan.Bind(map[string]*querypb.BindVariable{"v1": sqltypes.Int64BindVariable(1)})
+		assert.Equal(t, "unsupported: bind.the.uncacheable.plan", err.Error())
+	}
+
+	// Missing bind variable on the shardkey.
+	{
+		query := "select a from A where id = :v1"
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.True(t, plan.Cacheable())
+		_, err = plan.Bind(map[string]*querypb.BindVariable{})
+		assert.Equal(t, "unsupported: bind.variable.'v1'.on.shardkey", err.Error())
+	}
+}
@@ -42,6 +42,15 @@ const (
 	// PlanTypeDistinct enum.
 	PlanTypeDistinct PlanType = "PlanTypeDistinct"
 
+	// PlanTypeProject enum.
+	PlanTypeProject PlanType = "PlanTypeProject"
+
+	// PlanTypeFilter enum.
+	PlanTypeFilter PlanType = "PlanTypeFilter"
+
+	// PlanTypeWindow enum.
+	PlanTypeWindow PlanType = "PlanTypeWindow"
+
 	// PlanTypeUnion enum.
 	PlanTypeUnion PlanType = "PlanTypeUnion"
 
@@ -0,0 +1,78 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"encoding/json"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Plan = &ProjectPlan{}
+)
+
+// Projection is the select expr evaluated by the proxy after the results merged.
+type Projection struct {
+	// Field is the name of the column.
+	Field string
+	// Index is the index of the column in the results.
+	Index int
+	// Expr is the expr evaluated on the rows.
+	Expr sqlparser.Expr `json:"-"`
+}
+
+// ProjectPlan represents project plan.
+type ProjectPlan struct {
+	log *xlog.Log
+
+	Projections []Projection
+
+	// type
+	typ PlanType
+}
+
+// NewProjectPlan used to create ProjectPlan.
+func NewProjectPlan(log *xlog.Log, projections []Projection) *ProjectPlan {
+	return &ProjectPlan{
+		log:         log,
+		Projections: projections,
+		typ:         PlanTypeProject,
+	}
+}
+
+// Build used to build distributed querys.
+func (p *ProjectPlan) Build() error {
+	return nil
+}
+
+// Type returns the type of the plan.
+func (p *ProjectPlan) Type() PlanType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *ProjectPlan) JSON() string {
+	bout, err := json.MarshalIndent(p, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return string(bout)
+}
+
+// Children returns the children of the plan.
+func (p *ProjectPlan) Children() *PlanTree {
+	return nil
+}
+
+// Size returns the memory size.
+func (p *ProjectPlan) Size() int {
+	return 0
+}
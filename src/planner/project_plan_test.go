@@ -0,0 +1,54 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProjectPlan(t *testing.T) {
+	query := "select a, sum(b)/count(c) as x, ifnull(max(d), 0) from t"
+	want := `{
+	"Projections": [
+		{
+			"Field": "x",
+			"Index": 1
+		},
+		{
+			"Field": "ifnull(max(d), 0)",
+			"Index": 2
+		}
+	]
+}`
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tree, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	node := tree.(*sqlparser.Select)
+	var projections []Projection
+	for i, expr := range node.SelectExprs[1:] {
+		aliasExpr := expr.(*sqlparser.AliasedExpr)
+		field := aliasExpr.As.String()
+		if field == "" {
+			field = sqlparser.String(aliasExpr.Expr)
+		}
+		projections = append(projections, Projection{Field: field, Index: i + 1, Expr: aliasExpr.Expr})
+	}
+	plan := NewProjectPlan(log, projections)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, want, plan.JSON())
+	assert.Nil(t, plan.Children())
+	assert.Equal(t, PlanTypeProject, plan.Type())
+	assert.Equal(t, 0, plan.Size())
+}
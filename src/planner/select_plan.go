@@ -46,6 +46,13 @@ type SelectPlan struct {
 	typ PlanType
 
 	Root SelectNode
+
+	// HiddenCols is the count of the hidden columns at the end of the select list, which
+	// are added for the group by and order by, they must be removed from the results.
+	HiddenCols int
+
+	// Hints are the optimizer hints in the comments.
+	Hints *Hints
 }
 
 // NewSelectPlan used to create SelectPlan.
@@ -68,26 +75,49 @@ func (p *SelectPlan) analyze() error {
 	log := p.log
 	node := p.node
 
+	if p.Hints, err = ParseHints(node.Comments); err != nil {
+		return err
+	}
+
+	// The [NOT] EXISTS and [NOT] IN subqueries in the where clause are planned as semi joins.
+	semis, err := extractSemiJoins(p.router, p.database, node)
+	if err != nil {
+		return err
+	}
+
 	// Check subquery.
 	if hasSubquery(node) {
 		return errors.New("unsupported: subqueries.in.select")
 	}
 
+	if err = resolveJoinColumns(p.router, p.database, node); err != nil {
+		return err
+	}
+
 	if p.Root, err = scanTableExprs(log, p.router, p.database, node.From); err != nil {
 		return err
 	}
 
 	tbInfos := p.Root.getReferredTables()
+	// The where filters on the full joins, evaluated by the proxy.
+	var postFilters []sqlparser.Expr
 	if node.Where != nil {
 		joins, filters, err := parserWhereOrJoinExprs(node.Where.Expr, tbInfos)
 		if err != nil {
 			return err
 		}
+		joins, filters, postFilters = splitPostFilters(p.Root, joins, filters)
 		if err = p.Root.pushFilter(filters); err != nil {
 			return err
 		}
 		p.Root = p.Root.pushEqualCmpr(joins)
 	}
+	for _, semi := range semis {
+		if p.Root, err = buildSemiJoin(log, p.router, p.database, p.Root, semi); err != nil {
+			return err
+		}
+	}
+	tbInfos = p.Root.getReferredTables()
 	if p.Root, err = p.Root.calcRoute(); err != nil {
 		return err
 	}
@@ -105,12 +135,40 @@ func (p *SelectPlan) analyze() error {
 	}
 
 	p.Root.pushMisc(node)
+	setNestedLoop(p.Root, p.Hints)
+
+	windows, windowCols, err := addWindowCols(node, p.Root)
+	if err != nil {
+		return err
+	}
+
+	projections, filters, evalCols, err := addEvalCols(node, p.Root)
+	if err != nil {
+		return err
+	}
+	for _, filter := range postFilters {
+		evalCols += addLeafCols(node, filter, nil)
+	}
+	filters = append(postFilters, filters...)
 
 	var groups []selectTuple
 	fields, aggTyp, err := parserSelectExprs(node.SelectExprs, p.Root)
 	if err != nil {
 		return err
 	}
+	// The post filters are evaluated after the aggregation.
+	if len(postFilters) > 0 && (aggTyp != nullAgg || len(node.GroupBy) > 0 || node.Distinct != "" || len(windows) > 0) {
+		return errors.Errorf("unsupported: where.clause.'%s'.in.full.join.with.aggregation", sqlparser.String(postFilters[0]))
+	}
+
+	if p.HiddenCols, err = addHiddenCols(node, fields, tbInfos); err != nil {
+		return err
+	}
+	if p.HiddenCols > 0 {
+		if fields, aggTyp, err = parserSelectExprs(node.SelectExprs, p.Root); err != nil {
+			return err
+		}
+	}
 
 	if groups, err = checkGroupBy(node.GroupBy, fields, p.router, tbInfos, ok); err != nil {
 		return err
@@ -133,6 +191,18 @@ func (p *SelectPlan) analyze() error {
 			return err
 		}
 	}
+	p.HiddenCols += evalCols + windowCols
+
+	// The exprs evaluated by the proxy after the results merged.
+	if len(projections) > 0 {
+		p.Root.Children().Add(NewProjectPlan(p.log, projections))
+	}
+	if len(filters) > 0 {
+		p.Root.Children().Add(NewFilterPlan(p.log, filters))
+	}
+	if len(windows) > 0 {
+		p.Root.Children().Add(NewWindowPlan(p.log, windows))
+	}
 
 	if err = p.Root.pushOrderBy(node); err != nil {
 		return err
@@ -155,7 +225,7 @@ func (p *SelectPlan) Build() error {
 	}
 
 	p.Root.buildQuery(p.Root.getReferredTables())
-	return nil
+	return routeShard(p.Root, p.Hints)
 }
 
 // Type returns the type of the plan.
@@ -183,12 +253,17 @@ func (p *SelectPlan) JSON() string {
 		Aggregate   []string              `json:",omitempty"`
 		GatherMerge []string              `json:",omitempty"`
 		HashGroupBy []string              `json:",omitempty"`
+		Evaluate    []string              `json:",omitempty"`
+		Filter      []string              `json:",omitempty"`
+		Window      []string              `json:",omitempty"`
 		Limit       *limit                `json:",omitempty"`
+		Hints       []string              `json:",omitempty"`
 	}
 
 	// Project.
 	var prefix, project string
 	tuples := p.Root.getFields()
+	tuples = tuples[:len(tuples)-p.HiddenCols]
 	for _, tuple := range tuples {
 		field := tuple.field
 		if tuple.alias != "" {
@@ -208,9 +283,17 @@ func (p *SelectPlan) JSON() string {
 			joins.Strategy = "Sort Merge Join"
 		case NestedLoop:
 			joins.Strategy = "Nested Loop Join"
+		case Hash:
+			joins.Strategy = "Hash Join"
 		}
-		if j.IsLeftJoin {
+		if j.IsSemiJoin {
+			joins.Type = "SEMI JOIN"
+		} else if j.IsAntiJoin {
+			joins.Type = "ANTI JOIN"
+		} else if j.IsLeftJoin {
 			joins.Type = "LEFT JOIN"
+		} else if j.IsFullJoin {
+			joins.Type = "FULL JOIN"
 		} else {
 			if j.Strategy == Cartesian {
 				joins.Type = "CROSS JOIN"
@@ -224,6 +307,7 @@ func (p *SelectPlan) JSON() string {
 	var aggregate []string
 	var hashGroup []string
 	var gatherMerge []string
+	var evaluate, filter, window []string
 	var lim *limit
 	for _, sub := range p.Root.Children().Plans() {
 		switch sub.Type() {
@@ -244,6 +328,21 @@ func (p *SelectPlan) JSON() string {
 				}
 				gatherMerge = append(gatherMerge, field)
 			}
+		case PlanTypeProject:
+			plan := sub.(*ProjectPlan)
+			for _, proj := range plan.Projections {
+				evaluate = append(evaluate, sqlparser.String(proj.Expr))
+			}
+		case PlanTypeFilter:
+			plan := sub.(*FilterPlan)
+			for _, expr := range plan.Filters {
+				filter = append(filter, sqlparser.String(expr))
+			}
+		case PlanTypeWindow:
+			plan := sub.(*WindowPlan)
+			for _, w := range plan.Windows {
+				window = append(window, sqlparser.String(w.Func))
+			}
 		case PlanTypeLimit:
 			plan := sub.(*LimitPlan)
 			lim = &limit{Offset: plan.Offset, Limit: plan.Limit}
@@ -257,7 +356,11 @@ func (p *SelectPlan) JSON() string {
 		Aggregate:   aggregate,
 		GatherMerge: gatherMerge,
 		HashGroupBy: hashGroup,
+		Evaluate:    evaluate,
+		Filter:      filter,
+		Window:      window,
 		Limit:       lim,
+		Hints:       p.Hints.Strings(),
 	}
 	bout, err := json.MarshalIndent(exp, "", "\t")
 	if err != nil {
@@ -206,7 +206,10 @@ func TestSelectPlan(t *testing.T) {
 	"Join": {
 		"Type": "INNER JOIN",
 		"Strategy": "Nested Loop Join"
-	}
+	},
+	"Hints": [
+		"NESTED_LOOP"
+	]
 }`,
 		`{
 	"RawQuery": "select A.id from A left join B on A.a+1=B.a where A.id=1",
@@ -257,12 +260,12 @@ func TestSelectPlan(t *testing.T) {
 	"Project": "tmp, b, sum(id), count(id)",
 	"Partitions": [
 		{
-			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B0 as B group by b order by b asc",
+			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B0 as B order by b asc",
 			"Backend": "backend1",
 			"Range": "[0-512)"
 		},
 		{
-			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B1 as B group by b order by b asc",
+			"Query": "select id as tmp, b, id as ` + "`sum(id)`" + `, id as ` + "`count(id)`" + ` from sbtest.B1 as B order by b asc",
 			"Backend": "backend2",
 			"Range": "[512-4096)"
 		}
@@ -601,24 +604,19 @@ func TestSelectPlanDatabaseIsNull(t *testing.T) {
 
 func TestSelectUnsupportedPlan(t *testing.T) {
 	querys := []string{
-		"select * from A as A1 where id in (select id from B)",
+		"select A1.id from A as A1 where id in (select id from B) or a > 1",
 		"select distinct(b) from A",
 		"select * from A join B on B.id=A.id",
-		"select id from A order by b",
 		"select id from A limit x",
-		"select age,count(*) from A group by age having count(*) >=2",
 		"select * from A where B.a >1",
 		"select count() from A",
-		"select round(avg(id)) from A",
-		"select id,group_concat(distinct name) from A group by id",
+		"select group_concat(a order by 1) from A",
 		"select next value for A",
 		"select A.*,(select b.str from b where A.id=B.id) str from A",
-		"select avg(id)*1000 from A",
 		"select avg(*) from A",
 		"select B.* from A",
 		"select * from A where a>1 having count(a) >3",
 		"select a,b from A group by B.a",
-		"select A.id,G.a as a, concat(B.str,G.str), 1 from A,B, A as G group by a",
 		"select *,avg(a) from A",
 		"select A.id from A join B on A.id=B.id right join G on G.id=A.id and A.a>B.a",
 		"select A.id from (A,B) left join G on A.id =G.id and A.a>B.a",
@@ -628,35 +626,44 @@ func TestSelectUnsupportedPlan(t *testing.T) {
 		"select A.id from A join B on A.id = B.id join G on A.id+B.id<=G.id",
 		"select A.id from G join (A,B) on A.id+B.id<=G.id",
 		"select A.id from G join (A,B) on G.id<=A.id+B.id",
-		"select A.id as tmp, B.id from A,B having tmp=1",
-		"select COALESCE(B.b, ''), IF(B.b IS NULL, FALSE, TRUE) AS spent from A left join B on A.a=B.a",
-		"select A.a as b from A order by A.b",
-		"select a+1 from A order by a+1",
-		"select b as a from A group by A.a",
-		"select a+1 from A group by a+1",
 		"select count(distinct *) from A",
 		"select t1.a from G",
 		"select A.id from A join B on A.id=B.id where A.id in (1,2) or B.a=1",
+		"select distinct sum(a)+1 from A",
+		"select A.a+B.a as x, count(*) from A join B on A.id=B.id group by x",
+		"select a, rank() over () + 1 from A",
+		"select sum(rank() over ()) over () from A",
+		"select a from A where rank() over () > 1",
+		"select a from A order by rank() over ()",
+		"select distinct a, rank() over () as r from A",
+		"select A.id from A full join B on A.id > B.id",
+		"select /*+nested+*/ A.id from A full join B on A.id = B.id and A.a > B.a",
+		"select A.id from A natural join B",
+		"select count(*) from A full join B using (id) where A.a > 1",
+		"select id from (A, B) join G using (id)",
+		"select id from A full join B using (id) join G using (id)",
+		"select a from A where exists (select count(*) from B where B.id = A.id)",
+		"select a from A where (a, b) in (select a, b from B)",
+		"select a from A where a in (select a, b from B)",
+		"select a from A where exists (select 1 from B where B.id in (select id from G))",
+		"select a from A where exists (select 1 from B union select 1 from G)",
+		"select a from A where exists (select 1 from A where A.id = 1)",
+		"select a from A where exists (select 1 from B where B.b = A.b) order by B.a",
 	}
 	results := []string{
 		"unsupported: subqueries.in.select",
 		"unsupported: distinct",
 		"unsupported: '*'.expression.in.cross-shard.query",
-		"unsupported: orderby[b].should.in.select.list",
 		"unsupported: limit.offset.or.counts.must.be.IntVal",
-		"unsupported: expr[count(*)].in.having.clause",
 		"unsupported: unknown.table.'B'.in.clause",
 		"unsupported: invalid.use.of.group.function[count]",
-		"unsupported: 'round(avg(id))'.contain.aggregate.in.select.exprs",
-		"unsupported: group_concat.in.select.exprs",
+		"unsupported: orderby:[1].in.group_concat",
 		"unsupported: nextval.in.select.exprs",
 		"unsupported: subqueries.in.select",
-		"unsupported: 'avg(id) * 1000'.contain.aggregate.in.select.exprs",
 		"unsupported: syntax.error.at.'avg(*)'",
 		"unsupported:  unknown.table.'B'.in.field.list",
-		"unsupported: expr[count(a)].in.having.clause",
+		"unsupported: exists.aggregate.and.'*'.select.exprs",
 		"unsupported: unknow.table.in.group.by.field[B.a]",
-		"unsupported: expr.'concat(B.str, G.str)'.in.cross-shard.join",
 		"unsupported: exists.aggregate.and.'*'.select.exprs",
 		"unsupported: on.clause.'A.a > B.a'.in.cross-shard.join",
 		"unsupported: expr.'A.a > B.a'.in.cross-shard.join",
@@ -666,15 +673,29 @@ func TestSelectUnsupportedPlan(t *testing.T) {
 		"unsupported: expr.'A.id + B.id'.in.cross-shard.join",
 		"unsupported: expr.'A.id + B.id'.in.cross-shard.join",
 		"unsupported: expr.'A.id + B.id'.in.cross-shard.join",
-		"unsupported: unknown.column.'tmp'.in.having.clause",
-		"unsupported: expr.'COALESCE(B.b, '')'.in.cross-shard.left.join",
-		"unsupported: orderby[A.b].should.in.select.list",
-		"unsupported: orderby:[a + 1].type.should.be.colname",
-		"unsupported: group.by.field[A.a].should.be.in.select.list",
-		"unsupported: group.by.[a + 1].type.should.be.colname",
 		"unsupported: syntax.error.at.'count(distinct *)'",
 		"unsupported: unknown.column.'t1.a'.in.exprs",
 		"unsupported: clause.'A.id in (1, 2) or B.a in (1)'.in.cross-shard.join",
+		"unsupported: distinct",
+		"unsupported: group.by.field[x].should.be.in.noaggregate.select.list",
+		"unsupported: 'rank() over () + 1'.contain.window.function.in.select.exprs",
+		"unsupported: window.function.in.window.function.'sum(rank() over ()) over ()'",
+		"unsupported: window.function.in.where.clause",
+		"unsupported: window.function.in.order.by.clause",
+		"unsupported: distinct",
+		"unsupported: clause.'A.id > B.id'.in.full.join",
+		"unsupported: clause.'A.a > B.a'.in.full.join",
+		"unsupported: natural.join.in.cross-shard.query",
+		"unsupported: where.clause.'A.a > 1'.in.full.join.with.aggregation",
+		"unsupported: column.'id'.in.using.clause.is.ambiguous",
+		"unsupported: expr.'coalesce(A.id, B.id)'.in.cross-shard.full.join",
+		"unsupported: aggregation.or.limit.in.subquery.'(select count(*) from B where B.id = A.id)'",
+		"unsupported: row.constructor.'(A.a, A.b)'.in.subquery",
+		"unsupported: subquery.should.return.one.column",
+		"unsupported: nested.subqueries.in.select",
+		"unsupported: union.in.subquery",
+		"unsupported: not.unique.table.or.alias:'A'",
+		"unsupported: unknown.column.'B.a'.in.field.list",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -725,6 +746,28 @@ func TestSelectSupportedPlan(t *testing.T) {
 		"select /*+nested+*/ A.id from G,A,B where A.id=B.id having G.id=B.id and B.a=1 and 1=1",
 		"select COALESCE(A.b, ''), IF(A.b IS NULL, FALSE, TRUE) AS spent from A left join B on A.a=B.a",
 		"select COALESCE(B.b, ''), IF(B.b IS NULL, FALSE, TRUE) AS spent from A join B on A.a=B.a",
+		"select age,count(*) from A group by age having count(*) >=2",
+		"select round(avg(id)) from A",
+		"select avg(id)*1000 from A",
+		"select A.id,G.a as a, concat(B.str,G.str), 1 from A,B, A as G group by a",
+		"select A.id as tmp, B.id from A,B having tmp=1",
+		"select COALESCE(B.b, ''), IF(B.b IS NULL, FALSE, TRUE) AS spent from A left join B on A.a=B.a",
+		"select ifnull(max(b), 0), concat(str, '-', sum(b)) from A group by str order by sum(b)/count(b) desc",
+		"select a, sum(b), rank() over (order by sum(b) desc) from A group by a",
+		"select id, sum(b), rank() over (partition by id order by sum(b)) from A group by id",
+		"select A.a, B.b, lag(B.b) over (partition by A.a order by B.b) from A join B on A.id=B.id",
+		"select id, A.a, B.b from A join B using (id) where id > 1 order by id",
+		"select id, B.b from A right join B using (id, a) group by id",
+		"select * from A natural join G",
+		"select A.id, B.id from A full outer join B on A.id=B.id where A.id is null",
+		"select concat(A.a, B.a) from A full join B using (id)",
+		"select a from A where exists (select 1 from B where B.id = A.id and B.a > A.a)",
+		"select a, b from A where not exists (select 1 from B where B.id = A.id) and a in (select a from B as C) order by a",
+		"select a from A where id not in (select id from B where B.b > 1)",
+		"select a from A where a + 1 not in (select b from B)",
+		"select /*+nested+*/ a from A where a not in (select b from B where B.id = A.id)",
+		"select a from A where id in (select id from A as A2 where A2.b = 1)",
+		"select sum(a) from A where exists (select 1 from G where G.id = A.id) group by b",
 	}
 
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
@@ -749,6 +792,49 @@ func TestSelectSupportedPlan(t *testing.T) {
 	}
 }
 
+func TestSelectPlanDistinctAggregate(t *testing.T) {
+	querys := []string{
+		"select b,group_concat(distinct name) from A group by b",
+		"select group_concat(a, b order by c desc separator ';') as g from A",
+		"select count(distinct a), max(b), c from A group by c",
+		"select count(distinct a), count(b) from A",
+		"select group_concat(distinct a order by a) from A",
+	}
+	wants := []string{
+		"select b, name as `group_concat(distinct name)` from sbtest.A1 as A group by b, name order by b asc",
+		"select concat(a, b) as g, c from sbtest.A1 as A",
+		"select a as `count(distinct a)`, b as `max(b)`, c from sbtest.A1 as A group by c, a, b order by c asc",
+		"select a as `count(distinct a)`, b as `count(b)` from sbtest.A1 as A",
+		"select a as `group_concat(distinct a order by a asc)`, a from sbtest.A1 as A group by a",
+	}
+	aggrs := [][]Aggregator{
+		{{Field: "group_concat(distinct name)", Index: 1, Type: AggrTypeGroupConcat, Distinct: true, Separator: ","}},
+		{{Field: "group_concat(a, b order by c desc separator ';')", Index: 0, Type: AggrTypeGroupConcat, Separator: ";", OrderBys: []ConcatOrderBy{{Index: 1, Direction: DESC}}}},
+		{{Field: "count(distinct a)", Index: 0, Type: AggrTypeCount, Distinct: true}, {Field: "max(b)", Index: 1, Type: AggrTypeMax}},
+		{{Field: "count(distinct a)", Index: 0, Type: AggrTypeCount, Distinct: true}, {Field: "count(b)", Index: 1, Type: AggrTypeCount}},
+		{{Field: "group_concat(distinct a order by a asc)", Index: 0, Type: AggrTypeGroupConcat, Distinct: true, Separator: ",", OrderBys: []ConcatOrderBy{{Index: 1, Direction: ASC}}}},
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+		err = plan.Build()
+		assert.Nil(t, err)
+		assert.Equal(t, wants[i], plan.Root.GetQuery()[0].Query)
+		aggrPlan := plan.Root.Children().Plans()[0].(*AggregatePlan)
+		assert.Equal(t, aggrs[i], aggrPlan.NormalAggregators())
+	}
+}
+
 func TestSelectPlanAs(t *testing.T) {
 	results := []string{
 		`{
@@ -1077,3 +1163,400 @@ func TestSelectPlanJoinErr(t *testing.T) {
 		}
 	}
 }
+
+func TestSelectPlanEvaluate(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "select a, sum(b)/count(c) as x from B group by a having max(b) \u003e 1 order by x desc",
+	"Project": "a, x",
+	"Partitions": [
+		{
+			"Query": "select a, null as x, sum(b) as ` + "`sum(b)`" + `, count(c) as ` + "`count(c)`" + `, max(b) as ` + "`max(b)`" + ` from sbtest.B0 as B group by a order by x desc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select a, null as x, sum(b) as ` + "`sum(b)`" + `, count(c) as ` + "`count(c)`" + `, max(b) as ` + "`max(b)`" + ` from sbtest.B1 as B group by a order by x desc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Aggregate": [
+		"sum(b)",
+		"count(c)",
+		"max(b)"
+	],
+	"GatherMerge": [
+		"x"
+	],
+	"HashGroupBy": [
+		"a"
+	],
+	"Evaluate": [
+		"sum(b) / count(c)"
+	],
+	"Filter": [
+		"max(b) \u003e 1"
+	]
+}`,
+		`{
+	"RawQuery": "select B.a, B.a+C.a as s from B join B as C on B.a=C.a having B.id\u003cC.id",
+	"Project": "a, s",
+	"Partitions": [
+		{
+			"Query": "select B.a, null as s, B.id from sbtest.B0 as B order by B.a asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select B.a, null as s, B.id from sbtest.B1 as B order by B.a asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "select C.a, C.id from sbtest.B0 as C order by C.a asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select C.a, C.id from sbtest.B1 as C order by C.a asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Join": {
+		"Type": "INNER JOIN",
+		"Strategy": "Sort Merge Join"
+	},
+	"Evaluate": [
+		"B.a + C.a"
+	],
+	"Filter": [
+		"B.id \u003c C.id"
+	]
+}`,
+	}
+	querys := []string{
+		"select a, sum(b)/count(c) as x from B group by a having max(b) > 1 order by x desc",
+		"select B.a, B.a+C.a as s from B join B as C on B.a=C.a having B.id<C.id",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			got := plan.JSON()
+			want := results[i]
+			assert.Equal(t, want, got)
+			assert.Equal(t, PlanTypeSelect, plan.Type())
+		}
+	}
+}
+
+func TestSelectPlanWindow(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "select id, row_number() over (partition by id order by a) as rn from B order by rn limit 1",
+	"Project": "id, rn",
+	"Partitions": [
+		{
+			"Query": "select id, row_number() over (partition by id order by a asc) as rn from sbtest.B0 as B order by rn asc limit 1",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select id, row_number() over (partition by id order by a asc) as rn from sbtest.B1 as B order by rn asc limit 1",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"GatherMerge": [
+		"rn"
+	],
+	"Limit": {
+		"Offset": 0,
+		"Limit": 1
+	}
+}`,
+		`{
+	"RawQuery": "select a, rank() over (partition by a order by b desc) as r, sum(c) over () from B where id\u003e1 order by r limit 3",
+	"Project": "a, r, sum(c) over ()",
+	"Partitions": [
+		{
+			"Query": "select a, null as r, null as ` + "`sum(c) over ()`" + `, b, c from sbtest.B0 as B where id \u003e 1 order by r asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select a, null as r, null as ` + "`sum(c) over ()`" + `, b, c from sbtest.B1 as B where id \u003e 1 order by r asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"GatherMerge": [
+		"r"
+	],
+	"Window": [
+		"rank() over (partition by a order by b desc)",
+		"sum(c) over ()"
+	],
+	"Limit": {
+		"Offset": 0,
+		"Limit": 3
+	}
+}`,
+	}
+	querys := []string{
+		"select id, row_number() over (partition by id order by a) as rn from B order by rn limit 1",
+		"select a, rank() over (partition by a order by b desc) as r, sum(c) over () from B where id>1 order by r limit 3",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			got := plan.JSON()
+			want := results[i]
+			assert.Equal(t, want, got)
+			assert.Equal(t, PlanTypeSelect, plan.Type())
+		}
+	}
+}
+
+func TestSelectPlanFullJoin(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "select id, A.a, B.b from A full join B using (id) where B.b \u003e 1 order by id limit 2",
+	"Project": "id, a, b",
+	"Partitions": [
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A1 as A order by A.id asc",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		},
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A2 as A order by A.id asc",
+			"Backend": "backend2",
+			"Range": "[32-64)"
+		},
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A3 as A order by A.id asc",
+			"Backend": "backend3",
+			"Range": "[64-96)"
+		},
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A4 as A order by A.id asc",
+			"Backend": "backend4",
+			"Range": "[96-256)"
+		},
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A5 as A order by A.id asc",
+			"Backend": "backend5",
+			"Range": "[256-512)"
+		},
+		{
+			"Query": "select null as id, A.a, A.id from sbtest.A6 as A order by A.id asc",
+			"Backend": "backend6",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "select B.b, B.id from sbtest.B0 as B order by B.id asc",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select B.b, B.id from sbtest.B1 as B order by B.id asc",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Join": {
+		"Type": "FULL JOIN",
+		"Strategy": "Sort Merge Join"
+	},
+	"GatherMerge": [
+		"id"
+	],
+	"Evaluate": [
+		"coalesce(A.id, B.id)"
+	],
+	"Filter": [
+		"B.b \u003e 1"
+	],
+	"Limit": {
+		"Offset": 0,
+		"Limit": 2
+	}
+}`,
+	}
+	querys := []string{
+		"select id, A.a, B.b from A full join B using (id) where B.b > 1 order by id limit 2",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			got := plan.JSON()
+			want := results[i]
+			assert.Equal(t, want, got)
+		}
+	}
+}
+
+func TestSelectPlanSemiJoin(t *testing.T) {
+	results := []string{
+		`{
+	"RawQuery": "select a from A where a not in (select b from B where B.id = A.id) and b \u003e 1",
+	"Project": "a",
+	"Partitions": [
+		{
+			"Query": "select A.a, A.id from sbtest.A1 as A where A.b \u003e 1",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		},
+		{
+			"Query": "select A.a, A.id from sbtest.A2 as A where A.b \u003e 1",
+			"Backend": "backend2",
+			"Range": "[32-64)"
+		},
+		{
+			"Query": "select A.a, A.id from sbtest.A3 as A where A.b \u003e 1",
+			"Backend": "backend3",
+			"Range": "[64-96)"
+		},
+		{
+			"Query": "select A.a, A.id from sbtest.A4 as A where A.b \u003e 1",
+			"Backend": "backend4",
+			"Range": "[96-256)"
+		},
+		{
+			"Query": "select A.a, A.id from sbtest.A5 as A where A.b \u003e 1",
+			"Backend": "backend5",
+			"Range": "[256-512)"
+		},
+		{
+			"Query": "select A.a, A.id from sbtest.A6 as A where A.b \u003e 1",
+			"Backend": "backend6",
+			"Range": "[512-4096)"
+		},
+		{
+			"Query": "select B.id, B.b from sbtest.B0 as B",
+			"Backend": "backend1",
+			"Range": "[0-512)"
+		},
+		{
+			"Query": "select B.id, B.b from sbtest.B1 as B",
+			"Backend": "backend2",
+			"Range": "[512-4096)"
+		}
+	],
+	"Join": {
+		"Type": "ANTI JOIN",
+		"Strategy": "Hash Join"
+	}
+}`,
+		`{
+	"RawQuery": "select a from A where not exists (select 1 from G where G.a = A.a)",
+	"Project": "a",
+	"Partitions": [
+		{
+			"Query": "select A.a from sbtest.A1 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend1",
+			"Range": "[0-32)"
+		},
+		{
+			"Query": "select A.a from sbtest.A2 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend2",
+			"Range": "[32-64)"
+		},
+		{
+			"Query": "select A.a from sbtest.A3 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend3",
+			"Range": "[64-96)"
+		},
+		{
+			"Query": "select A.a from sbtest.A4 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend4",
+			"Range": "[96-256)"
+		},
+		{
+			"Query": "select A.a from sbtest.A5 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend5",
+			"Range": "[256-512)"
+		},
+		{
+			"Query": "select A.a from sbtest.A6 as A where not exists (select 1 from sbtest.G where G.a = A.a)",
+			"Backend": "backend6",
+			"Range": "[512-4096)"
+		}
+	]
+}`,
+	}
+	querys := []string{
+		"select a from A where a not in (select b from B where B.id = A.id) and b > 1",
+		"select a from A where not exists (select 1 from G where G.a = A.a)",
+	}
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig(), router.MockTableGConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		plan := NewSelectPlan(log, database, query, node.(*sqlparser.Select), route)
+
+		// plan build
+		{
+			err := plan.Build()
+			assert.Nil(t, err)
+			got := plan.JSON()
+			want := results[i]
+			assert.Equal(t, want, got)
+		}
+	}
+}
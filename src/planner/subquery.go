@@ -0,0 +1,252 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"router"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// semiJoin is the [NOT] EXISTS or [NOT] IN subquery in the where clause,
+// which is planned as the semi join or anti join.
+type semiJoin struct {
+	// expr is the predicate in the where clause, pushed down if the subquery can be merged.
+	expr sqlparser.Expr
+	// sel is the subquery.
+	sel *sqlparser.Select
+	// anti is true for NOT EXISTS and NOT IN.
+	anti bool
+	// in is the 'left = subquery column' comparison of [NOT] IN, nil for [NOT] EXISTS.
+	in *sqlparser.ComparisonExpr
+}
+
+/* extractSemiJoins used to extract the [NOT] EXISTS and [NOT] IN subqueries from the
+ * where clause, only the subqueries in the top level AND conditions are supported.
+ * If there're subqueries, the unqualified columns of the outer query and the subqueries
+ * are qualified by their only table.
+ * eg: select a from t1 where exists(select 1 from t2 where t2.id=t1.id) and b>1;
+ * ->  select t1.a from t1 where t1.b>1; semi join: exists(select 1 from t2 where t2.id=t1.id).
+ */
+func extractSemiJoins(r *router.Router, database string, node *sqlparser.Select) ([]*semiJoin, error) {
+	if node.Where == nil || !hasSubquery(node.Where) {
+		return nil, nil
+	}
+
+	scope, err := scanJoinScopes(r, database, node.From)
+	if err != nil {
+		return nil, err
+	}
+	replaceColumns(node, scope.qualify)
+
+	var semis []*semiJoin
+	var where sqlparser.Expr
+	for _, expr := range splitAndExpression(nil, node.Where.Expr) {
+		semi, err := parserSemiJoin(r, database, expr)
+		if err != nil {
+			return nil, err
+		}
+		if semi != nil {
+			semis = append(semis, semi)
+			continue
+		}
+		if where == nil {
+			where = expr
+		} else {
+			where = &sqlparser.AndExpr{Left: where, Right: expr}
+		}
+	}
+
+	node.Where = nil
+	if where != nil {
+		node.AddWhere(where)
+	}
+	return semis, nil
+}
+
+// parserSemiJoin used to parser the [NOT] EXISTS and [NOT] IN subquery, returns nil if the expr isn't.
+func parserSemiJoin(r *router.Router, database string, expr sqlparser.Expr) (*semiJoin, error) {
+	var subquery *sqlparser.Subquery
+	semi := &semiJoin{expr: expr}
+	switch exp := skipParenthesis(expr).(type) {
+	case *sqlparser.ExistsExpr:
+		subquery = exp.Subquery
+	case *sqlparser.NotExpr:
+		if exists, ok := skipParenthesis(exp.Expr).(*sqlparser.ExistsExpr); ok {
+			subquery = exists.Subquery
+			semi.anti = true
+		}
+	case *sqlparser.ComparisonExpr:
+		if sub, ok := exp.Right.(*sqlparser.Subquery); ok && (exp.Operator == sqlparser.InStr || exp.Operator == sqlparser.NotInStr) {
+			if _, ok := exp.Left.(sqlparser.ValTuple); ok {
+				return nil, errors.Errorf("unsupported: row.constructor.'%s'.in.subquery", sqlparser.String(exp.Left))
+			}
+			subquery = sub
+			semi.anti = exp.Operator == sqlparser.NotInStr
+			semi.in = &sqlparser.ComparisonExpr{Operator: sqlparser.EqualStr, Left: exp.Left}
+		}
+	}
+	if subquery == nil {
+		return nil, nil
+	}
+
+	sel, ok := subquery.Select.(*sqlparser.Select)
+	if !ok {
+		return nil, errors.New("unsupported: union.in.subquery")
+	}
+	hasAggr := false
+	for _, expr := range sel.SelectExprs {
+		if aliasExpr, ok := expr.(*sqlparser.AliasedExpr); ok && hasAggregate(aliasExpr.Expr) {
+			hasAggr = true
+		}
+	}
+	if hasAggr || len(sel.GroupBy) > 0 || sel.Having != nil || sel.Limit != nil {
+		return nil, errors.Errorf("unsupported: aggregation.or.limit.in.subquery.'%s'", sqlparser.String(subquery))
+	}
+	if hasSubquery(sel) {
+		return nil, errors.New("unsupported: nested.subqueries.in.select")
+	}
+	semi.sel = sel
+
+	scope, err := scanJoinScopes(r, database, sel.From)
+	if err != nil {
+		return nil, err
+	}
+	replaceColumns(sel, scope.qualify)
+
+	if semi.in != nil {
+		if len(sel.SelectExprs) != 1 {
+			return nil, errors.New("unsupported: subquery.should.return.one.column")
+		}
+		aliasExpr, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr)
+		if !ok {
+			return nil, errors.New("unsupported: subquery.should.return.one.column")
+		}
+		semi.in.Right = aliasExpr.Expr
+	}
+	return semi, nil
+}
+
+// buildSemiJoin used to build the semi join or anti join of the root and the subquery.
+// The subquery is pushed down if it can be merged with the root, that's all the subquery's
+// tables are global tables, or the correlation is on the shardkeys which have the same shards.
+func buildSemiJoin(log *xlog.Log, r *router.Router, database string, root SelectNode, semi *semiJoin) (SelectNode, error) {
+	sub, err := scanTableExprs(log, r, database, semi.sel.From)
+	if err != nil {
+		return nil, err
+	}
+
+	referredTables := make(map[string]*TableInfo)
+	for k, v := range root.getReferredTables() {
+		referredTables[k] = v
+	}
+	subTables := sub.getReferredTables()
+	for k, v := range subTables {
+		if _, ok := referredTables[k]; ok {
+			return nil, errors.Errorf("unsupported: not.unique.table.or.alias:'%s'", k)
+		}
+		referredTables[k] = v
+	}
+
+	var exprs []sqlparser.Expr
+	if semi.sel.Where != nil {
+		exprs = append(exprs, semi.sel.Where.Expr)
+	}
+	// The 'NOT IN' comparison is NULL-aware, cannot be a join key.
+	if semi.in != nil && !semi.anti {
+		exprs = append(exprs, semi.in)
+	}
+
+	var joinOn []joinTuple
+	var others []filterTuple
+	for _, expr := range exprs {
+		joins, filters, err := parserWhereOrJoinExprs(expr, referredTables)
+		if err != nil {
+			return nil, err
+		}
+		// The conditions only referring to the subquery's tables are pushed into the subquery.
+		var subJoins []joinTuple
+		var subFilters []filterTuple
+		for _, jt := range joins {
+			if checkTbInNode(jt.referTables, subTables) {
+				subJoins = append(subJoins, jt)
+				continue
+			}
+			if jt, err = checkJoinOn(root, sub, jt); err != nil {
+				return nil, err
+			}
+			joinOn = append(joinOn, jt)
+		}
+		for _, filter := range filters {
+			if len(filter.referTables) > 0 && checkTbInNode(filter.referTables, subTables) {
+				subFilters = append(subFilters, filter)
+				continue
+			}
+			others = append(others, filter)
+		}
+		if err = sub.pushFilter(subFilters); err != nil {
+			return nil, err
+		}
+		sub = sub.pushEqualCmpr(subJoins)
+	}
+
+	notIn := semi.in != nil && semi.anti
+	if notIn {
+		if _, _, err = parserWhereOrJoinExprs(semi.in, referredTables); err != nil {
+			return nil, err
+		}
+		if !checkTbInNode(getTbInExpr(semi.in.Left), root.getReferredTables()) ||
+			!checkTbInNode(getTbInExpr(semi.in.Right), subTables) {
+			return nil, errors.Errorf("unsupported: clause.'%s'.in.not.in.subquery", sqlparser.String(semi.in))
+		}
+	}
+
+	if lmn, ok := root.(*MergeNode); ok {
+		if rmn, ok := sub.(*MergeNode); ok {
+			if rmn.nonGlobalCnt == 0 {
+				return mergeSemiJoin(lmn, rmn, semi), nil
+			}
+			keys := joinOn
+			if notIn {
+				lc, lok := semi.in.Left.(*sqlparser.ColName)
+				rc, rok := semi.in.Right.(*sqlparser.ColName)
+				if lok && rok {
+					keys = append(keys, joinTuple{left: lc, right: rc})
+				}
+			}
+			for _, jt := range keys {
+				if isSameShard(lmn.referredTables, rmn.referredTables, jt.left, jt.right) {
+					return mergeSemiJoin(lmn, rmn, semi), nil
+				}
+			}
+		}
+	}
+
+	jn := newJoinNode(log, root, sub, r, nil, joinOn, referredTables)
+	jn.semi = semi
+	jn.IsSemiJoin = !semi.anti
+	jn.IsAntiJoin = semi.anti
+	root.setParent(jn)
+	sub.setParent(jn)
+	jn.setOtherJoin(others)
+	return jn, nil
+}
+
+// mergeSemiJoin used to merge the subquery into the root, the predicate is pushed down.
+func mergeSemiJoin(lmn, rmn *MergeNode, semi *semiJoin) *MergeNode {
+	for k, v := range rmn.getReferredTables() {
+		v.parent = lmn
+		lmn.referredTables[k] = v
+	}
+	lmn.nonGlobalCnt += rmn.nonGlobalCnt
+	lmn.addWhere(semi.expr)
+	return lmn
+}
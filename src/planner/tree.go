@@ -0,0 +1,248 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"xcontext"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+)
+
+const treeIndent = "    "
+
+// Annotator used to annotate the lines of the plan tree, such as with the runtime
+// statistics of the 'EXPLAIN ANALYZE'.
+type Annotator interface {
+	// Annotate returns the text appended to the line of the plan node or the sub plan.
+	Annotate(plan interface{}) string
+	// Querys returns the lines of the querys sent by the merge node, which are
+	// rendered instead of the node's Querys.
+	Querys(node *MergeNode) []string
+}
+
+// FormatQuery returns the line of the query tuple in the plan tree.
+func FormatQuery(tuple xcontext.QueryTuple) string {
+	if tuple.Range == "" {
+		return fmt.Sprintf("Query on %s: %s", tuple.Backend, tuple.Query)
+	}
+	return fmt.Sprintf("Query on %s %s: %s", tuple.Backend, tuple.Range, tuple.Query)
+}
+
+// FormatTree returns the human-readable tree of the plan, like the 'EXPLAIN FORMAT=TREE'
+// of MySQL. The root is the last operation executed, the children are above their parent.
+// The annotator can be nil.
+func FormatTree(plan Plan, annotator Annotator) string {
+	t := &treeFormatter{annotator: annotator}
+	if hints := planHints(plan).Strings(); len(hints) > 0 {
+		fmt.Fprintf(&t.buf, "Hints: %s\n", strings.Join(hints, ", "))
+	}
+	switch plan := plan.(type) {
+	case *SelectPlan:
+		t.formatNode(plan.Root, 0)
+	case *UnionPlan:
+		t.formatNode(plan.Root, 0)
+	case *InsertPlan:
+		t.formatQuerys("Insert", plan, plan.ReqMode, plan.RawQuery, plan.Querys)
+	case *DeletePlan:
+		t.formatQuerys("Delete", plan, plan.ReqMode, plan.RawQuery, plan.Querys)
+	case *UpdatePlan:
+		t.formatQuerys("Update", plan, plan.ReqMode, plan.RawQuery, plan.Querys)
+	case *DDLPlan:
+		t.formatQuerys("DDL", plan, plan.ReqMode, plan.RawQuery, plan.Querys)
+	case *OthersPlan:
+		t.formatQuerys("Others", plan, plan.ReqMode, plan.RawQuery, plan.Querys)
+	}
+	return t.buf.String()
+}
+
+// planHints returns the hints of the plan, nil if the plan has no hints.
+func planHints(plan Plan) *Hints {
+	switch plan := plan.(type) {
+	case *SelectPlan:
+		return plan.Hints
+	case *InsertPlan:
+		return plan.Hints
+	case *DeletePlan:
+		return plan.Hints
+	case *UpdatePlan:
+		return plan.Hints
+	}
+	return nil
+}
+
+type treeFormatter struct {
+	buf       bytes.Buffer
+	annotator Annotator
+}
+
+// writeLine used to write the line with the depth, the annotation of the plan is appended.
+func (t *treeFormatter) writeLine(depth int, plan interface{}, line string) {
+	t.buf.WriteString(strings.Repeat(treeIndent, depth))
+	t.buf.WriteString("-> ")
+	t.buf.WriteString(line)
+	if t.annotator != nil && plan != nil {
+		if annotation := t.annotator.Annotate(plan); annotation != "" {
+			t.buf.WriteString("  ")
+			t.buf.WriteString(annotation)
+		}
+	}
+	t.buf.WriteString("\n")
+}
+
+// formatNode used to format the node, its sub plans are executed in order after the node,
+// so they're written in reverse order above the node.
+func (t *treeFormatter) formatNode(node PlanNode, depth int) {
+	var plans []Plan
+	if children := node.Children(); children != nil {
+		plans = children.Plans()
+	}
+	for i := len(plans) - 1; i >= 0; i-- {
+		t.writeLine(depth, plans[i], formatSubPlan(plans[i]))
+		depth++
+	}
+
+	switch node := node.(type) {
+	case *MergeNode:
+		t.formatMergeNode(node, depth)
+	case *JoinNode:
+		t.writeLine(depth, node, formatJoinNode(node))
+		t.formatNode(node.Left, depth+1)
+		t.formatNode(node.Right, depth+1)
+	case *UnionNode:
+		t.writeLine(depth, node, "Union"+strings.TrimPrefix(node.Typ, "union"))
+		t.formatNode(node.Left, depth+1)
+		t.formatNode(node.Right, depth+1)
+	}
+}
+
+func (t *treeFormatter) formatMergeNode(node *MergeNode, depth int) {
+	switch node.ReqMode {
+	case xcontext.ReqSingle:
+		t.writeLine(depth, node, fmt.Sprintf("Route to single backend: %s", sqlparser.String(node.Sel)))
+	case xcontext.ReqScatter:
+		t.writeLine(depth, node, fmt.Sprintf("Route to all backends: %s", sqlparser.String(node.Sel)))
+	default:
+		t.writeLine(depth, node, fmt.Sprintf("Merge: %d partitions", len(node.Querys)))
+	}
+
+	var lines []string
+	if t.annotator != nil {
+		lines = t.annotator.Querys(node)
+	} else {
+		for _, tuple := range node.Querys {
+			lines = append(lines, FormatQuery(tuple))
+		}
+	}
+	for _, line := range lines {
+		t.writeLine(depth+1, nil, line)
+	}
+}
+
+func (t *treeFormatter) formatQuerys(name string, plan Plan, mode xcontext.RequestMode, query string, querys []xcontext.QueryTuple) {
+	switch mode {
+	case xcontext.ReqSingle:
+		t.writeLine(0, plan, fmt.Sprintf("%s on single backend: %s", name, query))
+	case xcontext.ReqScatter:
+		t.writeLine(0, plan, fmt.Sprintf("%s on all backends: %s", name, query))
+	default:
+		t.writeLine(0, plan, fmt.Sprintf("%s: %d partitions", name, len(querys)))
+		for _, tuple := range querys {
+			t.writeLine(1, nil, FormatQuery(tuple))
+		}
+	}
+}
+
+func formatJoinNode(node *JoinNode) string {
+	var typ, strategy string
+	switch {
+	case node.IsSemiJoin:
+		typ = "Semi join"
+	case node.IsAntiJoin:
+		typ = "Anti join"
+	case node.IsLeftJoin:
+		typ = "Left join"
+	case node.IsFullJoin:
+		typ = "Full join"
+	case node.Strategy == Cartesian:
+		typ = "Cross join"
+	default:
+		typ = "Inner join"
+	}
+	switch node.Strategy {
+	case Cartesian:
+		strategy = "Cartesian Join"
+	case SortMerge:
+		strategy = "Sort Merge Join"
+	case NestedLoop:
+		strategy = "Nested Loop Join"
+	case Hash:
+		strategy = "Hash Join"
+	}
+	var keys []string
+	for i := range node.LeftKeys {
+		keys = append(keys, fmt.Sprintf("%s.%s = %s.%s", node.LeftKeys[i].Table, node.LeftKeys[i].Field,
+			node.RightKeys[i].Table, node.RightKeys[i].Field))
+	}
+	if len(keys) == 0 {
+		return fmt.Sprintf("%s (%s)", typ, strategy)
+	}
+	return fmt.Sprintf("%s (%s): %s", typ, strategy, strings.Join(keys, " and "))
+}
+
+func formatSubPlan(plan Plan) string {
+	var items []string
+	switch plan := plan.(type) {
+	case *AggregatePlan:
+		var groups []string
+		for _, aggr := range plan.normalAggrs {
+			items = append(items, aggr.Field)
+		}
+		for _, aggr := range plan.groupAggrs {
+			groups = append(groups, aggr.Field)
+		}
+		switch {
+		case len(groups) == 0:
+			return fmt.Sprintf("Aggregate: %s", strings.Join(items, ", "))
+		case len(items) == 0:
+			return fmt.Sprintf("Group by: %s", strings.Join(groups, ", "))
+		}
+		return fmt.Sprintf("Aggregate: %s; group by: %s", strings.Join(items, ", "), strings.Join(groups, ", "))
+	case *OrderByPlan:
+		for _, order := range plan.OrderBys {
+			field := order.Field
+			if order.Table != "" {
+				field = strings.Join([]string{order.Table, order.Field}, ".")
+			}
+			items = append(items, fmt.Sprintf("%s %s", field, order.Direction))
+		}
+		return fmt.Sprintf("Sort: %s", strings.Join(items, ", "))
+	case *ProjectPlan:
+		for _, proj := range plan.Projections {
+			items = append(items, sqlparser.String(proj.Expr))
+		}
+		return fmt.Sprintf("Evaluate: %s", strings.Join(items, ", "))
+	case *FilterPlan:
+		for _, expr := range plan.Filters {
+			items = append(items, sqlparser.String(expr))
+		}
+		return fmt.Sprintf("Filter: %s", strings.Join(items, " and "))
+	case *WindowPlan:
+		for _, w := range plan.Windows {
+			items = append(items, sqlparser.String(w.Func))
+		}
+		return fmt.Sprintf("Window: %s", strings.Join(items, ", "))
+	case *LimitPlan:
+		return fmt.Sprintf("Limit: offset=%d, limit=%d", plan.Offset, plan.Limit)
+	}
+	return string(plan.Type())
+}
@@ -0,0 +1,78 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestFormatTree(t *testing.T) {
+	querys := []string{
+		"select a, sum(b) from B where id>1 group by a having sum(b)>10 order by a desc limit 10",
+		"select A.a, B.b from A join B on A.a=B.a where A.id=1 order by B.b",
+		"select a from B union all select a from A where id=1",
+		"delete from B where id>1",
+	}
+	results := []string{
+		`-> Limit: offset=0, limit=10
+    -> Sort: a DESC
+        -> Filter: sum(b) > 10
+            -> Aggregate: sum(b); group by: a
+                -> Merge: 2 partitions
+                    -> Query on backend1 [0-512): select a, sum(b) from sbtest.B0 as B where id > 1 group by a order by a desc
+                    -> Query on backend2 [512-4096): select a, sum(b) from sbtest.B1 as B where id > 1 group by a order by a desc
+`,
+		`-> Sort: B.b ASC
+    -> Inner join (Sort Merge Join): A.a = B.a
+        -> Merge: 1 partitions
+            -> Query on backend6 [512-4096): select A.a from sbtest.A6 as A where A.id = 1 order by A.a asc
+        -> Merge: 2 partitions
+            -> Query on backend1 [0-512): select B.b, B.a from sbtest.B0 as B order by B.a asc
+            -> Query on backend2 [512-4096): select B.b, B.a from sbtest.B1 as B order by B.a asc
+`,
+		`-> Union all
+    -> Merge: 2 partitions
+        -> Query on backend1 [0-512): select a from sbtest.B0 as B
+        -> Query on backend2 [512-4096): select a from sbtest.B1 as B
+    -> Merge: 1 partitions
+        -> Query on backend6 [512-4096): select a from sbtest.A6 as A where id = 1
+`,
+		`-> Delete: 2 partitions
+    -> Query on backend1 [0-512): delete from sbtest.B0 where id > 1
+    -> Query on backend2 [512-4096): delete from sbtest.B1 where id > 1
+`,
+	}
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	database := "sbtest"
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	err := route.AddForTest(database, router.MockTableMConfig(), router.MockTableBConfig())
+	assert.Nil(t, err)
+	for i, query := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		var plan Plan
+		switch node := node.(type) {
+		case *sqlparser.Select:
+			plan = NewSelectPlan(log, database, query, node, route)
+		case *sqlparser.Union:
+			plan = NewUnionPlan(log, database, query, node, route)
+		case *sqlparser.Delete:
+			plan = NewDeletePlan(log, database, query, node, route)
+		}
+		assert.Nil(t, plan.Build())
+		assert.Equal(t, results[i], FormatTree(plan, nil))
+	}
+}
@@ -48,6 +48,9 @@ type UpdatePlan struct {
 
 	// query and backend tuple
 	Querys []xcontext.QueryTuple
+
+	// Hints are the optimizer hints in the comments.
+	Hints *Hints
 }
 
 // NewUpdatePlan used to create UpdatePlan
@@ -83,6 +86,12 @@ func (p *UpdatePlan) Build() error {
 	}
 
 	node := p.node
+	hints, err := ParseHints(node.Comments)
+	if err != nil {
+		return err
+	}
+	p.Hints = hints
+
 	// Database.
 	database := p.database
 	if !node.Table.Qualifier.IsEmpty() {
@@ -118,7 +127,8 @@ func (p *UpdatePlan) Build() error {
 		}
 		p.Querys = append(p.Querys, tuple)
 	}
-	return nil
+	p.Querys, err = hints.routeDML(p.Querys, shardkey)
+	return err
 }
 
 // Type returns the type of the plan.
@@ -131,6 +141,7 @@ func (p *UpdatePlan) JSON() string {
 	type explain struct {
 		RawQuery   string                `json:",omitempty"`
 		Partitions []xcontext.QueryTuple `json:",omitempty"`
+		Hints      []string              `json:",omitempty"`
 	}
 
 	// Partitions.
@@ -139,6 +150,7 @@ func (p *UpdatePlan) JSON() string {
 	exp := &explain{
 		RawQuery:   p.RawQuery,
 		Partitions: parts,
+		Hints:      p.Hints.Strings(),
 	}
 	bout, err := json.MarshalIndent(exp, "", "\t")
 	if err != nil {
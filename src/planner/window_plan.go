@@ -0,0 +1,92 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"encoding/json"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+var (
+	_ Plan = &WindowPlan{}
+)
+
+// Window is the window function computed by the proxy after the results merged.
+type Window struct {
+	// Field is the name of the column.
+	Field string
+	// Index is the index of the column in the results.
+	Index int
+	// Func is the window function with the OVER clause.
+	Func *sqlparser.FuncExpr `json:"-"`
+}
+
+// WindowPlan represents window plan, the rows are sorted by the partition by and
+// the order by of the windows, then computed partition by partition.
+type WindowPlan struct {
+	log *xlog.Log
+
+	Windows []Window
+
+	// type
+	typ PlanType
+}
+
+// NewWindowPlan used to create WindowPlan.
+func NewWindowPlan(log *xlog.Log, windows []Window) *WindowPlan {
+	return &WindowPlan{
+		log:     log,
+		Windows: windows,
+		typ:     PlanTypeWindow,
+	}
+}
+
+// Build used to build distributed querys.
+func (p *WindowPlan) Build() error {
+	return nil
+}
+
+// Type returns the type of the plan.
+func (p *WindowPlan) Type() PlanType {
+	return p.typ
+}
+
+// JSON returns the plan info.
+func (p *WindowPlan) JSON() string {
+	type window struct {
+		Field string
+		Index int
+		Func  string
+	}
+	type explain struct {
+		Windows []window
+	}
+
+	exp := &explain{}
+	for _, w := range p.Windows {
+		exp.Windows = append(exp.Windows, window{Field: w.Field, Index: w.Index, Func: sqlparser.String(w.Func)})
+	}
+	bout, err := json.MarshalIndent(exp, "", "\t")
+	if err != nil {
+		return err.Error()
+	}
+	return string(bout)
+}
+
+// Children returns the children of the plan.
+func (p *WindowPlan) Children() *PlanTree {
+	return nil
+}
+
+// Size returns the memory size.
+func (p *WindowPlan) Size() int {
+	return 0
+}
@@ -0,0 +1,51 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestWindowPlan(t *testing.T) {
+	query := "select a, rank() over (partition by a order by b desc) as r, sum(b) over () from t"
+	want := `{
+	"Windows": [
+		{
+			"Field": "r",
+			"Index": 1,
+			"Func": "rank() over (partition by a order by b desc)"
+		},
+		{
+			"Field": "sum(b) over ()",
+			"Index": 2,
+			"Func": "sum(b) over ()"
+		}
+	]
+}`
+
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	tree, err := sqlparser.Parse(query)
+	assert.Nil(t, err)
+	exprs := tree.(*sqlparser.Select).SelectExprs
+	windows := []Window{
+		{Field: "r", Index: 1, Func: exprs[1].(*sqlparser.AliasedExpr).Expr.(*sqlparser.FuncExpr)},
+		{Field: "sum(b) over ()", Index: 2, Func: exprs[2].(*sqlparser.AliasedExpr).Expr.(*sqlparser.FuncExpr)},
+	}
+	plan := NewWindowPlan(log, windows)
+	err = plan.Build()
+	assert.Nil(t, err)
+	assert.Equal(t, want, plan.JSON())
+	assert.Nil(t, plan.Children())
+	assert.Equal(t, PlanTypeWindow, plan.Type())
+	assert.Equal(t, 0, plan.Size())
+}
@@ -9,9 +9,12 @@
 package autoincrement
 
 import (
+	"fmt"
+	"strings"
 	"sync"
-	"time"
 
+	"backend"
+	"config"
 	"router"
 
 	"github.com/xelabs/go-mysqlstack/sqlparser"
@@ -19,32 +22,36 @@ import (
 )
 
 // AutoIncrement struct.
-// Using Now().UnixNano as start seed seq.
+// The values are generated from the per-table sequences, the segments of which are
+// reserved from the store on the backend.
 type AutoIncrement struct {
-	mu     sync.Mutex
-	log    *xlog.Log
-	seq    uint64
-	router *router.Router
+	mu        sync.Mutex
+	log       *xlog.Log
+	router    *router.Router
+	store     *store
+	sequences map[string]*sequence
 }
 
 // NewAutoIncrement -- creates new AutoIncrement.
-func NewAutoIncrement(log *xlog.Log, router *router.Router) AutoIncrementHandler {
+func NewAutoIncrement(log *xlog.Log, router *router.Router, scatter *backend.Scatter) AutoIncrementHandler {
 	return &AutoIncrement{
-		log:    log,
-		router: router,
+		log:       log,
+		router:    router,
+		store:     newStore(log, scatter),
+		sequences: make(map[string]*sequence),
 	}
 }
 
 // Init -- used to init the plug module.
+// The store is accessed lazily, the backends may not be ready.
 func (autoinc *AutoIncrement) Init() error {
-	autoinc.seq = uint64(time.Now().UnixNano())
 	return nil
 }
 
 // Process -- process auto-increment.
-// Append the auto-increment column&value to the end of the row if not exists.
-func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert) error {
-	var seq uint64
+// Append the auto-increment column&value to the end of the row if not exists,
+// returns the first value generated, 0 if none.
+func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert, increment, offset uint64) (uint64, error) {
 	router := autoinc.router
 
 	// Qualifier is database in the insert query, such as "db.t1".
@@ -55,22 +62,157 @@ func (autoinc *AutoIncrement) Process(database string, ins *sqlparser.Insert) er
 
 	tblInfo, err := router.TableConfig(database, table)
 	if err != nil {
-		return err
+		return 0, err
+	}
+	if tblInfo.AutoIncrement == nil {
+		return 0, nil
+	}
+	n := autoincRows(ins, tblInfo.AutoIncrement)
+	if n == 0 {
+		return 0, nil
 	}
 
-	// Get seq(thread-safe).
-	autoinc.mu.Lock()
-	seq = autoinc.seq
-	switch rows := ins.Rows.(type) {
-	case sqlparser.Values:
-		autoinc.seq += uint64(len(rows))
+	// The values are generated from the sequence object.
+	if seqDB, name := sequenceOf(database, tblInfo.AutoIncrement); name != "" {
+		first, increment, err := autoinc.nextval(seqDB, name, uint64(n))
+		if err != nil {
+			return 0, err
+		}
+		modifyForAutoinc(ins, tblInfo.AutoIncrement, first, increment)
+		return first, nil
+	}
+
+	if increment == 0 {
+		increment = 1
+	}
+
+	seq := autoinc.getSequence(database, tableSequence(table))
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	// The sequence is seeded by the max value in the table, so the values are
+	// monotonic even if the store is lost or moved to another backend.
+	if !seq.seeded {
+		min, err := autoinc.store.seed(database, tblInfo.AutoIncrement.Column, tblInfo.Partitions)
+		if err != nil {
+			return 0, err
+		}
+		if min < tblInfo.AutoIncrement.Start {
+			min = tblInfo.AutoIncrement.Start
+		}
+		seq.min, seq.seeded = min, true
+	}
+	first, err := seq.nextValues(uint64(n), increment, offset, func(count uint64) (uint64, error) {
+		return autoinc.store.reserve(database, tableSequence(table), count, seq.min)
+	})
+	if err != nil {
+		return 0, err
+	}
+	modifyForAutoinc(ins, tblInfo.AutoIncrement, first, increment)
+	return first, nil
+}
+
+// Nextval returns the first of the n values generated from the sequence object,
+// the values are first, first+increment...
+func (autoinc *AutoIncrement) Nextval(database, name string, n uint64) (uint64, error) {
+	first, _, err := autoinc.nextval(database, name, n)
+	return first, err
+}
+
+func (autoinc *AutoIncrement) nextval(database, name string, n uint64) (uint64, uint64, error) {
+	conf, err := autoinc.router.SequenceConfig(database, name)
+	if err != nil {
+		return 0, 0, err
+	}
+	increment := conf.Increment
+	if increment == 0 {
+		increment = 1
+	}
+	// The values are congruent to the start modulo the increment.
+	offset := uint64(1)
+	if conf.Start > 0 {
+		offset = (conf.Start-1)%increment + 1
 	}
+
+	seq := autoinc.getSequence(database, objectSequence(name))
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	seq.cache = conf.Cache
+	// The sequence is seeded by the max values of the tables generated from it, so the values
+	// are monotonic even if the store is lost or moved to another backend.
+	if !seq.seeded {
+		min, err := autoinc.seedSequence(database, name)
+		if err != nil {
+			return 0, 0, err
+		}
+		if min < conf.Start {
+			min = conf.Start
+		}
+		seq.min, seq.seeded = min, true
+	}
+	first, err := seq.nextValues(n, increment, offset, func(count uint64) (uint64, error) {
+		return autoinc.store.reserve(database, objectSequence(name), count, seq.min)
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return first, increment, nil
+}
+
+// seedSequence returns the max of the auto-increment columns generated from the sequence plus one.
+func (autoinc *AutoIncrement) seedSequence(database, name string) (uint64, error) {
+	var max uint64
+	for db, tables := range autoinc.router.Tables() {
+		for _, table := range tables {
+			tblInfo, err := autoinc.router.TableConfig(db, table)
+			if err != nil || tblInfo.AutoIncrement == nil {
+				continue
+			}
+			if seqDB, seqName := sequenceOf(db, tblInfo.AutoIncrement); seqDB != database || seqName != name {
+				continue
+			}
+			v, err := autoinc.store.seed(db, tblInfo.AutoIncrement.Column, tblInfo.Partitions)
+			if err != nil {
+				return 0, err
+			}
+			if v > max {
+				max = v
+			}
+		}
+	}
+	return max, nil
+}
+
+// sequenceOf returns the database and the name of the sequence which the auto-increment column
+// is generated from, the name is empty if it's generated from the table's own sequence.
+func sequenceOf(database string, autoinc *config.AutoIncrement) (string, string) {
+	name := autoinc.Sequence
+	if idx := strings.Index(name, "."); idx >= 0 {
+		return name[:idx], name[idx+1:]
+	}
+	return database, name
+}
+
+// Reset used to forget the values of the sequence object, it starts over from the store.
+func (autoinc *AutoIncrement) Reset(database, name string) error {
+	name = objectSequence(name)
+	key := fmt.Sprintf("%s.%s", database, name)
+	autoinc.mu.Lock()
+	delete(autoinc.sequences, key)
 	autoinc.mu.Unlock()
+	return autoinc.store.remove(database, name)
+}
 
-	if tblInfo.AutoIncrement != nil {
-		modifyForAutoinc(ins, tblInfo.AutoIncrement, seq)
+// getSequence returns the sequence named in the store, creates it if not exists.
+func (autoinc *AutoIncrement) getSequence(database, name string) *sequence {
+	key := fmt.Sprintf("%s.%s", database, name)
+	autoinc.mu.Lock()
+	defer autoinc.mu.Unlock()
+	seq, ok := autoinc.sequences[key]
+	if !ok {
+		seq = &sequence{}
+		autoinc.sequences[key] = seq
 	}
-	return nil
+	return seq
 }
 
 // Close -- close the plugin.
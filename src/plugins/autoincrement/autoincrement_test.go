@@ -11,12 +11,15 @@ package autoincrement
 import (
 	"testing"
 
+	"backend"
 	"config"
 	"errors"
 	"router"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -43,6 +46,10 @@ func TestPluginAutoincGetAutoIncrement(t *testing.T) {
 			query:  "create table tab_auto_incr(a bigint not null auto_increment,b int not null,primary key (a))",
 			result: &config.AutoIncrement{Column: "a"},
 		},
+		{
+			query:  "create table tab_auto_incr(a bigint not null default 'nextval(`db1`.s1)' auto_increment,b int not null,primary key (a))",
+			result: &config.AutoIncrement{Column: "a", Sequence: "db1.s1"},
+		},
 	}
 
 	for _, test := range tests {
@@ -65,18 +72,21 @@ func TestPluginAutoincModifyForAutoinc(t *testing.T) {
 	tests := []struct {
 		query   string
 		want    string
+		first   uint64
 		autoinc *config.AutoIncrement
 	}{
 		// No autoinc column.
 		{
 			query:   "insert into t1(b) values(1),(2),(3)",
 			want:    "insert into t1(b, a) values (1, 65536), (2, 65537), (3, 65538)",
+			first:   65536,
 			autoinc: &config.AutoIncrement{Column: "a"},
 		},
 
 		{
 			query:   "insert into t1(b) values(1)",
 			want:    "insert into t1(b, a) values (1, 65536)",
+			first:   65536,
 			autoinc: &config.AutoIncrement{Column: "a"},
 		},
 
@@ -84,6 +94,7 @@ func TestPluginAutoincModifyForAutoinc(t *testing.T) {
 		{
 			query:   "replace into t1 (b) values(1),(2)",
 			want:    "replace into t1(b, a) values (1, 65536), (2, 65537)",
+			first:   65536,
 			autoinc: &config.AutoIncrement{Column: "a"},
 		},
 
@@ -106,7 +117,12 @@ func TestPluginAutoincModifyForAutoinc(t *testing.T) {
 		node, err := sqlparser.Parse(test.query)
 		assert.Nil(t, err)
 		insert := node.(*sqlparser.Insert)
-		modifyForAutoinc(insert, test.autoinc, 65535)
+		var first uint64
+		if autoincRows(insert, test.autoinc) > 0 {
+			first = 65536
+			modifyForAutoinc(insert, test.autoinc, first, 1)
+		}
+		assert.Equal(t, test.first, first)
 
 		buf := sqlparser.NewTrackedBuffer(nil)
 		insert.Format(buf)
@@ -122,8 +138,13 @@ func TestPluginAutoIncrement(t *testing.T) {
 	route, cleanup := router.MockNewRouter(log)
 	defer cleanup()
 
+	// Scatter.
+	scatter, fakedbs, scleanup := backend.MockScatter(log, 2)
+	defer scleanup()
+	MockInitSequence(fakedbs)
+
 	// Plugin.
-	autoplug := NewAutoIncrement(log, route)
+	autoplug := NewAutoIncrement(log, route, scatter)
 	err := autoplug.Init()
 	assert.Nil(t, err)
 	defer autoplug.Close()
@@ -169,8 +190,13 @@ func TestPluginAutoIncrement(t *testing.T) {
 		node, err := sqlparser.Parse(test.query)
 		assert.Nil(t, err)
 		insert := node.(*sqlparser.Insert)
-		err = autoplug.Process(db, insert)
+		first, err := autoplug.Process(db, insert, 0, 0)
 		assert.Nil(t, err)
+		if test.tblconf.AutoIncrement != nil {
+			assert.NotEqual(t, uint64(0), first)
+		} else {
+			assert.Equal(t, uint64(0), first)
+		}
 
 		// Check.
 		buf := sqlparser.NewTrackedBuffer(nil)
@@ -178,3 +204,269 @@ func TestPluginAutoIncrement(t *testing.T) {
 		log.Debug("%v", buf.String())
 	}
 }
+
+func TestPluginAutoincGetAutoIncrementOption(t *testing.T) {
+	tests := []struct {
+		query string
+		start uint64
+	}{
+		{"create table t1(a bigint not null auto_increment, b int) engine=innodb", 0},
+		{"create table t1(a bigint not null auto_increment, b int) engine=innodb auto_increment=100", 100},
+		{"create table t1(a bigint auto_increment) AUTO_INCREMENT = 7 default charset=utf8", 7},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.start, GetAutoIncrementOption(test.query), test.query)
+	}
+}
+
+func TestPluginAutoincSequenceNextValues(t *testing.T) {
+	var reserved []uint64
+	next := uint64(1)
+	reserve := func(count uint64) (uint64, error) {
+		reserved = append(reserved, count)
+		first := next
+		next += count
+		return first, nil
+	}
+
+	seq := &sequence{}
+	tests := []struct {
+		n         uint64
+		increment uint64
+		offset    uint64
+		first     uint64
+	}{
+		{3, 1, 1, 1},
+		{2, 0, 0, 4},
+		// auto_increment_increment=10, auto_increment_offset=5: 15, 25...
+		{2, 10, 5, 15},
+		// The offset is ignored if it's greater than the increment.
+		{1, 10, 11, 31},
+		{1, 1, 1, 32},
+		// The segment is extended.
+		{1000, 1, 1, 33},
+	}
+	for _, test := range tests {
+		first, err := seq.nextValues(test.n, test.increment, test.offset, reserve)
+		assert.Nil(t, err)
+		assert.Equal(t, test.first, first)
+	}
+	assert.Equal(t, []uint64{1000, 1000}, reserved)
+	assert.Equal(t, uint64(1033), seq.next)
+	assert.Equal(t, uint64(2001), seq.end)
+
+	// The segment isn't contiguous, the rest of the current one is dropped.
+	next = 5000
+	first, err := seq.nextValues(2000, 1, 1, reserve)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5000), first)
+	assert.Equal(t, uint64(7000), seq.end)
+
+	// Errors.
+	_, err = (&sequence{}).nextValues(1, 1, 1, func(count uint64) (uint64, error) {
+		return 0, errors.New("mock.reserve.error")
+	})
+	assert.NotNil(t, err)
+}
+
+func TestPluginAutoincSequenceStore(t *testing.T) {
+	db := "db1"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	scatter, fakedbs, scleanup := backend.MockScatter(log, 2)
+	defer scleanup()
+
+	autoplug := NewAutoIncrement(log, route, scatter)
+	err := autoplug.Init()
+	assert.Nil(t, err)
+	defer autoplug.Close()
+
+	tblconf := &config.TableConfig{
+		Name:          "t1",
+		ShardType:     "GLOBAL",
+		AutoIncrement: &config.AutoIncrement{Column: "id", Start: 100},
+		Partitions: []*config.PartitionConfig{
+			{Table: "t1", Backend: "backend0"},
+			{Table: "t1", Backend: "backend1"},
+		},
+	}
+	err = route.AddForTest(db, tblconf)
+	assert.Nil(t, err)
+
+	maxRs := func(max string) *sqltypes.Result {
+		return &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "max", Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte(max))}},
+		}
+	}
+	insert := func(query string) (uint64, string, error) {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		ins := node.(*sqlparser.Insert)
+		first, err := autoplug.Process(db, ins, 2, 1)
+		return first, sqlparser.String(ins), err
+	}
+
+	// The table holds the values greater than the AUTO_INCREMENT.
+	fakedbs.AddQuery(sequenceCreateDatabase, &sqltypes.Result{})
+	fakedbs.AddQuery(sequenceCreate, &sqltypes.Result{})
+	fakedbs.AddQuery("select ifnull(max(`id`), 0) from `db1`.`t1`", maxRs("200"))
+	reserveQuery := "insert into _radon.sequence(db, name, next_id) values('db1', 'table:t1', last_insert_id(1201)) on duplicate key update next_id = last_insert_id(greatest(next_id, 201) + 1000)"
+	fakedbs.AddQuery(reserveQuery, &sqltypes.Result{RowsAffected: 1, InsertID: 1201})
+	first, query, err := insert("insert into t1(b) values(1), (2)")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(201), first)
+	assert.Equal(t, "insert into t1(b, id) values (1, 201), (2, 203)", query)
+
+	// The values are handed out from the segment.
+	first, query, err = insert("insert into t1(b) values(3)")
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(205), first)
+	assert.Equal(t, "insert into t1(b, id) values (3, 205)", query)
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum(reserveQuery))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum(sequenceCreate))
+	// The first backend is pinned as the store.
+	assert.Equal(t, "backend0", scatter.SequenceBackend())
+
+	// Errors.
+	{
+		autoplug = NewAutoIncrement(log, route, scatter)
+		fakedbs.AddQueryError("select ifnull(max(`id`), 0) from `db1`.`t1`", errors.New("mock.max.error"))
+		_, _, err := insert("insert into t1(b) values(1)")
+		assert.NotNil(t, err)
+
+		// The next id is less than the values reserved.
+		fakedbs.ResetErrors()
+		fakedbs.AddQuery("select ifnull(max(`id`), 0) from `db1`.`t1`", maxRs("0"))
+		fakedbs.AddQueryPattern("insert into _radon.sequence.*", &sqltypes.Result{RowsAffected: 2, InsertID: 10})
+		_, _, err = insert("insert into t1(b) values(1)")
+		assert.NotNil(t, err)
+
+		// The pinned backend is removed, the sequences are not moved to another backend.
+		err = scatter.Remove(&config.BackendConfig{Name: "backend0"})
+		assert.Nil(t, err)
+		_, err = autoplug.(*AutoIncrement).store.backend()
+		assert.EqualError(t, err, "autoincrement.sequence.store.backend[backend0].can.not.be.found")
+	}
+}
+
+func TestPluginAutoincSequenceObject(t *testing.T) {
+	db := "db1"
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+
+	route, cleanup := router.MockNewRouter(log)
+	defer cleanup()
+	scatter, fakedbs, scleanup := backend.MockScatter(log, 2)
+	defer scleanup()
+
+	autoplug := NewAutoIncrement(log, route, scatter)
+	err := autoplug.Init()
+	assert.Nil(t, err)
+	defer autoplug.Close()
+
+	err = route.CreateDatabase(db)
+	assert.Nil(t, err)
+	err = route.CreateSequence(db, &config.SequenceConfig{Name: "s1", Start: 5, Increment: 10, Cache: 100})
+	assert.Nil(t, err)
+	tblconf := &config.TableConfig{
+		Name:          "t1",
+		ShardType:     "GLOBAL",
+		AutoIncrement: &config.AutoIncrement{Column: "id", Sequence: "db1.s1"},
+		Partitions: []*config.PartitionConfig{
+			{Table: "t1", Backend: "backend0"},
+		},
+	}
+	err = route.AddForTest(db, tblconf)
+	assert.Nil(t, err)
+
+	fakedbs.AddQuery(sequenceCreateDatabase, &sqltypes.Result{})
+	fakedbs.AddQuery(sequenceCreate, &sqltypes.Result{})
+	fakedbs.AddQuery("select ifnull(max(`id`), 0) from `db1`.`t1`", &sqltypes.Result{})
+	reserveQuery := "insert into _radon.sequence(db, name, next_id) values('db1', 'seq:s1', last_insert_id(105)) on duplicate key update next_id = last_insert_id(greatest(next_id, 5) + 100)"
+	fakedbs.AddQuery(reserveQuery, &sqltypes.Result{RowsAffected: 1, InsertID: 105})
+
+	// NEXTVAL.
+	first, err := autoplug.Nextval(db, "s1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), first)
+	first, err = autoplug.Nextval(db, "s1", 3)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(15), first)
+
+	// The auto-increment column generated from the sequence, the session increment is ignored.
+	node, err := sqlparser.Parse("insert into t1(b) values(1), (2)")
+	assert.Nil(t, err)
+	ins := node.(*sqlparser.Insert)
+	first, err = autoplug.Process(db, ins, 2, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(45), first)
+	assert.Equal(t, "insert into t1(b, id) values (1, 45), (2, 55)", sqlparser.String(ins))
+	assert.Equal(t, 1, fakedbs.GetQueryCalledNum(reserveQuery))
+
+	// Reset.
+	deleteQuery := "delete from _radon.sequence where db = 'db1' and name = 'seq:s1'"
+	fakedbs.AddQuery(deleteQuery, &sqltypes.Result{})
+	err = autoplug.Reset(db, "s1")
+	assert.Nil(t, err)
+	first, err = autoplug.Nextval(db, "s1", 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(5), first)
+	assert.Equal(t, 2, fakedbs.GetQueryCalledNum(reserveQuery))
+
+	// The sequence is seeded by the max values of the tables generated from it.
+	{
+		autoplug = NewAutoIncrement(log, route, scatter)
+		fakedbs.AddQuery("select ifnull(max(`id`), 0) from `db1`.`t1`", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "max", Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("200"))}},
+		})
+		seededQuery := "insert into _radon.sequence(db, name, next_id) values('db1', 'seq:s1', last_insert_id(301)) on duplicate key update next_id = last_insert_id(greatest(next_id, 201) + 100)"
+		fakedbs.AddQuery(seededQuery, &sqltypes.Result{RowsAffected: 1, InsertID: 301})
+		first, err = autoplug.Nextval(db, "s1", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(205), first)
+	}
+
+	// The sequence object named as a table doesn't share the values of the table's own sequence.
+	{
+		err = route.CreateSequence(db, &config.SequenceConfig{Name: "t2", Start: 1, Increment: 1, Cache: 100})
+		assert.Nil(t, err)
+		err = route.AddForTest(db, &config.TableConfig{
+			Name:          "t2",
+			ShardType:     "GLOBAL",
+			AutoIncrement: &config.AutoIncrement{Column: "id"},
+			Partitions: []*config.PartitionConfig{
+				{Table: "t2", Backend: "backend0"},
+			},
+		})
+		assert.Nil(t, err)
+		fakedbs.AddQuery("select ifnull(max(`id`), 0) from `db1`.`t2`", &sqltypes.Result{})
+		tableQuery := "insert into _radon.sequence(db, name, next_id) values('db1', 'table:t2', last_insert_id(1001)) on duplicate key update next_id = last_insert_id(greatest(next_id, 1) + 1000)"
+		fakedbs.AddQuery(tableQuery, &sqltypes.Result{RowsAffected: 1, InsertID: 1001})
+		objectQuery := "insert into _radon.sequence(db, name, next_id) values('db1', 'seq:t2', last_insert_id(101)) on duplicate key update next_id = last_insert_id(greatest(next_id, 1) + 100)"
+		fakedbs.AddQuery(objectQuery, &sqltypes.Result{RowsAffected: 1, InsertID: 101})
+
+		node, err := sqlparser.Parse("insert into t2(b) values(1)")
+		assert.Nil(t, err)
+		first, err := autoplug.Process(db, node.(*sqlparser.Insert), 1, 1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), first)
+		first, err = autoplug.Nextval(db, "t2", 1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), first)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(tableQuery))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(objectQuery))
+	}
+
+	// Errors.
+	{
+		_, err := autoplug.Nextval(db, "s2", 1)
+		assert.NotNil(t, err)
+
+		fakedbs.AddQueryError(deleteQuery, errors.New("mock.delete.error"))
+		err = autoplug.Reset(db, "s1")
+		assert.NotNil(t, err)
+	}
+}
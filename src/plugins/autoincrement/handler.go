@@ -10,6 +10,7 @@ package autoincrement
 
 import (
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 
@@ -20,15 +21,28 @@ import (
 
 var (
 	autoIncColumnType = "bigint"
+	// The table option AUTO_INCREMENT=N is dropped by the parser.
+	autoIncOptionRE = regexp.MustCompile(`(?i)\bauto_increment\s*=\s*(\d+)`)
+	// The default of the column generated from the sequence.
+	defaultNextvalRE = regexp.MustCompile("(?i)^nextval\\(([\\w`.]+)\\)$")
 )
 
 type AutoIncrementHandler interface {
 	Init() error
-	Process(database string, ins *sqlparser.Insert) error
+	// Process returns the first auto-increment value generated, 0 if none.
+	// The increment and offset are the auto_increment_increment and auto_increment_offset
+	// of the session, 0 means the default 1.
+	Process(database string, ins *sqlparser.Insert, increment, offset uint64) (uint64, error)
+	// Nextval returns the first of the n values generated from the sequence object.
+	Nextval(database, name string, n uint64) (uint64, error)
+	// Reset used to remove the values of the sequence, it starts over.
+	Reset(database, name string) error
 	Close() error
 }
 
 // GetAutoIncrement -- used to get config AutoIncrement from 'create table' DDL sqlnode.
+// The column "DEFAULT 'nextval(seq)' AUTO_INCREMENT" is generated from the sequence 'seq',
+// the default is removed from the column.
 func GetAutoIncrement(node *sqlparser.DDL) (*config.AutoIncrement, error) {
 	switch node.Action {
 	case sqlparser.CreateTableStr:
@@ -37,9 +51,16 @@ func GetAutoIncrement(node *sqlparser.DDL) (*config.AutoIncrement, error) {
 				if !strings.EqualFold(col.Type.Type, autoIncColumnType) {
 					return nil, fmt.Errorf("autoincrement.column.type[%v].must.be[%s]", col.Type.Type, autoIncColumnType)
 				} else {
-					return &config.AutoIncrement{
+					autoinc := &config.AutoIncrement{
 						Column: col.Name.String(),
-					}, nil
+					}
+					if def := col.Type.Default; def != nil && def.Type == sqlparser.StrVal {
+						if matches := defaultNextvalRE.FindStringSubmatch(string(def.Val)); matches != nil {
+							autoinc.Sequence = strings.Replace(matches[1], "`", "", -1)
+							col.Type.Default = nil
+						}
+					}
+					return autoinc, nil
 				}
 			}
 		}
@@ -47,24 +68,49 @@ func GetAutoIncrement(node *sqlparser.DDL) (*config.AutoIncrement, error) {
 	return nil, nil
 }
 
-func modifyForAutoinc(ins *sqlparser.Insert, autoinc *config.AutoIncrement, seq uint64) {
+// GetAutoIncrementOption returns the value of the table option 'AUTO_INCREMENT=N' in
+// the 'create table' query, 0 if not set.
+func GetAutoIncrementOption(query string) uint64 {
+	matches := autoIncOptionRE.FindStringSubmatch(query)
+	if matches == nil {
+		return 0
+	}
+	start, err := strconv.ParseUint(matches[1], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return start
+}
+
+// autoincRows returns the number of the rows which need the auto-increment values,
+// 0 if the insert has the autoinc column.
+func autoincRows(ins *sqlparser.Insert, autoinc *config.AutoIncrement) int {
 	col := sqlparser.NewColIdent(autoinc.Column)
 
 	// Insert has autoinc column.
 	for _, column := range ins.Columns {
 		if col.Equal(column) {
-			return
+			return 0
 		}
 	}
+	rows, ok := ins.Rows.(sqlparser.Values)
+	if !ok {
+		return 0
+	}
+	return len(rows)
+}
 
-	// Insert does not has autoinc column
+// modifyForAutoinc used to append the autoinc column and the values first, first+increment...
+// to the rows, the autoincRows must be checked before.
+func modifyForAutoinc(ins *sqlparser.Insert, autoinc *config.AutoIncrement, first uint64, increment uint64) {
 	// 1. append column info to the end.
-	ins.Columns = append(ins.Columns, col)
+	ins.Columns = append(ins.Columns, sqlparser.NewColIdent(autoinc.Column))
 
 	// 2. append vals to each row's end.
+	seq := first
 	rows := ins.Rows.(sqlparser.Values)
 	for i := range rows {
-		seq++
 		rows[i] = append(rows[i], sqlparser.NewIntVal([]byte(strconv.FormatUint(seq, 10))))
+		seq += increment
 	}
 }
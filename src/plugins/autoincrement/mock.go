@@ -0,0 +1,29 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package autoincrement
+
+import (
+	"fakedb"
+
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	// MockSequenceNextID is the next id returned by the mock store.
+	MockSequenceNextID uint64 = 1 << 20
+)
+
+// MockInitSequence used to mock the querys of the sequence store.
+func MockInitSequence(fakedbs *fakedb.DB) {
+	fakedbs.AddQuery(sequenceCreateDatabase, &sqltypes.Result{})
+	fakedbs.AddQuery(sequenceCreate, &sqltypes.Result{})
+	fakedbs.AddQueryPattern("select ifnull\\(max\\(.*", &sqltypes.Result{})
+	fakedbs.AddQueryPattern("insert into "+sequenceTable+".*", &sqltypes.Result{RowsAffected: 1, InsertID: MockSequenceNextID})
+	fakedbs.AddQueryPattern("delete from "+sequenceTable+".*", &sqltypes.Result{})
+}
@@ -0,0 +1,238 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package autoincrement
+
+import (
+	"fmt"
+	"sync"
+
+	"backend"
+	"config"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// segmentSize is the number of the values reserved from the store at once.
+	segmentSize = 1000
+
+	// sequenceDatabase is the database owned by radon on the backend.
+	sequenceDatabase       = "_radon"
+	sequenceTable          = sequenceDatabase + ".sequence"
+	sequenceCreateDatabase = "create database if not exists " + sequenceDatabase
+	sequenceCreate         = "create table if not exists " + sequenceTable + "(" +
+		"db varchar(64) not null, name varchar(80) not null, next_id bigint unsigned not null, " +
+		"primary key(db, name)) engine=innodb"
+
+	// The tables' own sequences and the sequence objects are named in their own namespaces
+	// in the store, a sequence object named as a table doesn't share the table's values.
+	tableSequencePrefix  = "table:"
+	objectSequencePrefix = "seq:"
+)
+
+// tableSequence returns the name of the table's own sequence.
+func tableSequence(table string) string {
+	return tableSequencePrefix + table
+}
+
+// objectSequence returns the name of the sequence object.
+func objectSequence(name string) string {
+	return objectSequencePrefix + name
+}
+
+// sequence is the segment [next, end) reserved from the store, the values in it are
+// handed out without accessing the store.
+type sequence struct {
+	mu   sync.Mutex
+	next uint64
+	end  uint64
+	// cache is the number of the values reserved at once, 0 means the segmentSize.
+	cache uint64
+	// min is the lower bound of the values, it's seeded when the sequence is first used.
+	min    uint64
+	seeded bool
+}
+
+// nextValues returns the first of the n values first, first+increment..., which
+// are congruent to the offset modulo the increment like MySQL.
+// The reserve is used to reserve at least the count values from the store.
+func (seq *sequence) nextValues(n, increment, offset uint64, reserve func(count uint64) (uint64, error)) (uint64, error) {
+	if increment == 0 {
+		increment = 1
+	}
+	// The offset is ignored if it's greater than the increment, the same as MySQL.
+	if offset == 0 || offset > increment {
+		offset = 1
+	}
+
+	// The aligned first value is less than next+increment, so n*increment values are enough.
+	need := n * increment
+	if seq.end-seq.next < need {
+		count := seq.cache
+		if count == 0 {
+			count = segmentSize
+		}
+		if count < need {
+			count = need
+		}
+		first, err := reserve(count)
+		if err != nil {
+			return 0, err
+		}
+		if first != seq.end {
+			seq.next = first
+		}
+		seq.end = first + count
+	}
+
+	first := offset
+	if seq.next > offset {
+		first = offset + (seq.next-offset+increment-1)/increment*increment
+	}
+	seq.next = first + (n-1)*increment + 1
+	return first, nil
+}
+
+// store used to reserve the segments of the sequences in the table on the backend pinned in the
+// backends config, the table is shared by all the radon peers, so the segments never overlap and
+// survive the restarts.
+type store struct {
+	log     *xlog.Log
+	mu      sync.Mutex
+	created bool
+	scatter *backend.Scatter
+}
+
+func newStore(log *xlog.Log, scatter *backend.Scatter) *store {
+	return &store{
+		log:     log,
+		scatter: scatter,
+	}
+}
+
+// execute used to execute the query on the backend.
+func (s *store) execute(backend string, query string) (*sqltypes.Result, error) {
+	txn, err := s.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	return txn.ExecuteOnThisBackend(backend, query)
+}
+
+// backend returns the backend of the store, the table is created if not exists.
+// The first backend is pinned if there's no one pinned, the sequences are not moved
+// to another backend as the backends are added or renamed.
+func (s *store) backend() (string, error) {
+	log := s.log
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	backends := s.scatter.Backends()
+	backend := s.scatter.SequenceBackend()
+	if backend == "" {
+		if len(backends) == 0 {
+			return "", errors.New("autoincrement.sequence.store.has.no.backends")
+		}
+		var err error
+		if backend, err = s.scatter.PinSequenceBackend(backends[0]); err != nil {
+			log.Error("autoincrement.pin.sequence.store.backend[%s].error:%+v", backends[0], err)
+			return "", err
+		}
+	}
+	found := false
+	for _, name := range backends {
+		if name == backend {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return "", errors.Errorf("autoincrement.sequence.store.backend[%s].can.not.be.found", backend)
+	}
+
+	if !s.created {
+		for _, query := range []string{sequenceCreateDatabase, sequenceCreate} {
+			if _, err := s.execute(backend, query); err != nil {
+				log.Error("autoincrement.create.sequence.table.on[%s].error:%+v", backend, err)
+				return "", err
+			}
+		}
+		s.created = true
+	}
+	return backend, nil
+}
+
+// reserve used to reserve the count values of the sequence not less than the min,
+// returns the first one. The next id is set by the LAST_INSERT_ID(expr), so it's
+// returned to us by the insert id atomically.
+func (s *store) reserve(database, name string, count, min uint64) (uint64, error) {
+	log := s.log
+	backend, err := s.backend()
+	if err != nil {
+		return 0, err
+	}
+
+	if min == 0 {
+		min = 1
+	}
+	query := fmt.Sprintf("insert into %s(db, name, next_id) values(%s, %s, last_insert_id(%d)) on duplicate key update next_id = last_insert_id(greatest(next_id, %d) + %d)",
+		sequenceTable, quote(database), quote(name), min+count, min, count)
+	qr, err := s.execute(backend, query)
+	if err != nil {
+		log.Error("autoincrement.reserve.sequence[%s.%s].on[%s].error:%+v", database, name, backend, err)
+		return 0, err
+	}
+	if qr.InsertID < count+min {
+		return 0, errors.Errorf("autoincrement.sequence[%s.%s].reserved.invalid.next.id[%d]", database, name, qr.InsertID)
+	}
+	return qr.InsertID - count, nil
+}
+
+// remove used to remove the sequence from the store, it starts over when it's used again.
+func (s *store) remove(database, name string) error {
+	backend, err := s.backend()
+	if err != nil {
+		return err
+	}
+	query := fmt.Sprintf("delete from %s where db = %s and name = %s", sequenceTable, quote(database), quote(name))
+	_, err = s.execute(backend, query)
+	return err
+}
+
+// seed returns the max of the column in the partitions plus one.
+func (s *store) seed(database string, column string, partitions []*config.PartitionConfig) (uint64, error) {
+	var max uint64
+	for _, part := range partitions {
+		query := fmt.Sprintf("select ifnull(max(`%s`), 0) from `%s`.`%s`", column, database, part.Table)
+		qr, err := s.execute(part.Backend, query)
+		if err != nil {
+			return 0, err
+		}
+		if len(qr.Rows) == 0 || len(qr.Rows[0]) == 0 {
+			continue
+		}
+		v, err := qr.Rows[0][0].ParseUint64()
+		if err != nil {
+			// Negative values.
+			continue
+		}
+		if v+1 > max {
+			max = v + 1
+		}
+	}
+	return max, nil
+}
+
+func quote(s string) string {
+	return sqlparser.String(sqlparser.NewStrVal([]byte(s)))
+}
@@ -47,7 +47,7 @@ func (plugin *Plugin) Init() error {
 	config := plugin.conf
 
 	// Register AutoIncrement plug.
-	autoincPlug := autoincrement.NewAutoIncrement(log, router)
+	autoincPlug := autoincrement.NewAutoIncrement(log, router, scatter)
 	if err := autoincPlug.Init(); err != nil {
 		return err
 	}
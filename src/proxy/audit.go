@@ -11,7 +11,8 @@ package proxy
 import (
 	"time"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
@@ -17,7 +17,8 @@ import (
 	"strconv"
 	"strings"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqldb"
 )
 
@@ -12,7 +12,8 @@ import (
 	"fmt"
 	"strings"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
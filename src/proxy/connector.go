@@ -14,5 +14,5 @@ import (
 
 // isConnectorFilter -- used to check the query is JDBC/Connector set.
 func (spanner *Spanner) isConnectorFilter(query string) bool {
-	return strings.HasPrefix(query, "/*") || strings.HasPrefix(query, "SET NAMES")
+	return strings.HasPrefix(query, "/*")
 }
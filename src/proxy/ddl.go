@@ -12,10 +12,10 @@ import (
 	"fmt"
 	"strings"
 
+	"forks/go-mysqlstack/driver"
 	"plugins/autoincrement"
 	"router"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
@@ -114,6 +114,16 @@ func tryGetShardKey(ddl *sqlparser.DDL) (string, error) {
 	return "", fmt.Errorf("The unique/primary constraint shoule be defined or add 'PARTITION BY HASH' to mandatory indication")
 }
 
+// splitQualifiedName returns the database and name of the '[db.]name', the database is the
+// default if it's not qualified.
+func splitQualifiedName(database, name string) (string, string) {
+	name = strings.Replace(name, "`", "", -1)
+	if idx := strings.Index(name, "."); idx >= 0 {
+		return name[:idx], name[idx+1:]
+	}
+	return database, name
+}
+
 func checkDatabaseExists(database string, router *router.Router) bool {
 	tblList := router.Tables()
 	_, ok := tblList[database]
@@ -242,6 +252,16 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		if err != nil {
 			return nil, err
 		}
+		if autoinc != nil {
+			autoinc.Start = autoincrement.GetAutoIncrementOption(query)
+			if autoinc.Sequence != "" {
+				seqDB, seqName := splitQualifiedName(database, autoinc.Sequence)
+				if _, err := route.SequenceConfig(seqDB, seqName); err != nil {
+					return nil, err
+				}
+				autoinc.Sequence = fmt.Sprintf("%s.%s", seqDB, seqName)
+			}
+		}
 		extra := &router.Extra{
 			AutoIncrement: autoinc,
 		}
@@ -254,19 +274,22 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 			}
 
 			assignedBackends := []string{ddl.BackendName}
-			if err := route.CreateTable(database, table, shardKey, tableType, assignedBackends, extra); err != nil {
+			if err := route.StageTable(database, table, shardKey, tableType, assignedBackends, extra); err != nil {
 				return nil, err
 			}
 		} else {
-			if err := route.CreateTable(database, table, shardKey, tableType, backends, extra); err != nil {
+			if err := route.StageTable(database, table, shardKey, tableType, backends, extra); err != nil {
 				return nil, err
 			}
 		}
 
 		r, err := spanner.ExecuteDDL(session, database, sqlparser.String(ddl), node)
 		if err != nil {
-			// Try to drop table.
-			route.DropTable(database, table)
+			// The table is only staged in router, discard it.
+			route.DiscardTable(database, table)
+			return nil, err
+		}
+		if err := route.CommitTable(database, table); err != nil {
 			return nil, err
 		}
 		return r, nil
@@ -325,6 +348,16 @@ func (spanner *Spanner) handleDDL(session *driver.Session, query string, node *s
 		if !checkTableExists(database, table, route) {
 			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, table)
 		}
+		// The ALTER TABLE on the HASH table is executed with the shadow tables in the online mode.
+		if spanner.sessions.getDDLOnline(session) && isOnlineDDL(ddl) {
+			if tconf, err := route.TableConfig(database, table); err == nil && tconf.ShardType == "HASH" {
+				return spanner.ExecuteDDLOnline(session, database, query, node, spanner.sessions.getDDLAsync(session))
+			}
+		}
+		// The long time DDL returns the job id at once in the async mode.
+		if spanner.sessions.getDDLAsync(session) {
+			return spanner.ExecuteDDLAsync(session, database, query, node)
+		}
 		// Execute.
 		r, err := spanner.ExecuteDDL(session, database, query, node)
 		if err != nil {
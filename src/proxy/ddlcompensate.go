@@ -0,0 +1,214 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// The CREATE TABLE, ALTER TABLE ADD COLUMN and ALTER TABLE RENAME are compensable, they're checked
+// on every partition before executing, and the partitions done are rolled back if the DDL
+// failed on the others, so the table isn't left in mixed shape.
+
+// isCompensableDDL returns true if the partitions done of the DDL can be rolled back.
+// The 'create table if not exists' isn't, the partitions may exist before.
+func isCompensableDDL(node sqlparser.Statement) bool {
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok {
+		return false
+	}
+	switch ddl.Action {
+	case sqlparser.CreateTableStr:
+		return !ddl.IfNotExists
+	case sqlparser.AlterAddColumnStr, sqlparser.RenameStr:
+		return true
+	}
+	return false
+}
+
+// quoteTable returns the `db`.`table` of the table name in the partition query.
+func quoteTable(database string, table sqlparser.TableName) string {
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	return fmt.Sprintf("`%s`.`%s`", database, table.Name.String())
+}
+
+// tableSchemaName returns the database and name of the table in the partition query.
+func tableSchemaName(database string, table sqlparser.TableName) (string, string) {
+	if !table.Qualifier.IsEmpty() {
+		database = table.Qualifier.String()
+	}
+	return database, table.Name.String()
+}
+
+// parseCompensableDDL returns the DDL of the partition query, nil if it isn't compensable.
+func parseCompensableDDL(query string) *sqlparser.DDL {
+	node, err := sqlparser.Parse(query)
+	if err != nil || !isCompensableDDL(node) {
+		return nil
+	}
+	return node.(*sqlparser.DDL)
+}
+
+// inverseDDLQuery returns the query which rolls back the partition query.
+func inverseDDLQuery(database string, query string) (string, error) {
+	ddl := parseCompensableDDL(query)
+	if ddl == nil {
+		return "", errors.Errorf("unsupported: rollback.ddl[%s]", query)
+	}
+	switch ddl.Action {
+	case sqlparser.CreateTableStr:
+		return fmt.Sprintf("drop table if exists %s", quoteTable(database, ddl.Table)), nil
+	case sqlparser.AlterAddColumnStr:
+		var drops []string
+		for _, col := range ddl.TableSpec.Columns {
+			drops = append(drops, fmt.Sprintf("drop column `%s`", col.Name.String()))
+		}
+		return fmt.Sprintf("alter table %s %s", quoteTable(database, ddl.Table), strings.Join(drops, ", ")), nil
+	default:
+		return fmt.Sprintf("alter table %s rename %s", quoteTable(database, ddl.NewName), quoteTable(database, ddl.Table)), nil
+	}
+}
+
+// checkDDLQuery returns the query which finds the conflicts of the partition query on the backend,
+// such as the table to create or the column to add exists. Empty if there's nothing to check.
+func checkDDLQuery(database string, query string) string {
+	ddl := parseCompensableDDL(query)
+	if ddl == nil {
+		return ""
+	}
+	switch ddl.Action {
+	case sqlparser.CreateTableStr:
+		db, table := tableSchemaName(database, ddl.Table)
+		return fmt.Sprintf("select table_name from information_schema.tables where %s", infoSchemaFilter(db, table))
+	case sqlparser.AlterAddColumnStr:
+		var cols []string
+		for _, col := range ddl.TableSpec.Columns {
+			cols = append(cols, sqlLiteral(sqltypes.NewVarChar(col.Name.String())))
+		}
+		db, table := tableSchemaName(database, ddl.Table)
+		return fmt.Sprintf("select column_name from information_schema.columns where %s and column_name in (%s)", infoSchemaFilter(db, table), strings.Join(cols, ", "))
+	default:
+		db, table := tableSchemaName(database, ddl.NewName)
+		return fmt.Sprintf("select table_name from information_schema.tables where %s", infoSchemaFilter(db, table))
+	}
+}
+
+// prevalidateDDL used to check the partition querys of the compensable DDL on every backend
+// before executing, it fails if any partition conflicts. The partitions of the online DDL are
+// checked by prevalidateOnlineDDL too.
+func (spanner *Spanner) prevalidateDDL(database string, node sqlparser.Statement, querys []xcontext.QueryTuple, online bool) error {
+	if online {
+		if err := spanner.prevalidateOnlineDDL(database, querys); err != nil {
+			return err
+		}
+	}
+	if !isCompensableDDL(node) {
+		return nil
+	}
+
+	var checks []xcontext.QueryTuple
+	for _, qt := range querys {
+		if check := checkDDLQuery(database, qt.Query); check != "" {
+			checks = append(checks, xcontext.QueryTuple{Query: check, Backend: qt.Backend, Range: qt.Range})
+		}
+	}
+	if len(checks) == 0 {
+		return nil
+	}
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.QueryTimeout)
+
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = checks
+	qr, err := txn.Execute(req)
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) > 0 {
+		name := qr.Rows[0][0].String()
+		if node.(*sqlparser.DDL).Action == sqlparser.AlterAddColumnStr {
+			return sqldb.NewSQLError1(1060, "42S21", "Duplicate column name '%s'", name)
+		}
+		return sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, name)
+	}
+	return nil
+}
+
+// rollbackDDLJob used to roll back the partitions done of the failed job, the partitions
+// rolled back are marked as rolledback.
+func (spanner *Spanner) rollbackDDLJob(id uint64) error {
+	log := spanner.log
+	jobs := spanner.ddlJobs
+
+	job, err := jobs.Get(id)
+	if err != nil {
+		return err
+	}
+	// The inverse query to the partition query.
+	inverses := make(map[string]string)
+	var querys []xcontext.QueryTuple
+	for _, part := range jobs.donePartitions(id) {
+		inverse, err := inverseDDLQuery(job.Database, part.Query)
+		if err != nil {
+			return err
+		}
+		inverses[part.Backend+inverse] = part.Query
+		querys = append(querys, xcontext.QueryTuple{Query: inverse, Backend: part.Backend, Range: part.Range})
+	}
+	if len(querys) == 0 {
+		return nil
+	}
+	log.Warning("spanner.ddl.job[%d:%s].rollback.partitions:%d", id, job.Query, len(querys))
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.DDLTimeout)
+
+	// The rollback fails if the partitions rolled back can't be flushed.
+	var mu sync.Mutex
+	var flushErr error
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = querys
+	req.Trace = func(backend string, query string, latency time.Duration, rows int) {
+		if err := jobs.partitionRolledBack(id, backend, inverses[backend+query]); err != nil {
+			mu.Lock()
+			if flushErr == nil {
+				flushErr = err
+			}
+			mu.Unlock()
+		}
+	}
+	if _, err := txn.Execute(req); err != nil {
+		log.Error("spanner.ddl.job[%d:%s].rollback.error:%+v", id, job.Query, err)
+		return err
+	}
+	return flushErr
+}
@@ -0,0 +1,224 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyDDLInverseQuery(t *testing.T) {
+	tests := []struct {
+		query   string
+		inverse string
+		check   string
+	}{
+		{
+			query:   "create table `test`.`t1_0000` (id int, b int) engine=InnoDB",
+			inverse: "drop table if exists `test`.`t1_0000`",
+			check:   "select table_name from information_schema.tables where table_schema='test' and table_name='t1_0000'",
+		},
+		{
+			query:   "alter table `test`.`t1_0000` add column (c int, d varchar(10))",
+			inverse: "alter table `test`.`t1_0000` drop column `c`, drop column `d`",
+			check:   "select column_name from information_schema.columns where table_schema='test' and table_name='t1_0000' and column_name in ('c', 'd')",
+		},
+		{
+			query:   "alter table `test`.`t1_0000` rename `test`.`t2_0000`",
+			inverse: "alter table `test`.`t2_0000` rename `test`.`t1_0000`",
+			check:   "select table_name from information_schema.tables where table_schema='test' and table_name='t2_0000'",
+		},
+		{
+			query:   "alter table t1_0000 rename to t2_0000",
+			inverse: "alter table `db`.`t2_0000` rename `db`.`t1_0000`",
+			check:   "select table_name from information_schema.tables where table_schema='db' and table_name='t2_0000'",
+		},
+	}
+	for _, test := range tests {
+		inverse, err := inverseDDLQuery("db", test.query)
+		assert.Nil(t, err)
+		assert.Equal(t, test.inverse, inverse)
+		assert.Equal(t, test.check, checkDDLQuery("db", test.query))
+	}
+
+	// Not compensable.
+	_, err := inverseDDLQuery("db", "alter table t1 engine=tokudb")
+	assert.NotNil(t, err)
+	_, err = inverseDDLQuery("db", "create table if not exists `test`.`t1_0000` (id int, b int) engine=InnoDB")
+	assert.NotNil(t, err)
+	assert.Equal(t, "", checkDDLQuery("db", "create table if not exists `test`.`t1_0000` (id int, b int) engine=InnoDB"))
+	assert.Equal(t, "", checkDDLQuery("db", "alter table t1 drop column c"))
+}
+
+func TestProxyDDLCompensate(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("alter .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The last job and the states of its partitions.
+	lastJob := func() (string, string, map[string]int) {
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		row := qr.Rows[len(qr.Rows)-1]
+		qr, err = client.FetchAll(fmt.Sprintf("show ddl job %s", row[0].String()), -1)
+		assert.Nil(t, err)
+		states := make(map[string]int)
+		for _, part := range qr.Rows {
+			states[part[3].String()]++
+		}
+		return row[4].String(), row[8].String(), states
+	}
+
+	// The create table failed on one partition, the others are dropped.
+	{
+		fakedbs.AddQueryErrorPattern("create table `test`.`t1_0002` .*", errors.New("mock.create.error"))
+		_, err := client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+		assert.NotNil(t, err)
+		state, jobErr, states := lastJob()
+		assert.Equal(t, ddlStateRolledBack, state)
+		assert.True(t, strings.Contains(jobErr, "mock.create.error"), jobErr)
+		assert.Equal(t, 1, states[ddlStateFailed])
+		assert.Equal(t, 0, states[ddlStateDone])
+		assert.True(t, states[ddlStateRolledBack] > 0)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("drop table if exists `test`.`t1_0000`"))
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("drop table if exists `test`.`t1_0002`"))
+		assert.False(t, checkTableExists("test", "t1", route))
+
+		fakedbs.ResetPatternErrors()
+		_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		state, _, _ = lastJob()
+		assert.Equal(t, ddlStateDone, state)
+	}
+
+	// The create table if not exists failed on one partition, the partitions may exist before and aren't dropped.
+	{
+		fakedbs.AddQueryErrorPattern("create table if not exists `test`.`t4_0002` .*", errors.New("mock.create.error"))
+		_, err := client.FetchAll("create table if not exists t4(id int, b int) partition by hash(id)", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("drop table if exists `test`.`t4_0000`"))
+		assert.False(t, checkTableExists("test", "t4", route))
+		fakedbs.ResetPatternErrors()
+	}
+
+	// The add column failed on one partition, the column is dropped on the others.
+	{
+		fakedbs.AddQueryError("alter table `test`.`t1_0005` add column (c int)", errors.New("mock.alter.error"))
+		_, err := client.FetchAll("alter table t1 add column (c int)", -1)
+		assert.NotNil(t, err)
+		state, _, states := lastJob()
+		assert.Equal(t, ddlStateRolledBack, state)
+		assert.Equal(t, 1, states[ddlStateFailed])
+		assert.Equal(t, 0, states[ddlStateDone])
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("alter table `test`.`t1_0000` drop column `c`"))
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("alter table `test`.`t1_0005` drop column `c`"))
+
+		// The rolled back job can't be resumed.
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll(fmt.Sprintf("resume ddl job %s", qr.Rows[len(qr.Rows)-1][0].String()), -1)
+		assert.NotNil(t, err)
+		fakedbs.ResetErrors()
+	}
+
+	// The rename failed on one partition, the others are renamed back and the router isn't changed.
+	{
+		fakedbs.AddQueryErrorPattern("alter table `test`.`t1_0003` rename .*", errors.New("mock.rename.error"))
+		_, err := client.FetchAll("alter table t1 rename t2", -1)
+		assert.NotNil(t, err)
+		state, _, _ := lastJob()
+		assert.Equal(t, ddlStateRolledBack, state)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("alter table `test`.`t2_0000` rename `test`.`t1_0000`"))
+		assert.True(t, checkTableExists("test", "t1", route))
+		assert.False(t, checkTableExists("test", "t2", route))
+		fakedbs.ResetPatternErrors()
+	}
+
+	// The rollback failed, the job is failed and can't be resumed.
+	{
+		fakedbs.AddQueryError("alter table `test`.`t1_0005` add column (d int)", errors.New("mock.alter.error"))
+		fakedbs.AddQueryError("alter table `test`.`t1_0000` drop column `d`", errors.New("mock.rollback.error"))
+		_, err := client.FetchAll("alter table t1 add column (d int)", -1)
+		assert.NotNil(t, err)
+		state, jobErr, states := lastJob()
+		assert.Equal(t, ddlStateFailed, state)
+		assert.True(t, strings.Contains(jobErr, "mock.rollback.error"), jobErr)
+		assert.True(t, states[ddlStateDone] > 0)
+
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll(fmt.Sprintf("resume ddl job %s", qr.Rows[len(qr.Rows)-1][0].String()), -1)
+		assert.NotNil(t, err)
+		fakedbs.ResetErrors()
+	}
+
+	// The conflicts are found before executing.
+	{
+		exists := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "table_name", Type: querypb.Type_VARCHAR},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t3_0001"))},
+			},
+		}
+		fakedbs.AddQuery("select table_name from information_schema.tables where table_schema='test' and table_name='t3_0001'", exists)
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		jobs := len(qr.Rows)
+
+		_, err = client.FetchAll("create table t3(id int, b int) partition by hash(id)", -1)
+		assert.EqualError(t, err, "Table 't3_0001' already exists (errno 1050) (sqlstate 42S01)")
+		_, err = client.FetchAll("alter table t1 rename t3", -1)
+		assert.EqualError(t, err, "Table 't3_0001' already exists (errno 1050) (sqlstate 42S01)")
+		assert.False(t, checkTableExists("test", "t3", route))
+		assert.True(t, checkTableExists("test", "t1", route))
+
+		fakedbs.AddQuery("select column_name from information_schema.columns where table_schema='test' and table_name='t1_0001' and column_name in ('b')", exists)
+		_, err = client.FetchAll("alter table t1 add column (b int)", -1)
+		assert.EqualError(t, err, "Duplicate column name 't3_0001' (errno 1060) (sqlstate 42S21)")
+
+		// No job created.
+		qr, err = client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, jobs, len(qr.Rows))
+	}
+}
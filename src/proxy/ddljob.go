@@ -0,0 +1,689 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend"
+	"config"
+	"forks/go-mysqlstack/driver"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// ddlJobsFileSuffix is the suffix of the file where the jobs are stored, the file is beside
+	// the metadir but not in it, the metadir is synced between the peers and the jobs are not.
+	ddlJobsFileSuffix = "-ddl-jobs.json"
+	// maxFinishedDDLJobs is the number of the done, cancelled and rolled back jobs kept.
+	maxFinishedDDLJobs = 100
+
+	ddlStatePending   = "pending"
+	ddlStateRunning   = "running"
+	ddlStateDone      = "done"
+	ddlStateFailed    = "failed"
+	ddlStateCancelled = "cancelled"
+	// ddlStateRolledBack is the state of the failed job whose partitions done are rolled back.
+	ddlStateRolledBack = "rolledback"
+)
+
+// DDLPartition is the DDL executed on one partition.
+type DDLPartition struct {
+	Backend string `json:"backend"`
+	Range   string `json:"range,omitempty"`
+	Query   string `json:"query"`
+	State   string `json:"state"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DDLJob is the DDL fanned out to the partitions, the state of every partition
+// is recorded, so the job can be resumed from the partitions not done.
+type DDLJob struct {
+	ID         uint64          `json:"id"`
+	User       string          `json:"user"`
+	Database   string          `json:"database"`
+	Query      string          `json:"query"`
+	State      string          `json:"state"`
+	Error      string          `json:"error,omitempty"`
+	CreateTime time.Time       `json:"create-time"`
+	UpdateTime time.Time       `json:"update-time"`
+	Partitions []*DDLPartition `json:"partitions"`
+	// Online is true if the partitions are altered by the online DDL.
+	Online bool `json:"online,omitempty"`
+
+	// txn is the transaction of the running job, it's aborted when the job is cancelled.
+	txn       *backend.Txn
+	cancelled bool
+	done      chan struct{}
+}
+
+// ddlJobsMeta is the content of the jobs file.
+type ddlJobsMeta struct {
+	NextID uint64    `json:"next-id"`
+	Jobs   []*DDLJob `json:"jobs"`
+}
+
+// DDLJobs tuple.
+type DDLJobs struct {
+	log    *xlog.Log
+	file   string
+	mu     sync.Mutex
+	nextID uint64
+	jobs   []*DDLJob
+}
+
+// NewDDLJobs creates the DDLJobs stored beside the metadir, the jobs are owned by this peer.
+func NewDDLJobs(log *xlog.Log, metadir string) *DDLJobs {
+	return &DDLJobs{
+		log:    log,
+		file:   ddlJobsPath(metadir),
+		nextID: 1,
+	}
+}
+
+// ddlJobsPath returns the path of the jobs file of the metadir.
+func ddlJobsPath(metadir string) string {
+	return path.Clean(metadir) + ddlJobsFileSuffix
+}
+
+// Init used to load the jobs from the file.
+// The jobs running when radon stopped are marked as failed, they can be resumed.
+func (j *DDLJobs) Init() error {
+	log := j.log
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	data, err := ioutil.ReadFile(j.file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.WithStack(err)
+	}
+	meta := &ddlJobsMeta{}
+	if err := json.Unmarshal(data, meta); err != nil {
+		return errors.WithStack(err)
+	}
+
+	interrupted := false
+	for _, job := range meta.Jobs {
+		if job.State == ddlStateRunning || job.State == ddlStatePending {
+			log.Warning("ddl.jobs.job[%d:%s].was.interrupted", job.ID, job.Query)
+			for _, part := range job.Partitions {
+				if part.State == ddlStateRunning {
+					part.State = ddlStatePending
+				}
+			}
+			job.State, job.Error = ddlStateFailed, "interrupted.by.restart"
+			interrupted = true
+		}
+	}
+	if meta.NextID > j.nextID {
+		j.nextID = meta.NextID
+	}
+	j.jobs = meta.Jobs
+	if interrupted {
+		return j.flush()
+	}
+	return nil
+}
+
+// flush used to write the jobs to the file, the caller must hold the lock.
+func (j *DDLJobs) flush() error {
+	meta := &ddlJobsMeta{
+		NextID: j.nextID,
+		Jobs:   j.jobs,
+	}
+	if err := config.WriteConfig(j.file, meta); err != nil {
+		j.log.Error("ddl.jobs.flush.to.file[%s].error:%+v", j.file, err)
+		return err
+	}
+	return nil
+}
+
+// finished returns true if the job can't be resumed any more.
+func (job *DDLJob) finished() bool {
+	switch job.State {
+	case ddlStateDone, ddlStateCancelled, ddlStateRolledBack:
+		return true
+	}
+	return false
+}
+
+// prune used to remove the oldest finished jobs, the caller must hold the lock.
+func (j *DDLJobs) prune() {
+	finished := 0
+	for _, job := range j.jobs {
+		if job.finished() {
+			finished++
+		}
+	}
+	jobs := j.jobs[:0]
+	for _, job := range j.jobs {
+		if finished > maxFinishedDDLJobs && job.finished() {
+			finished--
+			continue
+		}
+		jobs = append(jobs, job)
+	}
+	j.jobs = jobs
+}
+
+// Create used to create a pending job with the querys of the partitions.
+func (j *DDLJobs) Create(user string, database string, query string, querys []xcontext.QueryTuple, online bool) (*DDLJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	now := time.Now()
+	job := &DDLJob{
+		ID:         j.nextID,
+		User:       user,
+		Database:   database,
+		Query:      query,
+		State:      ddlStatePending,
+		CreateTime: now,
+		UpdateTime: now,
+		Online:     online,
+	}
+	for _, qt := range querys {
+		job.Partitions = append(job.Partitions, &DDLPartition{
+			Backend: qt.Backend,
+			Range:   qt.Range,
+			Query:   qt.Query,
+			State:   ddlStatePending,
+		})
+	}
+	j.nextID++
+	j.jobs = append(j.jobs, job)
+	j.prune()
+	if err := j.flush(); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// Get returns the job by id.
+func (j *DDLJobs) Get(id uint64) (*DDLJob, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.get(id)
+}
+
+func (j *DDLJobs) get(id uint64) (*DDLJob, error) {
+	for _, job := range j.jobs {
+		if job.ID == id {
+			return job, nil
+		}
+	}
+	return nil, sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "Unknown DDL job id: %d", id)
+}
+
+// Snapshot returns the copies of the jobs.
+func (j *DDLJobs) Snapshot() []DDLJob {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	jobs := make([]DDLJob, 0, len(j.jobs))
+	for _, job := range j.jobs {
+		cp := DDLJob{
+			ID:         job.ID,
+			User:       job.User,
+			Database:   job.Database,
+			Query:      job.Query,
+			State:      job.State,
+			Error:      job.Error,
+			CreateTime: job.CreateTime,
+			UpdateTime: job.UpdateTime,
+			Online:     job.Online,
+		}
+		for _, part := range job.Partitions {
+			p := *part
+			cp.Partitions = append(cp.Partitions, &p)
+		}
+		jobs = append(jobs, cp)
+	}
+	return jobs
+}
+
+// start used to mark the job running, returns the querys of the partitions not done.
+// The new job starts from pending, the failed and cancelled jobs are resumed.
+func (j *DDLJobs) start(id uint64, txn *backend.Txn, resume bool) ([]xcontext.QueryTuple, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !resume && job.State != ddlStatePending,
+		resume && job.State != ddlStateFailed && job.State != ddlStateCancelled:
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "DDL job %d is %s", id, job.State)
+	}
+
+	// The job isn't started if the state can't be flushed.
+	state, errMsg, updateTime := job.State, job.Error, job.UpdateTime
+	prevParts := make([]DDLPartition, len(job.Partitions))
+	for i, part := range job.Partitions {
+		prevParts[i] = *part
+	}
+
+	var querys []xcontext.QueryTuple
+	for _, part := range job.Partitions {
+		if part.State == ddlStateDone {
+			continue
+		}
+		part.State, part.Error = ddlStateRunning, ""
+		querys = append(querys, xcontext.QueryTuple{Query: part.Query, Backend: part.Backend, Range: part.Range})
+	}
+	job.State, job.Error = ddlStateRunning, ""
+	job.UpdateTime = time.Now()
+	if err := j.flush(); err != nil {
+		job.State, job.Error, job.UpdateTime = state, errMsg, updateTime
+		for i, part := range job.Partitions {
+			*part = prevParts[i]
+		}
+		return nil, err
+	}
+	job.txn, job.cancelled = txn, false
+	job.done = make(chan struct{})
+	return querys, nil
+}
+
+// partitionDone used to mark the partition done, the state isn't flushed, it's flushed with
+// the next state of the job, such as by the save of the online job or the finish.
+func (j *DDLJobs) partitionDone(id uint64, backend string, query string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return
+	}
+	for _, part := range job.Partitions {
+		if part.Backend == backend && part.Query == query {
+			part.State = ddlStateDone
+			break
+		}
+	}
+	job.UpdateTime = time.Now()
+}
+
+// save used to flush the jobs to the file.
+func (j *DDLJobs) save() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.flush()
+}
+
+// partitionFailed used to mark the partition failed, returns the error if it can't be flushed.
+func (j *DDLJobs) partitionFailed(id uint64, backend string, query string, execErr error) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return err
+	}
+	for _, part := range job.Partitions {
+		if part.Backend == backend && part.Query == query {
+			part.State, part.Error = ddlStateFailed, execErr.Error()
+			break
+		}
+	}
+	job.UpdateTime = time.Now()
+	return j.flush()
+}
+
+// isCancelled returns true if the job is being cancelled.
+func (j *DDLJobs) isCancelled(id uint64) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return false
+	}
+	return job.cancelled
+}
+
+// donePartitions returns the copies of the partitions done of the job.
+func (j *DDLJobs) donePartitions(id uint64) []DDLPartition {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return nil
+	}
+	var parts []DDLPartition
+	for _, part := range job.Partitions {
+		if part.State == ddlStateDone {
+			parts = append(parts, *part)
+		}
+	}
+	return parts
+}
+
+// partitionRolledBack used to mark the partition done rolled back, returns the error if it
+// can't be flushed.
+func (j *DDLJobs) partitionRolledBack(id uint64, backend string, query string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return err
+	}
+	for _, part := range job.Partitions {
+		if part.Backend == backend && part.Query == query && part.State == ddlStateDone {
+			part.State = ddlStateRolledBack
+			break
+		}
+	}
+	job.UpdateTime = time.Now()
+	return j.flush()
+}
+
+// finish used to record the result of the job.
+// The partitions on the same backend are executed in order and it stops at the first error,
+// so the first running partition of the backend failed and the others are still pending.
+// The online job marks the partitions one by one, the partitions left are still pending.
+// The failed job is rolledback if all the partitions done were rolled back.
+// It returns the error if the result can't be flushed, the result is still kept in memory.
+func (j *DDLJobs) finish(id uint64, execErr error, rolledBack bool) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	job, err := j.get(id)
+	if err != nil {
+		return err
+	}
+
+	// The partitions marked failed by partitionFailed.
+	failed := make(map[string]bool)
+	for _, part := range job.Partitions {
+		if part.State == ddlStateFailed {
+			failed[part.Backend] = true
+		}
+	}
+	for _, part := range job.Partitions {
+		if part.State != ddlStateRunning {
+			continue
+		}
+		switch {
+		case execErr == nil:
+			part.State = ddlStateDone
+		case job.cancelled || job.Online || failed[part.Backend]:
+			part.State = ddlStatePending
+		default:
+			part.State, part.Error = ddlStateFailed, execErr.Error()
+			failed[part.Backend] = true
+		}
+	}
+
+	switch {
+	case execErr == nil:
+		job.State = ddlStateDone
+	case rolledBack:
+		job.State, job.Error = ddlStateRolledBack, execErr.Error()
+	case job.cancelled:
+		job.State, job.Error = ddlStateCancelled, "cancelled"
+	default:
+		job.State, job.Error = ddlStateFailed, execErr.Error()
+	}
+	job.UpdateTime = time.Now()
+	job.txn = nil
+	close(job.done)
+	j.prune()
+	return j.flush()
+}
+
+// Cancel used to cancel the job, the running partitions are killed.
+func (j *DDLJobs) Cancel(id uint64) error {
+	j.mu.Lock()
+	job, err := j.get(id)
+	if err != nil {
+		j.mu.Unlock()
+		return err
+	}
+
+	switch job.State {
+	case ddlStateDone, ddlStateCancelled, ddlStateRolledBack:
+		j.mu.Unlock()
+		return sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "DDL job %d is %s", id, job.State)
+	case ddlStateRunning:
+		txn, done := job.txn, job.done
+		job.cancelled = true
+		j.mu.Unlock()
+		if txn != nil {
+			txn.Abort()
+		}
+		// Wait for the partitions killed.
+		<-done
+		return nil
+	default:
+		job.State, job.Error = ddlStateCancelled, "cancelled"
+		job.UpdateTime = time.Now()
+		defer j.mu.Unlock()
+		return j.flush()
+	}
+}
+
+var (
+	// SHOW DDL JOBS, SHOW DDL JOB id, CANCEL DDL JOB id and RESUME DDL JOB id.
+	ddlJobStmtRE = regexp.MustCompile(`(?is)^(show|cancel|resume)\s+ddl\s+(jobs|job\s+(\d+))$`)
+)
+
+// isDDLJobStmt returns true if the query is the statement of the DDL jobs.
+func isDDLJobStmt(query string) bool {
+	return ddlJobStmtRE.MatchString(query)
+}
+
+// ddlJobIDResult returns the result with the job id.
+func ddlJobIDResult(id uint64) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Job_id", Type: querypb.Type_UINT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", id)))},
+		},
+		RowsAffected: 1,
+	}
+}
+
+// handleDDLJobStmt used to handle the statements of the DDL jobs.
+// The super user can access all the jobs, the others can access the jobs created by themselves.
+func (spanner *Spanner) handleDDLJobStmt(session *driver.Session, query string) (*sqltypes.Result, error) {
+	matches := ddlJobStmtRE.FindStringSubmatch(query)
+	action, all := strings.ToLower(matches[1]), matches[3] == ""
+	if all && action != "show" {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, fmt.Sprintf("You have an error in your SQL syntax; near '%s'", matches[2]))
+	}
+
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	super := privilegePlug.IsSuperPriv(session.User())
+	if all {
+		return spanner.handleShowDDLJobs(session, super)
+	}
+
+	id, err := strconv.ParseUint(matches[3], 10, 64)
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	job, err := spanner.ddlJobs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if !super && job.User != session.User() {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; you are not the owner of the DDL job %d", id)
+	}
+
+	switch action {
+	case "show":
+		return spanner.handleShowDDLJob(id)
+	case "cancel":
+		if spanner.ReadOnly() {
+			return nil, sqldb.NewSQLError(sqldb.ER_OPTION_PREVENTS_STATEMENT, "--read-only")
+		}
+		if err := spanner.ddlJobs.Cancel(id); err != nil {
+			return nil, err
+		}
+		return &sqltypes.Result{}, nil
+	default:
+		if spanner.ReadOnly() {
+			return nil, sqldb.NewSQLError(sqldb.ER_OPTION_PREVENTS_STATEMENT, "--read-only")
+		}
+		return spanner.resumeDDLJob(session, job)
+	}
+}
+
+// handleShowDDLJobs used to handle the 'SHOW DDL JOBS'.
+func (spanner *Spanner) handleShowDDLJobs(session *driver.Session, super bool) (*sqltypes.Result, error) {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Job_id", Type: querypb.Type_UINT64},
+		{Name: "User", Type: querypb.Type_VARCHAR},
+		{Name: "Database", Type: querypb.Type_VARCHAR},
+		{Name: "Query", Type: querypb.Type_VARCHAR},
+		{Name: "State", Type: querypb.Type_VARCHAR},
+		{Name: "Partitions", Type: querypb.Type_INT64},
+		{Name: "Done", Type: querypb.Type_INT64},
+		{Name: "Failed", Type: querypb.Type_INT64},
+		{Name: "Error", Type: querypb.Type_VARCHAR},
+		{Name: "Create_time", Type: querypb.Type_DATETIME},
+		{Name: "Update_time", Type: querypb.Type_DATETIME},
+	}
+	for _, job := range spanner.ddlJobs.Snapshot() {
+		if !super && job.User != session.User() {
+			continue
+		}
+		var done, failed int
+		for _, part := range job.Partitions {
+			switch part.State {
+			case ddlStateDone:
+				done++
+			case ddlStateFailed:
+				failed++
+			}
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(fmt.Sprintf("%d", job.ID))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.User)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.Database)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.Query)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.State)),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(job.Partitions)))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", done))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", failed))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(job.Error)),
+			sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte(job.CreateTime.Format("2006-01-02 15:04:05"))),
+			sqltypes.MakeTrusted(querypb.Type_DATETIME, []byte(job.UpdateTime.Format("2006-01-02 15:04:05"))),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// handleShowDDLJob used to handle the 'SHOW DDL JOB id', returns the partitions of the job.
+func (spanner *Spanner) handleShowDDLJob(id uint64) (*sqltypes.Result, error) {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+		{Name: "Range", Type: querypb.Type_VARCHAR},
+		{Name: "Query", Type: querypb.Type_VARCHAR},
+		{Name: "State", Type: querypb.Type_VARCHAR},
+		{Name: "Error", Type: querypb.Type_VARCHAR},
+	}
+	for _, job := range spanner.ddlJobs.Snapshot() {
+		if job.ID != id {
+			continue
+		}
+		for _, part := range job.Partitions {
+			row := []sqltypes.Value{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(part.Backend)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(part.Range)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(part.Query)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(part.State)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(part.Error)),
+			}
+			qr.Rows = append(qr.Rows, row)
+		}
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// resumeDDLJob used to execute the partitions not done of the failed or cancelled job,
+// it's executed in the background if the session is in the async DDL mode.
+func (spanner *Spanner) resumeDDLJob(session *driver.Session, job *DDLJob) (*sqltypes.Result, error) {
+	log := spanner.log
+	node, err := sqlparser.Parse(job.Query)
+	if err != nil {
+		return nil, err
+	}
+	// The router isn't updated if the compensable DDL failed, it must be executed again.
+	if isCompensableDDL(node) && !job.Online {
+		return nil, errors.Errorf("unsupported: resume.compensable.ddl.job[%d]", job.ID)
+	}
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if err := privilegePlug.Check(job.Database, session.User(), node); err != nil {
+		return nil, err
+	}
+
+	// The online job alters the partitions left with the shadow tables.
+	if job.Online {
+		if spanner.sessions.getDDLAsync(session) {
+			go func() {
+				if _, err := spanner.runOnlineDDLJob(job.ID, true); err != nil {
+					log.Error("spanner.ddl.online.job[%d:%s].resume.error:%+v", job.ID, job.Query, err)
+				}
+			}()
+			return ddlJobIDResult(job.ID), nil
+		}
+		return spanner.runOnlineDDLJob(job.ID, true)
+	}
+
+	txn, err := spanner.createTransaction(session, spanner.conf.Proxy.DDLTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if spanner.sessions.getDDLAsync(session) {
+		go func() {
+			defer txn.Finish()
+			if _, err := spanner.runDDLJob(txn, job.ID, node, true); err != nil {
+				log.Error("spanner.ddl.job[%d:%s].resume.error:%+v", job.ID, job.Query, err)
+			}
+		}()
+		return ddlJobIDResult(job.ID), nil
+	}
+
+	defer txn.Finish()
+	spanner.sessions.TxnBinding(session, txn, node, job.Query)
+	defer spanner.sessions.TxnUnBinding(session)
+	return spanner.runDDLJob(txn, job.ID, node, true)
+}
@@ -0,0 +1,275 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyDDLJobs(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The state of the job.
+	jobState := func(id string) string {
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		for _, row := range qr.Rows {
+			if row[0].String() == id {
+				return row[4].String()
+			}
+		}
+		return ""
+	}
+	waitJob := func(id string, state string) {
+		for i := 0; i < 100; i++ {
+			if jobState(id) == state {
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		assert.Equal(t, state, jobState(id))
+	}
+
+	// The job of the create table.
+	{
+		_, err := client.FetchAll("create table t1(id int, b int) global", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "[1 mock test done 5 5 0 ]", fmt.Sprintf("%v", append(qr.Rows[0][:3], qr.Rows[0][4:9]...)))
+
+		qr, err = client.FetchAll("show ddl job 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Rows))
+		for _, row := range qr.Rows {
+			assert.Equal(t, "done", row[3].String())
+		}
+	}
+
+	// The job failed on one partition and resumed.
+	{
+		fakedbs.AddQueryError("alter table `test`.`t1` engine=tokudb", errors.New("mock.alter.error"))
+		_, err := client.FetchAll("alter table t1 engine=tokudb", -1)
+		assert.NotNil(t, err)
+		assert.Equal(t, "failed", jobState("2"))
+		qr, err := client.FetchAll("show ddl job 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Rows))
+		for _, row := range qr.Rows {
+			assert.Equal(t, "failed", row[3].String())
+		}
+
+		fakedbs.ResetErrors()
+		fakedbs.AddQuery("alter table `test`.`t1` engine=tokudb", &sqltypes.Result{})
+		_, err = client.FetchAll("resume ddl job 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "done", jobState("2"))
+
+		// The done job can't be resumed or cancelled.
+		_, err = client.FetchAll("resume ddl job 2", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("cancel ddl job 2", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The async DDL.
+	{
+		_, err := client.FetchAll("set radon_ddl_async='ON'", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("alter table t1 engine=tokudb", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "3", qr.Rows[0][0].String())
+		waitJob("3", "done")
+	}
+
+	// Cancel the running job and resume it.
+	{
+		_, err := client.FetchAll("create table t2(id int) single", -1)
+		assert.Nil(t, err)
+		fakedbs.AddQueryDelay("alter table `test`.`t2` engine=innodb", &sqltypes.Result{}, 100000000)
+		qr, err := client.FetchAll("alter table t2 engine=innodb", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "5", qr.Rows[0][0].String())
+		waitJob("5", "running")
+
+		_, err = client.FetchAll("cancel ddl job 5", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "cancelled", jobState("5"))
+		qr, err = client.FetchAll("show ddl job 5", -1)
+		assert.Nil(t, err)
+		for _, row := range qr.Rows {
+			assert.Equal(t, "pending", row[3].String())
+		}
+
+		fakedbs.AddQuery("alter table `test`.`t2` engine=innodb", &sqltypes.Result{})
+		qr, err = client.FetchAll("resume ddl job 5", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "5", qr.Rows[0][0].String())
+		waitJob("5", "done")
+
+		_, err = client.FetchAll("set radon_ddl_async=false", -1)
+		assert.Nil(t, err)
+	}
+
+	// The jobs are loaded after restart.
+	{
+		jobs := NewDDLJobs(log, proxy.conf.Proxy.MetaDir)
+		err := jobs.Init()
+		assert.Nil(t, err)
+		snapshot := jobs.Snapshot()
+		assert.Equal(t, 5, len(snapshot))
+		for _, job := range snapshot {
+			assert.Equal(t, "done", job.State)
+		}
+		assert.Equal(t, uint64(6), jobs.nextID)
+
+		// The jobs are owned by the peer, they aren't synced with the metadir.
+		meta, err := proxy.syncer.MetaJSON()
+		assert.Nil(t, err)
+		for name := range meta.Metas {
+			assert.False(t, strings.Contains(name, "ddl"), name)
+		}
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"show ddl job 100",
+			"cancel ddl job 100",
+			"resume ddl job 100",
+			"cancel ddl jobs",
+			"show ddl jobs 1",
+			"set radon_ddl_async='xx'",
+			"set radon_ddl_async=1",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+	}
+}
+
+func TestProxyDDLJobsInterrupted(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	jobs := NewDDLJobs(log, proxy.conf.Proxy.MetaDir)
+	err := jobs.Init()
+	assert.Nil(t, err)
+	job, err := jobs.Create("mock", "test", "alter table t1 engine=innodb", nil, false)
+	assert.Nil(t, err)
+	job.Partitions = []*DDLPartition{
+		{Backend: "backend0", Query: "q0", State: ddlStateDone},
+		{Backend: "backend1", Query: "q1", State: ddlStateRunning},
+	}
+	job.State = ddlStateRunning
+	jobs.flush()
+
+	// The running job is failed after restart.
+	jobs = NewDDLJobs(log, proxy.conf.Proxy.MetaDir)
+	err = jobs.Init()
+	assert.Nil(t, err)
+	snapshot := jobs.Snapshot()
+	assert.Equal(t, ddlStateFailed, snapshot[len(snapshot)-1].State)
+	assert.Equal(t, ddlStateDone, snapshot[len(snapshot)-1].Partitions[0].State)
+	assert.Equal(t, ddlStatePending, snapshot[len(snapshot)-1].Partitions[1].State)
+
+	// The pending job is cancelled.
+	job, err = jobs.Create("mock", "test", "alter table t1 engine=innodb", nil, false)
+	assert.Nil(t, err)
+	err = jobs.Cancel(job.ID)
+	assert.Nil(t, err)
+	job, err = jobs.Get(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, ddlStateCancelled, job.State)
+}
+
+func TestProxyDDLJobsFlushError(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	_, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+
+	jobs := NewDDLJobs(log, proxy.conf.Proxy.MetaDir)
+	err := jobs.Init()
+	assert.Nil(t, err)
+	job, err := jobs.Create("mock", "test", "alter table t1 engine=innodb", nil, false)
+	assert.Nil(t, err)
+	job.Partitions = []*DDLPartition{
+		{Backend: "backend0", Query: "q0", State: ddlStatePending},
+	}
+
+	// The file can't be written.
+	jobs.file = path.Join(proxy.conf.Proxy.MetaDir, "nonexistent", "jobs.json")
+
+	// The job isn't started.
+	_, err = jobs.start(job.ID, nil, false)
+	assert.NotNil(t, err)
+	assert.Equal(t, ddlStatePending, job.State)
+	assert.Equal(t, ddlStatePending, job.Partitions[0].State)
+
+	// The job is started but the partitions and result can't be flushed.
+	jobs.file = ddlJobsPath(proxy.conf.Proxy.MetaDir)
+	_, err = jobs.start(job.ID, nil, false)
+	assert.Nil(t, err)
+	jobs.file = path.Join(proxy.conf.Proxy.MetaDir, "nonexistent", "jobs.json")
+	jobs.partitionDone(job.ID, "backend0", "q0")
+	assert.Equal(t, ddlStateDone, job.Partitions[0].State)
+	err = jobs.save()
+	assert.NotNil(t, err)
+	err = jobs.finish(job.ID, nil, false)
+	assert.NotNil(t, err)
+	assert.Equal(t, ddlStateDone, job.State)
+
+	// The result is flushed with the next job.
+	jobs.file = ddlJobsPath(proxy.conf.Proxy.MetaDir)
+	_, err = jobs.Create("mock", "test", "alter table t1 engine=innodb", nil, false)
+	assert.Nil(t, err)
+	jobs = NewDDLJobs(log, proxy.conf.Proxy.MetaDir)
+	err = jobs.Init()
+	assert.Nil(t, err)
+	got, err := jobs.Get(job.ID)
+	assert.Nil(t, err)
+	assert.Equal(t, ddlStateDone, got.State)
+	assert.Equal(t, ddlStateDone, got.Partitions[0].State)
+}
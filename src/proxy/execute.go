@@ -9,27 +9,35 @@
 package proxy
 
 import (
+	"time"
+
+	"backend"
 	"executor"
+	"forks/go-mysqlstack/driver"
 	"optimizer"
 	"planner"
 	"xcontext"
 
 	"github.com/pkg/errors"
-	"github.com/xelabs/go-mysqlstack/driver"
+	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 // ExecuteMultiStmtsInTxn used to execute multiple statements in the transaction.
 func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	return spanner.executeMultiStmtsInTxn(session, database, query, node, nil)
+}
+
+func (spanner *Spanner) executeMultiStmtsInTxn(session *driver.Session, database string, query string, node sqlparser.Statement, opts *execOpts) (*sqltypes.Result, error) {
 	log := spanner.log
-	router := spanner.router
 	sessions := spanner.sessions
 	txSession := sessions.getTxnSession(session)
 
 	sessions.MultiStmtTxnBinding(session, nil, node, query)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -46,9 +54,12 @@ func (spanner *Spanner) ExecuteMultiStmtsInTxn(session *driver.Session, database
 
 // ExecuteSingleStmtTxnTwoPC used to execute single statement transaction with 2pc commit.
 func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	return spanner.executeSingleStmtTxnTwoPC(session, database, query, node, nil)
+}
+
+func (spanner *Spanner) executeSingleStmtTxnTwoPC(session *driver.Session, database string, query string, node sqlparser.Statement, opts *execOpts) (*sqltypes.Result, error) {
 	log := spanner.log
 	conf := spanner.conf
-	router := spanner.router
 	scatter := spanner.scatter
 	sessions := spanner.sessions
 
@@ -64,6 +75,10 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	txn.SetTimeout(conf.Proxy.QueryTimeout)
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetGroupConcatMaxLen(sessions.getGroupConcatMaxLen(session))
+	txn.SetSessionVars(sessions.getBackendVars(session))
 
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
@@ -76,7 +91,7 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 	}
 
 	// Transaction execute.
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -101,7 +116,7 @@ func (spanner *Spanner) ExecuteSingleStmtTxnTwoPC(session *driver.Session, datab
 // ExecuteNormal used to execute non-2pc querys to shards with QueryTimeout limits.
 func (spanner *Spanner) ExecuteNormal(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	timeout := spanner.conf.Proxy.QueryTimeout
-	return spanner.executeWithTimeout(session, database, query, node, timeout)
+	return spanner.executeWithTimeout(session, database, query, node, timeout, nil)
 }
 
 // ExecuteDDL used to execute ddl querys to the shards with DDLTimeout limits, used for create/drop index long time operation.
@@ -110,42 +125,148 @@ func (spanner *Spanner) ExecuteDDL(session *driver.Session, database string, que
 	timeout := spanner.conf.Proxy.DDLTimeout
 
 	txSession := spanner.sessions.getTxnSession(session)
-	if spanner.isTwoPC() && txSession.transaction != nil {
+	if txSession.transaction != nil {
 		return nil, errors.Errorf("in.multiStmtTrans.unsupported.DDL:%v.", query)
 	}
 
-	return spanner.executeWithTimeout(session, database, query, node, timeout)
+	querys, err := spanner.buildDDLQuerys(database, query, node)
+	if err != nil {
+		return nil, err
+	}
+	if querys == nil {
+		return spanner.executeWithTimeout(session, database, query, node, timeout, nil)
+	}
+	if err := spanner.prevalidateDDL(database, node, querys, false); err != nil {
+		return nil, err
+	}
+
+	txn, err := spanner.createTransaction(session, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+
+	job, err := spanner.ddlJobs.Create(session.User(), database, query, querys, false)
+	if err != nil {
+		return nil, err
+	}
+	spanner.sessions.TxnBinding(session, txn, node, query)
+	defer spanner.sessions.TxnUnBinding(session)
+	return spanner.runDDLJob(txn, job.ID, node, false)
+}
+
+// ExecuteDDLAsync used to create the DDL job and execute it in the background, returns the job id.
+func (spanner *Spanner) ExecuteDDLAsync(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	log := spanner.log
+	log.Info("spanner.execute.ddl.async.query:%s", query)
+
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession.transaction != nil {
+		return nil, errors.Errorf("in.multiStmtTrans.unsupported.DDL:%v.", query)
+	}
+
+	querys, err := spanner.buildDDLQuerys(database, query, node)
+	if err != nil {
+		return nil, err
+	}
+	if querys == nil {
+		return nil, errors.Errorf("unsupported: async.ddl[%s]", query)
+	}
+	if err := spanner.prevalidateDDL(database, node, querys, false); err != nil {
+		return nil, err
+	}
+
+	txn, err := spanner.createTransaction(session, spanner.conf.Proxy.DDLTimeout)
+	if err != nil {
+		return nil, err
+	}
+	job, err := spanner.ddlJobs.Create(session.User(), database, query, querys, false)
+	if err != nil {
+		txn.Finish()
+		return nil, err
+	}
+	go func() {
+		defer txn.Finish()
+		if _, err := spanner.runDDLJob(txn, job.ID, node, false); err != nil {
+			log.Error("spanner.ddl.job[%d:%s].error:%+v", job.ID, query, err)
+		}
+	}()
+	return ddlJobIDResult(job.ID), nil
+}
+
+// buildDDLQuerys returns the querys of the partitions, nil if the DDL isn't executed on the partitions.
+func (spanner *Spanner) buildDDLQuerys(database string, query string, node sqlparser.Statement) ([]xcontext.QueryTuple, error) {
+	plans, err := spanner.buildPlanTree(database, query, node, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(plans.Plans()) != 1 {
+		return nil, nil
+	}
+	plan, ok := plans.Plans()[0].(*planner.DDLPlan)
+	if !ok || plan.ReqMode != xcontext.ReqNormal || len(plan.Querys) == 0 {
+		return nil, nil
+	}
+	return plan.Querys, nil
+}
+
+// runDDLJob used to execute the partitions not done of the job, the state of the partitions
+// is recorded as they're done. The partitions done of the failed compensable DDL are rolled back.
+func (spanner *Spanner) runDDLJob(txn *backend.Txn, id uint64, node sqlparser.Statement, resume bool) (*sqltypes.Result, error) {
+	log := spanner.log
+	jobs := spanner.ddlJobs
+	querys, err := jobs.start(id, txn, resume)
+	if err != nil {
+		return nil, err
+	}
+
+	// The partitions done are flushed with the result of the job, not one by one.
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = querys
+	req.Trace = func(backend string, query string, latency time.Duration, rows int) {
+		jobs.partitionDone(id, backend, query)
+	}
+	qr, err := txn.Execute(req)
+	rolledBack := false
+	if err != nil && !resume && isCompensableDDL(node) {
+		if x := spanner.rollbackDDLJob(id); x != nil {
+			err = errors.Errorf("%v, rollback.error:%v", err, x)
+		} else {
+			rolledBack = true
+		}
+	}
+	// The DDL done on the backends isn't failed by the flush, the state is flushed with the next job.
+	if x := jobs.finish(id, err, rolledBack); x != nil {
+		log.Error("spanner.ddl.job[%d].finish.error:%+v", id, x)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return qr, nil
 }
 
 // ExecuteNormal used to execute non-2pc querys to shards with timeout limits.
 // timeout:
-//    0x01. if timeout <= 0, no limits.
-//    0x02. if timeout > 0, the query will be interrupted if the timeout(in millisecond) is exceeded.
-func (spanner *Spanner) executeWithTimeout(session *driver.Session, database string, query string, node sqlparser.Statement, timeout int) (*sqltypes.Result, error) {
+//
+//	0x01. if timeout <= 0, no limits.
+//	0x02. if timeout > 0, the query will be interrupted if the timeout(in millisecond) is exceeded.
+func (spanner *Spanner) executeWithTimeout(session *driver.Session, database string, query string, node sqlparser.Statement, timeout int, opts *execOpts) (*sqltypes.Result, error) {
 	log := spanner.log
-	conf := spanner.conf
-	router := spanner.router
-	scatter := spanner.scatter
 	sessions := spanner.sessions
 
 	// transaction.
-	txn, err := scatter.CreateTransaction()
+	txn, err := spanner.createTransaction(session, timeout)
 	if err != nil {
-		log.Error("spanner.txn.create.error:[%v]", err)
 		return nil, err
 	}
 	defer txn.Finish()
 
-	// txn limits.
-	txn.SetTimeout(timeout)
-	txn.SetMaxResult(conf.Proxy.MaxResultSize)
-	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
-
 	// binding.
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
-	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	plans, err := spanner.buildPlanTree(database, query, node, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -157,17 +278,144 @@ func (spanner *Spanner) executeWithTimeout(session *driver.Session, database str
 	return qr, nil
 }
 
+// createTransaction used to create the non-2pc txn with the timeout and the limits of the session.
+func (spanner *Spanner) createTransaction(session *driver.Session, timeout int) (*backend.Txn, error) {
+	log := spanner.log
+	conf := spanner.conf
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		log.Error("spanner.txn.create.error:[%v]", err)
+		return nil, err
+	}
+
+	// txn limits.
+	txn.SetTimeout(timeout)
+	txn.SetMaxResult(conf.Proxy.MaxResultSize)
+	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetGroupConcatMaxLen(spanner.sessions.getGroupConcatMaxLen(session))
+	txn.SetSessionVars(spanner.sessions.getBackendVars(session))
+	return txn, nil
+}
+
+// execOpts are the options of the DML execution.
+type execOpts struct {
+	// bindVars are the params of the prepared statement, which are bound into the plan by their types.
+	bindVars map[string]*querypb.BindVariable
+	// foundRows is set if the SQL_CALC_FOUND_ROWS is pushed down to the shards, it's called with
+	// the FOUND_ROWS() of each shard.
+	foundRows func(rows uint64)
+}
+
+// buildPlanTree used to build the plan tree of the query by the options.
+func (spanner *Spanner) buildPlanTree(database string, query string, node sqlparser.Statement, opts *execOpts) (*planner.PlanTree, error) {
+	if opts == nil {
+		return spanner.buildPlans(database, query, node, nil)
+	}
+	plans, err := spanner.buildPlans(database, query, node, opts.bindVars)
+	if err != nil {
+		return nil, err
+	}
+	if opts.foundRows != nil && !pushCalcFoundRows(plans, opts.foundRows) {
+		return nil, errors.Errorf("unsupported: sql_calc_found_rows.can.not.be.pushed.down")
+	}
+	return plans, nil
+}
+
+// buildSelectPlan used to build the select plan, it's replaced in the tests to count the builds.
+var buildSelectPlan = func(plan *planner.SelectPlan) error {
+	return plan.Build()
+}
+
+// buildPlans used to build the plan tree of the query, the select plan is got from the
+// plan cache if the query only differs in the values of the where clause from a cached one.
+// The args of the node are bound by the typedVars, which are the params of the prepared statement.
+func (spanner *Spanner) buildPlans(database string, query string, node sqlparser.Statement, typedVars map[string]*querypb.BindVariable) (*planner.PlanTree, error) {
+	log := spanner.log
+	router := spanner.router
+	plans := spanner.plans
+
+	sel, ok := node.(*sqlparser.Select)
+	if !ok || plans == nil {
+		if err := bindArgs(&node, typedVars); err != nil {
+			return nil, err
+		}
+		return optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	}
+
+	// The literals of the node are replaced by the bind variables, the same node is the key
+	// and the plan of the cache, and they're restored by the bindVars if it's uncacheable.
+	bindVars := make(map[string]*querypb.BindVariable)
+	text := planner.Parameterize(sel, bindVars)
+	for name, bv := range typedVars {
+		bindVars[name] = bv
+	}
+	version := router.Version()
+	entry, hit := plans.get(database, version, text)
+	if !hit {
+		plans.misses.Add(1)
+		entry = &planEntry{}
+		plan := planner.NewSelectPlan(log, database, text, sel, router)
+		if err := buildSelectPlan(plan); err == nil && plan.Cacheable() {
+			entry.plan = plan
+		}
+		plans.set(database, version, text, entry.plan)
+		if entry.plan == nil {
+			// The node is changed by the planner, it's parsed again from the text only the
+			// first time, the next ones are known as uncacheable by the cache.
+			var err error
+			if node, err = sqlparser.Parse(text); err != nil {
+				return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+			}
+		}
+	}
+	if entry.plan == nil {
+		if err := bindArgs(&node, bindVars); err != nil {
+			return nil, err
+		}
+		return optimizer.NewSimpleOptimizer(log, database, query, node, router).BuildPlanTree()
+	}
+	if hit {
+		plans.hits.Add(1)
+	}
+
+	plan, err := entry.plan.Bind(bindVars)
+	if err != nil {
+		return nil, err
+	}
+	plan.RawQuery = query
+	tree := planner.NewPlanTree()
+	tree.Add(plan)
+	return tree, nil
+}
+
+// bindArgs used to bind the args of the node by the bind variables, the node is re-parsed from
+// the generated query if the values can't be bound to the node directly.
+func bindArgs(node *sqlparser.Statement, bindVars map[string]*querypb.BindVariable) error {
+	if len(bindVars) == 0 || planner.BindLiterals(*node, bindVars) {
+		return nil
+	}
+	query, err := sqlparser.NewParsedQuery(*node).GenerateQuery(bindVars, nil)
+	if err != nil {
+		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	if *node, err = sqlparser.Parse(query); err != nil {
+		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	return nil
+}
+
 // ExecuteStreamFetch used to execute a stream fetch query.
 func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database string, query string, node sqlparser.Statement, callback func(qr *sqltypes.Result) error) error {
 	log := spanner.log
 	router := spanner.router
-	scatter := spanner.scatter
 	sessions := spanner.sessions
 
-	// transaction.
-	txn, err := scatter.CreateTransaction()
+	// transaction, the streaming isn't limited by the query timeout, such as the exports.
+	txn, err := spanner.createTransaction(session, 0)
 	if err != nil {
-		log.Error("spanner.txn.create.error:[%v]", err)
 		return err
 	}
 	defer txn.Finish()
@@ -176,29 +424,31 @@ func (spanner *Spanner) ExecuteStreamFetch(session *driver.Session, database str
 	sessions.TxnBinding(session, txn, node, query)
 	defer sessions.TxnUnBinding(session)
 
-	selectNode, ok := node.(*sqlparser.Select)
-	if !ok {
-		return errors.New("ExecuteStreamFetch.only.support.select")
-	}
-
-	plan := planner.NewSelectPlan(log, database, query, selectNode, router)
-	if err := plan.Build(); err != nil {
-		return err
-	}
-	m, ok := plan.Root.(*planner.MergeNode)
-	if !ok {
-		return errors.New("ExecuteStreamFetch.unsupport.cross-shard.join")
-	}
-	reqCtx := xcontext.NewRequestContext()
-	reqCtx.Mode = m.ReqMode
-	reqCtx.Querys = m.GetQuery()
-	reqCtx.RawQuery = plan.RawQuery
 	streamBufferSize := spanner.conf.Proxy.StreamBufferSize
-	return txn.ExecuteStreamFetch(reqCtx, callback, streamBufferSize)
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		plan := planner.NewSelectPlan(log, database, query, node, router)
+		if err := plan.Build(); err != nil {
+			return err
+		}
+		return executor.NewSelectExecutor(log, plan, txn).ExecuteStreamFetch(callback, streamBufferSize)
+	case *sqlparser.Union:
+		plan := planner.NewUnionPlan(log, database, query, node, router)
+		if err := plan.Build(); err != nil {
+			return err
+		}
+		return executor.NewUnionExecutor(log, plan, txn).ExecuteStreamFetch(callback, streamBufferSize)
+	}
+	return errors.New("ExecuteStreamFetch.only.support.select")
 }
 
 // ExecuteDML used to execute some DML querys to shards.
 func (spanner *Spanner) ExecuteDML(session *driver.Session, database string, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	return spanner.executeDML(session, database, query, node, nil)
+}
+
+// executeDML used to execute the DML whose args are bound by the bindVars.
+func (spanner *Spanner) executeDML(session *driver.Session, database string, query string, node sqlparser.Statement, opts *execOpts) (*sqltypes.Result, error) {
 	privilegePlug := spanner.plugins.PlugPrivilege()
 	if err := privilegePlug.Check(session.Schema(), session.User(), node); err != nil {
 		return nil, err
@@ -208,14 +458,27 @@ func (spanner *Spanner) ExecuteDML(session *driver.Session, database string, que
 		txSession := spanner.sessions.getTxnSession(session)
 		if spanner.IsDML(node) {
 			if txSession.transaction == nil {
-				return spanner.ExecuteSingleStmtTxnTwoPC(session, database, query, node)
+				// The NO_TWOPC hint, the DML is best-effort without the distributed transaction.
+				hints, err := planner.GetHints(node)
+				if err != nil {
+					return nil, err
+				}
+				if hints != nil && hints.NoTwoPC {
+					return spanner.executeWithTimeout(session, database, query, node, spanner.conf.Proxy.QueryTimeout, opts)
+				}
+				return spanner.executeSingleStmtTxnTwoPC(session, database, query, node, opts)
 			} else {
-				return spanner.ExecuteMultiStmtsInTxn(session, database, query, node)
+				return spanner.executeMultiStmtsInTxn(session, database, query, node, opts)
 			}
 		}
-		return spanner.ExecuteNormal(session, database, query, node)
+		return spanner.executeWithTimeout(session, database, query, node, spanner.conf.Proxy.QueryTimeout, opts)
+	}
+
+	// The DML in the local txn of the autocommit off.
+	if txSession := spanner.sessions.getTxnSession(session); txSession.transaction != nil && spanner.IsDML(node) {
+		return spanner.executeMultiStmtsInTxn(session, database, query, node, opts)
 	}
-	return spanner.ExecuteNormal(session, database, query, node)
+	return spanner.executeWithTimeout(session, database, query, node, spanner.conf.Proxy.QueryTimeout, opts)
 }
 
 // ExecuteSingle used to execute query on one shard without planner.
@@ -232,6 +495,21 @@ func (spanner *Spanner) ExecuteSingle(query string) (*sqltypes.Result, error) {
 	return txn.ExecuteSingle(query)
 }
 
+// ExecuteSingleWithSession used to execute query on one shard without planner,
+// the system variables of the session are applied to the backend connection.
+func (spanner *Spanner) ExecuteSingleWithSession(session *driver.Session, query string) (*sqltypes.Result, error) {
+	log := spanner.log
+	scatter := spanner.scatter
+	txn, err := scatter.CreateTransaction()
+	if err != nil {
+		log.Error("spanner.execute.single.txn.create.error:[%v]", err)
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetSessionVars(spanner.sessions.getBackendVars(session))
+	return txn.ExecuteSingle(query)
+}
+
 // ExecuteScatter used to execute query on all shards without planner.
 func (spanner *Spanner) ExecuteScatter(query string) (*sqltypes.Result, error) {
 	log := spanner.log
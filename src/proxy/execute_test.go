@@ -10,13 +10,16 @@ package proxy
 
 import (
 	"errors"
+	"io/ioutil"
+	"os"
 	"testing"
 
 	"fakedb"
+	"forks/go-mysqlstack/driver"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -86,6 +89,47 @@ func TestProxyExecute(t *testing.T) {
 	}
 }
 
+func TestProxyExecuteNoTwoPCHint(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryErrorPattern("xa .*", errors.New("mock.xa.error"))
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	proxy.conf.Proxy.TwopcEnable = true
+
+	// Insert with 2PC.
+	{
+		_, err = client.FetchAll("insert into test.t1 (id, b) values(1,2),(3,4)", -1)
+		assert.NotNil(t, err)
+	}
+
+	// Insert without 2PC by the hint.
+	{
+		_, err = client.FetchAll("insert /*+ NO_TWOPC */ into test.t1 (id, b) values(1,2),(3,4)", -1)
+		assert.Nil(t, err)
+	}
+
+	// Invalid hint.
+	{
+		_, err = client.FetchAll("insert /*+ RADON_SHARD() */ into test.t1 (id, b) values(1,2),(3,4)", -1)
+		assert.NotNil(t, err)
+	}
+}
+
 func TestProxyExecute2PCError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
@@ -233,14 +277,16 @@ func TestProxyExecuteSelectError(t *testing.T) {
 			"select t1.a,t2.b from test.t1, test.t2",
 		}
 		wants := []string{
-			"ExecuteStreamFetch.unsupport.cross-shard.join (errno 1105) (sqlstate HY000)",
+			"mock.stream.join.error (errno 1105) (sqlstate HY000)",
 		}
 		for i, query := range querys {
 			sql := "set @@SESSION.radon_streaming_fetch='ON'"
 			_, err := client.FetchAll(sql, -1)
 			assert.Nil(t, err)
 
-			fakedbs.AddQuery(query, fakedb.Result3)
+			// The cross-shard join is streamed, the error comes from the backend.
+			fakedbs.AddQueryPattern("select t1.a from .*", fakedb.Result3)
+			fakedbs.AddQueryErrorPattern("select t2.b from .*", errors.New("mock.stream.join.error"))
 			_, err = client.FetchAll(query, -1)
 
 			got := err.Error()
@@ -359,6 +405,96 @@ func TestProxyExecuteStreamFetch(t *testing.T) {
 	}
 }
 
+func TestProxyExecuteStreamFetchSpill(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	dir := "/tmp/radon_stream_spill_test"
+	os.RemoveAll(dir)
+	defer os.RemoveAll(dir)
+
+	rs := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{
+				Name: "id",
+				Type: querypb.Type_INT32,
+			},
+			{
+				Name: "b",
+				Type: querypb.Type_INT32,
+			},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("11")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")),
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("22")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", rs)
+	}
+
+	// create database and tables.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		querys := []string{
+			"create database test",
+			"create table test.t1(id int, b int) partition by hash(id)",
+			"create table test.t2(id int, b int) partition by hash(id)",
+		}
+		for _, query := range querys {
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+	}
+
+	// The streamed join is limited as the non-streamed, the sorts spill over the memory budget.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("set @@SESSION.radon_streaming_fetch='ON'", -1)
+		assert.Nil(t, err)
+
+		query := "select t1.id, t2.id from test.t1 join test.t2 on t1.b=t2.b"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		want := qr.RowsAffected
+
+		// The stream is interrupted, the connection is closed.
+		proxy.SetMaxResultSize(4)
+		_, err = client.FetchAll(query, -1)
+		assert.NotNil(t, err)
+
+		proxy.SetMaxQueryMemory(64)
+		proxy.conf.Proxy.SpillDir = dir
+		client, err = driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("set @@SESSION.radon_streaming_fetch='ON'", -1)
+		assert.Nil(t, err)
+		qr, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, want, qr.RowsAffected)
+
+		// The spilled files are created in the dir and removed.
+		files, err := ioutil.ReadDir(dir)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(files))
+	}
+}
+
 func TestProxyExecuteMultiStmtTxnDDLError(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
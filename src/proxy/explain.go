@@ -11,17 +11,25 @@ package proxy
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
+	"executor"
+	"forks/go-mysqlstack/driver"
 	"optimizer"
+	"planner"
 
 	"github.com/pkg/errors"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+const (
+	explainFormatJSON = "json"
+	explainFormatTree = "tree"
+)
+
 // handleExplain used to handle the EXPLAIN command.
 func (spanner *Spanner) handleExplain(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	log := spanner.log
@@ -32,12 +40,27 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 		{Name: "EXPLAIN", Type: querypb.Type_VARCHAR},
 	}
 
-	pat := `(?i)explain`
+	// EXPLAIN [ANALYZE] [FORMAT={JSON|TREE}] statement.
+	pat := `(?i)explain(\s+analyze\b)?(\s+format\s*=\s*['"]?(\w+)['"]?)?`
 	reg := regexp.MustCompile(pat)
-	idx := reg.FindStringIndex(query)
-	if len(idx) != 2 {
+	idx := reg.FindStringSubmatchIndex(query)
+	if len(idx) != 8 {
 		return nil, errors.Errorf("explain.query[%s].syntax.error", query)
 	}
+	analyze := idx[2] >= 0
+	format := explainFormatJSON
+	if idx[6] >= 0 {
+		format = strings.ToLower(query[idx[6]:idx[7]])
+	}
+	if analyze && idx[6] < 0 {
+		format = explainFormatTree
+	}
+	switch {
+	case format != explainFormatJSON && format != explainFormatTree:
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, fmt.Sprintf("unknown.explain.format.name:'%s'", format))
+	case analyze && format != explainFormatTree:
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain analyze only supports FORMAT=TREE")
+	}
 	cutQuery := query[idx[1]:]
 	subNode, err := sqlparser.Parse(cutQuery)
 	if err != nil {
@@ -61,8 +84,18 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 	case *sqlparser.Select:
 	case *sqlparser.Delete:
 	case *sqlparser.Insert:
+		if err := spanner.evalNextval(database, subNode.(*sqlparser.Insert)); err != nil {
+			return nil, err
+		}
+		var increment, offset uint64
+		if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+			increment, offset = txSession.getAutoInc()
+		}
 		autoincPlug := spanner.plugins.PlugAutoIncrement()
-		if err := autoincPlug.Process(database, subNode.(*sqlparser.Insert)); err != nil {
+		if _, err := autoincPlug.Process(database, subNode.(*sqlparser.Insert), increment, offset); err != nil {
+			return nil, err
+		}
+		if err := spanner.evalShardKey(session, database, subNode.(*sqlparser.Insert)); err != nil {
 			return nil, err
 		}
 	case *sqlparser.Update:
@@ -70,6 +103,13 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 	default:
 		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain only supports SELECT/DELETE/INSERT/UNION")
 	}
+	if analyze {
+		switch subNode.(type) {
+		case *sqlparser.Select, *sqlparser.Union:
+		default:
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "explain analyze only supports SELECT/UNION")
+		}
+	}
 
 	simOptimizer := optimizer.NewSimpleOptimizer(log, database, cutQuery, subNode, router)
 	planTree, err := simOptimizer.BuildPlanTree()
@@ -84,7 +124,18 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 	}
 
 	if len(planTree.Plans()) > 0 {
-		msg := planTree.Plans()[0].JSON()
+		plan := planTree.Plans()[0]
+		var msg string
+		switch {
+		case analyze:
+			if msg, err = spanner.analyzePlanTree(session, cutQuery, subNode, planTree); err != nil {
+				return nil, err
+			}
+		case format == explainFormatTree:
+			msg = planner.FormatTree(plan, nil)
+		default:
+			msg = plan.JSON()
+		}
 		row := []sqltypes.Value{
 			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(msg)),
 		}
@@ -93,3 +144,26 @@ func (spanner *Spanner) handleExplain(session *driver.Session, query string, nod
 	}
 	return qr, nil
 }
+
+// analyzePlanTree used to execute the plans and returns the tree of the first plan with
+// the runtime statistics, the results of the query are discarded.
+func (spanner *Spanner) analyzePlanTree(session *driver.Session, query string, node sqlparser.Statement, planTree *planner.PlanTree) (string, error) {
+	log := spanner.log
+	sessions := spanner.sessions
+
+	txn, err := spanner.createTransaction(session, spanner.conf.Proxy.QueryTimeout)
+	if err != nil {
+		return "", err
+	}
+	defer txn.Finish()
+
+	sessions.TxnBinding(session, txn, node, query)
+	defer sessions.TxnUnBinding(session)
+
+	analyzer := executor.NewAnalyzer()
+	executors := executor.NewTree(log, planTree, analyzer.Txn(txn))
+	if _, err := executors.Execute(); err != nil {
+		return "", err
+	}
+	return planner.FormatTree(planTree.Plans()[0], analyzer), nil
+}
@@ -9,10 +9,13 @@
 package proxy
 
 import (
+	"regexp"
 	"testing"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -315,3 +318,101 @@ func TestProxyExplainPrivilege(t *testing.T) {
 		assert.NotNil(t, err)
 	}
 }
+
+func TestProxyExplainAnalyze(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32, Table: "t1"},
+			{Name: "b", Type: querypb.Type_INT32, Table: "t1"},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3"))},
+		},
+	}
+	result2 := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "b", Type: querypb.Type_INT32, Table: "t2"},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQuery("select t1.id, t1.b from test.t1_0017 as t1 where t1.id = 1 order by t1.b asc", result)
+		fakedbs.AddQuery("select t2.b from test.t2_0029 as t2 where t2.id = 2 order by t2.b asc", result2)
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t2(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	// explain format=tree.
+	{
+		qr, err := client.FetchAll("explain format=tree select t1.id, t2.b from t1 join t2 on t1.b=t2.b where t1.id=1 and t2.id=2 order by t2.b limit 1", -1)
+		assert.Nil(t, err)
+		want := `-> Limit: offset=0, limit=1
+    -> Sort: t2.b ASC
+        -> Inner join (Sort Merge Join): t1.b = t2.b
+            -> Merge: 1 partitions
+                -> Query on backend2 [2278-2457): select t1.id, t1.b from test.t1_0017 as t1 where t1.id = 1 order by t1.b asc
+            -> Merge: 1 partitions
+                -> Query on backend4 [3916-4096): select t2.b from test.t2_0029 as t2 where t2.id = 2 order by t2.b asc
+`
+		got := string(qr.Rows[0][0].Raw())
+		assert.Equal(t, want, got)
+	}
+
+	// explain analyze.
+	{
+		qr, err := client.FetchAll("explain analyze select t1.id, t2.b from t1 join t2 on t1.b=t2.b where t1.id=1 and t2.id=2 order by t2.b limit 1", -1)
+		assert.Nil(t, err)
+		want := `-> Limit: offset=0, limit=1  (actual time=Xms rows=1 loops=1 peak_memory=26)
+    -> Sort: t2.b ASC  (actual time=Xms rows=3 loops=1 peak_memory=78)
+        -> Inner join (Sort Merge Join): t1.b = t2.b  (actual time=Xms rows=3 loops=1 peak_memory=78)
+            -> Merge: 1 partitions  (actual time=Xms rows=2 loops=1 peak_memory=52)
+                -> Query on backend2 [2278-2457): select t1.id, t1.b from test.t1_0017 as t1 where t1.id = 1 order by t1.b asc  (actual latency=Xms rows=2 loops=1)
+            -> Merge: 1 partitions  (actual time=Xms rows=3 loops=1 peak_memory=75)
+                -> Query on backend4 [3916-4096): select t2.b from test.t2_0029 as t2 where t2.id = 2 order by t2.b asc  (actual latency=Xms rows=3 loops=1)
+`
+		got := regexp.MustCompile(`[0-9.]+ms`).ReplaceAllString(string(qr.Rows[0][0].Raw()), "Xms")
+		assert.Equal(t, want, got)
+	}
+
+	// errors.
+	{
+		querys := []string{
+			"explain analyze delete from t1 where id=1",
+			"explain format=xml select * from t1",
+			"explain analyze format=json select * from t1",
+		}
+		wants := []string{
+			"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain analyze only supports SELECT/UNION (errno 1149) (sqlstate 42000)",
+			"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, unknown.explain.format.name:'xml' (errno 1149) (sqlstate 42000)",
+			"You have an error in your SQL syntax; check the manual that corresponds to your MySQL server version for the right syntax to use, explain analyze only supports FORMAT=TREE (errno 1149) (sqlstate 42000)",
+		}
+		for i, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Equal(t, wants[i], err.Error())
+		}
+	}
+}
@@ -0,0 +1,946 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"config"
+	"expression"
+	"forks/go-mysqlstack/driver"
+	"planner"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// infoSchemaView represents the view of the INFORMATION_SCHEMA virtualized by the proxy,
+// the rows of the partition tables are read from the backends and renamed to the logical table.
+type infoSchemaView struct {
+	// allParts is true if the rows of all the partitions are needed, otherwise the first partition's.
+	allParts bool
+	// merge used to merge the rows of the partitions into the rows of the logical table,
+	// the rows of the first partition are used if it's nil.
+	merge func(fields []*querypb.Field, table *infoSchemaTable, parts [][][]sqltypes.Value) [][]sqltypes.Value
+}
+
+// infoSchemaViews are the views answered by the partition tables, the SCHEMATA is answered
+// by the backend and filtered by the privilege.
+var infoSchemaViews = map[string]*infoSchemaView{
+	"TABLES":           {allParts: true, merge: mergeInfoSchemaTables},
+	"COLUMNS":          {},
+	"STATISTICS":       {allParts: true, merge: mergeInfoSchemaStatistics},
+	"KEY_COLUMN_USAGE": {},
+	"PARTITIONS":       {allParts: true, merge: mergeInfoSchemaPartitions},
+}
+
+// infoSchemaAggrs are the aggregate functions supported on the virtual views.
+var infoSchemaAggrs = map[string]planner.AggrType{
+	"count": planner.AggrTypeCount,
+	"sum":   planner.AggrTypeSum,
+	"min":   planner.AggrTypeMin,
+	"max":   planner.AggrTypeMax,
+	"avg":   planner.AggrTypeAvg,
+}
+
+// infoSchemaTable is the logical table in the virtual view.
+type infoSchemaTable struct {
+	database string
+	conf     *config.TableConfig
+	// parts are the partitions read from the backends, only the first copy of the global table
+	// is read since the copies are the same.
+	parts []*config.PartitionConfig
+}
+
+// handleSelectInformationschema used to handle the SELECT on the INFORMATION_SCHEMA.
+// The TABLES, COLUMNS, STATISTICS, KEY_COLUMN_USAGE, PARTITIONS and SCHEMATA are virtualized:
+// the logical tables are shown instead of the partition tables, with the rows and the sizes
+// summed over the partitions, and the SELECT is evaluated on the virtual rows by the proxy.
+// The rows of the schemas and tables not in the router, such as the system databases, the views
+// and the tables created on the backend directly, are read from a backend and merged.
+// The other views and the queries only on the system databases are answered by a backend.
+// If the query is:
+// > select table_name, table_rows from information_schema.tables where table_schema='test'
+// The partitions of the tables in test are read from every backend:
+// > select * from information_schema.tables where table_schema='test' and table_name in ('t1_0000', ...)
+func (spanner *Spanner) handleSelectInformationschema(session *driver.Session, query string, tbl string, node *sqlparser.Select) (*sqltypes.Result, error) {
+	view := strings.ToUpper(tbl)
+	spec, ok := infoSchemaViews[view]
+	if len(node.From) != 1 || (!ok && view != "SCHEMATA") {
+		return spanner.ExecuteSingle(query)
+	}
+	if node.Where != nil {
+		replaceDatabaseFunc(node.Where.Expr, session.Schema())
+	}
+
+	var err error
+	var qr *sqltypes.Result
+	if view == "SCHEMATA" {
+		if qr, err = spanner.infoSchemaSchemata(session); err != nil {
+			return nil, err
+		}
+	} else {
+		schemas, names := infoSchemaPushdown(node.Where)
+		if len(schemas) > 0 {
+			system := true
+			for schema := range schemas {
+				system = system && spanner.router.IsSystemDB(schema)
+			}
+			if system {
+				return spanner.ExecuteSingle(query)
+			}
+		}
+		tables, err := spanner.infoSchemaTables(session, schemas, names)
+		if err != nil {
+			return nil, err
+		}
+		if qr, err = spanner.infoSchemaRows(view, spec, tables); err != nil {
+			return nil, err
+		}
+		others, err := spanner.infoSchemaBackendRows(session, view, schemas, names)
+		if err != nil {
+			return nil, err
+		}
+		qr.Rows = append(qr.Rows, others.Rows...)
+	}
+
+	alias := tbl
+	if aliasTableExpr := node.From[0].(*sqlparser.AliasedTableExpr); !aliasTableExpr.As.IsEmpty() {
+		alias = aliasTableExpr.As.String()
+	}
+	return spanner.selectOnResult(node, qr, alias)
+}
+
+// replaceDatabaseFunc used to replace the DATABASE() and SCHEMA() of the comparisons with the current database.
+func replaceDatabaseFunc(expr sqlparser.Expr, database string) {
+	replace := func(expr sqlparser.Expr) sqlparser.Expr {
+		fn, ok := expr.(*sqlparser.FuncExpr)
+		if !ok || len(fn.Exprs) != 0 || !(fn.Name.EqualString("database") || fn.Name.EqualString("schema")) {
+			return expr
+		}
+		if database == "" {
+			return &sqlparser.NullVal{}
+		}
+		return sqlparser.NewStrVal([]byte(database))
+	}
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		if comparison, ok := node.(*sqlparser.ComparisonExpr); ok {
+			comparison.Left = replace(comparison.Left)
+			comparison.Right = replace(comparison.Right)
+		}
+		return true, nil
+	}, expr)
+}
+
+// infoSchemaFilter returns the condition on the TABLE_SCHEMA and TABLE_NAME of the information_schema
+// query, the names are escaped as the string literals.
+func infoSchemaFilter(database string, tables ...string) string {
+	filter := fmt.Sprintf("table_schema=%s", sqlLiteral(sqltypes.NewVarChar(database)))
+	switch len(tables) {
+	case 0:
+		return filter
+	case 1:
+		return fmt.Sprintf("%s and table_name=%s", filter, sqlLiteral(sqltypes.NewVarChar(tables[0])))
+	}
+	names := make([]string, 0, len(tables))
+	for _, table := range tables {
+		names = append(names, sqlLiteral(sqltypes.NewVarChar(table)))
+	}
+	return fmt.Sprintf("%s and table_name in (%s)", filter, strings.Join(names, ", "))
+}
+
+// infoSchemaPushdown returns the TABLE_SCHEMA and TABLE_NAME values(lowered) of the '=' or IN
+// conditions ANDed in the WHERE, they're used to read only the partitions needed. Nil if no condition.
+func infoSchemaPushdown(where *sqlparser.Where) (map[string]bool, map[string]bool) {
+	var schemas, names map[string]bool
+	if where == nil {
+		return nil, nil
+	}
+
+	intersect := func(set, vals map[string]bool) map[string]bool {
+		if set == nil {
+			return vals
+		}
+		for val := range set {
+			if !vals[val] {
+				delete(set, val)
+			}
+		}
+		return set
+	}
+	for _, cond := range splitAndConds(where.Expr) {
+		comparison, ok := cond.(*sqlparser.ComparisonExpr)
+		if !ok {
+			continue
+		}
+		col, ok := comparison.Left.(*sqlparser.ColName)
+		if !ok {
+			continue
+		}
+
+		var exprs []sqlparser.Expr
+		switch comparison.Operator {
+		case sqlparser.EqualStr:
+			exprs = []sqlparser.Expr{comparison.Right}
+		case sqlparser.InStr:
+			tuple, ok := comparison.Right.(sqlparser.ValTuple)
+			if !ok {
+				continue
+			}
+			exprs = tuple
+		default:
+			continue
+		}
+		vals := make(map[string]bool)
+		for _, expr := range exprs {
+			val, ok := expr.(*sqlparser.SQLVal)
+			if !ok || val.Type != sqlparser.StrVal {
+				vals = nil
+				break
+			}
+			vals[strings.ToLower(string(val.Val))] = true
+		}
+		if vals == nil {
+			continue
+		}
+
+		switch {
+		case col.Name.EqualString("TABLE_SCHEMA"):
+			schemas = intersect(schemas, vals)
+		case col.Name.EqualString("TABLE_NAME"):
+			names = intersect(names, vals)
+		}
+	}
+	return schemas, names
+}
+
+// splitAndConds used to split the expr into the conditions ANDed.
+func splitAndConds(expr sqlparser.Expr) []sqlparser.Expr {
+	switch expr := expr.(type) {
+	case *sqlparser.AndExpr:
+		return append(splitAndConds(expr.Left), splitAndConds(expr.Right)...)
+	case *sqlparser.ParenExpr:
+		return splitAndConds(expr.Expr)
+	}
+	return []sqlparser.Expr{expr}
+}
+
+// isDatabaseVisible returns true if the database is visible to the user, the user without the
+// super privilege only sees the databases granted, the same as the SHOW DATABASES.
+func (spanner *Spanner) isDatabaseVisible(session *driver.Session, database string) bool {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if privilegePlug.IsSuperPriv(session.User()) || privilegePlug.CheckUserPrivilegeIsSet(session.User()) {
+		return true
+	}
+	return privilegePlug.CheckDBinUserPrivilege(session.User(), database)
+}
+
+// infoSchemaSchemata used to get the SCHEMATA from a backend, only the databases visible to the user.
+func (spanner *Spanner) infoSchemaSchemata(session *driver.Session) (*sqltypes.Result, error) {
+	qr, err := spanner.ExecuteSingle("select * from information_schema.schemata")
+	if err != nil {
+		return nil, err
+	}
+	idx := fieldIndex(qr.Fields, "SCHEMA_NAME")
+	if idx < 0 {
+		return qr, nil
+	}
+	rows := make([][]sqltypes.Value, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		if spanner.isDatabaseVisible(session, row[idx].String()) {
+			rows = append(rows, row)
+		}
+	}
+	qr.Rows = rows
+	return qr, nil
+}
+
+// infoSchemaTables returns the logical tables visible to the user, filtered by the schemas and the names.
+func (spanner *Spanner) infoSchemaTables(session *driver.Session, schemas, names map[string]bool) ([]*infoSchemaTable, error) {
+	router := spanner.router
+	list := router.Tables()
+	databases := make([]string, 0, len(list))
+	for database := range list {
+		if schemas != nil && !schemas[strings.ToLower(database)] {
+			continue
+		}
+		if spanner.isDatabaseVisible(session, database) {
+			databases = append(databases, database)
+		}
+	}
+	sort.Strings(databases)
+
+	var tables []*infoSchemaTable
+	for _, database := range databases {
+		tbls := list[database]
+		sort.Strings(tbls)
+		for _, tbl := range tbls {
+			if names != nil && !names[strings.ToLower(tbl)] {
+				continue
+			}
+			conf, err := router.TableConfig(database, tbl)
+			if err != nil {
+				return nil, err
+			}
+			tables = append(tables, &infoSchemaTable{database: database, conf: conf})
+		}
+	}
+	return tables, nil
+}
+
+// infoSchemaBackendRows used to read the rows of the schemas and tables not in the router from a
+// backend, the router tables and their partitions are skipped, and so are the databases invisible
+// to the user.
+func (spanner *Spanner) infoSchemaBackendRows(session *driver.Session, view string, schemas, names map[string]bool) (*sqltypes.Result, error) {
+	router := spanner.router
+	literals := func(vals map[string]bool) string {
+		list := make([]string, 0, len(vals))
+		for val := range vals {
+			list = append(list, sqlLiteral(sqltypes.NewVarChar(val)))
+		}
+		sort.Strings(list)
+		return strings.Join(list, ", ")
+	}
+
+	var conds []string
+	switch {
+	case (schemas != nil && len(schemas) == 0) || (names != nil && len(names) == 0):
+		conds = append(conds, "1 != 1")
+	default:
+		if schemas != nil {
+			conds = append(conds, fmt.Sprintf("table_schema in (%s)", literals(schemas)))
+		}
+		if names != nil {
+			conds = append(conds, fmt.Sprintf("table_name in (%s)", literals(names)))
+		}
+	}
+	query := fmt.Sprintf("select * from information_schema.%s", strings.ToLower(view))
+	if len(conds) > 0 {
+		query = fmt.Sprintf("%s where %s", query, strings.Join(conds, " and "))
+	}
+	qr, err := spanner.ExecuteSingle(query)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Rows) == 0 {
+		return qr, nil
+	}
+	schemaIdx, nameIdx := fieldIndex(qr.Fields, "TABLE_SCHEMA"), fieldIndex(qr.Fields, "TABLE_NAME")
+	if schemaIdx < 0 || nameIdx < 0 {
+		return nil, errors.Errorf("unsupported: information_schema.%s.without.table_schema.or.table_name", view)
+	}
+
+	// The router tables and their partitions.
+	routed := make(map[string]bool)
+	for database, tbls := range router.Tables() {
+		for _, tbl := range tbls {
+			routed[database+"."+tbl] = true
+			conf, err := router.TableConfig(database, tbl)
+			if err != nil {
+				return nil, err
+			}
+			for _, part := range conf.Partitions {
+				routed[database+"."+part.Table] = true
+			}
+		}
+	}
+	rows := make([][]sqltypes.Value, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		database := row[schemaIdx].String()
+		if routed[database+"."+row[nameIdx].String()] || !spanner.isDatabaseVisible(session, database) {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	qr.Rows = rows
+	return qr, nil
+}
+
+// infoSchemaRows used to read the rows of the partitions of the tables from the backends,
+// and merge them into the rows of the logical tables.
+func (spanner *Spanner) infoSchemaRows(view string, spec *infoSchemaView, tables []*infoSchemaTable) (*sqltypes.Result, error) {
+	type location struct {
+		table int
+		part  int
+	}
+	// The partitions of the tables on every backend, backend -> database -> tables.
+	locations := make(map[string]location)
+	parts := make(map[string]map[string][]string)
+	for i, table := range tables {
+		table.parts = table.conf.Partitions
+		if (!spec.allParts || table.conf.ShardType == "GLOBAL") && len(table.parts) > 1 {
+			table.parts = table.parts[:1]
+		}
+		for j, part := range table.parts {
+			locations[table.database+"."+part.Table] = location{table: i, part: j}
+			if parts[part.Backend] == nil {
+				parts[part.Backend] = make(map[string][]string)
+			}
+			parts[part.Backend][table.database] = append(parts[part.Backend][table.database], part.Table)
+		}
+	}
+	if len(locations) == 0 {
+		// The view without rows.
+		return spanner.ExecuteSingle(fmt.Sprintf("select * from information_schema.%s where 1 != 1", strings.ToLower(view)))
+	}
+
+	backends := make([]string, 0, len(parts))
+	for backend := range parts {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	var querys []xcontext.QueryTuple
+	for _, backend := range backends {
+		databases := make([]string, 0, len(parts[backend]))
+		for database := range parts[backend] {
+			databases = append(databases, database)
+		}
+		sort.Strings(databases)
+		for _, database := range databases {
+			query := fmt.Sprintf("select * from information_schema.%s where %s", strings.ToLower(view), infoSchemaFilter(database, parts[backend][database]...))
+			querys = append(querys, xcontext.QueryTuple{Query: query, Backend: backend})
+		}
+	}
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.QueryTimeout)
+
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = querys
+	qr, err := txn.Execute(req)
+	if err != nil {
+		return nil, err
+	}
+	fields := qr.Fields
+	schemaIdx, nameIdx, refIdx := fieldIndex(fields, "TABLE_SCHEMA"), fieldIndex(fields, "TABLE_NAME"), fieldIndex(fields, "REFERENCED_TABLE_NAME")
+	if schemaIdx < 0 || nameIdx < 0 {
+		return nil, errors.Errorf("unsupported: information_schema.%s.without.table_schema.or.table_name", view)
+	}
+
+	// The rows of every partition, renamed to the logical table.
+	partRows := make([][][][]sqltypes.Value, len(tables))
+	for i, table := range tables {
+		partRows[i] = make([][][]sqltypes.Value, len(table.parts))
+	}
+	for _, row := range qr.Rows {
+		loc, ok := locations[row[schemaIdx].String()+"."+row[nameIdx].String()]
+		if !ok {
+			continue
+		}
+		table := tables[loc.table]
+		row[nameIdx] = sqltypes.MakeTrusted(fields[nameIdx].Type, []byte(table.conf.Name))
+		if refIdx >= 0 && !row[refIdx].IsNull() {
+			if ref, ok := locations[table.database+"."+row[refIdx].String()]; ok {
+				row[refIdx] = sqltypes.MakeTrusted(fields[refIdx].Type, []byte(tables[ref.table].conf.Name))
+			}
+		}
+		partRows[loc.table][loc.part] = append(partRows[loc.table][loc.part], row)
+	}
+
+	result := &sqltypes.Result{Fields: fields}
+	for i, table := range tables {
+		// The table without the first partition on the backend is skipped.
+		if len(partRows[i][0]) == 0 {
+			continue
+		}
+		rows := partRows[i][0]
+		if spec.merge != nil {
+			rows = spec.merge(fields, table, partRows[i])
+		}
+		result.Rows = append(result.Rows, rows...)
+	}
+	return result, nil
+}
+
+// mergeInfoSchemaTables used to merge the TABLES rows of the partitions, the rows and the sizes are summed.
+func mergeInfoSchemaTables(fields []*querypb.Field, table *infoSchemaTable, parts [][][]sqltypes.Value) [][]sqltypes.Value {
+	row := parts[0][0]
+	for _, rows := range parts[1:] {
+		for _, r := range rows {
+			for _, name := range []string{"TABLE_ROWS", "DATA_LENGTH", "INDEX_LENGTH", "DATA_FREE"} {
+				if idx := fieldIndex(fields, name); idx >= 0 {
+					if v, err := sqltypes.NullsafeAdd(row[idx], r[idx], fields[idx].Type, 0); err == nil {
+						row[idx] = v
+					}
+				}
+			}
+			for _, name := range []string{"AUTO_INCREMENT", "UPDATE_TIME"} {
+				if idx := fieldIndex(fields, name); idx >= 0 {
+					row[idx] = sqltypes.Max(row[idx], r[idx])
+				}
+			}
+		}
+	}
+
+	// The AVG_ROW_LENGTH is DATA_LENGTH / TABLE_ROWS.
+	avgIdx, rowsIdx, dataIdx := fieldIndex(fields, "AVG_ROW_LENGTH"), fieldIndex(fields, "TABLE_ROWS"), fieldIndex(fields, "DATA_LENGTH")
+	if avgIdx >= 0 && rowsIdx >= 0 && dataIdx >= 0 {
+		rows, err1 := strconv.ParseUint(row[rowsIdx].String(), 10, 64)
+		data, err2 := strconv.ParseUint(row[dataIdx].String(), 10, 64)
+		if err1 == nil && err2 == nil {
+			var avg uint64
+			if rows > 0 {
+				avg = data / rows
+			}
+			row[avgIdx] = sqltypes.MakeTrusted(fields[avgIdx].Type, []byte(strconv.FormatUint(avg, 10)))
+		}
+	}
+	return [][]sqltypes.Value{row}
+}
+
+// mergeInfoSchemaStatistics used to merge the STATISTICS rows of the partitions, the CARDINALITY of
+// the index column is summed.
+func mergeInfoSchemaStatistics(fields []*querypb.Field, table *infoSchemaTable, parts [][][]sqltypes.Value) [][]sqltypes.Value {
+	rows := parts[0]
+	cardIdx, indexIdx, seqIdx := fieldIndex(fields, "CARDINALITY"), fieldIndex(fields, "INDEX_NAME"), fieldIndex(fields, "SEQ_IN_INDEX")
+	if cardIdx < 0 || indexIdx < 0 || seqIdx < 0 {
+		return rows
+	}
+
+	keys := make(map[string][]sqltypes.Value)
+	for _, row := range rows {
+		keys[row[indexIdx].String()+"."+row[seqIdx].String()] = row
+	}
+	for _, part := range parts[1:] {
+		for _, r := range part {
+			if row, ok := keys[r[indexIdx].String()+"."+r[seqIdx].String()]; ok {
+				if v, err := sqltypes.NullsafeAdd(row[cardIdx], r[cardIdx], fields[cardIdx].Type, 0); err == nil {
+					row[cardIdx] = v
+				}
+			}
+		}
+	}
+	return rows
+}
+
+// mergeInfoSchemaPartitions used to show the partitions of the table as the PARTITIONS rows:
+// the partition table is the PARTITION_NAME, the shard type is the PARTITION_METHOD, the shard key is
+// the PARTITION_EXPRESSION, the segment is the PARTITION_DESCRIPTION and the backend is the NODEGROUP.
+// The partitions of the partition table itself are shown as the subpartitions.
+func mergeInfoSchemaPartitions(fields []*querypb.Field, table *infoSchemaTable, parts [][][]sqltypes.Value) [][]sqltypes.Value {
+	set := func(row []sqltypes.Value, name string, val sqltypes.Value) {
+		if idx := fieldIndex(fields, name); idx >= 0 {
+			row[idx] = val
+		}
+	}
+	get := func(row []sqltypes.Value, name string) sqltypes.Value {
+		if idx := fieldIndex(fields, name); idx >= 0 {
+			return row[idx]
+		}
+		return sqltypes.NULL
+	}
+	str := func(s string) sqltypes.Value {
+		if s == "" {
+			return sqltypes.NULL
+		}
+		return sqltypes.NewVarChar(s)
+	}
+
+	var rows [][]sqltypes.Value
+	for i, part := range table.parts {
+		nodegroup := part.Backend
+		if table.conf.ShardType == "GLOBAL" {
+			var backends []string
+			for _, p := range table.conf.Partitions {
+				backends = append(backends, p.Backend)
+			}
+			nodegroup = strings.Join(backends, ",")
+		}
+		for _, row := range parts[i] {
+			if !get(row, "PARTITION_NAME").IsNull() {
+				for _, name := range []string{"NAME", "ORDINAL_POSITION", "METHOD", "EXPRESSION"} {
+					set(row, "SUBPARTITION_"+name, get(row, "PARTITION_"+name))
+				}
+			}
+			set(row, "PARTITION_NAME", str(part.Table))
+			set(row, "PARTITION_ORDINAL_POSITION", sqltypes.NewUint64(uint64(i+1)))
+			set(row, "PARTITION_METHOD", str(table.conf.ShardType))
+			set(row, "PARTITION_EXPRESSION", str(table.conf.ShardKey))
+			set(row, "PARTITION_DESCRIPTION", str(part.Segment))
+			set(row, "NODEGROUP", str(nodegroup))
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+// fieldIndex returns the index of the field by the name(case-insensitive), -1 if not found.
+func fieldIndex(fields []*querypb.Field, name string) int {
+	for i, field := range fields {
+		if strings.EqualFold(field.Name, name) {
+			return i
+		}
+	}
+	return -1
+}
+
+// rowKey returns the key of the values, the same values have the same key.
+func rowKey(vals []sqltypes.Value) string {
+	var buf bytes.Buffer
+	for _, val := range vals {
+		if val.IsNull() {
+			buf.WriteString("N;")
+			continue
+		}
+		fmt.Fprintf(&buf, "%d:%s;", len(val.Raw()), val.Raw())
+	}
+	return buf.String()
+}
+
+// selectOnResult used to evaluate the SELECT on the rows of the result: WHERE, GROUP BY with
+// the aggregate functions, HAVING, ORDER BY, DISTINCT and LIMIT. The table qualifies the columns.
+func (spanner *Spanner) selectOnResult(node *sqlparser.Select, qr *sqltypes.Result, table string) (*sqltypes.Result, error) {
+	fields := make([]*querypb.Field, len(qr.Fields))
+	for i, field := range qr.Fields {
+		f := *field
+		f.Table = table
+		fields[i] = &f
+	}
+	columns := len(fields)
+
+	// WHERE.
+	rows := qr.Rows
+	if node.Where != nil {
+		var err error
+		if rows, err = filterRows(node.Where.Expr, fields, rows); err != nil {
+			return nil, err
+		}
+	}
+
+	// GROUP BY and the aggregate functions.
+	fields, rows, err := aggregateRows(node, fields, rows)
+	if err != nil {
+		return nil, err
+	}
+
+	// The select exprs, the HAVING and the ORDER BY are evaluated on the projected values
+	// followed by the row, so the aliases are resolved.
+	var evals []expression.Evaluator
+	var projected []*querypb.Field
+	for _, expr := range node.SelectExprs {
+		switch expr := expr.(type) {
+		case *sqlparser.StarExpr:
+			if name := expr.TableName.Name.String(); name != "" && !strings.EqualFold(name, table) {
+				return nil, errors.Errorf("unsupported: unknown.table.'%s'", name)
+			}
+			for i := 0; i < columns; i++ {
+				eval, err := expression.NewEvaluator(&sqlparser.ColName{Name: sqlparser.NewColIdent(fields[i].Name)}, fields)
+				if err != nil {
+					return nil, err
+				}
+				field := *qr.Fields[i]
+				evals = append(evals, eval)
+				projected = append(projected, &field)
+			}
+		case *sqlparser.AliasedExpr:
+			eval, err := expression.NewEvaluator(expr.Expr, fields)
+			if err != nil {
+				return nil, err
+			}
+			field := &querypb.Field{Name: sqlparser.String(expr.Expr)}
+			if col, ok := expr.Expr.(*sqlparser.ColName); ok {
+				field.Name = col.Name.String()
+			}
+			if !expr.As.IsEmpty() {
+				field.Name = expr.As.String()
+			}
+			eval.FixField(field)
+			evals = append(evals, eval)
+			projected = append(projected, field)
+		default:
+			return nil, errors.Errorf("unsupported: select.expr.'%s'", sqlparser.String(expr))
+		}
+	}
+	extFields := append(append([]*querypb.Field{}, projected...), fields...)
+	extRows := make([][]sqltypes.Value, 0, len(rows))
+	for _, row := range rows {
+		ext := make([]sqltypes.Value, len(evals), len(evals)+len(row))
+		for i, eval := range evals {
+			if ext[i], err = eval.Eval(row); err != nil {
+				return nil, err
+			}
+		}
+		extRows = append(extRows, append(ext, row...))
+	}
+
+	// HAVING.
+	if node.Having != nil {
+		if extRows, err = filterRows(node.Having.Expr, extFields, extRows); err != nil {
+			return nil, err
+		}
+	}
+
+	// ORDER BY, the position refers to the select expr.
+	if len(node.OrderBy) > 0 {
+		keys := make([]func(row []sqltypes.Value) (sqltypes.Value, error), len(node.OrderBy))
+		for i, order := range node.OrderBy {
+			if val, ok := order.Expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.IntVal {
+				pos, err := strconv.Atoi(string(val.Val))
+				if err != nil || pos < 1 || pos > len(projected) {
+					return nil, errors.Errorf("unsupported: unknown.column.'%s'.in.order.clause", string(val.Val))
+				}
+				keys[i] = func(row []sqltypes.Value) (sqltypes.Value, error) {
+					return row[pos-1], nil
+				}
+				continue
+			}
+			eval, err := expression.NewEvaluator(order.Expr, extFields)
+			if err != nil {
+				return nil, err
+			}
+			keys[i] = eval.Eval
+		}
+
+		type sortRow struct {
+			row  []sqltypes.Value
+			keys []sqltypes.Value
+		}
+		sorts := make([]sortRow, len(extRows))
+		for i, row := range extRows {
+			sorts[i].row = row
+			for _, key := range keys {
+				val, err := key(row)
+				if err != nil {
+					return nil, err
+				}
+				sorts[i].keys = append(sorts[i].keys, val)
+			}
+		}
+		sort.SliceStable(sorts, func(i, j int) bool {
+			for k, order := range node.OrderBy {
+				cmp := sqltypes.NullsafeCompare(sorts[i].keys[k], sorts[j].keys[k])
+				if cmp == 0 {
+					continue
+				}
+				if order.Direction == sqlparser.DescScr {
+					return cmp > 0
+				}
+				return cmp < 0
+			}
+			return false
+		})
+		for i := range sorts {
+			extRows[i] = sorts[i].row
+		}
+	}
+
+	// DISTINCT.
+	result := &sqltypes.Result{Fields: projected}
+	seen := make(map[string]bool)
+	for _, row := range extRows {
+		row = row[:len(projected)]
+		if node.Distinct == sqlparser.DistinctStr {
+			key := rowKey(row)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		result.Rows = append(result.Rows, row)
+	}
+
+	// LIMIT.
+	if node.Limit != nil {
+		limit := planner.NewLimitPlan(spanner.log, node.Limit)
+		if err := limit.Build(); err != nil {
+			return nil, err
+		}
+		start, end := limit.Offset, limit.Offset+limit.Limit
+		if start > len(result.Rows) {
+			start = len(result.Rows)
+		}
+		if end > len(result.Rows) {
+			end = len(result.Rows)
+		}
+		result.Rows = result.Rows[start:end]
+	}
+	result.RowsAffected = uint64(len(result.Rows))
+	return result, nil
+}
+
+// filterRows returns the rows which the condition is true on.
+func filterRows(cond sqlparser.Expr, fields []*querypb.Field, rows [][]sqltypes.Value) ([][]sqltypes.Value, error) {
+	eval, err := expression.NewEvaluator(cond, fields)
+	if err != nil {
+		return nil, err
+	}
+	filtered := make([][]sqltypes.Value, 0, len(rows))
+	for _, row := range rows {
+		ok, err := eval.EvalBool(row)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			filtered = append(filtered, row)
+		}
+	}
+	return filtered, nil
+}
+
+// aggregateRows used to group the rows by the GROUP BY and evaluate the aggregate functions of the
+// select exprs, the HAVING and the ORDER BY. The results of the aggregate functions are appended to
+// the first row of the group as the columns named by the function text, such as `count(*)`, which
+// the expression evaluator resolves the functions to.
+func aggregateRows(node *sqlparser.Select, fields []*querypb.Field, rows [][]sqltypes.Value) ([]*querypb.Field, [][]sqltypes.Value, error) {
+	var funcs []*sqlparser.FuncExpr
+	seen := make(map[string]bool)
+	visit := func(node sqlparser.SQLNode) (kontinue bool, err error) {
+		switch node := node.(type) {
+		case *sqlparser.FuncExpr:
+			if node.IsAggregate() {
+				if text := sqlparser.String(node); !seen[text] {
+					seen[text] = true
+					funcs = append(funcs, node)
+				}
+				return false, nil
+			}
+		case *sqlparser.GroupConcatExpr:
+			return false, errors.New("unsupported: group_concat.on.information_schema")
+		}
+		return true, nil
+	}
+	if err := sqlparser.Walk(visit, node.SelectExprs, node.OrderBy); err != nil {
+		return nil, nil, err
+	}
+	if node.Having != nil {
+		if err := sqlparser.Walk(visit, node.Having.Expr); err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(funcs) == 0 && len(node.GroupBy) == 0 {
+		return fields, rows, nil
+	}
+
+	// The arguments of the aggregate functions are appended to the row.
+	columns := len(fields)
+	aggrFields := append([]*querypb.Field{}, fields...)
+	args := make([]expression.Evaluator, len(funcs))
+	plans := make([]planner.Aggregator, len(funcs))
+	for i, fn := range funcs {
+		typ, ok := infoSchemaAggrs[fn.Name.Lowered()]
+		if !ok || len(fn.Exprs) != 1 {
+			return nil, nil, errors.Errorf("unsupported: function:%s", sqlparser.String(fn))
+		}
+		field := &querypb.Field{Name: sqlparser.String(fn), Type: querypb.Type_INT64}
+		switch arg := fn.Exprs[0].(type) {
+		case *sqlparser.StarExpr:
+			if typ != planner.AggrTypeCount {
+				return nil, nil, errors.Errorf("unsupported: function:%s", sqlparser.String(fn))
+			}
+		case *sqlparser.AliasedExpr:
+			eval, err := expression.NewEvaluator(arg.Expr, fields)
+			if err != nil {
+				return nil, nil, err
+			}
+			eval.FixField(field)
+			args[i] = eval
+		default:
+			return nil, nil, errors.Errorf("unsupported: function:%s", sqlparser.String(fn))
+		}
+		aggrFields = append(aggrFields, field)
+		plans[i] = planner.Aggregator{Field: field.Name, Index: columns + i, Type: typ, Distinct: fn.Distinct}
+	}
+	aggrs := expression.NewAggregations(plans, false, aggrFields, 0)
+
+	// The GROUP BY may refer to the alias or the position of the select expr.
+	groupBys := make([]expression.Evaluator, len(node.GroupBy))
+	for i, expr := range node.GroupBy {
+		var err error
+		if groupBys[i], err = expression.NewEvaluator(selectExprOf(node, expr), fields); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	type group struct {
+		row      []sqltypes.Value
+		evalCtxs []*expression.AggEvaluateContext
+	}
+	var groups []*group
+	index := make(map[string]*group)
+	for _, row := range rows {
+		x := make([]sqltypes.Value, columns+len(funcs))
+		copy(x, row)
+		for i, arg := range args {
+			x[columns+i] = sqltypes.NewInt64(1)
+			if arg != nil {
+				var err error
+				if x[columns+i], err = arg.Eval(row); err != nil {
+					return nil, nil, err
+				}
+			}
+		}
+		keys := make([]sqltypes.Value, len(groupBys))
+		for i, groupBy := range groupBys {
+			var err error
+			if keys[i], err = groupBy.Eval(row); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		key := rowKey(keys)
+		if g, ok := index[key]; ok {
+			for i, aggr := range aggrs {
+				aggr.Update(x, g.evalCtxs[i])
+			}
+			continue
+		}
+		g := &group{row: x, evalCtxs: expression.NewAggEvalCtxs(aggrs, x)}
+		index[key] = g
+		groups = append(groups, g)
+	}
+	// The aggregate functions without GROUP BY have one row even if no rows.
+	if len(groups) == 0 && len(node.GroupBy) == 0 {
+		x := make([]sqltypes.Value, columns+len(funcs))
+		for i := range x {
+			x[i] = sqltypes.NULL
+		}
+		groups = append(groups, &group{row: x, evalCtxs: expression.NewAggEvalCtxs(aggrs, nil)})
+	}
+
+	grouped := make([][]sqltypes.Value, len(groups))
+	for i, g := range groups {
+		grouped[i], _ = expression.GetResults(aggrs, g.evalCtxs, g.row)
+	}
+	return aggrFields, grouped, nil
+}
+
+// selectExprOf returns the select expr which the alias or the position refers to, otherwise the expr.
+func selectExprOf(node *sqlparser.Select, expr sqlparser.Expr) sqlparser.Expr {
+	switch e := expr.(type) {
+	case *sqlparser.ColName:
+		if e.Qualifier.IsEmpty() {
+			for _, sel := range node.SelectExprs {
+				if aliased, ok := sel.(*sqlparser.AliasedExpr); ok && !aliased.As.IsEmpty() && aliased.As.Equal(e.Name) {
+					return aliased.Expr
+				}
+			}
+		}
+	case *sqlparser.SQLVal:
+		if e.Type == sqlparser.IntVal {
+			if pos, err := strconv.Atoi(string(e.Val)); err == nil && pos > 0 && pos <= len(node.SelectExprs) {
+				if aliased, ok := node.SelectExprs[pos-1].(*sqlparser.AliasedExpr); ok {
+					return aliased.Expr
+				}
+			}
+		}
+	}
+	return expr
+}
@@ -0,0 +1,308 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"fakedb"
+	"forks/go-mysqlstack/driver"
+	"router"
+
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// mockInfoSchemaFields returns the fields, the type of the name with the '#' suffix is UINT64.
+func mockInfoSchemaFields(names ...string) []*querypb.Field {
+	fields := make([]*querypb.Field, len(names))
+	for i, name := range names {
+		fields[i] = &querypb.Field{Name: name, Type: querypb.Type_VARCHAR}
+		if strings.HasSuffix(name, "#") {
+			fields[i] = &querypb.Field{Name: strings.TrimSuffix(name, "#"), Type: querypb.Type_UINT64}
+		}
+	}
+	return fields
+}
+
+// mockInfoSchemaRow returns the row of the values, "NULL" is the NULL.
+func mockInfoSchemaRow(fields []*querypb.Field, vals ...string) []sqltypes.Value {
+	row := make([]sqltypes.Value, len(vals))
+	for i, val := range vals {
+		row[i] = sqltypes.NULL
+		if val != "NULL" {
+			row[i] = sqltypes.MakeTrusted(fields[i].Type, []byte(val))
+		}
+	}
+	return row
+}
+
+// mockInfoSchema used to mock the view of the information_schema on the backends, the rows of every
+// partition of the tables are made by the fn.
+func mockInfoSchema(fakedbs *fakedb.DB, route *router.Router, view string, allParts bool, fields []*querypb.Field, database string, tables []string, fn func(part string) [][]sqltypes.Value) {
+	parts := make(map[string][]string)
+	rows := make(map[string][][]sqltypes.Value)
+	for _, table := range tables {
+		conf, err := route.TableConfig(database, table)
+		if err != nil {
+			panic(err)
+		}
+		partitions := conf.Partitions
+		if !allParts || conf.ShardType == "GLOBAL" {
+			partitions = partitions[:1]
+		}
+		for _, part := range partitions {
+			parts[part.Backend] = append(parts[part.Backend], part.Table)
+			rows[part.Backend] = append(rows[part.Backend], fn(part.Table)...)
+		}
+	}
+	for backend, names := range parts {
+		query := fmt.Sprintf("select * from information_schema.%s where %s", view, infoSchemaFilter(database, names...))
+		fakedbs.AddQuery(query, &sqltypes.Result{Fields: fields, Rows: rows[backend]})
+	}
+	// The rows not in the router read from a backend.
+	fakedbs.AddQueryPattern(fmt.Sprintf("select \\* from information_schema\\.%s where table_schema in .*", view), &sqltypes.Result{Fields: fields})
+}
+
+func TestProxyInfoSchemaFilter(t *testing.T) {
+	assert.Equal(t, "table_schema='test'", infoSchemaFilter("test"))
+	assert.Equal(t, "table_schema='test' and table_name='t1'", infoSchemaFilter("test", "t1"))
+	assert.Equal(t, "table_schema='te\\'st' and table_name in ('t1', 't\\\\2')", infoSchemaFilter("te'st", "t1", "t\\2"))
+}
+
+func TestProxyInfoSchema(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"create table test.t2(id int, b int) global",
+		"create table test.t3(id int, b int) single",
+		"use test",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	// TABLES.
+	{
+		fields := mockInfoSchemaFields("TABLE_SCHEMA", "TABLE_NAME", "TABLE_ROWS#", "AVG_ROW_LENGTH#", "DATA_LENGTH#", "INDEX_LENGTH#", "AUTO_INCREMENT#")
+		fn := func(part string) [][]sqltypes.Value {
+			autoinc := "1"
+			if part == "t1_0003" {
+				autoinc = "9"
+			}
+			return [][]sqltypes.Value{mockInfoSchemaRow(fields, "test", part, "10", "100", "1000", "16", autoinc)}
+		}
+		// The view, the system table, the router table and the partition on the backend, the router
+		// table and the partition are skipped.
+		fakedbs.AddQueryPattern("select \\* from information_schema\\.tables where table_schema in \\(.*'test'\\).*", &sqltypes.Result{
+			Fields: fields,
+			Rows: [][]sqltypes.Value{
+				mockInfoSchemaRow(fields, "mysql", "user", "1", "100", "1000", "16", "NULL"),
+				mockInfoSchemaRow(fields, "test", "t1", "10", "100", "1000", "16", "1"),
+				mockInfoSchemaRow(fields, "test", "t1_0000", "10", "100", "1000", "16", "1"),
+				mockInfoSchemaRow(fields, "test", "v1", "NULL", "NULL", "NULL", "NULL", "NULL"),
+			},
+		})
+		mockInfoSchema(fakedbs, route, "tables", true, fields, "test", []string{"t1", "t2", "t3"}, fn)
+		mockInfoSchema(fakedbs, route, "tables", true, fields, "test", []string{"t1"}, fn)
+		fakedbs.AddQuery("select * from information_schema.tables where 1 != 1", &sqltypes.Result{Fields: fields})
+
+		tests := []struct {
+			query string
+			want  string
+		}{
+			{
+				query: "select table_name, table_rows, data_length, index_length, avg_row_length, `auto_increment` from information_schema.tables where table_schema = database() order by table_name",
+				want:  "[[t1 300 30000 480 100 9] [t2 10 1000 16 100 1] [t3 10 1000 16 100 1] [v1     ]]",
+			},
+			{
+				query: "SELECT COUNT(*), SUM(TABLE_ROWS) AS total FROM INFORMATION_SCHEMA.TABLES t WHERE t.TABLE_SCHEMA = 'test' AND t.TABLE_NAME LIKE 'T%'",
+				want:  "[[3 320]]",
+			},
+			{
+				query: "select table_name as name, table_rows from information_schema.tables where table_schema='test' and table_rows < 100 order by name desc limit 1, 1",
+				want:  "[[t2 10]]",
+			},
+			{
+				query: "select table_rows > 100, count(*) as cnt from information_schema.tables where table_schema='test' group by 1 having cnt > 1 order by 2",
+				want:  "[[0 2]]",
+			},
+			{
+				query: "select distinct table_schema from information_schema.tables where table_schema in ('test', 'nodb')",
+				want:  "[[test]]",
+			},
+			{
+				query: "select * from information_schema.tables where table_schema='test' and table_name='t1'",
+				want:  "[[test t1 300 100 30000 480 9]]",
+			},
+			{
+				query: "select count(*), max(table_rows) from information_schema.tables where table_schema='nodb'",
+				want:  "[[0 ]]",
+			},
+			{
+				query: "select table_schema, table_name from information_schema.tables where table_schema in ('test', 'mysql') and table_name in ('user', 'v1', 't1_0000') order by 2",
+				want:  "[[mysql user] [test v1]]",
+			},
+		}
+		for _, test := range tests {
+			qr, err := client.FetchAll(test.query, -1)
+			assert.Nil(t, err, test.query)
+			if err == nil {
+				assert.Equal(t, test.want, fmt.Sprintf("%v", qr.Rows), test.query)
+			}
+		}
+
+		qr, err := client.FetchAll("select table_name as name, table_rows from information_schema.tables t where t.table_schema='test' and t.table_name='t1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "name", qr.Fields[0].Name)
+		assert.Equal(t, "table_rows", qr.Fields[1].Name)
+	}
+
+	// COLUMNS.
+	{
+		fields := mockInfoSchemaFields("TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION#", "IS_NULLABLE", "DATA_TYPE", "COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH#")
+		mockInfoSchema(fakedbs, route, "columns", false, fields, "test", []string{"t1"}, func(part string) [][]sqltypes.Value {
+			return [][]sqltypes.Value{
+				mockInfoSchemaRow(fields, "test", part, "b", "2", "YES", "int", "int(10) unsigned", "NULL"),
+				mockInfoSchemaRow(fields, "test", part, "id", "1", "NO", "int", "int(11)", "NULL"),
+			}
+		})
+
+		// ClickHouse MySQL Driver.
+		query := "SELECT COLUMN_NAME AS name, DATA_TYPE AS type, IS_NULLABLE = 'YES' AS is_nullable, COLUMN_TYPE LIKE '%unsigned%' AS is_unsigned, CHARACTER_MAXIMUM_LENGTH AS length FROM INFORMATION_SCHEMA.COLUMNS WHERE TABLE_SCHEMA='test' AND TABLE_NAME='t1' ORDER BY ORDINAL_POSITION"
+		qr, err := client.FetchAll(query, -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[id int 0 0 ] [b int 1 1 ]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// STATISTICS.
+	{
+		fields := mockInfoSchemaFields("TABLE_SCHEMA", "TABLE_NAME", "INDEX_NAME", "SEQ_IN_INDEX#", "COLUMN_NAME", "CARDINALITY#")
+		mockInfoSchema(fakedbs, route, "statistics", true, fields, "test", []string{"t1"}, func(part string) [][]sqltypes.Value {
+			return [][]sqltypes.Value{
+				mockInfoSchemaRow(fields, "test", part, "PRIMARY", "1", "id", "10"),
+				mockInfoSchemaRow(fields, "test", part, "idx_b", "1", "b", "2"),
+			}
+		})
+		qr, err := client.FetchAll("select table_name, index_name, column_name, cardinality from information_schema.statistics where table_schema='test' and table_name='t1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[t1 PRIMARY id 300] [t1 idx_b b 60]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// KEY_COLUMN_USAGE.
+	{
+		fields := mockInfoSchemaFields("CONSTRAINT_NAME", "TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "REFERENCED_TABLE_NAME")
+		mockInfoSchema(fakedbs, route, "key_column_usage", false, fields, "test", []string{"t1"}, func(part string) [][]sqltypes.Value {
+			return [][]sqltypes.Value{mockInfoSchemaRow(fields, "PRIMARY", "test", part, "id", "NULL")}
+		})
+		qr, err := client.FetchAll("select constraint_name, table_name, column_name from information_schema.key_column_usage where table_schema='test' and table_name='t1'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[PRIMARY t1 id]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// PARTITIONS.
+	{
+		fields := mockInfoSchemaFields("TABLE_SCHEMA", "TABLE_NAME", "PARTITION_NAME", "SUBPARTITION_NAME", "PARTITION_ORDINAL_POSITION#", "PARTITION_METHOD", "PARTITION_EXPRESSION", "PARTITION_DESCRIPTION", "TABLE_ROWS#", "NODEGROUP")
+		mockInfoSchema(fakedbs, route, "partitions", true, fields, "test", []string{"t1"}, func(part string) [][]sqltypes.Value {
+			return [][]sqltypes.Value{mockInfoSchemaRow(fields, "test", part, "NULL", "NULL", "NULL", "NULL", "NULL", "NULL", "10", "NULL")}
+		})
+		conf, err := route.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("select table_name, partition_name, partition_method, partition_expression, partition_description, table_rows, nodegroup from information_schema.partitions where table_schema='test' and table_name='t1' order by partition_ordinal_position", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(conf.Partitions), len(qr.Rows))
+		for i, part := range conf.Partitions {
+			assert.Equal(t, fmt.Sprintf("[t1 %s HASH id %s 10 %s]", part.Table, part.Segment, part.Backend), fmt.Sprintf("%v", qr.Rows[i]))
+		}
+	}
+
+	// The system database and the view not virtualized are sent to the backend.
+	{
+		fields := mockInfoSchemaFields("TABLE_NAME")
+		fakedbs.AddQuery("select table_name from information_schema.tables where table_schema='mysql'", &sqltypes.Result{
+			Fields: fields,
+			Rows:   [][]sqltypes.Value{mockInfoSchemaRow(fields, "user")},
+		})
+		fakedbs.AddQuery("select routine_name from information_schema.routines", &sqltypes.Result{
+			Fields: fields,
+			Rows:   [][]sqltypes.Value{mockInfoSchemaRow(fields, "p1")},
+		})
+		qr, err := client.FetchAll("select table_name from information_schema.tables where table_schema='mysql'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[user]]", fmt.Sprintf("%v", qr.Rows))
+		qr, err = client.FetchAll("select routine_name from information_schema.routines", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[p1]]", fmt.Sprintf("%v", qr.Rows))
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"select nocolumn from information_schema.tables where table_schema='test' and table_name='t1'",
+			"select x.* from information_schema.tables where table_schema='test' and table_name='t1'",
+			"select group_concat(table_name) from information_schema.tables where table_schema='test' and table_name='t1'",
+			"select std(table_rows) from information_schema.tables where table_schema='test' and table_name='t1'",
+			"select table_name from information_schema.tables where table_schema='test' and table_name='t1' order by 3",
+			"select table_name from information_schema.tables where table_schema='test' and table_name='t1' limit a",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+	}
+}
+
+func TestProxyInfoSchemaPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fields := mockInfoSchemaFields("CATALOG_NAME", "SCHEMA_NAME")
+		fakedbs.AddQuery("select * from information_schema.schemata", &sqltypes.Result{
+			Fields: fields,
+			Rows: [][]sqltypes.Value{
+				mockInfoSchemaRow(fields, "def", "information_schema"),
+				mockInfoSchemaRow(fields, "def", "test"),
+				mockInfoSchemaRow(fields, "def", "db2"),
+			},
+		})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// The user only sees the database granted.
+	qr, err := client.FetchAll("select schema_name from information_schema.SCHEMATA order by 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "[[test]]", fmt.Sprintf("%v", qr.Rows))
+}
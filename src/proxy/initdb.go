@@ -11,7 +11,8 @@ package proxy
 import (
 	"fmt"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqldb"
 )
 
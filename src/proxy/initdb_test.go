@@ -11,8 +11,9 @@ package proxy
 import (
 	"testing"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -9,7 +9,11 @@
 package proxy
 
 import (
-	"github.com/xelabs/go-mysqlstack/driver"
+	"time"
+
+	"expression"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
@@ -19,9 +23,105 @@ func (spanner *Spanner) handleInsert(session *driver.Session, query string, node
 	database := session.Schema()
 	autoincPlug := spanner.plugins.PlugAutoIncrement()
 
+	// The NEXTVAL() in the values.
+	if err := spanner.evalNextval(database, node.(*sqlparser.Insert)); err != nil {
+		return nil, err
+	}
+
 	// AutoIncrement plugin process.
-	if err := autoincPlug.Process(database, node.(*sqlparser.Insert)); err != nil {
+	var increment, offset uint64
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		increment, offset = txSession.getAutoInc()
+	}
+	insertID, err := autoincPlug.Process(database, node.(*sqlparser.Insert), increment, offset)
+	if err != nil {
+		return nil, err
+	}
+	if err := spanner.evalShardKey(session, database, node.(*sqlparser.Insert)); err != nil {
+		return nil, err
+	}
+	qr, err := spanner.ExecuteDML(session, database, query, node)
+	if err != nil {
 		return nil, err
 	}
-	return spanner.ExecuteDML(session, database, query, node)
+
+	// The id generated by the proxy is returned to the client, instead of the backend's.
+	if insertID != 0 {
+		qr.InsertID = insertID
+	}
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		txSession.setLastInsertID(qr.InsertID)
+		txSession.setRowCount(int64(qr.RowsAffected))
+	}
+	return qr, nil
+}
+
+// evalShardKey used to evaluate the shard key values which are the constant expressions,
+// such as '-5', 'CONCAT('a', 'b')' and 'UUID()'. The expressions are replaced by their
+// values, so the value of the non-deterministic function is the same as routed and stored.
+// The expressions which can't be evaluated are left to the planner, the time functions are
+// evaluated in the time zone of the session.
+func (spanner *Spanner) evalShardKey(session *driver.Session, database string, node *sqlparser.Insert) error {
+	rows, ok := node.Rows.(sqlparser.Values)
+	if !ok {
+		return nil
+	}
+	if !node.Table.Qualifier.IsEmpty() {
+		database = node.Table.Qualifier.String()
+	}
+	shardKey, err := spanner.router.ShardKey(database, node.Table.Name.String())
+	if err != nil || shardKey == "" {
+		return nil
+	}
+
+	idx := -1
+	for i, column := range node.Columns {
+		if column.String() == shardKey {
+			idx = i
+			break
+		}
+	}
+	if idx == -1 {
+		return nil
+	}
+
+	loc := time.Local
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		if loc, err = txSession.getLocation(); err != nil {
+			return err
+		}
+	}
+
+	for _, row := range rows {
+		if idx >= len(row) {
+			continue
+		}
+		switch row[idx].(type) {
+		case *sqlparser.SQLVal, *sqlparser.NullVal:
+			continue
+		}
+		evaluator, err := expression.NewEvaluatorInLocation(row[idx], nil, loc)
+		if err != nil {
+			continue
+		}
+		val, err := evaluator.Eval(nil)
+		if err != nil {
+			return err
+		}
+		row[idx] = valueToExpr(val)
+	}
+	return nil
+}
+
+// valueToExpr returns the literal of the value.
+func valueToExpr(val sqltypes.Value) sqlparser.Expr {
+	switch {
+	case val.IsNull():
+		return &sqlparser.NullVal{}
+	case val.IsIntegral():
+		return sqlparser.NewIntVal(val.Raw())
+	case val.IsFloat(), val.Type() == sqltypes.Decimal:
+		return sqlparser.NewFloatVal(val.Raw())
+	}
+	return sqlparser.NewStrVal(val.Raw())
 }
@@ -9,12 +9,16 @@
 package proxy
 
 import (
+	"regexp"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"fakedb"
+	"forks/go-mysqlstack/driver"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -211,4 +215,108 @@ func TestProxyInsertAutoIncrement(t *testing.T) {
 		_, err = client.FetchAll(query, -1)
 		assert.Nil(t, err)
 	}
+
+	// auto_increment_increment and auto_increment_offset.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer client.Close()
+		_, err = client.FetchAll("create table test.t2(`id` bigint NOT NULL AUTO_INCREMENT, b int) AUTO_INCREMENT=100 partition by hash(id)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("set auto_increment_increment=10, auto_increment_offset=3", -1)
+		assert.Nil(t, err)
+
+		// The segment reserved from the mock store starts at MockSequenceNextID-1000.
+		qr, err := client.FetchAll("insert into test.t2(b) values(1), (2)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1047583), qr.InsertID)
+		qr, err = client.FetchAll("insert into test.t2(b) values(3)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1047603), qr.InsertID)
+	}
+}
+
+func TestProxyInsertShardKeyEval(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert into test.t2_.*\\(id, name\\) values \\('[0-9a-f-]{36}', 'x'\\)", &sqltypes.Result{RowsAffected: 1})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table test.t2(id varchar(64), name varchar(20)) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	explain := func(query string) string {
+		qr, err := client.FetchAll("explain format=tree "+query, -1)
+		assert.Nil(t, err)
+		assert.Regexp(t, "^-> Insert: [0-9]+ partitions", qr.Rows[0][0].String(), query)
+		// The order of the partitions is random.
+		lines := strings.Split(qr.Rows[0][0].String(), "\n")
+		sort.Strings(lines)
+		return strings.Join(lines, "\n")
+	}
+
+	// The expression is routed and stored as its value.
+	{
+		tcases := []struct {
+			query string
+			want  string
+		}{
+			{"insert into test.t1(id, b) values(-5, 1)", "insert into test.t1(id, b) values(-5, 1)"},
+			{"insert into test.t1(id, b) values(cast('7' as unsigned), 1), (1+2, 2)", "insert into test.t1(id, b) values(7, 1), (3, 2)"},
+			{"insert into test.t2(id, name) values(concat('a', 'b'), 'x')", "insert into test.t2(id, name) values('ab', 'x')"},
+			{"insert into test.t1(id, b) values(null, 1)", "insert into test.t1(id, b) values(null, 1)"},
+		}
+		for _, tcase := range tcases {
+			assert.Equal(t, explain(tcase.want), explain(tcase.query), tcase.query)
+		}
+	}
+
+	// The non-deterministic function is evaluated once.
+	{
+		assert.Regexp(t, "values \\('[0-9a-f-]{36}', 'x'\\)", explain("insert into test.t2(id, name) values(uuid(), 'x')"))
+		assert.Regexp(t, "values \\('[0-9]{4}-[0-9]{2}-[0-9]{2} [0-9:]{8}', 'x'\\)", explain("insert into test.t2(id, name) values(now(), 'x')"))
+
+		qr, err := client.FetchAll("insert into test.t2(id, name) values(uuid(), 'x')", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+	}
+
+	// The time functions are evaluated in the time zone of the session.
+	{
+		_, err := client.FetchAll("set time_zone='+14:00'", -1)
+		assert.Nil(t, err)
+		matches := regexp.MustCompile("values \\('([0-9-]{10} [0-9:]{8})', 'x'\\)").FindStringSubmatch(explain("insert into test.t2(id, name) values(now(), 'x')"))
+		assert.Equal(t, 2, len(matches))
+		got, err := time.ParseInLocation("2006-01-02 15:04:05", matches[1], time.FixedZone("", 14*3600))
+		assert.Nil(t, err)
+		assert.WithinDuration(t, time.Now(), got, 5*time.Second)
+
+		_, err = client.FetchAll("set time_zone='+15:00'", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("explain insert into test.t2(id, name) values(now(), 'x')", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("set time_zone=default", -1)
+		assert.Nil(t, err)
+	}
+
+	// Unsupported.
+	{
+		_, err := client.FetchAll("insert into test.t1(id, b) values(rand(), 1)", -1)
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("insert into test.t1(id, b) values(b+1, 1)", -1)
+		assert.NotNil(t, err)
+	}
 }
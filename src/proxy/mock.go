@@ -17,6 +17,7 @@ import (
 
 	"config"
 	"fakedb"
+	"plugins/autoincrement"
 	"plugins/privilege"
 
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
@@ -140,6 +141,8 @@ func MockProxy1(log *xlog.Log, conf *config.Config) (*fakedb.DB, *Proxy, func())
 
 	// the user with super privilege.
 	privilege.MockInitPrivilegeY(fakedbs)
+	autoincrement.MockInitSequence(fakedbs)
+	mockInitDDLCheck(fakedbs)
 
 	// Proxy.
 	mockJSON := tmpDir + "/radon_mock.json"
@@ -182,6 +185,8 @@ func MockProxyPrivilegeN(log *xlog.Log, conf *config.Config) (*fakedb.DB, *Proxy
 	}
 
 	privilege.MockInitPrivilegeN(fakedbs)
+	autoincrement.MockInitSequence(fakedbs)
+	mockInitDDLCheck(fakedbs)
 
 	// Proxy.
 	mockJSON := tmpDir + "/radon_mock.json"
@@ -224,6 +229,8 @@ func MockProxyPrivilegeNotSuper(log *xlog.Log, conf *config.Config) (*fakedb.DB,
 	}
 
 	privilege.MockInitPrivilegeNotSuper(fakedbs)
+	autoincrement.MockInitSequence(fakedbs)
+	mockInitDDLCheck(fakedbs)
 
 	// Proxy.
 	mockJSON := tmpDir + "/radon_mock.json"
@@ -266,6 +273,8 @@ func MockProxyPrivilegeUsers(log *xlog.Log, conf *config.Config) (*fakedb.DB, *P
 	}
 
 	privilege.MockInitPrivilegeUsers(fakedbs)
+	autoincrement.MockInitSequence(fakedbs)
+	mockInitDDLCheck(fakedbs)
 
 	// Proxy.
 	mockJSON := tmpDir + "/radon_mock.json"
@@ -284,3 +293,8 @@ func MockConfigIdleTxnTimeout1() *config.Config {
 	conf.Proxy.IdleTxnTimeout = 1 // 1s
 	return conf
 }
+
+// mockInitDDLCheck mocks the backends without the conflicts to the DDL, see prevalidateDDL.
+func mockInitDDLCheck(fakedbs *fakedb.DB) {
+	fakedbs.AddQueryPattern("select (table_name|column_name) from information_schema\\.(tables|columns) where .*", &sqltypes.Result{})
+}
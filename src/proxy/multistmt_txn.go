@@ -11,8 +11,9 @@ package proxy
 import (
 	"backend"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/pkg/errors"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
@@ -60,6 +61,20 @@ func (spanner *Spanner) handleCommit(session *driver.Session, query string, node
 
 // ExecuteBegin used to execute "start transaction" or "begin".
 func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
+	log := spanner.log
+	if !spanner.isTwoPC() {
+		log.Error("spanner.execute.2pc.disable")
+		return nil, errors.Errorf("spanner.query.execute.multistmt.txn.error[twopc-disable]")
+	}
+	if err := spanner.beginTxn(session, query, node, false); err != nil {
+		return nil, err
+	}
+	qr := &sqltypes.Result{}
+	return qr, nil
+}
+
+// beginTxn used to begin the multi-statement txn, the local txn is begun without the twopc.
+func (spanner *Spanner) beginTxn(session *driver.Session, query string, node sqlparser.Statement, local bool) error {
 	log := spanner.log
 	conf := spanner.conf
 	sessions := spanner.sessions
@@ -67,11 +82,6 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 	var txn backend.Transaction
 	var err error
 
-	if !spanner.isTwoPC() {
-		log.Error("spanner.execute.2pc.disable")
-		return nil, errors.Errorf("spanner.query.execute.multistmt.txn.error[twopc-disable]")
-	}
-
 	currentSession := sessions.getTxnSession(session)
 	txn = currentSession.transaction
 
@@ -81,29 +91,58 @@ func (spanner *Spanner) ExecuteBegin(session *driver.Session, query string, node
 	if txn != nil {
 		// the last txn isn't free
 		log.Error("spanner.execute.multistmt.begin.nestedTxn.unsupported.")
-		return nil, errors.Errorf("ExecuteMultiStatBegin.nestedTxn.unsupported")
+		return errors.Errorf("ExecuteMultiStatBegin.nestedTxn.unsupported")
 	}
 
 	txn, err = scatter.CreateTransaction()
 	if err != nil {
 		log.Error("spanner.txn.create.error:[%v]", err)
-		return nil, err
+		return err
 	}
 	txn.SetTimeout(conf.Proxy.QueryTimeout)
 	txn.SetMaxResult(conf.Proxy.MaxResultSize)
 	txn.SetMaxJoinRows(conf.Proxy.MaxJoinRows)
+	txn.SetMaxQueryMemory(conf.Proxy.MaxQueryMemory)
+	txn.SetSpillDir(conf.Proxy.SpillDir)
+	txn.SetGroupConcatMaxLen(currentSession.getGroupConcatMaxLen())
+	txn.SetSessionVars(currentSession.getBackendVars())
 	txn.SetMultiStmtTxn()
 
 	sessions.MultiStmtTxnBinding(session, txn, node, query)
-	if err := txn.BeginScatter(); err != nil {
+	if local {
+		err = txn.BeginLocal()
+	} else {
+		err = txn.BeginScatter()
+	}
+	if err != nil {
 		txn.Finish()
 		sessions.MultiStmtTxnUnBinding(session, true)
 		log.Error("spanner.execute.multistmt.txn.begin.scatter.error:[%v]", err)
-		return nil, err
+		return err
 	}
+	return nil
+}
 
-	qr := &sqltypes.Result{}
-	return qr, nil
+// beginImplicitTxn used to begin the multi-statement txn before the DML and the table SELECT if the
+// autocommit is off, the same as MySQL, it's ended by the COMMIT, ROLLBACK or SET autocommit=1.
+// The local txn is begun if the twopc is disabled.
+func (spanner *Spanner) beginImplicitTxn(session *driver.Session, node sqlparser.Statement) error {
+	switch node := node.(type) {
+	case *sqlparser.Insert, *sqlparser.Update, *sqlparser.Delete, *sqlparser.Union:
+	case *sqlparser.Select:
+		if !spanner.isTableSelect(node) {
+			return nil
+		}
+	default:
+		return nil
+	}
+
+	txSession := spanner.sessions.getTxnSession(session)
+	if val, ok := txSession.getSysVar(var_autocommit); !ok || val != "0" || txSession.transaction != nil {
+		return nil
+	}
+	begin := &sqlparser.Transaction{Action: sqlparser.BeginTxnStr}
+	return spanner.beginTxn(session, sqlparser.BeginTxnStr, begin, !spanner.isTwoPC())
 }
 
 // ExecuteRollback used to execute multiple-statement transaction sql:"rollback"
@@ -112,16 +151,17 @@ func (spanner *Spanner) ExecuteRollback(session *driver.Session, query string, n
 	sessions := spanner.sessions
 	var txn backend.Transaction
 
-	if !spanner.isTwoPC() {
+	// transaction.
+	currentSession := sessions.getTxnSession(session)
+	txn = currentSession.transaction
+
+	// The local txn of the autocommit off can be ended without the twopc.
+	if !spanner.isTwoPC() && txn == nil {
 		log.Error("spanner.execute.multistmt.txn.rollback.2pc.disable")
 		qr := &sqltypes.Result{Warnings: 1}
 		return qr, errors.Errorf("spanner.execute.multistmt.txn.rollback.error[twopc-disable]")
 	}
 
-	// transaction.
-	currentSession := sessions.getTxnSession(session)
-	txn = currentSession.transaction
-
 	// return err if query is "rollback" without begin a multi-transaction.
 	if txn == nil {
 		log.Error("spanner.execute.multistmt.txn.rollback.error.txn.not.begin")
@@ -147,16 +187,17 @@ func (spanner *Spanner) ExecuteCommit(session *driver.Session, query string, nod
 	sessions := spanner.sessions
 	var txn backend.Transaction
 
-	if !spanner.isTwoPC() {
+	// transaction.
+	currentSession := sessions.getTxnSession(session)
+	txn = currentSession.transaction
+
+	// The local txn of the autocommit off can be ended without the twopc.
+	if !spanner.isTwoPC() && txn == nil {
 		log.Error("spanner.execute.multistmt.txn.commit.error.2pc.disable")
 		qr := &sqltypes.Result{Warnings: 1}
 		return qr, errors.Errorf("spanner.execute.multistmt.txn.commit.error:[twopc-disable]")
 	}
 
-	// transaction.
-	currentSession := sessions.getTxnSession(session)
-	txn = currentSession.transaction
-
 	// return err if "commit" was sent without begin a multi-transaction.
 	if txn == nil {
 		log.Error("spanner.execute.multistmt.txn.commit.error.txn.not.begin")
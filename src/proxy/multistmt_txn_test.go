@@ -13,10 +13,10 @@ import (
 	"testing"
 
 	"fakedb"
+	"forks/go-mysqlstack/driver"
 
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
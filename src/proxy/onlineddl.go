@@ -0,0 +1,537 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"backend"
+	"forks/go-mysqlstack/driver"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+const (
+	// onlineDDLShadowSuffix is the suffix of the shadow table altered by the online DDL.
+	onlineDDLShadowSuffix = "_radon_osc"
+	// onlineDDLOldSuffix is the suffix of the partition table swapped out by the online DDL.
+	onlineDDLOldSuffix = "_radon_old"
+)
+
+var (
+	// onlineDDLThrottleWait is the time waited when the backend is busy.
+	onlineDDLThrottleWait = time.Second
+	errDDLJobCancelled    = errors.New("ddl.job.was.cancelled")
+)
+
+// isOnlineDDL returns true if the DDL can be executed online.
+func isOnlineDDL(node *sqlparser.DDL) bool {
+	switch node.Action {
+	case sqlparser.AlterEngineStr, sqlparser.AlterCharsetStr,
+		sqlparser.AlterAddColumnStr, sqlparser.AlterDropColumnStr, sqlparser.AlterModifyColumnStr:
+		return true
+	}
+	return false
+}
+
+// ExecuteDDLOnline used to create the job of the ALTER TABLE on the HASH table and alter the
+// partitions online, the job is executed in the background if async is true.
+func (spanner *Spanner) ExecuteDDLOnline(session *driver.Session, database string, query string, node sqlparser.Statement, async bool) (*sqltypes.Result, error) {
+	log := spanner.log
+	log.Info("spanner.execute.ddl.online.query:%s", query)
+
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession.transaction != nil {
+		return nil, errors.Errorf("in.multiStmtTrans.unsupported.DDL:%v.", query)
+	}
+
+	querys, err := spanner.buildDDLQuerys(database, query, node)
+	if err != nil {
+		return nil, err
+	}
+	if querys == nil {
+		return nil, errors.Errorf("unsupported: online.ddl[%s]", query)
+	}
+	if err := spanner.prevalidateDDL(database, node, querys, true); err != nil {
+		return nil, err
+	}
+
+	job, err := spanner.ddlJobs.Create(session.User(), database, query, querys, true)
+	if err != nil {
+		return nil, err
+	}
+	if async {
+		go func() {
+			if _, err := spanner.runOnlineDDLJob(job.ID, false); err != nil {
+				log.Error("spanner.ddl.online.job[%d:%s].error:%+v", job.ID, query, err)
+			}
+		}()
+		return ddlJobIDResult(job.ID), nil
+	}
+	return spanner.runOnlineDDLJob(job.ID, false)
+}
+
+// runOnlineDDLJob used to alter the partitions not done of the job online, at most
+// online-ddl-concurrency partitions are altered at the same time.
+func (spanner *Spanner) runOnlineDDLJob(id uint64, resume bool) (*sqltypes.Result, error) {
+	jobs := spanner.ddlJobs
+	job, err := jobs.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	database := job.Database
+	querys, err := jobs.start(id, nil, resume)
+	if err != nil {
+		return nil, err
+	}
+
+	concurrency := spanner.conf.Proxy.OnlineDDLConcurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var execErr error
+	tokens := make(chan struct{}, concurrency)
+	for _, qt := range querys {
+		tokens <- struct{}{}
+		mu.Lock()
+		stop := execErr != nil
+		mu.Unlock()
+		if stop || jobs.isCancelled(id) {
+			<-tokens
+			break
+		}
+
+		wg.Add(1)
+		go func(qt xcontext.QueryTuple) {
+			defer func() {
+				<-tokens
+				wg.Done()
+			}()
+			if err := spanner.onlineAlterPartition(database, qt, func() bool { return jobs.isCancelled(id) }); err != nil {
+				// The partition cancelled is still pending.
+				if err != errDDLJobCancelled {
+					if x := jobs.partitionFailed(id, qt.Backend, qt.Query, err); x != nil {
+						err = errors.Errorf("%v, flush.error:%v", err, x)
+					}
+				}
+				mu.Lock()
+				if execErr == nil {
+					execErr = err
+				}
+				mu.Unlock()
+				return
+			}
+			// The partition done is flushed for the resuming, the failure is logged by the flush
+			// and the job goes on, the state is flushed again by the next partition.
+			jobs.partitionDone(id, qt.Backend, qt.Query)
+			jobs.save()
+		}(qt)
+	}
+	wg.Wait()
+
+	if execErr == nil && jobs.isCancelled(id) {
+		execErr = errDDLJobCancelled
+	}
+	// The partitions altered aren't failed by the flush, the state is flushed with the next job.
+	if err := jobs.finish(id, execErr, false); err != nil {
+		spanner.log.Error("spanner.ddl.online.job[%d].finish.error:%+v", id, err)
+	}
+	if execErr != nil {
+		return nil, execErr
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// onlineAlter is the online DDL of one partition, the querys are executed in one txn.
+type onlineAlter struct {
+	spanner   *Spanner
+	txn       *backend.Txn
+	node      *sqlparser.DDL
+	backend   string
+	database  string
+	table     string
+	cancelled func() bool
+}
+
+// newOnlineAlter returns the online DDL of the partition query.
+func (spanner *Spanner) newOnlineAlter(txn *backend.Txn, database string, qt xcontext.QueryTuple, cancelled func() bool) (*onlineAlter, error) {
+	node, err := sqlparser.Parse(qt.Query)
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok || !isOnlineDDL(ddl) {
+		return nil, errors.Errorf("unsupported: online.ddl[%s]", qt.Query)
+	}
+	db, table := tableSchemaName(database, ddl.Table)
+	return &onlineAlter{
+		spanner:   spanner,
+		txn:       txn,
+		node:      ddl,
+		backend:   qt.Backend,
+		database:  db,
+		table:     table,
+		cancelled: cancelled,
+	}, nil
+}
+
+// prevalidateOnlineDDL used to check the partitions can be altered online before the job is created.
+// The partition table must have the single column primary key which isn't changed by the DDL.
+// MySQL older than 5.7 allows only one trigger for each event, the table with triggers is refused.
+func (spanner *Spanner) prevalidateOnlineDDL(database string, querys []xcontext.QueryTuple) error {
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+
+	versions := make(map[string]float64)
+	for _, qt := range querys {
+		o, err := spanner.newOnlineAlter(txn, database, qt, nil)
+		if err != nil {
+			return err
+		}
+		pk, err := o.primaryKey()
+		if err != nil {
+			return err
+		}
+		if len(pk) != 1 {
+			return errors.Errorf("unsupported: online.ddl.table[%s].needs.the.single.column.primary.key", o.table)
+		}
+		if err := o.checkColumns(pk[0]); err != nil {
+			return err
+		}
+
+		version, ok := versions[qt.Backend]
+		if !ok {
+			if version, err = o.version(); err != nil {
+				return err
+			}
+			versions[qt.Backend] = version
+		}
+		if version < defaultMySQLVersion {
+			triggers, err := o.triggers()
+			if err != nil {
+				return err
+			}
+			if len(triggers) > 0 {
+				return errors.Errorf("unsupported: online.ddl.table[%s].has.triggers[%s].on.mysql[%v]", o.table, strings.Join(triggers, ","), version)
+			}
+		}
+	}
+	return nil
+}
+
+// onlineAlterPartition used to alter the partition online like pt-online-schema-change:
+// 1. create the shadow table like the partition table and alter it.
+// 2. create the triggers on the partition table to catch up the changes to the shadow table.
+// 3. copy the rows to the shadow table chunk by chunk, it pauses when the backend is busy.
+// 4. swap the shadow table and the partition table by the atomic rename, drop the old one.
+func (spanner *Spanner) onlineAlterPartition(database string, qt xcontext.QueryTuple, cancelled func() bool) error {
+	log := spanner.log
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return err
+	}
+	defer txn.Finish()
+
+	o, err := spanner.newOnlineAlter(txn, database, qt, cancelled)
+	if err != nil {
+		return err
+	}
+	log.Info("spanner.ddl.online[%s].partition[%s:%s].start", qt.Query, o.backend, o.table)
+	if err := o.run(qt.Query); err != nil {
+		log.Error("spanner.ddl.online[%s].partition[%s:%s].error:%+v", qt.Query, o.backend, o.table, err)
+		o.cleanup()
+		return err
+	}
+	log.Info("spanner.ddl.online[%s].partition[%s:%s].done", qt.Query, o.backend, o.table)
+	return nil
+}
+
+// name returns the `db`.`table` of the table.
+func (o *onlineAlter) name(table string) string {
+	return fmt.Sprintf("`%s`.`%s`", o.database, table)
+}
+
+// trigger returns the name of the trigger for the event.
+func (o *onlineAlter) trigger(event string) string {
+	return o.name(fmt.Sprintf("radon_osc_%s_%s", o.table, event))
+}
+
+// execute used to execute the querys on the backend in order with the query-timeout,
+// returns the result of the last one.
+func (o *onlineAlter) execute(querys ...string) (*sqltypes.Result, error) {
+	return o.executeWithTimeout(o.spanner.conf.Proxy.QueryTimeout, querys...)
+}
+
+// executeDDL used to execute the DDLs on the backend in order with the ddl-timeout.
+func (o *onlineAlter) executeDDL(querys ...string) (*sqltypes.Result, error) {
+	return o.executeWithTimeout(o.spanner.conf.Proxy.DDLTimeout, querys...)
+}
+
+func (o *onlineAlter) executeWithTimeout(timeout int, querys ...string) (*sqltypes.Result, error) {
+	o.txn.SetTimeout(timeout)
+
+	var qr *sqltypes.Result
+	var err error
+	for _, query := range querys {
+		req := xcontext.NewRequestContext()
+		req.Mode = xcontext.ReqNormal
+		req.Querys = []xcontext.QueryTuple{{Query: query, Backend: o.backend}}
+		if qr, err = o.txn.Execute(req); err != nil {
+			return nil, err
+		}
+	}
+	return qr, nil
+}
+
+// version returns the MySQL version of the backend, such as 5.7.
+func (o *onlineAlter) version() (float64, error) {
+	qr, err := o.execute("select left(version(), 3) as version")
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Rows) == 0 {
+		return 0, errors.Errorf("online.ddl.backend[%s].version.not.found", o.backend)
+	}
+	return strconv.ParseFloat(qr.Rows[0][0].String(), 64)
+}
+
+// triggers returns the triggers on the partition table except the ones of the online DDL.
+func (o *onlineAlter) triggers() ([]string, error) {
+	qr, err := o.execute(fmt.Sprintf("select trigger_name from information_schema.triggers where event_object_schema=%s and event_object_table=%s and trigger_name not like 'radon\\_osc\\_%%'",
+		sqlLiteral(sqltypes.NewVarChar(o.database)), sqlLiteral(sqltypes.NewVarChar(o.table))))
+	if err != nil {
+		return nil, err
+	}
+	var triggers []string
+	for _, row := range qr.Rows {
+		triggers = append(triggers, row[0].String())
+	}
+	return triggers, nil
+}
+
+// checkColumns returns the error if the DDL changes the primary key, or adds the unique key
+// with which the rows copied by the INSERT IGNORE would be lost silently.
+func (o *onlineAlter) checkColumns(pk string) error {
+	var cols []*sqlparser.ColumnDefinition
+	switch o.node.Action {
+	case sqlparser.AlterDropColumnStr:
+		if strings.EqualFold(o.node.DropColumnName, pk) {
+			return errors.Errorf("unsupported: online.ddl.table[%s].changes.the.primary.key", o.table)
+		}
+	case sqlparser.AlterModifyColumnStr:
+		if strings.EqualFold(o.node.ModifyColumnDef.Name.String(), pk) {
+			return errors.Errorf("unsupported: online.ddl.table[%s].changes.the.primary.key", o.table)
+		}
+		cols = append(cols, o.node.ModifyColumnDef)
+	case sqlparser.AlterAddColumnStr:
+		cols = o.node.TableSpec.Columns
+	}
+	for _, col := range cols {
+		switch col.Type.KeyOpt {
+		case sqlparser.ColKeyPrimary, sqlparser.ColKeyUnique, sqlparser.ColKeyUniqueKey:
+			return errors.Errorf("unsupported: online.ddl.table[%s].adds.the.unique.key[%s]", o.table, col.Name.String())
+		}
+	}
+	return nil
+}
+
+// columns returns the columns of the table in order.
+func (o *onlineAlter) columns(table string) ([]string, error) {
+	qr, err := o.execute(fmt.Sprintf("select column_name from information_schema.columns where %s order by ordinal_position", infoSchemaFilter(o.database, table)))
+	if err != nil {
+		return nil, err
+	}
+	var cols []string
+	for _, row := range qr.Rows {
+		cols = append(cols, row[0].String())
+	}
+	return cols, nil
+}
+
+// primaryKey returns the columns of the primary key of the partition table.
+func (o *onlineAlter) primaryKey() ([]string, error) {
+	qr, err := o.execute(fmt.Sprintf("select column_name from information_schema.key_column_usage where %s and constraint_name='PRIMARY' order by ordinal_position", infoSchemaFilter(o.database, o.table)))
+	if err != nil {
+		return nil, err
+	}
+	var cols []string
+	for _, row := range qr.Rows {
+		cols = append(cols, row[0].String())
+	}
+	return cols, nil
+}
+
+// throttle used to wait until the Threads_running of the backend is not more than online-ddl-max-threads-running.
+func (o *onlineAlter) throttle() error {
+	log := o.spanner.log
+	max := o.spanner.conf.Proxy.OnlineDDLMaxThreadsRunning
+	for {
+		if o.cancelled() {
+			return errDDLJobCancelled
+		}
+		if max <= 0 {
+			return nil
+		}
+		qr, err := o.execute("show global status like 'Threads_running'")
+		if err != nil {
+			return err
+		}
+		if len(qr.Rows) == 0 || len(qr.Rows[0]) < 2 {
+			return nil
+		}
+		running, err := strconv.Atoi(qr.Rows[0][1].String())
+		if err != nil || running <= max {
+			return nil
+		}
+		log.Warning("spanner.ddl.online.partition[%s:%s].throttled.threads.running[%d>%d]", o.backend, o.table, running, max)
+		time.Sleep(onlineDDLThrottleWait)
+	}
+}
+
+// run used to alter the partition table through the shadow table.
+func (o *onlineAlter) run(query string) error {
+	seg := o.name(o.table)
+	shadow := o.name(o.table + onlineDDLShadowSuffix)
+	old := o.name(o.table + onlineDDLOldSuffix)
+
+	// The rows are copied chunk by chunk with the primary key.
+	pk, err := o.primaryKey()
+	if err != nil {
+		return err
+	}
+	if len(pk) != 1 {
+		return errors.Errorf("unsupported: online.ddl.table[%s].needs.the.single.column.primary.key", o.table)
+	}
+	key := fmt.Sprintf("`%s`", pk[0])
+
+	// Leftovers of the online DDL interrupted before.
+	o.cleanup()
+
+	// Create the shadow table and alter it, the DDL is regenerated with the shadow table.
+	ddl := *o.node
+	ddl.Table = sqlparser.TableName{
+		Name:      sqlparser.NewTableIdent(o.table + onlineDDLShadowSuffix),
+		Qualifier: sqlparser.NewTableIdent(o.database),
+	}
+	ddl.NewName = ddl.Table
+	alter := sqlparser.String(&ddl)
+	if _, err := o.executeDDL(fmt.Sprintf("create table %s like %s", shadow, seg), alter); err != nil {
+		return err
+	}
+
+	// The columns in both tables are copied.
+	segCols, err := o.columns(o.table)
+	if err != nil {
+		return err
+	}
+	shadowCols, err := o.columns(o.table + onlineDDLShadowSuffix)
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool)
+	for _, col := range segCols {
+		exists[strings.ToLower(col)] = true
+	}
+	var cols, newCols []string
+	for _, col := range shadowCols {
+		if exists[strings.ToLower(col)] {
+			cols = append(cols, fmt.Sprintf("`%s`", col))
+			newCols = append(newCols, fmt.Sprintf("NEW.`%s`", col))
+		}
+	}
+	if !exists[strings.ToLower(pk[0])] || len(cols) == 0 {
+		return errors.Errorf("unsupported: online.ddl[%s].changes.the.primary.key", query)
+	}
+	list, values := strings.Join(cols, ", "), strings.Join(newCols, ", ")
+
+	// Catch up the changes by the triggers.
+	if _, err := o.executeDDL(
+		fmt.Sprintf("create trigger %s after insert on %s for each row replace into %s(%s) values(%s)", o.trigger("ins"), seg, shadow, list, values),
+		fmt.Sprintf("create trigger %s after update on %s for each row begin delete ignore from %s where %s <=> OLD.%s; replace into %s(%s) values(%s); end", o.trigger("upd"), seg, shadow, key, key, shadow, list, values),
+		fmt.Sprintf("create trigger %s after delete on %s for each row delete ignore from %s where %s <=> OLD.%s", o.trigger("del"), seg, shadow, key, key),
+	); err != nil {
+		return err
+	}
+
+	// Copy the rows chunk by chunk.
+	chunk := o.spanner.conf.Proxy.OnlineDDLChunkSize
+	if chunk < 1 {
+		chunk = 1000
+	}
+	var last string
+	for {
+		if err := o.throttle(); err != nil {
+			return err
+		}
+		where := ""
+		if last != "" {
+			where = fmt.Sprintf(" where %s > %s", key, last)
+		}
+		qr, err := o.execute(fmt.Sprintf("select max(%s) from (select %s from %s%s order by %s limit %d) as chunk", key, key, seg, where, key, chunk))
+		if err != nil {
+			return err
+		}
+		if len(qr.Rows) == 0 || qr.Rows[0][0].IsNull() {
+			break
+		}
+		upto := sqlLiteral(qr.Rows[0][0])
+		cond := fmt.Sprintf("%s <= %s", key, upto)
+		if last != "" {
+			cond = fmt.Sprintf("%s > %s and %s", key, last, cond)
+		}
+		if _, err := o.execute(fmt.Sprintf("insert ignore into %s(%s) select %s from %s where %s lock in share mode", shadow, list, list, seg, cond)); err != nil {
+			return err
+		}
+		last = upto
+	}
+
+	// Swap the tables, the triggers are dropped with the old table.
+	if o.cancelled() {
+		return errDDLJobCancelled
+	}
+	_, err = o.executeDDL(
+		fmt.Sprintf("drop table if exists %s", old),
+		fmt.Sprintf("rename table %s to %s, %s to %s", seg, old, shadow, seg),
+		fmt.Sprintf("drop table if exists %s", old),
+	)
+	return err
+}
+
+// cleanup used to drop the triggers and the shadow table.
+func (o *onlineAlter) cleanup() {
+	log := o.spanner.log
+	if _, err := o.executeDDL(
+		fmt.Sprintf("drop trigger if exists %s", o.trigger("ins")),
+		fmt.Sprintf("drop trigger if exists %s", o.trigger("upd")),
+		fmt.Sprintf("drop trigger if exists %s", o.trigger("del")),
+		fmt.Sprintf("drop table if exists %s", o.name(o.table+onlineDDLShadowSuffix)),
+	); err != nil {
+		log.Error("spanner.ddl.online.partition[%s:%s].cleanup.error:%+v", o.backend, o.table, err)
+	}
+}
+
+// sqlLiteral returns the SQL literal of the value.
+func sqlLiteral(v sqltypes.Value) string {
+	buf := &bytes.Buffer{}
+	v.EncodeSQL(buf)
+	return buf.String()
+}
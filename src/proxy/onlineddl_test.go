@@ -0,0 +1,305 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// mockColumns returns the result with one column.
+func mockColumns(values ...string) *sqltypes.Result {
+	qr := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "column_name", Type: querypb.Type_VARCHAR},
+		},
+	}
+	for _, v := range values {
+		qr.Rows = append(qr.Rows, []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(v))})
+	}
+	return qr
+}
+
+// mockTriggersQuery returns the query of the triggers on the partition table.
+func mockTriggersQuery(table string) string {
+	return fmt.Sprintf("select trigger_name from information_schema.triggers where event_object_schema='test' and event_object_table='%s' and trigger_name not like 'radon\\_osc\\_%%'", table)
+}
+
+// mockVersion returns the result of the MySQL version.
+func mockVersion(version string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "version", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(version))},
+		},
+	}
+}
+
+// mockThreadsRunning returns the result of the Threads_running status.
+func mockThreadsRunning(running string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Variable_name", Type: querypb.Type_VARCHAR},
+			{Name: "Value", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("Threads_running")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(running)),
+			},
+		},
+	}
+}
+
+func TestProxyDDLOnline(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	wait := onlineDDLThrottleWait
+	onlineDDLThrottleWait = 10 * time.Millisecond
+	defer func() { onlineDDLThrottleWait = wait }()
+
+	// fakedbs.
+	{
+		maxID := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "max(`id`)", Type: querypb.Type_INT64},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("10"))},
+			},
+		}
+		noMaxID := &sqltypes.Result{
+			Fields: maxID.Fields,
+			Rows:   [][]sqltypes.Value{{sqltypes.NULL}},
+		}
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("alter .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("rename .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select column_name from information_schema\\.key_column_usage .*", mockColumns("id"))
+		fakedbs.AddQueryPattern("select max\\(`id`\\) from \\(select `id` from .* where `id` > 10 .*", noMaxID)
+		fakedbs.AddQueryPattern("select max\\(`id`\\) from .*", maxID)
+		fakedbs.AddQuery("show global status like 'threads_running'", mockThreadsRunning("1"))
+		fakedbs.AddQuery("select left(version(), 3) as version", mockVersion("5.7"))
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("create table t1(id int primary key, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	assert.Nil(t, err)
+	for _, segment := range segments {
+		fakedbs.AddQuery(fmt.Sprintf("select column_name from information_schema.columns where table_schema='test' and table_name='%s' order by ordinal_position", segment.Table), mockColumns("id", "b"))
+		fakedbs.AddQuery(fmt.Sprintf("select column_name from information_schema.columns where table_schema='test' and table_name='%s_radon_osc' order by ordinal_position", segment.Table), mockColumns("id", "b", "c"))
+		fakedbs.AddQuery(mockTriggersQuery(segment.Table), mockColumns())
+	}
+	_, err = client.FetchAll("set radon_ddl_online='ON'", -1)
+	assert.Nil(t, err)
+
+	// The job state and the partition states of the last job.
+	lastJob := func() (string, string, map[string]int) {
+		qr, err := client.FetchAll("show ddl jobs", -1)
+		assert.Nil(t, err)
+		row := qr.Rows[len(qr.Rows)-1]
+		qr, err = client.FetchAll(fmt.Sprintf("show ddl job %s", row[0].String()), -1)
+		assert.Nil(t, err)
+		states := make(map[string]int)
+		for _, part := range qr.Rows {
+			states[part[3].String()]++
+		}
+		return row[0].String(), row[4].String(), states
+	}
+
+	// The partitions are altered with the shadow tables.
+	{
+		_, err := client.FetchAll("alter table t1 add column (c int)", -1)
+		assert.Nil(t, err)
+		_, state, states := lastJob()
+		assert.Equal(t, ddlStateDone, state)
+		assert.Equal(t, len(segments), states[ddlStateDone])
+
+		seg := "`test`.`t1_0000`"
+		shadow := "`test`.`t1_0000_radon_osc`"
+		old := "`test`.`t1_0000_radon_old`"
+		querys := []string{
+			fmt.Sprintf("create table %s like %s", shadow, seg),
+			"alter table test.t1_0000_radon_osc add column (\n\t`c` int\n)",
+			fmt.Sprintf("create trigger `test`.`radon_osc_t1_0000_ins` after insert on %s for each row replace into %s(`id`, `b`) values(new.`id`, new.`b`)", seg, shadow),
+			fmt.Sprintf("create trigger `test`.`radon_osc_t1_0000_del` after delete on %s for each row delete ignore from %s where `id` <=> old.`id`", seg, shadow),
+			fmt.Sprintf("insert ignore into %s(`id`, `b`) select `id`, `b` from %s where `id` <= 10 lock in share mode", shadow, seg),
+			fmt.Sprintf("rename table %s to %s, %s to %s", seg, old, shadow, seg),
+		}
+		for _, query := range querys {
+			assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query), query)
+		}
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("alter table `test`.`t1_0000` add column (c int)"))
+	}
+
+	// The partition failed, the shadow table is dropped and the job is resumed.
+	{
+		dropTrigger := "drop trigger if exists `test`.`radon_osc_t1_0003_upd`"
+		dropped := fakedbs.GetQueryCalledNum(dropTrigger)
+		fakedbs.AddQueryErrorPattern("rename table `test`.`t1_0003` .*", errors.New("mock.rename.error"))
+		_, err := client.FetchAll("alter table t1 engine=tokudb", -1)
+		assert.NotNil(t, err)
+		id, state, states := lastJob()
+		assert.Equal(t, ddlStateFailed, state)
+		assert.Equal(t, 1, states[ddlStateFailed])
+		assert.Equal(t, 3, states[ddlStateDone])
+		assert.Equal(t, len(segments)-4, states[ddlStatePending])
+		// Dropped before the copy and after the error.
+		assert.Equal(t, dropped+2, fakedbs.GetQueryCalledNum(dropTrigger))
+
+		fakedbs.ResetPatternErrors()
+		_, err = client.FetchAll(fmt.Sprintf("resume ddl job %s", id), -1)
+		assert.Nil(t, err)
+		_, state, states = lastJob()
+		assert.Equal(t, ddlStateDone, state)
+		assert.Equal(t, len(segments), states[ddlStateDone])
+	}
+
+	// The copy is paused while the backend is busy.
+	{
+		fakedbs.AddQuery("show global status like 'threads_running'", mockThreadsRunning("100"))
+		_, err := client.FetchAll("set radon_ddl_async='ON'", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("alter table t1 modify column b bigint", -1)
+		assert.Nil(t, err)
+		id := qr.Rows[0][0].String()
+
+		time.Sleep(100 * time.Millisecond)
+		_, state, _ := lastJob()
+		assert.Equal(t, ddlStateRunning, state)
+		assert.True(t, fakedbs.GetQueryCalledNum("show global status like 'threads_running'") > 1)
+
+		// Cancelled while throttled.
+		_, err = client.FetchAll(fmt.Sprintf("cancel ddl job %s", id), -1)
+		assert.Nil(t, err)
+		_, state, states := lastJob()
+		assert.Equal(t, ddlStateCancelled, state)
+		assert.Equal(t, len(segments), states[ddlStatePending])
+
+		fakedbs.AddQuery("show global status like 'threads_running'", mockThreadsRunning("1"))
+		_, err = client.FetchAll(fmt.Sprintf("resume ddl job %s", id), -1)
+		assert.Nil(t, err)
+		for i := 0; i < 100; i++ {
+			if _, state, _ = lastJob(); state == ddlStateDone {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		assert.Equal(t, ddlStateDone, state)
+		_, err = client.FetchAll("set radon_ddl_async=false", -1)
+		assert.Nil(t, err)
+	}
+
+	// The table without the single column primary key, no job is created.
+	{
+		_, err := client.FetchAll("create table t2(id int, b int) partition by hash(id)", -1)
+		assert.Nil(t, err)
+		id, _, _ := lastJob()
+		fakedbs.AddQuery("select column_name from information_schema.key_column_usage where table_schema='test' and table_name='t2_0000' and constraint_name='primary' order by ordinal_position", mockColumns())
+		_, err = client.FetchAll("alter table t2 engine=tokudb", -1)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "needs.the.single.column.primary.key"), err.Error())
+		last, _, _ := lastJob()
+		assert.Equal(t, id, last)
+	}
+
+	// The DDL changes the primary key which isn't the shard key, no job is created.
+	{
+		_, err := client.FetchAll("create table t4(id int, b int) partition by hash(b)", -1)
+		assert.Nil(t, err)
+		id, _, _ := lastJob()
+		for _, query := range []string{"alter table t4 drop column id", "alter table t4 modify column id bigint"} {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+			assert.True(t, strings.Contains(err.Error(), "changes.the.primary.key"), err.Error())
+		}
+		last, _, _ := lastJob()
+		assert.Equal(t, id, last)
+	}
+
+	// The table with triggers is refused on MySQL older than 5.7.
+	{
+		fakedbs.AddQuery("select left(version(), 3) as version", mockVersion("5.6"))
+		fakedbs.AddQuery(mockTriggersQuery("t1_0000"), mockColumns("trg1"))
+		_, err := client.FetchAll("alter table t1 engine=innodb", -1)
+		assert.NotNil(t, err)
+		assert.True(t, strings.Contains(err.Error(), "has.triggers[trg1]"), err.Error())
+
+		fakedbs.AddQuery(mockTriggersQuery("t1_0000"), mockColumns())
+		_, err = client.FetchAll("alter table t1 engine=innodb", -1)
+		assert.Nil(t, err)
+		_, state, _ := lastJob()
+		assert.Equal(t, ddlStateDone, state)
+	}
+
+	// The global table isn't altered online.
+	{
+		_, err := client.FetchAll("create table t3(id int, b int) global", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("alter table t3 engine=tokudb", -1)
+		assert.Nil(t, err)
+		assert.True(t, fakedbs.GetQueryCalledNum("alter table `test`.`t3` engine=tokudb") > 0)
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("create table `test`.`t3_radon_osc` like `test`.`t3`"))
+	}
+}
+
+func TestProxyDDLOnlineCheckColumns(t *testing.T) {
+	querys := map[string]string{
+		"alter table t1 engine=tokudb":                  "",
+		"alter table t1 add column (c int)":             "",
+		"alter table t1 drop column id":                 "changes.the.primary.key",
+		"alter table t1 modify column id bigint":        "changes.the.primary.key",
+		"alter table t1 add column (d int unique)":      "adds.the.unique.key[d]",
+		"alter table t1 modify column b int unique key": "adds.the.unique.key[b]",
+	}
+	for query, want := range querys {
+		node, err := sqlparser.Parse(query)
+		assert.Nil(t, err)
+		o := &onlineAlter{node: node.(*sqlparser.DDL), table: "t1"}
+		err = o.checkColumns("id")
+		if want == "" {
+			assert.Nil(t, err, query)
+			continue
+		}
+		assert.NotNil(t, err, query)
+		assert.True(t, strings.Contains(err.Error(), want), err.Error())
+	}
+}
@@ -0,0 +1,125 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"container/list"
+	"fmt"
+	"sync"
+
+	"planner"
+	"xbase/sync2"
+)
+
+// planEntry is the cached plan of the parameterized query, the plan is nil if the query
+// cannot be cached, so the query will not be planned twice to know it.
+type planEntry struct {
+	key  string
+	plan *planner.SelectPlan
+}
+
+// PlanCache is a LRU cache of the select plans, keyed by the database, router version and
+// the parameterized query text. All the plans are purged if the router version changes.
+type PlanCache struct {
+	mu       sync.Mutex
+	capacity int
+	version  uint64
+	lru      *list.List
+	entries  map[string]*list.Element
+	hits     sync2.AtomicInt64
+	misses   sync2.AtomicInt64
+}
+
+// PlanCacheStatus is the status of the plan cache shown in 'SHOW STATUS'.
+type PlanCacheStatus struct {
+	Hits     int64
+	Misses   int64
+	Size     int
+	Capacity int
+}
+
+// NewPlanCache creates the new plan cache.
+func NewPlanCache(capacity int) *PlanCache {
+	return &PlanCache{
+		capacity: capacity,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}
+}
+
+func planCacheKey(database string, version uint64, query string) string {
+	return fmt.Sprintf("%s:%d:%s", database, version, query)
+}
+
+// get returns the entry of the key, the cache is purged if the router version changed.
+func (pc *PlanCache) get(database string, version uint64, query string) (*planEntry, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.version != version {
+		pc.purge()
+		pc.version = version
+	}
+	elem, ok := pc.entries[planCacheKey(database, version, query)]
+	if !ok {
+		return nil, false
+	}
+	pc.lru.MoveToFront(elem)
+	return elem.Value.(*planEntry), true
+}
+
+// set used to add the plan to the cache, the least recently used one is evicted if full.
+func (pc *PlanCache) set(database string, version uint64, query string, plan *planner.SelectPlan) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	if pc.version != version {
+		return
+	}
+	key := planCacheKey(database, version, query)
+	if elem, ok := pc.entries[key]; ok {
+		elem.Value.(*planEntry).plan = plan
+		pc.lru.MoveToFront(elem)
+		return
+	}
+	pc.entries[key] = pc.lru.PushFront(&planEntry{key: key, plan: plan})
+	for pc.lru.Len() > pc.capacity {
+		elem := pc.lru.Back()
+		pc.lru.Remove(elem)
+		delete(pc.entries, elem.Value.(*planEntry).key)
+	}
+}
+
+// Clear used to purge all the plans, such as after the DDL.
+func (pc *PlanCache) Clear() {
+	if pc == nil {
+		return
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	pc.purge()
+}
+
+func (pc *PlanCache) purge() {
+	pc.lru.Init()
+	pc.entries = make(map[string]*list.Element)
+}
+
+// Status returns the status of the plan cache.
+func (pc *PlanCache) Status() *PlanCacheStatus {
+	status := &PlanCacheStatus{}
+	if pc == nil {
+		return status
+	}
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+	status.Hits = pc.hits.Get()
+	status.Misses = pc.misses.Get()
+	status.Size = pc.lru.Len()
+	status.Capacity = pc.capacity
+	return status
+}
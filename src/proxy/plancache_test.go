@@ -0,0 +1,163 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+	"planner"
+
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestPlanCache(t *testing.T) {
+	pc := NewPlanCache(2)
+	plan := &planner.SelectPlan{}
+
+	_, ok := pc.get("db", 1, "q1")
+	assert.False(t, ok)
+	pc.set("db", 1, "q1", plan)
+	pc.set("db", 1, "q2", nil)
+	entry, ok := pc.get("db", 1, "q1")
+	assert.True(t, ok)
+	assert.Equal(t, plan, entry.plan)
+
+	// q2 is evicted.
+	pc.set("db", 1, "q3", plan)
+	_, ok = pc.get("db", 1, "q2")
+	assert.False(t, ok)
+	_, ok = pc.get("db1", 1, "q1")
+	assert.False(t, ok)
+	assert.Equal(t, 2, pc.Status().Size)
+
+	// Router version changed.
+	_, ok = pc.get("db", 2, "q1")
+	assert.False(t, ok)
+	assert.Equal(t, 0, pc.Status().Size)
+	pc.set("db", 1, "q1", plan)
+	assert.Equal(t, 0, pc.Status().Size)
+
+	pc.set("db", 2, "q1", plan)
+	assert.Equal(t, 1, pc.Status().Size)
+	pc.Clear()
+	assert.Equal(t, 0, pc.Status().Size)
+
+	// Disabled.
+	var nilpc *PlanCache
+	nilpc.Clear()
+	assert.Equal(t, &PlanCacheStatus{}, nilpc.Status())
+}
+
+func TestProxyPlanCache(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("alter .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .*", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "b", Type: querypb.Type_INT32}},
+		})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	querys := []string{
+		"select b from t1 where id = 1",
+		"select b from t1 where id = 2",
+		"select b from t1 where id = 3 and b = 'x'",
+		"select b from t1 where id = 4 and b = 'y'",
+		"select t1.b from t1 join t1 as t2 on t1.b = t2.b where t1.id = 1",
+		"select t1.b from t1 join t1 as t2 on t1.b = t2.b where t1.id = 2",
+	}
+	for _, query := range querys {
+		_, err = client.FetchAll(query, -1)
+		assert.Nil(t, err)
+	}
+	want := &PlanCacheStatus{Hits: 2, Misses: 3, Size: 3, Capacity: 1024}
+	assert.Equal(t, want, proxy.spanner.plans.Status())
+
+	qr, err := client.FetchAll("show status", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "radon_plancache", string(qr.Rows[5][0].Raw()))
+	assert.Equal(t, `{"Hits":2,"Misses":3,"Size":3,"Capacity":1024}`, string(qr.Rows[5][1].Raw()))
+
+	// DDL.
+	_, err = client.FetchAll("alter table t1 engine=tokudb", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, proxy.spanner.plans.Status().Size)
+}
+
+func TestProxyPlanCacheHitSkipBuild(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	builds := 0
+	defer func(build func(plan *planner.SelectPlan) error) {
+		buildSelectPlan = build
+	}(buildSelectPlan)
+	buildSelectPlan = func(plan *planner.SelectPlan) error {
+		builds++
+		return plan.Build()
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select b from test.t1_.* as t1 where id = 1", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "b", Type: querypb.Type_INT32}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("11"))}},
+		})
+		fakedbs.AddQueryPattern("select b from test.t1_.* as t1 where id = 2", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "b", Type: querypb.Type_INT32}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("22"))}},
+		})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Quit()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	qr, err := client.FetchAll("select b from t1 where id = 1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "11", qr.Rows[0][0].String())
+	assert.Equal(t, 1, builds)
+
+	// The plan is bound by the values of the hit, not built again.
+	qr, err = client.FetchAll("select b from t1 where id = 2", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "22", qr.Rows[0][0].String())
+	assert.Equal(t, 1, builds)
+	assert.Equal(t, int64(1), proxy.spanner.plans.Status().Hits)
+}
@@ -0,0 +1,350 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"executor"
+	"forks/go-mysqlstack/driver"
+	"optimizer"
+	"planner"
+	"xbase"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+// preparedStmt is kept by the prepared statement, the params of the query are the args ':v1', ':v2'...
+type preparedStmt struct {
+	query string
+	// tableSelect is true if the statement is a select on the tables, whose params are bound into the plan.
+	tableSelect bool
+}
+
+// ComStmtPrepare impl.
+// Here, the params of the statement are counted and typed by the columns they're compared with, and
+// the result fields of the select are got by the query whose where is impossible, so the statement
+// isn't executed and nothing is changed, such as the values of the sequences and the FOUND_ROWS().
+func (spanner *Spanner) ComStmtPrepare(session *driver.Session, stmt *driver.Statement) error {
+	log := spanner.log
+	query := strings.TrimSuffix(strings.TrimSpace(stmt.PrepareStmt), ";")
+
+	// Support for JDBC/Others driver.
+	if spanner.isConnectorFilter(query) || isSequenceDDL(query) {
+		return nil
+	}
+	query, calcFoundRows := stripCalcFoundRows(rewriteSequenceSyntax(query))
+
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		log.Error("prepare[%v].parser.error: %v", query, err)
+		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	params := sqlparser.GetBindvars(node)
+	if len(params) > 0xffff {
+		return sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "prepared.statement.contains.too.many.placeholders")
+	}
+	stmt.ParamCount = uint16(len(params))
+
+	prepared := &preparedStmt{query: query}
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		if spanner.isTableSelect(node) {
+			// The plan is checked, the unsupported select fails at the prepare.
+			plan := planner.NewSelectPlan(log, session.Schema(), query, node, spanner.router)
+			if err := plan.Build(); err != nil {
+				return err
+			}
+			prepared.tableSelect = !calcFoundRows
+		}
+		if stmt.Fields, err = spanner.prepareFields(session, query); err != nil {
+			return err
+		}
+	case *sqlparser.Union:
+		if stmt.Fields, err = spanner.prepareFields(session, query); err != nil {
+			return err
+		}
+	}
+	stmt.Params = spanner.prepareParams(session, query, int(stmt.ParamCount))
+	stmt.Prepared = prepared
+	return nil
+}
+
+// ComStmtExecute impl.
+// The params of the select on the tables are bound into the plan by their types, the others are
+// bound into the parsed statement by the ComQuery.
+func (spanner *Spanner) ComStmtExecute(session *driver.Session, stmt *driver.Statement, callback func(qr *sqltypes.Result) error) error {
+	var err error
+	var qr *sqltypes.Result
+	log := spanner.log
+	bindVars := sqltypes.CopyBindVariables(stmt.BindVars)
+
+	prepared, ok := stmt.Prepared.(*preparedStmt)
+	if !ok || !prepared.tableSelect || spanner.sessions.getTxnSession(session).getStreamingFetchVar() {
+		return spanner.ComQuery(session, stmt.PrepareStmt, bindVars, callback)
+	}
+
+	timeStart := time.Now()
+	slowQueryTime := time.Duration(spanner.conf.Proxy.LongQueryTime) * time.Second
+	spanner.throttle.Acquire()
+	defer spanner.throttle.Release()
+	if spanner.diskChecker.HighWater() {
+		return sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "%s", "no space left on device")
+	}
+
+	node, err := sqlparser.Parse(prepared.query)
+	if err != nil {
+		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	// The query with the values is used by the audit and the processlist.
+	query, err := sqlparser.NewParsedQuery(node).GenerateQuery(bindVars, nil)
+	if err != nil {
+		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+	defer func() {
+		queryStat(node, timeStart, slowQueryTime, err)
+	}()
+
+	if qr, err = spanner.executeDML(session, session.Schema(), query, node, &execOpts{bindVars: bindVars}); err != nil {
+		log.Error("proxy.stmt.execute[%s].from.session[%v].error:%+v", query, session.ID(), err)
+	} else {
+		spanner.trackSelect(session, len(qr.Rows))
+	}
+	spanner.auditLog(session, R, xbase.SELECT, query, qr)
+	return returnQuery(qr, callback, err)
+}
+
+// isTableSelect returns true if the select is on the tables of the router, not the dual,
+// the system databases or the sequences, which are answered by the proxy.
+func (spanner *Spanner) isTableSelect(node *sqlparser.Select) bool {
+	aliased, ok := node.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return true
+	}
+	tb, ok := aliased.Expr.(sqlparser.TableName)
+	if !ok {
+		return true
+	}
+	if _, ok := node.SelectExprs[0].(sqlparser.Nextval); ok {
+		return false
+	}
+	return tb.Name.String() != "dual" && !spanner.router.IsSystemDB(tb.Qualifier.String())
+}
+
+// impossibleWhere returns the where which is always false.
+func impossibleWhere() *sqlparser.Where {
+	return sqlparser.NewWhere(sqlparser.WhereStr, &sqlparser.ComparisonExpr{
+		Operator: sqlparser.NotEqualStr,
+		Left:     sqlparser.NewIntVal([]byte("1")),
+		Right:    sqlparser.NewIntVal([]byte("1")),
+	})
+}
+
+// prepareFields returns the fields of the select or union query, the where of each select is
+// replaced by the impossible one, and the NEXTVAL() by 0, so the backends return no rows.
+func (spanner *Spanner) prepareFields(session *driver.Session, query string) ([]*querypb.Field, error) {
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+	}
+
+	var impossible func(node sqlparser.SelectStatement)
+	impossible = func(node sqlparser.SelectStatement) {
+		switch node := node.(type) {
+		case *sqlparser.Select:
+			node.Where = impossibleWhere()
+			node.Having = nil
+			node.OrderBy = nil
+			node.Limit = nil
+			for _, expr := range node.SelectExprs {
+				if aliased, ok := expr.(*sqlparser.AliasedExpr); ok {
+					if _, ok := nextvalName(aliased.Expr); ok {
+						if aliased.As.IsEmpty() {
+							aliased.As = sqlparser.NewColIdent(sqlparser.String(aliased.Expr))
+						}
+						aliased.Expr = sqlparser.NewIntVal([]byte("0"))
+					}
+				}
+			}
+		case *sqlparser.ParenSelect:
+			impossible(node.Select)
+		case *sqlparser.Union:
+			impossible(node.Left)
+			impossible(node.Right)
+			node.OrderBy = nil
+			node.Limit = nil
+		}
+	}
+
+	sel, ok := node.(*sqlparser.Select)
+	switch {
+	case ok && !spanner.isTableSelect(sel):
+		if _, ok := sel.SelectExprs[0].(sqlparser.Nextval); ok {
+			return []*querypb.Field{{Name: "nextval", Type: querypb.Type_UINT64}}, nil
+		}
+		impossible(sel)
+		spanner.rewriteSessionFuncs(session, sel)
+		if err := bindNullArgs(&node); err != nil {
+			return nil, err
+		}
+		qr, err := spanner.ExecuteSingleWithSession(session, sqlparser.String(node))
+		if err != nil {
+			return nil, err
+		}
+		return qr.Fields, nil
+	default:
+		impossible(node.(sqlparser.SelectStatement))
+		return spanner.fetchImpossibleFields(session, node)
+	}
+}
+
+// prepareParams returns the fields of the params, the param compared with or assigned to a column
+// has the type of the column, the others are sent as VARBINARY.
+// The query is parsed again, because the plan rewrites the tables of the parsed one.
+func (spanner *Spanner) prepareParams(session *driver.Session, query string, count int) []*querypb.Field {
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	var cols sqlparser.SelectExprs
+	addParam := func(col *sqlparser.ColName, expr sqlparser.Expr) {
+		if val, ok := expr.(*sqlparser.SQLVal); ok && val.Type == sqlparser.ValArg {
+			names = append(names, string(val.Val[1:]))
+			cols = append(cols, &sqlparser.AliasedExpr{Expr: col})
+		}
+	}
+	visit := func(node sqlparser.SQLNode) (bool, error) {
+		switch node := node.(type) {
+		case *sqlparser.Subquery:
+			// The columns of the subquery are not in the from.
+			return false, nil
+		case *sqlparser.ComparisonExpr:
+			if col, ok := node.Left.(*sqlparser.ColName); ok {
+				if tuple, ok := node.Right.(sqlparser.ValTuple); ok {
+					for _, expr := range tuple {
+						addParam(col, expr)
+					}
+				}
+				addParam(col, node.Right)
+			}
+			if col, ok := node.Right.(*sqlparser.ColName); ok {
+				addParam(col, node.Left)
+			}
+		case *sqlparser.RangeCond:
+			if col, ok := node.Left.(*sqlparser.ColName); ok {
+				addParam(col, node.From)
+				addParam(col, node.To)
+			}
+		case *sqlparser.UpdateExpr:
+			addParam(node.Name, node.Expr)
+		}
+		return true, nil
+	}
+
+	var from sqlparser.TableExprs
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		from = node.From
+		_ = sqlparser.Walk(visit, node.Where)
+	case *sqlparser.Insert:
+		from = sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: node.Table}}
+		if rows, ok := node.Rows.(sqlparser.Values); ok {
+			for _, row := range rows {
+				for i, expr := range row {
+					if i < len(node.Columns) {
+						addParam(&sqlparser.ColName{Name: node.Columns[i]}, expr)
+					}
+				}
+			}
+		}
+	case *sqlparser.Update:
+		from = sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: node.Table}}
+		_ = sqlparser.Walk(visit, node.Exprs, node.Where)
+	case *sqlparser.Delete:
+		from = sqlparser.TableExprs{&sqlparser.AliasedTableExpr{Expr: node.Table}}
+		_ = sqlparser.Walk(visit, node.Where)
+	}
+	if len(cols) == 0 {
+		return nil
+	}
+
+	sel := &sqlparser.Select{SelectExprs: cols, From: from, Where: impossibleWhere()}
+	fields, err := spanner.fetchImpossibleFields(session, sel)
+	if err != nil || len(fields) != len(cols) {
+		spanner.log.Warning("prepare.params.of[%s].are.untyped.error:%v", query, err)
+		return nil
+	}
+
+	params := make([]*querypb.Field, count)
+	for i := range params {
+		params[i] = &querypb.Field{Name: "?", Type: sqltypes.VarBinary, Charset: 63}
+	}
+	for i, name := range names {
+		idx, err := strconv.Atoi(strings.TrimPrefix(name, "v"))
+		if err != nil || idx < 1 || idx > count {
+			continue
+		}
+		field := fields[i]
+		params[idx-1] = &querypb.Field{
+			Name:         "?",
+			Type:         field.Type,
+			Charset:      field.Charset,
+			ColumnLength: field.ColumnLength,
+			Decimals:     field.Decimals,
+			Flags:        field.Flags,
+		}
+	}
+	return params
+}
+
+// fetchImpossibleFields used to execute the select whose where is impossible by the plan,
+// and returns the fields.
+func (spanner *Spanner) fetchImpossibleFields(session *driver.Session, node sqlparser.Statement) ([]*querypb.Field, error) {
+	log := spanner.log
+	database := session.Schema()
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if err := privilegePlug.Check(database, session.User(), node); err != nil {
+		return nil, err
+	}
+	if err := bindNullArgs(&node); err != nil {
+		return nil, err
+	}
+
+	txn, err := spanner.createTransaction(session, spanner.conf.Proxy.QueryTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+
+	query := sqlparser.String(node)
+	plans, err := optimizer.NewSimpleOptimizer(log, database, query, node, spanner.router).BuildPlanTree()
+	if err != nil {
+		return nil, err
+	}
+	qr, err := executor.NewTree(log, plans, txn).Execute()
+	if err != nil {
+		return nil, err
+	}
+	return qr.Fields, nil
+}
+
+// bindNullArgs used to bind all the args of the node by NULL.
+func bindNullArgs(node *sqlparser.Statement) error {
+	bindVars := make(map[string]*querypb.BindVariable)
+	for name := range sqlparser.GetBindvars(*node) {
+		bindVars[name] = sqltypes.NullBindVariable
+	}
+	return bindArgs(node, bindVars)
+}
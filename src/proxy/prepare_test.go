@@ -0,0 +1,140 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyPrepare(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT32},
+		{Name: "name", Type: querypb.Type_VARCHAR},
+	}
+	result1 := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT32, []byte("10")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("name1")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select id, name from test.t1_.* where 1 != 1", &sqltypes.Result{Fields: fields})
+		fakedbs.AddQuery("select id, name from test.t1_0021 as t1 where id = 10 and name = 'name1'", result1)
+		fakedbs.AddQuery("insert into test.t1_0021(id, name) values (10, 'name1')", &sqltypes.Result{RowsAffected: 1})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id int, name varchar(20)) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	// Select.
+	{
+		stmt, err := client.ComStatementPrepare("select id, name from t1 where id=? and name=?")
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(2), stmt.ParamCount)
+		assert.Equal(t, []string{"id", "name"}, stmt.ColumnNames)
+		assert.Equal(t, 2, len(stmt.Params))
+		assert.Equal(t, querypb.Type_INT32, stmt.Params[0].Type)
+		assert.Equal(t, querypb.Type_VARCHAR, stmt.Params[1].Type)
+		// The prepare must not run the statement itself.
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum("select id, name from test.t1_0021 as t1 where id = 10 and name = 'name1'"))
+
+		params := []sqltypes.Value{
+			sqltypes.NewInt32(10),
+			sqltypes.NewVarChar("name1"),
+		}
+		qr, err := stmt.ComStatementQuery(params)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[10 name1]]", fmt.Sprintf("%v", qr.Rows))
+
+		// Execute again with the long data.
+		err = stmt.ComStatementSendLongData(1, []byte("na"))
+		assert.Nil(t, err)
+		err = stmt.ComStatementSendLongData(1, []byte("me1"))
+		assert.Nil(t, err)
+		params[1] = sqltypes.NewVarChar("xx")
+		qr, err = stmt.ComStatementQuery(params)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[10 name1]]", fmt.Sprintf("%v", qr.Rows))
+
+		// The long data is cleared by the reset.
+		err = stmt.ComStatementSendLongData(1, []byte("xx"))
+		assert.Nil(t, err)
+		err = stmt.ComStatementReset()
+		assert.Nil(t, err)
+		params[1] = sqltypes.NewVarChar("name1")
+		qr, err = stmt.ComStatementQuery(params)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[10 name1]]", fmt.Sprintf("%v", qr.Rows))
+
+		// Closed.
+		err = stmt.ComStatementClose()
+		assert.Nil(t, err)
+		_, err = stmt.ComStatementQuery(params)
+		assert.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Unknown prepared statement handler")
+	}
+
+	// Insert.
+	{
+		stmt, err := client.ComStatementPrepare("insert into t1(id, name) values(?, ?)")
+		assert.Nil(t, err)
+		assert.Equal(t, uint16(2), stmt.ParamCount)
+		assert.Nil(t, stmt.Fields)
+		assert.Equal(t, 2, len(stmt.Params))
+		assert.Equal(t, querypb.Type_INT32, stmt.Params[0].Type)
+
+		params := []sqltypes.Value{
+			sqltypes.NewInt64(10),
+			sqltypes.NewVarChar("name1"),
+		}
+		qr, err := stmt.ComStatementQuery(params)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), qr.RowsAffected)
+		stmt.ComStatementClose()
+	}
+
+	// Syntax error.
+	{
+		_, err := client.ComStatementPrepare("select from t1 where id=?")
+		assert.NotNil(t, err)
+	}
+
+	// Unsupported.
+	{
+		_, err := client.ComStatementPrepare("select id, name from t1 where id in (select id from t2) and name=?")
+		assert.NotNil(t, err)
+	}
+}
@@ -14,12 +14,12 @@ import (
 	"audit"
 	"backend"
 	"config"
+	"forks/go-mysqlstack/driver"
 	"plugins"
 	"router"
 	"syncer"
 	"xbase"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -197,6 +197,14 @@ func (p *Proxy) SetMaxJoinRows(size int) {
 	p.conf.Proxy.MaxJoinRows = size
 }
 
+// SetMaxQueryMemory used to set the per-query memory budget before spilling to disk.
+func (p *Proxy) SetMaxQueryMemory(size int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.log.Info("proxy.SetMaxQueryMemory:[%d->%d]", p.conf.Proxy.MaxQueryMemory, size)
+	p.conf.Proxy.MaxQueryMemory = size
+}
+
 // SetDDLTimeout used to set the ddl timeout.
 func (p *Proxy) SetDDLTimeout(timeout int) {
 	p.mu.Lock()
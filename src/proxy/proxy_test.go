@@ -45,6 +45,12 @@ func TestProxy1(t *testing.T) {
 		assert.Equal(t, 6666, proxy.conf.Proxy.MaxJoinRows)
 	}
 
+	// SetMaxQueryMemory
+	{
+		proxy.SetMaxQueryMemory(6666)
+		assert.Equal(t, 6666, proxy.conf.Proxy.MaxQueryMemory)
+	}
+
 	// SetDDLTimeout
 	{
 		proxy.SetDDLTimeout(6666)
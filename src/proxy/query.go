@@ -12,10 +12,11 @@ import (
 	"strings"
 	"time"
 
+	"forks/go-mysqlstack/driver"
 	"monitor"
+	"planner"
 	"xbase"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 
@@ -53,10 +54,19 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		return sqldb.NewSQLErrorf(sqldb.ER_UNKNOWN_ERROR, "%s", "no space left on device")
 	}
 
+	// The SET NAMES is tracked by the session.
+	if charset, collation, ok := parseSetNames(query); ok {
+		qr, err := spanner.handleSetNames(session, charset, collation)
+		spanner.auditLog(session, R, xbase.SET, query, qr)
+		return returnQuery(qr, callback, err)
+	}
+
 	// Support for JDBC/Others driver.
 	if spanner.isConnectorFilter(query) {
 		qr, err := spanner.handleJDBCShows(session, query, nil)
-		qr.Warnings = 1
+		if err == nil {
+			qr.Warnings = 1
+		}
 		return returnQuery(qr, callback, err)
 	}
 
@@ -64,7 +74,54 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	query = strings.TrimSpace(query)
 	query = strings.TrimSuffix(query, ";")
 
+	// The sequence DDLs are handled by the proxy.
+	if isSequenceDDL(query) {
+		if spanner.ReadOnly() {
+			return sqldb.NewSQLError(sqldb.ER_OPTION_PREVENTS_STATEMENT, "--read-only")
+		}
+		qr, err := spanner.handleSequenceDDL(session, query)
+		if err != nil {
+			log.Error("proxy.sequence.DDL[%s].from.session[%v].error:%+v", query, session.ID(), err)
+		}
+		spanner.auditLog(session, W, xbase.DDL, query, qr)
+		return returnQuery(qr, callback, err)
+	}
+	query = rewriteSequenceSyntax(query)
+
+	// The statements of the DDL jobs are handled by the proxy.
+	if isDDLJobStmt(query) {
+		qr, err := spanner.handleDDLJobStmt(session, query)
+		if err != nil {
+			log.Error("proxy.ddl.job[%s].from.session[%v].error:%+v", query, session.ID(), err)
+		}
+		return returnQuery(qr, callback, err)
+	}
+	if isSchemaCheckStmt(query) {
+		qr, err := spanner.handleSchemaCheckStmt(session, query)
+		if err != nil {
+			log.Error("proxy.schema.check[%s].from.session[%v].error:%+v", query, session.ID(), err)
+		}
+		return returnQuery(qr, callback, err)
+	}
+	if isShardInfoStmt(query) {
+		qr, err := spanner.handleShardInfoStmt(session, query)
+		if err != nil {
+			log.Error("proxy.shard.info[%s].from.session[%v].error:%+v", query, session.ID(), err)
+		}
+		spanner.auditLog(session, R, xbase.SHOW, query, qr)
+		return returnQuery(qr, callback, err)
+	}
+
+	// SQL_CALC_FOUND_ROWS is handled by the proxy.
+	query, calcFoundRows := stripCalcFoundRows(query)
+
 	node, err := sqlparser.Parse(query)
+	if err != nil {
+		// The LIKE and WHERE of the SHOW statements are evaluated by the proxy.
+		if show, serr := parseShowWithFilter(query); serr == nil {
+			node, err = show, nil
+		}
+	}
 	if err != nil {
 		log.Error("query[%v].parser.error: %v", query, err)
 		return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
@@ -79,11 +136,13 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
 		}
 
-		// This sucks.
-		node, err = sqlparser.Parse(query)
-		if err != nil {
-			log.Error("query[%v].parser.error: %v", query, err)
-			return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+		// The values are bound to the node directly, re-parse the query only if it fails.
+		if !planner.BindLiterals(node, bindVariables) {
+			node, err = sqlparser.Parse(query)
+			if err != nil {
+				log.Error("query[%v].parser.error: %v", query, err)
+				return sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+			}
 		}
 	}
 	log.Debug("query:%v", query)
@@ -103,6 +162,12 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 	defer func() {
 		queryStat(node, timeStart, slowQueryTime, err)
 	}()
+
+	// The multi-statement txn is begun implicitly if the autocommit is off.
+	if err = spanner.beginImplicitTxn(session, node); err != nil {
+		log.Error("proxy.query[%s].begin.implicit.txn.error:%+v", query, err)
+		return err
+	}
 	switch node := node.(type) {
 	case *sqlparser.Use:
 		if qr, err = spanner.handleUseDB(session, query, node); err != nil {
@@ -114,6 +179,8 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 		if qr, err = spanner.handleDDL(session, query, node); err != nil {
 			log.Error("proxy.DDL[%s].from.session[%v].error:%+v", query, session.ID(), err)
 		}
+		// The plans may be changed by the DDL.
+		spanner.plans.Clear()
 		spanner.auditLog(session, W, xbase.DDL, query, qr)
 		return returnQuery(qr, callback, err)
 	case *sqlparser.Show:
@@ -136,8 +203,7 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 				log.Error("proxy.show.engines[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			}
 		case sqlparser.ShowTablesStr, sqlparser.ShowFullTablesStr:
-			// Support for SHOW FULL TBALES which can be parsed used by Navicat
-			// TODO: need to support: SHOW [FULL] TABLES [FROM db_name] [like_or_where]
+			// Support for SHOW [FULL] TABLES [FROM db_name] [like_or_where] used by Navicat.
 			if qr, err = spanner.handleShowTables(session, query, node); err != nil {
 				log.Error("proxy.show.tables[%s].from.session[%v].error:%+v", query, session.ID(), err)
 			}
@@ -213,6 +279,12 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 				return err
 			}
 			return nil
+		} else if calcFoundRows {
+			if qr, err = spanner.handleSelectCalcFoundRows(session, query, node); err != nil {
+				log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
+			}
+			spanner.auditLog(session, R, xbase.SELECT, query, qr)
+			return returnQuery(qr, callback, err)
 		} else {
 			switch node.From[0].(type) {
 			case *sqlparser.AliasedTableExpr:
@@ -224,9 +296,14 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 						log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
 					}
 				} else {
-					if tb.Name.String() == "dual" {
+					if _, ok := node.SelectExprs[0].(sqlparser.Nextval); ok {
+						// Select next n values from sequence.
+						if qr, err = spanner.handleSelectNextval(session, node); err != nil {
+							log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
+						}
+					} else if tb.Name.String() == "dual" {
 						// Select 1.
-						if qr, err = spanner.ExecuteSingle(query); err != nil {
+						if qr, err = spanner.handleSelectDual(session, query, node); err != nil {
 							log.Error("proxy.select[%s].from.session[%v].error:%+v", query, session.ID(), err)
 						}
 					} else if spanner.router.IsSystemDB(tb.Qualifier.String()) {
@@ -252,7 +329,20 @@ func (spanner *Spanner) ComQuery(session *driver.Session, query string, bindVari
 			}
 		}
 	case *sqlparser.Union:
-		if qr, err = spanner.handleSelect(session, query, node); err != nil {
+		txSession := spanner.sessions.getTxnSession(session)
+		if txSession.getStreamingFetchVar() {
+			if err = spanner.handleSelectStream(session, query, node, callback); err != nil {
+				log.Error("proxy.union.for.backup:[%s].error:%+v", xbase.TruncateQuery(query, 256), err)
+				return err
+			}
+			return nil
+		}
+		if calcFoundRows {
+			qr, err = spanner.handleSelectCalcFoundRows(session, query, node)
+		} else {
+			qr, err = spanner.handleSelect(session, query, node)
+		}
+		if err != nil {
 			log.Error("proxy.union[%s].from.session[%v].error:%+v", query, session.ID(), err)
 		}
 		spanner.auditLog(session, W, xbase.UPDATE, query, qr)
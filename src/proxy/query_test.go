@@ -10,10 +10,12 @@ package proxy
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -276,6 +278,27 @@ func TestProxyQuerys(t *testing.T) {
 			got := int(qr.RowsAffected)
 			assert.Equal(t, want, got)
 		}
+		{
+			query := "set @@SESSION.radon_streaming_fetch='ON'"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+
+			querys := []string{
+				"select 11,'1nice name' union select a,b from test.t1",
+				"select 11,'1nice name' union all select a,b from test.t1",
+			}
+			wants := []int{1, 62527}
+			for i, query := range querys {
+				qr, err := client.FetchAll(query, -1)
+				assert.Nil(t, err)
+				got := int(qr.RowsAffected)
+				assert.Equal(t, wants[i], got)
+			}
+
+			query = "set @@SESSION.radon_streaming_fetch='OFF'"
+			_, err = client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
 	}
 
 	// select * from systemdatabase.table
@@ -349,6 +372,7 @@ func TestProxyQueryStmtPrepare(t *testing.T) {
 		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
 		fakedbs.AddQuery("insert into test.t1_0021(id, name) values (10, 'name1')", result11)
 		fakedbs.AddQuery("select * from test.t1_0021 as t1 where id = 10 and name = 'name1'", result11)
+		fakedbs.AddQueryPattern("select .* where 1 != 1", &sqltypes.Result{})
 	}
 
 	// create database.
@@ -459,6 +483,14 @@ func TestProxyQuerySystemDatabase(t *testing.T) {
 		assert.Nil(t, err)
 	}
 
+	// The columns of the first partition.
+	{
+		fields := mockInfoSchemaFields("TABLE_SCHEMA", "TABLE_NAME", "COLUMN_NAME", "ORDINAL_POSITION#", "IS_NULLABLE", "DATA_TYPE", "COLUMN_TYPE", "CHARACTER_MAXIMUM_LENGTH#")
+		mockInfoSchema(fakedbs, proxy.Router(), "columns", false, fields, "test", []string{"t1"}, func(part string) [][]sqltypes.Value {
+			return [][]sqltypes.Value{mockInfoSchemaRow(fields, "test", part, "id", "1", "YES", "int", "int(11)", "NULL")}
+		})
+	}
+
 	// select * from information_schema.COLUMNS where TABLE_NAME='t1' and TABLE_SCHEMA='test'
 	{
 		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
@@ -639,3 +671,177 @@ func TestProxyQueryTxnUnion_428(t *testing.T) {
 		}
 	}
 }
+
+func TestProxyQuerySessionFuncs(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	rows := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "id", Type: querypb.Type_INT32},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{RowsAffected: 1})
+		fakedbs.AddQueryPattern("delete .*", &sqltypes.Result{RowsAffected: 1})
+		fakedbs.AddQuery("select id from test.s1", rows)
+		fakedbs.AddQuery("select /* x */ distinct id from test.s1", rows)
+		fakedbs.AddQuery("select sql_calc_found_rows id from test.s1 limit 1, 1", &sqltypes.Result{Fields: rows.Fields, Rows: rows.Rows[1:2]})
+		fakedbs.AddQuery("select sql_calc_found_rows /* x */ distinct id from test.s1 limit 5", rows)
+		fakedbs.AddQuery("SELECT FOUND_ROWS()", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "FOUND_ROWS()", Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("3"))}},
+		})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id bigint not null auto_increment, b int, primary key(id)) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table s1(id int) single", -1)
+	assert.Nil(t, err)
+
+	selectFuncs := func(query string, want string) {
+		fakedbs.AddQuery(want, &sqltypes.Result{})
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err, want)
+	}
+
+	// The id generated by the proxy.
+	qr, err := client.FetchAll("insert into t1(b) values(1), (2), (3)", -1)
+	assert.Nil(t, err)
+	insertID := qr.InsertID
+	assert.NotEqual(t, uint64(0), insertID)
+	selectFuncs("select last_insert_id(), row_count()", fmt.Sprintf("select %d as `last_insert_id()`, %d as `row_count()` from dual", insertID, qr.RowsAffected))
+
+	// No id generated, the LAST_INSERT_ID() is unchanged.
+	qr, err = client.FetchAll("insert into t1(id, b) values(100, 1)", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), qr.InsertID)
+	selectFuncs("select LAST_INSERT_ID() as id, 1", fmt.Sprintf("select %d as id, 1 from dual", insertID))
+
+	// Delete.
+	_, err = client.FetchAll("delete from t1 where id=100", -1)
+	assert.Nil(t, err)
+	selectFuncs("select row_count()", "select 1 as `row_count()` from dual")
+
+	// Found rows.
+	{
+		qr, err = client.FetchAll("select id from s1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(qr.Rows))
+		selectFuncs("select found_rows(), row_count()", "select 3 as `found_rows()`, -1 as `row_count()` from dual")
+
+		qr, err = client.FetchAll("select sql_calc_found_rows id from s1 limit 1, 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[2]]", fmt.Sprintf("%v", qr.Rows))
+		selectFuncs("select found_rows()", "select 3 as `found_rows()` from dual")
+
+		qr, err = client.FetchAll("SELECT /* x */ DISTINCT SQL_CALC_FOUND_ROWS id from s1 limit 5", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 3, len(qr.Rows))
+		selectFuncs("select found_rows()", "select 3 as `found_rows()` from dual")
+	}
+}
+
+func TestProxyQueryCalcFoundRows(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	fields := []*querypb.Field{
+		{Name: "id", Type: querypb.Type_INT32},
+	}
+	rows := &sqltypes.Result{
+		Fields: fields,
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2"))},
+			{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("3"))},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select sql_calc_found_rows id from test.t1_.* as t1 order by id asc limit 3", rows)
+		fakedbs.AddQuery("SELECT FOUND_ROWS()", &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "FOUND_ROWS()", Type: querypb.Type_INT64}},
+			Rows:   [][]sqltypes.Value{{sqltypes.MakeTrusted(querypb.Type_INT64, []byte("5"))}},
+		})
+		fakedbs.AddQueryPattern("select b, count\\(\\*\\) from test.t1_.* as t1 group by b.*", &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "b", Type: querypb.Type_INT32},
+				{Name: "count(*)", Type: querypb.Type_INT64},
+			},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("1")), sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))},
+				{sqltypes.MakeTrusted(querypb.Type_INT32, []byte("2")), sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1"))},
+			},
+		})
+		fakedbs.AddQuery("select id from test.s1 union all select id from test.s1", rows)
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("use test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table s1(id int) single", -1)
+	assert.Nil(t, err)
+
+	foundRows := func(want string) {
+		query := fmt.Sprintf("select %s as `found_rows()` from dual", want)
+		fakedbs.AddQuery(query, &sqltypes.Result{})
+		_, err := client.FetchAll("select found_rows()", -1)
+		assert.Nil(t, err, query)
+	}
+
+	// Pushed down to the shards with the LIMIT offset+n, the FOUND_ROWS() is the sum of the shards'.
+	{
+		qr, err := client.FetchAll("select sql_calc_found_rows id from t1 order by id limit 1, 2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1] [1]]", fmt.Sprintf("%v", qr.Rows))
+		foundRows("150")
+		assert.Equal(t, 30, fakedbs.GetQueryCalledNum("SELECT FOUND_ROWS()"))
+	}
+
+	// The groups across the shards are counted by the proxy.
+	{
+		qr, err := client.FetchAll("select sql_calc_found_rows b, count(*) from t1 group by b limit 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		foundRows("2")
+		assert.Equal(t, 30, fakedbs.GetQueryCalledNum("SELECT FOUND_ROWS()"))
+	}
+
+	// Union.
+	{
+		qr, err := client.FetchAll("select sql_calc_found_rows id from s1 union all select id from s1 limit 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[1]]", fmt.Sprintf("%v", qr.Rows))
+		foundRows("3")
+	}
+}
@@ -9,8 +9,9 @@
 package proxy
 
 import (
+	"forks/go-mysqlstack/driver"
+
 	"github.com/pkg/errors"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
@@ -0,0 +1,566 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"backend"
+	"config"
+	"forks/go-mysqlstack/driver"
+	"monitor"
+	"router"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+const (
+	// schemaRepairSuffix is the suffix of the table with the majority definition built by the repair.
+	schemaRepairSuffix = "_radon_repair"
+	// schemaBackupSuffix is the suffix of the drifted table kept by the repair, followed by the time.
+	schemaBackupSuffix = "_radon_bak"
+	// maxTableNameLen is the max length of the MySQL table name.
+	maxTableNameLen = 64
+)
+
+var (
+	// The AUTO_INCREMENT table option differs between the partitions, it's ignored.
+	autoIncrementOptionRE = regexp.MustCompile(`(?i)\s+AUTO_INCREMENT=\d+`)
+
+	// RADON CHECK SCHEMA [[db.]table] and RADON REPAIR SCHEMA [db.]table.
+	schemaCheckStmtRE = regexp.MustCompile("(?is)^radon\\s+(check|repair)\\s+schema(?:\\s+([\\w`]+(?:\\.[\\w`]+)?))?$")
+)
+
+// SchemaPartition is the definition of the table on one partition.
+type SchemaPartition struct {
+	Backend    string `json:"backend"`
+	Table      string `json:"table"`
+	Definition string `json:"definition,omitempty"`
+	Missing    bool   `json:"missing,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Drifted    bool   `json:"drifted"`
+}
+
+// SchemaDrift is the result of comparing the definitions of the table across all the partitions.
+type SchemaDrift struct {
+	Database   string             `json:"database"`
+	Table      string             `json:"table"`
+	Majority   string             `json:"majority,omitempty"`
+	CheckTime  time.Time          `json:"check-time"`
+	Partitions []*SchemaPartition `json:"partitions"`
+}
+
+// Drifted returns the partitions whose definitions are different from the majority.
+func (d *SchemaDrift) Drifted() []*SchemaPartition {
+	var parts []*SchemaPartition
+	for _, part := range d.Partitions {
+		if part.Drifted {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// SchemaChecker tuple.
+type SchemaChecker struct {
+	log     *xlog.Log
+	conf    *config.ProxyConfig
+	router  *router.Router
+	scatter *backend.Scatter
+	done    chan bool
+	wg      sync.WaitGroup
+	mu      sync.RWMutex
+	drifts  map[string]*SchemaDrift
+}
+
+// NewSchemaChecker creates the SchemaChecker tuple.
+func NewSchemaChecker(log *xlog.Log, conf *config.ProxyConfig, router *router.Router, scatter *backend.Scatter) *SchemaChecker {
+	return &SchemaChecker{
+		log:     log,
+		conf:    conf,
+		router:  router,
+		scatter: scatter,
+		done:    make(chan bool),
+		drifts:  make(map[string]*SchemaDrift),
+	}
+}
+
+// Init used to init the background check goroutine, it's disabled if the interval is 0.
+func (sc *SchemaChecker) Init() error {
+	log := sc.log
+	interval := sc.conf.SchemaCheckInterval
+	if interval <= 0 {
+		log.Info("schema.check.disabled")
+		return nil
+	}
+
+	sc.wg.Add(1)
+	go func(sc *SchemaChecker) {
+		defer sc.wg.Done()
+		sc.check(time.Duration(interval) * time.Second)
+	}(sc)
+	log.Info("schema.check.init.done")
+	return nil
+}
+
+// Close used to close the background check goroutine.
+func (sc *SchemaChecker) Close() {
+	close(sc.done)
+	sc.wg.Wait()
+}
+
+func (sc *SchemaChecker) check(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			sc.CheckAll()
+		case <-sc.done:
+			return
+		}
+	}
+}
+
+// CheckAll used to check all the tables, returns the results sorted by the table name.
+func (sc *SchemaChecker) CheckAll() []*SchemaDrift {
+	log := sc.log
+
+	var names []string
+	checked := make(map[string]bool)
+	for db, tables := range sc.router.Tables() {
+		for _, table := range tables {
+			name := fmt.Sprintf("%s.%s", db, table)
+			names = append(names, name)
+			checked[name] = true
+		}
+	}
+	sort.Strings(names)
+
+	var drifts []*SchemaDrift
+	for _, name := range names {
+		idx := strings.Index(name, ".")
+		drift, err := sc.Check(name[:idx], name[idx+1:])
+		if err != nil {
+			log.Error("schema.check[%s].error:%+v", name, err)
+			continue
+		}
+		drifts = append(drifts, drift)
+	}
+
+	// Remove the tables dropped.
+	sc.mu.Lock()
+	for name, drift := range sc.drifts {
+		if !checked[name] {
+			monitor.SchemaDriftSet(drift.Database, drift.Table, 0)
+			delete(sc.drifts, name)
+		}
+	}
+	sc.mu.Unlock()
+	return drifts
+}
+
+// Check used to compare the normalized 'SHOW CREATE TABLE' of the table on all the partitions.
+func (sc *SchemaChecker) Check(database string, table string) (*SchemaDrift, error) {
+	log := sc.log
+	segments, err := sc.router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	drift := &SchemaDrift{
+		Database:  database,
+		Table:     table,
+		CheckTime: time.Now(),
+	}
+	votes := make(map[string]int)
+	for _, segment := range segments {
+		part := &SchemaPartition{
+			Backend: segment.Backend,
+			Table:   segment.Table,
+		}
+		query := fmt.Sprintf("show create table `%s`.`%s`", database, segment.Table)
+		qr, err := sc.execute([]xcontext.QueryTuple{{Query: query, Backend: segment.Backend}})
+		switch {
+		case err != nil:
+			if x, ok := errors.Cause(err).(*sqldb.SQLError); ok && x.Num == sqldb.ER_NO_SUCH_TABLE {
+				part.Missing = true
+			}
+			part.Error = err.Error()
+		case len(qr.Rows) == 0 || len(qr.Rows[0]) < 2:
+			part.Error = "empty.definition"
+		default:
+			part.Definition = normalizeTableDefinition(qr.Rows[0][1].String(), segment.Table, table)
+			votes[part.Definition]++
+		}
+		drift.Partitions = append(drift.Partitions, part)
+	}
+
+	// The majority definition must be on more than half of the partitions.
+	for _, part := range drift.Partitions {
+		if part.Definition != "" && votes[part.Definition]*2 > len(drift.Partitions) {
+			drift.Majority = part.Definition
+			break
+		}
+	}
+	for _, part := range drift.Partitions {
+		part.Drifted = part.Definition == "" || part.Definition != drift.Majority
+	}
+
+	n := len(drift.Drifted())
+	if n > 0 {
+		log.Warning("schema.check[%s.%s].drifted.partitions[%d/%d]", database, table, n, len(drift.Partitions))
+	}
+	monitor.SchemaDriftSet(database, table, float64(n))
+	sc.mu.Lock()
+	sc.drifts[fmt.Sprintf("%s.%s", database, table)] = drift
+	sc.mu.Unlock()
+	return drift, nil
+}
+
+// Repair used to apply the majority definition to the drifted partitions of the table.
+// The missing partition is created, the drifted partition is rebuilt with the majority
+// definition and the rows are copied, the old one is kept with the suffix '_radon_bak_<time>'.
+func (sc *SchemaChecker) Repair(database string, table string) (*SchemaDrift, error) {
+	log := sc.log
+	drift, err := sc.Check(database, table)
+	if err != nil {
+		return nil, err
+	}
+	drifted := drift.Drifted()
+	if len(drifted) == 0 {
+		return drift, nil
+	}
+	if drift.Majority == "" {
+		return nil, errors.Errorf("schema.repair[%s.%s].no.majority.definition", database, table)
+	}
+
+	for _, part := range drifted {
+		if err := sc.repairPartition(database, table, drift.Majority, part); err != nil {
+			log.Error("schema.repair[%s.%s].partition[%s:%s].error:%+v", database, table, part.Backend, part.Table, err)
+			return nil, err
+		}
+	}
+	return sc.Check(database, table)
+}
+
+// repairPartition used to create the missing partition or rebuild the drifted one, the drifted
+// partition is restored from the backup if it's renamed away but the repaired one isn't renamed in.
+func (sc *SchemaChecker) repairPartition(database string, table string, majority string, part *SchemaPartition) error {
+	log := sc.log
+	var atomic bool
+	if !part.Missing && part.Error == "" {
+		qr, err := sc.execute([]xcontext.QueryTuple{{Query: "select version()", Backend: part.Backend}})
+		if err != nil {
+			return err
+		}
+		if len(qr.Rows) > 0 && len(qr.Rows[0]) > 0 {
+			atomic = renameUnderLockSupported(qr.Rows[0][0].String())
+		}
+	}
+
+	backup := fmt.Sprintf("%s%s_%s", part.Table, schemaBackupSuffix, time.Now().Format("20060102150405"))
+	querys, err := repairQuerys(database, table, majority, part, backup, atomic)
+	if err != nil {
+		return err
+	}
+	var tuples []xcontext.QueryTuple
+	for _, query := range querys {
+		tuples = append(tuples, xcontext.QueryTuple{Query: query, Backend: part.Backend})
+	}
+	log.Warning("schema.repair[%s.%s].partition[%s:%s].querys:%v", database, table, part.Backend, part.Table, querys)
+	if _, err := sc.execute(tuples); err != nil {
+		if !part.Missing {
+			if x := sc.restorePartition(database, part, backup); x != nil {
+				log.Error("schema.repair[%s.%s].partition[%s:%s].restore.from[%s].error:%+v", database, table, part.Backend, part.Table, backup, x)
+				return errors.Errorf("%v, restore.partition[%s].from[%s].error:%v", err, part.Table, backup, x)
+			}
+		}
+		return err
+	}
+	return nil
+}
+
+// restorePartition used to rename the backup back if the partition is renamed to it but the
+// repaired one isn't renamed to the partition.
+func (sc *SchemaChecker) restorePartition(database string, part *SchemaPartition, backup string) error {
+	query := fmt.Sprintf("select table_name from information_schema.tables where %s", infoSchemaFilter(database, part.Table, backup))
+	qr, err := sc.execute([]xcontext.QueryTuple{{Query: query, Backend: part.Backend}})
+	if err != nil {
+		return err
+	}
+	exists := make(map[string]bool)
+	for _, row := range qr.Rows {
+		exists[row[0].String()] = true
+	}
+	if exists[part.Table] || !exists[backup] {
+		return nil
+	}
+	sc.log.Warning("schema.repair.restore.partition[%s:%s].from[%s]", part.Backend, part.Table, backup)
+	rename := fmt.Sprintf("rename table `%s`.`%s` to `%s`.`%s`", database, backup, database, part.Table)
+	_, err = sc.execute([]xcontext.QueryTuple{{Query: rename, Backend: part.Backend}})
+	return err
+}
+
+// renameUnderLockSupported returns true if the RENAME TABLE can be executed under the LOCK
+// TABLES, it's supported since MySQL 8.0.13.
+func renameUnderLockSupported(version string) bool {
+	var major, minor, patch int
+	if n, _ := fmt.Sscanf(version, "%d.%d.%d", &major, &minor, &patch); n < 3 {
+		return false
+	}
+	switch {
+	case major != 8:
+		return major > 8
+	case minor != 0:
+		return minor > 0
+	}
+	return patch >= 13
+}
+
+// Drifts returns the last results of the tables with drifted partitions.
+func (sc *SchemaChecker) Drifts() []*SchemaDrift {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+
+	var drifts []*SchemaDrift
+	for _, drift := range sc.drifts {
+		if len(drift.Drifted()) > 0 {
+			drifts = append(drifts, drift)
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool {
+		if drifts[i].Database != drifts[j].Database {
+			return drifts[i].Database < drifts[j].Database
+		}
+		return drifts[i].Table < drifts[j].Table
+	})
+	return drifts
+}
+
+// execute used to execute the querys on the backends in order.
+func (sc *SchemaChecker) execute(querys []xcontext.QueryTuple) (*sqltypes.Result, error) {
+	txn, err := sc.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(sc.conf.DDLTimeout)
+
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = querys
+	return txn.Execute(req)
+}
+
+// normalizeTableDefinition returns the definition with the partition table name replaced by the
+// table name, and the AUTO_INCREMENT table option removed.
+func normalizeTableDefinition(definition string, segTable string, table string) string {
+	definition = strings.Replace(definition, fmt.Sprintf("`%s`", segTable), fmt.Sprintf("`%s`", table), 1)
+	return autoIncrementOptionRE.ReplaceAllString(definition, "")
+}
+
+// definitionColumns returns the columns of the table definition.
+func definitionColumns(definition string) ([]*sqlparser.ColumnDefinition, error) {
+	node, err := sqlparser.Parse(definition)
+	if err != nil {
+		return nil, err
+	}
+	ddl, ok := node.(*sqlparser.DDL)
+	if !ok || ddl.TableSpec == nil {
+		return nil, errors.Errorf("unsupported: table.definition[%s]", definition)
+	}
+	return ddl.TableSpec.Columns, nil
+}
+
+// columnDataType returns the data type of the column without the options such as the default
+// and the comment, the rows are copied only if it isn't changed.
+func columnDataType(col *sqlparser.ColumnDefinition) string {
+	typ := sqlparser.ColumnType{
+		Type:       strings.ToLower(col.Type.Type),
+		Length:     col.Type.Length,
+		Unsigned:   col.Type.Unsigned,
+		Zerofill:   col.Type.Zerofill,
+		Scale:      col.Type.Scale,
+		Charset:    strings.ToLower(col.Type.Charset),
+		Collate:    strings.ToLower(col.Type.Collate),
+		EnumValues: col.Type.EnumValues,
+	}
+	return sqlparser.String(&typ)
+}
+
+// repairQuerys returns the querys to apply the majority definition to the drifted partition, the
+// drifted one is renamed to the backup. The partition is swapped by one RENAME TABLE under the
+// locks if atomic is true, otherwise by two ALTER TABLE RENAME.
+func repairQuerys(database string, table string, majority string, part *SchemaPartition, backup string, atomic bool) ([]string, error) {
+	seg := fmt.Sprintf("`%s`.`%s`", database, part.Table)
+	if part.Missing {
+		return []string{strings.Replace(majority, fmt.Sprintf("`%s`", table), seg, 1)}, nil
+	}
+	if part.Error != "" {
+		return nil, errors.Errorf("schema.repair[%s.%s].partition[%s:%s].error:%s", database, table, part.Backend, part.Table, part.Error)
+	}
+	if len(backup) > maxTableNameLen {
+		return nil, errors.Errorf("schema.repair[%s.%s].partition[%s:%s].backup[%s].name.too.long", database, table, part.Backend, part.Table, backup)
+	}
+
+	// The rows of the columns in both definitions are copied, the type must be the same.
+	majorityCols, err := definitionColumns(majority)
+	if err != nil {
+		return nil, err
+	}
+	partCols, err := definitionColumns(part.Definition)
+	if err != nil {
+		return nil, err
+	}
+	types := make(map[string]string)
+	for _, col := range partCols {
+		types[col.Name.Lowered()] = columnDataType(col)
+	}
+	var cols []string
+	for _, col := range majorityCols {
+		typ, ok := types[col.Name.Lowered()]
+		if !ok {
+			continue
+		}
+		if want := columnDataType(col); typ != want {
+			return nil, errors.Errorf("schema.repair[%s.%s].partition[%s:%s].column[%s].type.changed[%s->%s]", database, table, part.Backend, part.Table, col.Name.String(), typ, want)
+		}
+		cols = append(cols, fmt.Sprintf("`%s`", col.Name.String()))
+	}
+
+	repair := fmt.Sprintf("`%s`.`%s%s`", database, part.Table, schemaRepairSuffix)
+	bak := fmt.Sprintf("`%s`.`%s`", database, backup)
+	querys := []string{
+		fmt.Sprintf("drop table if exists %s", repair),
+		strings.Replace(majority, fmt.Sprintf("`%s`", table), repair, 1),
+		// The writes to the partition are blocked until it's swapped, the querys run on one
+		// connection which is closed on error, so the locks are released.
+		fmt.Sprintf("lock tables %s write, %s write", seg, repair),
+	}
+	if len(cols) > 0 {
+		list := strings.Join(cols, ", ")
+		querys = append(querys, fmt.Sprintf("insert into %s(%s) select %s from %s", repair, list, list, seg))
+	}
+	if atomic {
+		querys = append(querys, fmt.Sprintf("rename table %s to %s, %s to %s", seg, bak, repair, seg))
+	} else {
+		// RENAME TABLE isn't allowed under LOCK TABLES before MySQL 8.0.13, the partition is
+		// restored from the backup by the caller if the second rename fails.
+		querys = append(querys,
+			fmt.Sprintf("alter table %s rename %s", seg, bak),
+			fmt.Sprintf("alter table %s rename %s", repair, seg),
+		)
+	}
+	querys = append(querys, "unlock tables")
+	return querys, nil
+}
+
+// isSchemaCheckStmt returns true if the query is RADON CHECK SCHEMA or RADON REPAIR SCHEMA.
+func isSchemaCheckStmt(query string) bool {
+	return schemaCheckStmtRE.MatchString(query)
+}
+
+// handleSchemaCheckStmt used to handle the RADON CHECK SCHEMA and RADON REPAIR SCHEMA.
+// All the tables are checked if the table is omitted, the repair needs the table.
+func (spanner *Spanner) handleSchemaCheckStmt(session *driver.Session, query string) (*sqltypes.Result, error) {
+	checker := spanner.schemaChecker
+	matches := schemaCheckStmtRE.FindStringSubmatch(query)
+	action, name := strings.ToLower(matches[1]), matches[2]
+
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+	if name == "" {
+		if action == "repair" {
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "You have an error in your SQL syntax; the table is needed by the repair")
+		}
+		return schemaDriftsResult(checker.CheckAll()), nil
+	}
+
+	database, table := splitQualifiedName(session.Schema(), name)
+	if database == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := spanner.router.DatabaseACL(database); err != nil {
+		return nil, err
+	}
+
+	var drift *SchemaDrift
+	var err error
+	switch action {
+	case "check":
+		drift, err = checker.Check(database, table)
+	default:
+		if spanner.ReadOnly() {
+			return nil, sqldb.NewSQLError(sqldb.ER_OPTION_PREVENTS_STATEMENT, "--read-only")
+		}
+		drift, err = checker.Repair(database, table)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return schemaDriftsResult([]*SchemaDrift{drift}), nil
+}
+
+// schemaDriftsResult returns the result of the schema check, one row for each table.
+func schemaDriftsResult(drifts []*SchemaDrift) *sqltypes.Result {
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Database", Type: querypb.Type_VARCHAR},
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Partitions", Type: querypb.Type_INT64},
+		{Name: "Drifted", Type: querypb.Type_INT64},
+		{Name: "Status", Type: querypb.Type_VARCHAR},
+		{Name: "Detail", Type: querypb.Type_VARCHAR},
+	}
+	for _, drift := range drifts {
+		status := "OK"
+		var details []string
+		for _, part := range drift.Drifted() {
+			detail := fmt.Sprintf("%s:%s", part.Backend, part.Table)
+			switch {
+			case part.Missing:
+				detail += "(missing)"
+			case part.Error != "":
+				detail += "(error)"
+			}
+			details = append(details, detail)
+		}
+		switch {
+		case len(details) > 0 && drift.Majority == "":
+			status = "DRIFT(NO MAJORITY)"
+		case len(details) > 0:
+			status = "DRIFT"
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(drift.Database)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(drift.Table)),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(drift.Partitions)))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", len(details)))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(status)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(strings.Join(details, ", "))),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr
+}
@@ -0,0 +1,329 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+// mockShowCreateTable returns the result of the 'SHOW CREATE TABLE'.
+func mockShowCreateTable(table string, definition string) *sqltypes.Result {
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Table", Type: querypb.Type_VARCHAR},
+			{Name: "Create Table", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(table)),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(definition)),
+			},
+		},
+	}
+}
+
+func TestProxySchemaCheckNormalize(t *testing.T) {
+	def := "CREATE TABLE `t1_0001` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB AUTO_INCREMENT=10 DEFAULT CHARSET=utf8"
+	want := "CREATE TABLE `t1` (\n  `id` int(11) NOT NULL AUTO_INCREMENT,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=utf8"
+	assert.Equal(t, want, normalizeTableDefinition(def, "t1_0001", "t1"))
+
+	// The global table.
+	def = "CREATE TABLE `t1` (\n  `t1` int(11) DEFAULT NULL\n) ENGINE=InnoDB"
+	assert.Equal(t, def, normalizeTableDefinition(def, "t1", "t1"))
+}
+
+func TestProxySchemaRepairQuerys(t *testing.T) {
+	majority := "CREATE TABLE `t1` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB"
+	part := &SchemaPartition{
+		Backend:    "backend1",
+		Table:      "t1_0001",
+		Definition: "CREATE TABLE `t1` (\n  `id` int(11) DEFAULT '0',\n  `c` int(11) DEFAULT NULL\n) ENGINE=InnoDB",
+	}
+	backup := "t1_0001_radon_bak_20191010101010"
+
+	// The copy and the swap are done under the write locks.
+	{
+		querys, err := repairQuerys("test", "t1", majority, part, backup, false)
+		assert.Nil(t, err)
+		want := []string{
+			"drop table if exists `test`.`t1_0001_radon_repair`",
+			"CREATE TABLE `test`.`t1_0001_radon_repair` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB",
+			"lock tables `test`.`t1_0001` write, `test`.`t1_0001_radon_repair` write",
+			"insert into `test`.`t1_0001_radon_repair`(`id`) select `id` from `test`.`t1_0001`",
+			"alter table `test`.`t1_0001` rename `test`.`t1_0001_radon_bak_20191010101010`",
+			"alter table `test`.`t1_0001_radon_repair` rename `test`.`t1_0001`",
+			"unlock tables",
+		}
+		assert.Equal(t, want, querys)
+	}
+
+	// The swap is atomic.
+	{
+		querys, err := repairQuerys("test", "t1", majority, part, backup, true)
+		assert.Nil(t, err)
+		want := []string{
+			"drop table if exists `test`.`t1_0001_radon_repair`",
+			"CREATE TABLE `test`.`t1_0001_radon_repair` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB",
+			"lock tables `test`.`t1_0001` write, `test`.`t1_0001_radon_repair` write",
+			"insert into `test`.`t1_0001_radon_repair`(`id`) select `id` from `test`.`t1_0001`",
+			"rename table `test`.`t1_0001` to `test`.`t1_0001_radon_bak_20191010101010`, `test`.`t1_0001_radon_repair` to `test`.`t1_0001`",
+			"unlock tables",
+		}
+		assert.Equal(t, want, querys)
+	}
+
+	// The type of the copied column is changed.
+	{
+		changed := &SchemaPartition{
+			Backend:    "backend1",
+			Table:      "t1_0001",
+			Definition: "CREATE TABLE `t1` (\n  `id` bigint(20) DEFAULT NULL\n) ENGINE=InnoDB",
+		}
+		_, err := repairQuerys("test", "t1", majority, changed, backup, true)
+		assert.EqualError(t, err, "schema.repair[test.t1].partition[backend1:t1_0001].column[id].type.changed[bigint(20)->int(11)]")
+	}
+
+	// The backup name is too long.
+	{
+		_, err := repairQuerys("test", "t1", majority, part, strings.Repeat("x", 65), true)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxySchemaRepairVersion(t *testing.T) {
+	tests := []struct {
+		version string
+		atomic  bool
+	}{
+		{"5.7.25-log", false},
+		{"8.0.12", false},
+		{"8.0.13", true},
+		{"8.0.21-12", true},
+		{"8.1.0", true},
+		{"10.3.2-MariaDB", true},
+		{"xx", false},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.atomic, renameUnderLockSupported(test.version), test.version)
+	}
+}
+
+func TestProxySchemaRepairRestore(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	checker := proxy.Spanner().SchemaChecker()
+
+	backends := proxy.Scatter().Backends()
+	part := &SchemaPartition{Backend: backends[0], Table: "t1_0001"}
+	backup := "t1_0001_radon_bak_20191010101010"
+	query := "select table_name from information_schema.tables where table_schema='test' and table_name in ('t1_0001', 't1_0001_radon_bak_20191010101010')"
+	rename := "rename table `test`.`t1_0001_radon_bak_20191010101010` to `test`.`t1_0001`"
+	fakedbs.AddQuery(rename, &sqltypes.Result{})
+
+	// The partition exists, nothing to do.
+	{
+		fakedbs.AddQuery(query, &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "table_name", Type: querypb.Type_VARCHAR}},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1_0001"))},
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(backup))},
+			},
+		})
+		err := checker.restorePartition("test", part, backup)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, fakedbs.GetQueryCalledNum(rename))
+	}
+
+	// The partition is renamed to the backup, it's renamed back.
+	{
+		fakedbs.AddQuery(query, &sqltypes.Result{
+			Fields: []*querypb.Field{{Name: "table_name", Type: querypb.Type_VARCHAR}},
+			Rows: [][]sqltypes.Value{
+				{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(backup))},
+			},
+		})
+		err := checker.restorePartition("test", part, backup)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(rename))
+	}
+}
+
+func TestProxySchemaCheck(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	checker := proxy.Spanner().SchemaChecker()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("drop .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("alter .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("lock .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("unlock .*", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	_, err = client.FetchAll("create table t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	assert.Nil(t, err)
+	definition := "CREATE TABLE `%s` (\n  `id` int(11) DEFAULT NULL,\n  `b` int(11) DEFAULT NULL\n) ENGINE=InnoDB%s DEFAULT CHARSET=utf8"
+	for _, segment := range segments {
+		query := fmt.Sprintf("show create table `test`.`%s`", segment.Table)
+		fakedbs.AddQuery(query, mockShowCreateTable(segment.Table, fmt.Sprintf(definition, segment.Table, " AUTO_INCREMENT=10")))
+	}
+
+	// All the partitions are the same.
+	{
+		qr, err := client.FetchAll("radon check schema t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("[[test t1 %d 0 OK ]]", len(segments)), fmt.Sprintf("%v", qr.Rows))
+		assert.Equal(t, 0, len(checker.Drifts()))
+	}
+
+	// The partition drifted and the partition missing.
+	drifted, missing := segments[3], segments[4]
+	{
+		fakedbs.AddQuery(fmt.Sprintf("show create table `test`.`%s`", drifted.Table),
+			mockShowCreateTable(drifted.Table, fmt.Sprintf("CREATE TABLE `%s` (\n  `id` int(11) DEFAULT NULL,\n  `c` int(11) DEFAULT NULL\n) ENGINE=InnoDB", drifted.Table)))
+		fakedbs.AddQueryError(fmt.Sprintf("show create table `test`.`%s`", missing.Table),
+			sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, "test."+missing.Table))
+
+		qr, err := client.FetchAll("RADON CHECK SCHEMA test.t1", -1)
+		assert.Nil(t, err)
+		want := fmt.Sprintf("[[test t1 %d 2 DRIFT %s:%s, %s:%s(missing)]]", len(segments), drifted.Backend, drifted.Table, missing.Backend, missing.Table)
+		assert.Equal(t, want, fmt.Sprintf("%v", qr.Rows))
+
+		// The REST report.
+		drifts := checker.Drifts()
+		assert.Equal(t, 1, len(drifts))
+		assert.Equal(t, fmt.Sprintf(definition, "t1", ""), drifts[0].Majority)
+		assert.Equal(t, 2, len(drifts[0].Drifted()))
+
+		// Check all.
+		qr, err = client.FetchAll("radon check schema", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+		assert.Equal(t, "DRIFT", qr.Rows[0][4].String())
+	}
+
+	// Repair.
+	{
+		fakedbs.AddQuery("select version()", mockVersion("5.7.25-log"))
+		_, err := client.FetchAll("radon repair schema t1", -1)
+		assert.Nil(t, err)
+
+		create := fmt.Sprintf(definition, "test`.`"+missing.Table, "")
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(create))
+		seg := fmt.Sprintf("`test`.`%s`", drifted.Table)
+		repair := fmt.Sprintf("`test`.`%s_radon_repair`", drifted.Table)
+		querys := []string{
+			fmt.Sprintf(definition, "test`.`"+drifted.Table+"_radon_repair", ""),
+			fmt.Sprintf("lock tables %s write, %s write", seg, repair),
+			fmt.Sprintf("insert into %s(`id`) select `id` from %s", repair, seg),
+			fmt.Sprintf("alter table %s rename %s", repair, seg),
+			"unlock tables",
+		}
+		for _, query := range querys {
+			assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query), query)
+		}
+	}
+
+	// The drifted table is dropped.
+	{
+		_, err := client.FetchAll("drop table t1", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("radon check schema", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 0, len(qr.Rows))
+		assert.Equal(t, 0, len(checker.Drifts()))
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"radon repair schema",
+			"radon check schema t2",
+			"radon check schema xx.t1",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+
+		noDB, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer noDB.Close()
+		_, err = noDB.FetchAll("radon check schema t1", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxySchemaCheckNoMajority(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	_, err = client.FetchAll("create database test", -1)
+	assert.Nil(t, err)
+	_, err = client.FetchAll("create table test.t1(id int, b int) partition by hash(id)", -1)
+	assert.Nil(t, err)
+
+	// Every partition has its own definition.
+	segments, err := proxy.Router().Lookup("test", "t1", nil, nil)
+	assert.Nil(t, err)
+	for i, segment := range segments {
+		query := fmt.Sprintf("show create table `test`.`%s`", segment.Table)
+		fakedbs.AddQuery(query, mockShowCreateTable(segment.Table, fmt.Sprintf("CREATE TABLE `%s` (`c%d` int)", segment.Table, i)))
+	}
+	qr, err := client.FetchAll("radon check schema test.t1", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, "DRIFT(NO MAJORITY)", qr.Rows[0][4].String())
+
+	_, err = client.FetchAll("radon repair schema test.t1", -1)
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "no.majority.definition"), err.Error())
+}
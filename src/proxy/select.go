@@ -9,15 +9,249 @@
 package proxy
 
 import (
-	"github.com/xelabs/go-mysqlstack/driver"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"forks/go-mysqlstack/driver"
+	"optimizer"
+	"planner"
+	"xbase/sync2"
+	"xcontext"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
+// calcFoundRowsRE matches the SQL_CALC_FOUND_ROWS option of the select, which is handled by the proxy.
+var calcFoundRowsRE = regexp.MustCompile(`(?is)^(\s*select\s+(?:/\*.*?\*/\s*)*(?:(?:all|distinct|distinctrow|high_priority|straight_join|sql_small_result|sql_big_result|sql_buffer_result|sql_cache|sql_no_cache)\s+)*)sql_calc_found_rows\b\s*`)
+
+// stripCalcFoundRows returns the query without the SQL_CALC_FOUND_ROWS option, and true if it's stripped.
+func stripCalcFoundRows(query string) (string, bool) {
+	loc := calcFoundRowsRE.FindStringSubmatchIndex(query)
+	if loc == nil {
+		return query, false
+	}
+	return query[:loc[3]] + query[loc[1]:], true
+}
+
 // handleSelect used to handle the select command.
 func (spanner *Spanner) handleSelect(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	database := session.Schema()
-	return spanner.ExecuteDML(session, database, query, node)
+	qr, err := spanner.ExecuteDML(session, database, query, node)
+	if err != nil {
+		return nil, err
+	}
+	spanner.trackSelect(session, len(qr.Rows))
+	return qr, nil
+}
+
+// handleSelectCalcFoundRows used to handle the select with SQL_CALC_FOUND_ROWS. If the rows of the shards
+// are only sorted and limited by the proxy, the option is pushed down to the shards with the LIMIT
+// offset+n, and the FOUND_ROWS() is the sum of the shards'. Otherwise, such as the rows are aggregated,
+// filtered or joined by the proxy and the union, the LIMIT is removed to count all the rows, and applied
+// by the proxy.
+func (spanner *Spanner) handleSelectCalcFoundRows(session *driver.Session, query string, node sqlparser.SelectStatement) (*sqltypes.Result, error) {
+	database := session.Schema()
+	if sel, ok := node.(*sqlparser.Select); ok && spanner.calcFoundRowsPushable(database, query) {
+		var found sync2.AtomicInt64
+		opts := &execOpts{foundRows: func(rows uint64) { found.Add(int64(rows)) }}
+		qr, err := spanner.executeDML(session, database, query, sel, opts)
+		if err != nil {
+			return nil, err
+		}
+		spanner.trackSelect(session, int(found.Get()))
+		return qr, nil
+	}
+
+	var limit *sqlparser.Limit
+	switch node := node.(type) {
+	case *sqlparser.Select:
+		limit, node.Limit = node.Limit, nil
+	case *sqlparser.Union:
+		limit, node.Limit = node.Limit, nil
+	}
+	limitPlan := planner.NewLimitPlan(spanner.log, limit)
+	if err := limitPlan.Build(); err != nil {
+		return nil, err
+	}
+
+	qr, err := spanner.ExecuteDML(session, database, sqlparser.String(node), node)
+	if err != nil {
+		return nil, err
+	}
+	found := len(qr.Rows)
+	if limit != nil && limit.Rowcount != nil {
+		start, end := limitPlan.Offset, limitPlan.Offset+limitPlan.Limit
+		if start > found {
+			start = found
+		}
+		if end > found {
+			end = found
+		}
+		qr.Rows = qr.Rows[start:end]
+		qr.RowsAffected = uint64(len(qr.Rows))
+	}
+	spanner.trackSelect(session, found)
+	return qr, nil
+}
+
+// calcFoundRowsPushable returns true if the SQL_CALC_FOUND_ROWS of the query can be pushed down to the shards.
+func (spanner *Spanner) calcFoundRowsPushable(database string, query string) bool {
+	node, err := sqlparser.Parse(query)
+	if err != nil {
+		return false
+	}
+	plans, err := optimizer.NewSimpleOptimizer(spanner.log, database, query, node, spanner.router).BuildPlanTree()
+	if err != nil {
+		return false
+	}
+	return pushCalcFoundRows(plans, nil)
+}
+
+// shardSelectRE matches the beginning of the shard query.
+var shardSelectRE = regexp.MustCompile(`(?i)^\s*select\s`)
+
+// pushCalcFoundRows used to add the SQL_CALC_FOUND_ROWS to the shard querys of the plan, the foundRows is
+// called with the FOUND_ROWS() of each shard. Returns false if the plan is not a merge whose rows are only
+// sorted, limited or projected by the proxy, the sum of the shards' FOUND_ROWS() isn't the result's.
+// If the foundRows is nil, the plan is only checked.
+func pushCalcFoundRows(plans *planner.PlanTree, foundRows func(rows uint64)) bool {
+	if len(plans.Plans()) != 1 {
+		return false
+	}
+	plan, ok := plans.Plans()[0].(*planner.SelectPlan)
+	if !ok {
+		return false
+	}
+	merge, ok := plan.Root.(*planner.MergeNode)
+	if !ok || merge.ReqMode != xcontext.ReqNormal || len(merge.Querys) == 0 {
+		return false
+	}
+	if children := merge.Children(); children != nil {
+		for _, child := range children.Plans() {
+			switch child.Type() {
+			case planner.PlanTypeOrderby, planner.PlanTypeLimit, planner.PlanTypeProject:
+			default:
+				return false
+			}
+		}
+	}
+	for _, tuple := range merge.Querys {
+		if !shardSelectRE.MatchString(tuple.Query) {
+			return false
+		}
+	}
+	if foundRows == nil {
+		return true
+	}
+
+	for i, tuple := range merge.Querys {
+		loc := shardSelectRE.FindStringIndex(tuple.Query)
+		merge.Querys[i].Query = tuple.Query[:loc[1]] + "sql_calc_found_rows " + tuple.Query[loc[1]:]
+	}
+	merge.FoundRows = foundRows
+	return true
+}
+
+// trackSelect used to set the FOUND_ROWS() and ROW_COUNT() of the session after the select.
+func (spanner *Spanner) trackSelect(session *driver.Session, foundRows int) {
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		txSession.setFoundRows(uint64(foundRows))
+		txSession.setRowCount(-1)
+	}
+}
+
+// handleSelectDual used to handle the select without tables.
+// The LAST_INSERT_ID(), ROW_COUNT(), FOUND_ROWS() and @@autocommit are answered by the session,
+// the NEXTVAL() is answered by the sequence, the other system variables are answered by the
+// backend with the variables of the session applied.
+func (spanner *Spanner) handleSelectDual(session *driver.Session, query string, node *sqlparser.Select) (*sqltypes.Result, error) {
+	rewritten, err := spanner.rewriteNextval(session, node)
+	if err != nil {
+		return nil, err
+	}
+	if spanner.rewriteSessionFuncs(session, node) || rewritten {
+		query = sqlparser.String(node)
+	}
+	qr, err := spanner.ExecuteSingleWithSession(session, query)
+	if err != nil {
+		return nil, err
+	}
+	spanner.fillAutocommit(session, node, qr)
+	spanner.trackSelect(session, len(qr.Rows))
+	return qr, nil
+}
+
+// fillAutocommit used to set the @@autocommit columns of the result by the session,
+// the autocommit is handled by the proxy and isn't set to the backends.
+func (spanner *Spanner) fillAutocommit(session *driver.Session, node *sqlparser.Select, qr *sqltypes.Result) {
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession == nil {
+		return
+	}
+	val, ok := txSession.getSysVar(var_autocommit)
+	if !ok {
+		return
+	}
+
+	for i, expr := range node.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		col, ok := aliased.Expr.(*sqlparser.ColName)
+		if !ok || !col.Qualifier.IsEmpty() {
+			continue
+		}
+		switch strings.ToLower(col.Name.String()) {
+		case "@@" + var_autocommit, "@@session." + var_autocommit, "@@local." + var_autocommit:
+			for _, row := range qr.Rows {
+				if i < len(row) {
+					row[i] = sqltypes.MakeTrusted(row[i].Type(), []byte(val))
+				}
+			}
+		}
+	}
+}
+
+// rewriteSessionFuncs used to replace the LAST_INSERT_ID(), ROW_COUNT() and FOUND_ROWS() in the
+// select list by the values of the session, returns true if any is replaced.
+func (spanner *Spanner) rewriteSessionFuncs(session *driver.Session, node *sqlparser.Select) bool {
+	txSession := spanner.sessions.getTxnSession(session)
+	if txSession == nil {
+		return false
+	}
+
+	rewritten := false
+	for _, expr := range node.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		fn, ok := aliased.Expr.(*sqlparser.FuncExpr)
+		if !ok || len(fn.Exprs) != 0 || !fn.Qualifier.IsEmpty() {
+			continue
+		}
+
+		var val string
+		switch strings.ToLower(fn.Name.String()) {
+		case "last_insert_id":
+			val = strconv.FormatUint(txSession.getLastInsertID(), 10)
+		case "row_count":
+			val = strconv.FormatInt(txSession.getRowCount(), 10)
+		case "found_rows":
+			val = strconv.FormatUint(txSession.getFoundRows(), 10)
+		default:
+			continue
+		}
+		if aliased.As.IsEmpty() {
+			aliased.As = sqlparser.NewColIdent(sqlparser.String(fn))
+		}
+		aliased.Expr = sqlparser.NewIntVal([]byte(val))
+		rewritten = true
+	}
+	return rewritten
 }
 
 func (spanner *Spanner) handleSelectStream(session *driver.Session, query string, node sqlparser.Statement, callback func(qr *sqltypes.Result) error) error {
@@ -0,0 +1,348 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"config"
+	"forks/go-mysqlstack/driver"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	// CREATE SEQUENCE [IF NOT EXISTS] [db.]name [START [WITH|=] n] [INCREMENT [BY|=] n] [CACHE [=] n | NOCACHE]
+	// DROP SEQUENCE [IF EXISTS] [db.]name
+	createSequenceRE = regexp.MustCompile("(?is)^create\\s+sequence\\s+(if\\s+not\\s+exists\\s+)?([\\w`]+(?:\\.[\\w`]+)?)(.*)$")
+	dropSequenceRE   = regexp.MustCompile("(?is)^drop\\s+sequence\\s+(if\\s+exists\\s+)?([\\w`]+(?:\\.[\\w`]+)?)$")
+	sequenceOptionRE = regexp.MustCompile(`(?is)^\s*(?:(start|increment)(?:\s+with|\s+by)?\s*=?\s*(\d+)|(cache)\s*=?\s*(\d+)|(nocache))`)
+
+	// NEXT VALUE FOR name is the same as NEXTVAL(name).
+	nextValueForRE = regexp.MustCompile("(?i)\\bnext\\s+value\\s+for\\s+([\\w`]+(?:\\.[\\w`]+)?)")
+	// The column DEFAULT NEXTVAL(name) of the create table makes the column auto-increment
+	// by the sequence, see autoincrement.GetAutoIncrement.
+	defaultNextvalRE = regexp.MustCompile("(?i)\\bdefault\\s+nextval\\s*\\(\\s*([\\w`]+(?:\\.[\\w`]+)?)\\s*\\)")
+)
+
+// isSequenceDDL returns true if the query is CREATE SEQUENCE or DROP SEQUENCE.
+func isSequenceDDL(query string) bool {
+	query = strings.TrimSpace(maskQuery(query, false))
+	return createSequenceRE.MatchString(query) || dropSequenceRE.MatchString(query)
+}
+
+// rewriteSequenceSyntax returns the query with the NEXT VALUE FOR replaced by the NEXTVAL(),
+// and the DEFAULT NEXTVAL() of the column replaced by the AUTO_INCREMENT which can be parsed.
+// The string literals, quoted identifiers and comments are left untouched.
+func rewriteSequenceSyntax(query string) string {
+	if !strings.Contains(strings.ToLower(query), "next") {
+		return query
+	}
+	query = replaceOutsideLiterals(nextValueForRE, query, "nextval($1)")
+	return replaceOutsideLiterals(defaultNextvalRE, query, "default 'nextval($1)' auto_increment")
+}
+
+// replaceOutsideLiterals replaces the matches of the re which aren't in the literals or
+// comments of the query with the template.
+func replaceOutsideLiterals(re *regexp.Regexp, query string, template string) string {
+	masked := maskQuery(query, true)
+	matches := re.FindAllStringSubmatchIndex(masked, -1)
+	if matches == nil {
+		return query
+	}
+
+	var buf []byte
+	last := 0
+	for _, match := range matches {
+		buf = append(buf, query[last:match[0]]...)
+		// The groups are expanded from the query, the masked has the same offsets.
+		buf = re.ExpandString(buf, template, query, match)
+		last = match[1]
+	}
+	return string(append(buf, query[last:]...))
+}
+
+// maskQuery returns the query of the same length with the comments replaced by the blanks.
+// If literals is true, the contents of the strings and the quoted identifiers are replaced
+// by the '_' too, so that no keyword can be matched inside them.
+func maskQuery(query string, literals bool) string {
+	buf := []byte(query)
+	mask := func(from, to int, c byte) {
+		for i := from; i < to; i++ {
+			if buf[i] != '\n' {
+				buf[i] = c
+			}
+		}
+	}
+
+	for i := 0; i < len(buf); i++ {
+		switch c := buf[i]; {
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for ; end < len(buf); end++ {
+				if buf[end] == '\\' && c != '`' {
+					end++
+					continue
+				}
+				if buf[end] == c {
+					// The doubled quote is an escaped quote.
+					if end+1 < len(buf) && buf[end+1] == c {
+						end++
+						continue
+					}
+					break
+				}
+			}
+			if end > len(buf) {
+				end = len(buf)
+			}
+			if literals {
+				mask(i+1, end, '_')
+			}
+			i = end
+		case c == '#' || (c == '-' && strings.HasPrefix(query[i:], "-- ")):
+			end := strings.IndexByte(query[i:], '\n')
+			if end < 0 {
+				end = len(query) - i
+			}
+			mask(i, i+end, ' ')
+			i += end
+		case c == '/' && strings.HasPrefix(query[i:], "/*"):
+			end := strings.Index(query[i+2:], "*/")
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += i + 4
+			}
+			mask(i, end, ' ')
+			i = end - 1
+		}
+	}
+	return string(buf)
+}
+
+// handleSequenceDDL used to handle the CREATE SEQUENCE and DROP SEQUENCE.
+func (spanner *Spanner) handleSequenceDDL(session *driver.Session, query string) (*sqltypes.Result, error) {
+	route := spanner.router
+	autoincPlug := spanner.plugins.PlugAutoIncrement()
+
+	var ifExists bool
+	var name, options string
+	action := sqlparser.DropTableStr
+	query = strings.TrimSpace(maskQuery(query, false))
+	if matches := createSequenceRE.FindStringSubmatch(query); matches != nil {
+		action = sqlparser.CreateTableStr
+		ifExists, name, options = matches[1] != "", matches[2], matches[3]
+	} else {
+		matches = dropSequenceRE.FindStringSubmatch(query)
+		ifExists, name = matches[1] != "", matches[2]
+	}
+
+	database, name := splitQualifiedName(session.Schema(), name)
+	if database == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := route.DatabaseACL(database); err != nil {
+		return nil, err
+	}
+	// The sequence is checked as a table.
+	node := &sqlparser.DDL{Action: action, Table: sqlparser.TableName{Name: sqlparser.NewTableIdent(name), Qualifier: sqlparser.NewTableIdent(database)}}
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if err := privilegePlug.Check(database, session.User(), node); err != nil {
+		return nil, err
+	}
+
+	_, err := route.SequenceConfig(database, name)
+	exists := err == nil
+	switch action {
+	case sqlparser.CreateTableStr:
+		conf, err := parseSequenceOptions(name, options)
+		if err != nil {
+			return nil, err
+		}
+		if ifExists && exists {
+			return &sqltypes.Result{Warnings: 1}, nil
+		}
+		if !checkDatabaseExists(database, route) {
+			return nil, sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, database)
+		}
+		if checkTableExists(database, name, route) || exists {
+			return nil, sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, name)
+		}
+		// Remove the values left by the sequence dropped before.
+		if err := autoincPlug.Reset(database, name); err != nil {
+			return nil, err
+		}
+		if err := route.CreateSequence(database, conf); err != nil {
+			return nil, err
+		}
+	default:
+		if !exists {
+			if ifExists {
+				return &sqltypes.Result{Warnings: 1}, nil
+			}
+			return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, fmt.Sprintf("%s.%s", database, name))
+		}
+		if err := route.DropSequence(database, name); err != nil {
+			return nil, err
+		}
+		if err := autoincPlug.Reset(database, name); err != nil {
+			spanner.log.Error("proxy.drop.sequence[%s.%s].reset.error:%+v", database, name, err)
+		}
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// parseSequenceOptions returns the config of the sequence with the options.
+func parseSequenceOptions(name string, options string) (*config.SequenceConfig, error) {
+	conf := &config.SequenceConfig{Name: name, Start: 1, Increment: 1}
+	for strings.TrimSpace(options) != "" {
+		matches := sequenceOptionRE.FindStringSubmatch(options)
+		if matches == nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, fmt.Sprintf("unsupported.sequence.option[%s]", strings.TrimSpace(options)))
+		}
+		options = options[len(matches[0]):]
+
+		var v uint64
+		var err error
+		switch {
+		case matches[1] != "":
+			v, err = strconv.ParseUint(matches[2], 10, 64)
+		case matches[3] != "":
+			v, err = strconv.ParseUint(matches[4], 10, 64)
+		}
+		if err != nil {
+			return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, err.Error())
+		}
+		switch {
+		case strings.EqualFold(matches[1], "start"):
+			conf.Start = v
+		case strings.EqualFold(matches[1], "increment"):
+			conf.Increment = v
+		case matches[3] != "":
+			conf.Cache = v
+		case matches[5] != "":
+			conf.Cache = 1
+		}
+	}
+	if conf.Start == 0 || conf.Increment == 0 {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, "the.start.and.increment.of.sequence.must.be.positive")
+	}
+	return conf, nil
+}
+
+// nextvalName returns the sequence name if the expr is NEXTVAL(name).
+func nextvalName(expr sqlparser.Expr) (string, bool) {
+	fn, ok := expr.(*sqlparser.FuncExpr)
+	if !ok || !fn.Name.EqualString("nextval") || len(fn.Exprs) != 1 {
+		return "", false
+	}
+	aliased, ok := fn.Exprs[0].(*sqlparser.AliasedExpr)
+	if !ok {
+		return "", false
+	}
+	col, ok := aliased.Expr.(*sqlparser.ColName)
+	if !ok {
+		return "", false
+	}
+	return sqlparser.String(col), true
+}
+
+// nextval returns the literal of the next value of the sequence.
+func (spanner *Spanner) nextval(database string, name string) (*sqlparser.SQLVal, error) {
+	database, name = splitQualifiedName(database, name)
+	val, err := spanner.plugins.PlugAutoIncrement().Nextval(database, name, 1)
+	if err != nil {
+		return nil, err
+	}
+	return sqlparser.NewIntVal([]byte(strconv.FormatUint(val, 10))), nil
+}
+
+// evalNextval used to replace the NEXTVAL(name) in the values of the insert by the values
+// generated from the sequences.
+func (spanner *Spanner) evalNextval(database string, node *sqlparser.Insert) error {
+	rows, ok := node.Rows.(sqlparser.Values)
+	if !ok {
+		return nil
+	}
+	for _, row := range rows {
+		for i, expr := range row {
+			name, ok := nextvalName(expr)
+			if !ok {
+				continue
+			}
+			val, err := spanner.nextval(database, name)
+			if err != nil {
+				return err
+			}
+			row[i] = val
+		}
+	}
+	return nil
+}
+
+// rewriteNextval used to replace the NEXTVAL(name) in the select exprs by the values generated
+// from the sequences, returns true if it's rewritten.
+func (spanner *Spanner) rewriteNextval(session *driver.Session, node *sqlparser.Select) (bool, error) {
+	rewritten := false
+	for _, expr := range node.SelectExprs {
+		aliased, ok := expr.(*sqlparser.AliasedExpr)
+		if !ok {
+			continue
+		}
+		name, ok := nextvalName(aliased.Expr)
+		if !ok {
+			continue
+		}
+		val, err := spanner.nextval(session.Schema(), name)
+		if err != nil {
+			return false, err
+		}
+		if aliased.As.IsEmpty() {
+			aliased.As = sqlparser.NewColIdent(sqlparser.String(aliased.Expr))
+		}
+		aliased.Expr = val
+		rewritten = true
+	}
+	return rewritten, nil
+}
+
+// handleSelectNextval used to handle the 'SELECT NEXT n VALUES FROM name', the first of the
+// n values generated is returned.
+func (spanner *Spanner) handleSelectNextval(session *driver.Session, node *sqlparser.Select) (*sqltypes.Result, error) {
+	nextval := node.SelectExprs[0].(sqlparser.Nextval)
+	n, err := strconv.ParseUint(sqlparser.String(nextval.Expr), 10, 64)
+	if err != nil || n == 0 {
+		return nil, sqldb.NewSQLError(sqldb.ER_SYNTAX_ERROR, fmt.Sprintf("invalid.nextval.count[%s]", sqlparser.String(nextval.Expr)))
+	}
+	database := session.Schema()
+	tb := node.From[0].(*sqlparser.AliasedTableExpr).Expr.(sqlparser.TableName)
+	if !tb.Qualifier.IsEmpty() {
+		database = tb.Qualifier.String()
+	}
+	val, err := spanner.plugins.PlugAutoIncrement().Nextval(database, tb.Name.String(), n)
+	if err != nil {
+		return nil, err
+	}
+	return &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "nextval", Type: querypb.Type_UINT64},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_UINT64, []byte(strconv.FormatUint(val, 10)))},
+		},
+		RowsAffected: 1,
+	}, nil
+}
@@ -0,0 +1,204 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxySequenceRewrite(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{
+			"insert into t1(id) values(next value for s1)",
+			"insert into t1(id) values(nextval(s1))",
+		},
+		{
+			"create table t1(id bigint default nextval(test.`s1`), b int)",
+			"create table t1(id bigint default 'nextval(test.`s1`)' auto_increment, b int)",
+		},
+		// The literals, quoted identifiers and comments are left untouched.
+		{
+			"insert into t1(id, b) values(next value for s1, 'next value for s1')",
+			"insert into t1(id, b) values(nextval(s1), 'next value for s1')",
+		},
+		{
+			"select \"it''s \\\" next value for s1\", `next value for s1` from t1 /* next value for s1 */",
+			"select \"it''s \\\" next value for s1\", `next value for s1` from t1 /* next value for s1 */",
+		},
+		{
+			"select next value for s1 -- next value for s2\n, next value for s3 # default nextval(s4)",
+			"select nextval(s1) -- next value for s2\n, nextval(s3) # default nextval(s4)",
+		},
+		{
+			"insert into t1(b) values('default nextval(s1)')",
+			"insert into t1(b) values('default nextval(s1)')",
+		},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, rewriteSequenceSyntax(test.query))
+	}
+
+	// CREATE/DROP SEQUENCE with the comments.
+	assert.True(t, isSequenceDDL("/* x */ create sequence s1 start 5 -- the start"))
+	assert.True(t, isSequenceDDL("drop sequence s1 # dropped"))
+	assert.False(t, isSequenceDDL("select 'create sequence s1'"))
+	assert.False(t, isSequenceDDL("/* create sequence s1 */ select 1"))
+}
+
+func TestProxySequence(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select .* from dual", &sqltypes.Result{})
+	}
+
+	// create database.
+	{
+		client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create database test", -1)
+		assert.Nil(t, err)
+		client.Close()
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "test", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// Create.
+	{
+		_, err := client.FetchAll("create sequence s1", -1)
+		assert.Nil(t, err)
+		conf, err := route.SequenceConfig("test", "s1")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1), conf.Start)
+		assert.Equal(t, uint64(1), conf.Increment)
+
+		_, err = client.FetchAll("CREATE SEQUENCE IF NOT EXISTS test.`s2` START WITH 5 INCREMENT BY 10 CACHE 10", -1)
+		assert.Nil(t, err)
+		conf, err = route.SequenceConfig("test", "s2")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(5), conf.Start)
+		assert.Equal(t, uint64(10), conf.Increment)
+		assert.Equal(t, uint64(10), conf.Cache)
+
+		_, err = client.FetchAll("create sequence if not exists s2 nocache", -1)
+		assert.Nil(t, err)
+		conf, err = route.SequenceConfig("test", "s2")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(10), conf.Cache)
+
+		_, err = client.FetchAll("create sequence s3 /* s3 */ start 3 -- started at 3", -1)
+		assert.Nil(t, err)
+		conf, err = route.SequenceConfig("test", "s3")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(3), conf.Start)
+	}
+
+	// NEXTVAL, the segment reserved from the mock store starts at MockSequenceNextID-cache.
+	{
+		query := "select 1047576 as `nextval(s1)` from dual"
+		_, err := client.FetchAll("select nextval(s1)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query))
+
+		query = "select 1047577 as `nextval(s1)` from dual"
+		_, err = client.FetchAll("select next value for s1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(query))
+
+		qr, err := client.FetchAll("select next 5 values from s1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "1047578", qr.Rows[0][0].String())
+		qr, err = client.FetchAll("select next 1 values from test.s2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "1048575", qr.Rows[0][0].String())
+	}
+
+	// Insert.
+	{
+		_, err := client.FetchAll("create table t1(id bigint, b int) partition by hash(b)", -1)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("explain insert into t1(id, b) values(nextval(s1), 1), (next value for s1, 1)", -1)
+		assert.Nil(t, err)
+		explain := qr.Rows[0][0].String()
+		assert.True(t, strings.Contains(explain, "(1047583, 1), (1047584, 1)"), explain)
+		_, err = client.FetchAll("insert into t1(id, b) values(nextval(s1), 1)", -1)
+		assert.Nil(t, err)
+	}
+
+	// The auto-increment column generated from the sequence.
+	{
+		_, err := client.FetchAll("create table t2(id bigint not null default nextval(s2), b int) partition by hash(b)", -1)
+		assert.Nil(t, err)
+		conf, err := route.TableConfig("test", "t2")
+		assert.Nil(t, err)
+		assert.Equal(t, "id", conf.AutoIncrement.Column)
+		assert.Equal(t, "test.s2", conf.AutoIncrement.Sequence)
+
+		// The mock store reserves the 20 values ending at MockSequenceNextID again.
+		qr, err := client.FetchAll("insert into t2(b) values(1), (2)", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(1048565), qr.InsertID)
+	}
+
+	// Drop.
+	{
+		_, err := client.FetchAll("drop sequence s1", -1)
+		assert.Nil(t, err)
+		_, err = route.SequenceConfig("test", "s1")
+		assert.NotNil(t, err)
+		_, err = client.FetchAll("drop sequence if exists s1", -1)
+		assert.Nil(t, err)
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"create sequence s2",
+			"create sequence t1",
+			"create sequence s3 start with 0",
+			"create sequence s3 maxvalue 10",
+			"create sequence xx.s3",
+			"drop sequence s1",
+			"select nextval(s1)",
+			"select next 0 values from s2",
+			"insert into t1(id, b) values(nextval(s1), 1)",
+			"create table t3(id bigint not null default nextval(s1), b int) partition by hash(b)",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+
+		noDB, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer noDB.Close()
+		_, err = noDB.FetchAll("create sequence s3", -1)
+		assert.NotNil(t, err)
+	}
+}
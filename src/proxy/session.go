@@ -9,12 +9,16 @@
 package proxy
 
 import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"backend"
+	"forks/go-mysqlstack/driver"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -24,6 +28,8 @@ type bitmask uint32
 // session variables capabilities.
 const (
 	cap_streaming_fetch bitmask = 1 << iota // streaming fetch for this session
+	cap_ddl_async                           // the DDL jobs are executed in the background
+	cap_ddl_online                          // the ALTER TABLE of the HASH tables are executed online
 )
 
 type session struct {
@@ -35,6 +41,19 @@ type session struct {
 	timestamp    int64
 	capabilities bitmask
 	transaction  backend.Transaction
+	// groupConcatMaxLen is the group_concat_max_len of the session, 0 means the default.
+	groupConcatMaxLen int
+	// lastInsertID, rowCount and foundRows are the values of the LAST_INSERT_ID(),
+	// ROW_COUNT() and FOUND_ROWS() of the session.
+	lastInsertID uint64
+	rowCount     int64
+	foundRows    uint64
+	// autoIncIncrement and autoIncOffset are the auto_increment_increment and
+	// auto_increment_offset of the session, 0 means the default.
+	autoIncIncrement uint64
+	autoIncOffset    uint64
+	// sysVars are the system variables set by the session, the value is the SQL literal.
+	sysVars map[string]string
 }
 
 func (s *session) setStreamingFetchVar(r bool) {
@@ -49,6 +68,168 @@ func (s *session) getStreamingFetchVar() bool {
 	return s.capabilities&cap_streaming_fetch != 0
 }
 
+func (s *session) setDDLAsyncVar(r bool) {
+	if r {
+		s.capabilities |= cap_ddl_async
+	} else {
+		s.capabilities &= ^cap_ddl_async
+	}
+}
+
+func (s *session) getDDLAsyncVar() bool {
+	return s.capabilities&cap_ddl_async != 0
+}
+
+func (s *session) setDDLOnlineVar(r bool) {
+	if r {
+		s.capabilities |= cap_ddl_online
+	} else {
+		s.capabilities &= ^cap_ddl_online
+	}
+}
+
+func (s *session) getDDLOnlineVar() bool {
+	return s.capabilities&cap_ddl_online != 0
+}
+
+func (s *session) setGroupConcatMaxLen(max int) {
+	s.groupConcatMaxLen = max
+}
+
+func (s *session) getGroupConcatMaxLen() int {
+	return s.groupConcatMaxLen
+}
+
+// setLastInsertID used to set the LAST_INSERT_ID(), it's not changed by the statement without the generated id.
+func (s *session) setLastInsertID(id uint64) {
+	if id != 0 {
+		s.lastInsertID = id
+	}
+}
+
+func (s *session) getLastInsertID() uint64 {
+	return s.lastInsertID
+}
+
+// setRowCount used to set the ROW_COUNT(), -1 if the statement returns rows.
+func (s *session) setRowCount(count int64) {
+	s.rowCount = count
+}
+
+func (s *session) getRowCount() int64 {
+	return s.rowCount
+}
+
+func (s *session) setFoundRows(rows uint64) {
+	s.foundRows = rows
+}
+
+func (s *session) getFoundRows() uint64 {
+	return s.foundRows
+}
+
+func (s *session) setAutoIncIncrement(increment uint64) {
+	s.autoIncIncrement = increment
+}
+
+func (s *session) setAutoIncOffset(offset uint64) {
+	s.autoIncOffset = offset
+}
+
+// getAutoInc returns the auto_increment_increment and auto_increment_offset.
+func (s *session) getAutoInc() (uint64, uint64) {
+	return s.autoIncIncrement, s.autoIncOffset
+}
+
+// setSysVar used to set the system variable of the session.
+func (s *session) setSysVar(name string, val string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sysVars == nil {
+		s.sysVars = make(map[string]string)
+	}
+	s.sysVars[name] = val
+}
+
+// delSysVar used to reset the system variable to the default.
+func (s *session) delSysVar(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sysVars, name)
+}
+
+// setNames used to set the charset variables of the 'SET NAMES', the collation_connection is
+// the default collation of the charset if the collation is empty.
+func (s *session) setNames(charset string, collation string) {
+	s.delSysVar("collation_connection")
+	for _, name := range charsetVarsOf[var_names] {
+		if strings.EqualFold(charset, "default") {
+			s.delSysVar(name)
+		} else {
+			s.setSysVar(name, fmt.Sprintf("'%s'", charset))
+		}
+	}
+	if collation != "" && !strings.EqualFold(charset, "default") {
+		s.setSysVar("collation_connection", fmt.Sprintf("'%s'", collation))
+	}
+}
+
+// getSysVar returns the value of the system variable and true if it's set by the session.
+func (s *session) getSysVar(name string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	val, ok := s.sysVars[name]
+	return val, ok
+}
+
+// timeZoneRE matches the time zone offset, such as '+08:00'.
+var timeZoneRE = regexp.MustCompile(`^([+-])(\d{1,2}):(\d{2})$`)
+
+// getLocation returns the location of the session 'time_zone', which is used to evaluate the
+// time functions by the proxy, the default and 'SYSTEM' are the local time zone.
+func (s *session) getLocation() (*time.Location, error) {
+	val, ok := s.getSysVar("time_zone")
+	if !ok {
+		return time.Local, nil
+	}
+	tz := strings.Trim(val, "'\"")
+	if strings.EqualFold(tz, "system") {
+		return time.Local, nil
+	}
+	if matches := timeZoneRE.FindStringSubmatch(tz); matches != nil {
+		hour, _ := strconv.Atoi(matches[2])
+		minute, _ := strconv.Atoi(matches[3])
+		offset := hour*3600 + minute*60
+		if matches[1] == "-" {
+			offset = -offset
+		}
+		// The same range as MySQL.
+		if minute < 60 && offset >= -(12*3600+59*60) && offset <= 14*3600 {
+			return time.FixedZone(tz, offset), nil
+		}
+	} else if loc, err := time.LoadLocation(tz); err == nil {
+		return loc, nil
+	}
+	return nil, fmt.Errorf("Unknown or incorrect time zone: '%s'", tz)
+}
+
+// getBackendVars returns a copy of the system variables which are applied to the backend connections,
+// the autocommit is handled by the proxy.
+func (s *session) getBackendVars() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.sysVars) == 0 {
+		return nil
+	}
+	vars := make(map[string]string, len(s.sysVars))
+	for name, val := range s.sysVars {
+		if name != var_autocommit {
+			vars[name] = val
+		}
+	}
+	return vars
+}
+
 func newSession(log *xlog.Log, s *driver.Session) *session {
 	log.Debug("session[%v].created", s.ID())
 	return &session{
@@ -14,8 +14,8 @@ import (
 	"time"
 
 	"backend"
+	"forks/go-mysqlstack/driver"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -94,6 +94,38 @@ func (ss *Sessions) getTxnSession(session *driver.Session) *session {
 	return ss.sessions[session.ID()]
 }
 
+// getGroupConcatMaxLen used to get the group_concat_max_len of the session, 0 if the session not found.
+func (ss *Sessions) getGroupConcatMaxLen(session *driver.Session) int {
+	if s := ss.getTxnSession(session); s != nil {
+		return s.getGroupConcatMaxLen()
+	}
+	return 0
+}
+
+// getBackendVars used to get the system variables of the session applied to the backend connections.
+func (ss *Sessions) getBackendVars(session *driver.Session) map[string]string {
+	if s := ss.getTxnSession(session); s != nil {
+		return s.getBackendVars()
+	}
+	return nil
+}
+
+// getDDLAsync returns true if the DDL jobs of the session are executed in the background.
+func (ss *Sessions) getDDLAsync(session *driver.Session) bool {
+	if s := ss.getTxnSession(session); s != nil {
+		return s.getDDLAsyncVar()
+	}
+	return false
+}
+
+// getDDLOnline returns true if the ALTER TABLE of the session are executed online.
+func (ss *Sessions) getDDLOnline(session *driver.Session) bool {
+	if s := ss.getTxnSession(session); s != nil {
+		return s.getDDLOnlineVar()
+	}
+	return false
+}
+
 // getSession used to get current connection session.
 func (ss *Sessions) getSession(id uint32) *session {
 	ss.mu.RLock()
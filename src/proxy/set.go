@@ -10,24 +10,114 @@ package proxy
 
 import (
 	"fmt"
+	"math"
+	"regexp"
+	"strconv"
 	"strings"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
 const (
 	var_radon_streaming_fetch = "radon_streaming_fetch"
+	var_radon_ddl_async       = "radon_ddl_async"
+	var_radon_ddl_online      = "radon_ddl_online"
+	var_group_concat_max_len  = "group_concat_max_len"
+	var_auto_inc_increment    = "auto_increment_increment"
+	var_auto_inc_offset       = "auto_increment_offset"
+	var_autocommit            = "autocommit"
+	var_names                 = "names"
+	var_charset               = "charset"
 )
 
-// handleSet used to handle the SET command.
+// charsetVarsOf are the system variables set by the 'SET NAMES' and 'SET CHARACTER SET'.
+var charsetVarsOf = map[string][]string{
+	var_names:   {"character_set_client", "character_set_connection", "character_set_results"},
+	var_charset: {"character_set_client", "character_set_results"},
+}
+
+// sessionVars are the system variables which can be set by the session, they are applied to the
+// backend connections lazily and reset when the connections are recycled.
+var sessionVars = map[string]bool{
+	"character_set_client":     true,
+	"character_set_connection": true,
+	"character_set_results":    true,
+	"collation_connection":     true,
+	"sql_mode":                 true,
+	"time_zone":                true,
+	"tx_isolation":             true,
+	"transaction_isolation":    true,
+	"tx_read_only":             true,
+	"transaction_read_only":    true,
+	"foreign_key_checks":       true,
+	"unique_checks":            true,
+	"sql_safe_updates":         true,
+	"sql_notes":                true,
+	"sql_warnings":             true,
+	"sql_quote_show_create":    true,
+	"div_precision_increment":  true,
+	"lock_wait_timeout":        true,
+	"innodb_lock_wait_timeout": true,
+	"max_execution_time":       true,
+	"net_read_timeout":         true,
+	"net_write_timeout":        true,
+	"wait_timeout":             true,
+	"interactive_timeout":      true,
+}
+
+// charsetNameRE matches the name of the charset, it's sent to the backends.
+var charsetNameRE = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// setNamesRE matches the 'SET NAMES', the quoted collation can't be parsed by the parser.
+var setNamesRE = regexp.MustCompile(`(?is)^\s*set\s+names\s+['"]?(\w+)['"]?(?:\s+collate\s+['"]?(\w+)['"]?)?\s*;?\s*$`)
+
+// parseSetNames returns the charset and the collation of the 'SET NAMES', false if the query isn't.
+func parseSetNames(query string) (string, string, bool) {
+	matches := setNamesRE.FindStringSubmatch(query)
+	if matches == nil {
+		return "", "", false
+	}
+	return matches[1], matches[2], true
+}
+
+// handleSetNames used to handle the 'SET NAMES', the DEFAULT resets the charset variables.
+func (spanner *Spanner) handleSetNames(session *driver.Session, charset string, collation string) (*sqltypes.Result, error) {
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		txSession.setNames(charset, collation)
+		// The variables changed in the multi-statement txn are applied by its next query.
+		if txSession.transaction != nil {
+			txSession.transaction.SetSessionVars(txSession.getBackendVars())
+		}
+	}
+	return &sqltypes.Result{}, nil
+}
+
+// handleSet used to handle the SET command, the GLOBAL variables, the unknown variables and the
+// user variables are ignored with a warning.
 func (spanner *Spanner) handleSet(session *driver.Session, query string, node *sqlparser.Set) (*sqltypes.Result, error) {
+	var warnings uint16
 	txSession := spanner.sessions.getTxnSession(session)
 	for _, expr := range node.Exprs {
 		name := expr.Name.Lowered()
-		if strings.HasPrefix(name, "@@session.") {
+		if strings.HasPrefix(name, "@@global.") || (node.Scope == sqlparser.GlobalStr && !strings.HasPrefix(name, "@")) {
+			// The GLOBAL variables are not supported.
+			warnings++
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, "@@session."):
 			name = strings.TrimPrefix(name, "@@session.")
+		case strings.HasPrefix(name, "@@local."):
+			name = strings.TrimPrefix(name, "@@local.")
+		case strings.HasPrefix(name, "@@"):
+			name = strings.TrimPrefix(name, "@@")
+		case strings.HasPrefix(name, "@"):
+			// The user variables are not supported.
+			warnings++
+			continue
 		}
 
 		switch name {
@@ -42,6 +132,8 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 						txSession.setStreamingFetchVar(true)
 					case "off":
 						txSession.setStreamingFetchVar(false)
+					default:
+						return nil, fmt.Errorf("Variable '%s' can't be set to the value of '%s'", name, string(expr.Val))
 					}
 				default:
 					return nil, fmt.Errorf("Invalid value type: %v", sqlparser.String(expr))
@@ -52,9 +144,165 @@ func (spanner *Spanner) handleSet(session *driver.Session, query string, node *s
 				} else {
 					txSession.setStreamingFetchVar(false)
 				}
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+		case var_radon_ddl_async, var_radon_ddl_online:
+			var on bool
+			switch expr := expr.Expr.(type) {
+			case *sqlparser.SQLVal:
+				switch expr.Type {
+				case sqlparser.StrVal:
+					switch strings.ToLower(string(expr.Val)) {
+					case "on":
+						on = true
+					case "off":
+						on = false
+					default:
+						return nil, fmt.Errorf("Variable '%s' can't be set to the value of '%s'", name, string(expr.Val))
+					}
+				default:
+					return nil, fmt.Errorf("Invalid value type: %v", sqlparser.String(expr))
+				}
+			case sqlparser.BoolVal:
+				on = bool(expr)
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+			if name == var_radon_ddl_async {
+				txSession.setDDLAsyncVar(on)
+			} else {
+				txSession.setDDLOnlineVar(on)
+			}
+		case var_group_concat_max_len:
+			val, ok := expr.Expr.(*sqlparser.SQLVal)
+			if !ok || val.Type != sqlparser.IntVal {
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+			max, err := strconv.ParseUint(string(val.Val), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+			// The same as MySQL, the value is adjusted to the range.
+			if max < 4 {
+				max = 4
+			} else if max > math.MaxInt32 {
+				max = math.MaxInt32
+			}
+			txSession.setGroupConcatMaxLen(int(max))
+		case var_auto_inc_increment, var_auto_inc_offset:
+			val, ok := expr.Expr.(*sqlparser.SQLVal)
+			if !ok || val.Type != sqlparser.IntVal {
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+			v, err := strconv.ParseUint(string(val.Val), 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+			}
+			// The same as MySQL, the value is adjusted to the range [1, 65535].
+			if v < 1 {
+				v = 1
+			} else if v > math.MaxUint16 {
+				v = math.MaxUint16
+			}
+			if name == var_auto_inc_increment {
+				txSession.setAutoIncIncrement(v)
+			} else {
+				txSession.setAutoIncOffset(v)
+			}
+		case var_autocommit:
+			on := true
+			_, isDefault := expr.Expr.(*sqlparser.Default)
+			if !isDefault {
+				var err error
+				if on, err = boolValueOf(name, expr.Expr); err != nil {
+					return nil, err
+				}
+			}
+			// The autocommit off is mapped to the multi-statement txn, which is begun by the next statement,
+			// it's the local txn without the twopc.
+			// The same as MySQL, the txn in progress is committed if the autocommit is set to on.
+			if on && txSession.transaction != nil {
+				commit := &sqlparser.Transaction{Action: sqlparser.CommitTxnStr}
+				if _, err := spanner.ExecuteCommit(session, sqlparser.CommitTxnStr, commit); err != nil {
+					return nil, err
+				}
+			}
+			switch {
+			case isDefault:
+				txSession.delSysVar(name)
+			case on:
+				txSession.setSysVar(name, "1")
+			default:
+				txSession.setSysVar(name, "0")
+			}
+		case var_names, var_charset:
+			charset := "default"
+			if val, ok := expr.Expr.(*sqlparser.SQLVal); ok {
+				charset = string(val.Val)
+			}
+			if !charsetNameRE.MatchString(strings.ToLower(charset)) {
+				return nil, fmt.Errorf("Unknown character set: '%s'", charset)
+			}
+			if name == var_names {
+				txSession.setNames(charset, "")
+				continue
+			}
+			for _, v := range charsetVarsOf[name] {
+				if strings.EqualFold(charset, "default") {
+					txSession.delSysVar(v)
+				} else {
+					txSession.setSysVar(v, fmt.Sprintf("'%s'", charset))
+				}
+			}
+		default:
+			// The system variables are applied to the backend connections lazily, the value must be a constant.
+			if !sessionVars[name] {
+				warnings++
+				continue
+			}
+			switch val := expr.Expr.(type) {
+			case *sqlparser.Default:
+				txSession.delSysVar(name)
+			case *sqlparser.SQLVal, sqlparser.BoolVal, *sqlparser.NullVal:
+				txSession.setSysVar(name, sqlparser.String(val))
+			case *sqlparser.ColName:
+				// Such as the ON/OFF/SYSTEM.
+				if !val.Qualifier.IsEmpty() || strings.HasPrefix(val.Name.String(), "@") {
+					return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+				}
+				txSession.setSysVar(name, val.Name.String())
+			default:
+				return nil, fmt.Errorf("Incorrect argument type to variable '%s'", name)
 			}
 		}
 	}
-	qr := &sqltypes.Result{Warnings: 1}
+	// The variables changed in the multi-statement txn are applied by its next query.
+	if txSession.transaction != nil {
+		txSession.transaction.SetSessionVars(txSession.getBackendVars())
+	}
+	qr := &sqltypes.Result{Warnings: warnings}
 	return qr, nil
 }
+
+// boolValueOf returns the value of the boolean variable, the same as MySQL, the ON/OFF/TRUE/FALSE/1/0 are allowed.
+func boolValueOf(name string, expr sqlparser.Expr) (bool, error) {
+	var val string
+	switch expr := expr.(type) {
+	case *sqlparser.SQLVal:
+		val = string(expr.Val)
+	case sqlparser.BoolVal:
+		return bool(expr), nil
+	case *sqlparser.ColName:
+		val = expr.Name.String()
+	default:
+		return false, fmt.Errorf("Incorrect argument type to variable '%s'", name)
+	}
+	switch strings.ToLower(val) {
+	case "1", "on", "true":
+		return true, nil
+	case "0", "off", "false":
+		return false, nil
+	}
+	return false, fmt.Errorf("Variable '%s' can't be set to the value of '%s'", name, val)
+}
@@ -9,10 +9,14 @@
 package proxy
 
 import (
+	"errors"
+	"fmt"
 	"testing"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -75,5 +79,292 @@ func TestProxySet(t *testing.T) {
 			_, err := client.FetchAll(query, -1)
 			assert.NotNil(t, err)
 		}
+		{
+			query := "set group_concat_max_len=2048"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set @@SESSION.group_concat_max_len='abc'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
+		{
+			query := "set auto_increment_increment=0, auto_increment_offset=100000"
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err)
+		}
+		{
+			query := "set auto_increment_offset='abc'"
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err)
+		}
+	}
+}
+
+func TestProxySetSessionVars(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	set := "set character_set_client = 'utf8mb4', character_set_connection = 'utf8mb4', character_set_results = 'utf8mb4', collation_connection = 'utf8mb4_bin', foreign_key_checks = off, sql_mode = 'ANSI', time_zone = '+08:00'"
+	reset := "set names 'utf8', foreign_key_checks = DEFAULT, sql_mode = DEFAULT, time_zone = DEFAULT"
+	sqlMode := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "@@sql_mode", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("REAL_AS_FLOAT,PIPES_AS_CONCAT,ANSI_QUOTES,IGNORE_SPACE,ANSI"))},
+		},
+	}
+	autocommit := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "@@autocommit", Type: querypb.Type_INT64},
+			{Name: "@@time_zone", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_INT64, []byte("1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("+08:00")),
+			},
+		},
+	}
+	variables := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Variable_name", Type: querypb.Type_VARCHAR},
+			{Name: "Value", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("autocommit")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("ON")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQuery(set, &sqltypes.Result{})
+		fakedbs.AddQuery(reset, &sqltypes.Result{})
+		fakedbs.AddQuery("select @@sql_mode", sqlMode)
+		fakedbs.AddQuery("select @@autocommit, @@time_zone", autocommit)
+		fakedbs.AddQuery("show variables like 'autocommit'", variables)
+	}
+
+	proxy.SetTwoPC(true)
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// set.
+	{
+		querys := []string{
+			"set sql_mode='ANSI', @@session.time_zone='+08:00'",
+			"SET NAMES 'utf8mb4' COLLATE 'utf8mb4_bin'",
+			"set @@autocommit=0",
+			"set foreign_key_checks=off",
+			"set tx_isolation='READ-COMMITTED'",
+			"set tx_isolation=default",
+			"set @a=1, @b=2",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+	}
+
+	// The variables are applied to the backend connection and reset when it's recycled.
+	{
+		qr, err := client.FetchAll("select @@sql_mode", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, sqlMode.Rows, qr.Rows)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(set))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum(reset))
+	}
+
+	// The autocommit is answered by the session.
+	{
+		qr, err := client.FetchAll("select @@autocommit, @@time_zone", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[0 +08:00]]", fmt.Sprintf("%v", qr.Rows))
+
+		qr, err = client.FetchAll("show variables like 'autocommit'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, "[[autocommit OFF]]", fmt.Sprintf("%v", qr.Rows))
+		assert.Equal(t, 3, fakedbs.GetQueryCalledNum(set))
+	}
+
+	// errors.
+	{
+		querys := []string{
+			"set autocommit='xx'",
+			"set autocommit=1+1",
+			"set character set 'utf8\\', global sql_mode=\\''",
+			"set session sql_mode='ANSI', global time_zone='+08:00'",
+			"set sql_mode=concat(@@sql_mode, ',ANSI')",
+			"set time_zone=@tz",
+			"set foreign_key_checks=1+1",
+			"set radon_ddl_online=1+1",
+			"set radon_streaming_fetch='xx'",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+
+		// The global and the unknown variables are ignored with a warning.
+		querys = []string{
+			"set @@global.wait_timeout=10",
+			"set sql_mode='', @@global.wait_timeout=10",
+			"set global sql_mode='ANSI'",
+			"set `sql_mode = '', global sql_mode`='ANSI'",
+			"set innodb_buffer_pool_size=1024",
+			"set sql_log_bin=0",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+
+		// The invalid value is reported by the backend lazily.
+		_, err := client.FetchAll("set sql_mode='xx'", -1)
+		assert.Nil(t, err)
+		fakedbs.AddQueryErrorPattern("set .* sql_mode = 'xx'.*", errors.New("mock.set.sql_mode.error"))
+		_, err = client.FetchAll("select @@sql_mode", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxySetAutocommit(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("insert .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("XA .*", result1)
+		fakedbs.AddQuery("select 1", &sqltypes.Result{})
+		fakedbs.AddQuery("begin", &sqltypes.Result{})
+		fakedbs.AddQuery("commit", &sqltypes.Result{})
+		fakedbs.AddQuery("rollback", &sqltypes.Result{})
+		fakedbs.AddQuery("set sql_mode = 'ANSI'", &sqltypes.Result{})
+		fakedbs.AddQuery("set sql_mode = DEFAULT", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+
+	// create test table.
+	{
+		querys := []string{
+			"create database test",
+			"create table test.t1(id int, b int) partition by hash(id)",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+	}
+
+	// The local txn is begun by the DML without the twopc, and ended by the ROLLBACK or COMMIT.
+	{
+		querys := []string{
+			"set autocommit=0",
+			"insert into test.t1(id, b) values(1, 1)",
+			"insert into test.t1(id, b) values(1, 1)",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("begin"))
+		for _, s := range proxy.Spanner().sessions.sessions {
+			assert.NotNil(t, s.transaction)
+		}
+		_, err := client.FetchAll("rollback", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("rollback"))
+
+		querys = []string{
+			"insert into test.t1(id, b) values(1, 1)",
+			"commit",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		assert.Equal(t, 2, fakedbs.GetQueryCalledNum("begin"))
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("commit"))
+		for _, s := range proxy.Spanner().sessions.sessions {
+			assert.Nil(t, s.transaction)
+		}
+
+		// The DDL isn't allowed in the txn.
+		_, err = client.FetchAll("insert into test.t1(id, b) values(1, 1)", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("create table test.t2(id int, b int) partition by hash(id)", -1)
+		assert.NotNil(t, err)
+
+		_, err = client.FetchAll("set autocommit=1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 2, fakedbs.GetQueryCalledNum("commit"))
+	}
+
+	proxy.SetTwoPC(true)
+	// The txn is begun by the DML and committed by the COMMIT.
+	{
+		querys := []string{
+			"set autocommit=0",
+			"insert into test.t1(id, b) values(1, 1)",
+			"insert into test.t1(id, b) values(2, 2)",
+			"commit",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+
+		// The SELECT from the dual doesn't begin the txn.
+		_, err := client.FetchAll("select 1", -1)
+		assert.Nil(t, err)
+		_, err = client.FetchAll("commit", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The txn in progress is committed by the SET autocommit=1.
+	{
+		querys := []string{
+			"insert into test.t1(id, b) values(3, 3)",
+			"set autocommit=1",
+			"insert into test.t1(id, b) values(4, 4)",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		_, err := client.FetchAll("commit", -1)
+		assert.NotNil(t, err)
+	}
+
+	// The SET in the txn is applied to the connections of the txn.
+	{
+		querys := []string{
+			"begin",
+			"insert into test.t1(id, b) values(5, 5)",
+			"set sql_mode='ANSI'",
+			"insert into test.t1(id, b) values(5, 5)",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.Nil(t, err, query)
+		}
+		assert.Equal(t, 1, fakedbs.GetQueryCalledNum("set sql_mode = 'ANSI'"))
+		_, err := client.FetchAll("commit", -1)
+		assert.Nil(t, err)
 	}
 }
@@ -0,0 +1,284 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"config"
+	"forks/go-mysqlstack/driver"
+	"router"
+	"xcontext"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	// SHOW TABLE DISTRIBUTION [db.]tbl, SHOW BACKENDS and RADON LOCATE [db.]tbl KEY value.
+	showDistributionRE = regexp.MustCompile("(?is)^show\\s+table\\s+distribution\\s+([\\w`]+(?:\\.[\\w`]+)?)$")
+	showBackendsRE     = regexp.MustCompile(`(?is)^show\s+backends$`)
+	radonLocateRE      = regexp.MustCompile("(?is)^radon\\s+locate\\s+([\\w`]+(?:\\.[\\w`]+)?)\\s+key\\s+(.+)$")
+)
+
+// isShardInfoStmt returns true if the query is the statement of the sharding introspection.
+func isShardInfoStmt(query string) bool {
+	return showDistributionRE.MatchString(query) || showBackendsRE.MatchString(query) || radonLocateRE.MatchString(query)
+}
+
+// handleShardInfoStmt used to handle the SHOW TABLE DISTRIBUTION, SHOW BACKENDS and RADON LOCATE.
+func (spanner *Spanner) handleShardInfoStmt(session *driver.Session, query string) (*sqltypes.Result, error) {
+	if matches := showDistributionRE.FindStringSubmatch(query); matches != nil {
+		return spanner.handleShowTableDistribution(session, matches[1])
+	}
+	if matches := radonLocateRE.FindStringSubmatch(query); matches != nil {
+		return spanner.handleRadonLocate(session, matches[1], matches[2])
+	}
+	return spanner.handleShowBackends(session)
+}
+
+// checkTablePrivilege used to check the user can read the table, the same as SELECT.
+func (spanner *Spanner) checkTablePrivilege(session *driver.Session, name string) (string, string, error) {
+	database, table := splitQualifiedName(session.Schema(), name)
+	if database == "" {
+		return "", "", sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	if err := spanner.router.DatabaseACL(database); err != nil {
+		return "", "", err
+	}
+	node, err := sqlparser.Parse(fmt.Sprintf("select 1 from `%s`.`%s`", database, table))
+	if err != nil {
+		return "", "", err
+	}
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if err := privilegePlug.Check(database, session.User(), node); err != nil {
+		return "", "", err
+	}
+	return database, table, nil
+}
+
+// handleShowTableDistribution used to show the partitions of the table, with the rows and
+// the data size(data_length + index_length) of every partition from the backends.
+// +---------+------------+---------+------+-----------+
+// | Table   | Slot_range | Backend | Rows | Data_size |
+// +---------+------------+---------+------+-----------+
+// | t1_0000 | [0-128)    | node1   |   10 |     16384 |
+// +---------+------------+---------+------+-----------+
+func (spanner *Spanner) handleShowTableDistribution(session *driver.Session, name string) (*sqltypes.Result, error) {
+	database, table, err := spanner.checkTablePrivilege(session, name)
+	if err != nil {
+		return nil, err
+	}
+	segments, err := spanner.router.Lookup(database, table, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// The table status of the partitions on every backend.
+	parts := make(map[string][]string)
+	for _, segment := range segments {
+		parts[segment.Backend] = append(parts[segment.Backend], segment.Table)
+	}
+	backends := make([]string, 0, len(parts))
+	for backend := range parts {
+		backends = append(backends, backend)
+	}
+	sort.Strings(backends)
+	var querys []xcontext.QueryTuple
+	for _, backend := range backends {
+		query := fmt.Sprintf("select table_name, table_rows, data_length + index_length from information_schema.tables where %s", infoSchemaFilter(database, parts[backend]...))
+		querys = append(querys, xcontext.QueryTuple{Query: query, Backend: backend})
+	}
+
+	txn, err := spanner.scatter.CreateTransaction()
+	if err != nil {
+		return nil, err
+	}
+	defer txn.Finish()
+	txn.SetTimeout(spanner.conf.Proxy.QueryTimeout)
+
+	stats := make(map[string][]sqltypes.Value)
+	req := xcontext.NewRequestContext()
+	req.Mode = xcontext.ReqNormal
+	req.Querys = querys
+	statqr, err := txn.Execute(req)
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range statqr.Rows {
+		if len(row) == 3 {
+			stats[row[0].String()] = row[1:]
+		}
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Slot_range", Type: querypb.Type_VARCHAR},
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+		{Name: "Rows", Type: querypb.Type_INT64},
+		{Name: "Data_size", Type: querypb.Type_INT64},
+	}
+	for _, segment := range segments {
+		rows, size := sqltypes.NULL, sqltypes.NULL
+		if stat, ok := stats[segment.Table]; ok {
+			rows = sqltypes.MakeTrusted(querypb.Type_INT64, stat[0].Raw())
+			size = sqltypes.MakeTrusted(querypb.Type_INT64, stat[1].Raw())
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segment.Table)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segmentRange(segment))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segment.Backend)),
+			rows,
+			size,
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// segmentRange returns the slot range of the segment, empty for the global and single table.
+func segmentRange(segment router.Segment) string {
+	if segment.Range == nil {
+		return ""
+	}
+	return segment.Range.String()
+}
+
+// handleShowBackends used to show the backends with the config, the pool stats and the health.
+// The health is checked by 'SELECT 1' on the backend.
+func (spanner *Spanner) handleShowBackends(session *driver.Session) (*sqltypes.Result, error) {
+	privilegePlug := spanner.plugins.PlugPrivilege()
+	if !privilegePlug.IsSuperPriv(session.User()) {
+		return nil, sqldb.NewSQLErrorf(sqldb.ER_SPECIFIC_ACCESS_DENIED_ERROR, "Access denied; lacking super privilege for the operation")
+	}
+
+	scatter := spanner.scatter
+	confs := scatter.BackendConfigsClone()
+	sort.Slice(confs, func(i, j int) bool { return confs[i].Name < confs[j].Name })
+	pools := scatter.PoolClone()
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Name", Type: querypb.Type_VARCHAR},
+		{Name: "Address", Type: querypb.Type_VARCHAR},
+		{Name: "User", Type: querypb.Type_VARCHAR},
+		{Name: "Role", Type: querypb.Type_VARCHAR},
+		{Name: "Max_connections", Type: querypb.Type_INT64},
+		{Name: "Idle_connections", Type: querypb.Type_INT64},
+		{Name: "Pool_hits", Type: querypb.Type_INT64},
+		{Name: "Pool_misses", Type: querypb.Type_INT64},
+		{Name: "Health", Type: querypb.Type_VARCHAR},
+	}
+	for _, conf := range confs {
+		role := "normal"
+		if conf.Role == config.AttachBackend {
+			role = "attach"
+		}
+		var idle int
+		var counts map[string]int64
+		if pool, ok := pools[conf.Name]; ok {
+			idle, counts = pool.Stats()
+		}
+		health := "UP"
+		if _, err := spanner.ExecuteOnThisBackend(conf.Name, "SELECT 1"); err != nil {
+			health = fmt.Sprintf("DOWN(%v)", err)
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(conf.Name)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(conf.Address)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(conf.User)),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(role)),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", conf.MaxConnections))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", idle))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", counts["#pool.hit"]))),
+			sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", counts["#pool.miss"]))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(health)),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
+
+// locateKey returns the value of the key, such as 1, -1, 1.5 or 'a'.
+func locateKey(key string) (*sqlparser.SQLVal, error) {
+	node, err := sqlparser.Parse("select " + key)
+	if err != nil {
+		return nil, err
+	}
+	if sel, ok := node.(*sqlparser.Select); ok && len(sel.SelectExprs) == 1 {
+		if expr, ok := sel.SelectExprs[0].(*sqlparser.AliasedExpr); ok {
+			switch val := expr.Expr.(type) {
+			case *sqlparser.SQLVal:
+				return val, nil
+			case *sqlparser.UnaryExpr:
+				if v, ok := val.Expr.(*sqlparser.SQLVal); ok && val.Operator == sqlparser.UMinusStr && (v.Type == sqlparser.IntVal || v.Type == sqlparser.FloatVal) {
+					return &sqlparser.SQLVal{Type: v.Type, Val: append([]byte("-"), v.Val...)}, nil
+				}
+			}
+		}
+	}
+	return nil, errors.Errorf("unsupported: radon.locate.key[%s]", key)
+}
+
+// handleRadonLocate used to show the segment which the key of the table maps to.
+// The global table is on every backend and the single table has only one segment.
+func (spanner *Spanner) handleRadonLocate(session *driver.Session, name string, key string) (*sqltypes.Result, error) {
+	database, table, err := spanner.checkTablePrivilege(session, name)
+	if err != nil {
+		return nil, err
+	}
+	val, err := locateKey(strings.TrimSpace(key))
+	if err != nil {
+		return nil, err
+	}
+	idx, err := spanner.router.GetIndex(database, table, val)
+	if err != nil {
+		return nil, err
+	}
+	var index []int
+	if idx >= 0 {
+		index = []int{idx}
+	}
+	segments, err := spanner.router.GetSegments(database, table, index)
+	if err != nil {
+		return nil, err
+	}
+
+	qr := &sqltypes.Result{}
+	qr.Fields = []*querypb.Field{
+		{Name: "Table", Type: querypb.Type_VARCHAR},
+		{Name: "Slot", Type: querypb.Type_INT64},
+		{Name: "Slot_range", Type: querypb.Type_VARCHAR},
+		{Name: "Backend", Type: querypb.Type_VARCHAR},
+	}
+	for _, segment := range segments {
+		slot := sqltypes.NULL
+		if idx >= 0 {
+			slot = sqltypes.MakeTrusted(querypb.Type_INT64, []byte(fmt.Sprintf("%d", idx)))
+		}
+		row := []sqltypes.Value{
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segment.Table)),
+			slot,
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segmentRange(segment))),
+			sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(segment.Backend)),
+		}
+		qr.Rows = append(qr.Rows, row)
+	}
+	qr.RowsAffected = uint64(len(qr.Rows))
+	return qr, nil
+}
@@ -0,0 +1,179 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"forks/go-mysqlstack/driver"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestProxyShardInfo(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+	route := proxy.Router()
+
+	// fakedbs.
+	{
+		stats := &sqltypes.Result{
+			Fields: []*querypb.Field{
+				{Name: "table_name", Type: querypb.Type_VARCHAR},
+				{Name: "table_rows", Type: querypb.Type_UINT64},
+				{Name: "data_length + index_length", Type: querypb.Type_UINT64},
+			},
+			Rows: [][]sqltypes.Value{
+				{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1_0000")),
+					sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("10")),
+					sqltypes.MakeTrusted(querypb.Type_UINT64, []byte("16384")),
+				},
+			},
+		}
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("select table_name, table_rows, .*", stats)
+		fakedbs.AddQuery("select 1", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"create table test.t2(id int, b int) global",
+		"create table test.t3(id int, b int) single",
+		"use test",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	// SHOW TABLE DISTRIBUTION.
+	{
+		segments, err := route.Lookup("test", "t1", nil, nil)
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("show table distribution test.t1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, len(segments), len(qr.Rows))
+		for i, row := range qr.Rows {
+			seg := segments[i]
+			assert.Equal(t, fmt.Sprintf("[%s %s %s]", seg.Table, seg.Range.String(), seg.Backend), fmt.Sprintf("%v", row[:3]))
+			if seg.Table == "t1_0000" {
+				assert.Equal(t, "[10 16384]", fmt.Sprintf("%v", row[3:]))
+			} else {
+				assert.True(t, row[3].IsNull())
+				assert.True(t, row[4].IsNull())
+			}
+		}
+
+		qr, err = client.FetchAll("SHOW TABLE DISTRIBUTION t2", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Rows))
+	}
+
+	// RADON LOCATE.
+	{
+		idx, err := route.GetIndex("test", "t1", sqlparser.NewIntVal([]byte("-7")))
+		assert.Nil(t, err)
+		segments, err := route.GetSegments("test", "t1", []int{idx})
+		assert.Nil(t, err)
+		qr, err := client.FetchAll("radon locate t1 key -7", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("[[%s %d %s %s]]", segments[0].Table, idx, segments[0].Range.String(), segments[0].Backend), fmt.Sprintf("%v", qr.Rows))
+
+		idx, err = route.GetIndex("test", "t1", sqlparser.NewStrVal([]byte("abc")))
+		assert.Nil(t, err)
+		qr, err = client.FetchAll("RADON LOCATE test.t1 KEY 'abc'", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, fmt.Sprintf("%d", idx), qr.Rows[0][1].String())
+
+		// The global table is on every backend.
+		qr, err = client.FetchAll("radon locate t2 key 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Rows))
+		qr, err = client.FetchAll("radon locate t3 key 1", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 1, len(qr.Rows))
+	}
+
+	// SHOW BACKENDS.
+	{
+		qr, err := client.FetchAll("show backends", -1)
+		assert.Nil(t, err)
+		assert.Equal(t, 5, len(qr.Rows))
+		for _, row := range qr.Rows {
+			assert.Equal(t, "normal", row[3].String())
+			assert.Equal(t, "UP", row[8].String())
+		}
+
+		fakedbs.AddQueryError("select 1", errors.New("mock.ping.error"))
+		qr, err = client.FetchAll("show backends", -1)
+		assert.Nil(t, err)
+		assert.True(t, strings.HasPrefix(qr.Rows[0][8].String(), "DOWN"), qr.Rows[0][8].String())
+	}
+
+	// Errors.
+	{
+		querys := []string{
+			"show table distribution t4",
+			"show table distribution mysql.user",
+			"radon locate t1 key b+1",
+			"radon locate t4 key 1",
+		}
+		for _, query := range querys {
+			_, err := client.FetchAll(query, -1)
+			assert.NotNil(t, err, query)
+		}
+
+		noDB, err := driver.NewConn("mock", "mock", address, "", "utf8")
+		assert.Nil(t, err)
+		defer noDB.Close()
+		_, err = noDB.FetchAll("show table distribution t1", -1)
+		assert.NotNil(t, err)
+	}
+}
+
+func TestProxyShardInfoPrivilege(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxyPrivilegeN(log, MockDefaultConfig())
+	defer cleanup()
+	address := proxy.Address()
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"show table distribution test.t1",
+		"radon locate test.t1 key 1",
+		"show backends",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.NotNil(t, err, query)
+		assert.True(t, strings.Contains(err.Error(), "Access denied"), err.Error())
+	}
+}
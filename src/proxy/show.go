@@ -18,8 +18,8 @@ import (
 	"time"
 
 	"build"
+	"forks/go-mysqlstack/driver"
 
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqldb"
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/common"
@@ -27,37 +27,34 @@ import (
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
-// handleShowDatabases used to handle the 'SHOW DATABASES' command.
+// handleShowDatabases used to handle the 'SHOW DATABASES [LIKE 'pattern' | WHERE expr]' command.
 func (spanner *Spanner) handleShowDatabases(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
-	qr, err := spanner.ExecuteSingle(query)
+	qr, err := spanner.ExecuteSingle("SHOW DATABASES")
 	if err != nil {
 		return nil, err
 	}
 
+	// The user without the super privilege only sees the databases granted.
 	privilegePlug := spanner.plugins.PlugPrivilege()
-	isSuper := privilegePlug.IsSuperPriv(session.User())
-	if isSuper {
-		return qr, nil
-	} else {
-		isSet := privilegePlug.CheckUserPrivilegeIsSet(session.User())
-		if isSet {
-			return qr, nil
-		} else {
-			newqr := &sqltypes.Result{}
-			for _, row := range qr.Rows {
-				db := string(row[0].Raw())
-				if isExist := privilegePlug.CheckDBinUserPrivilege(session.User(), db); isExist {
-					newqr.RowsAffected++
-					newqr.Rows = append(newqr.Rows, row)
-				}
+	if !privilegePlug.IsSuperPriv(session.User()) && !privilegePlug.CheckUserPrivilegeIsSet(session.User()) {
+		newqr := &sqltypes.Result{}
+		for _, row := range qr.Rows {
+			db := string(row[0].Raw())
+			if isExist := privilegePlug.CheckDBinUserPrivilege(session.User(), db); isExist {
+				newqr.RowsAffected++
+				newqr.Rows = append(newqr.Rows, row)
 			}
+		}
 
-			newqr.Fields = []*querypb.Field{
-				{Name: "Database", Type: querypb.Type_VARCHAR},
-			}
-			return newqr, nil
+		newqr.Fields = []*querypb.Field{
+			{Name: "Database", Type: querypb.Type_VARCHAR},
 		}
+		qr = newqr
+	}
+	if err := filterShowResult(query, qr); err != nil {
+		return nil, err
 	}
+	return qr, nil
 }
 
 // handleShowEngines used to handle the 'SHOW ENGINES' command.
@@ -70,7 +67,7 @@ func (spanner *Spanner) handleShowCreateDatabase(session *driver.Session, query
 	return spanner.ExecuteSingle(query)
 }
 
-// handleShowTableStatus used to handle the 'SHOW TABLE STATUS' command.
+// handleShowTableStatus used to handle the 'SHOW TABLE STATUS [FROM db] [LIKE 'pattern' | WHERE expr]' command.
 // | Name          | Engine | Version | Row_format  | Rows    | Avg_row_length | Data_length | Max_data_length     | Index_length | Data_free            | Auto_increment | Create_time         | Update_time         | Check_time | Collation       | Checksum | Create_options | Comment |
 // +---------------+--------+---------+-------------+---------+----------------+-------------+---------------------+--------------+----------------------+----------------+---------------------+---------------------+------------+-----------------+----------+----------------+---------+
 // | block_0000    | TokuDB |      10 | tokudb_zstd |    6134 |           1395 |     8556930 | 9223372036854775807 |       509122 | 18446744073704837574 |           NULL | 2019-04-24 17:36:10 | 2019-05-04 12:47:45 | NULL       | utf8_general_ci |     NULL |                |
@@ -195,13 +192,18 @@ func (spanner *Spanner) handleShowTableStatus(session *driver.Session, query str
 	qr.RowsAffected = uint64(len)
 	qr.Rows = qr.Rows[0:0]
 	qr.Rows = append(qr.Rows, newqr.Rows...)
+	if err := filterShowResult(query, qr); err != nil {
+		return nil, err
+	}
 	return qr, nil
 }
 
-// handleShowTables used to handle the 'SHOW TABLES' command.
+// handleShowTables used to handle the 'SHOW [FULL] TABLES [FROM db] [LIKE 'pattern' | WHERE expr]' command.
+// The FULL adds the Table_type column, the views are picked from the backends.
 func (spanner *Spanner) handleShowTables(session *driver.Session, query string, node *sqlparser.Show) (*sqltypes.Result, error) {
 	router := spanner.router
 	ast := node
+	full := ast.Type == sqlparser.ShowFullTablesStr
 
 	database := session.Schema()
 	if !ast.Database.IsEmpty() {
@@ -217,7 +219,10 @@ func (spanner *Spanner) handleShowTables(session *driver.Session, query string,
 
 	// For validating the query works, we send it to the backend and check the error.
 	rewritten := fmt.Sprintf("SHOW TABLES FROM %s", database)
-	_, err := spanner.ExecuteScatter(rewritten)
+	if full {
+		rewritten = fmt.Sprintf("SHOW FULL TABLES FROM %s", database)
+	}
+	backendqr, err := spanner.ExecuteScatter(rewritten)
 	if err != nil {
 		return nil, err
 	}
@@ -229,10 +234,34 @@ func (spanner *Spanner) handleShowTables(session *driver.Session, query string,
 		qr.Fields = []*querypb.Field{
 			{Name: fmt.Sprintf("Tables_in_%s", database), Type: querypb.Type_VARCHAR},
 		}
+		if full {
+			qr.Fields = append(qr.Fields, &querypb.Field{Name: "Table_type", Type: querypb.Type_VARCHAR})
+		}
 		for _, table := range tables {
 			row := []sqltypes.Value{sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(table))}
+			if full {
+				row = append(row, sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("BASE TABLE")))
+			}
 			qr.Rows = append(qr.Rows, row)
 		}
+
+		// The views aren't in the router.
+		if full {
+			views := make(map[string]bool)
+			for _, row := range backendqr.Rows {
+				if len(row) < 2 || !strings.EqualFold(row[1].String(), "VIEW") || views[row[0].String()] {
+					continue
+				}
+				views[row[0].String()] = true
+				qr.Rows = append(qr.Rows, []sqltypes.Value{
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, row[0].Raw()),
+					sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("VIEW")),
+				})
+			}
+		}
+	}
+	if err := filterShowResult(query, qr); err != nil {
+		return nil, err
 	}
 	return qr, nil
 }
@@ -326,7 +355,7 @@ func (spanner *Spanner) handleShowCreateTable(session *driver.Session, query str
 	return qr, nil
 }
 
-// handleShowColumns used to handle the 'SHOW COLUMNS' command.
+// handleShowColumns used to handle the 'SHOW COLUMNS FROM tbl [LIKE 'pattern' | WHERE expr]' command.
 func (spanner *Spanner) handleShowColumns(session *driver.Session, query string, node *sqlparser.Show) (*sqltypes.Result, error) {
 	router := spanner.router
 	ast := node
@@ -356,7 +385,9 @@ func (spanner *Spanner) handleShowColumns(session *driver.Session, query string,
 	if err != nil {
 		return nil, err
 	}
-
+	if err := filterShowResult(query, qr); err != nil {
+		return nil, err
+	}
 	return qr, nil
 }
 
@@ -541,6 +572,19 @@ func (spanner *Spanner) handleShowStatus(session *driver.Session, query string,
 		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(backendsJSON)),
 	})
 
+	// 6. plan cache row.
+	var planJSON []byte
+	varname = "radon_plancache"
+	if b, err := json.Marshal(spanner.plans.Status()); err != nil {
+		planJSON = []byte(err.Error())
+	} else {
+		planJSON = b
+	}
+	qr.Rows = append(qr.Rows, []sqltypes.Value{
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(varname)),
+		sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte(planJSON)),
+	})
+
 	return qr, nil
 }
 
@@ -617,6 +661,27 @@ func (spanner *Spanner) handleShowVersions(session *driver.Session, query string
 	return qr, nil
 }
 
+// handleJDBCShows used to handle the SHOW WARNINGS and SHOW VARIABLES, the variables are
+// answered by the backend with the session variables applied, except the autocommit.
 func (spanner *Spanner) handleJDBCShows(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
-	return spanner.ExecuteSingle(query)
+	qr, err := spanner.ExecuteSingleWithSession(session, query)
+	if err != nil {
+		return nil, err
+	}
+	txSession := spanner.sessions.getTxnSession(session)
+	if show, ok := node.(*sqlparser.Show); !ok || show.Type != sqlparser.ShowVariablesStr || txSession == nil {
+		return qr, nil
+	}
+	if val, ok := txSession.getSysVar(var_autocommit); ok {
+		value := "OFF"
+		if val == "1" {
+			value = "ON"
+		}
+		for _, row := range qr.Rows {
+			if len(row) == 2 && strings.EqualFold(row[0].String(), var_autocommit) {
+				row[1] = sqltypes.MakeTrusted(row[1].Type(), []byte(value))
+			}
+		}
+	}
+	return qr, nil
 }
@@ -10,13 +10,15 @@ package proxy
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"forks/go-mysqlstack/driver"
+
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	querypb "github.com/xelabs/go-mysqlstack/sqlparser/depends/query"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
@@ -399,6 +401,106 @@ func TestProxyShowTables(t *testing.T) {
 	}
 }
 
+func TestProxyShowFilter(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	fakedbs, proxy, cleanup := MockProxy(log)
+	defer cleanup()
+	address := proxy.Address()
+
+	fullTables := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Tables_in_test", Type: querypb.Type_VARCHAR},
+			{Name: "Table_type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("t1_0000")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("BASE TABLE")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("v1")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("VIEW")),
+			},
+		},
+	}
+	columns := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "Field", Type: querypb.Type_VARCHAR},
+			{Name: "Type", Type: querypb.Type_VARCHAR},
+		},
+		Rows: [][]sqltypes.Value{
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("id")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("int(11)")),
+			},
+			{
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("b")),
+				sqltypes.MakeTrusted(querypb.Type_VARCHAR, []byte("varchar(10)")),
+			},
+		},
+	}
+
+	// fakedbs.
+	{
+		fakedbs.AddQueryPattern("use .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("create .*", &sqltypes.Result{})
+		fakedbs.AddQuery("show databases", showDatabasesResult)
+		fakedbs.AddQuery("show full tables from test", fullTables)
+		fakedbs.AddQueryPattern("show tables from .*", &sqltypes.Result{})
+		fakedbs.AddQueryPattern("show table status .*", showTableStatusResult1)
+		fakedbs.AddQueryPattern("show columns .*", columns)
+	}
+
+	client, err := driver.NewConn("mock", "mock", address, "", "utf8")
+	assert.Nil(t, err)
+	defer client.Close()
+	querys := []string{
+		"create database test",
+		"create table test.t1(id int, b int) partition by hash(id)",
+		"create table test.t2(id int, b int) global",
+		"create table test.a1(id int, b int) single",
+		"use test",
+	}
+	for _, query := range querys {
+		_, err := client.FetchAll(query, -1)
+		assert.Nil(t, err, query)
+	}
+
+	tests := []struct {
+		query string
+		want  string
+	}{
+		{"show databases like 'test_'", "[[test1]]"},
+		{"show databases where `Database` = 'test'", "[[test]]"},
+		{"show tables like 't%'", "[[t1] [t2]]"},
+		{"show tables from test like 'T2'", "[[t2]]"},
+		{"show tables where Tables_in_test like '%1' and Tables_in_test != 't1'", "[[a1]]"},
+		{"show full tables", "[[a1 BASE TABLE] [t1 BASE TABLE] [t2 BASE TABLE] [v1 VIEW]]"},
+		{"show full tables from test where Table_type = 'VIEW'", "[[v1 VIEW]]"},
+		{"show full tables like 't1';", "[[t1 BASE TABLE]]"},
+		{"show table status like 'a%'", "a"},
+		{"show table status from test where Name in ('c')", "c"},
+		{"show columns from t1 like 'i%'", "[[id int(11)]]"},
+		{"show columns from test.t1 where Type like 'varchar%'", "[[b varchar(10)]]"},
+	}
+	for _, test := range tests {
+		qr, err := client.FetchAll(test.query, -1)
+		assert.Nil(t, err, test.query)
+		// The tables of the router are unordered.
+		sort.Slice(qr.Rows, func(i, j int) bool { return qr.Rows[i][0].String() < qr.Rows[j][0].String() })
+		got := fmt.Sprintf("%v", qr.Rows)
+		if strings.HasPrefix(test.query, "show table status") {
+			assert.Equal(t, 1, len(qr.Rows), test.query)
+			got = qr.Rows[0][0].String()
+		}
+		assert.Equal(t, test.want, got, test.query)
+	}
+
+	// The unknown column.
+	_, err = client.FetchAll("show tables where xx = 1", -1)
+	assert.NotNil(t, err)
+}
+
 func TestProxyShowTableStatus(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	fakedbs, proxy, cleanup := MockProxy(log)
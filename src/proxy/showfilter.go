@@ -0,0 +1,92 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package proxy
+
+import (
+	"fmt"
+	"regexp"
+
+	"expression"
+
+	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqlparser"
+	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
+)
+
+var (
+	// The [LIKE 'pattern' | WHERE expr] of the SHOW statement, it's picked from the query.
+	showFilterRE = regexp.MustCompile(`(?is)\s(like|where)\s+(.+?)[\s;]*$`)
+	// The SHOW statements whose LIKE and WHERE can't be parsed by the parser.
+	showFilterStmtRE = regexp.MustCompile(`(?is)^show\s+(full\s+)?(tables|table\s+status|columns)\s`)
+)
+
+// parseShowWithFilter used to parse the SHOW statement without its LIKE or WHERE,
+// the filter is evaluated on the result by filterShowResult.
+func parseShowWithFilter(query string) (sqlparser.Statement, error) {
+	loc := showFilterRE.FindStringIndex(query)
+	if loc == nil || !showFilterStmtRE.MatchString(query) {
+		return nil, errors.Errorf("unsupported: show.filter[%s]", query)
+	}
+	return sqlparser.Parse(query[:loc[0]])
+}
+
+// showFilter returns the LIKE or WHERE of the SHOW statement as an expression on the result,
+// the LIKE matches the first column. Nil if the statement has no filter.
+func showFilter(query string, qr *sqltypes.Result) (sqlparser.Expr, error) {
+	m := showFilterRE.FindStringSubmatch(query)
+	if m == nil {
+		return nil, nil
+	}
+
+	cond := m[2]
+	if m[1][0] == 'l' || m[1][0] == 'L' {
+		if len(qr.Fields) == 0 {
+			return nil, nil
+		}
+		cond = fmt.Sprintf("`%s` like %s", qr.Fields[0].Name, m[2])
+	}
+	node, err := sqlparser.Parse("select 1 from dual where " + cond)
+	if err != nil {
+		return nil, err
+	}
+	sel, ok := node.(*sqlparser.Select)
+	if !ok || sel.Where == nil {
+		return nil, errors.Errorf("unsupported: show.filter[%s]", m[0])
+	}
+	return sel.Where.Expr, nil
+}
+
+// filterShowResult used to filter the virtual result of the SHOW statement by its LIKE or WHERE.
+func filterShowResult(query string, qr *sqltypes.Result) error {
+	if qr == nil {
+		return nil
+	}
+	expr, err := showFilter(query, qr)
+	if err != nil || expr == nil {
+		return err
+	}
+	eval, err := expression.NewEvaluator(expr, qr.Fields)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]sqltypes.Value, 0, len(qr.Rows))
+	for _, row := range qr.Rows {
+		ok, err := eval.EvalBool(row)
+		if err != nil {
+			return err
+		}
+		if ok {
+			rows = append(rows, row)
+		}
+	}
+	qr.Rows = rows
+	qr.RowsAffected = uint64(len(rows))
+	return nil
+}
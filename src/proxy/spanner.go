@@ -18,7 +18,8 @@ import (
 	"xbase"
 	"xbase/sync2"
 
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
 
@@ -35,6 +36,9 @@ type Spanner struct {
 	plugins       *plugins.Plugin
 	diskChecker   *DiskCheck
 	manager       *Manager
+	ddlJobs       *DDLJobs
+	schemaChecker *SchemaChecker
+	plans         *PlanCache
 	readonly      sync2.AtomicBool
 	serverVersion string
 }
@@ -42,6 +46,10 @@ type Spanner struct {
 // NewSpanner creates a new spanner.
 func NewSpanner(log *xlog.Log, conf *config.Config,
 	iptable *IPTable, router *router.Router, scatter *backend.Scatter, sessions *Sessions, audit *audit.Audit, throttle *xbase.Throttle, plugins *plugins.Plugin, serverVersion string) *Spanner {
+	var plans *PlanCache
+	if conf.Proxy.PlanCacheSize > 0 {
+		plans = NewPlanCache(conf.Proxy.PlanCacheSize)
+	}
 	return &Spanner{
 		log:           log,
 		conf:          conf,
@@ -52,6 +60,7 @@ func NewSpanner(log *xlog.Log, conf *config.Config,
 		sessions:      sessions,
 		throttle:      throttle,
 		plugins:       plugins,
+		plans:         plans,
 		serverVersion: serverVersion,
 	}
 }
@@ -72,6 +81,18 @@ func (spanner *Spanner) Init() error {
 		return err
 	}
 	spanner.manager = mgr
+
+	ddlJobs := NewDDLJobs(log, conf.Proxy.MetaDir)
+	if err := ddlJobs.Init(); err != nil {
+		return err
+	}
+	spanner.ddlJobs = ddlJobs
+
+	schemaChecker := NewSchemaChecker(log, conf.Proxy, spanner.router, spanner.scatter)
+	if err := schemaChecker.Init(); err != nil {
+		return err
+	}
+	spanner.schemaChecker = schemaChecker
 	return nil
 }
 
@@ -79,10 +100,16 @@ func (spanner *Spanner) Init() error {
 func (spanner *Spanner) Close() error {
 	spanner.diskChecker.Close()
 	spanner.manager.Close()
+	spanner.schemaChecker.Close()
 	spanner.log.Info("spanner.closed...")
 	return nil
 }
 
+// SchemaChecker returns the schema drift checker.
+func (spanner *Spanner) SchemaChecker() *SchemaChecker {
+	return spanner.schemaChecker
+}
+
 // ReadOnly returns the readonly or not.
 func (spanner *Spanner) ReadOnly() bool {
 	return spanner.readonly.Get()
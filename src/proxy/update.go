@@ -9,7 +9,8 @@
 package proxy
 
 import (
-	"github.com/xelabs/go-mysqlstack/driver"
+	"forks/go-mysqlstack/driver"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
@@ -17,5 +18,12 @@ import (
 // handleUpdate used to handle the update command.
 func (spanner *Spanner) handleUpdate(session *driver.Session, query string, node sqlparser.Statement) (*sqltypes.Result, error) {
 	database := session.Schema()
-	return spanner.ExecuteDML(session, database, query, node)
+	qr, err := spanner.ExecuteDML(session, database, query, node)
+	if err != nil {
+		return nil, err
+	}
+	if txSession := spanner.sessions.getTxnSession(session); txSession != nil {
+		txSession.setRowCount(int64(qr.RowsAffected))
+	}
+	return qr, nil
 }
@@ -12,9 +12,9 @@ import (
 	"testing"
 
 	"fakedb"
+	"forks/go-mysqlstack/driver"
 
 	"github.com/stretchr/testify/assert"
-	"github.com/xelabs/go-mysqlstack/driver"
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 	"github.com/xelabs/go-mysqlstack/xlog"
 )
@@ -13,10 +13,12 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"sync/atomic"
 
 	"config"
 
 	"github.com/pkg/errors"
+	"github.com/xelabs/go-mysqlstack/sqldb"
 )
 
 const (
@@ -165,6 +167,47 @@ func (r *Router) CreateTable(db, table, shardKey string, tableType string, backe
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
+	if err := r.stageTable(db, table, shardKey, tableType, backends, extra); err != nil {
+		return err
+	}
+	return r.commitTable(db, table)
+}
+
+// StageTable used to stage a table in router, so the partitions can be created by the plan of
+// the CREATE TABLE. The staged table is invisible to the other plans and isn't on disk, it's
+// published by CommitTable or removed by DiscardTable.
+// Lock.
+func (r *Router) StageTable(db, table, shardKey string, tableType string, backends []string, extra *Extra) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.stageTable(db, table, shardKey, tableType, backends, extra)
+}
+
+// CommitTable used to publish the staged table to router and flush the schema to disk.
+// Lock.
+func (r *Router) CommitTable(db, table string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.commitTable(db, table)
+}
+
+// DiscardTable used to remove the staged table from router, the schema isn't on disk.
+// Lock.
+func (r *Router) DiscardTable(db, table string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.staged[db][table]; !ok {
+		return errors.Errorf("router.can.not.find.staged.table[%v.%v]", db, table)
+	}
+	delete(r.staged[db], table)
+	if len(r.staged[db]) == 0 {
+		delete(r.staged, db)
+	}
+	return nil
+}
+
+func (r *Router) stageTable(db, table, shardKey string, tableType string, backends []string, extra *Extra) error {
 	var err error
 	var tableConf *config.TableConfig
 	log := r.log
@@ -191,18 +234,62 @@ func (r *Router) CreateTable(db, table, shardKey string, tableType string, backe
 	if extra != nil {
 		tableConf.AutoIncrement = extra.AutoIncrement
 	}
+	if db == "" {
+		return errors.New("db.can't.be.null")
+	}
+	if schema, ok := r.Schemas[db]; ok {
+		if _, ok := schema.Sequences[table]; ok {
+			return sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, table)
+		}
+		if _, ok := schema.Tables[table]; ok {
+			return errors.Errorf("router.add.db[%v].table[%v].exists", db, table)
+		}
+	}
+	if _, ok := r.staged[db][table]; ok {
+		return errors.Errorf("router.add.db[%v].table[%v].exists", db, table)
+	}
 
-	// add config to router.
-	if err = r.addTable(db, tableConf); err != nil {
-		log.Error("frm.create.add.route.error:%v", err)
+	t, err := r.newTable(tableConf)
+	if err != nil {
+		log.Error("frm.create.stage.route.error:%v", err)
 		return err
 	}
-	if err = r.writeTableFrmData(db, table, tableConf); err != nil {
-		log.Error("frm.create.table[db:%v, table:%v].file.error:%+v", db, tableConf.Name, err)
+	if _, ok := r.staged[db]; !ok {
+		r.staged[db] = make(map[string]*Table)
+	}
+	r.staged[db][table] = t
+	return nil
+}
+
+func (r *Router) commitTable(db, table string) error {
+	log := r.log
+	t, ok := r.staged[db][table]
+	if !ok {
+		return errors.Errorf("router.can.not.find.staged.table[%v.%v]", db, table)
+	}
+	delete(r.staged[db], table)
+	if len(r.staged[db]) == 0 {
+		delete(r.staged, db)
+	}
+
+	// publish the table to router.
+	schema, ok := r.Schemas[db]
+	if !ok {
+		schema = &Schema{DB: db, Tables: make(map[string]*Table)}
+		r.Schemas[db] = schema
+	}
+	if _, ok := schema.Tables[table]; ok {
+		return errors.Errorf("router.add.db[%v].table[%v].exists", db, table)
+	}
+	schema.Tables[table] = t
+	atomic.AddUint64(&r.version, 1)
+
+	if err := r.writeTableFrmData(db, table, t.TableConfig); err != nil {
+		log.Error("frm.create.table[db:%v, table:%v].file.error:%+v", db, table, err)
 		return err
 	}
 
-	if err = config.UpdateVersion(r.metadir); err != nil {
+	if err := config.UpdateVersion(r.metadir); err != nil {
 		log.Panicf("frm.create.table.update.version.error:%v", err)
 		return err
 	}
@@ -368,6 +455,10 @@ func (r *Router) LoadConfig() error {
 				return err
 			}
 		}
+		if err := r.loadSequences(k); err != nil {
+			log.Error("router.load.sequences.of.db[%v].error:%+v", k, err)
+			return err
+		}
 	}
 	return nil
 }
@@ -167,6 +167,69 @@ func TestFrmTableError(t *testing.T) {
 	}
 }
 
+func TestFrmStageTable(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	backends := []string{"backend1", "backend2"}
+
+	// Stage and commit.
+	{
+		err := router.StageTable("test", "t1", "id", "", backends, nil)
+		assert.Nil(t, err)
+		assert.False(t, checkFileExistsForTest(router, "test", "t1"))
+		// The staged table is invisible.
+		_, err = router.TableConfig("test", "t1")
+		assert.NotNil(t, err)
+		_, err = router.Lookup("test", "t1", nil, nil)
+		assert.NotNil(t, err)
+		assert.Equal(t, 0, len(router.Tables()["test"]))
+		shardKey, segments, err := router.LookupStaged("test", "t1")
+		assert.Nil(t, err)
+		assert.Equal(t, "id", shardKey)
+		assert.Equal(t, 32, len(segments))
+
+		// Stage the table again.
+		err = router.StageTable("test", "t1", "id", "", backends, nil)
+		assert.NotNil(t, err)
+
+		err = router.CommitTable("test", "t1")
+		assert.Nil(t, err)
+		assert.True(t, checkFileExistsForTest(router, "test", "t1"))
+		_, err = router.TableConfig("test", "t1")
+		assert.Nil(t, err)
+		_, _, err = router.LookupStaged("test", "t1")
+		assert.NotNil(t, err)
+
+		// Stage the table exists.
+		err = router.StageTable("test", "t1", "id", "", backends, nil)
+		assert.NotNil(t, err)
+	}
+
+	// Stage and discard.
+	{
+		err := router.StageTable("test", "t2", "id", "", backends, nil)
+		assert.Nil(t, err)
+		err = router.DiscardTable("test", "t2")
+		assert.Nil(t, err)
+		err = router.DiscardTable("test", "t2")
+		assert.NotNil(t, err)
+		assert.False(t, checkFileExistsForTest(router, "test", "t2"))
+		_, err = router.TableConfig("test", "t2")
+		assert.NotNil(t, err)
+	}
+
+	// Commit the table not staged.
+	{
+		err := router.CommitTable("test", "t3")
+		assert.NotNil(t, err)
+		err = router.CommitTable("xx", "t3")
+		assert.NotNil(t, err)
+	}
+}
+
 func TestFrmDropDatabase(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	router, cleanup := MockNewRouter(log)
@@ -234,11 +297,15 @@ func TestFrmLoad(t *testing.T) {
 		// load.
 		err := router1.LoadConfig()
 		assert.Nil(t, err)
+		// The version is increased by each load.
+		router1.version = router.version
 		assert.Equal(t, router, router1)
 
 		// load again.
 		err = router1.LoadConfig()
 		assert.Nil(t, err)
+		// The version is increased by each load.
+		router1.version = router.version
 		assert.Equal(t, router, router1)
 	}
 }
@@ -332,6 +399,8 @@ func TestFrmDatabaseNoTables(t *testing.T) {
 		// load.
 		err := router1.LoadConfig()
 		assert.Nil(t, err)
+		// The version is increased by each load.
+		router1.version = router.version
 		assert.Equal(t, router, router1)
 	}
 
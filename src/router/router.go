@@ -12,6 +12,7 @@ import (
 	"encoding/json"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"config"
 
@@ -44,6 +45,8 @@ type Schema struct {
 	DB string `json:",omitempty"`
 	// tables map, key is table name
 	Tables map[string]*Table `json:",omitempty"`
+	// sequences map, key is sequence name
+	Sequences map[string]*config.SequenceConfig `json:",omitempty"`
 }
 
 // Router tuple.
@@ -53,9 +56,15 @@ type Router struct {
 	metadir string
 	dbACL   *DatabaseACL
 	conf    *config.RouterConfig
+	// version is increased on every change of the schemas.
+	version uint64
 
 	// schemas map, key is database name
 	Schemas map[string]*Schema `json:",omitempty"`
+
+	// staged map, key is database name, value is the tables staged by the CREATE TABLE,
+	// they're invisible to the planning until committed.
+	staged map[string]map[string]*Table
 }
 
 // NewRouter creates the new router.
@@ -66,6 +75,7 @@ func NewRouter(log *xlog.Log, metadir string, conf *config.RouterConfig) *Router
 		conf:    conf,
 		dbACL:   NewDatabaseACL(),
 		Schemas: make(map[string]*Schema),
+		staged:  make(map[string]map[string]*Table),
 	}
 	return route
 }
@@ -74,7 +84,6 @@ func NewRouter(log *xlog.Log, metadir string, conf *config.RouterConfig) *Router
 func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 	var ok bool
 	var schema *Schema
-	var table *Table
 
 	if db == "" {
 		return errors.New("db.can't.be.null")
@@ -82,6 +91,7 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 	if tbl == nil {
 		return errors.New("table.config..can't.be.nil")
 	}
+	atomic.AddUint64(&r.version, 1)
 
 	// schema
 	if schema, ok = r.Schemas[db]; !ok {
@@ -90,16 +100,24 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 	}
 
 	// table
-	if _, ok = schema.Tables[tbl.Name]; !ok {
-		table = &Table{
-			Name:        tbl.Name,
-			ShardKey:    tbl.ShardKey,
-			TableConfig: tbl,
-		}
-		schema.Tables[tbl.Name] = table
-	} else {
+	if _, ok = schema.Tables[tbl.Name]; ok {
 		return errors.Errorf("router.add.db[%v].table[%v].exists", db, tbl.Name)
 	}
+	table, err := r.newTable(tbl)
+	if err != nil {
+		return err
+	}
+	schema.Tables[tbl.Name] = table
+	return nil
+}
+
+// newTable -- used to create a table router with the partition method built.
+func (r *Router) newTable(tbl *config.TableConfig) (*Table, error) {
+	table := &Table{
+		Name:        tbl.Name,
+		ShardKey:    tbl.ShardKey,
+		TableConfig: tbl,
+	}
 
 	// methods
 	switch tbl.ShardType {
@@ -110,25 +128,25 @@ func (r *Router) addTable(db string, tbl *config.TableConfig) error {
 		}
 		hash := NewHash(r.log, slots, tbl)
 		if err := hash.Build(); err != nil {
-			return err
+			return nil, err
 		}
 		table.Partition = hash
 	case methodTypeGlobal:
 		global := NewGlobal(r.log, tbl)
 		if err := global.Build(); err != nil {
-			return err
+			return nil, err
 		}
 		table.Partition = global
 	case methodTypeSingle:
 		single := NewSingle(r.log, tbl)
 		if err := single.Build(); err != nil {
-			return err
+			return nil, err
 		}
 		table.Partition = single
 	default:
-		return errors.Errorf("router.unsupport.shardtype:[%v]", tbl.ShardType)
+		return nil, errors.Errorf("router.unsupport.shardtype:[%v]", tbl.ShardType)
 	}
-	return nil
+	return table, nil
 }
 
 // removeTable -- used to remove a table router from schema map.
@@ -146,6 +164,7 @@ func (r *Router) removeTable(db string, table string) error {
 	}
 	// remove
 	delete(schema.Tables, table)
+	atomic.AddUint64(&r.version, 1)
 	return nil
 }
 
@@ -177,6 +196,7 @@ func (r *Router) addDatabase(db string) error {
 	if _, ok := r.Schemas[db]; !ok {
 		schema := &Schema{DB: db, Tables: make(map[string]*Table)}
 		r.Schemas[db] = schema
+		atomic.AddUint64(&r.version, 1)
 		return nil
 	}
 	return errors.Errorf("router.database.exists")
@@ -187,12 +207,19 @@ func (r *Router) dropDatabase(db string) error {
 		return errors.Errorf("router.can.not.find.db[%v]", db)
 	}
 	delete(r.Schemas, db)
+	atomic.AddUint64(&r.version, 1)
 	return nil
 }
 
 // clear used to reset Schemas to new.
 func (r *Router) clear() {
 	r.Schemas = make(map[string]*Schema)
+	atomic.AddUint64(&r.version, 1)
+}
+
+// Version returns the version of the router, which is changed if the schemas changed.
+func (r *Router) Version() uint64 {
+	return atomic.LoadUint64(&r.version)
 }
 
 // DatabaseACL used to check wheather the database is a system database.
@@ -296,6 +323,24 @@ func (r *Router) Lookup(database string, tableName string, startKey *sqlparser.S
 	return partInfos, nil
 }
 
+// LookupStaged used to lookup the shard key and the partitions of the staged table, the table
+// isn't visible to Lookup until it's committed, so only the plan of the CREATE TABLE resolves it.
+func (r *Router) LookupStaged(database string, tableName string) (string, []Segment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	table, ok := r.staged[database][tableName]
+	if !ok {
+		return "", nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, tableName)
+	}
+	partInfos, err := table.Partition.Lookup(nil, nil)
+	if err != nil {
+		r.log.Error("router.staged.partition.lookup.error:%+v", err)
+		return "", nil, err
+	}
+	return table.ShardKey, partInfos, nil
+}
+
 // Tables returns all the tables.
 func (r *Router) Tables() map[string][]string {
 	r.mu.RLock()
@@ -247,6 +247,27 @@ func TestRouterRemove(t *testing.T) {
 	}
 }
 
+func TestRouterVersion(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+	assert.NotNil(t, router)
+
+	version := router.Version()
+	err := router.addTable("sbtest", MockTableMConfig())
+	assert.Nil(t, err)
+	assert.True(t, router.Version() > version)
+
+	version = router.Version()
+	_, err = router.Lookup("sbtest", "A", nil, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, version, router.Version())
+
+	err = router.removeTable("sbtest", MockTableMConfig().Name)
+	assert.Nil(t, err)
+	assert.True(t, router.Version() > version)
+}
+
 func TestRouterLookup(t *testing.T) {
 	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
 	router, cleanup := MockNewRouter(log)
@@ -0,0 +1,169 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync/atomic"
+
+	"config"
+
+	"github.com/xelabs/go-mysqlstack/sqldb"
+)
+
+const (
+	// sequenceDir is the sub directory of the database dir where the sequences are stored.
+	sequenceDir = "_sequences"
+)
+
+// writeSequenceFrmData used to write sequence's json to file.
+// The file name is : [schema-dir]/[database]/_sequences/[sequence].json.
+func (r *Router) writeSequenceFrmData(db string, conf *config.SequenceConfig) error {
+	log := r.log
+	dir := path.Join(r.metadir, db, sequenceDir)
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if x := os.MkdirAll(dir, os.ModePerm); x != nil {
+			log.Error("frm.write.sequence.mkdir[%v].error:%v", dir, x)
+			return x
+		}
+	}
+
+	file := path.Join(dir, fmt.Sprintf("%s.json", conf.Name))
+	if err := config.WriteConfig(file, conf); err != nil {
+		log.Error("frm.write.sequence.to.file[%v].error:%v", file, err)
+		return err
+	}
+	return nil
+}
+
+// loadSequences used to load the sequences of the database from the files.
+func (r *Router) loadSequences(db string) error {
+	log := r.log
+	dir := path.Join(r.metadir, db, sequenceDir)
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.Error("frm.load.sequences.readdir[%v].error:%v", dir, err)
+		return err
+	}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		file := path.Join(dir, f.Name())
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			log.Error("frm.load.sequence.read.file[%v].error:%v", file, err)
+			return err
+		}
+		conf, err := config.ReadSequenceConfig(string(data))
+		if err != nil {
+			log.Error("frm.load.sequence.parse.json.file[%v].error:%v", file, err)
+			return err
+		}
+		if err := r.addSequence(db, conf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addSequence used to add the sequence to the schema.
+func (r *Router) addSequence(db string, conf *config.SequenceConfig) error {
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, db)
+	}
+	if _, ok := schema.Tables[conf.Name]; ok {
+		return sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, conf.Name)
+	}
+	if _, ok := schema.Sequences[conf.Name]; ok {
+		return sqldb.NewSQLError(sqldb.ER_TABLE_EXISTS_ERROR, conf.Name)
+	}
+	if schema.Sequences == nil {
+		schema.Sequences = make(map[string]*config.SequenceConfig)
+	}
+	schema.Sequences[conf.Name] = conf
+	atomic.AddUint64(&r.version, 1)
+	return nil
+}
+
+// CreateSequence used to add a sequence to router and flush it to disk.
+func (r *Router) CreateSequence(db string, conf *config.SequenceConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log := r.log
+	if err := r.addSequence(db, conf); err != nil {
+		log.Error("frm.create.sequence[%s.%s].error:%v", db, conf.Name, err)
+		return err
+	}
+	if err := r.writeSequenceFrmData(db, conf); err != nil {
+		delete(r.Schemas[db].Sequences, conf.Name)
+		return err
+	}
+	if err := config.UpdateVersion(r.metadir); err != nil {
+		log.Panicf("frm.create.sequence.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// DropSequence used to remove a sequence from router and remove the file from disk.
+func (r *Router) DropSequence(db, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	log := r.log
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, db)
+	}
+	if _, ok := schema.Sequences[name]; !ok {
+		return sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, fmt.Sprintf("%s.%s", db, name))
+	}
+	delete(schema.Sequences, name)
+	atomic.AddUint64(&r.version, 1)
+
+	file := path.Join(r.metadir, db, sequenceDir, fmt.Sprintf("%s.json", name))
+	log.Warning("frm.remove.sequence.file[%v]", file)
+	if err := os.Remove(file); err != nil {
+		log.Error("frm.drop.sequence[%s.%s].remove.file.error:%v", db, name, err)
+		return err
+	}
+	if err := config.UpdateVersion(r.metadir); err != nil {
+		log.Panicf("frm.drop.sequence.update.version.error:%v", err)
+		return err
+	}
+	return nil
+}
+
+// SequenceConfig returns the config of the sequence.
+func (r *Router) SequenceConfig(db, name string) (*config.SequenceConfig, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if db == "" {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_DB_ERROR)
+	}
+	schema, ok := r.Schemas[db]
+	if !ok {
+		return nil, sqldb.NewSQLError(sqldb.ER_BAD_DB_ERROR, db)
+	}
+	conf, ok := schema.Sequences[name]
+	if !ok {
+		return nil, sqldb.NewSQLError(sqldb.ER_NO_SUCH_TABLE, fmt.Sprintf("%s.%s", db, name))
+	}
+	return conf, nil
+}
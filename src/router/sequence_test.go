@@ -0,0 +1,93 @@
+/*
+ * Radon
+ *
+ * Copyright 2019 The Radon Authors.
+ * Code is licensed under the GPLv3.
+ *
+ */
+
+package router
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/xelabs/go-mysqlstack/xlog"
+)
+
+func TestFrmSequence(t *testing.T) {
+	log := xlog.NewStdLog(xlog.Level(xlog.PANIC))
+	router, cleanup := MockNewRouter(log)
+	defer cleanup()
+
+	router.CreateDatabase("test")
+	backends := []string{"backend1", "backend2"}
+	err := router.CreateTable("test", "t1", "id", "", backends, nil)
+	assert.Nil(t, err)
+
+	// Create.
+	{
+		err := router.CreateSequence("test", &config.SequenceConfig{Name: "s1", Start: 10, Increment: 2})
+		assert.Nil(t, err)
+		_, err = os.Stat(path.Join(router.metadir, "test", sequenceDir, "s1.json"))
+		assert.Nil(t, err)
+
+		conf, err := router.SequenceConfig("test", "s1")
+		assert.Nil(t, err)
+		assert.Equal(t, &config.SequenceConfig{Name: "s1", Start: 10, Increment: 2}, conf)
+	}
+
+	// Load.
+	{
+		err := router.LoadConfig()
+		assert.Nil(t, err)
+		conf, err := router.SequenceConfig("test", "s1")
+		assert.Nil(t, err)
+		assert.Equal(t, uint64(10), conf.Start)
+		_, err = router.TableConfig("test", "t1")
+		assert.Nil(t, err)
+	}
+
+	// The sequence and the table share the names.
+	{
+		err := router.CreateSequence("test", &config.SequenceConfig{Name: "s1"})
+		assert.EqualError(t, err, "Table 's1' already exists (errno 1050) (sqlstate 42S01)")
+		err = router.CreateSequence("test", &config.SequenceConfig{Name: "t1"})
+		assert.EqualError(t, err, "Table 't1' already exists (errno 1050) (sqlstate 42S01)")
+		err = router.CreateTable("test", "s1", "id", "", backends, nil)
+		assert.EqualError(t, err, "Table 's1' already exists (errno 1050) (sqlstate 42S01)")
+	}
+
+	// Drop.
+	{
+		err := router.DropSequence("test", "s1")
+		assert.Nil(t, err)
+		_, err = router.SequenceConfig("test", "s1")
+		assert.EqualError(t, err, "Table 'test.s1' doesn't exist (errno 1146) (sqlstate 42S02)")
+		_, err = os.Stat(path.Join(router.metadir, "test", sequenceDir, "s1.json"))
+		assert.True(t, os.IsNotExist(err))
+
+		err = router.LoadConfig()
+		assert.Nil(t, err)
+		_, err = router.SequenceConfig("test", "s1")
+		assert.NotNil(t, err)
+	}
+
+	// Errors.
+	{
+		err := router.CreateSequence("xx", &config.SequenceConfig{Name: "s1"})
+		assert.NotNil(t, err)
+		err = router.DropSequence("xx", "s1")
+		assert.NotNil(t, err)
+		err = router.DropSequence("test", "s1")
+		assert.NotNil(t, err)
+		_, err = router.SequenceConfig("", "s1")
+		assert.NotNil(t, err)
+		_, err = router.SequenceConfig("xx", "s1")
+		assert.NotNil(t, err)
+	}
+}
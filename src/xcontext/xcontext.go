@@ -9,6 +9,8 @@
 package xcontext
 
 import (
+	"time"
+
 	"github.com/xelabs/go-mysqlstack/sqlparser/depends/sqltypes"
 )
 
@@ -56,6 +58,13 @@ type RequestContext struct {
 	Mode     RequestMode
 	TxnMode  TxnMode
 	Querys   []QueryTuple
+	// Trace is called after each query executed on the backend if it's set,
+	// used to collect the latency and the rows for the EXPLAIN ANALYZE and the progress of the DDL job.
+	Trace func(backend string, query string, latency time.Duration, rows int)
+	// FoundRows is called with the FOUND_ROWS() of each query if it's set, which is fetched on the
+	// same connection after the query, used by the SQL_CALC_FOUND_ROWS pushed down to the shards.
+	// It may be called by the shards concurrently.
+	FoundRows func(rows uint64)
 }
 
 // NewRequestContext creates RequestContext